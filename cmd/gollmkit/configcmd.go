@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// runConfig dispatches the config subcommands
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand (available: audit)")
+	}
+
+	switch args[0] {
+	case "audit":
+		return runConfigAudit(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q (available: audit)", args[0])
+	}
+}
+
+// runConfigAudit reports security problems in a config file
+func runConfigAudit(args []string) error {
+	fs := flag.NewFlagSet("config audit", flag.ContinueOnError)
+	configPath := fs.String("config", "gollmkit-config.yaml", "path to the gollmkit config file")
+	asJSON := fs.Bool("json", false, "print findings as JSON")
+	failOn := fs.String("fail-on", string(config.SeverityHigh), "exit with an error if a finding of at least this severity exists (high, medium, low, none)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	findings, err := config.AuditFile(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if findings == nil {
+			findings = []config.Finding{}
+		}
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	} else if len(findings) == 0 {
+		fmt.Println("No issues found")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SEVERITY\tPATH\tMESSAGE")
+		for _, f := range findings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Severity, f.Path, f.Message)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if *failOn == "none" {
+		return nil
+	}
+	threshold := config.Severity(*failOn)
+	for _, f := range findings {
+		if f.Severity.AtLeast(threshold) {
+			return fmt.Errorf("found issues of severity %s or higher", threshold)
+		}
+	}
+	return nil
+}
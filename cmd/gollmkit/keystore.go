@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// runKeyStore dispatches the keystore subcommands
+func runKeyStore(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand (available: migrate, reencrypt)")
+	}
+
+	switch args[0] {
+	case "migrate":
+		return runKeyStoreMigrate(args[1:])
+	case "reencrypt":
+		return runKeyStoreReencrypt(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q (available: migrate, reencrypt)", args[0])
+	}
+}
+
+// runKeyStoreMigrate moves the plaintext keys of a config file into its file
+// or keychain key store and rewrites the file to reference them by name
+func runKeyStoreMigrate(args []string) error {
+	fs := flag.NewFlagSet("keystore migrate", flag.ContinueOnError)
+	configPath := fs.String("config", "gollmkit-config.yaml", "path to the gollmkit config file")
+	path := fs.String("path", "", "key store file (default global.key_store.path of the config)")
+	keyEnv := fs.String("key-env", "", "environment variable holding the file store passphrase")
+	keyFile := fs.String("key-file", "", "file holding the file store passphrase")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var store auth.KeyStore
+	switch cfg.Global.KeyStore.Type {
+	case config.KeyStoreFile:
+		if *path == "" {
+			*path = cfg.Global.KeyStore.Path
+		}
+		fileStore, err := openFileKeyStore(ctx, cfg, *path, *keyEnv, *keyFile)
+		if err != nil {
+			return err
+		}
+		defer fileStore.Close()
+		store = fileStore
+	case config.KeyStoreKeychain:
+		store = auth.NewKeychainKeyStore(auth.SystemKeychain(), cfg.Global.KeyStore.Options["service"])
+	default:
+		return fmt.Errorf("global.key_store.type must be %q or %q to migrate keys, got %q",
+			config.KeyStoreFile, config.KeyStoreKeychain, cfg.Global.KeyStore.Type)
+	}
+
+	result, err := auth.MigrateConfigFile(ctx, *configPath, store)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range result.Migrated {
+		fmt.Printf("Migrated %s\n", id)
+	}
+	for _, id := range result.Skipped {
+		fmt.Printf("Skipped  %s\n", id)
+	}
+	fmt.Printf("%d keys migrated, %s now references them by name\n", len(result.Migrated), *configPath)
+	return nil
+}
+
+// openFileKeyStore opens the file key store at path with the passphrase of
+// the flags, or the master key or passphrase the config resolves to
+func openFileKeyStore(ctx context.Context, cfg *config.Config, path, keyEnv, keyFile string) (*auth.FileKeyStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no key store file, set --path or global.key_store.path")
+	}
+
+	key, err := passphrase(keyEnv, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" && cfg.Global.KeyStore.MasterKey.Type != "" {
+		master, err := auth.NewMasterKeyFromConfig(cfg.Global.KeyStore.MasterKey)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewFileKeyStoreWithMasterKey(ctx, path, master, 0)
+	}
+	if key == "" {
+		if key, err = auth.EncryptionKeyFromConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return auth.NewFileKeyStore(path, key, 0)
+}
+
+// runKeyStoreReencrypt rotates the passphrase of a file key store. The old
+// passphrase defaults to the one the config currently resolves to. The store
+// must not be open in a running process while it is rotated.
+func runKeyStoreReencrypt(args []string) error {
+	fs := flag.NewFlagSet("keystore reencrypt", flag.ContinueOnError)
+	configPath := fs.String("config", "gollmkit-config.yaml", "path to the gollmkit config file")
+	path := fs.String("path", "", "key store file (default global.key_store.path of the config)")
+	oldEnv := fs.String("old-key-env", "", "environment variable holding the current passphrase")
+	oldFile := fs.String("old-key-file", "", "file holding the current passphrase")
+	newEnv := fs.String("new-key-env", "", "environment variable holding the new passphrase")
+	newFile := fs.String("new-key-file", "", "file holding the new passphrase")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		*path = cfg.Global.KeyStore.Path
+	}
+	if *path == "" {
+		return fmt.Errorf("no key store file, set --path or global.key_store.path")
+	}
+
+	oldKey, err := passphrase(*oldEnv, *oldFile)
+	if err != nil {
+		return fmt.Errorf("old key: %w", err)
+	}
+	if oldKey == "" {
+		if oldKey, err = auth.EncryptionKeyFromConfig(cfg); err != nil {
+			return fmt.Errorf("old key: %w", err)
+		}
+	}
+	newKey, err := passphrase(*newEnv, *newFile)
+	if err != nil {
+		return fmt.Errorf("new key: %w", err)
+	}
+	if newKey == "" {
+		return fmt.Errorf("new key: set --new-key-env or --new-key-file")
+	}
+
+	store, err := auth.NewFileKeyStore(*path, oldKey, 0)
+	if err != nil {
+		return err
+	}
+	if err := store.ReencryptAll(context.Background(), oldKey, newKey); err != nil {
+		store.Close()
+		return err
+	}
+	if err := store.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-encrypted %s\n", *path)
+	return nil
+}
+
+// passphrase reads a passphrase from the environment variable env or the
+// file path, whichever is set. It returns "" if neither is.
+func passphrase(env, path string) (string, error) {
+	switch {
+	case env != "" && path != "":
+		return "", fmt.Errorf("set only one of the -env and -file flags")
+	case path != "":
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	case env != "":
+		key := os.Getenv(env)
+		if key == "" {
+			return "", fmt.Errorf("environment variable %s is not set", env)
+		}
+		return key, nil
+	}
+	return "", nil
+}
@@ -1 +1,74 @@
-package gollmkit
+// Command gollmkit provides operational tooling for gollmkit deployments
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// command is a top-level CLI command
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists all top-level commands in the order shown by usage
+var commands = []command{
+	{"config", "Inspect and audit configuration files", runConfig},
+	{"keystore", "Manage persistent key stores", runKeyStore},
+	{"stats", "Summarize recorded request statistics", runStats},
+	{"usage", "Export usage and cost reports", runUsage},
+	{"watch", "Continuously show live provider health and budgets", runWatch},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				printError(name, os.Args[2:], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "gollmkit: unknown command %q\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+// printUsage prints the top-level help text
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: gollmkit <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// printError reports a command failure. Commands run with --json report it
+// as a JSON object with a stable error code instead of plain text.
+func printError(name string, args []string, err error) {
+	for _, arg := range args {
+		if arg == "--json" || arg == "-json" {
+			fmt.Fprintf(os.Stderr, "%s\n", providers.MarshalError(err))
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "gollmkit %s: %v\n", name, err)
+}
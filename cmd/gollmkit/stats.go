@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/analytics"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// runStats dispatches the stats subcommands
+func runStats(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand (available: leaderboard)")
+	}
+
+	switch args[0] {
+	case "leaderboard":
+		return runLeaderboard(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q (available: leaderboard)", args[0])
+	}
+}
+
+// runLeaderboard prints a per provider/model summary of recorded requests
+func runLeaderboard(args []string) error {
+	fs := flag.NewFlagSet("stats leaderboard", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the gollmkit config file")
+	eventsPath := fs.String("events", "", "path to the analytics JSONL file (defaults to global.analytics_path)")
+	window := fs.Duration("window", 24*time.Hour, "time window to summarize")
+	asJSON := fs.Bool("json", false, "print the leaderboard as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path, err := resolveEventsPath(*eventsPath, *configPath)
+	if err != nil {
+		return err
+	}
+
+	events, err := analytics.ReadEvents(path, time.Now().Add(-*window))
+	if err != nil {
+		return err
+	}
+	entries := analytics.Leaderboard(events)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No requests recorded in the last %s\n", *window)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tREQUESTS\tERROR RATE\tP95 LATENCY\tP95 TTFT\tTOKENS/S\t$/1K TOKENS\tCACHE HIT RATE")
+	for _, e := range entries {
+		ttft, rate := "-", "-"
+		if e.P95TimeToFirstToken > 0 {
+			ttft = e.P95TimeToFirstToken.Round(time.Millisecond).String()
+		}
+		if e.TokensPerSecond > 0 {
+			rate = fmt.Sprintf("%.1f", e.TokensPerSecond)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\t%s\t%s\t%s\t$%.4f\t%.1f%%\n",
+			e.Provider, e.Model, e.Requests, e.ErrorRate*100,
+			e.P95Latency.Round(time.Millisecond), ttft, rate, e.CostPer1K, e.CacheHitRate*100)
+	}
+	return w.Flush()
+}
+
+// resolveEventsPath returns the analytics file from the flag or the config
+func resolveEventsPath(eventsPath, configPath string) (string, error) {
+	if eventsPath != "" {
+		return eventsPath, nil
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Global.AnalyticsPath == "" {
+		return "", fmt.Errorf("no analytics file: set global.analytics_path or pass --events")
+	}
+	return cfg.Global.AnalyticsPath, nil
+}
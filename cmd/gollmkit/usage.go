@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/analytics"
+	"github.com/gollmkit/gollmkit/internal/usage"
+)
+
+// runUsage dispatches the usage subcommands
+func runUsage(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand (available: export, forecast)")
+	}
+
+	switch args[0] {
+	case "export":
+		return runUsageExport(args[1:])
+	case "forecast":
+		return runUsageForecast(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q (available: export, forecast)", args[0])
+	}
+}
+
+// runUsageExport exports usage and cost rolled up by the requested dimensions
+func runUsageExport(args []string) error {
+	fs := flag.NewFlagSet("usage export", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the gollmkit config file")
+	eventsPath := fs.String("events", "", "path to the analytics JSONL file (defaults to global.analytics_path)")
+	window := fs.Duration("window", 30*24*time.Hour, "time window to export")
+	by := fs.String("by", "provider,model,key,day", "comma separated dimensions to group by (provider, model, key, tenant, day)")
+	format := fs.String("format", "csv", "output format: csv or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	groupBy, err := usage.ParseDimensions(*by)
+	if err != nil {
+		return err
+	}
+
+	path, err := resolveEventsPath(*eventsPath, *configPath)
+	if err != nil {
+		return err
+	}
+	since := time.Now().Add(-*window)
+	events, err := analytics.ReadEvents(path, since)
+	if err != nil {
+		return err
+	}
+
+	rows := usage.Rollup(events, usage.Query{Since: since, GroupBy: groupBy})
+	switch *format {
+	case "csv":
+		return usage.WriteCSV(os.Stdout, rows, groupBy)
+	case "json":
+		return usage.WriteJSON(os.Stdout, rows)
+	default:
+		return fmt.Errorf("unknown format %q (available: csv, json)", *format)
+	}
+}
+
+// runUsageForecast prints the projected month-end spend per provider
+func runUsageForecast(args []string) error {
+	fs := flag.NewFlagSet("usage forecast", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the gollmkit config file")
+	eventsPath := fs.String("events", "", "path to the analytics JSONL file (defaults to global.analytics_path)")
+	window := fs.Duration("window", 7*24*time.Hour, "time window the burn rate is averaged over")
+	provider := fs.String("provider", "", "forecast a single provider")
+	asJSON := fs.Bool("json", false, "print the forecasts as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *window <= 0 {
+		return fmt.Errorf("--window must be positive")
+	}
+
+	path, err := resolveEventsPath(*eventsPath, *configPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if windowStart := now.Add(-*window); windowStart.Before(since) {
+		since = windowStart
+	}
+	events, err := analytics.ReadEvents(path, since)
+	if err != nil {
+		return err
+	}
+
+	names := []string{*provider}
+	if *provider == "" {
+		seen := make(map[string]bool)
+		for _, event := range events {
+			seen[event.Provider] = true
+		}
+		names = names[:0]
+		for name := range seen {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	forecasts := make([]usage.Forecast, 0, len(names)+1)
+	for _, name := range names {
+		forecasts = append(forecasts, usage.ForecastSpend(events, name, *window, now))
+	}
+	if *provider == "" {
+		forecasts = append(forecasts, usage.ForecastSpend(events, "", *window, now))
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(forecasts)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PROVIDER\tSPENT (%s)\tBURN RATE\tPROJECTED (%s)\n",
+		forecasts[0].MonthStart.Format("Jan"), forecasts[0].MonthEnd.AddDate(0, 0, -1).Format("Jan 2"))
+	for _, forecast := range forecasts {
+		name := forecast.Provider
+		if name == "" {
+			name = "TOTAL"
+		}
+		fmt.Fprintf(w, "%s\t$%.4f\t$%.4f/day\t$%.2f\n", name, forecast.Spent, forecast.BurnRate, forecast.Projected)
+	}
+	return w.Flush()
+}
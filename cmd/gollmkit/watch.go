@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// staleStateAfter is how old a state file may be before the view flags it as stale
+const staleStateAfter = 30 * time.Second
+
+// runWatch continuously renders the live provider state published by an application
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the gollmkit config file")
+	statePath := fs.String("state", "", "path to the state file (defaults to global.state_path)")
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
+	once := fs.Bool("once", false, "render a single view and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path, err := resolveStatePath(*statePath, *configPath)
+	if err != nil {
+		return err
+	}
+
+	if *once {
+		return renderWatch(os.Stdout, path, time.Now())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		fmt.Print(clearScreen)
+		if err := renderWatch(os.Stdout, path, time.Now()); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		fmt.Printf("\nRefreshing every %s, press Ctrl+C to exit\n", *interval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolveStatePath returns the state file from the flag or the config
+func resolveStatePath(statePath, configPath string) (string, error) {
+	if statePath != "" {
+		return statePath, nil
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Global.StatePath == "" {
+		return "", fmt.Errorf("no state file: set global.state_path or pass --state")
+	}
+	return cfg.Global.StatePath, nil
+}
+
+// renderWatch renders one view of the state file
+func renderWatch(out io.Writer, path string, now time.Time) error {
+	snapshot, err := providers.ReadState(path)
+	if err != nil {
+		return err
+	}
+
+	age := now.Sub(snapshot.Time).Round(time.Second)
+	fmt.Fprintf(out, "gollmkit watch  %s  (state updated %s ago)\n", now.Format(time.RFC3339), age)
+	if age > staleStateAfter {
+		fmt.Fprintln(out, "WARNING: state is stale; is the application still publishing?")
+	}
+
+	fmt.Fprintf(out, "Budget: $%.4f spent today", snapshot.DailyCost)
+	if snapshot.DailyCostLimit > 0 {
+		fmt.Fprintf(out, " of $%.2f (%.1f%%)", snapshot.DailyCostLimit, snapshot.DailyCost/snapshot.DailyCostLimit*100)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out)
+
+	names := make([]string, 0, len(snapshot.Providers))
+	for name := range snapshot.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tHEALTH\tIN FLIGHT\tREQUESTS\tDAILY COST\tMONTH FORECAST\tSTRATEGY")
+	for _, name := range names {
+		state := snapshot.Providers[name]
+		stats := state.Statistics
+		forecast := "-"
+		if state.Forecast != nil {
+			forecast = fmt.Sprintf("$%.2f", state.Forecast.Projected)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t$%.4f\t%s\t%s\n",
+			name, providerHealth(stats), state.InFlight, stats.TotalRequests, state.DailyCost, forecast, state.Rotation.Strategy)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tKEY\tSTATE\tREQUESTS\tERRORS\tDAILY COST\tRATE LIMIT HEADROOM")
+	for _, name := range names {
+		stats := snapshot.Providers[name].Statistics
+		keyNames := make([]string, 0, len(stats.KeyStats))
+		for keyName := range stats.KeyStats {
+			keyNames = append(keyNames, keyName)
+		}
+		sort.Strings(keyNames)
+
+		for _, keyName := range keyNames {
+			key := stats.KeyStats[keyName]
+			var requests, errors int64
+			var dailyCost float64
+			if key.Usage != nil {
+				requests, errors, dailyCost = key.Usage.UsageCount, key.Usage.ErrorCount, key.Usage.DailyCost
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t$%.4f\t%s\n",
+				name, keyName, keyState(key), requests, errors, dailyCost, headroom(key.RateLimit, now))
+		}
+	}
+	return w.Flush()
+}
+
+// providerHealth summarizes how many keys of a provider are healthy
+func providerHealth(stats *auth.ProviderStats) string {
+	switch {
+	case stats.TotalKeys == 0:
+		return "no keys"
+	case stats.HealthyKeys == 0:
+		return fmt.Sprintf("DOWN 0/%d", stats.TotalKeys)
+	case stats.HealthyKeys < stats.TotalKeys:
+		return fmt.Sprintf("degraded %d/%d", stats.HealthyKeys, stats.TotalKeys)
+	default:
+		return fmt.Sprintf("ok %d/%d", stats.HealthyKeys, stats.TotalKeys)
+	}
+}
+
+// keyState describes the state of a key
+func keyState(key *auth.KeyStats) string {
+	switch {
+	case key.Deleted:
+		return "deleted"
+	case !key.Healthy:
+		return "unhealthy"
+	default:
+		return "healthy"
+	}
+}
+
+// headroom describes the remaining rate limit of a key
+func headroom(state *auth.RateLimitState, now time.Time) string {
+	if state == nil {
+		return "-"
+	}
+
+	var parts []string
+	if state.RemainingRequests >= 0 {
+		parts = append(parts, fmt.Sprintf("%d req", state.RemainingRequests))
+	}
+	if state.RemainingTokens >= 0 {
+		parts = append(parts, fmt.Sprintf("%d tok", state.RemainingTokens))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+
+	result := strings.Join(parts, ", ")
+	if state.NearLimit(now) {
+		result += " (near limit)"
+	}
+	return result
+}
@@ -82,9 +82,9 @@ func main() {
 	// Example 4: Key Rotation Example
 	fmt.Println("\n=== Key Rotation Example ===")
 	fmt.Println("OpenAI Key Rotation:")
-	demonstrateKeyRotation(ctx, rotator, "openai", 3)
+	demonstrateKeyRotation(ctx, rotator, "openai", "gpt-4o", 3)
 	fmt.Println("\nAnthropic Key Rotation:")
-	demonstrateKeyRotation(ctx, rotator, "anthropic", 3)
+	demonstrateKeyRotation(ctx, rotator, "anthropic", "claude-3-sonnet-20240229", 3)
 
 	// Example 5: Key Validation Example
 	fmt.Println("\n=== Key Validation Example ===")
@@ -104,7 +104,7 @@ func main() {
 }
 
 // demonstrateKeyRotation shows how key rotation works
-func demonstrateKeyRotation(ctx context.Context, rotator *auth.KeyRotator, provider string, iterations int) {
+func demonstrateKeyRotation(ctx context.Context, rotator *auth.KeyRotator, provider, model string, iterations int) {
 	fmt.Printf("Provider: %s\n", provider)
 
 	for i := 0; i < iterations; i++ {
@@ -121,10 +121,11 @@ func demonstrateKeyRotation(ctx context.Context, rotator *auth.KeyRotator, provi
 			selection.LastUsed.Format("15:04:05"))
 
 		// Simulate usage
-		err = rotator.RecordUsage(ctx, provider, selection.KeyName, 1000, 0.05)
+		err = rotator.RecordUsage(ctx, provider, selection.KeyName, model, 1000, 0.05)
 		if err != nil {
 			fmt.Printf("    Error recording usage: %v\n", err)
 		}
+		selection.Release()
 
 		// Small delay to show time differences
 		time.Sleep(100 * time.Millisecond)
@@ -170,6 +171,7 @@ func demonstrateKeyValidation(ctx context.Context, keyStore auth.KeyStore, cfg *
 func demonstrateUsageTracking(ctx context.Context, rotator *auth.KeyRotator) {
 	// Simulate some usage
 	providers := []string{"openai", "anthropic"}
+	models := map[string]string{"openai": "gpt-4o", "anthropic": "claude-3-sonnet-20240229"}
 
 	for _, provider := range providers {
 		// Get a key
@@ -193,7 +195,7 @@ func demonstrateUsageTracking(ctx context.Context, rotator *auth.KeyRotator) {
 		fmt.Printf("Provider: %s, Key: %s\n", provider, selection.KeyName)
 
 		for _, scenario := range usageScenarios {
-			err = rotator.RecordUsage(ctx, provider, selection.KeyName, scenario.tokens, scenario.cost)
+			err = rotator.RecordUsage(ctx, provider, selection.KeyName, models[provider], scenario.tokens, scenario.cost)
 			if err != nil {
 				fmt.Printf("  Error recording usage: %v\n", err)
 				continue
@@ -250,8 +252,8 @@ func demonstrateHealthChecking(ctx context.Context, keyStore auth.KeyStore, cfg
 		}
 	}
 
-	// Note: In a real application, you would start the health checker
-	// with healthChecker.Start(ctx, providers) in a separate goroutine
+	// Note: In a real application, you would run periodic health checks
+	// with provider.StartHealthChecks(ctx) and stop them on shutdown
 }
 
 // demonstrateStatistics shows statistics functionality
@@ -283,6 +285,12 @@ func demonstrateStatistics(ctx context.Context, rotator *auth.KeyRotator) {
 				keyName, healthStatus, keyStats.Usage.UsageCount, keyStats.Usage.CostUsed)
 		}
 
+		// Show per-model stats
+		for model, usage := range stats.Models {
+			fmt.Printf("    %s: %d requests, %d tokens, $%.3f cost\n",
+				model, usage.UsageCount, usage.TokensUsed, usage.CostUsed)
+		}
+
 		// Get rotation status
 		rotationStatus, err := rotator.GetRotationStatus(ctx, provider)
 		if err != nil {
@@ -292,7 +300,7 @@ func demonstrateStatistics(ctx context.Context, rotator *auth.KeyRotator) {
 
 		fmt.Printf("  Rotation Strategy: %s\n", rotationStatus.Strategy)
 		if rotationStatus.Strategy == config.RotationRoundRobin {
-			fmt.Printf("  Current Index: %d\n", rotationStatus.CurrentIndex)
+			fmt.Printf("  Active Key: %s\n", rotationStatus.ActiveKey)
 		}
 		if !rotationStatus.LastRotation.IsZero() {
 			fmt.Printf("  Last Rotation: %s\n", rotationStatus.LastRotation.Format("15:04:05"))
@@ -1,9 +1,12 @@
 module github.com/gollmkit/gollmkit
 
-require github.com/spf13/viper v1.20.1
+require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/spf13/viper v1.20.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/fsnotify/fsnotify v1.8.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
 	github.com/sagikazarmark/locafero v0.7.0 // indirect
@@ -16,7 +19,6 @@ require (
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 go 1.23.5
@@ -0,0 +1,190 @@
+// Package agent runs a model with a system prompt and tools over a thread of
+// messages, executing the tools the model calls until it answers
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+	"github.com/gollmkit/gollmkit/internal/tools"
+)
+
+// DefaultMaxIterations is the number of model calls per run of agents that don't set one
+const DefaultMaxIterations = 10
+
+var (
+	// ErrMaxIterations is returned when the model still calls tools after MaxIterations calls
+	ErrMaxIterations = errors.New("agent reached its iteration limit")
+	// ErrBudgetExceeded is returned when a run spent MaxCost or MaxTokens before the model answered
+	ErrBudgetExceeded = errors.New("agent exceeded its budget")
+)
+
+// Chatter sends a conversation to an LLM. It is implemented by providers.UnifiedProvider.
+type Chatter interface {
+	Chat(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error)
+}
+
+// costCalculator prices the usage of a response. It is implemented by providers.UnifiedProvider.
+type costCalculator interface {
+	CalculateCost(provider providers.ProviderType, model string, usage providers.TokenUsage) float64
+}
+
+// ToolFunc executes a call of a tool with the JSON arguments chosen by the
+// model and returns the result sent back to it. Errors are sent back as the
+// result too, so the model can correct its call.
+type ToolFunc func(ctx context.Context, arguments string) (string, error)
+
+// Options configures an agent
+type Options struct {
+	// SystemPrompt is sent before the thread on every call
+	SystemPrompt string
+
+	// RequestOptions are used for every call. Tools are set to those added
+	// with AddTool and AddTools.
+	RequestOptions providers.RequestOptions
+
+	// MaxIterations bounds the model calls of a run. Defaults to DefaultMaxIterations.
+	MaxIterations int
+
+	// MaxCost and MaxTokens bound what a run may spend, in USD and total
+	// tokens. Zero means no limit. MaxCost needs a Chatter that calculates
+	// costs, such as providers.UnifiedProvider.
+	MaxCost   float64
+	MaxTokens int
+}
+
+// Result is the outcome of a run
+type Result struct {
+	// Content is the answer of the model
+	Content string
+	// Response is the last response of the model
+	Response *providers.CompletionResponse
+
+	Iterations int // model calls
+	ToolCalls  int // tool calls executed
+	Usage      providers.TokenUsage
+	Cost       float64
+}
+
+// Agent is a model with a system prompt and tools that keeps a thread of
+// messages across runs. It is safe for concurrent use; runs are serialized.
+type Agent struct {
+	mu      sync.Mutex
+	chatter Chatter
+	opts    Options
+	tools   *tools.Set
+	thread  []providers.Message
+}
+
+// New creates an agent sending its calls with chatter
+func New(chatter Chatter, opts Options) *Agent {
+	if opts.MaxIterations <= 0 {
+		opts.MaxIterations = DefaultMaxIterations
+	}
+	return &Agent{
+		chatter: chatter,
+		opts:    opts,
+		tools:   tools.NewSet(),
+	}
+}
+
+// AddTool offers tool to the model and executes its calls with fn. Adding a
+// tool with the name of an existing one replaces it.
+func (a *Agent) AddTool(tool providers.Tool, fn ToolFunc) {
+	a.tools.Add(tools.Func(tool, fn))
+}
+
+// AddTools offers tools created with the tools package to the model
+func (a *Agent) AddTools(t ...*tools.Tool) {
+	a.tools.Add(t...)
+}
+
+// Thread returns a copy of the messages of the thread
+func (a *Agent) Thread() []providers.Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]providers.Message(nil), a.thread...)
+}
+
+// Reset clears the thread
+func (a *Agent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.thread = nil
+}
+
+// Run adds a user message to the thread and calls the model, executing the
+// tools it calls and sending back their results, until it answers without
+// calling tools. The calls of a response are executed concurrently. If a
+// call of the model fails, the thread is left unchanged. When a run stops at
+// its iteration limit or budget, the thread keeps the tool results so far
+// and the partial Result is returned with ErrMaxIterations or
+// ErrBudgetExceeded; running the agent again continues from there.
+func (a *Agent) Run(ctx context.Context, input string) (*Result, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	thread := append(append([]providers.Message(nil), a.thread...),
+		providers.Message{Role: "user", Content: input})
+
+	opts := a.opts.RequestOptions
+	opts.Tools = a.tools.Definitions()
+
+	result := &Result{}
+	for result.Iterations < a.opts.MaxIterations {
+		resp, err := a.chatter.Chat(ctx, a.conversation(thread), opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Iterations++
+		result.Response = resp
+		result.Content = resp.Content
+		a.addUsage(result, resp)
+
+		thread = append(thread, providers.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+		if len(resp.ToolCalls) == 0 {
+			a.thread = thread
+			return result, nil
+		}
+
+		thread = append(thread, a.tools.Execute(ctx, resp.ToolCalls)...)
+		result.ToolCalls += len(resp.ToolCalls)
+		a.thread = thread
+
+		if a.overBudget(result) {
+			return result, fmt.Errorf("%w: spent $%.4f and %d tokens in %d iterations",
+				ErrBudgetExceeded, result.Cost, result.Usage.TotalTokens, result.Iterations)
+		}
+	}
+	return result, fmt.Errorf("%w of %d", ErrMaxIterations, a.opts.MaxIterations)
+}
+
+// conversation prepends the system prompt to thread
+func (a *Agent) conversation(thread []providers.Message) []providers.Message {
+	if a.opts.SystemPrompt == "" {
+		return thread
+	}
+	return append([]providers.Message{{Role: "system", Content: a.opts.SystemPrompt}}, thread...)
+}
+
+// addUsage adds the usage and cost of resp to result
+func (a *Agent) addUsage(result *Result, resp *providers.CompletionResponse) {
+	result.Usage.PromptTokens += resp.Usage.PromptTokens
+	result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+	result.Usage.TotalTokens += resp.Usage.TotalTokens
+	result.Usage.CachedTokens += resp.Usage.CachedTokens
+	result.Usage.ReasoningTokens += resp.Usage.ReasoningTokens
+
+	if calculator, ok := a.chatter.(costCalculator); ok {
+		result.Cost += calculator.CalculateCost(providers.ProviderType(resp.ProviderName), resp.Model, resp.Usage)
+	}
+}
+
+// overBudget reports whether result spent the cost or token budget of the agent
+func (a *Agent) overBudget(result *Result) bool {
+	return (a.opts.MaxCost > 0 && result.Cost >= a.opts.MaxCost) ||
+		(a.opts.MaxTokens > 0 && result.Usage.TotalTokens >= a.opts.MaxTokens)
+}
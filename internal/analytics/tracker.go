@@ -1 +1,254 @@
-package gollmkit
+// Package analytics records per-request events and summarizes them into reports
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxMemoryEvents bounds the number of events kept in memory by a Tracker
+const maxMemoryEvents = 10000
+
+// Event represents a single completed LLM request
+type Event struct {
+	Time         time.Time     `json:"time"`
+	Provider     string        `json:"provider"`
+	Model        string        `json:"model"`
+	KeyName      string        `json:"key_name"`
+	Tenant       string        `json:"tenant,omitempty"`
+	Latency      time.Duration `json:"latency"`
+	InputTokens  int           `json:"input_tokens"`
+	OutputTokens int           `json:"output_tokens"`
+	Cost         float64       `json:"cost"`
+	Error        string        `json:"error,omitempty"`
+	ErrorCode    string        `json:"error_code,omitempty"` // stable code such as GLK-504-TIMEOUT
+	CacheHit     bool          `json:"cache_hit,omitempty"`
+
+	// Streamed requests only: time to the first generated token and output
+	// tokens per second after it, zero if too few tokens were generated
+	TimeToFirstToken time.Duration `json:"ttft,omitempty"`
+	TokensPerSecond  float64       `json:"tokens_per_second,omitempty"`
+
+	RequestID         string `json:"request_id,omitempty"`
+	ProviderRequestID string `json:"provider_request_id,omitempty"`
+}
+
+// Tracker records request events in memory and optionally appends them to a JSONL file
+type Tracker struct {
+	mu     sync.RWMutex
+	events []Event
+	file   *os.File
+	enc    *json.Encoder
+}
+
+// NewTracker creates a tracker. If path is not empty, events are also
+// appended to that file so other processes (e.g. the CLI) can read them.
+func NewTracker(path string) (*Tracker, error) {
+	t := &Tracker{}
+	if path == "" {
+		return t, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics file: %w", err)
+	}
+	t.file = file
+	t.enc = json.NewEncoder(file)
+
+	return t, nil
+}
+
+// Record records an event
+func (t *Tracker) Record(event Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, event)
+	if len(t.events) > maxMemoryEvents {
+		t.events = t.events[len(t.events)-maxMemoryEvents:]
+	}
+
+	if t.enc != nil {
+		if err := t.enc.Encode(event); err != nil {
+			return fmt.Errorf("failed to write analytics event: %w", err)
+		}
+	}
+	return nil
+}
+
+// Events returns the in-memory events recorded at or after since
+func (t *Tracker) Events(since time.Time) []Event {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var events []Event
+	for _, event := range t.events {
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Sync commits the events written to the analytics file to stable storage
+func (t *Tracker) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	if err := t.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync analytics file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying analytics file
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	t.enc = nil
+	return err
+}
+
+// ReadEvents reads events recorded at or after since from a JSONL analytics file
+func ReadEvents(path string, since time.Time) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics file: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid analytics event on line %d: %w", line, err)
+		}
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read analytics file: %w", err)
+	}
+
+	return events, nil
+}
+
+// LeaderboardEntry summarizes the requests of one provider/model
+type LeaderboardEntry struct {
+	Provider     string        `json:"provider"`
+	Model        string        `json:"model"`
+	Requests     int           `json:"requests"`
+	Errors       int           `json:"errors"`
+	ErrorRate    float64       `json:"error_rate"`
+	P95Latency   time.Duration `json:"p95_latency"`
+	Tokens       int64         `json:"tokens"`
+	Cost         float64       `json:"cost"`
+	CostPer1K    float64       `json:"cost_per_1k_tokens"`
+	CacheHitRate float64       `json:"cache_hit_rate"`
+
+	// Streamed requests only
+	P95TimeToFirstToken time.Duration `json:"p95_ttft,omitempty"`
+	TokensPerSecond     float64       `json:"tokens_per_second,omitempty"` // median
+}
+
+// Leaderboard summarizes events per provider/model, ordered by realized cost per 1k tokens
+func Leaderboard(events []Event) []LeaderboardEntry {
+	type group struct {
+		entry     LeaderboardEntry
+		latencies []time.Duration
+		ttfts     []time.Duration
+		rates     []float64
+		cacheHits int
+	}
+
+	groups := make(map[string]*group)
+	for _, event := range events {
+		id := event.Provider + "/" + event.Model
+		g, exists := groups[id]
+		if !exists {
+			g = &group{entry: LeaderboardEntry{Provider: event.Provider, Model: event.Model}}
+			groups[id] = g
+		}
+
+		g.entry.Requests++
+		if event.Error != "" {
+			g.entry.Errors++
+			continue
+		}
+		if event.CacheHit {
+			g.cacheHits++
+		}
+		g.latencies = append(g.latencies, event.Latency)
+		if event.TimeToFirstToken > 0 {
+			g.ttfts = append(g.ttfts, event.TimeToFirstToken)
+		}
+		if event.TokensPerSecond > 0 {
+			g.rates = append(g.rates, event.TokensPerSecond)
+		}
+		g.entry.Tokens += int64(event.InputTokens + event.OutputTokens)
+		g.entry.Cost += event.Cost
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(groups))
+	for _, g := range groups {
+		entry := g.entry
+		entry.ErrorRate = float64(entry.Errors) / float64(entry.Requests)
+		entry.CacheHitRate = float64(g.cacheHits) / float64(entry.Requests)
+		entry.P95Latency = p95(g.latencies)
+		entry.P95TimeToFirstToken = p95(g.ttfts)
+		if len(g.rates) > 0 {
+			sort.Float64s(g.rates)
+			entry.TokensPerSecond = g.rates[(len(g.rates)-1)/2]
+		}
+		if entry.Tokens > 0 {
+			entry.CostPer1K = entry.Cost / (float64(entry.Tokens) / 1000.0)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CostPer1K != entries[j].CostPer1K {
+			return entries[i].CostPer1K < entries[j].CostPer1K
+		}
+		if entries[i].Provider != entries[j].Provider {
+			return entries[i].Provider < entries[j].Provider
+		}
+		return entries[i].Model < entries[j].Model
+	})
+
+	return entries
+}
+
+// p95 returns the 95th percentile of latencies using nearest-rank
+func p95(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(float64(len(sorted))*0.95+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
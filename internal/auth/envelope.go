@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// dataKeySize is the size of data encryption keys, for AES-256
+const dataKeySize = 32
+
+// MasterKey wraps the data encryption key that encrypts stored API keys
+// (envelope encryption), so only the wrapped data key is kept next to the
+// encrypted keys and the master key never leaves its key management service
+type MasterKey interface {
+	// ID identifies the master key, e.g. a KMS key ARN
+	ID() string
+
+	// Wrap encrypts a data key
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key returned by Wrap
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// newDataKey generates a random data encryption key
+func newDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// newDataKeyEncryptor creates an encryptor using a data key directly
+func newDataKeyEncryptor(dataKey []byte) (*KeyEncryptor, error) {
+	if len(dataKey) != dataKeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", dataKeySize, len(dataKey))
+	}
+	return &KeyEncryptor{key: dataKey}, nil
+}
+
+// LocalMasterKey is a user-supplied 32-byte master key, e.g. held in a secret
+// manager and passed through an environment variable
+type LocalMasterKey struct {
+	id        string
+	encryptor *KeyEncryptor
+}
+
+// NewLocalMasterKey creates a master key from 32 bytes of key material
+func NewLocalMasterKey(id string, key []byte) (*LocalMasterKey, error) {
+	encryptor, err := newDataKeyEncryptor(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return &LocalMasterKey{id: id, encryptor: encryptor}, nil
+}
+
+// ID implements MasterKey
+func (k *LocalMasterKey) ID() string {
+	return k.id
+}
+
+// Wrap implements MasterKey
+func (k *LocalMasterKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	wrapped, err := k.encryptor.Encrypt(string(dataKey))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(wrapped), nil
+}
+
+// Unwrap implements MasterKey
+func (k *LocalMasterKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	dataKey, err := k.encryptor.Decrypt(string(wrapped))
+	if err != nil {
+		return nil, fmt.Errorf("master key %s: %w", k.id, err)
+	}
+	return []byte(dataKey), nil
+}
+
+// NewMasterKeyFromConfig creates the master key selected by configuration.
+// AWS credentials are read from the standard AWS_* environment variables and
+// GCP access tokens from the instance metadata server or GOOGLE_OAUTH_ACCESS_TOKEN.
+func NewMasterKeyFromConfig(cfg config.MasterKeyConfig) (MasterKey, error) {
+	switch cfg.Type {
+	case config.MasterKeyLocal:
+		key, err := base64.StdEncoding.DecodeString(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("master key must be base64-encoded: %w", err)
+		}
+		id := cfg.KeyID
+		if id == "" {
+			id = config.MasterKeyLocal
+		}
+		return NewLocalMasterKey(id, key)
+	case config.MasterKeyAWSKMS:
+		creds, err := AWSCredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		region := cfg.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			return nil, fmt.Errorf("aws-kms master key requires a region")
+		}
+		return NewAWSKMSMasterKey(cfg.KeyID, region, creds), nil
+	case config.MasterKeyGCPKMS:
+		return NewGCPKMSMasterKey(cfg.KeyID, DefaultGCPTokenSource), nil
+	default:
+		return nil, fmt.Errorf("unknown master key type %q", cfg.Type)
+	}
+}
+
+// kmsHTTPClient is the default client of KMS master keys
+var kmsHTTPClient = &http.Client{Timeout: defaultKMSTimeout}
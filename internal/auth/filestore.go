@@ -0,0 +1,525 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/logging"
+)
+
+// DefaultFileKeyStoreFlushInterval is how often usage statistics of a
+// FileKeyStore are written to disk
+const DefaultFileKeyStoreFlushInterval = 5 * time.Second
+
+// fileKeyStoreVersion is the format version of key store files
+const fileKeyStoreVersion = 1
+
+// fileKeyStoreData is the on-disk representation of a FileKeyStore
+type fileKeyStoreData struct {
+	Version   int                                      `json:"version"`
+	MasterKey string                                   `json:"master_key,omitempty"` // ID of the master key wrapping DataKey
+	DataKey   []byte                                   `json:"data_key,omitempty"`   // wrapped data key of envelope encryption
+	Providers map[string]map[string]*fileKeyStoreEntry `json:"providers"`
+	Models    map[string]map[string]*fileModelEntry    `json:"models,omitempty"` // provider -> model
+}
+
+// fileModelEntry is the persisted usage of a single model
+type fileModelEntry struct {
+	Usage  *ModelUsage  `json:"usage"`
+	Series *UsageSeries `json:"series"`
+}
+
+// fileKeyStoreEntry is the persisted state of a single key
+type fileKeyStoreEntry struct {
+	Key       string       `json:"key"` // encrypted
+	Healthy   bool         `json:"healthy"`
+	DeletedAt *time.Time   `json:"deleted_at,omitempty"`
+	Usage     *KeyUsage    `json:"usage"`
+	Series    *UsageSeries `json:"series"`
+}
+
+// FileKeyStore is a KeyStore persisted to a local JSON file, so single-binary
+// deployments keep their keys and usage statistics across restarts. Keys are
+// always encrypted at rest, with a passphrase or a data key wrapped by a
+// MasterKey. Key changes are written immediately, usage statistics every
+// flush interval and on Close; each write atomically replaces the file.
+type FileKeyStore struct {
+	*MemoryKeyStore
+	path string
+
+	rotateMu   sync.Mutex // serializes key rotations
+	master     MasterKey  // nil for passphrase encryption
+	wrappedKey []byte     // data key wrapped by master, guarded by MemoryKeyStore.mu
+
+	saveMu sync.Mutex // serializes writes of the file
+
+	dirtyMu sync.Mutex
+	dirty   bool // usage changed since the last write
+
+	loggerMu sync.Mutex
+	logger   logging.Logger
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileKeyStore opens the key store file at path, creating it on first
+// write. encryptionKey encrypts the stored keys and must match the key the
+// file was written with.
+func NewFileKeyStore(path, encryptionKey string, flushInterval time.Duration) (*FileKeyStore, error) {
+	if encryptionKey == "" {
+		return nil, errors.New("file key store requires an encryption key")
+	}
+
+	data, err := readFileKeyStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if data.DataKey != nil {
+		return nil, fmt.Errorf("key store %s is encrypted with master key %s, open it with NewFileKeyStoreWithMasterKey", path, data.MasterKey)
+	}
+	return openFileKeyStore(path, NewMemoryKeyStore(encryptionKey), nil, nil, data, flushInterval)
+}
+
+// NewFileKeyStoreWithMasterKey opens the key store file at path using
+// envelope encryption: stored keys are encrypted with a random data key,
+// which is kept in the file wrapped by master. A new file gets a new data key.
+func NewFileKeyStoreWithMasterKey(ctx context.Context, path string, master MasterKey, flushInterval time.Duration) (*FileKeyStore, error) {
+	data, err := readFileKeyStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataKey, wrapped []byte
+	switch {
+	case data.DataKey != nil:
+		wrapped = data.DataKey
+		if dataKey, err = master.Unwrap(ctx, wrapped); err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key of %s: %w", path, err)
+		}
+	case len(data.Providers) > 0:
+		return nil, fmt.Errorf("key store %s is encrypted with a passphrase, open it with NewFileKeyStore and convert it with RotateMasterKey", path)
+	default:
+		if dataKey, err = newDataKey(); err != nil {
+			return nil, err
+		}
+		if wrapped, err = master.Wrap(ctx, dataKey); err != nil {
+			return nil, fmt.Errorf("failed to wrap data key: %w", err)
+		}
+	}
+
+	encryptor, err := newDataKeyEncryptor(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	m := NewMemoryKeyStore("")
+	m.encryptor = encryptor
+	return openFileKeyStore(path, m, master, wrapped, data, flushInterval)
+}
+
+// openFileKeyStore loads data into m and starts the background flush
+func openFileKeyStore(path string, m *MemoryKeyStore, master MasterKey, wrapped []byte, data *fileKeyStoreData, flushInterval time.Duration) (*FileKeyStore, error) {
+	if flushInterval <= 0 {
+		flushInterval = DefaultFileKeyStoreFlushInterval
+	}
+
+	f := &FileKeyStore{
+		MemoryKeyStore: m,
+		path:           path,
+		master:         master,
+		wrappedKey:     wrapped,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	if err := f.load(data); err != nil {
+		return nil, err
+	}
+
+	go f.flushLoop(flushInterval)
+	return f, nil
+}
+
+// readFileKeyStore reads the key store file at path. A missing file reads as
+// an empty store.
+func readFileKeyStore(path string) (*fileKeyStoreData, error) {
+	var data fileKeyStoreData
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("invalid key store %s: %w", path, err)
+	}
+	if data.Version > fileKeyStoreVersion {
+		return nil, fmt.Errorf("key store %s has unsupported version %d", path, data.Version)
+	}
+	return &data, nil
+}
+
+// load fills the store with the keys of data
+func (f *FileKeyStore) load(data *fileKeyStoreData) error {
+	m := f.MemoryKeyStore
+	for provider, entries := range data.Providers {
+		shard := newKeyShard()
+		m.shards[provider] = shard
+
+		for keyName, entry := range entries {
+			// Fail early on a wrong encryption key rather than on first use
+			if _, err := m.encryptor.Decrypt(entry.Key); err != nil {
+				return fmt.Errorf("failed to decrypt key %s/%s in %s, wrong encryption key?", provider, keyName, f.path)
+			}
+
+			key := &keyEntry{stored: entry.Key, healthy: entry.Healthy, deletedAt: entry.DeletedAt, series: entry.Series}
+			if entry.Usage != nil {
+				key.usage = *entry.Usage
+			}
+			if key.series == nil {
+				key.series = NewUsageSeries()
+			}
+			shard.keys[keyName] = key
+		}
+	}
+
+	for provider, entries := range data.Models {
+		shard, exists := m.shards[provider]
+		if !exists {
+			shard = newKeyShard()
+			m.shards[provider] = shard
+		}
+		for model, entry := range entries {
+			usage := &modelEntry{series: entry.Series}
+			if entry.Usage != nil {
+				usage.usage = *entry.Usage
+			}
+			if usage.series == nil {
+				usage.series = NewUsageSeries()
+			}
+			shard.models[model] = usage
+		}
+	}
+	return nil
+}
+
+// save atomically replaces the key store file with the current state
+func (f *FileKeyStore) save() error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+
+	f.dirtyMu.Lock()
+	f.dirty = false
+	f.dirtyMu.Unlock()
+
+	raw, err := f.marshal()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create key store directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	return nil
+}
+
+// marshal encodes the current state of the store
+func (f *FileKeyStore) marshal() ([]byte, error) {
+	m := f.MemoryKeyStore
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := fileKeyStoreData{
+		Version:   fileKeyStoreVersion,
+		DataKey:   f.wrappedKey,
+		Providers: make(map[string]map[string]*fileKeyStoreEntry, len(m.shards)),
+	}
+	if f.master != nil {
+		data.MasterKey = f.master.ID()
+	}
+	for provider, shard := range m.shards {
+		shard.mu.RLock()
+		keys := make(map[string]*fileKeyStoreEntry, len(shard.keys))
+		for keyName, key := range shard.keys {
+			// Copy the statistics, requests keep updating them while the file is written
+			key.mu.Lock()
+			usage := key.usage
+			keys[keyName] = &fileKeyStoreEntry{
+				Key:       key.stored,
+				Healthy:   key.healthy,
+				DeletedAt: key.deletedAt,
+				Usage:     &usage,
+				Series:    key.series.clone(),
+			}
+			key.mu.Unlock()
+		}
+		data.Providers[provider] = keys
+
+		if len(shard.models) > 0 {
+			if data.Models == nil {
+				data.Models = make(map[string]map[string]*fileModelEntry)
+			}
+			models := make(map[string]*fileModelEntry, len(shard.models))
+			for model, entry := range shard.models {
+				entry.mu.Lock()
+				usage := entry.usage
+				models[model] = &fileModelEntry{Usage: &usage, Series: entry.series.clone()}
+				entry.mu.Unlock()
+			}
+			data.Models[provider] = models
+		}
+		shard.mu.RUnlock()
+	}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+// SetLogger sets the logger receiving failed background flushes,
+// slog.Default() if unset
+func (f *FileKeyStore) SetLogger(logger logging.Logger) {
+	f.loggerMu.Lock()
+	defer f.loggerMu.Unlock()
+	f.logger = logger
+}
+
+// log returns the logger of the store
+func (f *FileKeyStore) log() logging.Logger {
+	f.loggerMu.Lock()
+	defer f.loggerMu.Unlock()
+	return logging.OrDefault(f.logger)
+}
+
+// flushLoop writes pending usage statistics every interval until Close
+func (f *FileKeyStore) flushLoop(interval time.Duration) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Flush(); err != nil {
+				f.log().Error("gollmkit: failed to flush key store usage", "path", f.path, "error", err)
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Flush writes pending usage statistics now instead of at the next flush interval
+func (f *FileKeyStore) Flush() error {
+	f.dirtyMu.Lock()
+	dirty := f.dirty
+	f.dirtyMu.Unlock()
+	if !dirty {
+		return nil
+	}
+	return f.save()
+}
+
+// markDirty schedules the usage statistics for the next flush
+func (f *FileKeyStore) markDirty() {
+	f.dirtyMu.Lock()
+	f.dirty = true
+	f.dirtyMu.Unlock()
+}
+
+// StoreKey stores an API key and writes the file. Storing the value a key
+// already has keeps its usage statistics, so populating the store from
+// configuration on every start doesn't reset them.
+func (f *FileKeyStore) StoreKey(ctx context.Context, provider, keyName, key string) error {
+	if f.restoreIfStored(provider, keyName, key) {
+		return f.save()
+	}
+
+	if err := f.MemoryKeyStore.StoreKey(ctx, provider, keyName, key); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// DeleteKey soft-deletes an API key and writes the file
+func (f *FileKeyStore) DeleteKey(ctx context.Context, provider, keyName string) error {
+	if err := f.MemoryKeyStore.DeleteKey(ctx, provider, keyName); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// RestoreKey restores a soft-deleted API key and writes the file
+func (f *FileKeyStore) RestoreKey(ctx context.Context, provider, keyName string) error {
+	if err := f.MemoryKeyStore.RestoreKey(ctx, provider, keyName); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// PurgeKey permanently removes an API key and writes the file
+func (f *FileKeyStore) PurgeKey(ctx context.Context, provider, keyName string) error {
+	if err := f.MemoryKeyStore.PurgeKey(ctx, provider, keyName); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// UpdateUsage updates key and model usage statistics, written at the next flush
+func (f *FileKeyStore) UpdateUsage(ctx context.Context, provider, keyName, model string, tokens int, cost float64) error {
+	if err := f.MemoryKeyStore.UpdateUsage(ctx, provider, keyName, model, tokens, cost); err != nil {
+		return err
+	}
+	f.markDirty()
+	return nil
+}
+
+// UpdateUsageBatch updates the usage statistics of many requests, written at the next flush
+func (f *FileKeyStore) UpdateUsageBatch(ctx context.Context, records []UsageRecord) error {
+	err := f.MemoryKeyStore.UpdateUsageBatch(ctx, records)
+	f.markDirty()
+	return err
+}
+
+// SetHealth sets the health status of a key and writes the file
+func (f *FileKeyStore) SetHealth(ctx context.Context, provider, keyName string, healthy bool) error {
+	if err := f.MemoryKeyStore.SetHealth(ctx, provider, keyName, healthy); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// RecordError records an error for a key, written at the next flush
+func (f *FileKeyStore) RecordError(ctx context.Context, provider, keyName, errorMsg string) error {
+	if err := f.MemoryKeyStore.RecordError(ctx, provider, keyName, errorMsg); err != nil {
+		return err
+	}
+	f.markDirty()
+	return nil
+}
+
+// ReencryptAll re-encrypts every stored key from the passphrase oldKey to
+// newKey and writes the file. Stores using a master key are rotated with
+// RotateDataKey instead.
+func (f *FileKeyStore) ReencryptAll(ctx context.Context, oldKey, newKey string) error {
+	f.rotateMu.Lock()
+	defer f.rotateMu.Unlock()
+
+	if f.master != nil {
+		return errors.New("key store is encrypted with a master key, use RotateDataKey")
+	}
+	if err := f.MemoryKeyStore.ReencryptAll(ctx, oldKey, newKey); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// RotateMasterKey wraps the data key with master and writes the file, so the
+// previous master key can be retired. Stored keys are unchanged. A store
+// encrypted with a passphrase is converted to envelope encryption, which
+// re-encrypts every stored key with a new data key.
+func (f *FileKeyStore) RotateMasterKey(ctx context.Context, master MasterKey) error {
+	f.rotateMu.Lock()
+	defer f.rotateMu.Unlock()
+
+	if f.master == nil {
+		return f.rotateDataKey(ctx, master)
+	}
+
+	m := f.MemoryKeyStore
+	m.mu.RLock()
+	dataKey := m.encryptor.key
+	m.mu.RUnlock()
+
+	wrapped, err := master.Wrap(ctx, dataKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	m.mu.Lock()
+	f.master = master
+	f.wrappedKey = wrapped
+	m.mu.Unlock()
+	return f.save()
+}
+
+// RotateDataKey re-encrypts every stored key with a new data key wrapped by
+// the current master key and writes the file
+func (f *FileKeyStore) RotateDataKey(ctx context.Context) error {
+	f.rotateMu.Lock()
+	defer f.rotateMu.Unlock()
+
+	if f.master == nil {
+		return errors.New("key store has no master key, use RotateMasterKey")
+	}
+	return f.rotateDataKey(ctx, f.master)
+}
+
+// rotateDataKey switches to a new data key wrapped by master
+func (f *FileKeyStore) rotateDataKey(ctx context.Context, master MasterKey) error {
+	dataKey, err := newDataKey()
+	if err != nil {
+		return err
+	}
+	wrapped, err := master.Wrap(ctx, dataKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	encryptor, err := newDataKeyEncryptor(dataKey)
+	if err != nil {
+		return err
+	}
+
+	m := f.MemoryKeyStore
+	m.mu.Lock()
+	err = m.reencrypt(encryptor)
+	if err == nil {
+		f.master = master
+		f.wrappedKey = wrapped
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// Close stops the background flush and writes pending usage statistics
+func (f *FileKeyStore) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		<-f.done
+		err = f.Flush()
+	})
+	return err
+}
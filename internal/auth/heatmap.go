@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// heatmapBucketSize is the width of a usage heatmap bucket
+	heatmapBucketSize = 5 * time.Minute
+
+	// heatmapWindow is how far back the usage heatmap reaches
+	heatmapWindow = 24 * time.Hour
+)
+
+// UsageBucket holds the usage of a key within one heatmap bucket
+type UsageBucket struct {
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+	Tokens   int64     `json:"tokens"`
+}
+
+// HeatmapTracker records time-bucketed usage per provider and key
+type HeatmapTracker struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]map[int64]*UsageBucket // provider -> keyName -> bucket index -> bucket
+}
+
+// NewHeatmapTracker creates an empty heatmap tracker
+func NewHeatmapTracker() *HeatmapTracker {
+	return &HeatmapTracker{
+		buckets: make(map[string]map[string]map[int64]*UsageBucket),
+	}
+}
+
+// Record adds a request with the given token count to the current bucket of a key
+func (ht *HeatmapTracker) Record(provider, keyName string, tokens int, at time.Time) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	if ht.buckets[provider] == nil {
+		ht.buckets[provider] = make(map[string]map[int64]*UsageBucket)
+	}
+	keyBuckets := ht.buckets[provider][keyName]
+	if keyBuckets == nil {
+		keyBuckets = make(map[int64]*UsageBucket)
+		ht.buckets[provider][keyName] = keyBuckets
+	}
+
+	idx := bucketIndex(at)
+	bucket, exists := keyBuckets[idx]
+	if !exists {
+		bucket = &UsageBucket{Start: bucketStart(idx)}
+		keyBuckets[idx] = bucket
+		ht.prune(keyBuckets, idx)
+	}
+	bucket.Requests++
+	bucket.Tokens += int64(tokens)
+}
+
+// Heatmap returns the usage buckets of every key of a provider for the last
+// 24 hours, ordered oldest first. Buckets without usage are included as zeros.
+func (ht *HeatmapTracker) Heatmap(provider string, now time.Time) map[string][]UsageBucket {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	current := bucketIndex(now)
+	count := int64(heatmapWindow / heatmapBucketSize)
+
+	heatmap := make(map[string][]UsageBucket)
+	for keyName, keyBuckets := range ht.buckets[provider] {
+		series := make([]UsageBucket, 0, count)
+		for idx := current - count + 1; idx <= current; idx++ {
+			if bucket, exists := keyBuckets[idx]; exists {
+				series = append(series, *bucket)
+			} else {
+				series = append(series, UsageBucket{Start: bucketStart(idx)})
+			}
+		}
+		heatmap[keyName] = series
+	}
+	return heatmap
+}
+
+// prune drops buckets that fell out of the heatmap window
+func (ht *HeatmapTracker) prune(keyBuckets map[int64]*UsageBucket, current int64) {
+	oldest := current - int64(heatmapWindow/heatmapBucketSize) + 1
+	for idx := range keyBuckets {
+		if idx < oldest {
+			delete(keyBuckets, idx)
+		}
+	}
+}
+
+// bucketIndex returns the index of the bucket containing t
+func bucketIndex(t time.Time) int64 {
+	return t.Unix() / int64(heatmapBucketSize/time.Second)
+}
+
+// bucketStart returns the start time of the bucket with the given index
+func bucketStart(idx int64) time.Time {
+	return time.Unix(idx*int64(heatmapBucketSize/time.Second), 0).UTC()
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// ErrKeysSaturated is returned when every key is at its max_in_flight limit
+var ErrKeysSaturated = errors.New("all keys are at their in-flight limit")
+
+// inFlightTracker counts the requests in flight on keys with a max_in_flight
+// limit. It is guarded by the rotator's mutex.
+type inFlightTracker struct {
+	counts map[string]map[string]int // provider -> keyName -> requests in flight
+	freed  chan struct{}             // closed and replaced whenever a slot is released
+}
+
+// newInFlightTracker creates an empty tracker
+func newInFlightTracker() *inFlightTracker {
+	return &inFlightTracker{
+		counts: make(map[string]map[string]int),
+		freed:  make(chan struct{}),
+	}
+}
+
+// available reports whether key can take another request
+func (t *inFlightTracker) available(provider string, key config.APIKey) bool {
+	return key.MaxInFlight <= 0 || t.counts[provider][key.Name] < key.MaxInFlight
+}
+
+// acquire takes a slot of a key
+func (t *inFlightTracker) acquire(provider, keyName string) {
+	if t.counts[provider] == nil {
+		t.counts[provider] = make(map[string]int)
+	}
+	t.counts[provider][keyName]++
+}
+
+// release returns a slot of a key and wakes up requests queued for a slot
+func (t *inFlightTracker) release(provider, keyName string) {
+	if t.counts[provider][keyName] > 0 {
+		t.counts[provider][keyName]--
+	}
+	close(t.freed)
+	t.freed = make(chan struct{})
+}
+
+// filterSaturated removes keys that are at their max_in_flight limit
+func (kr *KeyRotator) filterSaturated(provider string, keys []config.APIKey) []config.APIKey {
+	var available []config.APIKey
+	for _, key := range keys {
+		if kr.inFlight.available(provider, key) {
+			available = append(available, key)
+		}
+	}
+	return available
+}
+
+// acquireSlot takes an in-flight slot for a selected key with a
+// max_in_flight limit, released by KeySelection.Release
+func (kr *KeyRotator) acquireSlot(selection *KeySelection) {
+	if selection.MaxInFlight <= 0 {
+		return
+	}
+	kr.inFlight.acquire(selection.Provider, selection.KeyName)
+
+	var once sync.Once
+	selection.release = func() {
+		once.Do(func() {
+			kr.mu.Lock()
+			defer kr.mu.Unlock()
+			kr.inFlight.release(selection.Provider, selection.KeyName)
+		})
+	}
+}
+
+// Release returns the in-flight slot taken by GetNextKey once the request
+// using the key has finished. It is safe to call more than once.
+func (s *KeySelection) Release() {
+	if s != nil && s.release != nil {
+		s.release()
+	}
+}
+
+// InFlight returns the number of requests in flight on a key with a
+// max_in_flight limit
+func (kr *KeyRotator) InFlight(provider, keyName string) int {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.inFlight.counts[provider][keyName]
+}
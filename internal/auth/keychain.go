@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultKeychainService is the service name keys are stored under in the OS keychain
+const DefaultKeychainService = "gollmkit"
+
+var (
+	// ErrKeychainNotFound is returned by a Keychain for a missing secret
+	ErrKeychainNotFound = errors.New("secret not found in keychain")
+
+	// ErrKeychainUnsupported is returned on platforms without a supported credential manager
+	ErrKeychainUnsupported = errors.New("OS keychain not supported on this platform")
+)
+
+// Keychain stores secrets in a credential manager, addressed by service and account
+type Keychain interface {
+	Get(service, account string) (string, error)
+	Set(service, account, secret string) error
+	Delete(service, account string) error
+}
+
+// SystemKeychain returns the credential manager of the OS: the macOS
+// Keychain, the Windows Credential Manager or the Linux Secret Service
+// (through secret-tool).
+func SystemKeychain() Keychain {
+	return systemKeychain{}
+}
+
+// KeychainKeyStore is a KeyStore keeping API keys in the OS keychain, so
+// developers don't need to put keys in YAML or environment variables. Each
+// key is stored under the account "<provider>/<key name>". Usage statistics,
+// health and soft deletes are kept in memory.
+type KeychainKeyStore struct {
+	*MemoryKeyStore
+	keychain Keychain
+	service  string
+
+	loadMu sync.Mutex // serializes loading keys from the keychain
+}
+
+// NewKeychainKeyStore creates a key store backed by keychain. Keys are loaded
+// from the keychain when first used and kept encrypted in memory.
+func NewKeychainKeyStore(keychain Keychain, service string) *KeychainKeyStore {
+	if service == "" {
+		service = DefaultKeychainService
+	}
+	return &KeychainKeyStore{
+		MemoryKeyStore: NewMemoryKeyStore(defaultEncryptionKey),
+		keychain:       keychain,
+		service:        service,
+	}
+}
+
+// keychainAccount returns the keychain account of a key
+func keychainAccount(provider, keyName string) string {
+	return provider + "/" + keyName
+}
+
+// Load reads a key from the keychain into the store unless it is already
+// loaded or soft-deleted
+func (k *KeychainKeyStore) Load(ctx context.Context, provider, keyName string) error {
+	k.loadMu.Lock()
+	defer k.loadMu.Unlock()
+
+	m := k.MemoryKeyStore
+	if m.hasKey(provider, keyName) {
+		return nil
+	}
+
+	account := keychainAccount(provider, keyName)
+	secret, err := k.keychain.Get(k.service, account)
+	if errors.Is(err, ErrKeychainNotFound) {
+		return fmt.Errorf("key %s not found in the OS keychain (service %q, account %q)", keyName, k.service, account)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read key %s for provider %s from the OS keychain: %w", keyName, provider, err)
+	}
+	return m.StoreKey(ctx, provider, keyName, secret)
+}
+
+// StoreKey stores an API key in the keychain. Storing the value a key already
+// has keeps its usage statistics.
+func (k *KeychainKeyStore) StoreKey(ctx context.Context, provider, keyName, key string) error {
+	if current, err := k.MemoryKeyStore.GetKey(ctx, provider, keyName); err == nil && current == key {
+		return nil
+	}
+
+	account := keychainAccount(provider, keyName)
+	if current, err := k.keychain.Get(k.service, account); err != nil || current != key {
+		if err := k.keychain.Set(k.service, account, key); err != nil {
+			return fmt.Errorf("failed to write key %s for provider %s to the OS keychain: %w", keyName, provider, err)
+		}
+	}
+	return k.MemoryKeyStore.StoreKey(ctx, provider, keyName, key)
+}
+
+// GetKey retrieves an API key, loading it from the keychain on first use
+func (k *KeychainKeyStore) GetKey(ctx context.Context, provider, keyName string) (string, error) {
+	if err := k.Load(ctx, provider, keyName); err != nil {
+		return "", err
+	}
+	return k.MemoryKeyStore.GetKey(ctx, provider, keyName)
+}
+
+// IsHealthy checks if a key is healthy, loading it from the keychain on first use
+func (k *KeychainKeyStore) IsHealthy(ctx context.Context, provider, keyName string) (bool, error) {
+	if err := k.Load(ctx, provider, keyName); err != nil {
+		return false, err
+	}
+	return k.MemoryKeyStore.IsHealthy(ctx, provider, keyName)
+}
+
+// PurgeKey permanently removes an API key from the store and the keychain
+func (k *KeychainKeyStore) PurgeKey(ctx context.Context, provider, keyName string) error {
+	err := k.keychain.Delete(k.service, keychainAccount(provider, keyName))
+	if err != nil && !errors.Is(err, ErrKeychainNotFound) {
+		return fmt.Errorf("failed to remove key %s for provider %s from the OS keychain: %w", keyName, provider, err)
+	}
+	return k.MemoryKeyStore.PurgeKey(ctx, provider, keyName)
+}
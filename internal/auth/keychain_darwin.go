@@ -0,0 +1,60 @@
+//go:build darwin
+
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityNotFound is the exit status of security(1) for a missing item
+const securityNotFound = 44
+
+// systemKeychain stores secrets in the macOS login keychain using security(1)
+type systemKeychain struct{}
+
+// Get implements Keychain
+func (systemKeychain) Get(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Set implements Keychain. The command is passed on stdin in interactive
+// mode so the secret doesn't show up in the process list.
+func (systemKeychain) Set(service, account, secret string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		securityQuote(service), securityQuote(account), hex.EncodeToString([]byte(secret))))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Delete implements Keychain
+func (systemKeychain) Delete(service, account string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).Run(); err != nil {
+		return securityError(err)
+	}
+	return nil
+}
+
+// securityError maps the exit status of a missing item to ErrKeychainNotFound
+func securityError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return ErrKeychainNotFound
+	}
+	return err
+}
+
+// securityQuote quotes an argument for security(1) interactive mode
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// systemKeychain stores secrets with the Secret Service (GNOME Keyring,
+// KWallet) using secret-tool(1)
+type systemKeychain struct{}
+
+// Get implements Keychain
+func (systemKeychain) Get(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		return "", secretToolError(err)
+	}
+	if len(out) == 0 {
+		return "", ErrKeychainNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Set implements Keychain. secret-tool reads the secret from stdin, so it
+// doesn't show up in the process list.
+func (systemKeychain) Set(service, account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", "gollmkit "+account,
+		"service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	if _, err := cmd.Output(); err != nil {
+		return secretToolError(err)
+	}
+	return nil
+}
+
+// Delete implements Keychain
+func (systemKeychain) Delete(service, account string) error {
+	if _, err := exec.Command("secret-tool", "clear", "service", service, "account", account).Output(); err != nil {
+		return secretToolError(err)
+	}
+	return nil
+}
+
+// secretToolError maps a missing secret-tool binary to ErrKeychainUnsupported.
+// secret-tool fails silently for missing secrets and with a message otherwise.
+func secretToolError(err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrKeychainUnsupported
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if len(exitErr.Stderr) == 0 {
+			return ErrKeychainNotFound
+		}
+		return fmt.Errorf("secret-tool: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
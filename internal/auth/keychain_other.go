@@ -0,0 +1,21 @@
+//go:build !darwin && !linux && !windows
+
+package auth
+
+// systemKeychain reports that no credential manager is supported
+type systemKeychain struct{}
+
+// Get implements Keychain
+func (systemKeychain) Get(service, account string) (string, error) {
+	return "", ErrKeychainUnsupported
+}
+
+// Set implements Keychain
+func (systemKeychain) Set(service, account, secret string) error {
+	return ErrKeychainUnsupported
+}
+
+// Delete implements Keychain
+func (systemKeychain) Delete(service, account string) error {
+	return ErrKeychainUnsupported
+}
@@ -0,0 +1,120 @@
+//go:build windows
+
+package auth
+
+import (
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential mirrors the Win32 CREDENTIALW structure
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// systemKeychain stores secrets as generic credentials of the Windows
+// Credential Manager, targeted "<service>:<account>"
+type systemKeychain struct{}
+
+// Get implements Keychain
+func (systemKeychain) Get(service, account string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(service + ":" + account)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return "", credError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	// Secrets are stored as UTF-16, like credentials added with cmdkey
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	chars := make([]uint16, len(blob)/2)
+	for i := range chars {
+		chars[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(chars)), nil
+}
+
+// Set implements Keychain
+func (systemKeychain) Set(service, account, secret string) error {
+	target, err := syscall.UTF16PtrFromString(service + ":" + account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	chars := utf16.Encode([]rune(secret))
+	blob := make([]byte, 2*len(chars))
+	for i, c := range chars {
+		blob[2*i] = byte(c)
+		blob[2*i+1] = byte(c >> 8)
+	}
+
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+// Delete implements Keychain
+func (systemKeychain) Delete(service, account string) error {
+	target, err := syscall.UTF16PtrFromString(service + ":" + account)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+// credError maps ERROR_NOT_FOUND to ErrKeychainNotFound
+func credError(err error) error {
+	if err == errorNotFound {
+		return ErrKeychainNotFound
+	}
+	return err
+}
@@ -6,13 +6,16 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
 	"time"
 
 	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
 )
 
 // KeyStore defines the interface for API key storage and management
@@ -23,45 +26,122 @@ type KeyStore interface {
 	// GetKey retrieves an API key
 	GetKey(ctx context.Context, provider, keyName string) (string, error)
 
-	// DeleteKey removes an API key
+	// DeleteKey soft-deletes an API key: it is hidden from rotation but its
+	// usage statistics are retained and can still be queried
 	DeleteKey(ctx context.Context, provider, keyName string) error
 
-	// ListKeys returns all key names for a provider
+	// RestoreKey restores a soft-deleted API key
+	RestoreKey(ctx context.Context, provider, keyName string) error
+
+	// PurgeKey permanently removes an API key together with its statistics
+	PurgeKey(ctx context.Context, provider, keyName string) error
+
+	// ListKeys returns all active key names for a provider
 	ListKeys(ctx context.Context, provider string) ([]string, error)
 
+	// ListDeletedKeys returns the soft-deleted key names for a provider
+	ListDeletedKeys(ctx context.Context, provider string) ([]string, error)
+
 	// IsHealthy checks if a key is healthy and valid
 	IsHealthy(ctx context.Context, provider, keyName string) (bool, error)
 
-	// UpdateUsage updates key usage statistics
-	UpdateUsage(ctx context.Context, provider, keyName string, tokens int, cost float64) error
+	// SetHealth sets the health status of a key
+	SetHealth(ctx context.Context, provider, keyName string, healthy bool) error
+
+	// RecordError records a failed request or validation for a key
+	RecordError(ctx context.Context, provider, keyName, errorMsg string) error
+
+	// UpdateUsage updates the usage statistics of a key and of the model
+	// the request was sent to. An empty model updates only the key.
+	UpdateUsage(ctx context.Context, provider, keyName, model string, tokens int, cost float64) error
 
 	// GetUsage returns key usage statistics
 	GetUsage(ctx context.Context, provider, keyName string) (*KeyUsage, error)
 
+	// GetUsageSeries returns the usage buckets of a key at the given
+	// resolution starting at or after since, oldest first
+	GetUsageSeries(ctx context.Context, provider, keyName string, resolution Resolution, since time.Time) ([]UsagePoint, error)
+
+	// GetModelUsage returns the usage statistics of every model of a
+	// provider, across all of its keys
+	GetModelUsage(ctx context.Context, provider string) (map[string]*ModelUsage, error)
+
+	// GetModelUsageSeries returns the usage buckets of a model at the given
+	// resolution starting at or after since, oldest first
+	GetModelUsageSeries(ctx context.Context, provider, model string, resolution Resolution, since time.Time) ([]UsagePoint, error)
+
 	// Close closes the keystore connection
 	Close() error
 }
 
+// Flusher is implemented by key stores that buffer usage statistics before
+// persisting them, such as FileKeyStore
+type Flusher interface {
+	// Flush persists pending usage statistics
+	Flush() error
+}
+
 // KeyUsage represents usage statistics for an API key
 type KeyUsage struct {
 	LastUsed   time.Time `json:"last_used"`
 	UsageCount int64     `json:"usage_count"`
 	TokensUsed int64     `json:"tokens_used"`
 	CostUsed   float64   `json:"cost_used"`
-	DailyCost  float64   `json:"daily_cost"`
+	DailyCost  float64   `json:"daily_cost"` // cost since midnight UTC
 	ErrorCount int64     `json:"error_count"`
 	LastError  string    `json:"last_error,omitempty"`
 }
 
-// MemoryKeyStore is an in-memory implementation of KeyStore for development/testing
+// ModelUsage represents usage statistics for a model across all keys of a provider
+type ModelUsage struct {
+	LastUsed   time.Time `json:"last_used"`
+	UsageCount int64     `json:"usage_count"`
+	TokensUsed int64     `json:"tokens_used"`
+	CostUsed   float64   `json:"cost_used"`
+}
+
+// MemoryKeyStore is an in-memory implementation of KeyStore for development/testing.
+// Keys are sharded by provider and every key and model has its own lock, so
+// concurrent requests only contend when they use the same key or model.
 type MemoryKeyStore struct {
-	mu        sync.RWMutex
-	keys      map[string]map[string]string    // provider -> keyName -> encryptedKey
-	usage     map[string]map[string]*KeyUsage // provider -> keyName -> usage
-	health    map[string]map[string]bool      // provider -> keyName -> healthy
+	mu        sync.RWMutex         // guards shards and encryptor; held shared by every operation
+	shards    map[string]*keyShard // provider -> keys and models
 	encryptor *KeyEncryptor
 }
 
+// keyShard holds the keys and models of one provider. Its lock guards the
+// maps, the entries in them have their own.
+type keyShard struct {
+	mu     sync.RWMutex
+	keys   map[string]*keyEntry   // keyName -> key
+	models map[string]*modelEntry // model -> usage
+}
+
+// keyEntry is a stored key with its health and usage statistics
+type keyEntry struct {
+	mu        sync.Mutex
+	stored    string // encrypted, unless the store has no encryptor
+	healthy   bool
+	deletedAt *time.Time // set while soft-deleted
+	usage     KeyUsage
+	series    *UsageSeries
+}
+
+// modelEntry holds the usage statistics of a model
+type modelEntry struct {
+	mu     sync.Mutex
+	usage  ModelUsage
+	series *UsageSeries
+}
+
+// newKeyShard creates an empty shard
+func newKeyShard() *keyShard {
+	return &keyShard{
+		keys:   make(map[string]*keyEntry),
+		models: make(map[string]*modelEntry),
+	}
+}
+
 // NewMemoryKeyStore creates a new in-memory key store
 func NewMemoryKeyStore(encryptionKey string) *MemoryKeyStore {
 	var encryptor *KeyEncryptor
@@ -70,24 +150,69 @@ func NewMemoryKeyStore(encryptionKey string) *MemoryKeyStore {
 	}
 
 	return &MemoryKeyStore{
-		keys:      make(map[string]map[string]string),
-		usage:     make(map[string]map[string]*KeyUsage),
-		health:    make(map[string]map[string]bool),
+		shards:    make(map[string]*keyShard),
 		encryptor: encryptor,
 	}
 }
 
-// StoreKey stores an API key securely
-func (m *MemoryKeyStore) StoreKey(ctx context.Context, provider, keyName, key string) error {
+// addShard creates the shard of provider if it doesn't exist yet. Shards are
+// never removed. The caller must not hold m.mu.
+func (m *MemoryKeyStore) addShard(provider string) {
+	m.mu.RLock()
+	_, exists := m.shards[provider]
+	m.mu.RUnlock()
+	if exists {
+		return
+	}
+
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	if m.shards[provider] == nil {
+		m.shards[provider] = newKeyShard()
+	}
+	m.mu.Unlock()
+}
 
-	if m.keys[provider] == nil {
-		m.keys[provider] = make(map[string]string)
-		m.usage[provider] = make(map[string]*KeyUsage)
-		m.health[provider] = make(map[string]bool)
+// entry returns the entry of a key. The caller must hold m.mu.
+func (m *MemoryKeyStore) entry(provider, keyName string) (*keyEntry, error) {
+	shard, exists := m.shards[provider]
+	if !exists {
+		return nil, fmt.Errorf("provider %s not found", provider)
 	}
 
+	shard.mu.RLock()
+	entry, exists := shard.keys[keyName]
+	shard.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("key %s not found for provider %s", keyName, provider)
+	}
+	return entry, nil
+}
+
+// model returns the entry of a model, creating it if needed
+func (s *keyShard) model(model string) *modelEntry {
+	s.mu.RLock()
+	entry, exists := s.models[model]
+	s.mu.RUnlock()
+	if exists {
+		return entry
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, exists = s.models[model]; !exists {
+		entry = &modelEntry{series: NewUsageSeries()}
+		s.models[model] = entry
+	}
+	return entry
+}
+
+// StoreKey stores an API key securely
+func (m *MemoryKeyStore) StoreKey(ctx context.Context, provider, keyName, key string) error {
+	m.addShard(provider)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	var storedKey string
 	var err error
 
@@ -100,72 +225,171 @@ func (m *MemoryKeyStore) StoreKey(ctx context.Context, provider, keyName, key st
 		storedKey = key
 	}
 
-	m.keys[provider][keyName] = storedKey
-	m.usage[provider][keyName] = &KeyUsage{
-		LastUsed:   time.Now(),
-		UsageCount: 0,
-		TokensUsed: 0,
-		CostUsed:   0,
-		DailyCost:  0,
-		ErrorCount: 0,
+	shard := m.shards[provider]
+	shard.mu.Lock()
+	shard.keys[keyName] = &keyEntry{
+		stored:  storedKey,
+		healthy: true,
+		usage:   KeyUsage{LastUsed: time.Now()},
+		series:  NewUsageSeries(),
 	}
-	m.health[provider][keyName] = true
+	shard.mu.Unlock()
 
 	return nil
 }
 
+// hasKey reports whether a key is stored, soft-deleted or not
+func (m *MemoryKeyStore) hasKey(provider, keyName string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, err := m.entry(provider, keyName)
+	return err == nil
+}
+
+// restoreIfStored restores a key and reports true if it is already stored
+// with the value key, keeping its usage statistics
+func (m *MemoryKeyStore) restoreIfStored(provider, keyName, key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return false
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	current := entry.stored
+	if m.encryptor != nil {
+		if current, err = m.encryptor.Decrypt(entry.stored); err != nil {
+			return false
+		}
+	}
+	if current != key {
+		return false
+	}
+	entry.deletedAt = nil
+	return true
+}
+
 // GetKey retrieves an API key
 func (m *MemoryKeyStore) GetKey(ctx context.Context, provider, keyName string) (string, error) {
+	m.mu.RLock()
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		m.mu.RUnlock()
+		return "", err
+	}
+	entry.mu.Lock()
+	stored, deleted := entry.stored, entry.deletedAt != nil
+	entry.mu.Unlock()
+	encryptor := m.encryptor
+	m.mu.RUnlock()
+
+	if deleted {
+		return "", fmt.Errorf("key %s for provider %s is deleted", keyName, provider)
+	}
+
+	// Decrypt outside the locks, it is the most expensive part of the lookup
+	if encryptor != nil {
+		return encryptor.Decrypt(stored)
+	}
+
+	return stored, nil
+}
+
+// DeleteKey soft-deletes an API key, retaining its usage statistics
+func (m *MemoryKeyStore) DeleteKey(ctx context.Context, provider, keyName string) error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	providerKeys, exists := m.keys[provider]
-	if !exists {
-		return "", fmt.Errorf("provider %s not found", provider)
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return nil
 	}
 
-	encryptedKey, exists := providerKeys[keyName]
-	if !exists {
-		return "", fmt.Errorf("key %s not found for provider %s", keyName, provider)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.deletedAt == nil {
+		now := time.Now()
+		entry.deletedAt = &now
 	}
 
-	if m.encryptor != nil {
-		return m.encryptor.Decrypt(encryptedKey)
+	return nil
+}
+
+// RestoreKey restores a soft-deleted API key
+func (m *MemoryKeyStore) RestoreKey(ctx context.Context, provider, keyName string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	notDeleted := fmt.Errorf("key %s for provider %s is not deleted", keyName, provider)
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return notDeleted
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.deletedAt == nil {
+		return notDeleted
 	}
+	entry.deletedAt = nil
 
-	return encryptedKey, nil
+	return nil
 }
 
-// DeleteKey removes an API key
-func (m *MemoryKeyStore) DeleteKey(ctx context.Context, provider, keyName string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// PurgeKey permanently removes an API key together with its statistics
+func (m *MemoryKeyStore) PurgeKey(ctx context.Context, provider, keyName string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	if m.keys[provider] != nil {
-		delete(m.keys[provider], keyName)
-		delete(m.usage[provider], keyName)
-		delete(m.health[provider], keyName)
+	if shard, exists := m.shards[provider]; exists {
+		shard.mu.Lock()
+		delete(shard.keys, keyName)
+		shard.mu.Unlock()
 	}
 
 	return nil
 }
 
-// ListKeys returns all key names for a provider
-func (m *MemoryKeyStore) ListKeys(ctx context.Context, provider string) ([]string, error) {
+// listKeys returns the names of the keys of a provider that are soft-deleted,
+// or of those that aren't
+func (m *MemoryKeyStore) listKeys(provider string, deleted bool) []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	providerKeys, exists := m.keys[provider]
+	shard, exists := m.shards[provider]
 	if !exists {
-		return []string{}, nil
+		return []string{}
 	}
 
-	keys := make([]string, 0, len(providerKeys))
-	for keyName := range providerKeys {
-		keys = append(keys, keyName)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	keys := make([]string, 0, len(shard.keys))
+	for keyName, entry := range shard.keys {
+		entry.mu.Lock()
+		isDeleted := entry.deletedAt != nil
+		entry.mu.Unlock()
+		if isDeleted == deleted {
+			keys = append(keys, keyName)
+		}
 	}
 
-	return keys, nil
+	return keys
+}
+
+// ListKeys returns all active key names for a provider
+func (m *MemoryKeyStore) ListKeys(ctx context.Context, provider string) ([]string, error) {
+	return m.listKeys(provider, false), nil
+}
+
+// ListDeletedKeys returns the soft-deleted key names for a provider
+func (m *MemoryKeyStore) ListDeletedKeys(ctx context.Context, provider string) ([]string, error) {
+	return m.listKeys(provider, true), nil
 }
 
 // IsHealthy checks if a key is healthy and valid
@@ -173,46 +397,120 @@ func (m *MemoryKeyStore) IsHealthy(ctx context.Context, provider, keyName string
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if m.health[provider] == nil {
-		return false, fmt.Errorf("provider %s not found", provider)
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return false, err
 	}
 
-	healthy, exists := m.health[provider][keyName]
-	if !exists {
-		return false, fmt.Errorf("key %s not found for provider %s", keyName, provider)
-	}
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	return entry.healthy, nil
+}
 
-	return healthy, nil
+// UpdateUsage updates the usage statistics of a key and of model
+func (m *MemoryKeyStore) UpdateUsage(ctx context.Context, provider, keyName, model string, tokens int, cost float64) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.updateUsage(UsageRecord{Provider: provider, KeyName: keyName, Model: model, Tokens: tokens, Cost: cost, Time: time.Now()})
 }
 
-// UpdateUsage updates key usage statistics
-func (m *MemoryKeyStore) UpdateUsage(ctx context.Context, provider, keyName string, tokens int, cost float64) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// UpdateUsageBatch updates the usage statistics of many requests, at the
+// time each of them finished
+func (m *MemoryKeyStore) UpdateUsageBatch(ctx context.Context, records []UsageRecord) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var errs []error
+	for _, record := range records {
+		if record.Time.IsZero() {
+			record.Time = time.Now()
+		}
+		if err := m.updateUsage(record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// updateUsage applies a usage record. The caller must hold m.mu.
+func (m *MemoryKeyStore) updateUsage(record UsageRecord) error {
+	entry, err := m.entry(record.Provider, record.KeyName)
+	if err != nil {
+		return err
+	}
 
-	if m.usage[provider] == nil {
-		return fmt.Errorf("provider %s not found", provider)
+	entry.mu.Lock()
+	entry.usage.LastUsed = record.Time
+	entry.usage.UsageCount++
+	entry.usage.TokensUsed += int64(record.Tokens)
+	entry.usage.CostUsed += record.Cost
+	entry.series.Add(record.Time, record.Tokens, record.Cost)
+	entry.mu.Unlock()
+
+	if record.Model != "" {
+		m.shards[record.Provider].model(record.Model).add(record.Time, record.Tokens, record.Cost)
 	}
+	return nil
+}
+
+// add adds a request to the statistics of a model
+func (e *modelEntry) add(now time.Time, tokens int, cost float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	usage, exists := m.usage[provider][keyName]
+	e.usage.LastUsed = now
+	e.usage.UsageCount++
+	e.usage.TokensUsed += int64(tokens)
+	e.usage.CostUsed += cost
+	e.series.Add(now, tokens, cost)
+}
+
+// GetModelUsage returns copies of the usage statistics of every model of a provider
+func (m *MemoryKeyStore) GetModelUsage(ctx context.Context, provider string) (map[string]*ModelUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shard, exists := m.shards[provider]
 	if !exists {
-		return fmt.Errorf("key %s not found for provider %s", keyName, provider)
+		return map[string]*ModelUsage{}, nil
 	}
 
-	usage.LastUsed = time.Now()
-	usage.UsageCount++
-	usage.TokensUsed += int64(tokens)
-	usage.CostUsed += cost
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
 
-	// Reset daily cost if it's a new day
-	now := time.Now()
-	if usage.LastUsed.Day() != now.Day() {
-		usage.DailyCost = cost
-	} else {
-		usage.DailyCost += cost
+	models := make(map[string]*ModelUsage, len(shard.models))
+	for model, entry := range shard.models {
+		entry.mu.Lock()
+		copied := entry.usage
+		entry.mu.Unlock()
+		models[model] = &copied
 	}
+	return models, nil
+}
 
-	return nil
+// GetModelUsageSeries returns the usage buckets of a model at the given resolution starting at or after since
+func (m *MemoryKeyStore) GetModelUsageSeries(ctx context.Context, provider, model string, resolution Resolution, since time.Time) ([]UsagePoint, error) {
+	if _, known := resolutionRetention[resolution]; !known {
+		return nil, fmt.Errorf("unknown usage resolution %q", resolution)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shard, exists := m.shards[provider]
+	if !exists {
+		return nil, nil // no requests recorded for the provider yet
+	}
+	shard.mu.RLock()
+	entry, exists := shard.models[model]
+	shard.mu.RUnlock()
+	if !exists {
+		return nil, nil // no requests recorded for the model yet
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	return entry.series.Points(resolution, since), nil
 }
 
 // GetUsage returns key usage statistics
@@ -220,65 +518,126 @@ func (m *MemoryKeyStore) GetUsage(ctx context.Context, provider, keyName string)
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if m.usage[provider] == nil {
-		return nil, fmt.Errorf("provider %s not found", provider)
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return nil, err
 	}
 
-	usage, exists := m.usage[provider][keyName]
-	if !exists {
-		return nil, fmt.Errorf("key %s not found for provider %s", keyName, provider)
-	}
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
 
 	// Return a copy to prevent external modification
-	return &KeyUsage{
-		LastUsed:   usage.LastUsed,
-		UsageCount: usage.UsageCount,
-		TokensUsed: usage.TokensUsed,
-		CostUsed:   usage.CostUsed,
-		DailyCost:  usage.DailyCost,
-		ErrorCount: usage.ErrorCount,
-		LastError:  usage.LastError,
-	}, nil
+	usage := entry.usage
+	usage.DailyCost = SumPoints(entry.series.Points(ResolutionDay, time.Now())).Cost
+	return &usage, nil
+}
+
+// GetUsageSeries returns the usage buckets of a key at the given resolution starting at or after since
+func (m *MemoryKeyStore) GetUsageSeries(ctx context.Context, provider, keyName string, resolution Resolution, since time.Time) ([]UsagePoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return nil, err
+	}
+	if _, known := resolutionRetention[resolution]; !known {
+		return nil, fmt.Errorf("unknown usage resolution %q", resolution)
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	return entry.series.Points(resolution, since), nil
 }
 
 // SetHealth sets the health status of a key
 func (m *MemoryKeyStore) SetHealth(ctx context.Context, provider, keyName string, healthy bool) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	if m.health[provider] == nil {
-		return fmt.Errorf("provider %s not found", provider)
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return err
 	}
 
-	m.health[provider][keyName] = healthy
+	entry.mu.Lock()
+	entry.healthy = healthy
+	entry.mu.Unlock()
 	return nil
 }
 
 // RecordError records an error for a key
 func (m *MemoryKeyStore) RecordError(ctx context.Context, provider, keyName, errorMsg string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	if m.usage[provider] == nil {
-		return fmt.Errorf("provider %s not found", provider)
+	entry, err := m.entry(provider, keyName)
+	if err != nil {
+		return err
 	}
 
-	usage, exists := m.usage[provider][keyName]
-	if !exists {
-		return fmt.Errorf("key %s not found for provider %s", keyName, provider)
-	}
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
 
-	usage.ErrorCount++
-	usage.LastError = errorMsg
+	entry.usage.ErrorCount++
+	entry.usage.LastError = errorMsg
 
 	// Mark as unhealthy if too many errors
-	if usage.ErrorCount > 5 {
-		m.health[provider][keyName] = false
+	if entry.usage.ErrorCount > 5 {
+		entry.healthy = false
 	}
 
 	return nil
 }
 
+// ReencryptAll re-encrypts every stored key from the passphrase oldKey to
+// newKey, which encrypts keys stored afterwards. oldKey must be the current
+// passphrase, or empty for a store that isn't encrypted.
+func (m *MemoryKeyStore) ReencryptAll(ctx context.Context, oldKey, newKey string) error {
+	if newKey == "" {
+		return errors.New("new encryption key must not be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.encryptor != nil
+	if current != (oldKey != "") ||
+		current && subtle.ConstantTimeCompare(m.encryptor.key, NewKeyEncryptor(oldKey).key) != 1 {
+		return errors.New("old encryption key does not match the key store")
+	}
+	return m.reencrypt(NewKeyEncryptor(newKey))
+}
+
+// reencrypt re-encrypts every stored key with encryptor, which replaces the
+// current one. Nothing changes if a key fails to decrypt. The caller must
+// hold m.mu exclusively, which keeps every other operation out of the entries.
+func (m *MemoryKeyStore) reencrypt(encryptor *KeyEncryptor) error {
+	rekeyed := make(map[*keyEntry]string)
+	for provider, shard := range m.shards {
+		for keyName, entry := range shard.keys {
+			plaintext := entry.stored
+			if m.encryptor != nil {
+				var err error
+				if plaintext, err = m.encryptor.Decrypt(entry.stored); err != nil {
+					return fmt.Errorf("failed to decrypt key %s for provider %s: %w", keyName, provider, err)
+				}
+			}
+			encrypted, err := encryptor.Encrypt(plaintext)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt key %s for provider %s: %w", keyName, provider, err)
+			}
+			rekeyed[entry] = encrypted
+		}
+	}
+
+	for entry, encrypted := range rekeyed {
+		entry.stored = encrypted
+	}
+	m.encryptor = encryptor
+	return nil
+}
+
 // Close closes the keystore connection
 func (m *MemoryKeyStore) Close() error {
 	// Nothing to close for memory store
@@ -349,24 +708,39 @@ func (e *KeyEncryptor) Decrypt(ciphertext string) (string, error) {
 	return string(plaintext), nil
 }
 
-// NewKeyStoreFromConfig creates a KeyStore from configuration
+// NewKeyStoreFromConfig creates the KeyStore of the type registered for
+// global.key_store.type and populates it with the keys of the configuration.
+// Warnings are logged to slog.Default(); see NewKeyStoreFromConfigWithLogger.
 func NewKeyStoreFromConfig(cfg *config.Config) (KeyStore, error) {
-	// For now, we only support memory store
-	// In production, this could be extended to support database backends
+	return NewKeyStoreFromConfigWithLogger(cfg, nil)
+}
 
-	var encryptionKey string
-	if cfg.Global.EncryptKeys {
-		// In production, this should come from environment or secure vault
-		encryptionKey = "default-encryption-key-change-in-production"
+// NewKeyStoreFromConfigWithLogger creates the KeyStore like
+// NewKeyStoreFromConfig, logging warnings to logger. Stores with a SetLogger
+// method, such as FileKeyStore, log their background errors to it too.
+func NewKeyStoreFromConfigWithLogger(cfg *config.Config, logger logging.Logger) (KeyStore, error) {
+	factory, err := keyStoreFactory(cfg.Global.KeyStore.Type)
+	if err != nil {
+		return nil, err
+	}
+	warnDefaultKey(cfg, logger)
+	store, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if setter, ok := store.(interface{ SetLogger(logging.Logger) }); ok && logger != nil {
+		setter.SetLogger(logger)
 	}
-
-	store := NewMemoryKeyStore(encryptionKey)
 
 	// Populate store with keys from config
 	ctx := context.Background()
 	for providerName, provider := range cfg.Providers {
 		for _, apiKey := range provider.APIKeys {
+			if apiKey.IsKeyRef() {
+				continue // already held by the key store
+			}
 			if err := store.StoreKey(ctx, providerName, apiKey.Name, apiKey.Key); err != nil {
+				store.Close()
 				return nil, fmt.Errorf("failed to store key %s for provider %s: %w",
 					apiKey.Name, providerName, err)
 			}
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultKMSTimeout bounds a single KMS request
+const defaultKMSTimeout = 30 * time.Second
+
+// AWSCredentials are the credentials used to sign AWS KMS requests
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSCredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// the optional AWS_SESSION_TOKEN
+func AWSCredentialsFromEnv() (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for aws-kms master keys")
+	}
+	return creds, nil
+}
+
+// AWSKMSMasterKey wraps data keys with an AWS KMS key
+type AWSKMSMasterKey struct {
+	keyID    string
+	region   string
+	creds    AWSCredentials
+	endpoint string
+	client   *http.Client
+}
+
+// NewAWSKMSMasterKey creates a master key for the KMS key keyID (key ID,
+// ARN or alias) in region
+func NewAWSKMSMasterKey(keyID, region string, creds AWSCredentials) *AWSKMSMasterKey {
+	return &AWSKMSMasterKey{
+		keyID:    keyID,
+		region:   region,
+		creds:    creds,
+		endpoint: fmt.Sprintf("https://kms.%s.amazonaws.com/", region),
+		client:   kmsHTTPClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client used for KMS requests
+func (k *AWSKMSMasterKey) SetHTTPClient(client *http.Client) {
+	k.client = client
+}
+
+// ID implements MasterKey
+func (k *AWSKMSMasterKey) ID() string {
+	return k.keyID
+}
+
+// Wrap implements MasterKey
+func (k *AWSKMSMasterKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	req := map[string]any{"KeyId": k.keyID, "Plaintext": dataKey}
+	if err := k.call(ctx, "Encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.CiphertextBlob, nil
+}
+
+// Unwrap implements MasterKey
+func (k *AWSKMSMasterKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	req := map[string]any{"KeyId": k.keyID, "CiphertextBlob": wrapped}
+	if err := k.call(ctx, "Decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// call performs a signed KMS API request. []byte fields are base64-encoded
+// by encoding/json, as KMS expects.
+func (k *AWSKMSMasterKey) call(ctx context.Context, action string, body, result any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	signAWSRequest(req, payload, k.creds, k.region, "kms", time.Now())
+
+	return doKMSRequest(k.client, req, "AWS KMS "+action, result)
+}
+
+// signAWSRequest adds AWS Signature Version 4 headers to req
+func signAWSRequest(req *http.Request, payload []byte, creds AWSCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	canonicalRequest, signedHeaders := awsCanonicalRequest(req, payload)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	signature := awsSignature(creds.SecretAccessKey, date, region, service, awsStringToSign(amzDate, scope, canonicalRequest))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalRequest returns the SigV4 canonical request of req and its
+// signed headers, which are all headers of req
+func awsCanonicalRequest(req *http.Request, payload []byte) (canonical, signedHeaders string) {
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ") // inner spaces collapsed
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders = strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonical = strings.Join([]string{
+		req.Method,
+		path,
+		awsCanonicalQuery(req.URL.RawQuery),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	return canonical, signedHeaders
+}
+
+// awsCanonicalQuery returns the SigV4 canonical query string of rawQuery:
+// its parameters URI-encoded and sorted by name, then value
+func awsCanonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var params [][2]string
+	for _, param := range strings.Split(rawQuery, "&") {
+		name, value, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params = append(params, [2]string{awsURIEncode(name), awsURIEncode(value)})
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+
+	encoded := make([]string, len(params))
+	for i, param := range params {
+		encoded[i] = param[0] + "=" + param[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// awsURIEncode percent-encodes every byte of s but the unreserved characters
+// of RFC 3986, as SigV4 requires
+func awsURIEncode(s string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			encoded.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", c)
+	}
+	return encoded.String()
+}
+
+// awsStringToSign returns the SigV4 string to sign of a canonical request
+func awsStringToSign(amzDate, scope, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	return "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+}
+
+// awsSignature returns the SigV4 signature of stringToSign, with the signing
+// key derived from secret for the date, region and service
+func awsSignature(secret, date, region, service, stringToSign string) string {
+	signingKey := hmacSHA256([]byte("AWS4"+secret), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// GCPTokenSource returns an OAuth access token for Google Cloud APIs
+type GCPTokenSource func(ctx context.Context) (string, error)
+
+// gcpMetadataTokenURL serves the default service account token on Google Cloud
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// DefaultGCPTokenSource returns GOOGLE_OAUTH_ACCESS_TOKEN if set, and the
+// default service account token of the instance metadata server otherwise
+func DefaultGCPTokenSource(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doKMSRequest(kmsHTTPClient, req, "GCP metadata token", &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// GCPKMSMasterKey wraps data keys with a Google Cloud KMS key
+type GCPKMSMasterKey struct {
+	keyName  string
+	tokens   GCPTokenSource
+	endpoint string
+	client   *http.Client
+}
+
+// NewGCPKMSMasterKey creates a master key for the KMS key resource keyName,
+// "projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>"
+func NewGCPKMSMasterKey(keyName string, tokens GCPTokenSource) *GCPKMSMasterKey {
+	return &GCPKMSMasterKey{
+		keyName:  keyName,
+		tokens:   tokens,
+		endpoint: "https://cloudkms.googleapis.com/v1/",
+		client:   kmsHTTPClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client used for KMS requests
+func (k *GCPKMSMasterKey) SetHTTPClient(client *http.Client) {
+	k.client = client
+}
+
+// ID implements MasterKey
+func (k *GCPKMSMasterKey) ID() string {
+	return k.keyName
+}
+
+// Wrap implements MasterKey
+func (k *GCPKMSMasterKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := k.call(ctx, "encrypt", map[string]any{"plaintext": dataKey}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+// Unwrap implements MasterKey
+func (k *GCPKMSMasterKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := k.call(ctx, "decrypt", map[string]any{"ciphertext": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// call performs an authorized Cloud KMS API request
+func (k *GCPKMSMasterKey) call(ctx context.Context, method string, body, result any) error {
+	token, err := k.tokens(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get GCP access token: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	endpoint := k.endpoint + (&url.URL{Path: k.keyName}).EscapedPath() + ":" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return doKMSRequest(k.client, req, "GCP KMS "+method, result)
+}
+
+// doKMSRequest sends req and decodes the JSON response into result
+func doKMSRequest(client *http.Client, req *http.Request, operation string, result any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", operation, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed with status %d: %s", operation, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("%s returned an invalid response: %w", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignAWSRequest signs requests of the AWS Signature Version 4 test
+// suite and checks the canonical request, string to sign and signature
+// published for each
+func TestSignAWSRequest(t *testing.T) {
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	const scope = "20150830/us-east-1/service/aws4_request"
+
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		headers   [][2]string
+		body      string
+		canonical string
+		hash      string // of the canonical request, the last line of the string to sign
+		signature string
+	}{
+		{
+			name:   "get-vanilla",
+			method: "GET",
+			url:    "https://example.amazonaws.com/",
+			canonical: "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "bb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "post-vanilla",
+			method: "POST",
+			url:    "https://example.amazonaws.com/",
+			canonical: "POST\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "553f88c9e4d10fc9e109e2aeb65f030801b70c2f6468faca261d401ae622fc87",
+			signature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:   "get-vanilla-query-order-key-case",
+			method: "GET",
+			url:    "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			canonical: "GET\n/\nParam1=value1&Param2=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "816cd5b414d056048ba4f7c5386d6e0533120fb1fcfa93762cf0fc39e2cf19e0",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:   "get-vanilla-empty-query-key",
+			method: "GET",
+			url:    "https://example.amazonaws.com/?Param1=value1",
+			canonical: "GET\n/\nParam1=value1\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "1e24db194ed7d0eec2de28d7369675a243488e08526e8c1c73571282f7c517ab",
+			signature: "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb",
+		},
+		{
+			name:   "get-utf8",
+			method: "GET",
+			url:    "https://example.amazonaws.com/ሴ",
+			canonical: "GET\n/%E1%88%B4\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "2a0a97d02205e45ce2e994789806b19270cfbbb0921b278ccf58f5249ac42102",
+			signature: "8318018e0b0f223aa2bbf98705b62bb787dc9c0e678f255a891fd03141be5d85",
+		},
+		{
+			name:    "post-header-key-sort",
+			method:  "POST",
+			url:     "https://example.amazonaws.com/",
+			headers: [][2]string{{"My-Header1", "value1"}},
+			canonical: "POST\n/\n\nhost:example.amazonaws.com\nmy-header1:value1\nx-amz-date:20150830T123600Z\n\nhost;my-header1;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "9368318c2967cf6de74404b30c65a91e8f6253e0a8659d6d5319f1a812f87d65",
+			signature: "c5410059b04c1ee005303aed430f6e6645f61f4dc9e1461ec8f8916fdf18852c",
+		},
+		{
+			name:    "get-header-value-trim",
+			method:  "GET",
+			url:     "https://example.amazonaws.com/",
+			headers: [][2]string{{"My-Header1", " value1"}, {"My-Header2", ` "a   b   c"`}},
+			canonical: "GET\n/\n\nhost:example.amazonaws.com\nmy-header1:value1\nmy-header2:\"a b c\"\nx-amz-date:20150830T123600Z\n\nhost;my-header1;my-header2;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash:      "a726db9b0df21c14f559d0a978e563112acb1b9e05476f0a6a1c7d68f28605c7",
+			signature: "acc3ed3afb60bb290fc8d2dd0098b9911fcaa05412b367055dee359757a9c736",
+		},
+		{
+			name:    "post-x-www-form-urlencoded",
+			method:  "POST",
+			url:     "https://example.amazonaws.com/",
+			headers: [][2]string{{"Content-Type", "application/x-www-form-urlencoded"}},
+			body:    "Param1=value1",
+			canonical: "POST\n/\n\ncontent-type:application/x-www-form-urlencoded\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\ncontent-type;host;x-amz-date\n" +
+				"9095672bbd1f56dfc5b65f3e153adc8731a4a654192329106275f4c7b24d0b6e",
+			hash:      "42a5e5bb34198acb3e84da4f085bb7927f2bc277ca766e6d19c73c2154021281",
+			signature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, header := range tt.headers {
+				req.Header.Add(header[0], header[1])
+			}
+			req.Header.Set("X-Amz-Date", "20150830T123600Z")
+
+			canonical, signedHeaders := awsCanonicalRequest(req, []byte(tt.body))
+			if canonical != tt.canonical {
+				t.Errorf("canonical request:\n%s\nwant:\n%s", canonical, tt.canonical)
+			}
+			stringToSign := awsStringToSign("20150830T123600Z", scope, canonical)
+			if want := "AWS4-HMAC-SHA256\n20150830T123600Z\n" + scope + "\n" + tt.hash; stringToSign != want {
+				t.Errorf("string to sign:\n%s\nwant:\n%s", stringToSign, want)
+			}
+
+			signAWSRequest(req, []byte(tt.body), creds, "us-east-1", "service", now)
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/" + scope + ", SignedHeaders=" + signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("authorization:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestSignAWSRequestSessionToken signs the session token of temporary
+// credentials
+func TestSignAWSRequestSessionToken(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://kms.us-east-1.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := AWSCredentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}
+	signAWSRequest(req, nil, creds, "us-east-1", "kms", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("security token header %q, want token", got)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("session token isn't signed: %s", auth)
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultLatencyWindow is the number of samples kept per provider/model
+const defaultLatencyWindow = 100
+
+// LatencyStats represents rolling latency percentiles
+type LatencyStats struct {
+	Samples int           `json:"samples"`
+	P50     time.Duration `json:"p50"`
+	P95     time.Duration `json:"p95"`
+}
+
+// LatencyTracker keeps a rolling window of request latencies per provider and model
+type LatencyTracker struct {
+	mu      sync.RWMutex
+	size    int
+	windows map[string]map[string]*latencyWindow // provider -> model -> window
+}
+
+// latencyWindow is a fixed-size ring buffer of latency samples
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+// NewLatencyTracker creates a latency tracker keeping size samples per provider/model
+func NewLatencyTracker(size int) *LatencyTracker {
+	if size <= 0 {
+		size = defaultLatencyWindow
+	}
+	return &LatencyTracker{
+		size:    size,
+		windows: make(map[string]map[string]*latencyWindow),
+	}
+}
+
+// Record adds a latency sample for a provider/model
+func (lt *LatencyTracker) Record(provider, model string, d time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if lt.windows[provider] == nil {
+		lt.windows[provider] = make(map[string]*latencyWindow)
+	}
+
+	w, exists := lt.windows[provider][model]
+	if !exists {
+		w = &latencyWindow{samples: make([]time.Duration, 0, lt.size)}
+		lt.windows[provider][model] = w
+	}
+
+	if len(w.samples) < lt.size {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % lt.size
+}
+
+// ModelStats returns latency statistics for every model of a provider
+func (lt *LatencyTracker) ModelStats(provider string) map[string]*LatencyStats {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	stats := make(map[string]*LatencyStats)
+	for model, w := range lt.windows[provider] {
+		stats[model] = computeLatencyStats(w.samples)
+	}
+	return stats
+}
+
+// ProviderStats returns latency statistics across all models of a provider.
+// The second return value is false if no samples have been recorded.
+func (lt *LatencyTracker) ProviderStats(provider string) (*LatencyStats, bool) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	var all []time.Duration
+	for _, w := range lt.windows[provider] {
+		all = append(all, w.samples...)
+	}
+	if len(all) == 0 {
+		return nil, false
+	}
+	return computeLatencyStats(all), true
+}
+
+// Fastest returns the candidate provider with the lowest p50 latency.
+// Providers without samples are preferred so that they get measured,
+// and ties keep the order of candidates.
+func (lt *LatencyTracker) Fastest(candidates []string) string {
+	best := ""
+	var bestP50 time.Duration
+
+	for _, provider := range candidates {
+		stats, ok := lt.ProviderStats(provider)
+		if !ok {
+			return provider
+		}
+		if best == "" || stats.P50 < bestP50 {
+			best = provider
+			bestP50 = stats.P50
+		}
+	}
+
+	return best
+}
+
+// computeLatencyStats calculates percentiles over a set of samples
+func computeLatencyStats(samples []time.Duration) *LatencyStats {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &LatencyStats{
+		Samples: len(sorted),
+		P50:     percentile(sorted, 0.50),
+		P95:     percentile(sorted, 0.95),
+	}
+}
+
+// percentile returns the p-th percentile of sorted samples using nearest-rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// ConfigUpdater applies a new configuration, e.g. BaseProvider.UpdateConfig
+type ConfigUpdater interface {
+	UpdateConfig(ctx context.Context, cfg *config.Config) error
+}
+
+// KeyManager adds, removes, disables, enables and replaces keys at runtime.
+// Changes take effect in key selection immediately and can be persisted with Save.
+type KeyManager struct {
+	mu      sync.Mutex
+	rotator *KeyRotator
+	updater ConfigUpdater
+	edits   []keyEdit // changes not yet saved, in order
+}
+
+// keyEdit is an unsaved change to one field of a key entry, or to the whole
+// entry if field is empty
+type keyEdit struct {
+	provider, keyName, field string
+}
+
+// NewKeyManager creates a key manager for the keys of a rotator. Changes are
+// applied with updater, which should be the provider using the rotator so its
+// configuration changes too; with a nil updater only the rotator is updated.
+func NewKeyManager(rotator *KeyRotator, updater ConfigUpdater) *KeyManager {
+	if updater == nil {
+		updater = rotator
+	}
+	return &KeyManager{rotator: rotator, updater: updater}
+}
+
+// AddKey adds a key to a provider
+func (m *KeyManager) AddKey(ctx context.Context, provider string, key config.APIKey) error {
+	return m.update(ctx, provider, func(p *config.ProviderConfig) error {
+		for _, existing := range p.APIKeys {
+			if existing.Name == key.Name {
+				return fmt.Errorf("key %s already exists for provider %s", key.Name, provider)
+			}
+		}
+		p.APIKeys = append(p.APIKeys, key)
+		return nil
+	}, keyEdit{provider: provider, keyName: key.Name})
+}
+
+// RemoveKey removes a key from a provider
+func (m *KeyManager) RemoveKey(ctx context.Context, provider, keyName string) error {
+	return m.update(ctx, provider, func(p *config.ProviderConfig) error {
+		for i := range p.APIKeys {
+			if p.APIKeys[i].Name == keyName {
+				p.APIKeys = append(p.APIKeys[:i], p.APIKeys[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("key %s not found for provider %s", keyName, provider)
+	}, keyEdit{provider: provider, keyName: keyName})
+}
+
+// DisableKey stops a key from being selected without removing it
+func (m *KeyManager) DisableKey(ctx context.Context, provider, keyName string) error {
+	return m.updateKey(ctx, provider, keyName, "enabled", func(k *config.APIKey) {
+		k.Enabled = false
+	})
+}
+
+// EnableKey makes a disabled key selectable again
+func (m *KeyManager) EnableKey(ctx context.Context, provider, keyName string) error {
+	return m.updateKey(ctx, provider, keyName, "enabled", func(k *config.APIKey) {
+		k.Enabled = true
+	})
+}
+
+// ReplaceKey replaces the value of a key, e.g. after the provider rotated it
+func (m *KeyManager) ReplaceKey(ctx context.Context, provider, keyName, value string) error {
+	return m.updateKey(ctx, provider, keyName, "key", func(k *config.APIKey) {
+		k.Key = value
+	})
+}
+
+// Save writes the changes made since the last Save to the config file at
+// configPath. Only the changed key entries are rewritten, as the file has
+// them: placeholders and environment overrides of other values stay out of
+// it. Added and replaced key values are written as given, so prefer key store
+// references to plaintext keys.
+func (m *KeyManager) Save(configPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.edits) == 0 {
+		return nil
+	}
+
+	file, err := config.OpenConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	m.rotator.mu.RLock()
+	cfg := m.rotator.config
+	m.rotator.mu.RUnlock()
+
+	for _, edit := range m.edits {
+		key, exists := findKey(cfg, edit.provider, edit.keyName)
+		switch {
+		case !exists:
+			file.RemoveKey(edit.provider, edit.keyName)
+		case edit.field == "":
+			if err := file.AddKey(edit.provider, key); err != nil {
+				return err
+			}
+		default:
+			var value interface{} = key.Enabled
+			if edit.field == "key" {
+				value = key.Key
+			}
+			if err := file.SetKeyField(edit.provider, edit.keyName, edit.field, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := file.Save(); err != nil {
+		return err
+	}
+	m.edits = nil
+	return nil
+}
+
+// findKey returns the named key of a provider
+func findKey(cfg *config.Config, provider, keyName string) (config.APIKey, bool) {
+	for _, key := range cfg.Providers[provider].APIKeys {
+		if key.Name == keyName {
+			return key, true
+		}
+	}
+	return config.APIKey{}, false
+}
+
+// updateKey applies fn, which changes field, to the named key of a provider
+func (m *KeyManager) updateKey(ctx context.Context, provider, keyName, field string, fn func(k *config.APIKey)) error {
+	return m.update(ctx, provider, func(p *config.ProviderConfig) error {
+		for i := range p.APIKeys {
+			if p.APIKeys[i].Name == keyName {
+				fn(&p.APIKeys[i])
+				return nil
+			}
+		}
+		return fmt.Errorf("key %s not found for provider %s", keyName, provider)
+	}, keyEdit{provider: provider, keyName: keyName, field: field})
+}
+
+// update applies fn to a copy of the provider configuration, validates the
+// result, applies it with the updater and records edit for Save
+func (m *KeyManager) update(ctx context.Context, provider string, fn func(p *config.ProviderConfig) error, edit keyEdit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rotator.mu.RLock()
+	cfg := m.rotator.config.Clone()
+	m.rotator.mu.RUnlock()
+
+	providerCfg, exists := cfg.Providers[provider]
+	if !exists {
+		return fmt.Errorf("provider %s not found", provider)
+	}
+	if err := fn(&providerCfg); err != nil {
+		return err
+	}
+	cfg.Providers[provider] = providerCfg
+
+	if err := config.Validate(cfg); err != nil {
+		return fmt.Errorf("key change rejected: %w", err)
+	}
+	if err := m.updater.UpdateConfig(ctx, cfg); err != nil {
+		return err
+	}
+	for _, recorded := range m.edits {
+		if recorded == edit {
+			return nil
+		}
+	}
+	m.edits = append(m.edits, edit)
+	return nil
+}
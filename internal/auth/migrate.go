@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// MigrationResult describes the outcome of a plaintext key migration
+type MigrationResult struct {
+	Migrated []string `json:"migrated"` // provider/keyName of keys moved into the key store
+	Skipped  []string `json:"skipped"`  // provider/keyName of keys that were already references or aren't in plaintext
+}
+
+// MigratePlaintextKeys stores every inline plaintext key of cfg in store,
+// verifies that each key reads back unchanged and then replaces the inline
+// values in cfg with key store references. cfg is left untouched on error.
+func MigratePlaintextKeys(ctx context.Context, cfg *config.Config, store KeyStore) (*MigrationResult, error) {
+	result := &MigrationResult{}
+
+	// Store and verify everything before rewriting the configuration
+	for providerName, provider := range cfg.Providers {
+		for _, apiKey := range provider.APIKeys {
+			id := providerName + "/" + apiKey.Name
+			if apiKey.IsKeyRef() {
+				result.Skipped = append(result.Skipped, id)
+				continue
+			}
+
+			if err := storeVerified(ctx, store, providerName, apiKey.Name, apiKey.Key); err != nil {
+				return nil, err
+			}
+
+			result.Migrated = append(result.Migrated, id)
+		}
+	}
+
+	for providerName, provider := range cfg.Providers {
+		for i := range provider.APIKeys {
+			if !provider.APIKeys[i].IsKeyRef() {
+				provider.APIKeys[i].Key = config.KeyRefPrefix + provider.APIKeys[i].Name
+			}
+		}
+		cfg.Providers[providerName] = provider
+	}
+
+	return result, nil
+}
+
+// MigrateConfigFile migrates the plaintext keys of the config file at
+// configPath into store and rewrites the file to reference key names only.
+// The file is edited as written: only the values of migrated keys change, and
+// keys set by ${VAR} placeholders are skipped, as their values aren't in the file.
+func MigrateConfigFile(ctx context.Context, configPath string, store KeyStore) (*MigrationResult, error) {
+	file, err := config.OpenConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{}
+	var migrated []config.RawKey
+	for _, rawKey := range file.Keys() {
+		id := rawKey.Provider + "/" + rawKey.Name
+		if rawKey.Key == "" || strings.HasPrefix(rawKey.Key, config.KeyRefPrefix) || config.HasPlaceholder(rawKey.Key) {
+			result.Skipped = append(result.Skipped, id)
+			continue
+		}
+		if err := storeVerified(ctx, store, rawKey.Provider, rawKey.Name, rawKey.Key); err != nil {
+			return nil, err
+		}
+		migrated = append(migrated, rawKey)
+		result.Migrated = append(result.Migrated, id)
+	}
+	if len(migrated) == 0 {
+		return result, nil
+	}
+
+	for _, rawKey := range migrated {
+		if err := file.SetKeyField(rawKey.Provider, rawKey.Name, "key", config.KeyRefPrefix+rawKey.Name); err != nil {
+			return nil, err
+		}
+	}
+	if err := file.Save(); err != nil {
+		return nil, fmt.Errorf("keys migrated but failed to rewrite config: %w", err)
+	}
+	return result, nil
+}
+
+// storeVerified stores a key and checks that it reads back unchanged
+func storeVerified(ctx context.Context, store KeyStore, provider, keyName, key string) error {
+	id := provider + "/" + keyName
+	if err := store.StoreKey(ctx, provider, keyName, key); err != nil {
+		return fmt.Errorf("failed to store key %s: %w", id, err)
+	}
+
+	stored, err := store.GetKey(ctx, provider, keyName)
+	if err != nil {
+		return fmt.Errorf("failed to read back key %s: %w", id, err)
+	}
+	if stored != key {
+		return fmt.Errorf("round-trip verification failed for key %s", id)
+	}
+	return nil
+}
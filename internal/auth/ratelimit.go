@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"time"
+)
+
+const (
+	// rateLimitRequestReserve is the remaining request count at or below which a key is avoided
+	rateLimitRequestReserve = 1
+
+	// rateLimitTokenReserve is the remaining token count below which a key is avoided
+	rateLimitTokenReserve = 1000
+
+	// defaultRateLimitReset is assumed when a provider doesn't report when a limit resets
+	defaultRateLimitReset = time.Minute
+)
+
+// RateLimitState is the rate limit headroom of a key as reported by the provider.
+// Negative remaining values mean the provider didn't report that limit.
+type RateLimitState struct {
+	RemainingRequests int       `json:"remaining_requests"`
+	RemainingTokens   int       `json:"remaining_tokens"`
+	ResetRequests     time.Time `json:"reset_requests,omitempty"`
+	ResetTokens       time.Time `json:"reset_tokens,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// NearLimit reports whether the key is about to be throttled at the given time
+func (s *RateLimitState) NearLimit(now time.Time) bool {
+	if s.RemainingRequests >= 0 && s.RemainingRequests <= rateLimitRequestReserve &&
+		now.Before(s.resetAt(s.ResetRequests)) {
+		return true
+	}
+	if s.RemainingTokens >= 0 && s.RemainingTokens < rateLimitTokenReserve &&
+		now.Before(s.resetAt(s.ResetTokens)) {
+		return true
+	}
+	return false
+}
+
+// resetAt returns the reset time, falling back to a default window after the last update
+func (s *RateLimitState) resetAt(reset time.Time) time.Time {
+	if reset.IsZero() {
+		return s.UpdatedAt.Add(defaultRateLimitReset)
+	}
+	return reset
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+)
+
+// defaultEncryptionKey encrypts the keys of the built-in key stores unless a
+// passphrase is configured
+const defaultEncryptionKey = "default-encryption-key-change-in-production"
+
+// DefaultEncryptionKeyEnv is the environment variable read for the encryption
+// passphrase if the configuration names no source
+const DefaultEncryptionKeyEnv = "GOLLMKIT_ENCRYPTION_KEY"
+
+// EncryptionKeyFromConfig returns the passphrase encrypting stored keys: the
+// contents of global.key_store.encryption_key_file, the environment variable
+// named by encryption_key_env or GOLLMKIT_ENCRYPTION_KEY, in that order, and
+// the built-in default otherwise. A configured source that is empty is an error.
+func EncryptionKeyFromConfig(cfg *config.Config) (string, error) {
+	keyStore := cfg.Global.KeyStore
+	switch {
+	case keyStore.EncryptionKeyFile != "":
+		raw, err := os.ReadFile(keyStore.EncryptionKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read encryption key: %w", err)
+		}
+		key := strings.TrimRight(string(raw), "\r\n")
+		if key == "" {
+			return "", fmt.Errorf("encryption key file %s is empty", keyStore.EncryptionKeyFile)
+		}
+		return key, nil
+	case keyStore.EncryptionKeyEnv != "":
+		key := os.Getenv(keyStore.EncryptionKeyEnv)
+		if key == "" {
+			return "", fmt.Errorf("encryption key environment variable %s is not set", keyStore.EncryptionKeyEnv)
+		}
+		return key, nil
+	}
+	if key := os.Getenv(DefaultEncryptionKeyEnv); key != "" {
+		return key, nil
+	}
+	return defaultEncryptionKey, nil
+}
+
+// KeyStoreFactory creates an empty key store from configuration. The store is
+// then populated with the keys of the configuration by NewKeyStoreFromConfig.
+type KeyStoreFactory func(cfg *config.Config) (KeyStore, error)
+
+var (
+	keyStoresMu sync.RWMutex
+	keyStores   = map[string]KeyStoreFactory{
+		config.KeyStoreMemory:   newMemoryKeyStoreFromConfig,
+		config.KeyStoreFile:     newFileKeyStoreFromConfig,
+		config.KeyStoreKeychain: newKeychainKeyStoreFromConfig,
+	}
+)
+
+// RegisterKeyStore registers a key store type selectable with
+// global.key_store.type, e.g. RegisterKeyStore("dynamo", newDynamoKeyStore).
+// Registering an existing type replaces it. Backend-specific settings are
+// available to the factory in global.key_store.options.
+func RegisterKeyStore(name string, factory KeyStoreFactory) {
+	keyStoresMu.Lock()
+	defer keyStoresMu.Unlock()
+	keyStores[name] = factory
+}
+
+// UnregisterKeyStore removes a previously registered key store type
+func UnregisterKeyStore(name string) {
+	keyStoresMu.Lock()
+	defer keyStoresMu.Unlock()
+	delete(keyStores, name)
+}
+
+// KeyStoreTypes returns the registered key store types, sorted
+func KeyStoreTypes() []string {
+	keyStoresMu.RLock()
+	defer keyStoresMu.RUnlock()
+
+	names := make([]string, 0, len(keyStores))
+	for name := range keyStores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keyStoreFactory returns the factory of a key store type, memory if empty
+func keyStoreFactory(name string) (KeyStoreFactory, error) {
+	if name == "" {
+		name = config.KeyStoreMemory
+	}
+
+	keyStoresMu.RLock()
+	factory, exists := keyStores[name]
+	keyStoresMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown key store type %q, registered: %s",
+			name, strings.Join(KeyStoreTypes(), ", "))
+	}
+	return factory, nil
+}
+
+// newMemoryKeyStoreFromConfig creates the memory key store, encrypted if
+// global.encrypt_keys is set
+func newMemoryKeyStoreFromConfig(cfg *config.Config) (KeyStore, error) {
+	var encryptionKey string
+	if cfg.Global.EncryptKeys {
+		var err error
+		if encryptionKey, err = EncryptionKeyFromConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return NewMemoryKeyStore(encryptionKey), nil
+}
+
+// newFileKeyStoreFromConfig creates the file key store. Keys on disk are
+// always encrypted, with envelope encryption if a master key is configured.
+func newFileKeyStoreFromConfig(cfg *config.Config) (KeyStore, error) {
+	flushInterval, err := cfg.Global.KeyStore.GetFlushInterval()
+	if err != nil {
+		return nil, fmt.Errorf("invalid key store flush interval: %w", err)
+	}
+	if cfg.Global.KeyStore.MasterKey.Type == "" {
+		encryptionKey, err := EncryptionKeyFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileKeyStore(cfg.Global.KeyStore.Path, encryptionKey, flushInterval)
+	}
+
+	master, err := NewMasterKeyFromConfig(cfg.Global.KeyStore.MasterKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileKeyStoreWithMasterKey(context.Background(), cfg.Global.KeyStore.Path, master, flushInterval)
+}
+
+// warnDefaultKey warns if the file key store is encrypted with the built-in
+// default key
+func warnDefaultKey(cfg *config.Config, logger logging.Logger) {
+	if cfg.Global.KeyStore.Type != config.KeyStoreFile || cfg.Global.KeyStore.MasterKey.Type != "" {
+		return
+	}
+	if encryptionKey, err := EncryptionKeyFromConfig(cfg); err != nil || encryptionKey != defaultEncryptionKey {
+		return
+	}
+	logging.OrDefault(logger).Warn("gollmkit: key store is encrypted with the built-in default key, set the passphrase environment variable or global.key_store.encryption_key_env",
+		"path", cfg.Global.KeyStore.Path, "env", DefaultEncryptionKeyEnv)
+}
+
+// newKeychainKeyStoreFromConfig creates the OS keychain key store. Keys referenced by
+// the configuration are loaded right away, so a missing key fails at startup.
+func newKeychainKeyStoreFromConfig(cfg *config.Config) (KeyStore, error) {
+	store := NewKeychainKeyStore(SystemKeychain(), cfg.Global.KeyStore.Options["service"])
+	ctx := context.Background()
+	for providerName, provider := range cfg.Providers {
+		for _, apiKey := range provider.APIKeys {
+			if !apiKey.IsKeyRef() {
+				continue
+			}
+			if err := store.Load(ctx, providerName, apiKey.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return store, nil
+}
@@ -2,150 +2,613 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"math/rand/v2"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+	"github.com/gollmkit/gollmkit/internal/redact"
 )
 
+// ErrBudgetExceeded is returned when no key can be used without exceeding its cost limit
+var ErrBudgetExceeded = errors.New("cost limit exceeded")
+
+// ErrModelNotAllowed is returned when the allowed_models of every key exclude the requested model
+var ErrModelNotAllowed = errors.New("model not allowed for any key")
+
+// ErrNoTaggedKey is returned when no key carries the tags a request is restricted to
+var ErrNoTaggedKey = errors.New("no key matches the tags")
+
 // KeyRotator manages API key rotation strategies
 type KeyRotator struct {
-	mu          sync.RWMutex
-	config      *config.Config
-	keyStore    KeyStore
-	lastUsed    map[string]map[string]time.Time // provider -> keyName -> lastUsed
-	rotationIdx map[string]int                  // provider -> current rotation index
-	rand        *rand.Rand
+	mu         sync.RWMutex
+	config     *config.Config
+	keyStore   KeyStore
+	lastUsed   map[string]map[string]time.Time // provider -> keyName -> lastUsed
+	picks      map[string]map[string]uint64    // provider -> keyName -> sequence number of its last round-robin pick
+	pickSeq    uint64                          // sequence number of the latest round-robin pick
+	activeKey  map[string]string               // provider -> active key of the single strategy, the first key if unset
+	latency    *LatencyTracker
+	streams    *StreamRateTracker
+	heatmap    *HeatmapTracker
+	rateLimits map[string]map[string]*RateLimitState // provider -> keyName -> rate limit state
+	rotatedAt  map[string]time.Time                  // provider -> last scheduled rotation
+	inFlight   *inFlightTracker
+	usage      *usageQueue // usage waiting to be written to keyStore
+	logger     logging.Logger
 }
 
-// NewKeyRotator creates a new key rotator
+// NewKeyRotator creates a new key rotator. The usage queue settings of
+// global.key_store are read once, later configuration changes keep them.
 func NewKeyRotator(cfg *config.Config, keyStore KeyStore) *KeyRotator {
-	return &KeyRotator{
-		config:      cfg,
-		keyStore:    keyStore,
-		lastUsed:    make(map[string]map[string]time.Time),
-		rotationIdx: make(map[string]int),
-		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	kr := &KeyRotator{
+		config:     cfg,
+		keyStore:   keyStore,
+		lastUsed:   make(map[string]map[string]time.Time),
+		picks:      make(map[string]map[string]uint64),
+		activeKey:  make(map[string]string),
+		latency:    NewLatencyTracker(defaultLatencyWindow),
+		streams:    NewStreamRateTracker(defaultLatencyWindow),
+		heatmap:    NewHeatmapTracker(),
+		rateLimits: make(map[string]map[string]*RateLimitState),
+		rotatedAt:  make(map[string]time.Time),
+		inFlight:   newInFlightTracker(),
 	}
+	kr.usage = newUsageQueue(cfg, keyStore, kr.log)
+	return kr
 }
 
-// KeySelection represents a selected API key with metadata
-type KeySelection struct {
-	Provider   string
-	KeyName    string
-	Key        string
-	RateLimit  int
-	CostLimit  float64
-	UsageCount int64
-	LastUsed   time.Time
-	Strategy   config.RotationStrategy
-}
-
-// GetNextKey returns the next API key based on rotation strategy
-func (kr *KeyRotator) GetNextKey(ctx context.Context, provider string) (*KeySelection, error) {
+// SetLogger sets the logger receiving key selection and health records,
+// slog.Default() if unset
+func (kr *KeyRotator) SetLogger(logger logging.Logger) {
+	kr.logger = logger
+}
+
+// log returns the logger of the rotator
+func (kr *KeyRotator) log() logging.Logger {
+	return logging.OrDefault(kr.logger)
+}
+
+// UpdateConfig swaps in a new configuration. Keys that are new or whose value
+// changed are stored in the key store before they become selectable.
+func (kr *KeyRotator) UpdateConfig(ctx context.Context, cfg *config.Config) error {
+	for providerName, provider := range cfg.Providers {
+		deletedNames, err := kr.keyStore.ListDeletedKeys(ctx, providerName)
+		if err != nil {
+			return fmt.Errorf("failed to list deleted keys for provider %s: %w", providerName, err)
+		}
+		deleted := make(map[string]bool, len(deletedNames))
+		for _, name := range deletedNames {
+			deleted[name] = true
+		}
+
+		for _, apiKey := range provider.APIKeys {
+			// Deleted keys must be restored explicitly, not resurrected by a reload
+			if apiKey.IsKeyRef() || deleted[apiKey.Name] {
+				continue
+			}
+			existing, err := kr.keyStore.GetKey(ctx, providerName, apiKey.Name)
+			if err == nil && existing == apiKey.Key {
+				continue
+			}
+			if err := kr.keyStore.StoreKey(ctx, providerName, apiKey.Name, apiKey.Key); err != nil {
+				return fmt.Errorf("failed to store key %s for provider %s: %w", apiKey.Name, providerName, err)
+			}
+		}
+	}
+
 	kr.mu.Lock()
 	defer kr.mu.Unlock()
 
+	kr.config = cfg
+	// Rotation state is keyed by key name and survives the reload; only removed keys are forgotten
+	for provider, picks := range kr.picks {
+		configured := make(map[string]bool)
+		for _, key := range cfg.Providers[provider].APIKeys {
+			configured[key.Name] = true
+		}
+		for keyName := range picks {
+			if !configured[keyName] {
+				delete(picks, keyName)
+			}
+		}
+	}
+	return nil
+}
+
+// KeySelection represents a selected API key with metadata
+type KeySelection struct {
+	Provider    string
+	KeyName     string
+	Key         string
+	RateLimit   int
+	CostLimit   float64
+	UsageCount  int64
+	LastUsed    time.Time
+	Strategy    config.RotationStrategy
+	MaxInFlight int
+
+	release func() // returns the in-flight slot, nil if the key has no limit
+}
+
+// SelectOption restricts which keys GetNextKey may select
+type SelectOption func(*selectOptions)
+
+// selectOptions holds the restrictions applied by SelectOptions
+type selectOptions struct {
+	exclude map[string]bool
+	only    []map[string]bool
+	models  []string
+	tags    []map[string]string
+}
+
+// ExcludeKeys prevents the named keys from being selected, e.g. keys that
+// already failed for the current request
+func ExcludeKeys(keyNames ...string) SelectOption {
+	return func(o *selectOptions) {
+		if o.exclude == nil {
+			o.exclude = make(map[string]bool)
+		}
+		for _, name := range keyNames {
+			o.exclude[name] = true
+		}
+	}
+}
+
+// OnlyKeys restricts selection to the named keys, e.g. the key that owns a
+// file uploaded to the provider. Given more than once, keys must be named by
+// every set.
+func OnlyKeys(keyNames ...string) SelectOption {
+	return func(o *selectOptions) {
+		names := make(map[string]bool, len(keyNames))
+		for _, name := range keyNames {
+			names[name] = true
+		}
+		o.only = append(o.only, names)
+	}
+}
+
+// ForModel restricts selection to keys whose allowed_models include model.
+// Given more than once, keys must allow every model.
+func ForModel(model string) SelectOption {
+	return func(o *selectOptions) {
+		o.models = append(o.models, model)
+	}
+}
+
+// WithTags restricts selection to keys carrying every tag of tags with the
+// same value. Given more than once, keys must match every tag set.
+func WithTags(tags map[string]string) SelectOption {
+	return func(o *selectOptions) {
+		if len(tags) > 0 {
+			o.tags = append(o.tags, tags)
+		}
+	}
+}
+
+// GetNextKey returns the next API key based on rotation strategy. Keys at
+// their max_in_flight limit are skipped; if every key is, GetNextKey waits up
+// to rotation.queue_timeout for a slot and fails with ErrKeysSaturated
+// otherwise. Callers must call Release on the selection once the request is done.
+func (kr *KeyRotator) GetNextKey(ctx context.Context, provider string, opts ...SelectOption) (*KeySelection, error) {
+	var selectOpts selectOptions
+	for _, opt := range opts {
+		opt(&selectOpts)
+	}
+
+	var deadline <-chan time.Time
+	for {
+		selection, freed, err := kr.nextKey(ctx, provider, selectOpts)
+		if errors.Is(err, errKeyTaken) {
+			continue
+		}
+		if !errors.Is(err, ErrKeysSaturated) {
+			return selection, err
+		}
+
+		kr.mu.RLock()
+		queueTimeout := kr.queueTimeout(provider)
+		kr.mu.RUnlock()
+		if queueTimeout <= 0 {
+			return nil, err
+		}
+		if deadline == nil {
+			timer := time.NewTimer(queueTimeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		select {
+		case <-freed:
+		case <-deadline:
+			return nil, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// errKeyTaken is returned by nextKey when the key chosen from key store data
+// became unavailable before its slot was taken; GetNextKey selects again
+var errKeyTaken = errors.New("selected key became unavailable")
+
+// queueTimeout returns how long GetNextKey waits for a free key of a provider
+func (kr *KeyRotator) queueTimeout(provider string) time.Duration {
 	providerConfig, err := kr.config.GetProvider(provider)
 	if err != nil {
-		return nil, fmt.Errorf("provider not found: %w", err)
+		return 0
+	}
+	timeout, err := providerConfig.Rotation.GetQueueTimeout()
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+// nextKey selects the next key of a provider and takes its in-flight slot.
+// kr.mu is held only while rotation state is read or updated, never across
+// key store calls, which may decrypt keys or reach a keychain or KMS. If
+// every key is saturated, freed is closed once a slot is released.
+func (kr *KeyRotator) nextKey(ctx context.Context, provider string, selectOpts selectOptions) (selection *KeySelection, freed <-chan struct{}, err error) {
+	kr.mu.RLock()
+	cfg := kr.config
+	kr.mu.RUnlock()
+
+	providerConfig, err := cfg.GetProvider(provider)
+	if err != nil {
+		return nil, nil, fmt.Errorf("provider not found: %w", err)
 	}
 
 	enabledKeys := providerConfig.GetEnabledKeys()
 	if len(enabledKeys) == 0 {
-		return nil, fmt.Errorf("no enabled keys available for provider %s", provider)
+		return nil, nil, fmt.Errorf("no enabled keys available for provider %s", provider)
 	}
 
-	var selectedKey *config.APIKey
-	var keyName string
+	// Soft-deleted keys stay in the config but must never be selected
+	enabledKeys, err = kr.filterDeleted(ctx, provider, enabledKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(enabledKeys) == 0 {
+		return nil, nil, fmt.Errorf("no enabled keys available for provider %s", provider)
+	}
 
-	switch providerConfig.Rotation.Strategy {
-	case config.RotationRoundRobin:
-		selectedKey, keyName = kr.selectRoundRobin(provider, enabledKeys)
-	case config.RotationLeastUsed:
-		selectedKey, keyName, err = kr.selectLeastUsed(ctx, provider, enabledKeys)
-	case config.RotationCostOptimized:
-		selectedKey, keyName, err = kr.selectCostOptimized(ctx, provider, enabledKeys)
-	case config.RotationRandom:
-		selectedKey, keyName = kr.selectRandom(enabledKeys)
-	case config.RotationSingle:
-		selectedKey, keyName = kr.selectSingle(enabledKeys)
-	default:
-		selectedKey, keyName = kr.selectRoundRobin(provider, enabledKeys)
+	for _, only := range selectOpts.only {
+		var named []config.APIKey
+		for _, key := range enabledKeys {
+			if only[key.Name] {
+				named = append(named, key)
+			}
+		}
+		if len(named) == 0 {
+			return nil, nil, fmt.Errorf("no enabled key named %s for provider %s", strings.Join(sortedNames(only), " or "), provider)
+		}
+		enabledKeys = named
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("key selection failed: %w", err)
+	if len(selectOpts.models) > 0 {
+		var allowed []config.APIKey
+		for _, key := range enabledKeys {
+			if allowsModels(key, selectOpts.models) {
+				allowed = append(allowed, key)
+			}
+		}
+		if len(allowed) == 0 {
+			return nil, nil, fmt.Errorf("%w: provider %s, model %s", ErrModelNotAllowed, provider, strings.Join(selectOpts.models, ", "))
+		}
+		enabledKeys = allowed
+	}
+
+	if len(selectOpts.tags) > 0 {
+		var tagged []config.APIKey
+		for _, key := range enabledKeys {
+			if hasTagSets(key, selectOpts.tags) {
+				tagged = append(tagged, key)
+			}
+		}
+		if len(tagged) == 0 {
+			return nil, nil, fmt.Errorf("%w: provider %s, tags %s", ErrNoTaggedKey, provider, formatTagSets(selectOpts.tags))
+		}
+		enabledKeys = tagged
+	}
+
+	if len(selectOpts.exclude) > 0 {
+		var remaining []config.APIKey
+		for _, key := range enabledKeys {
+			if !selectOpts.exclude[key.Name] {
+				remaining = append(remaining, key)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil, nil, fmt.Errorf("no keys left for provider %s after excluding %d failed keys", provider, len(selectOpts.exclude))
+		}
+		enabledKeys = remaining
+	}
+
+	// Strategies ranking keys by usage or health read it before the lock is taken
+	strategy := providerConfig.Rotation.Strategy
+	var chosen []config.APIKey
+	if usesKeyStore(strategy) {
+		kr.mu.RLock()
+		candidates := kr.filterAvailable(provider, enabledKeys)
+		freed := kr.inFlight.freed
+		kr.mu.RUnlock()
+		if len(candidates) == 0 {
+			return nil, freed, fmt.Errorf("%w for provider %s", ErrKeysSaturated, provider)
+		}
+
+		if chosen, err = kr.rankKeys(ctx, strategy, provider, candidates); err != nil {
+			return nil, nil, fmt.Errorf("key selection failed: %w", err)
+		}
+		if len(chosen) == 0 {
+			return nil, nil, fmt.Errorf("no suitable key found for provider %s", provider)
+		}
+	}
+
+	kr.mu.Lock()
+	candidates := kr.filterAvailable(provider, enabledKeys)
+	if len(candidates) == 0 {
+		freed := kr.inFlight.freed
+		kr.mu.Unlock()
+		return nil, freed, fmt.Errorf("%w for provider %s", ErrKeysSaturated, provider)
 	}
 
+	var selectedKey *config.APIKey
+	switch {
+	case chosen != nil:
+		// Keys chosen from key store data must still be available
+		keys := intersectKeys(chosen, candidates)
+		switch {
+		case len(keys) == 0:
+		case strategy == config.RotationPriority:
+			selectedKey, _ = kr.selectRoundRobin(provider, keys)
+		default:
+			selectedKey = &keys[0]
+		}
+	case strategy == config.RotationRandom:
+		selectedKey, _ = kr.selectRandom(candidates)
+	case strategy == config.RotationSingle:
+		selectedKey, _ = kr.selectSingle(provider, candidates)
+	case strategy == config.RotationWeighted:
+		selectedKey, _ = kr.selectWeighted(candidates)
+	default:
+		selectedKey, _ = kr.selectRoundRobin(provider, candidates)
+	}
 	if selectedKey == nil {
-		return nil, fmt.Errorf("no suitable key found for provider %s", provider)
+		kr.mu.Unlock()
+		return nil, nil, errKeyTaken
 	}
+	keyName := selectedKey.Name
+	kr.updateLastUsed(provider, keyName)
+	selection = newKeySelection(provider, selectedKey, strategy)
+	kr.acquireSlot(selection)
+	kr.mu.Unlock()
 
 	// Health check if enabled
 	if providerConfig.Rotation.HealthCheck {
 		healthy, err := kr.keyStore.IsHealthy(ctx, provider, keyName)
 		if err != nil {
-			return nil, fmt.Errorf("health check failed: %w", err)
+			selection.Release()
+			return nil, nil, fmt.Errorf("health check failed: %w", err)
 		}
 		if !healthy {
+			selection.Release()
 			// Try fallback if enabled
 			if providerConfig.Rotation.FallbackEnabled && len(enabledKeys) > 1 {
-				return kr.getFallbackKey(ctx, provider, keyName, enabledKeys)
+				kr.log().Warn("gollmkit: selected key is unhealthy, using a fallback key", "provider", provider, "key", keyName)
+				selection, err := kr.getFallbackKey(ctx, provider, keyName, enabledKeys)
+				return selection, nil, err
 			}
-			return nil, fmt.Errorf("selected key %s is unhealthy and no fallback available", keyName)
+			return nil, nil, fmt.Errorf("selected key %s is unhealthy and no fallback available", keyName)
 		}
 	}
 
-	// Get the actual key value
-	keyValue, err := kr.keyStore.GetKey(ctx, provider, keyName)
+	if err := kr.loadKey(ctx, selection); err != nil {
+		selection.Release()
+		return nil, nil, err
+	}
+	kr.log().Debug("gollmkit: key selected", "provider", provider, "key", keyName, "strategy", strategy)
+	return selection, nil, nil
+}
+
+// newKeySelection returns the selection of a key, without its value and usage
+func newKeySelection(provider string, key *config.APIKey, strategy config.RotationStrategy) *KeySelection {
+	return &KeySelection{
+		Provider:    provider,
+		KeyName:     key.Name,
+		RateLimit:   key.RateLimit,
+		CostLimit:   key.CostLimit,
+		Strategy:    strategy,
+		MaxInFlight: key.MaxInFlight,
+	}
+}
+
+// loadKey reads the value and usage statistics of a selected key from the key store
+func (kr *KeyRotator) loadKey(ctx context.Context, selection *KeySelection) error {
+	keyValue, err := kr.keyStore.GetKey(ctx, selection.Provider, selection.KeyName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve key: %w", err)
+		return fmt.Errorf("failed to retrieve key: %w", err)
+	}
+	selection.Key = keyValue
+
+	// Keys without usage statistics count as unused
+	selection.LastUsed = time.Now()
+	if usage, err := kr.keyStore.GetUsage(ctx, selection.Provider, selection.KeyName); err == nil {
+		selection.UsageCount = usage.UsageCount
+		selection.LastUsed = usage.LastUsed
+	}
+	return nil
+}
+
+// filterAvailable removes keys at their in-flight limit and, unless every
+// remaining key is, keys near their rate limit. The caller must hold kr.mu.
+func (kr *KeyRotator) filterAvailable(provider string, keys []config.APIKey) []config.APIKey {
+	available := kr.filterSaturated(provider, keys)
+	if len(available) == 0 {
+		return nil
+	}
+	// Proactively avoid keys that are about to be throttled
+	return kr.filterRateLimited(provider, available)
+}
+
+// usesKeyStore reports whether a strategy ranks keys by key store data
+func usesKeyStore(strategy config.RotationStrategy) bool {
+	switch strategy {
+	case config.RotationLeastUsed, config.RotationCostOptimized, config.RotationPriority:
+		return true
+	}
+	_, custom := customStrategy(strategy)
+	return custom
+}
+
+// rankKeys returns the keys a strategy using key store data chose from keys:
+// the selected key, or the tier of the priority strategy to rotate through
+func (kr *KeyRotator) rankKeys(ctx context.Context, strategy config.RotationStrategy, provider string, keys []config.APIKey) ([]config.APIKey, error) {
+	var selectedKey *config.APIKey
+	var err error
+	switch strategy {
+	case config.RotationLeastUsed:
+		selectedKey, _, err = kr.selectLeastUsed(ctx, provider, keys)
+	case config.RotationCostOptimized:
+		selectedKey, _, err = kr.selectCostOptimized(ctx, provider, keys)
+	case config.RotationPriority:
+		return kr.priorityTier(ctx, provider, keys)
+	default:
+		custom, _ := customStrategy(strategy)
+		selectedKey, _, err = kr.selectCustom(ctx, custom, provider, keys)
+	}
+	if err != nil || selectedKey == nil {
+		return nil, err
+	}
+	return []config.APIKey{*selectedKey}, nil
+}
+
+// intersectKeys returns the keys of keys that are also in available
+func intersectKeys(keys, available []config.APIKey) []config.APIKey {
+	names := make(map[string]bool, len(available))
+	for _, key := range available {
+		names[key.Name] = true
+	}
+	var kept []config.APIKey
+	for _, key := range keys {
+		if names[key.Name] {
+			kept = append(kept, key)
+		}
+	}
+	return kept
+}
+
+// allowsModels reports whether key may be used with every one of models
+func allowsModels(key config.APIKey, models []string) bool {
+	for _, model := range models {
+		if !key.AllowsModel(model) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTagSets reports whether key carries every tag of every one of tagSets
+func hasTagSets(key config.APIKey, tagSets []map[string]string) bool {
+	for _, tags := range tagSets {
+		if !key.HasTags(tags) {
+			return false
+		}
 	}
+	return true
+}
+
+// formatTagSets formats tag sets as sorted name=value pairs for error messages
+func formatTagSets(tagSets []map[string]string) string {
+	var pairs []string
+	for _, tags := range tagSets {
+		for name, value := range tags {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
 
-	// Get usage statistics
-	usage, err := kr.keyStore.GetUsage(ctx, provider, keyName)
+// sortedNames returns the names of a key set, sorted
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// GetKeyByName returns a specific key of a provider, bypassing rotation. It is
+// used for operations that must reuse an earlier key, such as polling a batch job.
+func (kr *KeyRotator) GetKeyByName(ctx context.Context, provider, keyName string) (*KeySelection, error) {
+	kr.mu.RLock()
+	providerConfig, err := kr.config.GetProvider(provider)
+	kr.mu.RUnlock()
 	if err != nil {
-		// If usage doesn't exist, create default
-		usage = &KeyUsage{
-			LastUsed:   time.Now(),
-			UsageCount: 0,
+		return nil, fmt.Errorf("provider not found: %w", err)
+	}
+
+	var apiKey *config.APIKey
+	for i := range providerConfig.APIKeys {
+		if providerConfig.APIKeys[i].Name == keyName {
+			apiKey = &providerConfig.APIKeys[i]
+			break
 		}
 	}
+	if apiKey == nil {
+		return nil, fmt.Errorf("key %s not found for provider %s", keyName, provider)
+	}
+	if apiKey.IsExpired(time.Now()) {
+		return nil, fmt.Errorf("key %s for provider %s has expired", keyName, provider)
+	}
 
-	// Update last used time
-	kr.updateLastUsed(provider, keyName)
+	keyValue, err := kr.keyStore.GetKey(ctx, provider, keyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve key: %w", err)
+	}
 
 	return &KeySelection{
-		Provider:   provider,
-		KeyName:    keyName,
-		Key:        keyValue,
-		RateLimit:  selectedKey.RateLimit,
-		CostLimit:  selectedKey.CostLimit,
-		UsageCount: usage.UsageCount,
-		LastUsed:   usage.LastUsed,
-		Strategy:   providerConfig.Rotation.Strategy,
+		Provider:  provider,
+		KeyName:   keyName,
+		Key:       keyValue,
+		RateLimit: apiKey.RateLimit,
+		CostLimit: apiKey.CostLimit,
+		Strategy:  providerConfig.Rotation.Strategy,
 	}, nil
 }
 
-// selectRoundRobin implements round-robin key selection
+// selectRoundRobin implements round-robin key selection by picking the key of
+// keys picked least recently, never-picked keys first in order. Picks are
+// tracked by key name, so rotation stays fair when callers filter keys out,
+// e.g. rate-limited keys or the failed key of a fallback.
 func (kr *KeyRotator) selectRoundRobin(provider string, keys []config.APIKey) (*config.APIKey, string) {
 	if len(keys) == 0 {
 		return nil, ""
 	}
 
-	// Initialize rotation index if not exists
-	if _, exists := kr.rotationIdx[provider]; !exists {
-		kr.rotationIdx[provider] = 0
+	picks, exists := kr.picks[provider]
+	if !exists {
+		picks = make(map[string]uint64)
+		kr.picks[provider] = picks
 	}
 
-	// Get current index and increment for next time
-	idx := kr.rotationIdx[provider]
-	kr.rotationIdx[provider] = (idx + 1) % len(keys)
+	selected := 0
+	for i := 1; i < len(keys); i++ {
+		if picks[keys[i].Name] < picks[keys[selected].Name] {
+			selected = i
+		}
+	}
+	kr.pickSeq++
+	picks[keys[selected].Name] = kr.pickSeq
 
-	selectedKey := &keys[idx]
+	selectedKey := &keys[selected]
 	return selectedKey, selectedKey.Name
 }
 
@@ -213,7 +676,7 @@ func (kr *KeyRotator) selectCostOptimized(ctx context.Context, provider string,
 	}
 
 	if bestKey == nil {
-		return nil, "", fmt.Errorf("all keys have exceeded their cost limits")
+		return nil, "", fmt.Errorf("%w: all keys have exceeded their cost limits", ErrBudgetExceeded)
 	}
 
 	return bestKey, bestKeyName, nil
@@ -225,21 +688,115 @@ func (kr *KeyRotator) selectRandom(keys []config.APIKey) (*config.APIKey, string
 		return nil, ""
 	}
 
-	idx := kr.rand.Intn(len(keys))
+	idx := rand.IntN(len(keys))
 	selectedKey := &keys[idx]
 	return selectedKey, selectedKey.Name
 }
 
+// selectWeighted implements weighted random key selection, distributing
+// traffic proportionally to each key's weight
+func (kr *KeyRotator) selectWeighted(keys []config.APIKey) (*config.APIKey, string) {
+	if len(keys) == 0 {
+		return nil, ""
+	}
+
+	totalWeight := 0
+	for _, key := range keys {
+		totalWeight += key.GetWeight()
+	}
+
+	pick := rand.IntN(totalWeight)
+	for i := range keys {
+		pick -= keys[i].GetWeight()
+		if pick < 0 {
+			return &keys[i], keys[i].Name
+		}
+	}
+
+	// Unreachable as long as weights are positive
+	selectedKey := &keys[len(keys)-1]
+	return selectedKey, selectedKey.Name
+}
+
+// priorityTier returns the keys the priority strategy rotates through: the
+// keys of the highest priority, where a lower tier is used only while every
+// key of the higher tiers is unhealthy, rate-limited (filtered out by the
+// caller) or over its cost limit
+func (kr *KeyRotator) priorityTier(ctx context.Context, provider string, keys []config.APIKey) ([]config.APIKey, error) {
+	var tier []config.APIKey
+	for _, key := range keys {
+		if len(tier) > 0 && key.Priority < tier[0].Priority {
+			continue
+		}
+		if healthy, err := kr.keyStore.IsHealthy(ctx, provider, key.Name); err == nil && !healthy {
+			continue
+		}
+		if usage, err := kr.keyStore.GetUsage(ctx, provider, key.Name); err == nil &&
+			key.CostLimit > 0 && usage.DailyCost >= key.CostLimit {
+			continue
+		}
+
+		if len(tier) > 0 && key.Priority > tier[0].Priority {
+			tier = tier[:0]
+		}
+		tier = append(tier, key)
+	}
+	if len(tier) == 0 {
+		return nil, fmt.Errorf("no healthy key within its cost limit")
+	}
+	return tier, nil
+}
+
 // selectSingle implements single key selection (first available)
-func (kr *KeyRotator) selectSingle(keys []config.APIKey) (*config.APIKey, string) {
+func (kr *KeyRotator) selectSingle(provider string, keys []config.APIKey) (*config.APIKey, string) {
 	if len(keys) == 0 {
 		return nil, ""
 	}
 
+	// The active key only changes on scheduled rotation (see Rotate). While it
+	// is filtered out, e.g. rate-limited, the first available key stands in.
+	if active, exists := kr.activeKey[provider]; exists {
+		for i := range keys {
+			if keys[i].Name == active {
+				return &keys[i], active
+			}
+		}
+	}
 	selectedKey := &keys[0]
 	return selectedKey, selectedKey.Name
 }
 
+// Rotate moves a provider using the single strategy on to its next key. It is
+// called by the Scheduler every rotation interval.
+func (kr *KeyRotator) Rotate(provider string) {
+	kr.rotate(provider, time.Now())
+}
+
+// rotate advances the active key of a provider, recording at as the rotation time
+func (kr *KeyRotator) rotate(provider string, at time.Time) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	kr.rotatedAt[provider] = at
+	providerConfig, err := kr.config.GetProvider(provider)
+	if err != nil {
+		return
+	}
+	keys := providerConfig.GetEnabledKeys()
+	if len(keys) == 0 {
+		return
+	}
+
+	current := 0
+	for i, key := range keys {
+		if key.Name == kr.activeKey[provider] {
+			current = i
+			break
+		}
+	}
+	kr.activeKey[provider] = keys[(current+1)%len(keys)].Name
+}
+
 // getFallbackKey gets a fallback key when primary selection fails
 func (kr *KeyRotator) getFallbackKey(ctx context.Context, provider, excludeKey string, keys []config.APIKey) (*KeySelection, error) {
 	// Filter out the failed key
@@ -258,33 +815,92 @@ func (kr *KeyRotator) getFallbackKey(ctx context.Context, provider, excludeKey s
 	}
 
 	// Use round-robin for fallback selection
+	kr.mu.Lock()
 	selectedKey, keyName := kr.selectRoundRobin(provider, fallbackKeys)
-	if selectedKey == nil {
-		return nil, fmt.Errorf("fallback selection failed")
+	kr.updateLastUsed(provider, keyName)
+	selection := newKeySelection(provider, selectedKey, config.RotationRoundRobin)
+	kr.acquireSlot(selection)
+	kr.mu.Unlock()
+
+	if err := kr.loadKey(ctx, selection); err != nil {
+		selection.Release()
+		return nil, fmt.Errorf("fallback key %s: %w", keyName, err)
 	}
+	return selection, nil
+}
 
-	keyValue, err := kr.keyStore.GetKey(ctx, provider, keyName)
+// filterDeleted removes keys that were soft-deleted from the key store
+func (kr *KeyRotator) filterDeleted(ctx context.Context, provider string, keys []config.APIKey) ([]config.APIKey, error) {
+	deletedNames, err := kr.keyStore.ListDeletedKeys(ctx, provider)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve fallback key: %w", err)
+		return nil, fmt.Errorf("failed to list deleted keys: %w", err)
+	}
+	if len(deletedNames) == 0 {
+		return keys, nil
 	}
 
-	usage, _ := kr.keyStore.GetUsage(ctx, provider, keyName)
-	if usage == nil {
-		usage = &KeyUsage{LastUsed: time.Now()}
+	deleted := make(map[string]bool, len(deletedNames))
+	for _, name := range deletedNames {
+		deleted[name] = true
 	}
 
-	kr.updateLastUsed(provider, keyName)
+	var active []config.APIKey
+	for _, key := range keys {
+		if !deleted[key.Name] {
+			active = append(active, key)
+		}
+	}
+	return active, nil
+}
 
-	return &KeySelection{
-		Provider:   provider,
-		KeyName:    keyName,
-		Key:        keyValue,
-		RateLimit:  selectedKey.RateLimit,
-		CostLimit:  selectedKey.CostLimit,
-		UsageCount: usage.UsageCount,
-		LastUsed:   usage.LastUsed,
-		Strategy:   config.RotationRoundRobin, // Fallback uses round-robin
-	}, nil
+// filterRateLimited removes keys that are near their provider-reported rate limit.
+// If every key is near its limit the keys are returned unfiltered.
+func (kr *KeyRotator) filterRateLimited(provider string, keys []config.APIKey) []config.APIKey {
+	states := kr.rateLimits[provider]
+	if len(states) == 0 {
+		return keys
+	}
+
+	now := time.Now()
+	var available []config.APIKey
+	for _, key := range keys {
+		if state, exists := states[key.Name]; exists && state.NearLimit(now) {
+			continue
+		}
+		available = append(available, key)
+	}
+
+	if len(available) == 0 {
+		return keys
+	}
+	return available
+}
+
+// RecordRateLimit records the rate limit headroom reported by the provider for a key
+func (kr *KeyRotator) RecordRateLimit(provider, keyName string, state RateLimitState) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if kr.rateLimits[provider] == nil {
+		kr.rateLimits[provider] = make(map[string]*RateLimitState)
+	}
+	if state.UpdatedAt.IsZero() {
+		state.UpdatedAt = time.Now()
+	}
+	kr.rateLimits[provider][keyName] = &state
+}
+
+// GetRateLimit returns the last reported rate limit state for a key
+func (kr *KeyRotator) GetRateLimit(provider, keyName string) (*RateLimitState, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	state, exists := kr.rateLimits[provider][keyName]
+	if !exists {
+		return nil, false
+	}
+	copied := *state
+	return &copied, true
 }
 
 // updateLastUsed updates the last used time for a key
@@ -295,25 +911,136 @@ func (kr *KeyRotator) updateLastUsed(provider, keyName string) {
 	kr.lastUsed[provider][keyName] = time.Now()
 }
 
-// RecordUsage records usage for a key and updates statistics
-func (kr *KeyRotator) RecordUsage(ctx context.Context, provider, keyName string, tokens int, cost float64) error {
-	return kr.keyStore.UpdateUsage(ctx, provider, keyName, tokens, cost)
+// RecordUsage records the usage of a request sent to model with a key. The
+// key and model statistics are updated in the background, in batches; only
+// while the queue is full or after StopUsageQueue are they written before
+// RecordUsage returns, and an error is returned if that fails.
+func (kr *KeyRotator) RecordUsage(ctx context.Context, provider, keyName, model string, tokens int, cost float64) error {
+	now := time.Now()
+	kr.heatmap.Record(provider, keyName, tokens, now)
+
+	record := UsageRecord{Provider: provider, KeyName: keyName, Model: model, Tokens: tokens, Cost: cost, Time: now}
+	if kr.usage.add(record) {
+		return nil
+	}
+	return writeUsage(context.WithoutCancel(ctx), kr.keyStore, []UsageRecord{record})
+}
+
+// FlushUsage waits until the usage recorded before it was called is written
+// to the key store. Statistics are flushed before they are read.
+func (kr *KeyRotator) FlushUsage(ctx context.Context) error {
+	return kr.usage.flush(ctx)
+}
+
+// StopUsageQueue writes the queued usage to the key store and stops the
+// background writer. Usage recorded afterwards is written synchronously.
+func (kr *KeyRotator) StopUsageQueue(ctx context.Context) error {
+	return kr.usage.close(ctx)
+}
+
+// GetUsageHeatmap returns per-key request and token counts in 5-minute buckets
+// for the last 24 hours, ordered oldest first, for rendering rotation heatmaps
+func (kr *KeyRotator) GetUsageHeatmap(provider string) map[string][]UsageBucket {
+	return kr.heatmap.Heatmap(provider, time.Now())
 }
 
-// RecordError records an error for a key
+// RecordError records an error for a key, with its key material redacted
 func (kr *KeyRotator) RecordError(ctx context.Context, provider, keyName, errorMsg string) error {
-	if memStore, ok := kr.keyStore.(*MemoryKeyStore); ok {
-		return memStore.RecordError(ctx, provider, keyName, errorMsg)
+	secret, _ := kr.keyStore.GetKey(ctx, provider, keyName)
+	return kr.keyStore.RecordError(ctx, provider, keyName, redact.String(errorMsg, secret))
+}
+
+// KeyStore returns the key store the rotator selects keys from
+func (kr *KeyRotator) KeyStore() KeyStore {
+	return kr.keyStore
+}
+
+// HealthCheckKeys returns the enabled, non-deleted key names of every
+// provider with rotation.health_check enabled in the current configuration
+func (kr *KeyRotator) HealthCheckKeys(ctx context.Context) map[string][]string {
+	kr.mu.RLock()
+	cfg := kr.config
+	kr.mu.RUnlock()
+
+	keys := make(map[string][]string)
+	for providerName, provider := range cfg.Providers {
+		if !provider.Rotation.HealthCheck {
+			continue
+		}
+		enabled, err := kr.filterDeleted(ctx, providerName, provider.GetEnabledKeys())
+		if err != nil {
+			continue
+		}
+		for _, key := range enabled {
+			keys[providerName] = append(keys[providerName], key.Name)
+		}
 	}
-	return fmt.Errorf("error recording not supported by this keystore implementation")
+	return keys
+}
+
+// GetUsageWindow returns the usage of a key within a window such as WindowToday
+func (kr *KeyRotator) GetUsageWindow(ctx context.Context, provider, keyName string, window Window) (*UsagePoint, error) {
+	kr.flushBeforeRead(ctx)
+	return UsageInWindow(ctx, kr.keyStore, provider, keyName, window, time.Now())
 }
 
-// GetKeyStatistics returns statistics for all keys of a provider
+// MarkUnhealthy marks a key as unhealthy, e.g. after the provider rejected it
+func (kr *KeyRotator) MarkUnhealthy(ctx context.Context, provider, keyName string) error {
+	kr.log().Warn("gollmkit: key marked unhealthy", "provider", provider, "key", keyName)
+	return kr.keyStore.SetHealth(ctx, provider, keyName, false)
+}
+
+// RecordLatency records the latency of a completed request for a provider/model
+func (kr *KeyRotator) RecordLatency(provider, model string, latency time.Duration) {
+	kr.latency.Record(provider, model, latency)
+}
+
+// RecordStream records the time to first token and the output tokens per
+// second of a completed stream for a provider/model
+func (kr *KeyRotator) RecordStream(provider, model string, ttft time.Duration, tokensPerSecond float64) {
+	kr.streams.Record(provider, model, ttft, tokensPerSecond)
+}
+
+// FastestProvider returns the configured provider with the lowest observed
+// latency among candidates, preferring providers that have not been measured yet
+func (kr *KeyRotator) FastestProvider(candidates []string) (string, error) {
+	kr.mu.RLock()
+	var configured []string
+	for _, provider := range candidates {
+		if _, err := kr.config.GetProvider(provider); err == nil {
+			configured = append(configured, provider)
+		}
+	}
+	kr.mu.RUnlock()
+
+	if len(configured) == 0 {
+		return "", fmt.Errorf("no configured providers among candidates %v", candidates)
+	}
+
+	return kr.latency.Fastest(configured), nil
+}
+
+// flushBeforeRead writes queued usage, so statistics read afterwards include
+// the requests that already finished. Reads go ahead if it fails.
+func (kr *KeyRotator) flushBeforeRead(ctx context.Context) {
+	if err := kr.FlushUsage(ctx); err != nil {
+		kr.log().Debug("gollmkit: statistics may miss queued usage", "error", err)
+	}
+}
+
+// GetKeyStatistics returns statistics for all keys of a provider,
+// including soft-deleted keys whose history is retained
 func (kr *KeyRotator) GetKeyStatistics(ctx context.Context, provider string) (map[string]*KeyUsage, error) {
+	kr.flushBeforeRead(ctx)
 	keyNames, err := kr.keyStore.ListKeys(ctx, provider)
 	if err != nil {
 		return nil, err
 	}
+	deletedNames, err := kr.keyStore.ListDeletedKeys(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	keyNames = append(keyNames, deletedNames...)
 
 	stats := make(map[string]*KeyUsage)
 	for _, keyName := range keyNames {
@@ -327,6 +1054,19 @@ func (kr *KeyRotator) GetKeyStatistics(ctx context.Context, provider string) (ma
 	return stats, nil
 }
 
+// GetModelStatistics returns statistics for all models of a provider that
+// have been used, across all keys
+func (kr *KeyRotator) GetModelStatistics(ctx context.Context, provider string) (map[string]*ModelUsage, error) {
+	kr.flushBeforeRead(ctx)
+	return kr.keyStore.GetModelUsage(ctx, provider)
+}
+
+// GetModelUsageWindow returns the usage of a model within a window such as WindowLast7Days
+func (kr *KeyRotator) GetModelUsageWindow(ctx context.Context, provider, model string, window Window) (*UsagePoint, error) {
+	kr.flushBeforeRead(ctx)
+	return ModelUsageInWindow(ctx, kr.keyStore, provider, model, window, time.Now())
+}
+
 // GetProviderStatistics returns aggregated statistics for a provider
 func (kr *KeyRotator) GetProviderStatistics(ctx context.Context, provider string) (*ProviderStats, error) {
 	keyStats, err := kr.GetKeyStatistics(ctx, provider)
@@ -334,19 +1074,33 @@ func (kr *KeyRotator) GetProviderStatistics(ctx context.Context, provider string
 		return nil, err
 	}
 
+	deletedNames, err := kr.keyStore.ListDeletedKeys(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	deleted := make(map[string]bool, len(deletedNames))
+	for _, name := range deletedNames {
+		deleted[name] = true
+	}
+
 	stats := &ProviderStats{
 		Provider:      provider,
-		TotalKeys:     len(keyStats),
+		TotalKeys:     len(keyStats) - len(deleted),
 		HealthyKeys:   0,
 		TotalCost:     0,
 		TotalTokens:   0,
 		TotalRequests: 0,
 		KeyStats:      make(map[string]*KeyStats),
+		Latency:       kr.latency.ModelStats(provider),
+		Streaming:     kr.streams.ModelStats(provider),
+	}
+	if stats.Models, err = kr.keyStore.GetModelUsage(ctx, provider); err != nil {
+		return nil, err
 	}
 
 	for keyName, usage := range keyStats {
 		healthy, _ := kr.keyStore.IsHealthy(ctx, provider, keyName)
-		if healthy {
+		if healthy && !deleted[keyName] {
 			stats.HealthyKeys++
 		}
 
@@ -359,7 +1113,12 @@ func (kr *KeyRotator) GetProviderStatistics(ctx context.Context, provider string
 			Healthy:  healthy,
 			Usage:    usage,
 			LastUsed: usage.LastUsed,
+			Deleted:  deleted[keyName],
+		}
+		if rateLimit, ok := kr.GetRateLimit(provider, keyName); ok {
+			stats.KeyStats[keyName].RateLimit = rateLimit
 		}
+		stats.KeyStats[keyName].InFlight = kr.InFlight(provider, keyName)
 	}
 
 	return stats, nil
@@ -367,43 +1126,49 @@ func (kr *KeyRotator) GetProviderStatistics(ctx context.Context, provider string
 
 // ProviderStats represents aggregated statistics for a provider
 type ProviderStats struct {
-	Provider      string               `json:"provider"`
-	TotalKeys     int                  `json:"total_keys"`
-	HealthyKeys   int                  `json:"healthy_keys"`
-	TotalCost     float64              `json:"total_cost"`
-	TotalTokens   int64                `json:"total_tokens"`
-	TotalRequests int64                `json:"total_requests"`
-	KeyStats      map[string]*KeyStats `json:"key_stats"`
+	Provider      string                   `json:"provider"`
+	TotalKeys     int                      `json:"total_keys"`
+	HealthyKeys   int                      `json:"healthy_keys"`
+	TotalCost     float64                  `json:"total_cost"`
+	TotalTokens   int64                    `json:"total_tokens"`
+	TotalRequests int64                    `json:"total_requests"`
+	KeyStats      map[string]*KeyStats     `json:"key_stats"`
+	Latency       map[string]*LatencyStats `json:"latency,omitempty"`   // model -> latency
+	Streaming     map[string]*StreamStats  `json:"streaming,omitempty"` // model -> time to first token and tokens/sec
+	Models        map[string]*ModelUsage   `json:"models,omitempty"`    // model -> usage
 }
 
 // KeyStats represents statistics for a single key
 type KeyStats struct {
-	Name     string    `json:"name"`
-	Healthy  bool      `json:"healthy"`
-	Usage    *KeyUsage `json:"usage"`
-	LastUsed time.Time `json:"last_used"`
+	Name      string          `json:"name"`
+	Healthy   bool            `json:"healthy"`
+	Usage     *KeyUsage       `json:"usage"`
+	LastUsed  time.Time       `json:"last_used"`
+	RateLimit *RateLimitState `json:"rate_limit,omitempty"`
+	Deleted   bool            `json:"deleted,omitempty"`
+	InFlight  int             `json:"in_flight,omitempty"`
 }
 
 // RotationStatus represents the current rotation status
 type RotationStatus struct {
 	Provider      string                  `json:"provider"`
 	Strategy      config.RotationStrategy `json:"strategy"`
-	CurrentIndex  int                     `json:"current_index,omitempty"`
+	ActiveKey     string                  `json:"active_key,omitempty"` // key of the single strategy, or the latest round-robin pick
 	AvailableKeys []string                `json:"available_keys"`
 	LastRotation  time.Time               `json:"last_rotation"`
 }
 
 // GetRotationStatus returns the current rotation status for a provider
 func (kr *KeyRotator) GetRotationStatus(ctx context.Context, provider string) (*RotationStatus, error) {
-	kr.mu.RLock()
-	defer kr.mu.RUnlock()
-
-	providerConfig, err := kr.config.GetProvider(provider)
+	keyNames, err := kr.keyStore.ListKeys(ctx, provider)
 	if err != nil {
 		return nil, err
 	}
 
-	keyNames, err := kr.keyStore.ListKeys(ctx, provider)
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	providerConfig, err := kr.config.GetProvider(provider)
 	if err != nil {
 		return nil, err
 	}
@@ -414,11 +1179,18 @@ func (kr *KeyRotator) GetRotationStatus(ctx context.Context, provider string) (*
 		AvailableKeys: keyNames,
 	}
 
-	if idx, exists := kr.rotationIdx[provider]; exists {
-		status.CurrentIndex = idx
+	status.ActiveKey = kr.activeKey[provider]
+	if status.ActiveKey == "" {
+		var latest uint64
+		for keyName, seq := range kr.picks[provider] {
+			if seq > latest {
+				latest, status.ActiveKey = seq, keyName
+			}
+		}
 	}
 
-	// Get last rotation time from last used times
+	// Get last rotation time from the scheduled rotation or last used times
+	status.LastRotation = kr.rotatedAt[provider]
 	if providerLastUsed, exists := kr.lastUsed[provider]; exists {
 		for _, lastUsed := range providerLastUsed {
 			if lastUsed.After(status.LastRotation) {
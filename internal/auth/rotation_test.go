@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// TestRoundRobinUnderConfigUpdates selects keys from many goroutines while
+// the configuration is swapped, and checks that round-robin stays fair. Run
+// it with -race.
+func TestRoundRobinUnderConfigUpdates(t *testing.T) {
+	const (
+		keys       = 4
+		workers    = 16
+		selections = 250
+	)
+
+	builder := config.New().Provider("mock").RotationStrategy(config.RotationRoundRobin).AddModel("mock-1", 0.001, 0.002)
+	for i := 0; i < keys; i++ {
+		builder.AddKey(fmt.Sprintf("k%d", i), fmt.Sprintf("mock-key-%d", i))
+	}
+	cfg, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewKeyStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotator := NewKeyRotator(cfg, store)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	var selectors sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		selectors.Add(1)
+		go func() {
+			defer selectors.Done()
+			for i := 0; i < selections; i++ {
+				selection, err := rotator.GetNextKey(ctx, "mock")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want := "mock-key-" + selection.KeyName[1:]; selection.Key != want {
+					t.Errorf("key %s has value %q, want %q", selection.KeyName, selection.Key, want)
+				}
+				selection.Release()
+
+				mu.Lock()
+				counts[selection.KeyName]++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Swap in changed configurations until the selectors are done
+	done := make(chan struct{})
+	var updater sync.WaitGroup
+	updater.Add(1)
+	go func() {
+		defer updater.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			updated := cfg.Clone()
+			provider := updated.Providers["mock"]
+			for k := range provider.APIKeys {
+				provider.APIKeys[k].RateLimit = i % 100
+			}
+			updated.Providers["mock"] = provider
+			if err := rotator.UpdateConfig(ctx, updated); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	selectors.Wait()
+	close(done)
+	updater.Wait()
+
+	if len(counts) != keys {
+		t.Fatalf("selected %d distinct keys, want %d: %v", len(counts), keys, counts)
+	}
+	lowest, highest := workers*selections, 0
+	for _, count := range counts {
+		lowest, highest = min(lowest, count), max(highest, count)
+	}
+	// Each pick takes the least recently picked key, so the spread is at most one
+	if highest-lowest > 1 {
+		t.Errorf("unfair distribution %v", counts)
+	}
+}
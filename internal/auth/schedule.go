@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+const (
+	// defaultExpiryWarning is how long before expiry KeyExpiring is emitted
+	defaultExpiryWarning = 7 * 24 * time.Hour
+
+	// defaultScheduleCheck is how often the scheduler checks keys
+	defaultScheduleCheck = time.Minute
+)
+
+// KeyEventType identifies a key lifecycle event
+type KeyEventType string
+
+const (
+	KeyExpiring    KeyEventType = "expiring"     // the key expires within the warning period
+	KeyExpired     KeyEventType = "expired"      // the key has expired and is no longer selected
+	KeyRotationDue KeyEventType = "rotation_due" // the key's rotate_after time has passed
+	KeyRotated     KeyEventType = "rotated"      // the provider moved on to its next key
+)
+
+// KeyEvent is emitted by the Scheduler, e.g. to notify operators or call a
+// provisioning hook that issues a replacement key
+type KeyEvent struct {
+	Type     KeyEventType `json:"type"`
+	Provider string       `json:"provider"`
+	KeyName  string       `json:"key_name,omitempty"`
+	At       time.Time    `json:"at"` // expiry or due time of the key, or time of the rotation
+}
+
+// KeyEventHook receives key lifecycle events
+type KeyEventHook func(ctx context.Context, event KeyEvent)
+
+// Scheduler watches key expiry and rotate_after times and rotates providers
+// using the single strategy every rotation interval
+type Scheduler struct {
+	rotator       *KeyRotator
+	hook          KeyEventHook
+	ExpiryWarning time.Duration // defaults to 7 days
+	CheckInterval time.Duration // defaults to 1 minute
+
+	emitted map[KeyEvent]bool
+}
+
+// NewScheduler creates a scheduler that reports events to hook
+func NewScheduler(rotator *KeyRotator, hook KeyEventHook) *Scheduler {
+	return &Scheduler{
+		rotator:       rotator,
+		hook:          hook,
+		ExpiryWarning: defaultExpiryWarning,
+		CheckInterval: defaultScheduleCheck,
+		emitted:       make(map[KeyEvent]bool),
+	}
+}
+
+// Run checks keys every CheckInterval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	interval := s.CheckInterval
+	if interval <= 0 {
+		interval = defaultScheduleCheck
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.Check(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check emits the events that are due at now and performs due rotations.
+// Each expiry and rotate_after event is emitted once. Check must not be
+// called concurrently with itself or Run.
+func (s *Scheduler) Check(ctx context.Context, now time.Time) {
+	s.rotator.mu.RLock()
+	cfg := s.rotator.config
+	rotatedAt := make(map[string]time.Time, len(s.rotator.rotatedAt))
+	for provider, at := range s.rotator.rotatedAt {
+		rotatedAt[provider] = at
+	}
+	s.rotator.mu.RUnlock()
+
+	for providerName, provider := range cfg.Providers {
+		for _, key := range provider.APIKeys {
+			s.checkKey(ctx, providerName, key, now)
+		}
+
+		if provider.Rotation.Strategy == config.RotationSingle && provider.Rotation.Interval != "" {
+			interval, err := provider.Rotation.GetInterval()
+			if err != nil || interval <= 0 {
+				continue
+			}
+			last, exists := rotatedAt[providerName]
+			if !exists {
+				// Start the first interval now rather than rotating immediately
+				s.rotator.mu.Lock()
+				s.rotator.rotatedAt[providerName] = now
+				s.rotator.mu.Unlock()
+				continue
+			}
+			if now.Sub(last) >= interval {
+				s.rotator.rotate(providerName, now)
+				s.emit(ctx, KeyEvent{Type: KeyRotated, Provider: providerName, At: now}, false)
+			}
+		}
+	}
+}
+
+// checkKey emits the expiry and rotation events of a single key
+func (s *Scheduler) checkKey(ctx context.Context, provider string, key config.APIKey, now time.Time) {
+	if expiresAt, err := key.GetExpiresAt(); err == nil && !expiresAt.IsZero() {
+		switch {
+		case !now.Before(expiresAt):
+			s.emit(ctx, KeyEvent{Type: KeyExpired, Provider: provider, KeyName: key.Name, At: expiresAt}, true)
+		case expiresAt.Sub(now) <= s.ExpiryWarning:
+			s.emit(ctx, KeyEvent{Type: KeyExpiring, Provider: provider, KeyName: key.Name, At: expiresAt}, true)
+		}
+	}
+
+	if rotateAfter, err := key.GetRotateAfter(); err == nil && !rotateAfter.IsZero() && !now.Before(rotateAfter) {
+		s.emit(ctx, KeyEvent{Type: KeyRotationDue, Provider: provider, KeyName: key.Name, At: rotateAfter}, true)
+	}
+}
+
+// emit calls the hook, skipping events already emitted if once is set
+func (s *Scheduler) emit(ctx context.Context, event KeyEvent, once bool) {
+	if once {
+		if s.emitted[event] {
+			return
+		}
+		s.emitted[event] = true
+	}
+	if s.hook != nil {
+		s.hook(ctx, event)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// RotationStrategy is a custom key selection strategy. SelectKey picks one of
+// keys, the enabled keys of provider that aren't deleted, excluded or near
+// their rate limit. stats holds the usage of each key by name; keys without
+// recorded usage are absent.
+//
+// SelectKey is called without the rotator locked, concurrently for concurrent
+// requests, so it must be safe for concurrent use. If the key it picks reaches
+// its in-flight limit before it is taken, SelectKey is called again.
+type RotationStrategy interface {
+	SelectKey(ctx context.Context, provider string, keys []config.APIKey, stats map[string]*KeyUsage) (*config.APIKey, error)
+}
+
+// RotationStrategyFunc adapts a plain function to the RotationStrategy interface
+type RotationStrategyFunc func(ctx context.Context, provider string, keys []config.APIKey, stats map[string]*KeyUsage) (*config.APIKey, error)
+
+// SelectKey calls f
+func (f RotationStrategyFunc) SelectKey(ctx context.Context, provider string, keys []config.APIKey, stats map[string]*KeyUsage) (*config.APIKey, error) {
+	return f(ctx, provider, keys, stats)
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = make(map[config.RotationStrategy]RotationStrategy)
+)
+
+// RegisterRotationStrategy registers a strategy selectable by name with
+// rotation.strategy or global.default_rotation_strategy. Register strategies
+// before loading the configuration, which is validated against them.
+// Registering an existing name replaces it; built-in strategies can't be replaced.
+func RegisterRotationStrategy(name config.RotationStrategy, strategy RotationStrategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = strategy
+	config.AllowRotationStrategy(name)
+}
+
+// UnregisterRotationStrategy removes a previously registered strategy
+func UnregisterRotationStrategy(name config.RotationStrategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	delete(strategies, name)
+	config.DisallowRotationStrategy(name)
+}
+
+// customStrategy returns the registered strategy of name, if any
+func customStrategy(name config.RotationStrategy) (RotationStrategy, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	strategy, exists := strategies[name]
+	return strategy, exists
+}
+
+// selectCustom selects a key with a registered strategy
+func (kr *KeyRotator) selectCustom(ctx context.Context, strategy RotationStrategy, provider string, keys []config.APIKey) (*config.APIKey, string, error) {
+	stats := make(map[string]*KeyUsage, len(keys))
+	for _, key := range keys {
+		if usage, err := kr.keyStore.GetUsage(ctx, provider, key.Name); err == nil {
+			stats[key.Name] = usage
+		}
+	}
+
+	selected, err := strategy.SelectKey(ctx, provider, keys, stats)
+	if err != nil || selected == nil {
+		return nil, "", err
+	}
+
+	// Only keys that passed filtering may be returned
+	for i := range keys {
+		if keys[i].Name == selected.Name {
+			return &keys[i], keys[i].Name, nil
+		}
+	}
+	return nil, "", fmt.Errorf("strategy selected unavailable key %s", selected.Name)
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StreamStats represents rolling time-to-first-token and generation rate
+// percentiles of streamed completions
+type StreamStats struct {
+	Samples int           `json:"samples"`
+	TTFTP50 time.Duration `json:"ttft_p50"`
+	TTFTP95 time.Duration `json:"ttft_p95"`
+
+	// Output tokens per second after the first token. P5 is the rate the
+	// slowest 5% of streams stay below.
+	TokensPerSecondP50 float64 `json:"tokens_per_second_p50,omitempty"`
+	TokensPerSecondP5  float64 `json:"tokens_per_second_p5,omitempty"`
+}
+
+// StreamRateTracker keeps a rolling window of streaming samples per provider and model
+type StreamRateTracker struct {
+	mu      sync.RWMutex
+	size    int
+	windows map[string]map[string]*streamWindow // provider -> model -> window
+}
+
+// streamSample is the time to first token and generation rate of one stream
+type streamSample struct {
+	ttft            time.Duration
+	tokensPerSecond float64 // zero if too few tokens were generated to measure
+}
+
+// streamWindow is a fixed-size ring buffer of stream samples
+type streamWindow struct {
+	samples []streamSample
+	next    int
+}
+
+// NewStreamRateTracker creates a tracker keeping size samples per provider/model
+func NewStreamRateTracker(size int) *StreamRateTracker {
+	if size <= 0 {
+		size = defaultLatencyWindow
+	}
+	return &StreamRateTracker{
+		size:    size,
+		windows: make(map[string]map[string]*streamWindow),
+	}
+}
+
+// Record adds the time to first token and output tokens per second of a
+// stream for a provider/model. Pass zero tokensPerSecond if it is unknown.
+func (st *StreamRateTracker) Record(provider, model string, ttft time.Duration, tokensPerSecond float64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.windows[provider] == nil {
+		st.windows[provider] = make(map[string]*streamWindow)
+	}
+
+	w, exists := st.windows[provider][model]
+	if !exists {
+		w = &streamWindow{samples: make([]streamSample, 0, st.size)}
+		st.windows[provider][model] = w
+	}
+
+	sample := streamSample{ttft: ttft, tokensPerSecond: tokensPerSecond}
+	if len(w.samples) < st.size {
+		w.samples = append(w.samples, sample)
+		return
+	}
+	w.samples[w.next] = sample
+	w.next = (w.next + 1) % st.size
+}
+
+// ModelStats returns streaming statistics for every streamed model of a provider
+func (st *StreamRateTracker) ModelStats(provider string) map[string]*StreamStats {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	stats := make(map[string]*StreamStats)
+	for model, w := range st.windows[provider] {
+		stats[model] = computeStreamStats(w.samples)
+	}
+	return stats
+}
+
+// computeStreamStats calculates percentiles over a set of samples
+func computeStreamStats(samples []streamSample) *StreamStats {
+	ttfts := make([]time.Duration, 0, len(samples))
+	var rates []float64
+	for _, sample := range samples {
+		ttfts = append(ttfts, sample.ttft)
+		if sample.tokensPerSecond > 0 {
+			rates = append(rates, sample.tokensPerSecond)
+		}
+	}
+	sort.Slice(ttfts, func(i, j int) bool { return ttfts[i] < ttfts[j] })
+	sort.Float64s(rates)
+
+	return &StreamStats{
+		Samples:            len(samples),
+		TTFTP50:            percentile(ttfts, 0.50),
+		TTFTP95:            percentile(ttfts, 0.95),
+		TokensPerSecondP50: ratePercentile(rates, 0.50),
+		TokensPerSecondP5:  ratePercentile(rates, 0.05),
+	}
+}
+
+// ratePercentile returns the p-th percentile of sorted rates using nearest-rank
+func ratePercentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Resolution is the bucket width of a usage time series
+type Resolution string
+
+const (
+	ResolutionMinute Resolution = "minute"
+	ResolutionHour   Resolution = "hour"
+	ResolutionDay    Resolution = "day"
+)
+
+// resolutionRetention is how many buckets are kept per resolution
+var resolutionRetention = map[Resolution]int{
+	ResolutionMinute: 120,     // 2 hours
+	ResolutionHour:   24 * 35, // 35 days
+	ResolutionDay:    400,     // over a year
+}
+
+// bucketStart returns the UTC start of the bucket containing t
+func (r Resolution) bucketStart(t time.Time) time.Time {
+	t = t.UTC()
+	switch r {
+	case ResolutionMinute:
+		return t.Truncate(time.Minute)
+	case ResolutionHour:
+		return t.Truncate(time.Hour)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// UsagePoint holds the usage of a key within one time series bucket
+type UsagePoint struct {
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+	Tokens   int64     `json:"tokens"`
+	Cost     float64   `json:"cost"`
+}
+
+// UsageSeries is a bucketed usage time series of a single key at minute,
+// hour and day resolution. It is not safe for concurrent use.
+type UsageSeries struct {
+	buckets map[Resolution]map[int64]*UsagePoint // resolution -> bucket start (unix) -> bucket
+}
+
+// NewUsageSeries creates an empty usage series
+func NewUsageSeries() *UsageSeries {
+	s := &UsageSeries{buckets: make(map[Resolution]map[int64]*UsagePoint)}
+	for res := range resolutionRetention {
+		s.buckets[res] = make(map[int64]*UsagePoint)
+	}
+	return s
+}
+
+// Add records a request at the given time in every resolution
+func (s *UsageSeries) Add(at time.Time, tokens int, cost float64) {
+	for res, retention := range resolutionRetention {
+		start := res.bucketStart(at)
+		point, exists := s.buckets[res][start.Unix()]
+		if !exists {
+			point = &UsagePoint{Start: start}
+			s.buckets[res][start.Unix()] = point
+			s.prune(res, retention)
+		}
+		point.Requests++
+		point.Tokens += int64(tokens)
+		point.Cost += cost
+	}
+}
+
+// prune drops the oldest buckets beyond retention
+func (s *UsageSeries) prune(res Resolution, retention int) {
+	buckets := s.buckets[res]
+	if len(buckets) <= retention {
+		return
+	}
+	starts := make([]int64, 0, len(buckets))
+	for start := range buckets {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, start := range starts[:len(starts)-retention] {
+		delete(buckets, start)
+	}
+}
+
+// clone returns a deep copy of the series
+func (s *UsageSeries) clone() *UsageSeries {
+	clone := &UsageSeries{buckets: make(map[Resolution]map[int64]*UsagePoint, len(s.buckets))}
+	for res, buckets := range s.buckets {
+		clone.buckets[res] = make(map[int64]*UsagePoint, len(buckets))
+		for start, point := range buckets {
+			copied := *point
+			clone.buckets[res][start] = &copied
+		}
+	}
+	return clone
+}
+
+// MarshalJSON encodes the buckets of every resolution, oldest first
+func (s *UsageSeries) MarshalJSON() ([]byte, error) {
+	points := make(map[Resolution][]UsagePoint, len(s.buckets))
+	for res := range s.buckets {
+		points[res] = s.Points(res, time.Time{})
+	}
+	return json.Marshal(points)
+}
+
+// UnmarshalJSON decodes buckets encoded by MarshalJSON
+func (s *UsageSeries) UnmarshalJSON(data []byte) error {
+	var points map[Resolution][]UsagePoint
+	if err := json.Unmarshal(data, &points); err != nil {
+		return err
+	}
+
+	*s = *NewUsageSeries()
+	for res, resPoints := range points {
+		if _, known := resolutionRetention[res]; !known {
+			continue
+		}
+		for _, point := range resPoints {
+			point := point
+			s.buckets[res][point.Start.Unix()] = &point
+		}
+	}
+	return nil
+}
+
+// Points returns copies of the buckets at res starting at or after since, oldest first
+func (s *UsageSeries) Points(res Resolution, since time.Time) []UsagePoint {
+	from := res.bucketStart(since)
+	var points []UsagePoint
+	for _, point := range s.buckets[res] {
+		if !point.Start.Before(from) {
+			points = append(points, *point)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Start.Before(points[j].Start) })
+	return points
+}
+
+// Window is a named time range for usage queries
+type Window string
+
+const (
+	WindowLastHour  Window = "last_hour"
+	WindowToday     Window = "today"       // since midnight UTC
+	WindowLast7Days Window = "last_7_days" // the last 168 hours, in whole hours
+	WindowThisMonth Window = "this_month"  // since the first of the month, UTC
+)
+
+// Range returns the start of the window at now and the resolution that covers it exactly
+func (w Window) Range(now time.Time) (time.Time, Resolution, error) {
+	now = now.UTC()
+	switch w {
+	case WindowLastHour:
+		return now.Add(-time.Hour), ResolutionMinute, nil
+	case WindowToday:
+		return ResolutionDay.bucketStart(now), ResolutionDay, nil
+	case WindowLast7Days:
+		return now.Add(-7 * 24 * time.Hour), ResolutionHour, nil
+	case WindowThisMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), ResolutionDay, nil
+	default:
+		return time.Time{}, "", fmt.Errorf("unknown usage window %q", w)
+	}
+}
+
+// SumPoints adds up the usage of points into one point starting at the first
+func SumPoints(points []UsagePoint) UsagePoint {
+	var total UsagePoint
+	for i, point := range points {
+		if i == 0 {
+			total.Start = point.Start
+		}
+		total.Requests += point.Requests
+		total.Tokens += point.Tokens
+		total.Cost += point.Cost
+	}
+	return total
+}
+
+// UsageInWindow returns the total usage of a key within a window
+func UsageInWindow(ctx context.Context, store KeyStore, provider, keyName string, window Window, now time.Time) (*UsagePoint, error) {
+	since, res, err := window.Range(now)
+	if err != nil {
+		return nil, err
+	}
+	points, err := store.GetUsageSeries(ctx, provider, keyName, res, since)
+	if err != nil {
+		return nil, err
+	}
+	total := SumPoints(points)
+	total.Start = since
+	return &total, nil
+}
+
+// ModelUsageInWindow returns the total usage of a model within a window
+func ModelUsageInWindow(ctx context.Context, store KeyStore, provider, model string, window Window, now time.Time) (*UsagePoint, error) {
+	since, res, err := window.Range(now)
+	if err != nil {
+		return nil, err
+	}
+	points, err := store.GetModelUsageSeries(ctx, provider, model, res, since)
+	if err != nil {
+		return nil, err
+	}
+	total := SumPoints(points)
+	total.Start = since
+	return &total, nil
+}
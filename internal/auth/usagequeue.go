@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+)
+
+// UsageRecord is the usage of a single request
+type UsageRecord struct {
+	Provider string
+	KeyName  string
+	Model    string // empty if only the key is updated
+	Tokens   int
+	Cost     float64
+	Time     time.Time // when the request finished
+}
+
+// BatchUsageUpdater is implemented by key stores that apply many usage
+// records at once, e.g. in a single round trip or file write. Stores without
+// it are updated with UpdateUsage for every record.
+type BatchUsageUpdater interface {
+	// UpdateUsageBatch applies every record it can and returns the errors of
+	// those it couldn't
+	UpdateUsageBatch(ctx context.Context, records []UsageRecord) error
+}
+
+// usageQueue buffers usage records and writes them to a key store in
+// batches from a background goroutine, started by the first record
+type usageQueue struct {
+	store     KeyStore
+	records   chan UsageRecord
+	batchSize int
+	interval  time.Duration
+	logger    func() logging.Logger
+
+	startOnce sync.Once
+	flushes   chan chan struct{}
+	stopMu    sync.RWMutex
+	stopped   bool
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newUsageQueue creates a queue writing to store with the settings of cfg
+func newUsageQueue(cfg *config.Config, store KeyStore, logger func() logging.Logger) *usageQueue {
+	interval, err := cfg.Global.KeyStore.GetUsageBatchInterval()
+	if err != nil || interval <= 0 {
+		interval = config.DefaultUsageBatchInterval
+	}
+	return &usageQueue{
+		store:     store,
+		records:   make(chan UsageRecord, cfg.Global.KeyStore.GetUsageQueueSize()),
+		batchSize: cfg.Global.KeyStore.GetUsageBatchSize(),
+		interval:  interval,
+		logger:    logger,
+		flushes:   make(chan chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// add queues a record and reports whether it was queued. It isn't while the
+// queue is full or after it was stopped, and the caller writes it itself.
+func (q *usageQueue) add(record UsageRecord) bool {
+	q.stopMu.RLock()
+	defer q.stopMu.RUnlock()
+	if q.stopped {
+		return false
+	}
+
+	q.startOnce.Do(func() { go q.run() })
+	select {
+	case q.records <- record:
+		return true
+	default:
+		return false
+	}
+}
+
+// flush waits until the records queued before it was called are written
+func (q *usageQueue) flush(ctx context.Context) error {
+	q.stopMu.RLock()
+	stopped := q.stopped
+	q.stopMu.RUnlock()
+	if stopped {
+		return nil // close wrote everything
+	}
+
+	q.startOnce.Do(func() { go q.run() })
+	written := make(chan struct{})
+	select {
+	case q.flushes <- written:
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("usage not written: %w", ctx.Err())
+	}
+	select {
+	case <-written:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("usage not written: %w", ctx.Err())
+	}
+}
+
+// close writes the queued records and stops the queue. Later records are
+// written by their callers.
+func (q *usageQueue) close(ctx context.Context) error {
+	q.stopMu.Lock()
+	if q.stopped {
+		q.stopMu.Unlock()
+		return nil
+	}
+	q.stopped = true
+	q.stopMu.Unlock()
+
+	q.startOnce.Do(func() { go q.run() })
+	close(q.stop)
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("usage not written: %w", ctx.Err())
+	}
+}
+
+// run writes queued records whenever a batch is full or interval has passed
+// since the first record of a batch was queued
+func (q *usageQueue) run() {
+	defer close(q.done)
+
+	timer := time.NewTimer(q.interval)
+	timer.Stop()
+	batch := make([]UsageRecord, 0, q.batchSize)
+	write := func() {
+		if len(batch) > 0 {
+			q.write(batch)
+			batch = batch[:0]
+		}
+		timer.Stop()
+	}
+	drain := func() {
+		for {
+			select {
+			case record := <-q.records:
+				if batch = append(batch, record); len(batch) >= q.batchSize {
+					write()
+				}
+			default:
+				write()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case record := <-q.records:
+			if batch = append(batch, record); len(batch) == 1 {
+				timer.Reset(q.interval)
+			}
+			if len(batch) >= q.batchSize {
+				write()
+			}
+		case <-timer.C:
+			write()
+		case written := <-q.flushes:
+			drain()
+			close(written)
+		case <-q.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// write writes a batch to the store. Failures are logged, the requests the
+// usage belongs to have already succeeded.
+func (q *usageQueue) write(batch []UsageRecord) {
+	if err := writeUsage(context.Background(), q.store, batch); err != nil {
+		q.logger().Warn("gollmkit: failed to record usage", "records", len(batch), "error", err)
+	}
+}
+
+// writeUsage applies records to store, in one call if it is a BatchUsageUpdater
+func writeUsage(ctx context.Context, store KeyStore, records []UsageRecord) error {
+	if batcher, ok := store.(BatchUsageUpdater); ok {
+		return batcher.UpdateUsageBatch(ctx, records)
+	}
+
+	var errs []error
+	for _, record := range records {
+		if err := store.UpdateUsage(ctx, record.Provider, record.KeyName, record.Model, record.Tokens, record.Cost); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
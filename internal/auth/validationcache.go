@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// validationCache holds recent validation results so repeated validations,
+// such as health checks every interval, don't probe the provider every time
+type validationCache struct {
+	mu      sync.Mutex
+	entries map[string]validationCacheEntry // provider/keyName -> latest result
+}
+
+// validationCacheEntry is a cached result of a key with a given value
+type validationCacheEntry struct {
+	fingerprint string // hash of the validated key value, so a changed key is revalidated
+	result      ValidationResult
+	expires     time.Time
+}
+
+// newValidationCache creates an empty cache
+func newValidationCache() *validationCache {
+	return &validationCache{entries: make(map[string]validationCacheEntry)}
+}
+
+// get returns a copy of the cached result of a key, if it hasn't expired at now
+func (c *validationCache) get(provider, keyName, fingerprint string, now time.Time) (*ValidationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[provider+"/"+keyName]
+	if !ok || entry.fingerprint != fingerprint || !now.Before(entry.expires) {
+		return nil, false
+	}
+	result := copyResult(&entry.result)
+	result.Cached = true
+	return result, true
+}
+
+// put caches the result of a key until expires
+func (c *validationCache) put(provider, keyName, fingerprint string, result *ValidationResult, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[provider+"/"+keyName] = validationCacheEntry{fingerprint: fingerprint, result: *copyResult(result), expires: expires}
+}
+
+// invalidate drops the cached result of a key
+func (c *validationCache) invalidate(provider, keyName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, provider+"/"+keyName)
+}
+
+// copyResult copies a result, so cached results aren't shared with callers
+func copyResult(result *ValidationResult) *ValidationResult {
+	clone := *result
+	clone.Metadata = make(map[string]interface{}, len(result.Metadata))
+	for name, value := range result.Metadata {
+		clone.Metadata[name] = value
+	}
+	return &clone
+}
+
+// keyFingerprint returns a hash identifying a key value without storing it
+func keyFingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,291 +2,356 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+	"github.com/gollmkit/gollmkit/internal/useragent"
 )
 
 // KeyValidator handles API key validation for different providers
 type KeyValidator struct {
 	httpClient *http.Client
+	logger     logging.Logger
+
+	formatOnly bool          // skip live probes
+	cacheTTL   time.Duration // how long results are reused, 0 to always validate
+	cache      *validationCache
+	probes     chan struct{} // bounds concurrent live probes, nil if unlimited
+	workers    int           // keys ValidateAllKeys validates at once
+
+	providerProbesMu sync.Mutex
+	providerProbes   map[string]chan struct{} // provider -> bounds its concurrent live probes
+	perProvider      int                      // size of providerProbes channels, 0 if unlimited
+
+	providerModeMu sync.RWMutex
+	providerMode   map[string]string // provider -> config.ValidationProbe*, cheap if absent
 }
 
-// NewKeyValidator creates a new key validator
+// DefaultValidationWorkers is how many keys ValidateAllKeys validates at once by default
+const DefaultValidationWorkers = 8
+
+// ValidationTimeout bounds a single live validation probe
+const ValidationTimeout = 10 * time.Second
+
+// NewKeyValidator creates a new key validator. It probes the provider on
+// every validation until SetCacheTTL is called.
 func NewKeyValidator() *KeyValidator {
 	return &KeyValidator{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		httpClient:     useragent.Wrap(&http.Client{Timeout: ValidationTimeout}, "", nil),
+		cache:          newValidationCache(),
+		workers:        DefaultValidationWorkers,
+		providerProbes: make(map[string]chan struct{}),
+		providerMode:   make(map[string]string),
 	}
 }
 
-// ValidationResult represents the result of key validation
-type ValidationResult struct {
-	Valid     bool                   `json:"valid"`
-	Provider  string                 `json:"provider"`
-	KeyName   string                 `json:"key_name"`
-	Message   string                 `json:"message,omitempty"`
-	CheckedAt time.Time              `json:"checked_at"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
-}
-
-// ValidateKey validates an API key for a specific provider
-func (kv *KeyValidator) ValidateKey(ctx context.Context, provider, keyName, apiKey string) (*ValidationResult, error) {
-	result := &ValidationResult{
-		Provider:  provider,
-		KeyName:   keyName,
-		CheckedAt: time.Now(),
-		Metadata:  make(map[string]interface{}),
-	}
-
-	// First, check key format
-	if !kv.isValidKeyFormat(provider, apiKey) {
-		result.Valid = false
-		result.Message = "Invalid key format"
-		return result, nil
+// NewKeyValidatorFromConfig creates a key validator with the mode, result
+// cache and probe concurrency of cfg
+func NewKeyValidatorFromConfig(cfg config.ValidationConfig) (*KeyValidator, error) {
+	ttl, err := cfg.GetCacheTTL()
+	if err != nil {
+		return nil, fmt.Errorf("invalid validation cache_ttl: %w", err)
 	}
 
-	// Then, perform live validation if possible
-	switch strings.ToLower(provider) {
-	case "openai":
-		return kv.validateOpenAIKey(ctx, result, apiKey)
-	case "anthropic":
-		return kv.validateAnthropicKey(ctx, result, apiKey)
-	case "gemini", "google":
-		return kv.validateGeminiKey(ctx, result, apiKey)
-	default:
-		result.Valid = true
-		result.Message = "Format validation passed (live validation not implemented)"
-		return result, nil
+	kv := NewKeyValidator()
+	kv.SetFormatOnly(cfg.Mode == config.ValidationModeFormat)
+	kv.SetCacheTTL(ttl)
+	kv.SetMaxConcurrentProbes(cfg.GetMaxConcurrentProbes())
+	kv.SetMaxProbesPerProvider(cfg.GetMaxProbesPerProvider())
+	kv.SetWorkers(cfg.GetWorkers())
+	for provider, probe := range cfg.Probes {
+		kv.SetProbe(provider, probe)
 	}
+	return kv, nil
 }
 
-// isValidKeyFormat checks if the API key format is valid for the provider
-func (kv *KeyValidator) isValidKeyFormat(provider, apiKey string) bool {
-	switch strings.ToLower(provider) {
-	case "openai":
-		// OpenAI keys typically start with "sk-" and are 51 characters long
-		// New format: sk-proj-... (longer)
-		openAIPattern := regexp.MustCompile(`^sk-[a-zA-Z0-9]{48}$|^sk-proj-[a-zA-Z0-9-_]{43,}$`)
-		return openAIPattern.MatchString(apiKey)
-
-	case "anthropic":
-		// Anthropic keys start with "sk-ant-"
-		anthropicPattern := regexp.MustCompile(`^sk-ant-[a-zA-Z0-9-_]{93,}$`)
-		return anthropicPattern.MatchString(apiKey)
-
-	case "gemini", "google":
-		// Google AI keys typically start with "AIza"
-		geminiPattern := regexp.MustCompile(`^AIza[a-zA-Z0-9_-]{35}$`)
-		return geminiPattern.MatchString(apiKey)
-
-	default:
-		// For unknown providers, just check it's not empty
-		return strings.TrimSpace(apiKey) != ""
-	}
+// SetLogger sets the logger receiving validation records, slog.Default() if unset
+func (kv *KeyValidator) SetLogger(logger logging.Logger) {
+	kv.logger = logger
 }
 
-// validateOpenAIKey validates an OpenAI API key by making a test request
-func (kv *KeyValidator) validateOpenAIKey(ctx context.Context, result *ValidationResult, apiKey string) (*ValidationResult, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.openai.com/v1/models", nil)
-	if err != nil {
-		return result, fmt.Errorf("failed to create request: %w", err)
-	}
+// SetFormatOnly makes validation only check the key format, never calling the
+// provider. Live probes cost requests and, for Anthropic, tokens.
+func (kv *KeyValidator) SetFormatOnly(formatOnly bool) {
+	kv.formatOnly = formatOnly
+}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("User-Agent", "GoLLM/1.0")
+// SetCacheTTL makes validations reuse the result of a key for ttl, as long as
+// the key's value is unchanged. Failed probes, such as network errors, are not
+// cached. Zero disables the cache.
+func (kv *KeyValidator) SetCacheTTL(ttl time.Duration) {
+	kv.cacheTTL = ttl
+}
 
-	resp, err := kv.httpClient.Do(req)
-	if err != nil {
-		result.Valid = false
-		result.Message = fmt.Sprintf("Request failed: %s", err.Error())
-		return result, nil
+// SetMaxConcurrentProbes bounds how many live probes run at once, unlimited if n <= 0
+func (kv *KeyValidator) SetMaxConcurrentProbes(n int) {
+	if n <= 0 {
+		kv.probes = nil
+		return
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		result.Valid = true
-		result.Message = "Key is valid and active"
-
-		// Try to extract organization info from headers
-		if org := resp.Header.Get("openai-organization"); org != "" {
-			result.Metadata["organization"] = org
-		}
+	kv.probes = make(chan struct{}, n)
+}
 
-	case http.StatusUnauthorized:
-		result.Valid = false
-		result.Message = "Invalid or expired API key"
+// SetHTTPClient sets the HTTP client probing providers
+func (kv *KeyValidator) SetHTTPClient(client *http.Client) {
+	kv.httpClient = client
+}
 
-	case http.StatusTooManyRequests:
-		result.Valid = true
-		result.Message = "Key is valid but rate limited"
-		result.Metadata["rate_limited"] = true
+// SetMaxProbesPerProvider bounds how many live probes run at once against a
+// single provider, unlimited if n <= 0
+func (kv *KeyValidator) SetMaxProbesPerProvider(n int) {
+	kv.providerProbesMu.Lock()
+	defer kv.providerProbesMu.Unlock()
+	kv.perProvider = n
+	kv.providerProbes = make(map[string]chan struct{})
+}
 
-	case http.StatusForbidden:
-		result.Valid = false
-		result.Message = "Key lacks required permissions"
+// SetProbe sets the live probe of a provider's keys: config.ValidationProbeNone
+// to only check their format, ValidationProbeCheap (the default) for a
+// non-billable request, or ValidationProbeFull for a minimal billable request
+func (kv *KeyValidator) SetProbe(provider, probe string) {
+	kv.providerModeMu.Lock()
+	defer kv.providerModeMu.Unlock()
+	kv.providerMode[strings.ToLower(provider)] = probe
+}
 
-	default:
-		result.Valid = false
-		result.Message = fmt.Sprintf("Unexpected status code: %d", resp.StatusCode)
+// probe returns the live probe of a provider's keys
+func (kv *KeyValidator) probe(provider string) string {
+	kv.providerModeMu.RLock()
+	defer kv.providerModeMu.RUnlock()
+	if probe := kv.providerMode[strings.ToLower(provider)]; probe != "" {
+		return probe
 	}
-
-	return result, nil
+	return config.ValidationProbeCheap
 }
 
-// validateAnthropicKey validates an Anthropic API key
-func (kv *KeyValidator) validateAnthropicKey(ctx context.Context, result *ValidationResult, apiKey string) (*ValidationResult, error) {
-	// Anthropic doesn't have a models endpoint, so we'll make a minimal completion request
-	reqBody := strings.NewReader(`{
-		"model": "claude-3-haiku-20240307",
-		"max_tokens": 1,
-		"messages": [{"role": "user", "content": "Hi"}]
-	}`)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", reqBody)
-	if err != nil {
-		return result, fmt.Errorf("failed to create request: %w", err)
+// SetWorkers sets how many keys ValidateAllKeys validates at once
+func (kv *KeyValidator) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
 	}
+	kv.workers = n
+}
 
-	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "GoLLM/1.0")
-
-	resp, err := kv.httpClient.Do(req)
-	if err != nil {
-		result.Valid = false
-		result.Message = fmt.Sprintf("Request failed: %s", err.Error())
-		return result, nil
+// providerSlots returns the channel bounding the live probes of provider, nil if unlimited
+func (kv *KeyValidator) providerSlots(provider string) chan struct{} {
+	kv.providerProbesMu.Lock()
+	defer kv.providerProbesMu.Unlock()
+	if kv.perProvider <= 0 {
+		return nil
 	}
-	defer resp.Body.Close()
+	slots, ok := kv.providerProbes[provider]
+	if !ok {
+		slots = make(chan struct{}, kv.perProvider)
+		kv.providerProbes[provider] = slots
+	}
+	return slots
+}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		result.Valid = true
-		result.Message = "Key is valid and active"
+// acquireProbe waits for a free probe slot, ctx permitting
+func acquireProbe(ctx context.Context, slots chan struct{}) error {
+	if slots == nil {
+		return nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	case http.StatusUnauthorized:
-		result.Valid = false
-		result.Message = "Invalid or expired API key"
+// releaseProbe frees a slot taken with acquireProbe
+func releaseProbe(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
+}
 
-	case http.StatusTooManyRequests:
-		result.Valid = true
-		result.Message = "Key is valid but rate limited"
-		result.Metadata["rate_limited"] = true
+// Invalidate drops the cached result of a key, so its next validation probes the provider
+func (kv *KeyValidator) Invalidate(provider, keyName string) {
+	kv.cache.invalidate(provider, keyName)
+}
 
-	case http.StatusForbidden:
-		result.Valid = false
-		result.Message = "Key lacks required permissions"
+// ValidationResult represents the result of key validation
+type ValidationResult struct {
+	Valid     bool                   `json:"valid"`
+	Provider  string                 `json:"provider"`
+	KeyName   string                 `json:"key_name"`
+	Message   string                 `json:"message,omitempty"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Cached    bool                   `json:"cached,omitempty"` // reused from an earlier validation, see SetCacheTTL
+	Error     string                 `json:"error,omitempty"`  // why the key couldn't be validated; Valid is then unknown
 
-	case http.StatusBadRequest:
-		// Bad request might still mean the key is valid
-		result.Valid = true
-		result.Message = "Key appears valid (request format issue)"
+	transient bool // the probe failed, e.g. with a network error, so the result isn't cached
+}
 
-	default:
-		result.Valid = false
-		result.Message = fmt.Sprintf("Unexpected status code: %d", resp.StatusCode)
+// ValidateKey validates an API key for a specific provider, reusing a cached
+// result if the cache is enabled
+func (kv *KeyValidator) ValidateKey(ctx context.Context, provider, keyName, apiKey string) (*ValidationResult, error) {
+	if kv.cacheTTL <= 0 {
+		return kv.validateKey(ctx, provider, keyName, apiKey)
 	}
 
-	return result, nil
+	fingerprint := keyFingerprint(apiKey)
+	if result, ok := kv.cache.get(provider, keyName, fingerprint, time.Now()); ok {
+		return result, nil
+	}
+	result, err := kv.validateKey(ctx, provider, keyName, apiKey)
+	if err == nil && !result.transient {
+		kv.cache.put(provider, keyName, fingerprint, result, result.CheckedAt.Add(kv.cacheTTL))
+	}
+	return result, err
 }
 
-// validateGeminiKey validates a Google Gemini API key
-func (kv *KeyValidator) validateGeminiKey(ctx context.Context, result *ValidationResult, apiKey string) (*ValidationResult, error) {
-	// Use the models list endpoint for validation
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models?key=%s", apiKey)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return result, fmt.Errorf("failed to create request: %w", err)
+// validateKey checks the format of a key and, unless format-only, probes the provider
+func (kv *KeyValidator) validateKey(ctx context.Context, provider, keyName, apiKey string) (*ValidationResult, error) {
+	result := &ValidationResult{
+		Provider:  provider,
+		KeyName:   keyName,
+		CheckedAt: time.Now(),
+		Metadata:  make(map[string]interface{}),
 	}
 
-	req.Header.Set("User-Agent", "GoLLM/1.0")
-
-	resp, err := kv.httpClient.Do(req)
-	if err != nil {
+	// First, check key format
+	validator := providerValidator(provider)
+	if !validator.ValidFormat(apiKey) {
 		result.Valid = false
-		result.Message = fmt.Sprintf("Request failed: %s", err.Error())
+		result.Message = "Invalid key format"
 		return result, nil
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
+	probe := kv.probe(provider)
+	if kv.formatOnly || probe == config.ValidationProbeNone {
 		result.Valid = true
-		result.Message = "Key is valid and active"
-
-	case http.StatusUnauthorized, http.StatusForbidden:
-		result.Valid = false
-		result.Message = "Invalid or expired API key"
-
-	case http.StatusTooManyRequests:
-		result.Valid = true
-		result.Message = "Key is valid but rate limited"
-		result.Metadata["rate_limited"] = true
-
-	case http.StatusBadRequest:
-		result.Valid = false
-		result.Message = "Invalid request (possibly malformed key)"
+		result.Message = "Format validation passed (live validation disabled)"
+		return result, nil
+	}
 
-	default:
+	// Wait for a slot of the provider before taking one of the global limit
+	providerSlots := kv.providerSlots(provider)
+	if err := acquireProbe(ctx, providerSlots); err != nil {
+		return result, err
+	}
+	defer releaseProbe(providerSlots)
+	if err := acquireProbe(ctx, kv.probes); err != nil {
+		return result, err
+	}
+	defer releaseProbe(kv.probes)
+
+	// Then, perform live validation
+	var err error
+	if full, ok := validator.(FullProber); ok && probe == config.ValidationProbeFull {
+		err = full.FullProbe(ctx, kv.httpClient, apiKey, result)
+	} else {
+		err = validator.Probe(ctx, kv.httpClient, apiKey, result)
+	}
+	if err != nil {
 		result.Valid = false
-		result.Message = fmt.Sprintf("Unexpected status code: %d", resp.StatusCode)
+		result.Message = fmt.Sprintf("Request failed: %s", err.Error())
+		result.transient = true
 	}
-
 	return result, nil
 }
 
-// ValidateAllKeys validates all keys for all providers in the configuration
+// ValidateAllKeys validates the keys of every provider concurrently with
+// SetWorkers workers. It returns a result for every key; keys that couldn't be
+// validated, e.g. because the key store failed or ctx is done, have Error set
+// and are joined into the returned error, while the other results are still valid.
 func (kv *KeyValidator) ValidateAllKeys(ctx context.Context, keyStore KeyStore, providers map[string][]string) (map[string]map[string]*ValidationResult, error) {
-	results := make(map[string]map[string]*ValidationResult)
-
+	type job struct{ provider, keyName string }
+	var jobs []job
 	for provider, keyNames := range providers {
-		results[provider] = make(map[string]*ValidationResult)
-
 		for _, keyName := range keyNames {
-			apiKey, err := keyStore.GetKey(ctx, provider, keyName)
-			if err != nil {
-				results[provider][keyName] = &ValidationResult{
-					Valid:     false,
-					Provider:  provider,
-					KeyName:   keyName,
-					Message:   fmt.Sprintf("Failed to retrieve key: %s", err.Error()),
-					CheckedAt: time.Now(),
-				}
-				continue
-			}
+			jobs = append(jobs, job{provider, keyName})
+		}
+	}
 
-			result, err := kv.ValidateKey(ctx, provider, keyName, apiKey)
-			if err != nil {
-				results[provider][keyName] = &ValidationResult{
-					Valid:     false,
-					Provider:  provider,
-					KeyName:   keyName,
-					Message:   fmt.Sprintf("Validation error: %s", err.Error()),
-					CheckedAt: time.Now(),
-				}
-				continue
+	validated := make([]*ValidationResult, len(jobs))
+	errs := make([]error, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < kv.workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				validated[i], errs[i] = kv.validateStoredKey(ctx, keyStore, jobs[i].provider, jobs[i].keyName)
 			}
+		}()
+	}
 
-			results[provider][keyName] = result
+feed:
+	for i := range jobs {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			for j := i; j < len(jobs); j++ {
+				errs[j] = ctx.Err()
+			}
+			break feed
 		}
 	}
+	close(indexes)
+	wg.Wait()
 
+	results := make(map[string]map[string]*ValidationResult, len(providers))
+	for provider := range providers {
+		results[provider] = make(map[string]*ValidationResult)
+	}
+	var failed []error
+	for i, j := range jobs {
+		if errs[i] != nil {
+			validated[i] = &ValidationResult{
+				Provider:  j.provider,
+				KeyName:   j.keyName,
+				Message:   fmt.Sprintf("Validation error: %s", errs[i].Error()),
+				CheckedAt: time.Now(),
+				Error:     errs[i].Error(),
+			}
+			failed = append(failed, fmt.Errorf("%s/%s: %w", j.provider, j.keyName, errs[i]))
+		}
+		results[j.provider][j.keyName] = validated[i]
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d keys could not be validated: %w", len(failed), len(jobs), errors.Join(failed...))
+	}
 	return results, nil
 }
 
+// validateStoredKey validates a key read from keyStore
+func (kv *KeyValidator) validateStoredKey(ctx context.Context, keyStore KeyStore, provider, keyName string) (*ValidationResult, error) {
+	logger := logging.OrDefault(kv.logger)
+	apiKey, err := keyStore.GetKey(ctx, provider, keyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve key: %w", err)
+	}
+
+	result, err := kv.ValidateKey(ctx, provider, keyName, apiKey)
+	if err != nil {
+		logger.Warn("gollmkit: key validation failed", "provider", provider, "key", keyName, "error", err)
+		return nil, err
+	}
+	logger.Debug("gollmkit: key validated",
+		"provider", provider, "key", keyName, "valid", result.Valid, "cached", result.Cached, "message", result.Message)
+	return result, nil
+}
+
 // HealthChecker performs periodic health checks on API keys
 type HealthChecker struct {
 	validator *KeyValidator
 	keyStore  KeyStore
 	interval  time.Duration
 	stopCh    chan struct{}
+	stopOnce  sync.Once
+	checks    sync.WaitGroup // health checks in progress
+	logger    logging.Logger
 }
 
 // NewHealthChecker creates a new health checker
@@ -299,18 +364,44 @@ func NewHealthChecker(keyStore KeyStore, interval time.Duration) *HealthChecker
 	}
 }
 
-// Start begins periodic health checking
+// SetValidator sets the validator used for health checks
+func (hc *HealthChecker) SetValidator(validator *KeyValidator) {
+	hc.validator = validator
+}
+
+// SetLogger sets the logger receiving health check failures, slog.Default() if unset
+func (hc *HealthChecker) SetLogger(logger logging.Logger) {
+	hc.logger = logger
+}
+
+// Start begins periodic health checking of the given keys
 func (hc *HealthChecker) Start(ctx context.Context, providers map[string][]string) {
+	hc.Run(ctx, func(ctx context.Context) map[string][]string { return providers })
+}
+
+// Run begins periodic health checking of the keys returned by keys, which is
+// called before every check so key changes are picked up. It blocks until
+// ctx is done or Stop is called, and returns once running checks finished.
+func (hc *HealthChecker) Run(ctx context.Context, keys func(ctx context.Context) map[string][]string) {
 	ticker := time.NewTicker(hc.interval)
 	defer ticker.Stop()
+	defer hc.checks.Wait()
+
+	check := func() {
+		hc.checks.Add(1)
+		go func() {
+			defer hc.checks.Done()
+			hc.performHealthCheck(ctx, keys(ctx))
+		}()
+	}
 
 	// Perform initial health check
-	go hc.performHealthCheck(ctx, providers)
+	check()
 
 	for {
 		select {
 		case <-ticker.C:
-			go hc.performHealthCheck(ctx, providers)
+			check()
 		case <-hc.stopCh:
 			return
 		case <-ctx.Done():
@@ -319,29 +410,53 @@ func (hc *HealthChecker) Start(ctx context.Context, providers map[string][]strin
 	}
 }
 
-// Stop stops the health checker
+// Stop stops the health checker. It is safe to call more than once.
 func (hc *HealthChecker) Stop() {
-	close(hc.stopCh)
+	hc.stopOnce.Do(func() { close(hc.stopCh) })
 }
 
 // performHealthCheck performs a health check on all keys
 func (hc *HealthChecker) performHealthCheck(ctx context.Context, providers map[string][]string) {
+	logger := logging.OrDefault(hc.logger)
+
+	// Cached results are reused for healthy keys only, so keys marked
+	// unhealthy, e.g. after failed requests, are probed again
+	for provider, keyNames := range providers {
+		for _, keyName := range keyNames {
+			if healthy, err := hc.keyStore.IsHealthy(ctx, provider, keyName); err != nil || !healthy {
+				hc.validator.Invalidate(provider, keyName)
+			}
+		}
+	}
+
+	// Keys that couldn't be validated keep their health; the others are still updated
 	results, err := hc.validator.ValidateAllKeys(ctx, hc.keyStore, providers)
 	if err != nil {
-		return // Log error in production
+		logger.Error("gollmkit: health check incomplete", "error", err)
 	}
 
 	// Update health status in key store
+	var checked, unhealthy int
 	for provider, providerResults := range results {
 		for keyName, result := range providerResults {
-			if memStore, ok := hc.keyStore.(*MemoryKeyStore); ok {
-				memStore.SetHealth(ctx, provider, keyName, result.Valid)
-				if !result.Valid {
-					memStore.RecordError(ctx, provider, keyName, result.Message)
-				}
+			if result.Error != "" {
+				continue
+			}
+			checked++
+			if err := hc.keyStore.SetHealth(ctx, provider, keyName, result.Valid); err != nil {
+				logger.Error("gollmkit: failed to store key health", "provider", provider, "key", keyName, "error", err)
+			}
+			if result.Valid {
+				continue
+			}
+			unhealthy++
+			logger.Warn("gollmkit: key failed health check", "provider", provider, "key", keyName, "message", result.Message)
+			if err := hc.keyStore.RecordError(ctx, provider, keyName, result.Message); err != nil {
+				logger.Error("gollmkit: failed to record key error", "provider", provider, "key", keyName, "error", err)
 			}
 		}
 	}
+	logger.Debug("gollmkit: health check finished", "keys", checked, "unhealthy", unhealthy)
 }
 
 // GetHealthStatus returns the current health status of all keys
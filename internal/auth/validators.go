@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// ProviderValidator validates the keys of one provider. Register one with
+// RegisterValidator to validate keys of a custom provider.
+type ProviderValidator interface {
+	// ValidFormat reports whether apiKey is well-formed, without calling the provider
+	ValidFormat(apiKey string) bool
+	// Probe checks a well-formed apiKey against the provider with client and
+	// sets Valid, Message and Metadata of result. It returns an error if the
+	// validity couldn't be determined, e.g. on network errors or provider
+	// outages; the key is then reported invalid and the result isn't cached.
+	Probe(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error
+}
+
+// FullProber is implemented by validators that can also check a key with a
+// minimal billable request, used for providers whose probe is set to full.
+// Validators without it use Probe instead.
+type FullProber interface {
+	FullProbe(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error
+}
+
+// ProbeFunc is the live check of a PatternValidator
+type ProbeFunc func(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error
+
+// PatternValidator is a ProviderValidator checking key formats with a
+// regular expression. Without ProbeFunc, well-formed keys are reported valid.
+// FullProbeFunc is the billable check of the full probe, ProbeFunc if nil.
+type PatternValidator struct {
+	Pattern       *regexp.Regexp
+	ProbeFunc     ProbeFunc
+	FullProbeFunc ProbeFunc
+}
+
+// ValidFormat reports whether apiKey matches the pattern, or isn't blank if there is none
+func (v PatternValidator) ValidFormat(apiKey string) bool {
+	if v.Pattern == nil {
+		return strings.TrimSpace(apiKey) != ""
+	}
+	return v.Pattern.MatchString(apiKey)
+}
+
+// Probe calls ProbeFunc, if any
+func (v PatternValidator) Probe(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error {
+	if v.ProbeFunc == nil {
+		result.Valid = true
+		result.Message = "Format validation passed (live validation not implemented)"
+		return nil
+	}
+	return v.ProbeFunc(ctx, client, apiKey, result)
+}
+
+// FullProbe calls FullProbeFunc, or Probe if there is none
+func (v PatternValidator) FullProbe(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error {
+	if v.FullProbeFunc == nil {
+		return v.Probe(ctx, client, apiKey, result)
+	}
+	return v.FullProbeFunc(ctx, client, apiKey, result)
+}
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]ProviderValidator{
+		// OpenAI keys are "sk-" followed by 48 characters, project keys "sk-proj-..." are longer
+		"openai": PatternValidator{
+			Pattern:   regexp.MustCompile(`^sk-[a-zA-Z0-9]{48}$|^sk-proj-[a-zA-Z0-9-_]{43,}$`),
+			ProbeFunc: BearerProbe("https://api.openai.com/v1/models"),
+		},
+		"anthropic": PatternValidator{
+			Pattern:       regexp.MustCompile(`^sk-ant-[a-zA-Z0-9-_]{93,}$`),
+			ProbeFunc:     probeAnthropicModels,
+			FullProbeFunc: probeAnthropicCompletion,
+		},
+		"gemini": geminiValidator,
+		"google": geminiValidator,
+		"xai": PatternValidator{
+			Pattern:   regexp.MustCompile(`^xai-[a-zA-Z0-9]{20,}$`),
+			ProbeFunc: BearerProbe("https://api.x.ai/v1/models"),
+		},
+		// DeepSeek keys are "sk-" followed by 32 hex characters
+		"deepseek": PatternValidator{
+			Pattern:   regexp.MustCompile(`^sk-[a-f0-9]{32}$`),
+			ProbeFunc: BearerProbe("https://api.deepseek.com/models"),
+		},
+	}
+
+	// geminiValidator validates Google AI keys, which start with "AIza"
+	geminiValidator = PatternValidator{
+		Pattern:   regexp.MustCompile(`^AIza[a-zA-Z0-9_-]{35}$`),
+		ProbeFunc: probeGemini,
+	}
+)
+
+// RegisterValidator registers the validator of a provider's keys, e.g.
+// RegisterValidator("mistral", auth.PatternValidator{...}). Provider names are
+// case-insensitive. Registering an existing provider, built-in ones included,
+// replaces its validator. Keys of providers without a validator are only
+// checked for not being blank.
+func RegisterValidator(provider string, validator ProviderValidator) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[strings.ToLower(provider)] = validator
+}
+
+// UnregisterValidator removes the validator of a provider
+func UnregisterValidator(provider string) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	delete(validators, strings.ToLower(provider))
+}
+
+// ValidatedProviders returns the providers with a registered validator, sorted
+func ValidatedProviders() []string {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+
+	names := make([]string, 0, len(validators))
+	for name := range validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// providerValidator returns the validator of provider, a blank check if there is none
+func providerValidator(provider string) ProviderValidator {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+	if validator, exists := validators[strings.ToLower(provider)]; exists {
+		return validator
+	}
+	return PatternValidator{}
+}
+
+// BearerProbe returns a probe for OpenAI-compatible APIs, listing the models
+// at modelsURL with the key as bearer token
+func BearerProbe(modelsURL string) ProbeFunc {
+	return func(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", modelsURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			result.Valid = true
+			result.Message = "Key is valid and active"
+
+			// OpenAI reports the organization of the key
+			if org := resp.Header.Get("openai-organization"); org != "" {
+				result.Metadata["organization"] = org
+			}
+
+		case http.StatusUnauthorized:
+			result.Valid = false
+			result.Message = "Invalid or expired API key"
+
+		case http.StatusTooManyRequests:
+			result.Valid = true
+			result.Message = "Key is valid but rate limited"
+			result.Metadata["rate_limited"] = true
+
+		case http.StatusForbidden:
+			result.Valid = false
+			result.Message = "Key lacks required permissions"
+
+		default:
+			return unexpectedStatus(result, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// probeAnthropicModels validates an Anthropic API key by listing its models, which isn't billed
+func probeAnthropicModels(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.anthropic.com/v1/models?limit=1", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", config.DefaultAnthropicVersion)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Valid = true
+		result.Message = "Key is valid and active"
+
+	case http.StatusUnauthorized:
+		result.Valid = false
+		result.Message = "Invalid or expired API key"
+
+	case http.StatusTooManyRequests:
+		result.Valid = true
+		result.Message = "Key is valid but rate limited"
+		result.Metadata["rate_limited"] = true
+
+	case http.StatusForbidden:
+		result.Valid = false
+		result.Message = "Key lacks required permissions"
+
+	default:
+		return unexpectedStatus(result, resp.StatusCode)
+	}
+	return nil
+}
+
+// probeAnthropicCompletion validates an Anthropic API key with a 1-token
+// completion, which also fails for keys of accounts without credit
+func probeAnthropicCompletion(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error {
+	reqBody := strings.NewReader(`{
+		"model": "claude-3-haiku-20240307",
+		"max_tokens": 1,
+		"messages": [{"role": "user", "content": "Hi"}]
+	}`)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", config.DefaultAnthropicVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Valid = true
+		result.Message = "Key is valid and active"
+
+	case http.StatusUnauthorized:
+		result.Valid = false
+		result.Message = "Invalid or expired API key"
+
+	case http.StatusTooManyRequests:
+		result.Valid = true
+		result.Message = "Key is valid but rate limited"
+		result.Metadata["rate_limited"] = true
+
+	case http.StatusForbidden:
+		result.Valid = false
+		result.Message = "Key lacks required permissions"
+
+	case http.StatusBadRequest:
+		// Bad request might still mean the key is valid
+		result.Valid = true
+		result.Message = "Key appears valid (request format issue)"
+
+	default:
+		return unexpectedStatus(result, resp.StatusCode)
+	}
+	return nil
+}
+
+// probeGemini validates a Google Gemini API key
+func probeGemini(ctx context.Context, client *http.Client, apiKey string, result *ValidationResult) error {
+	// Use the models list endpoint for validation
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://generativelanguage.googleapis.com/v1/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-goog-api-key", apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Valid = true
+		result.Message = "Key is valid and active"
+
+	case http.StatusUnauthorized, http.StatusForbidden:
+		result.Valid = false
+		result.Message = "Invalid or expired API key"
+
+	case http.StatusTooManyRequests:
+		result.Valid = true
+		result.Message = "Key is valid but rate limited"
+		result.Metadata["rate_limited"] = true
+
+	case http.StatusBadRequest:
+		result.Valid = false
+		result.Message = "Invalid request (possibly malformed key)"
+
+	default:
+		return unexpectedStatus(result, resp.StatusCode)
+	}
+	return nil
+}
+
+// unexpectedStatus reports a key invalid for an unexpected status code. Server
+// errors say nothing about the key, so they are returned as an error.
+func unexpectedStatus(result *ValidationResult, statusCode int) error {
+	if statusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code: %d", statusCode)
+	}
+	result.Valid = false
+	result.Message = fmt.Sprintf("Unexpected status code: %d", statusCode)
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package compress shortens long prompts before they are sent to a provider,
+// by pruning low-information text or by having a cheap model summarize it
+package compress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// Defaults of Options
+const (
+	DefaultRatio            = 0.5
+	DefaultMinMessageTokens = 64
+)
+
+// Strategy shortens text to about target tokens of model
+type Strategy interface {
+	Compress(ctx context.Context, text string, target int, model string) (string, error)
+}
+
+// Options configure a Compressor
+type Options struct {
+	// Threshold is the estimated number of prompt tokens above which a
+	// request is compressed
+	Threshold int
+
+	// Ratio is the share of its tokens a compressed message keeps, DefaultRatio by default
+	Ratio float64
+
+	// MinMessageTokens leaves messages shorter than it as they are,
+	// DefaultMinMessageTokens by default
+	MinMessageTokens int
+
+	// Strategy defaults to NewPruner()
+	Strategy Strategy
+}
+
+// Result describes the compression of one request
+type Result struct {
+	Model        string
+	Messages     int // messages that were compressed
+	TokensBefore int
+	TokensAfter  int
+	Err          error // the strategy's error; the request is then sent uncompressed
+}
+
+// TokensSaved returns how many prompt tokens the compression saved
+func (r Result) TokensSaved() int {
+	return r.TokensBefore - r.TokensAfter
+}
+
+// Stats are the totals of a Compressor. Token counts are those of the
+// compressed requests.
+type Stats struct {
+	Requests     int64 `json:"requests"`
+	Compressed   int64 `json:"compressed"`
+	Failed       int64 `json:"failed"`
+	TokensBefore int64 `json:"tokens_before"`
+	TokensAfter  int64 `json:"tokens_after"`
+}
+
+// TokensSaved returns how many prompt tokens compression saved
+func (s Stats) TokensSaved() int64 {
+	return s.TokensBefore - s.TokensAfter
+}
+
+// Compressor compresses the messages of requests exceeding a token threshold
+type Compressor struct {
+	opts       Options
+	onCompress func(ctx context.Context, result Result)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New creates a compressor with opts
+func New(opts Options) *Compressor {
+	if opts.Ratio <= 0 || opts.Ratio >= 1 {
+		opts.Ratio = DefaultRatio
+	}
+	if opts.MinMessageTokens <= 0 {
+		opts.MinMessageTokens = DefaultMinMessageTokens
+	}
+	if opts.Strategy == nil {
+		opts.Strategy = NewPruner()
+	}
+	return &Compressor{opts: opts}
+}
+
+// OnCompress sets a handler called for every request that exceeded the
+// threshold, e.g. to export the tokens saved as metrics
+func (c *Compressor) OnCompress(fn func(ctx context.Context, result Result)) {
+	c.onCompress = fn
+}
+
+// Stats returns the totals of the requests compressed so far
+func (c *Compressor) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Middleware returns middleware that compresses requests before they are
+// sent. If the strategy fails, the request is sent uncompressed. Dry runs
+// are passed through. Install it with UnifiedProvider.Use.
+func (c *Compressor) Middleware() providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+			if opts.DryRun || skipped(ctx) {
+				return next(ctx, messages, opts)
+			}
+			if compressed, _, err := c.Compress(ctx, messages, opts.Model); err == nil {
+				messages = compressed
+			}
+			return next(ctx, messages, opts)
+		}
+	}
+}
+
+// Compress returns a copy of messages whose user, assistant and tool
+// messages are compressed if their estimated tokens for model exceed the
+// threshold. System messages are kept as they are.
+func (c *Compressor) Compress(ctx context.Context, messages []providers.Message, model string) ([]providers.Message, Result, error) {
+	result := Result{Model: model}
+	counts := make([]int, len(messages))
+	for i, msg := range messages {
+		counts[i] = tokenizer.CountTokens(model, msg.Content)
+		result.TokensBefore += counts[i]
+	}
+	result.TokensAfter = result.TokensBefore
+
+	c.mu.Lock()
+	c.stats.Requests++
+	c.mu.Unlock()
+	if result.TokensBefore <= c.opts.Threshold {
+		return messages, result, nil
+	}
+
+	compressed := append([]providers.Message(nil), messages...)
+	for i, msg := range compressed {
+		if msg.Role == "system" || counts[i] < c.opts.MinMessageTokens {
+			continue
+		}
+
+		target := max(int(float64(counts[i])*c.opts.Ratio), 1)
+		content, err := c.opts.Strategy.Compress(ctx, msg.Content, target, model)
+		if err != nil {
+			result.Err = fmt.Errorf("compressing message %d: %w", i, err)
+			result.TokensAfter = result.TokensBefore
+			c.record(ctx, result)
+			return messages, result, result.Err
+		}
+		content = strings.TrimSpace(content)
+		tokens := tokenizer.CountTokens(model, content)
+		if content == "" || tokens >= counts[i] {
+			continue
+		}
+
+		compressed[i].Content = content
+		result.Messages++
+		result.TokensAfter -= counts[i] - tokens
+	}
+	c.record(ctx, result)
+	return compressed, result, nil
+}
+
+// record adds a request that exceeded the threshold to the stats and reports it
+func (c *Compressor) record(ctx context.Context, result Result) {
+	c.mu.Lock()
+	if result.Err != nil {
+		c.stats.Failed++
+	} else if result.Messages > 0 {
+		c.stats.Compressed++
+		c.stats.TokensBefore += int64(result.TokensBefore)
+		c.stats.TokensAfter += int64(result.TokensAfter)
+	}
+	c.mu.Unlock()
+
+	if c.onCompress != nil {
+		c.onCompress(ctx, result)
+	}
+}
+
+// skipKey marks contexts whose requests must not be compressed
+type skipKey struct{}
+
+// WithoutCompression returns a context whose requests bypass compression
+// middleware, e.g. for the summary requests of Summarizer
+func WithoutCompression(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipKey{}, true)
+}
+
+// skipped reports whether ctx bypasses compression
+func skipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipKey{}).(bool)
+	return skip
+}
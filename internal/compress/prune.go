@@ -0,0 +1,267 @@
+package compress
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// stopwords carry no information of their own when sentences are scored
+var stopwords = wordSet(`a about above after again against all am an and any are as at be because been
+before being below between both but by can could did do does doing down during each few for from
+further had has have having he her here hers herself him himself his how i if in into is it its itself
+just me more most my myself no nor not of off on once only or other our ours ourselves out over own
+same she should so some such than that the their theirs them themselves then there these they this
+those through to too under until up very was we were what when where which while who whom why will
+with would you your yours yourself yourselves`)
+
+// fillerWords are dropped from sentences when dropping sentences wasn't enough
+var fillerWords = wordSet(`a an the very really just quite basically actually simply literally totally
+certainly definitely rather somewhat perhaps indeed essentially generally`)
+
+// Pruner compresses text without calling a model, in the spirit of
+// LLMLingua: it drops repeated sentences, then the sentences with the least
+// information, then filler words. The first and last sentence, which
+// usually hold the instruction and the question, and code blocks are kept.
+type Pruner struct{}
+
+// NewPruner creates a pruning strategy
+func NewPruner() *Pruner {
+	return &Pruner{}
+}
+
+// segment is a sentence of the text, or a code block
+type segment struct {
+	text      string
+	sep       string // whitespace following the segment
+	tokens    int
+	score     float64
+	protected bool
+	dropped   bool
+}
+
+// Compress implements Strategy
+func (p *Pruner) Compress(ctx context.Context, text string, target int, model string) (string, error) {
+	segments := splitSegments(text)
+	total := 0
+	for i := range segments {
+		segments[i].tokens = tokenizer.CountTokens(model, segments[i].text)
+		total += segments[i].tokens
+	}
+	if total <= target {
+		return text, nil
+	}
+	scoreSegments(segments)
+
+	candidates := make([]int, 0, len(segments))
+	for i := range segments {
+		if !segments[i].protected {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return segments[candidates[a]].score < segments[candidates[b]].score
+	})
+	for _, i := range candidates {
+		if total <= target {
+			break
+		}
+		segments[i].dropped = true
+		total -= segments[i].tokens
+	}
+
+	if total > target {
+		for i := range segments {
+			if segments[i].dropped || strings.HasPrefix(segments[i].text, "```") {
+				continue
+			}
+			segments[i].text = dropFillers(segments[i].text)
+		}
+	}
+	return joinSegments(segments), nil
+}
+
+// splitSegments splits text into sentences and fenced code blocks. The first
+// and last sentence and the code blocks are protected.
+func splitSegments(text string) []segment {
+	var segments []segment
+	lines := strings.SplitAfter(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			block := line
+			for i+1 < len(lines) {
+				i++
+				block += lines[i]
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+					break
+				}
+			}
+			trimmed := strings.TrimRightFunc(block, unicode.IsSpace)
+			segments = append(segments, segment{
+				text:      strings.TrimLeftFunc(trimmed, unicode.IsSpace),
+				sep:       block[len(trimmed):],
+				protected: true,
+			})
+			continue
+		}
+		segments = append(segments, splitSentences(line)...)
+	}
+
+	first, last := -1, -1
+	for i := range segments {
+		if segments[i].text == "" {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first >= 0 {
+		segments[first].protected = true
+		segments[last].protected = true
+	}
+	return segments
+}
+
+// splitSentences splits a line into sentences ending in ., ! or ? followed by whitespace
+func splitSentences(line string) []segment {
+	var segments []segment
+	start := 0
+	for start < len(line) && isSpace(line[start]) {
+		start++
+	}
+	if start > 0 {
+		// Leading indentation is kept with the line break before it
+		segments = append(segments, segment{sep: line[:start]})
+	}
+
+	for i := start; i < len(line); i++ {
+		if !strings.ContainsRune(".!?", rune(line[i])) {
+			continue
+		}
+		end := i + 1
+		for end < len(line) && strings.ContainsRune(`"')]`, rune(line[end])) {
+			end++
+		}
+		if end < len(line) && !isSpace(line[end]) {
+			continue
+		}
+		next := end
+		for next < len(line) && isSpace(line[next]) {
+			next++
+		}
+		segments = append(segments, segment{text: line[start:end], sep: line[end:next]})
+		start, i = next, next-1
+	}
+	if start < len(line) {
+		trimmed := strings.TrimRightFunc(line[start:], unicode.IsSpace)
+		segments = append(segments, segment{text: trimmed, sep: line[start+len(trimmed):]})
+	}
+	return segments
+}
+
+// scoreSegments scores sentences by their information per word: words that
+// are rare within the text weigh more, numbers more still, and repeated
+// sentences score below all others
+func scoreSegments(segments []segment) {
+	words := make([][]string, len(segments))
+	frequency := make(map[string]int)
+	for i, seg := range segments {
+		seen := make(map[string]bool)
+		for _, word := range splitWords(seg.text) {
+			words[i] = append(words[i], word)
+			if !seen[word] {
+				seen[word] = true
+				frequency[word]++
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	n := float64(len(segments))
+	for i := range segments {
+		key := strings.Join(words[i], " ")
+		if seen[key] {
+			segments[i].score = -1
+			continue
+		}
+		seen[key] = true
+
+		score := 0.0
+		for _, word := range words[i] {
+			if stopwords[word] {
+				continue
+			}
+			score += math.Log(1 + n/float64(frequency[word]))
+			if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+				score++
+			}
+		}
+		segments[i].score = score / float64(max(len(words[i]), 1))
+	}
+}
+
+// joinSegments joins the segments that weren't dropped. Where segments were
+// dropped, the strongest separator among them is kept, so line breaks survive.
+func joinSegments(segments []segment) string {
+	var b strings.Builder
+	sep := ""
+	for _, seg := range segments {
+		switch {
+		case seg.text == "":
+			// Blank lines and indentation
+			sep += seg.sep
+		case seg.dropped:
+			if strings.Count(seg.sep, "\n") > strings.Count(sep, "\n") {
+				sep = seg.sep
+			}
+		default:
+			if b.Len() > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(seg.text)
+			sep = seg.sep
+		}
+	}
+	return b.String()
+}
+
+// dropFillers removes filler words from a sentence, keeping words with
+// punctuation attached
+func dropFillers(sentence string) string {
+	fields := strings.Fields(sentence)
+	kept := fields[:0]
+	for _, field := range fields {
+		if !fillerWords[strings.ToLower(field)] {
+			kept = append(kept, field)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// splitWords returns the lower-cased words of text
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordSet returns the set of the whitespace-separated words
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// isSpace reports whether b is ASCII whitespace
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,49 @@
+package compress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// summarizePrompt asks for a summary of about %d tokens of the text that follows
+const summarizePrompt = `Compress the following text to about %d tokens for another language model to read.
+Keep every fact, number, name, code snippet and instruction it needs to answer; drop repetition, filler and formatting.
+Copy any question or request at the end of the text verbatim.
+Reply with the compressed text only.
+
+%s`
+
+// Summarizer compresses text by having a model, usually a cheap one,
+// summarize it
+type Summarizer struct {
+	provider providers.LLMProvider
+	opts     providers.RequestOptions
+}
+
+// NewSummarizer creates a strategy summarizing with provider and opts, e.g.
+// RequestOptions{Provider: providers.OpenAI, Model: "gpt-4o-mini"}. Summary
+// requests bypass compression middleware installed on provider.
+func NewSummarizer(provider providers.LLMProvider, opts providers.RequestOptions) *Summarizer {
+	return &Summarizer{provider: provider, opts: opts}
+}
+
+// Compress implements Strategy
+func (s *Summarizer) Compress(ctx context.Context, text string, target int, model string) (string, error) {
+	opts := s.opts
+	if opts.MaxTokens == 0 {
+		// Leave room for a summary that overshoots the target a little
+		opts.MaxTokens = target * 2
+	}
+
+	resp, err := s.provider.Invoke(WithoutCompression(ctx), fmt.Sprintf(summarizePrompt, target, text), opts)
+	if err != nil {
+		return "", fmt.Errorf("summarizing: %w", err)
+	}
+	if resp.FinishReason == providers.FinishReasonLength {
+		return "", errors.New("summarizing: summary was cut off")
+	}
+	return resp.Content, nil
+}
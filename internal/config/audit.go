@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// Severity ranks how serious an audit finding is
+type Severity string
+
+const (
+	SeverityHigh   Severity = "high"
+	SeverityMedium Severity = "medium"
+	SeverityLow    Severity = "low"
+)
+
+// rank orders severities from least to most serious
+func (s Severity) rank() int {
+	switch s {
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether s is as serious as other or more
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// broadCostLimit is the per-key daily cost limit above which a limit is considered overly broad
+const broadCostLimit = 1000.0
+
+// Finding is a security problem detected in a configuration
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+}
+
+// Audit inspects a configuration for security problems and returns the
+// findings ordered by severity, most serious first. The configuration should
+// be the file as written, i.e. before environment overrides are applied.
+func Audit(cfg *Config) []Finding {
+	var findings []Finding
+	add := func(severity Severity, path, format string, args ...interface{}) {
+		findings = append(findings, Finding{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !cfg.Global.EncryptKeys {
+		add(SeverityHigh, "global.encrypt_keys", "key encryption is disabled; stored keys are kept in plaintext")
+	} else {
+		add(SeverityHigh, "global.encrypt_keys", "keys are encrypted with the built-in default password")
+	}
+	if !cfg.Global.KeyValidation {
+		add(SeverityLow, "global.key_validation", "key validation is disabled")
+	}
+	if !cfg.Global.AuditLogging {
+		add(SeverityLow, "global.audit_logging", "audit logging is disabled")
+	}
+	if cfg.Global.DailyCostLimit <= 0 {
+		add(SeverityMedium, "global.daily_cost_limit", "no global daily cost limit is set")
+	}
+
+	providerNames := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
+	for _, providerName := range providerNames {
+		provider := cfg.Providers[providerName]
+		for i, key := range provider.APIKeys {
+			path := fmt.Sprintf("providers.%s.api_keys[%d]", providerName, i)
+
+			if key.Key != "" && !key.IsKeyRef() && !HasPlaceholder(key.Key) {
+				if os.Getenv(envKeyName(providerName, key.Name)) != "" {
+					add(SeverityLow, path+".key", "key %q has an inline value that is overridden from the environment; replace it with a placeholder", key.Name)
+				} else {
+					add(SeverityHigh, path+".key", "key %q is stored in plaintext in the config file; use an environment variable or a key store reference", key.Name)
+				}
+			}
+
+			switch {
+			case key.CostLimit <= 0:
+				add(SeverityMedium, path+".cost_limit", "key %q has no cost limit", key.Name)
+			case key.CostLimit > broadCostLimit:
+				add(SeverityLow, path+".cost_limit", "key %q has a broad daily cost limit of $%.2f", key.Name, key.CostLimit)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity.rank() > findings[j].Severity.rank()
+	})
+	return findings
+}
+
+// AuditFile audits the config file at configPath as written, without
+// applying environment overrides or validation
+func AuditFile(configPath string) ([]Finding, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetConfigFile(configPath)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("error unmarshaling config: %w", err)
+	}
+
+	return Audit(&cfg), nil
+}
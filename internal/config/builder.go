@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Builder constructs a Config in code, e.g.
+//
+//	cfg, err := config.New().
+//		Provider("openai").
+//		AddKey("primary", os.Getenv("OPENAI_API_KEY")).
+//		AddModel("gpt-4o-mini", 0.00015, 0.0006).
+//		RotationStrategy(config.RotationRoundRobin).
+//		Build()
+//
+// Key and model methods apply to the provider most recently selected with
+// Provider. Errors are collected and returned by Build.
+type Builder struct {
+	config  Config
+	current string
+	errs    []error
+}
+
+// New creates an empty config builder
+func New() *Builder {
+	return &Builder{
+		config: Config{Providers: make(map[string]ProviderConfig)},
+	}
+}
+
+// Provider selects the provider that following calls configure, adding it if needed
+func (b *Builder) Provider(name string) *Builder {
+	if name == "" {
+		b.errs = append(b.errs, errors.New("provider name cannot be empty"))
+		return b
+	}
+	if _, exists := b.config.Providers[name]; !exists {
+		b.config.Providers[name] = ProviderConfig{}
+	}
+	b.current = name
+	return b
+}
+
+// AddKey adds an enabled API key to the current provider
+func (b *Builder) AddKey(name, key string) *Builder {
+	return b.AddAPIKey(APIKey{Name: name, Key: key, Enabled: true})
+}
+
+// AddAPIKey adds a fully specified API key to the current provider
+func (b *Builder) AddAPIKey(key APIKey) *Builder {
+	return b.update("AddKey", func(p *ProviderConfig) {
+		p.APIKeys = append(p.APIKeys, key)
+	})
+}
+
+// AddModel adds an enabled model with its costs per 1k tokens to the current provider
+func (b *Builder) AddModel(name string, inputCostPer1K, outputCostPer1K float64) *Builder {
+	return b.AddModelConfig(ModelConfig{
+		Name:                  name,
+		InputCostPer1KTokens:  inputCostPer1K,
+		OutputCostPer1KTokens: outputCostPer1K,
+		Enabled:               true,
+	})
+}
+
+// AddModelConfig adds a fully specified model to the current provider
+func (b *Builder) AddModelConfig(model ModelConfig) *Builder {
+	return b.update("AddModel", func(p *ProviderConfig) {
+		p.Models = append(p.Models, model)
+	})
+}
+
+// RotationStrategy sets the key rotation strategy of the current provider
+func (b *Builder) RotationStrategy(strategy RotationStrategy) *Builder {
+	return b.update("RotationStrategy", func(p *ProviderConfig) {
+		p.Rotation.Strategy = strategy
+	})
+}
+
+// Rotation replaces the rotation settings of the current provider
+func (b *Builder) Rotation(rotation RotationConfig) *Builder {
+	return b.update("Rotation", func(p *ProviderConfig) {
+		p.Rotation = rotation
+	})
+}
+
+// Vertex sets the GCP project and location of the current provider, for the vertex provider
+func (b *Builder) Vertex(project, location string) *Builder {
+	return b.update("Vertex", func(p *ProviderConfig) {
+		p.Project = project
+		p.Location = location
+	})
+}
+
+// FallbackChain sets the order in which providers are tried
+func (b *Builder) FallbackChain(providers ...string) *Builder {
+	b.config.Global.FallbackChain = append([]string(nil), providers...)
+	return b
+}
+
+// Tenant sets the quotas of a tenant; use DefaultTenant for tenants without their own entry
+func (b *Builder) Tenant(id string, tenant TenantConfig) *Builder {
+	if b.config.Tenants == nil {
+		b.config.Tenants = make(map[string]TenantConfig)
+	}
+	b.config.Tenants[id] = tenant
+	return b
+}
+
+// ModerationRule adds a guardrail rule
+func (b *Builder) ModerationRule(rule ModerationRule) *Builder {
+	b.config.Moderation.Rules = append(b.config.Moderation.Rules, rule)
+	return b
+}
+
+// LanguageRule adds a locale-specific rule
+func (b *Builder) LanguageRule(rule LanguageRule) *Builder {
+	b.config.Language.Rules = append(b.config.Language.Rules, rule)
+	return b
+}
+
+// VectorStore sets the vector store
+func (b *Builder) VectorStore(store VectorStoreConfig) *Builder {
+	b.config.VectorStore = store
+	return b
+}
+
+// Global modifies the global settings
+func (b *Builder) Global(fn func(g *GlobalConfig)) *Builder {
+	fn(&b.config.Global)
+	return b
+}
+
+// Build validates the configuration the same way LoadConfig does and returns it
+func (b *Builder) Build() (*Config, error) {
+	if len(b.errs) > 0 {
+		return nil, fmt.Errorf("config builder failed: %w", errors.Join(b.errs...))
+	}
+
+	config := b.config.Clone()
+	if err := Validate(config); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	return config, nil
+}
+
+// update applies fn to the current provider, recording an error if none is selected
+func (b *Builder) update(method string, fn func(p *ProviderConfig)) *Builder {
+	provider, exists := b.config.Providers[b.current]
+	if !exists {
+		b.errs = append(b.errs, fmt.Errorf("%s called before Provider", method))
+		return b
+	}
+	fn(&provider)
+	b.config.Providers[b.current] = provider
+	return b
+}
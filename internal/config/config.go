@@ -3,8 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/gollmkit/gollmkit/internal/logging"
 	"github.com/spf13/viper"
 )
 
@@ -17,15 +19,36 @@ const (
 	RotationCostOptimized RotationStrategy = "cost_optimized"
 	RotationRandom        RotationStrategy = "random"
 	RotationSingle        RotationStrategy = "single"
+	RotationWeighted      RotationStrategy = "weighted"
+	RotationPriority      RotationStrategy = "priority"
+
+	// RotationLatencyOptimized routes requests without an explicit provider
+	// to the fastest provider in the fallback chain
+	RotationLatencyOptimized RotationStrategy = "latency_optimized"
 )
 
+// KeyRefPrefix marks an API key value as a reference to a key held in the key
+// store under the key's name, e.g. "keystore:primary", instead of the plaintext key
+const KeyRefPrefix = "keystore:"
+
 // APIKey represents a single API key configuration
 type APIKey struct {
-	Key        string    `yaml:"key" json:"key" mapstructure:"key"`
-	Name       string    `yaml:"name" json:"name" mapstructure:"name"`
-	RateLimit  int       `yaml:"rate_limit" json:"rate_limit" mapstructure:"rate_limit"`
-	CostLimit  float64   `yaml:"cost_limit" json:"cost_limit" mapstructure:"cost_limit"`
-	Enabled    bool      `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+	Key           string   `yaml:"key" json:"key" mapstructure:"key"`
+	Name          string   `yaml:"name" json:"name" mapstructure:"name"`
+	RateLimit     int      `yaml:"rate_limit" json:"rate_limit" mapstructure:"rate_limit"`
+	CostLimit     float64  `yaml:"cost_limit" json:"cost_limit" mapstructure:"cost_limit"`
+	Enabled       bool     `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+	Weight        int      `yaml:"weight" json:"weight" mapstructure:"weight"`                         // relative share for weighted rotation
+	Priority      int      `yaml:"priority" json:"priority" mapstructure:"priority"`                   // tier for priority rotation, higher is used first
+	MaxInFlight   int      `yaml:"max_in_flight" json:"max_in_flight" mapstructure:"max_in_flight"`    // concurrent requests allowed on the key, 0 = unlimited
+	ExpiresAt     string   `yaml:"expires_at" json:"expires_at" mapstructure:"expires_at"`             // RFC 3339 time or date after which the key is refused
+	RotateAfter   string   `yaml:"rotate_after" json:"rotate_after" mapstructure:"rotate_after"`       // RFC 3339 time or date after which the key should be replaced
+	AllowedModels []string `yaml:"allowed_models" json:"allowed_models" mapstructure:"allowed_models"` // models the key may be used with, empty = all
+
+	// Tags label the key, e.g. env: prod or team: search, so requests can
+	// restrict rotation to matching keys with RequestOptions.KeyFilter
+	Tags map[string]string `yaml:"tags" json:"tags" mapstructure:"tags"`
+
 	LastUsed   time.Time `yaml:"-" json:"-"` // runtime-only
 	UsageCount int64     `yaml:"-" json:"-"`
 	CostUsed   float64   `yaml:"-" json:"-"`
@@ -36,6 +59,93 @@ type ProviderConfig struct {
 	APIKeys  []APIKey       `yaml:"api_keys" json:"api_keys" mapstructure:"api_keys"`
 	Models   []ModelConfig  `yaml:"models" json:"models" mapstructure:"models"`
 	Rotation RotationConfig `yaml:"rotation" json:"rotation" mapstructure:"rotation"`
+	Timeout  string         `yaml:"timeout" json:"timeout" mapstructure:"timeout"` // per-request timeout, defaults to global.key_timeout
+
+	// Project and Location select the GCP project and region of the vertex provider
+	Project  string `yaml:"project,omitempty" json:"project,omitempty" mapstructure:"project"`
+	Location string `yaml:"location,omitempty" json:"location,omitempty" mapstructure:"location"`
+
+	// ModelRemaps replace deprecated models with their successors
+	ModelRemaps []ModelRemap `yaml:"model_remaps,omitempty" json:"model_remaps,omitempty" mapstructure:"model_remaps"`
+
+	// SafetySettings are the default Gemini safety thresholds of the gemini and vertex providers
+	SafetySettings []SafetySetting `yaml:"safety_settings,omitempty" json:"safety_settings,omitempty" mapstructure:"safety_settings"`
+
+	// APIVersion and BetaFeatures set the anthropic-version and anthropic-beta
+	// headers of the anthropic provider
+	APIVersion   string   `yaml:"api_version,omitempty" json:"api_version,omitempty" mapstructure:"api_version"`
+	BetaFeatures []string `yaml:"beta_features,omitempty" json:"beta_features,omitempty" mapstructure:"beta_features"`
+
+	// Compression gzips large request bodies sent to the provider
+	Compression CompressionConfig `yaml:"compression,omitempty" json:"compression,omitempty" mapstructure:"compression"`
+}
+
+// Request compression modes, set per provider in compression.requests
+const (
+	CompressionAuto = "auto" // default, gzip for providers known to accept it, i.e. gemini and vertex
+	CompressionGzip = "gzip" // always gzip large request bodies
+	CompressionNone = "none" // never compress request bodies
+)
+
+// DefaultCompressionMinBytes is the smallest request body that is compressed
+const DefaultCompressionMinBytes = 16 << 10
+
+// CompressionConfig controls gzip compression of request bodies. Responses
+// are always requested and decompressed transparently with gzip.
+type CompressionConfig struct {
+	Requests string `yaml:"requests,omitempty" json:"requests,omitempty" mapstructure:"requests"`    // auto, gzip or none
+	MinBytes int    `yaml:"min_bytes,omitempty" json:"min_bytes,omitempty" mapstructure:"min_bytes"` // default 16384
+}
+
+// GetMinBytes returns the smallest request body that is compressed
+func (c *CompressionConfig) GetMinBytes() int {
+	if c.MinBytes <= 0 {
+		return DefaultCompressionMinBytes
+	}
+	return c.MinBytes
+}
+
+// SafetySetting sets the threshold at which Gemini blocks content of a harm
+// category, e.g. HARM_CATEGORY_HARASSMENT and BLOCK_ONLY_HIGH
+type SafetySetting struct {
+	Category  string `yaml:"category" json:"category" mapstructure:"category"`
+	Threshold string `yaml:"threshold" json:"threshold" mapstructure:"threshold"`
+}
+
+// ModelRemap sends requests for a deprecated model to its replacement
+type ModelRemap struct {
+	From string `yaml:"from" json:"from" mapstructure:"from"`
+	To   string `yaml:"to" json:"to" mapstructure:"to"`
+}
+
+// ModelAlias is a logical model name resolved to a concrete model, so
+// application code doesn't hard-code model versions
+type ModelAlias struct {
+	Name     string `yaml:"name" json:"name" mapstructure:"name"`
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty" mapstructure:"provider"` // empty keeps the provider of the request
+	Model    string `yaml:"model" json:"model" mapstructure:"model"`
+}
+
+// DefaultVertexLocation is the Vertex AI region used when none is configured
+const DefaultVertexLocation = "us-central1"
+
+// DefaultAnthropicVersion is the Anthropic API version used when none is configured
+const DefaultAnthropicVersion = "2023-06-01"
+
+// GetAPIVersion returns the configured Anthropic API version, defaulting to DefaultAnthropicVersion
+func (p *ProviderConfig) GetAPIVersion() string {
+	if p.APIVersion == "" {
+		return DefaultAnthropicVersion
+	}
+	return p.APIVersion
+}
+
+// GetLocation returns the configured Vertex AI region, defaulting to DefaultVertexLocation
+func (p *ProviderConfig) GetLocation() string {
+	if p.Location == "" {
+		return DefaultVertexLocation
+	}
+	return p.Location
 }
 
 // GetModelByName returns a model configuration by name
@@ -48,6 +158,35 @@ func (p *ProviderConfig) GetModelByName(name string) (*ModelConfig, error) {
 	return nil, fmt.Errorf("model %s not found or not enabled", name)
 }
 
+// RemapModel follows the remaps of a deprecated model to its current
+// replacement. The second return value is false if model isn't remapped.
+func (p *ProviderConfig) RemapModel(model string) (string, bool) {
+	remapped := false
+	// Bounded so a remap cycle can't loop forever; Validate rejects cycles
+	for i := 0; i < len(p.ModelRemaps); i++ {
+		next := ""
+		for _, remap := range p.ModelRemaps {
+			if remap.From == model {
+				next = remap.To
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		model, remapped = next, true
+	}
+	return model, remapped
+}
+
+// GetTimeout returns the per-request timeout of the provider, falling back to the global key timeout
+func (p *ProviderConfig) GetTimeout(global *GlobalConfig) (time.Duration, error) {
+	if p.Timeout == "" {
+		return global.GetKeyTimeout()
+	}
+	return time.ParseDuration(p.Timeout)
+}
+
 // GetEnabledModels returns a list of enabled models
 func (p *ProviderConfig) GetEnabledModels() []ModelConfig {
 	var enabled []ModelConfig
@@ -64,12 +203,83 @@ func (k *APIKey) IsValid() bool {
 	return k.Enabled && k.Key != "" && k.Name != ""
 }
 
+// IsKeyRef reports whether the key value references a key held in the key store
+func (k *APIKey) IsKeyRef() bool {
+	return strings.HasPrefix(k.Key, KeyRefPrefix)
+}
+
+// GetWeight returns the rotation weight of the key, defaulting to 1
+func (k *APIKey) GetWeight() int {
+	if k.Weight <= 0 {
+		return 1
+	}
+	return k.Weight
+}
+
+// AllowsModel reports whether the key may be used with model
+func (k *APIKey) AllowsModel(model string) bool {
+	if len(k.AllowedModels) == 0 {
+		return true
+	}
+	for _, allowed := range k.AllowedModels {
+		if allowed == model {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTags reports whether the key carries every tag of tags with the same value
+func (k *APIKey) HasTags(tags map[string]string) bool {
+	for name, value := range tags {
+		if tagValue, ok := k.Tags[name]; !ok || tagValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// GetExpiresAt returns the expiry time of the key, zero if it doesn't expire
+func (k *APIKey) GetExpiresAt() (time.Time, error) {
+	return parseKeyTime(k.ExpiresAt)
+}
+
+// GetRotateAfter returns the time after which the key should be rotated, zero if not scheduled
+func (k *APIKey) GetRotateAfter() (time.Time, error) {
+	return parseKeyTime(k.RotateAfter)
+}
+
+// IsExpired reports whether the key has expired at now. Keys with an
+// unparseable expiry are treated as expired.
+func (k *APIKey) IsExpired(now time.Time) bool {
+	expiresAt, err := k.GetExpiresAt()
+	if err != nil {
+		return true
+	}
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// parseKeyTime parses an RFC 3339 time or a date (interpreted as midnight UTC)
+func parseKeyTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
 // CanUse checks if the key can be used based on limits
 func (k *APIKey) CanUse() bool {
 	if !k.IsValid() {
 		return false
 	}
 
+	if k.IsExpired(time.Now()) {
+		return false
+	}
+
 	// Check daily cost limit
 	if k.CostLimit > 0 && k.CostUsed >= k.CostLimit {
 		return false
@@ -85,6 +295,24 @@ type ModelConfig struct {
 	OutputCostPer1KTokens float64 `yaml:"output_cost_per_1k_tokens" json:"output_cost_per_1k_tokens" mapstructure:"output_cost_per_1k_tokens"`
 	MaxTokens             int     `yaml:"max_tokens" json:"max_tokens" mapstructure:"max_tokens"`
 	Enabled               bool    `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+
+	// CachedInputCostPer1KTokens prices prompt tokens read from the provider's
+	// prompt cache, InputCostPer1KTokens if 0
+	CachedInputCostPer1KTokens float64 `yaml:"cached_input_cost_per_1k_tokens,omitempty" json:"cached_input_cost_per_1k_tokens,omitempty" mapstructure:"cached_input_cost_per_1k_tokens"`
+
+	// CostPerImage prices image generation models per generated image
+	CostPerImage float64 `yaml:"cost_per_image,omitempty" json:"cost_per_image,omitempty" mapstructure:"cost_per_image"`
+
+	// CostPerMinute prices transcription models per minute of audio
+	CostPerMinute float64 `yaml:"cost_per_minute,omitempty" json:"cost_per_minute,omitempty" mapstructure:"cost_per_minute"`
+
+	// CostPer1KChars prices text-to-speech models per 1000 input characters
+	CostPer1KChars float64 `yaml:"cost_per_1k_chars,omitempty" json:"cost_per_1k_chars,omitempty" mapstructure:"cost_per_1k_chars"`
+
+	// FIMTemplate formats fill-in-the-middle requests into a raw prompt for
+	// models trained with FIM tokens but served without a suffix parameter,
+	// e.g. "<fim_prefix>{prefix}<fim_suffix>{suffix}<fim_middle>" for StarCoder
+	FIMTemplate string `yaml:"fim_template,omitempty" json:"fim_template,omitempty" mapstructure:"fim_template"`
 }
 
 // CalculateCost calculates the cost for given input/output tokens
@@ -96,10 +324,11 @@ func (m *ModelConfig) CalculateCost(inputTokens, outputTokens int) float64 {
 
 // RotationConfig defines key rotation behavior
 type RotationConfig struct {
-	Strategy        RotationStrategy `yaml:"strategy" json:"strategy"`
-	Interval        string           `yaml:"interval" json:"interval"`
-	HealthCheck     bool             `yaml:"health_check" json:"health_check"`
-	FallbackEnabled bool             `yaml:"fallback_enabled" json:"fallback_enabled"`
+	Strategy        RotationStrategy `yaml:"strategy" json:"strategy" mapstructure:"strategy"`
+	Interval        string           `yaml:"interval" json:"interval" mapstructure:"interval"`
+	HealthCheck     bool             `yaml:"health_check" json:"health_check" mapstructure:"health_check"`
+	FallbackEnabled bool             `yaml:"fallback_enabled" json:"fallback_enabled" mapstructure:"fallback_enabled"`
+	QueueTimeout    string           `yaml:"queue_timeout" json:"queue_timeout" mapstructure:"queue_timeout"` // wait for a free key when all are at max_in_flight, 0 = fail fast
 }
 
 // GetInterval returns the rotation interval as time.Duration
@@ -110,6 +339,15 @@ func (r *RotationConfig) GetInterval() (time.Duration, error) {
 	return time.ParseDuration(r.Interval)
 }
 
+// GetQueueTimeout returns how long to wait for a key with a free in-flight
+// slot, 0 if requests fail right away when every key is saturated
+func (r *RotationConfig) GetQueueTimeout() (time.Duration, error) {
+	if r.QueueTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.QueueTimeout)
+}
+
 // GetEnabledKeys returns only enabled API keys
 func (p *ProviderConfig) GetEnabledKeys() []APIKey {
 	var enabled []APIKey
@@ -123,16 +361,253 @@ func (p *ProviderConfig) GetEnabledKeys() []APIKey {
 
 // GlobalConfig represents global configuration settings
 type GlobalConfig struct {
-	FallbackChain           []string         `yaml:"fallback_chain" json:"fallback_chain"`
-	GlobalRateLimit         int              `yaml:"global_rate_limit" json:"global_rate_limit"`
-	DailyCostLimit          float64          `yaml:"daily_cost_limit" json:"daily_cost_limit"`
-	CostAlertThreshold      float64          `yaml:"cost_alert_threshold" json:"cost_alert_threshold"`
-	EncryptKeys             bool             `yaml:"encrypt_keys" json:"encrypt_keys"`
-	KeyValidation           bool             `yaml:"key_validation" json:"key_validation"`
-	AuditLogging            bool             `yaml:"audit_logging" json:"audit_logging"`
-	DefaultRotationStrategy RotationStrategy `yaml:"default_rotation_strategy" json:"default_rotation_strategy"`
-	HealthCheckInterval     string           `yaml:"health_check_interval" json:"health_check_interval"`
-	KeyTimeout              string           `yaml:"key_timeout" json:"key_timeout"`
+	FallbackChain           []string         `yaml:"fallback_chain" json:"fallback_chain" mapstructure:"fallback_chain"`
+	GlobalRateLimit         int              `yaml:"global_rate_limit" json:"global_rate_limit" mapstructure:"global_rate_limit"`
+	DailyCostLimit          float64          `yaml:"daily_cost_limit" json:"daily_cost_limit" mapstructure:"daily_cost_limit"`
+	CostAlertThreshold      float64          `yaml:"cost_alert_threshold" json:"cost_alert_threshold" mapstructure:"cost_alert_threshold"`
+	EncryptKeys             bool             `yaml:"encrypt_keys" json:"encrypt_keys" mapstructure:"encrypt_keys"`
+	KeyValidation           bool             `yaml:"key_validation" json:"key_validation" mapstructure:"key_validation"`
+	AuditLogging            bool             `yaml:"audit_logging" json:"audit_logging" mapstructure:"audit_logging"`
+	DefaultRotationStrategy RotationStrategy `yaml:"default_rotation_strategy" json:"default_rotation_strategy" mapstructure:"default_rotation_strategy"`
+	HealthCheckInterval     string           `yaml:"health_check_interval" json:"health_check_interval" mapstructure:"health_check_interval"`
+	Validation              ValidationConfig `yaml:"validation" json:"validation" mapstructure:"validation"`
+	KeyTimeout              string           `yaml:"key_timeout" json:"key_timeout" mapstructure:"key_timeout"`
+	ProviderRouting         RotationStrategy `yaml:"provider_routing" json:"provider_routing" mapstructure:"provider_routing"`
+	AnalyticsPath           string           `yaml:"analytics_path" json:"analytics_path" mapstructure:"analytics_path"` // JSONL file for request events
+	StatePath               string           `yaml:"state_path" json:"state_path" mapstructure:"state_path"`             // JSON file with live state for `gollmkit watch`
+	HTTP                    HTTPConfig       `yaml:"http" json:"http" mapstructure:"http"`
+	RequestLimits           RequestLimits    `yaml:"request_limits" json:"request_limits" mapstructure:"request_limits"`
+	KeyStore                KeyStoreConfig   `yaml:"key_store" json:"key_store" mapstructure:"key_store"`
+	ModelAliases            []ModelAlias     `yaml:"model_aliases,omitempty" json:"model_aliases,omitempty" mapstructure:"model_aliases"`
+}
+
+// GetModelAlias returns the alias with the given name
+func (g *GlobalConfig) GetModelAlias(name string) (*ModelAlias, bool) {
+	for _, alias := range g.ModelAliases {
+		if alias.Name == name {
+			return &alias, true
+		}
+	}
+	return nil, false
+}
+
+// Key validation modes
+const (
+	ValidationModeLive   = "live"   // check the format, then probe the provider's API
+	ValidationModeFormat = "format" // only check the format, never call the provider
+)
+
+// Live validation probes, set per provider in global.validation.probes
+const (
+	ValidationProbeNone  = "none"  // only check the format of the provider's keys
+	ValidationProbeCheap = "cheap" // call a non-billable endpoint such as the models list
+	ValidationProbeFull  = "full"  // send a minimal billable request, verifying the key can generate
+)
+
+// ValidationConfig controls how health checks validate keys
+type ValidationConfig struct {
+	Mode                 string `yaml:"mode" json:"mode" mapstructure:"mode"`                                                          // live (default) or format
+	CacheTTL             string `yaml:"cache_ttl" json:"cache_ttl" mapstructure:"cache_ttl"`                                           // how long a result is reused, default 1h, "0" to always probe
+	MaxConcurrentProbes  int    `yaml:"max_concurrent_probes" json:"max_concurrent_probes" mapstructure:"max_concurrent_probes"`       // live probes at once, default 4
+	MaxProbesPerProvider int    `yaml:"max_probes_per_provider" json:"max_probes_per_provider" mapstructure:"max_probes_per_provider"` // live probes at once per provider, default 2
+	Workers              int    `yaml:"workers" json:"workers" mapstructure:"workers"`                                                 // keys validated at once, default 8
+
+	// Probes sets the live probe of each provider, cheap if unset
+	Probes map[string]string `yaml:"probes,omitempty" json:"probes,omitempty" mapstructure:"probes"`
+}
+
+// GetProbe returns the live probe of a provider
+func (v *ValidationConfig) GetProbe(provider string) string {
+	if probe := v.Probes[provider]; probe != "" {
+		return probe
+	}
+	return ValidationProbeCheap
+}
+
+// GetCacheTTL returns how long validation results are reused
+func (v *ValidationConfig) GetCacheTTL() (time.Duration, error) {
+	if v.CacheTTL == "" {
+		return time.Hour, nil // default 1 hour
+	}
+	return time.ParseDuration(v.CacheTTL)
+}
+
+// GetMaxConcurrentProbes returns how many live validation probes may run at once
+func (v *ValidationConfig) GetMaxConcurrentProbes() int {
+	if v.MaxConcurrentProbes <= 0 {
+		return 4
+	}
+	return v.MaxConcurrentProbes
+}
+
+// GetMaxProbesPerProvider returns how many live validation probes may run at once per provider
+func (v *ValidationConfig) GetMaxProbesPerProvider() int {
+	if v.MaxProbesPerProvider <= 0 {
+		return 2
+	}
+	return v.MaxProbesPerProvider
+}
+
+// GetWorkers returns how many keys are validated at once
+func (v *ValidationConfig) GetWorkers() int {
+	if v.Workers <= 0 {
+		return 8
+	}
+	return v.Workers
+}
+
+// Key store types
+const (
+	KeyStoreMemory   = "memory"
+	KeyStoreFile     = "file"
+	KeyStoreKeychain = "keychain"
+)
+
+// KeyStoreConfig selects where API keys and their usage statistics are kept
+type KeyStoreConfig struct {
+	Type          string `yaml:"type" json:"type" mapstructure:"type"`                               // memory (default), file, keychain or a type registered with auth.RegisterKeyStore
+	Path          string `yaml:"path" json:"path" mapstructure:"path"`                               // file of the file key store
+	FlushInterval string `yaml:"flush_interval" json:"flush_interval" mapstructure:"flush_interval"` // how often usage is written, default 5s
+
+	// Usage of requests is queued and written to the store in batches, off
+	// the request path. Requests record usage synchronously while the queue is full.
+	UsageQueueSize     int    `yaml:"usage_queue_size,omitempty" json:"usage_queue_size,omitempty" mapstructure:"usage_queue_size"`             // default 4096
+	UsageBatchSize     int    `yaml:"usage_batch_size,omitempty" json:"usage_batch_size,omitempty" mapstructure:"usage_batch_size"`             // records per write, default 256
+	UsageBatchInterval string `yaml:"usage_batch_interval,omitempty" json:"usage_batch_interval,omitempty" mapstructure:"usage_batch_interval"` // longest wait for a full batch, default 100ms
+
+	// Encryption passphrase of the stored keys, read from an environment
+	// variable or a file instead of the built-in default
+	EncryptionKeyEnv  string `yaml:"encryption_key_env,omitempty" json:"encryption_key_env,omitempty" mapstructure:"encryption_key_env"`
+	EncryptionKeyFile string `yaml:"encryption_key_file,omitempty" json:"encryption_key_file,omitempty" mapstructure:"encryption_key_file"`
+
+	// MasterKey enables envelope encryption of file key stores
+	MasterKey MasterKeyConfig `yaml:"master_key,omitempty" json:"master_key,omitempty" mapstructure:"master_key"`
+
+	// Options holds settings of keychain (service) and custom key store types
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options"`
+}
+
+// Master key types
+const (
+	MasterKeyLocal  = "local"
+	MasterKeyAWSKMS = "aws-kms"
+	MasterKeyGCPKMS = "gcp-kms"
+)
+
+// MasterKeyConfig selects the master key wrapping the data key that encrypts
+// stored API keys
+type MasterKeyConfig struct {
+	Type   string `yaml:"type" json:"type" mapstructure:"type"`                           // local, aws-kms or gcp-kms
+	KeyID  string `yaml:"key_id" json:"key_id" mapstructure:"key_id"`                     // KMS key ARN, alias or resource name
+	Region string `yaml:"region,omitempty" json:"region,omitempty" mapstructure:"region"` // AWS region, default $AWS_REGION
+	Key    string `yaml:"key,omitempty" json:"key,omitempty" mapstructure:"key"`          // base64 32-byte local key, e.g. "${GOLLMKIT_MASTER_KEY}"
+}
+
+// GetFlushInterval returns the usage flush interval as time.Duration, zero if unset
+func (k *KeyStoreConfig) GetFlushInterval() (time.Duration, error) {
+	if k.FlushInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(k.FlushInterval)
+}
+
+// Defaults of the usage queue
+const (
+	DefaultUsageQueueSize     = 4096
+	DefaultUsageBatchSize     = 256
+	DefaultUsageBatchInterval = 100 * time.Millisecond
+)
+
+// GetUsageQueueSize returns the capacity of the usage queue
+func (k *KeyStoreConfig) GetUsageQueueSize() int {
+	if k.UsageQueueSize <= 0 {
+		return DefaultUsageQueueSize
+	}
+	return k.UsageQueueSize
+}
+
+// GetUsageBatchSize returns the number of usage records written at once
+func (k *KeyStoreConfig) GetUsageBatchSize() int {
+	if k.UsageBatchSize <= 0 {
+		return DefaultUsageBatchSize
+	}
+	return k.UsageBatchSize
+}
+
+// GetUsageBatchInterval returns how long queued usage waits for a full batch
+func (k *KeyStoreConfig) GetUsageBatchInterval() (time.Duration, error) {
+	if k.UsageBatchInterval == "" {
+		return DefaultUsageBatchInterval, nil
+	}
+	return time.ParseDuration(k.UsageBatchInterval)
+}
+
+// RequestLimits bounds the size of requests, which are rejected before any
+// provider is called when they exceed a limit. Zero values mean unlimited.
+type RequestLimits struct {
+	MaxPromptBytes     int `yaml:"max_prompt_bytes" json:"max_prompt_bytes" mapstructure:"max_prompt_bytes"`             // total bytes of all message contents or a media prompt
+	MaxMessages        int `yaml:"max_messages" json:"max_messages" mapstructure:"max_messages"`                         // messages per chat request
+	MaxAttachmentBytes int `yaml:"max_attachment_bytes" json:"max_attachment_bytes" mapstructure:"max_attachment_bytes"` // uploaded files such as audio
+}
+
+// HTTPConfig configures the HTTP client used to call providers
+type HTTPConfig struct {
+	Timeout             string `yaml:"timeout" json:"timeout" mapstructure:"timeout"`
+	ProxyURL            string `yaml:"proxy_url" json:"proxy_url" mapstructure:"proxy_url"` // defaults to the HTTPS_PROXY environment variable
+	CAFile              string `yaml:"ca_file" json:"ca_file" mapstructure:"ca_file"`       // PEM bundle trusted in addition to the system roots
+	CertFile            string `yaml:"cert_file" json:"cert_file" mapstructure:"cert_file"` // client certificate for mTLS
+	KeyFile             string `yaml:"key_file" json:"key_file" mapstructure:"key_file"`
+	MaxIdleConnsPerHost int    `yaml:"max_idle_conns_per_host" json:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"`      // default 64
+	MaxConnsPerHost     int    `yaml:"max_conns_per_host,omitempty" json:"max_conns_per_host,omitempty" mapstructure:"max_conns_per_host"` // default 256, requests beyond it wait for a connection
+	IdleConnTimeout     string `yaml:"idle_conn_timeout,omitempty" json:"idle_conn_timeout,omitempty" mapstructure:"idle_conn_timeout"`    // default 90s
+	DisableHTTP2        bool   `yaml:"disable_http2,omitempty" json:"disable_http2,omitempty" mapstructure:"disable_http2"`                // e.g. for proxies that break HTTP/2
+
+	// UserAgent names the application in the User-Agent of provider calls,
+	// e.g. "billing-service/2.3", ahead of gollmkit's own version
+	UserAgent string `yaml:"user_agent,omitempty" json:"user_agent,omitempty" mapstructure:"user_agent"`
+	// Headers are sent with every provider call that doesn't set them itself
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" mapstructure:"headers"`
+}
+
+// GetTimeout returns the timeout of HTTP requests made without a deadline of
+// their own as time.Duration. Provider calls are bounded by their request
+// timeout instead (see ProviderConfig.GetTimeout).
+func (h *HTTPConfig) GetTimeout() (time.Duration, error) {
+	if h.Timeout == "" {
+		return 2 * time.Minute, nil // default 2 minutes, long completions can take a while
+	}
+	return time.ParseDuration(h.Timeout)
+}
+
+// Defaults of the connection pool of each provider
+const (
+	DefaultMaxIdleConnsPerHost = 64
+	DefaultMaxConnsPerHost     = 256
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// GetMaxIdleConnsPerHost returns how many idle connections are kept per host
+func (h *HTTPConfig) GetMaxIdleConnsPerHost() int {
+	if h.MaxIdleConnsPerHost <= 0 {
+		return DefaultMaxIdleConnsPerHost
+	}
+	return h.MaxIdleConnsPerHost
+}
+
+// GetMaxConnsPerHost returns how many connections are opened per host
+func (h *HTTPConfig) GetMaxConnsPerHost() int {
+	if h.MaxConnsPerHost <= 0 {
+		return DefaultMaxConnsPerHost
+	}
+	return h.MaxConnsPerHost
+}
+
+// GetIdleConnTimeout returns how long idle connections are kept as time.Duration
+func (h *HTTPConfig) GetIdleConnTimeout() (time.Duration, error) {
+	if h.IdleConnTimeout == "" {
+		return DefaultIdleConnTimeout, nil
+	}
+	return time.ParseDuration(h.IdleConnTimeout)
 }
 
 // GetHealthCheckInterval returns the health check interval as time.Duration
@@ -155,6 +630,209 @@ func (g *GlobalConfig) GetKeyTimeout() (time.Duration, error) {
 type Config struct {
 	Providers map[string]ProviderConfig `yaml:"providers" json:"providers" mapstructure:"providers"`
 	Global    GlobalConfig              `yaml:"global" json:"global" mapstructure:"global"`
+	Tenants   map[string]TenantConfig   `yaml:"tenants" json:"tenants" mapstructure:"tenants"`
+
+	Moderation ModerationConfig `yaml:"moderation,omitempty" json:"moderation,omitempty" mapstructure:"moderation"`
+
+	Language LanguageConfig `yaml:"language,omitempty" json:"language,omitempty" mapstructure:"language"`
+
+	VectorStore VectorStoreConfig `yaml:"vector_store,omitempty" json:"vector_store,omitempty" mapstructure:"vector_store"`
+}
+
+// DefaultTenant is the tenant whose limits apply to tenants without their own entry
+const DefaultTenant = "default"
+
+// TenantConfig defines the quotas of a tenant. Zero values mean unlimited.
+type TenantConfig struct {
+	DailyCostLimit   float64 `yaml:"daily_cost_limit" json:"daily_cost_limit" mapstructure:"daily_cost_limit"`
+	MonthlyCostLimit float64 `yaml:"monthly_cost_limit" json:"monthly_cost_limit" mapstructure:"monthly_cost_limit"`
+	RateLimit        int     `yaml:"rate_limit" json:"rate_limit" mapstructure:"rate_limit"` // requests per minute
+}
+
+// Moderation rule types
+const (
+	ModerationRegex   = "regex"   // matches any of Patterns as regular expressions
+	ModerationKeyword = "keyword" // matches any of Patterns as case-insensitive whole words
+	ModerationPII     = "pii"     // matches emails, phone numbers, SSNs, card numbers and IP addresses
+	ModerationOpenAI  = "openai"  // OpenAI's moderation endpoint
+)
+
+// Moderation actions
+const (
+	ModerationBlock  = "block"  // reject the request or response with an error
+	ModerationRedact = "redact" // replace the matched text
+	ModerationFlag   = "flag"   // report the match and let the text through
+)
+
+// Moderation stages
+const (
+	ModerationInput  = "input"  // messages sent to the provider
+	ModerationOutput = "output" // responses returned by the provider
+	ModerationBoth   = "both"
+)
+
+// ModerationConfig defines the guardrails applied around chat requests
+type ModerationConfig struct {
+	Rules []ModerationRule `yaml:"rules,omitempty" json:"rules,omitempty" mapstructure:"rules"`
+}
+
+// ModerationRule checks text at a stage and applies an action to matches
+type ModerationRule struct {
+	Name     string   `yaml:"name" json:"name" mapstructure:"name"`
+	Type     string   `yaml:"type" json:"type" mapstructure:"type"`
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty" mapstructure:"patterns"`
+
+	// Category labels matches of regex and keyword rules. For pii and openai
+	// rules it restricts the rule to the listed categories, if set.
+	Categories []string `yaml:"categories,omitempty" json:"categories,omitempty" mapstructure:"categories"`
+
+	Action string `yaml:"action" json:"action" mapstructure:"action"`
+	Stage  string `yaml:"stage,omitempty" json:"stage,omitempty" mapstructure:"stage"` // defaults to both
+}
+
+// LanguageAny matches prompts in every language, including undetected ones
+const LanguageAny = "*"
+
+// LanguageConfig defines locale-specific defaults applied by the language of a prompt
+type LanguageConfig struct {
+	// MinConfidence is the confidence below which a detected language is
+	// ignored, 0.5 if unset
+	MinConfidence float64 `yaml:"min_confidence,omitempty" json:"min_confidence,omitempty" mapstructure:"min_confidence"`
+
+	// Rules are tried in order; the first rule listing the language applies
+	Rules []LanguageRule `yaml:"rules,omitempty" json:"rules,omitempty" mapstructure:"rules"`
+}
+
+// LanguageRule applies a system instruction and routing to prompts in one of Languages
+type LanguageRule struct {
+	Languages    []string `yaml:"languages" json:"languages" mapstructure:"languages"` // ISO 639-1 codes or LanguageAny
+	SystemPrompt string   `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty" mapstructure:"system_prompt"`
+	Provider     string   `yaml:"provider,omitempty" json:"provider,omitempty" mapstructure:"provider"`
+	Model        string   `yaml:"model,omitempty" json:"model,omitempty" mapstructure:"model"`
+}
+
+// Vector store types
+const (
+	VectorStoreMemory   = "memory"
+	VectorStorePGVector = "pgvector"
+	VectorStoreQdrant   = "qdrant"
+)
+
+// Defaults of the vector store
+const (
+	DefaultVectorCollection = "gollmkit"
+	DefaultSQLDriver        = "pgx"
+)
+
+// VectorStoreConfig selects the store of embeddings backing semantic caching
+// and retrieval
+type VectorStoreConfig struct {
+	Type       string `yaml:"type" json:"type" mapstructure:"type"`                                       // memory (default), pgvector, qdrant or a type registered with vectorstore.Register
+	Dimensions int    `yaml:"dimensions,omitempty" json:"dimensions,omitempty" mapstructure:"dimensions"` // length of the vectors, required to create pgvector tables and qdrant collections
+	Collection string `yaml:"collection,omitempty" json:"collection,omitempty" mapstructure:"collection"` // qdrant collection or pgvector table, default gollmkit
+
+	DSN    string `yaml:"dsn,omitempty" json:"dsn,omitempty" mapstructure:"dsn"`          // pgvector connection string, e.g. "${DATABASE_URL}"
+	Driver string `yaml:"driver,omitempty" json:"driver,omitempty" mapstructure:"driver"` // database/sql driver of pgvector, default pgx
+
+	URL    string `yaml:"url,omitempty" json:"url,omitempty" mapstructure:"url"`             // qdrant REST endpoint, e.g. http://localhost:6333
+	APIKey string `yaml:"api_key,omitempty" json:"api_key,omitempty" mapstructure:"api_key"` // qdrant API key, e.g. "${QDRANT_API_KEY}"
+
+	// Options holds settings of custom vector store types
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options"`
+}
+
+// GetCollection returns the collection or table of the vector store
+func (v *VectorStoreConfig) GetCollection() string {
+	if v.Collection == "" {
+		return DefaultVectorCollection
+	}
+	return v.Collection
+}
+
+// GetDriver returns the database/sql driver of pgvector stores
+func (v *VectorStoreConfig) GetDriver() string {
+	if v.Driver == "" {
+		return DefaultSQLDriver
+	}
+	return v.Driver
+}
+
+// Clone returns a deep copy of the configuration's providers, tenants and global settings
+func (c *Config) Clone() *Config {
+	clone := &Config{
+		Providers: make(map[string]ProviderConfig, len(c.Providers)),
+		Global:    c.Global,
+	}
+	clone.Global.FallbackChain = append([]string(nil), c.Global.FallbackChain...)
+	clone.Global.ModelAliases = append([]ModelAlias(nil), c.Global.ModelAliases...)
+	if c.Global.KeyStore.Options != nil {
+		clone.Global.KeyStore.Options = make(map[string]string, len(c.Global.KeyStore.Options))
+		for name, value := range c.Global.KeyStore.Options {
+			clone.Global.KeyStore.Options[name] = value
+		}
+	}
+	if c.Global.HTTP.Headers != nil {
+		clone.Global.HTTP.Headers = make(map[string]string, len(c.Global.HTTP.Headers))
+		for name, value := range c.Global.HTTP.Headers {
+			clone.Global.HTTP.Headers[name] = value
+		}
+	}
+	if c.Global.Validation.Probes != nil {
+		clone.Global.Validation.Probes = make(map[string]string, len(c.Global.Validation.Probes))
+		for name, probe := range c.Global.Validation.Probes {
+			clone.Global.Validation.Probes[name] = probe
+		}
+	}
+	for name, provider := range c.Providers {
+		provider.APIKeys = append([]APIKey(nil), provider.APIKeys...)
+		for i, key := range provider.APIKeys {
+			provider.APIKeys[i].AllowedModels = append([]string(nil), key.AllowedModels...)
+			if key.Tags != nil {
+				provider.APIKeys[i].Tags = make(map[string]string, len(key.Tags))
+				for tag, value := range key.Tags {
+					provider.APIKeys[i].Tags[tag] = value
+				}
+			}
+		}
+		provider.Models = append([]ModelConfig(nil), provider.Models...)
+		provider.ModelRemaps = append([]ModelRemap(nil), provider.ModelRemaps...)
+		provider.SafetySettings = append([]SafetySetting(nil), provider.SafetySettings...)
+		provider.BetaFeatures = append([]string(nil), provider.BetaFeatures...)
+		clone.Providers[name] = provider
+	}
+	if c.Tenants != nil {
+		clone.Tenants = make(map[string]TenantConfig, len(c.Tenants))
+		for id, tenant := range c.Tenants {
+			clone.Tenants[id] = tenant
+		}
+	}
+	for _, rule := range c.Moderation.Rules {
+		rule.Patterns = append([]string(nil), rule.Patterns...)
+		rule.Categories = append([]string(nil), rule.Categories...)
+		clone.Moderation.Rules = append(clone.Moderation.Rules, rule)
+	}
+	clone.Language = c.Language
+	clone.Language.Rules = nil
+	for _, rule := range c.Language.Rules {
+		rule.Languages = append([]string(nil), rule.Languages...)
+		clone.Language.Rules = append(clone.Language.Rules, rule)
+	}
+	clone.VectorStore = c.VectorStore
+	if c.VectorStore.Options != nil {
+		clone.VectorStore.Options = make(map[string]string, len(c.VectorStore.Options))
+		for name, value := range c.VectorStore.Options {
+			clone.VectorStore.Options[name] = value
+		}
+	}
+	return clone
+}
+
+// GetTenantLimits returns the quotas of a tenant, falling back to the DefaultTenant entry
+func (c *Config) GetTenantLimits(tenantID string) TenantConfig {
+	if tenant, exists := c.Tenants[tenantID]; exists {
+		return tenant
+	}
+	return c.Tenants[DefaultTenant]
 }
 
 // GetProvider returns a provider configuration by name
@@ -166,125 +844,108 @@ func (c *Config) GetProvider(name string) (*ProviderConfig, error) {
 	return &provider, nil
 }
 
-// LoadConfig loads configuration from a YAML file
+// LoadConfig loads configuration from a YAML file. Warnings, such as unknown
+// fields, are logged to slog.Default(); see LoadConfigWithLogger.
 func LoadConfig(configPath string) (*Config, error) {
-	// Set up viper
-	viper.SetConfigType("yaml")
+	return LoadConfigWithLogger(configPath, nil)
+}
+
+// LoadConfigWithLogger loads configuration from a YAML file like LoadConfig,
+// logging warnings to logger
+func LoadConfigWithLogger(configPath string, logger logging.Logger) (*Config, error) {
+	// Set up a dedicated viper instance so concurrent loads (e.g. hot reload) don't share state
+	v := viper.New()
+	v.SetConfigType("yaml")
 
 	if configPath != "" {
-		viper.SetConfigFile(configPath)
+		v.SetConfigFile(configPath)
 	} else {
 		// Look for config in common locations
-		viper.SetConfigName("gollmkit-config")
-		viper.AddConfigPath(".")
-		viper.AddConfigPath("$HOME/.gollmkit")
-		viper.AddConfigPath("/etc/gollmkit")
+		v.SetConfigName("gollmkit-config")
+		v.AddConfigPath(".")
+		v.AddConfigPath("$HOME/.gollmkit")
+		v.AddConfigPath("/etc/gollmkit")
 	}
 
 	// Allow environment variable overrides
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("GOLLMKIT")
+	v.AutomaticEnv()
+	v.SetEnvPrefix("GOLLMKIT")
 
 	// Read configuration
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			return nil, fmt.Errorf("config file not found: %w", err)
 		}
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
+	// Expand ${VAR} and ${VAR:-default} placeholders
+	settings, err := expandSettings(v.AllSettings())
+	if err != nil {
+		return nil, fmt.Errorf("config interpolation failed: %w", err)
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("config interpolation failed: %w", err)
+	}
+
+	// Unknown fields are most likely typos; they are ignored but worth a warning
+	for _, path := range UnknownFields(settings) {
+		logging.OrDefault(logger).Warn("gollmkit: config: unknown field is ignored", "field", path)
+	}
+
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
-	// Load secrets from env (before validation)
+	// Load secrets from legacy GOLLM_<provider>_API_KEY_<name> variables (before validation)
 	config.LoadFromEnvironment()
 
 	// Validate after environment substitution
-	if err := validateConfig(&config); err != nil {
+	if err := Validate(&config); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
 
 	return &config, nil
 }
 
-// validateConfig performs basic validation on the configuration
-func validateConfig(config *Config) error {
-	if len(config.Providers) == 0 {
-		return fmt.Errorf("at least one provider must be configured")
-	}
-
-	for providerName, provider := range config.Providers {
-
-		if len(provider.APIKeys) == 0 {
-			return fmt.Errorf("provider %s must have at least one API key", providerName)
-		}
-
-		if len(provider.Models) == 0 {
-			return fmt.Errorf("provider %s must have at least one model", providerName)
-		}
-
-		// Validate API keys
-		enabledKeyCount := 0
-		for i, key := range provider.APIKeys {
-			if key.Key == "" {
-				return fmt.Errorf("provider %s: API key %d has empty key", providerName, i)
-			}
-			if key.Name == "" {
-				return fmt.Errorf("provider %s: API key %d has empty name", providerName, i)
-			}
-			if key.Enabled {
-				enabledKeyCount++
-			}
-		}
-
-		if enabledKeyCount == 0 {
-			return fmt.Errorf("provider %s must have at least one enabled API key", providerName)
-		}
-
-		// Validate models
-		enabledModelCount := 0
-		for i, model := range provider.Models {
-			if model.Name == "" {
-				return fmt.Errorf("provider %s: model %d has empty name", providerName, i)
-			}
-			if model.Enabled {
-				enabledModelCount++
-			}
-		}
-
-		if enabledModelCount == 0 {
-			return fmt.Errorf("provider %s must have at least one enabled model", providerName)
-		}
-	}
-
-	return nil
-}
-
 // SaveConfig saves the configuration to a file
 func (c *Config) SaveConfig(configPath string) error {
-	viper.SetConfigFile(configPath)
+	v := viper.New()
+	v.SetConfigFile(configPath)
 
 	// Set the config values
-	viper.Set("providers", c.Providers)
-	viper.Set("global", c.Global)
+	v.Set("providers", c.Providers)
+	v.Set("global", c.Global)
+	if len(c.Tenants) > 0 {
+		v.Set("tenants", c.Tenants)
+	}
+	if len(c.Moderation.Rules) > 0 {
+		v.Set("moderation", c.Moderation)
+	}
+	if len(c.Language.Rules) > 0 {
+		v.Set("language", c.Language)
+	}
+	if c.VectorStore.Type != "" {
+		v.Set("vector_store", c.VectorStore)
+	}
 
-	return viper.WriteConfig()
+	return v.WriteConfig()
 }
 
 // LoadFromEnvironment loads sensitive values from environment variables
 func (c *Config) LoadFromEnvironment() {
 	for providerName, provider := range c.Providers {
 		for i, key := range provider.APIKeys {
-			envKey := fmt.Sprintf("GOLLM_%s_API_KEY_%s",
-				fmt.Sprintf("%s", providerName),
-				fmt.Sprintf("%s", key.Name))
-
-			if envValue := os.Getenv(envKey); envValue != "" {
+			if envValue := os.Getenv(envKeyName(providerName, key.Name)); envValue != "" {
 				provider.APIKeys[i].Key = envValue
 			}
 		}
 		c.Providers[providerName] = provider
 	}
 }
+
+// envKeyName returns the environment variable that overrides an API key
+func envKeyName(providerName, keyName string) string {
+	return fmt.Sprintf("GOLLM_%s_API_KEY_%s", providerName, keyName)
+}
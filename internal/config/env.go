@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern matches ${VAR} and ${VAR:-default} placeholders, and
+// placeholders escaped as $${VAR}
+var placeholderPattern = regexp.MustCompile(`\$(\$)?\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// HasPlaceholder reports whether s contains an environment placeholder
+func HasPlaceholder(s string) bool {
+	for _, groups := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		if groups[1] == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// expandPlaceholders replaces ${VAR} and ${VAR:-default} placeholders in s
+// with environment values and returns the names of variables that are unset
+// and have no default. Escaped placeholders such as $${VAR} are kept as ${VAR}.
+func expandPlaceholders(s string) (string, []string) {
+	var missing []string
+	expanded := placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := placeholderPattern.FindStringSubmatch(match)
+		if groups[1] != "" {
+			return match[1:]
+		}
+		name, hasDefault, fallback := groups[2], groups[3] != "", groups[4]
+
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			return value
+		}
+		if hasDefault {
+			return fallback
+		}
+		missing = append(missing, name)
+		return match
+	})
+	return expanded, missing
+}
+
+// expandSettings recursively expands placeholders in every string value of
+// parsed configuration settings
+func expandSettings(settings map[string]interface{}) (map[string]interface{}, error) {
+	var problems []string
+	expanded := expandValue(settings, "", &problems).(map[string]interface{})
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("undefined environment variables: %s", strings.Join(problems, "; "))
+	}
+	return expanded, nil
+}
+
+// expandValue expands placeholders in value, recording missing variables with their config path
+func expandValue(value interface{}, path string, problems *[]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		expanded, missing := expandPlaceholders(v)
+		for _, name := range missing {
+			*problems = append(*problems, fmt.Sprintf("%s references unset variable %s", path, name))
+		}
+		return expanded
+
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = expandValue(item, joinPath(path, key), problems)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = expandValue(item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+		return result
+
+	default:
+		return value
+	}
+}
+
+// joinPath appends a key to a dotted config path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
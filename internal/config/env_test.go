@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/logging"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	t.Setenv("GOLLMKIT_TEST_KEY", "sk-test")
+	t.Setenv("GOLLMKIT_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		missing []string
+	}{
+		{"no placeholder", "sk-literal", "sk-literal", nil},
+		{"variable", "${GOLLMKIT_TEST_KEY}", "sk-test", nil},
+		{"embedded", "Bearer ${GOLLMKIT_TEST_KEY}!", "Bearer sk-test!", nil},
+		{"default unused", "${GOLLMKIT_TEST_KEY:-fallback}", "sk-test", nil},
+		{"default", "${GOLLMKIT_TEST_UNSET:-fallback}", "fallback", nil},
+		{"empty default", "${GOLLMKIT_TEST_UNSET:-}", "", nil},
+		{"default for empty variable", "${GOLLMKIT_TEST_EMPTY:-fallback}", "fallback", nil},
+		{"default with punctuation", "${GOLLMKIT_TEST_UNSET:-http://localhost:6333/a-b}", "http://localhost:6333/a-b", nil},
+		{"unset", "${GOLLMKIT_TEST_UNSET}", "${GOLLMKIT_TEST_UNSET}", []string{"GOLLMKIT_TEST_UNSET"}},
+		{"empty without default", "${GOLLMKIT_TEST_EMPTY}", "${GOLLMKIT_TEST_EMPTY}", []string{"GOLLMKIT_TEST_EMPTY"}},
+		{"several", "${GOLLMKIT_TEST_A}-${GOLLMKIT_TEST_KEY}-${GOLLMKIT_TEST_B}", "${GOLLMKIT_TEST_A}-sk-test-${GOLLMKIT_TEST_B}", []string{"GOLLMKIT_TEST_A", "GOLLMKIT_TEST_B"}},
+		{"escaped", "$${GOLLMKIT_TEST_KEY}", "${GOLLMKIT_TEST_KEY}", nil},
+		{"escaped unset", "$${GOLLMKIT_TEST_UNSET}", "${GOLLMKIT_TEST_UNSET}", nil},
+		{"escaped default", "$${GOLLMKIT_TEST_UNSET:-x}", "${GOLLMKIT_TEST_UNSET:-x}", nil},
+		{"escaped next to variable", "$${A}${GOLLMKIT_TEST_KEY}", "${A}sk-test", nil},
+		{"dollars without braces", "pa$$word $HOME", "pa$$word $HOME", nil},
+		{"invalid name", "${1VAR} ${}", "${1VAR} ${}", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := expandPlaceholders(tt.value)
+			if got != tt.want {
+				t.Errorf("expanded %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("missing %v, want %v", missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestHasPlaceholder(t *testing.T) {
+	tests := map[string]bool{
+		"sk-literal":        false,
+		"${VAR}":            true,
+		"${VAR:-default}":   true,
+		"prefix ${VAR}":     true,
+		"$${VAR}":           false,
+		"$${VAR} and ${B}":  true,
+		"$VAR":              false,
+		"${not a variable}": false,
+	}
+	for value, want := range tests {
+		if got := HasPlaceholder(value); got != want {
+			t.Errorf("HasPlaceholder(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+// TestExpandSettings expands nested maps and lists and reports every unset
+// variable with its path
+func TestExpandSettings(t *testing.T) {
+	t.Setenv("GOLLMKIT_TEST_KEY", "sk-test")
+
+	settings := map[string]interface{}{
+		"providers": map[string]interface{}{
+			"openai": map[string]interface{}{
+				"api_keys": []interface{}{
+					map[string]interface{}{"key": "${GOLLMKIT_TEST_KEY}", "rate_limit": 60},
+					map[string]interface{}{"key": "${GOLLMKIT_TEST_UNSET:-sk-default}", "enabled": true},
+				},
+			},
+		},
+		"vector_store": map[string]interface{}{"dsn": "$${DATABASE_URL}"},
+	}
+	expanded, err := expandSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"providers": map[string]interface{}{
+			"openai": map[string]interface{}{
+				"api_keys": []interface{}{
+					map[string]interface{}{"key": "sk-test", "rate_limit": 60},
+					map[string]interface{}{"key": "sk-default", "enabled": true},
+				},
+			},
+		},
+		"vector_store": map[string]interface{}{"dsn": "${DATABASE_URL}"},
+	}
+	if !reflect.DeepEqual(expanded, want) {
+		t.Errorf("expanded %v, want %v", expanded, want)
+	}
+
+	_, err = expandSettings(map[string]interface{}{
+		"providers": map[string]interface{}{
+			"openai": map[string]interface{}{
+				"api_keys": []interface{}{map[string]interface{}{"key": "${GOLLMKIT_TEST_B}"}},
+			},
+		},
+		"vector_store": map[string]interface{}{"url": "${GOLLMKIT_TEST_A}"},
+	})
+	wantErr := "undefined environment variables: " +
+		"providers.openai.api_keys[0].key references unset variable GOLLMKIT_TEST_B; " +
+		"vector_store.url references unset variable GOLLMKIT_TEST_A"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("error %v, want %s", err, wantErr)
+	}
+}
+
+// TestLoadConfigPlaceholders expands placeholders when a configuration file
+// is loaded, and fails on unset variables without a default
+func TestLoadConfigPlaceholders(t *testing.T) {
+	t.Setenv("GOLLMKIT_TEST_KEY", "sk-test")
+	path := filepath.Join(t.TempDir(), "gollmkit-config.yaml")
+	write := func(key string) {
+		t.Helper()
+		data := "providers:\n  openai:\n    api_keys:\n      - name: primary\n        key: \"" + key + "\"\n        enabled: true\n" +
+			"    models:\n      - name: gpt-4o\n        enabled: true\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("${GOLLMKIT_TEST_KEY}")
+	cfg, err := LoadConfigWithLogger(path, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := cfg.Providers["openai"].APIKeys[0].Key; key != "sk-test" {
+		t.Errorf("key %q, want sk-test", key)
+	}
+
+	write("${GOLLMKIT_TEST_UNSET}")
+	_, err = LoadConfigWithLogger(path, logging.Discard())
+	if err == nil || !strings.Contains(err.Error(), "providers.openai.api_keys[0].key references unset variable GOLLMKIT_TEST_UNSET") {
+		t.Errorf("error %v, want the unset variable", err)
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RawKey is an API key entry as written in a configuration file
+type RawKey struct {
+	Provider string // lower-cased, as LoadConfig does
+	Name     string
+	Key      string // the value as written, e.g. a ${VAR} placeholder
+}
+
+// ConfigFile edits the API keys of a YAML configuration file in place. Unlike
+// LoadConfig and SaveConfig it works on the file as written: placeholders
+// aren't expanded, environment overrides aren't applied, and everything but
+// the edited key entries, comments included, is left as it was.
+type ConfigFile struct {
+	path string
+	doc  yaml.Node
+}
+
+// OpenConfigFile parses the YAML configuration file at path for editing
+func OpenConfigFile(path string) (*ConfigFile, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("cannot edit %s: only YAML config files are supported", path)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	f := &ConfigFile{path: path}
+	if err := yaml.Unmarshal(raw, &f.doc); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+	if f.doc.Kind == 0 {
+		// Empty file
+		f.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if len(f.doc.Content) == 0 || f.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("error parsing config file: top level is not a mapping")
+	}
+	return f, nil
+}
+
+// Keys returns the API key entries of every provider, in file order
+func (f *ConfigFile) Keys() []RawKey {
+	var keys []RawKey
+	providers := mappingValue(f.doc.Content[0], "providers")
+	if providers == nil || providers.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(providers.Content); i += 2 {
+		provider := strings.ToLower(providers.Content[i].Value)
+		apiKeys := mappingValue(providers.Content[i+1], "api_keys")
+		if apiKeys == nil || apiKeys.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, entry := range apiKeys.Content {
+			keys = append(keys, RawKey{
+				Provider: provider,
+				Name:     scalarValue(mappingValue(entry, "name")),
+				Key:      scalarValue(mappingValue(entry, "key")),
+			})
+		}
+	}
+	return keys
+}
+
+// SetKeyField sets one field of the named key of a provider, e.g. "key" or
+// "enabled", leaving its other fields untouched
+func (f *ConfigFile) SetKeyField(provider, keyName, field string, value interface{}) error {
+	entry := f.keyEntry(provider, keyName)
+	if entry == nil {
+		return fmt.Errorf("key %s not found for provider %s in %s", keyName, provider, f.path)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode %s of key %s: %w", field, keyName, err)
+	}
+	setMappingValue(entry, field, &node)
+	return nil
+}
+
+// AddKey adds a key entry to a provider, replacing an entry of the same name.
+// Fields left at their zero value are omitted, except the key and enabled.
+func (f *ConfigFile) AddKey(provider string, key APIKey) error {
+	var entry, zero yaml.Node
+	if err := entry.Encode(key); err != nil {
+		return fmt.Errorf("failed to encode key %s: %w", key.Name, err)
+	}
+	if err := zero.Encode(APIKey{}); err != nil {
+		return fmt.Errorf("failed to encode key %s: %w", key.Name, err)
+	}
+
+	kept := entry.Content[:0]
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		field, value := entry.Content[i], entry.Content[i+1]
+		switch field.Value {
+		case "key", "name", "enabled":
+		default:
+			if isZeroNode(value, mappingValue(&zero, field.Value)) {
+				continue
+			}
+		}
+		kept = append(kept, field, value)
+	}
+	entry.Content = kept
+
+	providerNode := f.providerNode(provider, true)
+	apiKeys := mappingValue(providerNode, "api_keys")
+	if apiKeys == nil || apiKeys.Kind != yaml.SequenceNode {
+		apiKeys = &yaml.Node{Kind: yaml.SequenceNode}
+		setMappingValue(providerNode, "api_keys", apiKeys)
+	}
+	for i, existing := range apiKeys.Content {
+		if scalarValue(mappingValue(existing, "name")) == key.Name {
+			apiKeys.Content[i] = &entry
+			return nil
+		}
+	}
+	apiKeys.Content = append(apiKeys.Content, &entry)
+	return nil
+}
+
+// RemoveKey removes the named key of a provider, if the file has it
+func (f *ConfigFile) RemoveKey(provider, keyName string) {
+	apiKeys := mappingValue(f.providerNode(provider, false), "api_keys")
+	if apiKeys == nil {
+		return
+	}
+	for i, entry := range apiKeys.Content {
+		if scalarValue(mappingValue(entry, "name")) == keyName {
+			apiKeys.Content = append(apiKeys.Content[:i], apiKeys.Content[i+1:]...)
+			return
+		}
+	}
+}
+
+// Save writes the file back. It is replaced atomically and keeps its permissions.
+func (f *ConfigFile) Save() error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&f.doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// providerNode returns the mapping of a provider, matched case-insensitively,
+// creating it if create is set
+func (f *ConfigFile) providerNode(provider string, create bool) *yaml.Node {
+	root := f.doc.Content[0]
+	providers := mappingValue(root, "providers")
+	if providers == nil || providers.Kind != yaml.MappingNode {
+		if !create {
+			return nil
+		}
+		providers = &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(root, "providers", providers)
+	}
+	for i := 0; i+1 < len(providers.Content); i += 2 {
+		if strings.EqualFold(providers.Content[i].Value, provider) {
+			return providers.Content[i+1]
+		}
+	}
+	if !create {
+		return nil
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(providers, provider, node)
+	return node
+}
+
+// keyEntry returns the mapping of the named key of a provider, or nil
+func (f *ConfigFile) keyEntry(provider, keyName string) *yaml.Node {
+	apiKeys := mappingValue(f.providerNode(provider, false), "api_keys")
+	if apiKeys == nil {
+		return nil
+	}
+	for _, entry := range apiKeys.Content {
+		if scalarValue(mappingValue(entry, "name")) == keyName {
+			return entry
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets the value of key in a mapping node, keeping the
+// comments and quoting of a value it replaces
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			old := node.Content[i+1]
+			if value.Kind == yaml.ScalarNode && old.Kind == yaml.ScalarNode && value.Tag == old.Tag {
+				value.Style = old.Style
+			}
+			value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// scalarValue returns the value of a scalar node, or "" for other nodes
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// isZeroNode reports whether value encodes the same as the zero value node
+func isZeroNode(value, zero *yaml.Node) bool {
+	if zero == nil {
+		return false
+	}
+	if value.Kind != zero.Kind {
+		return false
+	}
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return value.Value == zero.Value
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(value.Content) == 0
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestConfigFilePreservesPlaceholders edits key entries and writes the file
+// back without expanding the placeholders or dropping the comments of the
+// entries it doesn't touch
+func TestConfigFilePreservesPlaceholders(t *testing.T) {
+	t.Setenv("OPENAI_KEY_PRIMARY", "sk-expanded")
+	path := filepath.Join(t.TempDir(), "gollmkit-config.yaml")
+	original := `# Keys are read from the environment
+providers:
+  openai:
+    api_keys:
+      - name: primary
+        key: "${OPENAI_KEY_PRIMARY}" # set by the deployment
+        enabled: true
+      - name: backup
+        key: "${OPENAI_KEY_BACKUP:-sk-proj-placeholder}"
+        enabled: true
+    models:
+      - name: gpt-4o
+        enabled: true
+vector_store:
+  type: pgvector
+  dsn: "${DATABASE_URL}"
+`
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := OpenConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKeys := []RawKey{
+		{Provider: "openai", Name: "primary", Key: "${OPENAI_KEY_PRIMARY}"},
+		{Provider: "openai", Name: "backup", Key: "${OPENAI_KEY_BACKUP:-sk-proj-placeholder}"},
+	}
+	if keys := file.Keys(); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys %+v, want %+v", keys, wantKeys)
+	}
+
+	if err := file.SetKeyField("openai", "backup", "enabled", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AddKey("anthropic", APIKey{Name: "primary", Key: "${ANTHROPIC_KEY}", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Keys are read from the environment
+providers:
+  openai:
+    api_keys:
+      - name: primary
+        key: "${OPENAI_KEY_PRIMARY}" # set by the deployment
+        enabled: true
+      - name: backup
+        key: "${OPENAI_KEY_BACKUP:-sk-proj-placeholder}"
+        enabled: false
+    models:
+      - name: gpt-4o
+        enabled: true
+  anthropic:
+    api_keys:
+      - key: ${ANTHROPIC_KEY}
+        name: primary
+        enabled: true
+vector_store:
+  type: pgvector
+  dsn: "${DATABASE_URL}"
+`
+	if string(saved) != want {
+		t.Errorf("saved:\n%s\nwant:\n%s", saved, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("mode %v, want 0600", mode)
+	}
+}
@@ -0,0 +1,684 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyRotationStrategies lists the strategies supported for selecting API keys
+var keyRotationStrategies = []RotationStrategy{
+	RotationRoundRobin,
+	RotationLeastUsed,
+	RotationCostOptimized,
+	RotationRandom,
+	RotationSingle,
+	RotationWeighted,
+	RotationPriority,
+}
+
+var (
+	customStrategiesMu sync.RWMutex
+	customStrategies   = make(map[RotationStrategy]bool) // registered by auth.RegisterRotationStrategy
+)
+
+// AllowRotationStrategy makes Validate accept a custom key rotation strategy.
+// It is called by auth.RegisterRotationStrategy.
+func AllowRotationStrategy(name RotationStrategy) {
+	customStrategiesMu.Lock()
+	defer customStrategiesMu.Unlock()
+	customStrategies[name] = true
+}
+
+// DisallowRotationStrategy reverts AllowRotationStrategy
+func DisallowRotationStrategy(name RotationStrategy) {
+	customStrategiesMu.Lock()
+	defer customStrategiesMu.Unlock()
+	delete(customStrategies, name)
+}
+
+// rotationStrategies returns the built-in and custom key rotation strategies
+func rotationStrategies() []RotationStrategy {
+	customStrategiesMu.RLock()
+	defer customStrategiesMu.RUnlock()
+
+	strategies := append([]RotationStrategy(nil), keyRotationStrategies...)
+	custom := make([]RotationStrategy, 0, len(customStrategies))
+	for name := range customStrategies {
+		custom = append(custom, name)
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i] < custom[j] })
+	return append(strategies, custom...)
+}
+
+// providerRoutingStrategies lists the strategies supported for global.provider_routing
+var providerRoutingStrategies = []RotationStrategy{
+	RotationLatencyOptimized,
+}
+
+// FieldError is a problem with a single configuration value
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Error implements error
+func (e FieldError) Error() string {
+	return e.Path + " " + e.Message
+}
+
+// ValidationError holds every problem found in a configuration
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Error implements error, listing one problem per line
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		lines[i] = fieldErr.Error()
+	}
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	return fmt.Sprintf("%d problems:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// validator collects field errors
+type validator struct {
+	errs []FieldError
+}
+
+// addf records a problem at path
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// nonNegative records a problem if value is negative
+func (v *validator) nonNegative(path string, value float64) {
+	if value < 0 {
+		v.addf(path, "must be >= 0")
+	}
+}
+
+// duration records a problem if value is set but not a valid positive duration
+func (v *validator) duration(path, value string) {
+	if value == "" {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.addf(path, "must be a duration such as \"30s\" or \"5m\", got %q", value)
+		return
+	}
+	if d <= 0 {
+		v.addf(path, "must be a positive duration, got %q", value)
+	}
+}
+
+// keyTime records a problem if value is set but not an RFC 3339 time or date
+func (v *validator) keyTime(path, value string) {
+	if _, err := parseKeyTime(value); err != nil {
+		v.addf(path, "must be an RFC 3339 time or a date such as \"2025-12-31\", got %q", value)
+	}
+}
+
+// strategy records a problem if value is set but not one of allowed
+func (v *validator) strategy(path string, value RotationStrategy, allowed []RotationStrategy) {
+	if value == "" {
+		return
+	}
+	names := make([]string, len(allowed))
+	for i, s := range allowed {
+		if s == value {
+			return
+		}
+		names[i] = string(s)
+	}
+	v.addf(path, "must be one of %s, got %q", strings.Join(names, ", "), value)
+}
+
+// Validate checks a configuration and reports every problem at once as a
+// *ValidationError whose entries carry YAML paths such as
+// providers.openai.api_keys[1].rate_limit
+func Validate(cfg *Config) error {
+	v := &validator{}
+
+	if len(cfg.Providers) == 0 {
+		v.addf("providers", "must configure at least one provider")
+	}
+
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v.provider("providers."+name, cfg.Providers[name])
+	}
+	if vertex, exists := cfg.Providers["vertex"]; exists && vertex.Project == "" {
+		v.addf("providers.vertex.project", "must be set to the GCP project ID")
+	}
+	for _, name := range names {
+		if name != "gemini" && name != "vertex" && len(cfg.Providers[name].SafetySettings) > 0 {
+			v.addf("providers."+name+".safety_settings", "are only supported by gemini and vertex")
+		}
+		if name != "anthropic" && cfg.Providers[name].APIVersion != "" {
+			v.addf("providers."+name+".api_version", "is only supported by anthropic")
+		}
+		if name != "anthropic" && len(cfg.Providers[name].BetaFeatures) > 0 {
+			v.addf("providers."+name+".beta_features", "are only supported by anthropic")
+		}
+	}
+	if anthropic, exists := cfg.Providers["anthropic"]; exists {
+		v.anthropicHeaders("providers.anthropic", anthropic)
+	}
+	v.global("global", cfg)
+
+	tenants := make([]string, 0, len(cfg.Tenants))
+	for id := range cfg.Tenants {
+		tenants = append(tenants, id)
+	}
+	sort.Strings(tenants)
+	for _, id := range tenants {
+		tenantCfg := cfg.Tenants[id]
+		path := "tenants." + id
+		v.nonNegative(path+".daily_cost_limit", tenantCfg.DailyCostLimit)
+		v.nonNegative(path+".monthly_cost_limit", tenantCfg.MonthlyCostLimit)
+		v.nonNegative(path+".rate_limit", float64(tenantCfg.RateLimit))
+	}
+
+	v.moderation("moderation", cfg.Moderation)
+	v.language("language", cfg)
+	v.vectorStore("vector_store", cfg.VectorStore)
+
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+// provider validates a single provider
+func (v *validator) provider(path string, provider ProviderConfig) {
+	if len(provider.APIKeys) == 0 {
+		v.addf(path+".api_keys", "must contain at least one API key")
+	}
+
+	modelNames := make(map[string]bool, len(provider.Models))
+	for _, model := range provider.Models {
+		modelNames[model.Name] = true
+	}
+
+	enabledKeys := 0
+	keyNames := make(map[string]bool)
+	for i, key := range provider.APIKeys {
+		keyPath := fmt.Sprintf("%s.api_keys[%d]", path, i)
+		if key.Key == "" {
+			v.addf(keyPath+".key", "must not be empty")
+		}
+		if key.Name == "" {
+			v.addf(keyPath+".name", "must not be empty")
+		} else if keyNames[key.Name] {
+			v.addf(keyPath+".name", "duplicates key name %q", key.Name)
+		}
+		keyNames[key.Name] = true
+		v.nonNegative(keyPath+".rate_limit", float64(key.RateLimit))
+		v.nonNegative(keyPath+".cost_limit", key.CostLimit)
+		v.nonNegative(keyPath+".weight", float64(key.Weight))
+		v.nonNegative(keyPath+".max_in_flight", float64(key.MaxInFlight))
+		v.keyTime(keyPath+".expires_at", key.ExpiresAt)
+		v.keyTime(keyPath+".rotate_after", key.RotateAfter)
+		if _, ok := key.Tags[""]; ok {
+			v.addf(keyPath+".tags", "must not contain an empty tag name")
+		}
+		for j, model := range key.AllowedModels {
+			if !modelNames[model] {
+				v.addf(fmt.Sprintf("%s.allowed_models[%d]", keyPath, j), "names no configured model: %q", model)
+			}
+		}
+		if key.Enabled {
+			enabledKeys++
+		}
+	}
+	if len(provider.APIKeys) > 0 && enabledKeys == 0 {
+		v.addf(path+".api_keys", "must contain at least one enabled API key")
+	}
+
+	if len(provider.Models) == 0 {
+		v.addf(path+".models", "must contain at least one model")
+	}
+
+	enabledModels := 0
+	for i, model := range provider.Models {
+		modelPath := fmt.Sprintf("%s.models[%d]", path, i)
+		if model.Name == "" {
+			v.addf(modelPath+".name", "must not be empty")
+		}
+		v.nonNegative(modelPath+".input_cost_per_1k_tokens", model.InputCostPer1KTokens)
+		v.nonNegative(modelPath+".output_cost_per_1k_tokens", model.OutputCostPer1KTokens)
+		v.nonNegative(modelPath+".cached_input_cost_per_1k_tokens", model.CachedInputCostPer1KTokens)
+		v.nonNegative(modelPath+".max_tokens", float64(model.MaxTokens))
+		v.nonNegative(modelPath+".cost_per_image", model.CostPerImage)
+		v.nonNegative(modelPath+".cost_per_minute", model.CostPerMinute)
+		v.nonNegative(modelPath+".cost_per_1k_chars", model.CostPer1KChars)
+		if model.FIMTemplate != "" && (!strings.Contains(model.FIMTemplate, "{prefix}") || !strings.Contains(model.FIMTemplate, "{suffix}")) {
+			v.addf(modelPath+".fim_template", "must contain {prefix} and {suffix}")
+		}
+		if model.Enabled {
+			enabledModels++
+		}
+	}
+	if len(provider.Models) > 0 && enabledModels == 0 {
+		v.addf(path+".models", "must contain at least one enabled model")
+	}
+
+	v.strategy(path+".rotation.strategy", provider.Rotation.Strategy, rotationStrategies())
+	v.duration(path+".rotation.interval", provider.Rotation.Interval)
+	v.duration(path+".rotation.queue_timeout", provider.Rotation.QueueTimeout)
+	v.duration(path+".timeout", provider.Timeout)
+	v.modelRemaps(path+".model_remaps", provider)
+	v.safetySettings(path+".safety_settings", provider.SafetySettings)
+	v.compression(path+".compression", provider.Compression)
+}
+
+// compression validates the request compression settings of a provider
+func (v *validator) compression(path string, compression CompressionConfig) {
+	switch compression.Requests {
+	case "", CompressionAuto, CompressionGzip, CompressionNone:
+	default:
+		v.addf(path+".requests", "must be one of %s, %s, %s, got %q", CompressionAuto, CompressionGzip, CompressionNone, compression.Requests)
+	}
+	v.nonNegative(path+".min_bytes", float64(compression.MinBytes))
+}
+
+// validation validates the key validation settings
+func (v *validator) validation(path string, validation ValidationConfig) {
+	switch validation.Mode {
+	case "", ValidationModeLive, ValidationModeFormat:
+	default:
+		v.addf(path+".mode", "must be one of %s, %s, got %q", ValidationModeLive, ValidationModeFormat, validation.Mode)
+	}
+	if ttl, err := validation.GetCacheTTL(); err != nil || ttl < 0 {
+		v.addf(path+".cache_ttl", "must be a duration such as \"30m\" or \"0\", got %q", validation.CacheTTL)
+	}
+	v.nonNegative(path+".max_concurrent_probes", float64(validation.MaxConcurrentProbes))
+	v.nonNegative(path+".max_probes_per_provider", float64(validation.MaxProbesPerProvider))
+	v.nonNegative(path+".workers", float64(validation.Workers))
+	providers := make([]string, 0, len(validation.Probes))
+	for provider := range validation.Probes {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		switch probe := validation.Probes[provider]; probe {
+		case ValidationProbeNone, ValidationProbeCheap, ValidationProbeFull:
+		default:
+			v.addf(path+".probes."+provider, "must be one of %s, %s, %s, got %q",
+				ValidationProbeNone, ValidationProbeCheap, ValidationProbeFull, probe)
+		}
+	}
+}
+
+// apiVersionPattern matches dated API versions such as 2023-06-01
+var apiVersionPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// anthropicHeaders validates the API version and beta features of the anthropic provider
+func (v *validator) anthropicHeaders(path string, provider ProviderConfig) {
+	if provider.APIVersion != "" && !apiVersionPattern.MatchString(provider.APIVersion) {
+		v.addf(path+".api_version", "must be a date such as %s, got %q", DefaultAnthropicVersion, provider.APIVersion)
+	}
+	for i, feature := range provider.BetaFeatures {
+		if strings.TrimSpace(feature) == "" || strings.Contains(feature, ",") {
+			v.addf(fmt.Sprintf("%s.beta_features[%d]", path, i), "must be a single feature name, got %q", feature)
+		}
+	}
+}
+
+// safetyThresholds lists the Gemini harm block thresholds
+var safetyThresholds = map[string]bool{
+	"HARM_BLOCK_THRESHOLD_UNSPECIFIED": true,
+	"BLOCK_LOW_AND_ABOVE":              true,
+	"BLOCK_MEDIUM_AND_ABOVE":           true,
+	"BLOCK_ONLY_HIGH":                  true,
+	"BLOCK_NONE":                       true,
+	"OFF":                              true,
+}
+
+// safetySettings validates the Gemini safety settings of a provider
+func (v *validator) safetySettings(path string, settings []SafetySetting) {
+	categories := make(map[string]bool)
+	for i, setting := range settings {
+		settingPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case setting.Category == "":
+			v.addf(settingPath+".category", "must not be empty")
+		case !strings.HasPrefix(setting.Category, "HARM_CATEGORY_"):
+			v.addf(settingPath+".category", "unknown harm category %q", setting.Category)
+		case categories[setting.Category]:
+			v.addf(settingPath+".category", "duplicates category %q", setting.Category)
+		}
+		categories[setting.Category] = true
+		if !safetyThresholds[setting.Threshold] {
+			v.addf(settingPath+".threshold", "unknown threshold %q", setting.Threshold)
+		}
+	}
+}
+
+// modelRemaps validates the deprecation remaps of a provider
+func (v *validator) modelRemaps(path string, provider ProviderConfig) {
+	from := make(map[string]string)
+	for i, remap := range provider.ModelRemaps {
+		remapPath := fmt.Sprintf("%s[%d]", path, i)
+		if remap.From == "" {
+			v.addf(remapPath+".from", "must not be empty")
+		} else if _, exists := from[remap.From]; exists {
+			v.addf(remapPath+".from", "duplicates remap of %q", remap.From)
+		}
+		if remap.To == "" {
+			v.addf(remapPath+".to", "must not be empty")
+		}
+		from[remap.From] = remap.To
+	}
+
+	for i, remap := range provider.ModelRemaps {
+		seen := map[string]bool{remap.From: true}
+		for model := remap.To; model != ""; model = from[model] {
+			if seen[model] {
+				v.addf(fmt.Sprintf("%s[%d]", path, i), "remaps %q in a cycle", remap.From)
+				break
+			}
+			seen[model] = true
+		}
+	}
+}
+
+// modelAliases validates the model aliases
+func (v *validator) modelAliases(path string, cfg *Config) {
+	names := make(map[string]bool)
+	for i, alias := range cfg.Global.ModelAliases {
+		aliasPath := fmt.Sprintf("%s[%d]", path, i)
+		if alias.Name == "" {
+			v.addf(aliasPath+".name", "must not be empty")
+		} else if names[alias.Name] {
+			v.addf(aliasPath+".name", "duplicates alias %q", alias.Name)
+		}
+		names[alias.Name] = true
+		if alias.Model == "" {
+			v.addf(aliasPath+".model", "must not be empty")
+		}
+		if _, exists := cfg.Providers[alias.Provider]; alias.Provider != "" && !exists {
+			v.addf(aliasPath+".provider", "references unknown provider %q", alias.Provider)
+		}
+	}
+}
+
+// global validates the global settings
+func (v *validator) global(path string, cfg *Config) {
+	global := cfg.Global
+
+	for i, name := range global.FallbackChain {
+		if _, exists := cfg.Providers[name]; !exists {
+			v.addf(fmt.Sprintf("%s.fallback_chain[%d]", path, i), "references unknown provider %q", name)
+		}
+	}
+
+	v.nonNegative(path+".global_rate_limit", float64(global.GlobalRateLimit))
+	v.nonNegative(path+".daily_cost_limit", global.DailyCostLimit)
+	if global.CostAlertThreshold < 0 || global.CostAlertThreshold > 1 {
+		v.addf(path+".cost_alert_threshold", "must be between 0 and 1")
+	}
+
+	v.strategy(path+".default_rotation_strategy", global.DefaultRotationStrategy, rotationStrategies())
+	v.strategy(path+".provider_routing", global.ProviderRouting, providerRoutingStrategies)
+	v.duration(path+".health_check_interval", global.HealthCheckInterval)
+	v.validation(path+".validation", global.Validation)
+	v.duration(path+".key_timeout", global.KeyTimeout)
+	v.http(path+".http", global.HTTP)
+	v.nonNegative(path+".request_limits.max_prompt_bytes", float64(global.RequestLimits.MaxPromptBytes))
+	v.nonNegative(path+".request_limits.max_messages", float64(global.RequestLimits.MaxMessages))
+	v.nonNegative(path+".request_limits.max_attachment_bytes", float64(global.RequestLimits.MaxAttachmentBytes))
+
+	// Other types may be registered at runtime and are checked when the store is created
+	if global.KeyStore.Type == KeyStoreFile && global.KeyStore.Path == "" {
+		v.addf(path+".key_store.path", "must be set for file key stores")
+	}
+	v.duration(path+".key_store.flush_interval", global.KeyStore.FlushInterval)
+	v.nonNegative(path+".key_store.usage_queue_size", float64(global.KeyStore.UsageQueueSize))
+	v.nonNegative(path+".key_store.usage_batch_size", float64(global.KeyStore.UsageBatchSize))
+	v.duration(path+".key_store.usage_batch_interval", global.KeyStore.UsageBatchInterval)
+	if global.KeyStore.EncryptionKeyEnv != "" && global.KeyStore.EncryptionKeyFile != "" {
+		v.addf(path+".key_store.encryption_key_file", "must not be set together with encryption_key_env")
+	}
+	v.masterKey(path+".key_store", global.KeyStore)
+	v.modelAliases(path+".model_aliases", cfg)
+}
+
+// masterKey validates the master key of a key store
+func (v *validator) masterKey(path string, keyStore KeyStoreConfig) {
+	master := keyStore.MasterKey
+	if master.Type == "" {
+		return
+	}
+	path += ".master_key"
+	if keyStore.Type != KeyStoreFile {
+		v.addf(path, "is only supported by file key stores")
+	}
+
+	switch master.Type {
+	case MasterKeyLocal:
+		if key, err := base64.StdEncoding.DecodeString(master.Key); err != nil || len(key) != 32 {
+			v.addf(path+".key", "must be a base64-encoded 32-byte key")
+		}
+	case MasterKeyAWSKMS, MasterKeyGCPKMS:
+		if master.KeyID == "" {
+			v.addf(path+".key_id", "must be set for %s master keys", master.Type)
+		}
+	default:
+		v.addf(path+".type", "must be one of local, aws-kms, gcp-kms, got %q", master.Type)
+	}
+}
+
+// moderation validates the moderation rules
+func (v *validator) moderation(path string, moderation ModerationConfig) {
+	for i, rule := range moderation.Rules {
+		rulePath := fmt.Sprintf("%s.rules[%d]", path, i)
+		if rule.Name == "" {
+			v.addf(rulePath+".name", "must not be empty")
+		}
+
+		switch rule.Type {
+		case ModerationRegex, ModerationKeyword:
+			if len(rule.Patterns) == 0 {
+				v.addf(rulePath+".patterns", "must contain at least one pattern for %s rules", rule.Type)
+			}
+		case ModerationPII, ModerationOpenAI:
+		default:
+			v.addf(rulePath+".type", "must be one of regex, keyword, pii, openai, got %q", rule.Type)
+		}
+		if rule.Type == ModerationRegex {
+			for j, pattern := range rule.Patterns {
+				if _, err := regexp.Compile(pattern); err != nil {
+					v.addf(fmt.Sprintf("%s.patterns[%d]", rulePath, j), "must be a valid regular expression: %v", err)
+				}
+			}
+		}
+
+		switch rule.Action {
+		case ModerationBlock, ModerationRedact, ModerationFlag:
+		default:
+			v.addf(rulePath+".action", "must be one of block, redact, flag, got %q", rule.Action)
+		}
+		switch rule.Stage {
+		case "", ModerationInput, ModerationOutput, ModerationBoth:
+		default:
+			v.addf(rulePath+".stage", "must be one of input, output, both, got %q", rule.Stage)
+		}
+	}
+}
+
+// languageCodePattern matches ISO 639-1 and 639-3 language codes
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// language validates the locale-specific rules
+func (v *validator) language(path string, cfg *Config) {
+	language := cfg.Language
+	if language.MinConfidence < 0 || language.MinConfidence > 1 {
+		v.addf(path+".min_confidence", "must be between 0 and 1")
+	}
+	for i, rule := range language.Rules {
+		rulePath := fmt.Sprintf("%s.rules[%d]", path, i)
+		if len(rule.Languages) == 0 {
+			v.addf(rulePath+".languages", "must contain at least one language")
+		}
+		for j, code := range rule.Languages {
+			if code != LanguageAny && !languageCodePattern.MatchString(code) {
+				v.addf(fmt.Sprintf("%s.languages[%d]", rulePath, j), "must be a lower-case ISO 639 code or %q, got %q", LanguageAny, code)
+			}
+		}
+		if rule.SystemPrompt == "" && rule.Provider == "" && rule.Model == "" {
+			v.addf(rulePath, "must set system_prompt, provider or model")
+		}
+		if _, exists := cfg.Providers[rule.Provider]; rule.Provider != "" && !exists {
+			v.addf(rulePath+".provider", "references unknown provider %q", rule.Provider)
+		}
+	}
+}
+
+// sqlIdentifierPattern matches unquoted SQL table names, optionally schema-qualified
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// vectorStore validates the vector store settings. Custom types are checked
+// when the store is created, as they are registered at runtime.
+func (v *validator) vectorStore(path string, store VectorStoreConfig) {
+	v.nonNegative(path+".dimensions", float64(store.Dimensions))
+	switch store.Type {
+	case VectorStorePGVector:
+		if store.DSN == "" {
+			v.addf(path+".dsn", "must not be empty for pgvector stores")
+		}
+		if !sqlIdentifierPattern.MatchString(store.GetCollection()) {
+			v.addf(path+".collection", "must be a valid table name for pgvector stores, got %q", store.Collection)
+		}
+	case VectorStoreQdrant:
+		if store.URL == "" {
+			v.addf(path+".url", "must not be empty for qdrant stores")
+		} else if u, err := url.Parse(store.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			v.addf(path+".url", "must be an absolute URL, got %q", store.URL)
+		}
+	}
+}
+
+// http validates the HTTP client settings
+func (v *validator) http(path string, http HTTPConfig) {
+	v.duration(path+".timeout", http.Timeout)
+	v.nonNegative(path+".max_idle_conns_per_host", float64(http.MaxIdleConnsPerHost))
+	v.nonNegative(path+".max_conns_per_host", float64(http.MaxConnsPerHost))
+	v.duration(path+".idle_conn_timeout", http.IdleConnTimeout)
+
+	if http.ProxyURL != "" {
+		if u, err := url.Parse(http.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			v.addf(path+".proxy_url", "must be an absolute URL such as \"http://proxy:3128\", got %q", http.ProxyURL)
+		}
+	}
+	if (http.CertFile == "") != (http.KeyFile == "") {
+		v.addf(path, "cert_file and key_file must be set together")
+	}
+
+	names := make([]string, 0, len(http.Headers))
+	for name := range http.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case !headerNamePattern.MatchString(name):
+			v.addf(path+".headers."+name, "is not a valid header name")
+		case credentialHeaders[strings.ToLower(name)]:
+			v.addf(path+".headers."+name, "carries credentials and is set from the configured keys")
+		case strings.EqualFold(name, "User-Agent"):
+			v.addf(path+".headers."+name, "is set with user_agent")
+		}
+	}
+}
+
+// headerNamePattern matches valid HTTP header names
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// credentialHeaders are the lower-cased headers carrying provider credentials
+var credentialHeaders = map[string]bool{
+	"authorization":  true,
+	"x-api-key":      true,
+	"x-goog-api-key": true,
+}
+
+// UnknownFields returns the paths of settings that don't correspond to any
+// configuration field, e.g. misspelled keys that would otherwise be ignored
+func UnknownFields(settings map[string]interface{}) []string {
+	var unknown []string
+	collectUnknown("", settings, reflect.TypeOf(Config{}), &unknown)
+	sort.Strings(unknown)
+	return unknown
+}
+
+// collectUnknown walks value alongside the struct type t and appends unknown paths
+func collectUnknown(path string, value interface{}, t reflect.Type, unknown *[]string) {
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := settingsMap(value)
+		if fields == nil {
+			return
+		}
+		known := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			known[tag] = field.Type
+		}
+		for key, fieldValue := range fields {
+			fieldPath := joinPath(path, key)
+			fieldType, exists := known[strings.ToLower(key)]
+			if !exists {
+				*unknown = append(*unknown, fieldPath)
+				continue
+			}
+			collectUnknown(fieldPath, fieldValue, fieldType, unknown)
+		}
+	case reflect.Map:
+		for key, entry := range settingsMap(value) {
+			collectUnknown(joinPath(path, key), entry, t.Elem(), unknown)
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			collectUnknown(fmt.Sprintf("%s[%d]", path, i), item, t.Elem(), unknown)
+		}
+	}
+}
+
+// settingsMap converts the map types produced by YAML decoding to map[string]interface{}
+func settingsMap(value interface{}) map[string]interface{} {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(m))
+		for key, entry := range m {
+			result[fmt.Sprint(key)] = entry
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validConfig returns a configuration without problems
+func validConfig() *Config {
+	return &Config{
+		Providers: map[string]ProviderConfig{
+			"openai": {
+				APIKeys: []APIKey{{Key: "sk-1", Name: "primary", Enabled: true}},
+				Models:  []ModelConfig{{Name: "gpt-4o", Enabled: true}},
+			},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name:   "no providers",
+			modify: func(cfg *Config) { cfg.Providers = nil },
+			want:   []FieldError{{"providers", "must configure at least one provider"}},
+		},
+		{
+			name: "empty provider",
+			modify: func(cfg *Config) {
+				cfg.Providers["anthropic"] = ProviderConfig{}
+			},
+			want: []FieldError{
+				{"providers.anthropic.api_keys", "must contain at least one API key"},
+				{"providers.anthropic.models", "must contain at least one model"},
+			},
+		},
+		{
+			name: "key fields",
+			modify: func(cfg *Config) {
+				cfg.Providers["openai"] = ProviderConfig{
+					APIKeys: []APIKey{
+						{Key: "sk-1", Name: "primary", RateLimit: -1, Weight: -2},
+						{Name: "primary", ExpiresAt: "next week", AllowedModels: []string{"gpt-4o", "gpt-5"}},
+						{Key: "sk-3", Tags: map[string]string{"": "prod"}},
+					},
+					Models: []ModelConfig{{Name: "gpt-4o", Enabled: true}},
+				}
+			},
+			want: []FieldError{
+				{"providers.openai.api_keys[0].rate_limit", "must be >= 0"},
+				{"providers.openai.api_keys[0].weight", "must be >= 0"},
+				{"providers.openai.api_keys[1].key", "must not be empty"},
+				{"providers.openai.api_keys[1].name", `duplicates key name "primary"`},
+				{"providers.openai.api_keys[1].expires_at", `must be an RFC 3339 time or a date such as "2025-12-31", got "next week"`},
+				{"providers.openai.api_keys[1].allowed_models[1]", `names no configured model: "gpt-5"`},
+				{"providers.openai.api_keys[2].name", "must not be empty"},
+				{"providers.openai.api_keys[2].tags", "must not contain an empty tag name"},
+				{"providers.openai.api_keys", "must contain at least one enabled API key"},
+			},
+		},
+		{
+			name: "model fields",
+			modify: func(cfg *Config) {
+				provider := cfg.Providers["openai"]
+				provider.Models = []ModelConfig{
+					{Name: "", InputCostPer1KTokens: -0.5},
+					{Name: "codestral", FIMTemplate: "{prefix}<fim>"},
+				}
+				cfg.Providers["openai"] = provider
+			},
+			want: []FieldError{
+				{"providers.openai.models[0].name", "must not be empty"},
+				{"providers.openai.models[0].input_cost_per_1k_tokens", "must be >= 0"},
+				{"providers.openai.models[1].fim_template", "must contain {prefix} and {suffix}"},
+				{"providers.openai.models", "must contain at least one enabled model"},
+			},
+		},
+		{
+			name: "rotation, timeout and compression",
+			modify: func(cfg *Config) {
+				provider := cfg.Providers["openai"]
+				provider.Rotation = RotationConfig{Interval: "often", QueueTimeout: "-5s"}
+				provider.Timeout = "0s"
+				provider.Compression = CompressionConfig{Requests: "brotli", MinBytes: -1}
+				cfg.Providers["openai"] = provider
+			},
+			want: []FieldError{
+				{"providers.openai.rotation.interval", `must be a duration such as "30s" or "5m", got "often"`},
+				{"providers.openai.rotation.queue_timeout", `must be a positive duration, got "-5s"`},
+				{"providers.openai.timeout", `must be a positive duration, got "0s"`},
+				{"providers.openai.compression.requests", `must be one of auto, gzip, none, got "brotli"`},
+				{"providers.openai.compression.min_bytes", "must be >= 0"},
+			},
+		},
+		{
+			name: "model remaps",
+			modify: func(cfg *Config) {
+				provider := cfg.Providers["openai"]
+				provider.ModelRemaps = []ModelRemap{
+					{From: "a", To: "b"},
+					{From: "b", To: "a"},
+					{},
+					{From: "x", To: "y"},
+					{From: "x", To: "z"},
+				}
+				cfg.Providers["openai"] = provider
+			},
+			want: []FieldError{
+				{"providers.openai.model_remaps[2].from", "must not be empty"},
+				{"providers.openai.model_remaps[2].to", "must not be empty"},
+				{"providers.openai.model_remaps[4].from", `duplicates remap of "x"`},
+				{"providers.openai.model_remaps[0]", `remaps "a" in a cycle`},
+				{"providers.openai.model_remaps[1]", `remaps "b" in a cycle`},
+			},
+		},
+		{
+			name: "safety settings outside gemini",
+			modify: func(cfg *Config) {
+				provider := cfg.Providers["openai"]
+				provider.SafetySettings = []SafetySetting{
+					{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"},
+					{Category: "HARASSMENT", Threshold: "BLOCK_SOME"},
+					{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "OFF"},
+				}
+				provider.APIVersion = "2023-06-01"
+				cfg.Providers["openai"] = provider
+			},
+			want: []FieldError{
+				{"providers.openai.safety_settings[1].category", `unknown harm category "HARASSMENT"`},
+				{"providers.openai.safety_settings[1].threshold", `unknown threshold "BLOCK_SOME"`},
+				{"providers.openai.safety_settings[2].category", `duplicates category "HARM_CATEGORY_HARASSMENT"`},
+				{"providers.openai.safety_settings", "are only supported by gemini and vertex"},
+				{"providers.openai.api_version", "is only supported by anthropic"},
+			},
+		},
+		{
+			name: "vertex and anthropic",
+			modify: func(cfg *Config) {
+				cfg.Providers = map[string]ProviderConfig{
+					"anthropic": {
+						APIKeys:      []APIKey{{Key: "sk-ant", Name: "a", Enabled: true}},
+						Models:       []ModelConfig{{Name: "claude", Enabled: true}},
+						APIVersion:   "v1",
+						BetaFeatures: []string{"prompt-caching-2024-07-31", "a,b", " "},
+					},
+					"vertex": {
+						APIKeys: []APIKey{{Key: "{}", Name: "sa", Enabled: true}},
+						Models:  []ModelConfig{{Name: "gemini", Enabled: true}},
+					},
+				}
+			},
+			want: []FieldError{
+				{"providers.vertex.project", "must be set to the GCP project ID"},
+				{"providers.anthropic.api_version", `must be a date such as ` + DefaultAnthropicVersion + `, got "v1"`},
+				{"providers.anthropic.beta_features[1]", `must be a single feature name, got "a,b"`},
+				{"providers.anthropic.beta_features[2]", `must be a single feature name, got " "`},
+			},
+		},
+		{
+			name: "global",
+			modify: func(cfg *Config) {
+				cfg.Global = GlobalConfig{
+					FallbackChain:      []string{"openai", "mistral"},
+					CostAlertThreshold: 1.5,
+					ProviderRouting:    "fastest",
+					KeyTimeout:         "soon",
+					RequestLimits:      RequestLimits{MaxAttachmentBytes: -1},
+					ModelAliases: []ModelAlias{
+						{Name: "fast", Model: "gpt-4o-mini"},
+						{Name: "fast", Provider: "cohere"},
+					},
+				}
+			},
+			want: []FieldError{
+				{"global.fallback_chain[1]", `references unknown provider "mistral"`},
+				{"global.cost_alert_threshold", "must be between 0 and 1"},
+				{"global.provider_routing", `must be one of latency_optimized, got "fastest"`},
+				{"global.key_timeout", `must be a duration such as "30s" or "5m", got "soon"`},
+				{"global.request_limits.max_attachment_bytes", "must be >= 0"},
+				{"global.model_aliases[1].name", `duplicates alias "fast"`},
+				{"global.model_aliases[1].model", "must not be empty"},
+				{"global.model_aliases[1].provider", `references unknown provider "cohere"`},
+			},
+		},
+		{
+			name: "key validation",
+			modify: func(cfg *Config) {
+				cfg.Global.Validation = ValidationConfig{
+					Mode:     "offline",
+					CacheTTL: "-1m",
+					Workers:  -1,
+					Probes:   map[string]string{"openai": "full", "anthropic": "deep"},
+				}
+			},
+			want: []FieldError{
+				{"global.validation.mode", `must be one of live, format, got "offline"`},
+				{"global.validation.cache_ttl", `must be a duration such as "30m" or "0", got "-1m"`},
+				{"global.validation.workers", "must be >= 0"},
+				{"global.validation.probes.anthropic", `must be one of none, cheap, full, got "deep"`},
+			},
+		},
+		{
+			name: "http",
+			modify: func(cfg *Config) {
+				cfg.Global.HTTP = HTTPConfig{
+					ProxyURL: "proxy:3128",
+					CertFile: "client.pem",
+					Headers: map[string]string{
+						"Authorization": "Bearer x",
+						"Bad Header":    "x",
+						"User-Agent":    "x",
+						"X-Team":        "search",
+					},
+				}
+			},
+			want: []FieldError{
+				{"global.http.proxy_url", `must be an absolute URL such as "http://proxy:3128", got "proxy:3128"`},
+				{"global.http", "cert_file and key_file must be set together"},
+				{"global.http.headers.Authorization", "carries credentials and is set from the configured keys"},
+				{"global.http.headers.Bad Header", "is not a valid header name"},
+				{"global.http.headers.User-Agent", "is set with user_agent"},
+			},
+		},
+		{
+			name: "key store",
+			modify: func(cfg *Config) {
+				cfg.Global.KeyStore = KeyStoreConfig{
+					Type:              KeyStoreFile,
+					EncryptionKeyEnv:  "GOLLMKIT_KEY",
+					EncryptionKeyFile: "/run/secrets/key",
+					MasterKey:         MasterKeyConfig{Type: MasterKeyLocal, Key: "c2hvcnQ="},
+				}
+			},
+			want: []FieldError{
+				{"global.key_store.path", "must be set for file key stores"},
+				{"global.key_store.encryption_key_file", "must not be set together with encryption_key_env"},
+				{"global.key_store.master_key.key", "must be a base64-encoded 32-byte key"},
+			},
+		},
+		{
+			name: "master key outside file stores",
+			modify: func(cfg *Config) {
+				cfg.Global.KeyStore.MasterKey = MasterKeyConfig{Type: MasterKeyAWSKMS}
+			},
+			want: []FieldError{
+				{"global.key_store.master_key", "is only supported by file key stores"},
+				{"global.key_store.master_key.key_id", "must be set for aws-kms master keys"},
+			},
+		},
+		{
+			name: "tenants",
+			modify: func(cfg *Config) {
+				cfg.Tenants = map[string]TenantConfig{
+					"beta":  {RateLimit: -1},
+					"alpha": {DailyCostLimit: -1, MonthlyCostLimit: -2},
+				}
+			},
+			want: []FieldError{
+				{"tenants.alpha.daily_cost_limit", "must be >= 0"},
+				{"tenants.alpha.monthly_cost_limit", "must be >= 0"},
+				{"tenants.beta.rate_limit", "must be >= 0"},
+			},
+		},
+		{
+			name: "moderation",
+			modify: func(cfg *Config) {
+				cfg.Moderation.Rules = []ModerationRule{
+					{Name: "secrets", Type: ModerationRegex, Patterns: []string{`sk-[a-z]+`, `(unclosed`}, Action: ModerationBlock},
+					{Type: ModerationKeyword, Action: "delete", Stage: "never"},
+					{Name: "toxic", Type: "llm", Action: ModerationFlag},
+				}
+			},
+			want: []FieldError{
+				{"moderation.rules[0].patterns[1]", "must be a valid regular expression: error parsing regexp: missing closing ): `(unclosed`"},
+				{"moderation.rules[1].name", "must not be empty"},
+				{"moderation.rules[1].patterns", "must contain at least one pattern for keyword rules"},
+				{"moderation.rules[1].action", `must be one of block, redact, flag, got "delete"`},
+				{"moderation.rules[1].stage", `must be one of input, output, both, got "never"`},
+				{"moderation.rules[2].type", `must be one of regex, keyword, pii, openai, got "llm"`},
+			},
+		},
+		{
+			name: "language",
+			modify: func(cfg *Config) {
+				cfg.Language = LanguageConfig{
+					MinConfidence: 2,
+					Rules: []LanguageRule{
+						{Languages: []string{"fr", "EN"}, Provider: "mistral"},
+						{},
+					},
+				}
+			},
+			want: []FieldError{
+				{"language.min_confidence", "must be between 0 and 1"},
+				{"language.rules[0].languages[1]", `must be a lower-case ISO 639 code or "` + LanguageAny + `", got "EN"`},
+				{"language.rules[0].provider", `references unknown provider "mistral"`},
+				{"language.rules[1].languages", "must contain at least one language"},
+				{"language.rules[1]", "must set system_prompt, provider or model"},
+			},
+		},
+		{
+			name: "pgvector",
+			modify: func(cfg *Config) {
+				cfg.VectorStore = VectorStoreConfig{Type: VectorStorePGVector, Dimensions: -1, Collection: "drop table"}
+			},
+			want: []FieldError{
+				{"vector_store.dimensions", "must be >= 0"},
+				{"vector_store.dsn", "must not be empty for pgvector stores"},
+				{"vector_store.collection", `must be a valid table name for pgvector stores, got "drop table"`},
+			},
+		},
+		{
+			name: "qdrant",
+			modify: func(cfg *Config) {
+				cfg.VectorStore = VectorStoreConfig{Type: VectorStoreQdrant, URL: "localhost:6333"}
+			},
+			want: []FieldError{
+				{"vector_store.url", `must be an absolute URL, got "localhost:6333"`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := Validate(cfg)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("error %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Errors, tt.want) {
+				t.Errorf("errors:\n%s\nwant:\n%s", formatFieldErrors(validationErr.Errors), formatFieldErrors(tt.want))
+			}
+		})
+	}
+}
+
+// formatFieldErrors lists field errors one per line
+func formatFieldErrors(errs []FieldError) string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestValidationErrorMessage lists every problem of a configuration in the
+// error message, and a single problem on its own
+func TestValidationErrorMessage(t *testing.T) {
+	single := &ValidationError{Errors: []FieldError{{"providers", "must configure at least one provider"}}}
+	if got := single.Error(); got != "providers must configure at least one provider" {
+		t.Errorf("single problem: %q", got)
+	}
+
+	cfg := validConfig()
+	cfg.Providers["openai"].APIKeys[0].Key = ""
+	cfg.Global.CostAlertThreshold = -1
+	err := Validate(cfg)
+	want := "2 problems:\n" +
+		"  providers.openai.api_keys[0].key must not be empty\n" +
+		"  global.cost_alert_threshold must be between 0 and 1"
+	if err == nil || err.Error() != want {
+		t.Errorf("error %v, want:\n%s", err, want)
+	}
+}
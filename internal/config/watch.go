@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce coalesces the burst of events editors produce when saving a file
+const watchDebounce = 100 * time.Millisecond
+
+// Watcher reloads a configuration file whenever it changes on disk
+type Watcher struct {
+	watcher   *fsnotify.Watcher
+	path      string
+	onChange  func(*Config)
+	onError   func(error)
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Watch watches the config file at path and calls onChange with the newly
+// loaded configuration after every change. Changes that fail to load or
+// validate are passed to onError (if not nil) and the previous configuration
+// stays in effect. Call Close to stop watching.
+func Watch(path string, onChange func(*Config), onError func(error)) (*Watcher, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config path: %w", err)
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// Watch the directory: editors often replace the file instead of writing it in place
+	if err := fsWatcher.Add(filepath.Dir(absPath)); err != nil {
+		fsWatcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	w := &Watcher{
+		watcher:  fsWatcher,
+		path:     absPath,
+		onChange: onChange,
+		onError:  onError,
+		done:     make(chan struct{}),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+// Close stops watching the configuration file
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.watcher.Close()
+	})
+	return err
+}
+
+// run processes file system events until the watcher is closed
+func (w *Watcher) run() {
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
+
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != w.path {
+				continue
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(watchDebounce, func() {
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+			})
+
+		case <-reload:
+			cfg, err := LoadConfig(w.path)
+			if err != nil {
+				w.reportError(err)
+				continue
+			}
+			w.onChange(cfg)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.reportError(err)
+		}
+	}
+}
+
+// reportError passes an error to the error callback if one is set
+func (w *Watcher) reportError(err error) {
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
@@ -0,0 +1,167 @@
+// Package document extracts the text of documents such as PDFs, Word files
+// and web pages, for sending documents to providers that can't read them
+// natively. Extractors are registered per MIME type and can be replaced,
+// e.g. with a full PDF library, with Register.
+package document
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned for documents of a type without an extractor
+var ErrUnsupported = errors.New("unsupported document type")
+
+// ErrNoText is returned for documents without extractable text, such as
+// scanned PDFs
+var ErrNoText = errors.New("document has no extractable text")
+
+// MIME types of the built-in extractors
+const (
+	TypePDF      = "application/pdf"
+	TypeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	TypeHTML     = "text/html"
+	TypePlain    = "text/plain"
+	TypeMarkdown = "text/markdown"
+)
+
+// Extractor returns the text of a document
+type Extractor func(data []byte) (string, error)
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{
+		TypePDF:                 extractPDF,
+		TypeDOCX:                extractDOCX,
+		TypeHTML:                extractHTML,
+		"application/xhtml+xml": extractHTML,
+		"application/json":      extractPlain,
+		"application/xml":       extractPlain,
+		"application/yaml":      extractPlain,
+		"application/x-yaml":    extractPlain,
+	}
+)
+
+// Register registers the extractor of a MIME type. Registering an existing
+// type replaces it. Text types (text/*) without an extractor are read as
+// plain text.
+func Register(mimeType string, extractor Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[mimeType] = extractor
+}
+
+// Unregister removes the extractor of a MIME type
+func Unregister(mimeType string) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	delete(extractors, mimeType)
+}
+
+// Types returns the MIME types with a registered extractor, sorted
+func Types() []string {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	types := make([]string, 0, len(extractors))
+	for mimeType := range extractors {
+		types = append(types, mimeType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ExtractText returns the text of a document of the given MIME type
+func ExtractText(mimeType string, data []byte) (string, error) {
+	mimeType = baseType(mimeType)
+
+	extractorsMu.RLock()
+	extractor, exists := extractors[mimeType]
+	extractorsMu.RUnlock()
+	if !exists {
+		if !strings.HasPrefix(mimeType, "text/") {
+			return "", fmt.Errorf("%w: %s", ErrUnsupported, mimeType)
+		}
+		extractor = extractPlain
+	}
+
+	text, err := extractor(data)
+	if err != nil {
+		return "", err
+	}
+	text = cleanText(text)
+	if text == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// extensionTypes are the MIME types of document extensions that the mime
+// package may not know, depending on the system's MIME database
+var extensionTypes = map[string]string{
+	".pdf":  TypePDF,
+	".docx": TypeDOCX,
+	".htm":  TypeHTML,
+	".html": TypeHTML,
+	".txt":  TypePlain,
+	".md":   TypeMarkdown,
+	".csv":  "text/csv",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// MIMEType returns the MIME type of a file by its extension,
+// application/octet-stream if it is unknown
+func MIMEType(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if mimeType, ok := extensionTypes[ext]; ok {
+		return mimeType
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return baseType(mimeType)
+	}
+	return "application/octet-stream"
+}
+
+// baseType returns mimeType without parameters, in lower case
+func baseType(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// extractPlain reads data as UTF-8 text
+func extractPlain(data []byte) (string, error) {
+	return strings.ToValidUTF8(string(data), "\uFFFD"), nil
+}
+
+// cleanText trims the lines of text and collapses runs of blank lines
+func cleanText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	cleaned := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			blank = len(cleaned) > 0
+			continue
+		}
+		if blank {
+			cleaned = append(cleaned, "")
+			blank = false
+		}
+		cleaned = append(cleaned, line)
+	}
+	return strings.Join(cleaned, "\n")
+}
@@ -0,0 +1,75 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxDOCXPartSize bounds the decompressed size of the main part of a Word file
+const maxDOCXPartSize = 64 << 20
+
+// extractDOCX returns the text of the paragraphs of a Word (.docx) file
+func extractDOCX(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("reading docx: %w", err)
+	}
+
+	var part *zip.File
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			part = file
+			break
+		}
+	}
+	if part == nil {
+		return "", errors.New("reading docx: missing word/document.xml")
+	}
+	reader, err := part.Open()
+	if err != nil {
+		return "", fmt.Errorf("reading docx: %w", err)
+	}
+	defer reader.Close()
+
+	var text strings.Builder
+	decoder := xml.NewDecoder(io.LimitReader(reader, maxDOCXPartSize))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading docx: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte('\t')
+			case "br", "cr":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(token)
+			}
+		}
+	}
+	return text.String(), nil
+}
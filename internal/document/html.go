@@ -0,0 +1,37 @@
+package document
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlHidden matches elements whose content isn't text of the page
+	htmlHidden = regexp.MustCompile(`(?is)<(script|style|head|noscript|template)\b.*?</(script|style|head|noscript|template)\s*>`)
+
+	// htmlBreaks matches tags ending a line of text
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6]|blockquote|pre|section|article|table|ul|ol)\s*>`)
+
+	// htmlTags matches the remaining tags and comments
+	htmlTags = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>`)
+
+	// htmlSpaces matches runs of horizontal whitespace
+	htmlSpaces = regexp.MustCompile(`[ \t\f\v]+`)
+)
+
+// extractHTML returns the visible text of an HTML page, a line per block element
+func extractHTML(data []byte) (string, error) {
+	text := htmlHidden.ReplaceAllString(string(data), "")
+	text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+	text = htmlBreaks.ReplaceAllString(text, "\n")
+	text = htmlTags.ReplaceAllString(text, " ")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(htmlSpaces.ReplaceAllString(line, " "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,426 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamSize bounds the decompressed size of a PDF content stream
+const maxPDFStreamSize = 64 << 20
+
+// pdfUnsupportedFilters are stream filters of images and of encodings the
+// extractor doesn't decode
+var pdfUnsupportedFilters = []string{
+	"/DCTDecode", "/JPXDecode", "/CCITTFaxDecode", "/JBIG2Decode",
+	"/LZWDecode", "/ASCII85Decode", "/ASCIIHexDecode", "/RunLengthDecode", "/Crypt",
+}
+
+// extractPDF returns the text shown by the content streams of a PDF. It is a
+// best-effort extractor without dependencies: encrypted files and fonts
+// with custom encodings (such as CID fonts of most CJK documents) yield no
+// text. Register a full PDF library with Register for those.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return "", errors.New("reading pdf: not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("reading pdf: encrypted PDFs are not supported")
+	}
+
+	var text strings.Builder
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		pos = start + len("stream")
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+
+		// The stream data starts after the end of line following the keyword
+		body := pos
+		if body < len(data) && data[body] == '\r' {
+			body++
+		}
+		if body < len(data) && data[body] == '\n' {
+			body++
+		}
+		end := bytes.Index(data[body:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		pos = body + end + len("endstream")
+
+		obj := bytes.LastIndex(data[:start], []byte("obj"))
+		if obj < 0 {
+			continue
+		}
+		dict := string(data[obj+len("obj") : start])
+		content, ok := pdfContentStream(dict, data[body:body+end])
+		if !ok {
+			continue
+		}
+		extractPDFContent(content, &text)
+		text.WriteByte('\n')
+	}
+	return collapseSpaces(text.String()), nil
+}
+
+// pdfContentStream returns the decoded data of a stream if its dictionary
+// describes a page or form content stream
+func pdfContentStream(dict string, raw []byte) ([]byte, bool) {
+	// Fonts, images, metadata, object and cross-reference streams have a type or font lengths
+	if strings.Contains(dict, "/Length1") || strings.Contains(dict, "/Length2") || strings.Contains(dict, "/Length3") {
+		return nil, false
+	}
+	if (strings.Contains(dict, "/Type") || strings.Contains(dict, "/Subtype")) && !strings.Contains(dict, "/Form") {
+		return nil, false
+	}
+	for _, filter := range pdfUnsupportedFilters {
+		if strings.Contains(dict, filter) {
+			return nil, false
+		}
+	}
+	if !strings.Contains(dict, "/FlateDecode") {
+		return raw, true
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer reader.Close()
+	// Streams truncated or with trailing garbage still yield what was inflated
+	content, _ := io.ReadAll(io.LimitReader(reader, maxPDFStreamSize))
+	return content, len(content) > 0
+}
+
+// pdfString is a string operand of a content stream
+type pdfString []byte
+
+// pdfArrayStart marks the start of an array operand on the operand stack
+type pdfArrayStart struct{}
+
+// pdfArrayEnd is the token of the end of an array
+type pdfArrayEnd struct{}
+
+// extractPDFContent writes the text shown by the text operators of a content stream to text
+func extractPDFContent(content []byte, text *strings.Builder) {
+	var operands []interface{}
+	lastY := 0.0
+	lexer := pdfLexer{data: content}
+	for {
+		token, operator, ok := lexer.next()
+		if !ok {
+			return
+		}
+		if !operator {
+			if token == nil {
+				continue
+			}
+			if _, end := token.(pdfArrayEnd); end {
+				operands = closeArray(operands)
+				continue
+			}
+			operands = append(operands, token)
+			continue
+		}
+
+		op := token.(string)
+		switch op {
+		case "Tj":
+			writePDFString(text, lastString(operands))
+		case "'", "\"":
+			text.WriteByte('\n')
+			writePDFString(text, lastString(operands))
+		case "TJ":
+			if len(operands) > 0 {
+				items, _ := operands[len(operands)-1].([]interface{})
+				for _, item := range items {
+					switch item := item.(type) {
+					case pdfString:
+						writePDFString(text, item)
+					case float64:
+						// Large negative adjustments separate words
+						if item < -180 {
+							text.WriteByte(' ')
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if y, ok := operand(operands, 1); ok && y != 0 {
+				text.WriteByte('\n')
+			} else {
+				text.WriteByte(' ')
+			}
+		case "Tm":
+			if y, ok := operand(operands, 1); ok {
+				if y != lastY {
+					text.WriteByte('\n')
+				} else {
+					text.WriteByte(' ')
+				}
+				lastY = y
+			}
+		case "T*", "ET":
+			text.WriteByte('\n')
+		case "ID":
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// operand returns the number operand at position i from the end of operands, 1 being the last
+func operand(operands []interface{}, i int) (float64, bool) {
+	if len(operands) < i {
+		return 0, false
+	}
+	value, ok := operands[len(operands)-i].(float64)
+	return value, ok
+}
+
+// lastString returns the last operand if it is a string
+func lastString(operands []interface{}) pdfString {
+	if len(operands) == 0 {
+		return nil
+	}
+	s, _ := operands[len(operands)-1].(pdfString)
+	return s
+}
+
+// closeArray replaces the operands since the last array start with an array of them
+func closeArray(operands []interface{}) []interface{} {
+	for i := len(operands) - 1; i >= 0; i-- {
+		if _, start := operands[i].(pdfArrayStart); start {
+			items := append([]interface{}(nil), operands[i+1:]...)
+			return append(operands[:i], items)
+		}
+	}
+	return operands
+}
+
+// winAnsiSpecials are the WinAnsiEncoding characters that differ from Latin-1
+var winAnsiSpecials = map[byte]rune{
+	0x80: '€', 0x85: '…', 0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”',
+	0x95: '•', 0x96: '–', 0x97: '—', 0x99: '™',
+}
+
+// writePDFString writes a string operand as text. Strings starting with a
+// UTF-16 byte order mark are decoded as UTF-16, others as WinAnsiEncoding.
+// Strings that are mostly control bytes use a font encoding that can't be
+// decoded without the font, such as glyph IDs, and are skipped.
+func writePDFString(text *strings.Builder, s pdfString) {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		text.WriteString(string(utf16.Decode(units)))
+		return
+	}
+
+	control := 0
+	for _, b := range s {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			control++
+		}
+	}
+	if control*3 > len(s) {
+		return
+	}
+	for _, b := range s {
+		switch {
+		case b < 0x20 && b != '\t':
+			continue
+		case winAnsiSpecials[b] != 0:
+			text.WriteRune(winAnsiSpecials[b])
+		default:
+			text.WriteRune(rune(b))
+		}
+	}
+}
+
+// collapseSpaces collapses runs of spaces within the lines of text
+func collapseSpaces(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pdfLexer splits a content stream into operands and operators
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// isPDFSpace reports whether b is PDF whitespace
+func isPDFSpace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+// isPDFDelimiter reports whether b ends a name, number or operator
+func isPDFDelimiter(b byte) bool {
+	return isPDFSpace(b) || strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+// next returns the next token: an operand (pdfString, float64,
+// pdfArrayStart, pdfArrayEnd or nil for operands that don't matter) or an
+// operator name. ok is false at the end of the stream.
+func (l *pdfLexer) next() (token interface{}, operator bool, ok bool) {
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		switch {
+		case isPDFSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case b == '(':
+			return l.literalString(), false, true
+		case b == '<':
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+				l.pos += 2
+				return nil, false, true
+			}
+			return l.hexString(), false, true
+		case b == '>':
+			l.pos++
+			return nil, false, true
+		case b == '[':
+			l.pos++
+			return pdfArrayStart{}, false, true
+		case b == ']':
+			l.pos++
+			return pdfArrayEnd{}, false, true
+		case b == '{' || b == '}' || b == ')':
+			l.pos++
+		case b == '/':
+			l.pos++
+			l.word()
+			return nil, false, true
+		default:
+			word := l.word()
+			if word == "" {
+				l.pos++
+				continue
+			}
+			if number, err := strconv.ParseFloat(word, 64); err == nil {
+				return number, false, true
+			}
+			return word, true, true
+		}
+	}
+	return nil, false, false
+}
+
+// word reads regular characters up to the next delimiter
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// literalString reads a parenthesized string with its escapes
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // (
+	var s pdfString
+	depth := 1
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return s
+			}
+			escaped := l.data[l.pos]
+			l.pos++
+			switch escaped {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				value := int(escaped - '0')
+				for n := 1; n < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; n++ {
+					value = value*8 + int(l.data[l.pos]-'0')
+					l.pos++
+				}
+				s = append(s, byte(value))
+			default:
+				s = append(s, escaped)
+			}
+			continue
+		}
+		s = append(s, b)
+	}
+	return s
+}
+
+// hexString reads a string of hexadecimal digits in angle brackets
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if b := l.data[l.pos]; !isPDFSpace(b) {
+			digits = append(digits, b)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	s := make(pdfString, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		value, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return nil
+		}
+		s = append(s, byte(value))
+	}
+	return s
+}
+
+// skipInlineImage skips the binary data of an inline image up to its EI operator
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+1 < len(l.data) {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' && isPDFSpace(l.data[l.pos-1]) &&
+			(l.pos+2 == len(l.data) || isPDFSpace(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
@@ -0,0 +1,260 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pdfStream is a content stream of a test PDF
+type pdfStream struct {
+	dict    string // entries besides /Length
+	content string
+	flate   bool
+}
+
+// buildPDF returns a PDF with a page showing each content stream, and a
+// cross-reference table with the offsets of its objects
+func buildPDF(t *testing.T, streams ...pdfStream) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	contents := make([]string, len(streams))
+	for i := range streams {
+		contents[i] = fmt.Sprintf("%d 0 R", i+5)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents [" + strings.Join(contents, " ") + "] >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for _, stream := range streams {
+		data := []byte(stream.content)
+		dict := stream.dict
+		if stream.flate {
+			var compressed bytes.Buffer
+			w := zlib.NewWriter(&compressed)
+			w.Write(data)
+			w.Close()
+			data = compressed.Bytes()
+			dict += " /Filter /FlateDecode"
+		}
+		object(fmt.Sprintf("<< /Length %d%s >>\nstream\n%s\nendstream", len(data), dict, data))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []pdfStream
+		want    string
+	}{
+		{
+			name:    "uncompressed",
+			streams: []pdfStream{{content: "BT /F1 12 Tf 72 720 Td (Hello, world) Tj ET"}},
+			want:    "Hello, world",
+		},
+		{
+			name: "flate",
+			streams: []pdfStream{{
+				content: "BT /F1 12 Tf 72 720 Td (First line) Tj 0 -14 Td (Second line) Tj ET",
+				flate:   true,
+			}},
+			want: "First line\nSecond line",
+		},
+		{
+			name: "TJ arrays",
+			streams: []pdfStream{{
+				content: "BT /F1 12 Tf 72 720 Td [(Ker) 20 (ned) -250 (words)] TJ ET",
+				flate:   true,
+			}},
+			want: "Kerned words",
+		},
+		{
+			name: "escaped strings",
+			streams: []pdfStream{{
+				content: `BT /F1 12 Tf 72 720 Td (Parens \(nested (ok)\) back\\slash \101\102C tab\there) Tj ` +
+					`T* (split \` + "\n" + `line) Tj T* (\222quoted\223 \200) Tj ET`,
+			}},
+			want: "Parens (nested (ok)) back\\slash ABC tab here\nsplit line\n’quoted“ €",
+		},
+		{
+			name: "hex and UTF-16 strings",
+			streams: []pdfStream{{
+				content: "BT /F1 12 Tf 72 720 Td <48 65 6C6C 6F> Tj T* <FEFF00DC006E00EF> Tj ET",
+			}},
+			want: "Hello\nÜnï",
+		},
+		{
+			name: "text positioned with Tm and quote operators",
+			streams: []pdfStream{{
+				content: "BT 1 0 0 1 72 720 Tm (Left) Tj 1 0 0 1 300 720 Tm (right) Tj 1 0 0 1 72 700 Tm (Below) Tj (Next) ' ET",
+			}},
+			want: "Left right\nBelow\nNext",
+		},
+		{
+			name: "several content streams",
+			streams: []pdfStream{
+				{content: "BT 72 720 Td (One) Tj ET", flate: true},
+				{content: "BT 72 700 Td (Two) Tj ET"},
+			},
+			want: "One\n\nTwo",
+		},
+		{
+			name: "inline image skipped",
+			streams: []pdfStream{{
+				content: "q BI /W 2 /H 2 /BPC 8 /CS /G ID \x00\xff(Tj)\x10 EI Q BT 72 720 Td (After image) Tj ET",
+			}},
+			want: "After image",
+		},
+		{
+			name: "font and image streams skipped",
+			streams: []pdfStream{
+				{dict: " /Length1 120", content: "(font program) Tj"},
+				{dict: " /Type /XObject /Subtype /Image /Filter /DCTDecode", content: "(jpeg) Tj"},
+				{content: "BT 72 720 Td (Page text) Tj ET"},
+			},
+			want: "Page text",
+		},
+		{
+			name: "form XObject",
+			streams: []pdfStream{{
+				dict:    " /Type /XObject /Subtype /Form /BBox [0 0 100 100]",
+				content: "BT 0 0 Td (In a form) Tj ET",
+			}},
+			want: "In a form",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := ExtractText(TypePDF, buildPDF(t, tt.streams...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.want {
+				t.Errorf("text %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFErrors(t *testing.T) {
+	if _, err := ExtractText(TypePDF, []byte("<html>not a pdf</html>")); err == nil || !strings.Contains(err.Error(), "not a PDF") {
+		t.Errorf("non-PDF: %v", err)
+	}
+
+	encrypted := append(buildPDF(t, pdfStream{content: "BT (secret) Tj ET"}), "trailer << /Encrypt 9 0 R >>\n"...)
+	if _, err := ExtractText(TypePDF, encrypted); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("encrypted: %v", err)
+	}
+
+	// Glyph IDs of a font with a custom encoding
+	scanned := buildPDF(t, pdfStream{content: "BT /F1 12 Tf 72 720 Td <0001000200030004> Tj ET", flate: true})
+	if _, err := ExtractText(TypePDF, scanned); !errors.Is(err, ErrNoText) {
+		t.Errorf("custom encoding: %v, want ErrNoText", err)
+	}
+}
+
+// TestExtractPDFMalformed reads damaged files without panicking, keeping
+// the text that can still be found
+func TestExtractPDFMalformed(t *testing.T) {
+	valid := buildPDF(t,
+		pdfStream{content: "BT /F1 12 Tf 72 720 Td [(Hello) -300 (world)] TJ ET", flate: true},
+		pdfStream{content: "BT 72 700 Td (Escaped \\(text\\) \\101) Tj ET"},
+	)
+	xref := bytes.Index(valid, []byte("xref"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want string // text that must still be extracted, if any
+	}{
+		{
+			name: "garbage xref",
+			data: append(append([]byte(nil), valid[:xref]...), "xref\n0 99\nnot an entry\ntrailer\n<< /Size -1 >>\nstartxref\n999999\n%%EOF"...),
+			want: "Hello world",
+		},
+		{
+			name: "missing xref and trailer",
+			data: valid[:xref],
+			want: "Hello world",
+		},
+		{
+			name: "wrong xref offsets",
+			data: bytes.ReplaceAll(valid, []byte(" 00000 n "), []byte(" 99999 n ")),
+			want: "Hello world",
+		},
+		{
+			name: "corrupt flate data",
+			data: corruptFlate(valid),
+			want: "Escaped (text) A",
+		},
+		{
+			name: "missing endstream",
+			data: bytes.Replace(valid, []byte("endstream"), []byte("endstrea"), -1),
+		},
+		{
+			name: "stream keyword at the end",
+			data: []byte("%PDF-1.4\n1 0 obj\n<< /Length 4 >>\nstream"),
+		},
+		{
+			name: "stream without object",
+			data: []byte("%PDF-1.4\nstream\nBT (orphan) Tj ET\nendstream\n"),
+		},
+		{
+			name: "unterminated strings and arrays",
+			data: buildPDF(t, pdfStream{content: "BT [(open (nested <48 [ (\\"}),
+		},
+		{
+			name: "odd hex string",
+			data: buildPDF(t, pdfStream{content: "BT <48656C6C6F2> Tj <zz> Tj ET"}),
+		},
+		{
+			name: "inline image without end",
+			data: buildPDF(t, pdfStream{content: "BI /W 1 ID \x00\x01\x02"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _ := extractPDF(tt.data)
+			if tt.want != "" && !strings.Contains(text, tt.want) {
+				t.Errorf("text %q doesn't contain %q", text, tt.want)
+			}
+		})
+	}
+
+	// Every truncation of the file
+	for n := range valid {
+		extractPDF(valid[:n])
+	}
+}
+
+// corruptFlate overwrites the compressed data of the first FlateDecode
+// stream of data after its zlib header
+func corruptFlate(data []byte) []byte {
+	corrupted := append([]byte(nil), data...)
+	filter := bytes.Index(corrupted, []byte("/FlateDecode"))
+	start := filter + bytes.Index(corrupted[filter:], []byte("stream\n")) + len("stream\n")
+	for i := start + 2; i < start+12; i++ {
+		corrupted[i] = 0xff
+	}
+	return corrupted
+}
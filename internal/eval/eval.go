@@ -0,0 +1,199 @@
+// Package eval runs a dataset of prompts against provider/model
+// configurations, scores the responses and compares quality, cost and latency
+package eval
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// Case is one prompt of a dataset
+type Case struct {
+	ID       string              `json:"id"`
+	Prompt   string              `json:"prompt,omitempty"`   // shorthand for a single user message
+	Messages []providers.Message `json:"messages,omitempty"` // used instead of Prompt if set
+	Expected string              `json:"expected,omitempty"` // reference answer for scorers
+}
+
+// messages returns the conversation sent for the case
+func (c Case) messages() []providers.Message {
+	if len(c.Messages) > 0 {
+		return c.Messages
+	}
+	return []providers.Message{{Role: "user", Content: c.Prompt}}
+}
+
+// LoadDataset reads cases from a JSONL file, one case per line. Cases without
+// an ID are numbered by line.
+func LoadDataset(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	var cases []Case
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("invalid dataset %s line %d: %w", path, line, err)
+		}
+		if c.Prompt == "" && len(c.Messages) == 0 {
+			return nil, fmt.Errorf("dataset %s line %d has neither a prompt nor messages", path, line)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("%d", line)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	return cases, nil
+}
+
+// Target is a provider/model configuration under evaluation
+type Target struct {
+	Name    string                   `json:"name"`
+	Options providers.RequestOptions `json:"options"`
+}
+
+// label returns the name of the target, provider/model if unnamed
+func (t Target) label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return string(t.Options.Provider) + "/" + t.Options.Model
+}
+
+// Result is the outcome of one case on one target
+type Result struct {
+	CaseID  string               `json:"case_id"`
+	Target  string               `json:"target"`
+	Content string               `json:"content,omitempty"`
+	Usage   providers.TokenUsage `json:"usage"`
+	Cost    float64              `json:"cost"`
+	Latency time.Duration        `json:"latency"`
+	Scores  map[string]float64   `json:"scores,omitempty"`
+	Error   string               `json:"error,omitempty"`
+}
+
+// CostFunc prices the token usage of a response
+type CostFunc func(provider providers.ProviderType, model string, usage providers.TokenUsage) float64
+
+// defaultConcurrency is the number of requests a Runner sends at once
+const defaultConcurrency = 4
+
+// Runner evaluates datasets against targets
+type Runner struct {
+	provider    providers.LLMProvider
+	scorers     []Scorer
+	cost        CostFunc
+	concurrency int
+}
+
+// NewRunner creates a runner sending requests through provider and scoring
+// every response with scorers. Costs are computed with the provider's
+// pricing if it is a *providers.UnifiedProvider.
+func NewRunner(provider providers.LLMProvider, scorers ...Scorer) *Runner {
+	r := &Runner{provider: provider, scorers: scorers, concurrency: defaultConcurrency}
+	if unified, ok := provider.(*providers.UnifiedProvider); ok {
+		r.cost = unified.CalculateCost
+	}
+	return r
+}
+
+// SetCostFunc sets how response costs are computed
+func (r *Runner) SetCostFunc(cost CostFunc) {
+	r.cost = cost
+}
+
+// SetConcurrency sets how many requests are sent at once
+func (r *Runner) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	r.concurrency = n
+}
+
+// Run sends every case to every target and returns the report. Failed
+// requests and scorers are recorded in the results rather than aborting the run;
+// an error is returned only if ctx is done or the inputs are empty.
+func (r *Runner) Run(ctx context.Context, dataset []Case, targets []Target) (*Report, error) {
+	if len(dataset) == 0 {
+		return nil, errors.New("eval: empty dataset")
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("eval: no targets")
+	}
+
+	results := make([]Result, len(dataset)*len(targets))
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	for t, target := range targets {
+		for c, evalCase := range dataset {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return nil, ctx.Err()
+			}
+
+			wg.Add(1)
+			go func(i int, target Target, evalCase Case) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i] = r.runCase(ctx, target, evalCase)
+			}(t*len(dataset)+c, target, evalCase)
+		}
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return newReport(targets, r.scorers, results, len(dataset)), nil
+}
+
+// runCase sends one case to one target and scores the response
+func (r *Runner) runCase(ctx context.Context, target Target, evalCase Case) Result {
+	result := Result{CaseID: evalCase.ID, Target: target.label()}
+
+	start := time.Now()
+	resp, err := r.provider.Chat(ctx, evalCase.messages(), target.Options)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Content = resp.Content
+	result.Usage = resp.Usage
+	if r.cost != nil {
+		result.Cost = r.cost(target.Options.Provider, target.Options.Model, resp.Usage)
+	}
+
+	result.Scores = make(map[string]float64, len(r.scorers))
+	for _, scorer := range r.scorers {
+		score, err := scorer.Score(ctx, evalCase, resp)
+		if err != nil {
+			result.Error = fmt.Sprintf("scorer %s: %v", scorer.Name, err)
+			continue
+		}
+		result.Scores[scorer.Name] = score
+	}
+	return result
+}
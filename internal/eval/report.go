@@ -0,0 +1,112 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary aggregates the results of one target
+type Summary struct {
+	Target      string             `json:"target"`
+	Cases       int                `json:"cases"`
+	Errors      int                `json:"errors"`
+	Scores      map[string]float64 `json:"scores"` // scorer -> mean score over successful cases
+	Tokens      int64              `json:"tokens"`
+	Cost        float64            `json:"cost"`
+	MeanLatency time.Duration      `json:"mean_latency"`
+	P95Latency  time.Duration      `json:"p95_latency"`
+}
+
+// Report is the outcome of a Run: a summary per target, in the order the
+// targets were given, and every individual result
+type Report struct {
+	Scorers   []string  `json:"scorers"`
+	Summaries []Summary `json:"summaries"`
+	Results   []Result  `json:"results"`
+}
+
+// newReport aggregates results, which hold the results of each target in
+// turn, cases results per target
+func newReport(targets []Target, scorers []Scorer, results []Result, cases int) *Report {
+	report := &Report{Results: results}
+	for _, scorer := range scorers {
+		report.Scorers = append(report.Scorers, scorer.Name)
+	}
+
+	for t, target := range targets {
+		summary := Summary{Target: target.label(), Scores: make(map[string]float64)}
+		scored := make(map[string]int)
+		var latencies []time.Duration
+		var totalLatency time.Duration
+
+		for _, result := range results[t*cases : (t+1)*cases] {
+			summary.Cases++
+			latencies = append(latencies, result.Latency)
+			totalLatency += result.Latency
+			if result.Error != "" {
+				summary.Errors++
+			}
+			summary.Tokens += int64(result.Usage.TotalTokens)
+			summary.Cost += result.Cost
+			for name, score := range result.Scores {
+				summary.Scores[name] += score
+				scored[name]++
+			}
+		}
+
+		for name, n := range scored {
+			summary.Scores[name] /= float64(n)
+		}
+		if summary.Cases > 0 {
+			summary.MeanLatency = totalLatency / time.Duration(summary.Cases)
+		}
+		summary.P95Latency = p95(latencies)
+		report.Summaries = append(report.Summaries, summary)
+	}
+	return report
+}
+
+// WriteTable writes the summaries as an aligned text table
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := []string{"TARGET", "CASES", "ERRORS"}
+	for _, name := range r.Scorers {
+		header = append(header, strings.ToUpper(name))
+	}
+	header = append(header, "TOKENS", "COST", "MEAN LATENCY", "P95 LATENCY")
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, s := range r.Summaries {
+		row := []string{s.Target, fmt.Sprint(s.Cases), fmt.Sprint(s.Errors)}
+		for _, name := range r.Scorers {
+			row = append(row, fmt.Sprintf("%.3f", s.Scores[name]))
+		}
+		row = append(row,
+			fmt.Sprint(s.Tokens),
+			fmt.Sprintf("$%.4f", s.Cost),
+			s.MeanLatency.Round(time.Millisecond).String(),
+			s.P95Latency.Round(time.Millisecond).String())
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// p95 returns the 95th percentile of latencies using nearest-rank
+func p95(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(float64(len(sorted))*0.95+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
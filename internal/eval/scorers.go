@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// ScoreFunc scores a response to a case, usually between 0 and 1
+type ScoreFunc func(ctx context.Context, c Case, resp *providers.CompletionResponse) (float64, error)
+
+// Scorer is a named scoring function; the name labels its column in reports
+type Scorer struct {
+	Name  string
+	Score ScoreFunc
+}
+
+// ExactMatch scores 1 if the response equals the expected answer, ignoring
+// surrounding whitespace and case, and 0 otherwise
+func ExactMatch() Scorer {
+	return Scorer{
+		Name: "exact_match",
+		Score: func(ctx context.Context, c Case, resp *providers.CompletionResponse) (float64, error) {
+			if strings.EqualFold(strings.TrimSpace(resp.Content), strings.TrimSpace(c.Expected)) {
+				return 1, nil
+			}
+			return 0, nil
+		},
+	}
+}
+
+// Regex scores 1 if the response matches pattern and 0 otherwise
+func Regex(name string, pattern *regexp.Regexp) Scorer {
+	return Scorer{
+		Name: name,
+		Score: func(ctx context.Context, c Case, resp *providers.CompletionResponse) (float64, error) {
+			if pattern.MatchString(resp.Content) {
+				return 1, nil
+			}
+			return 0, nil
+		},
+	}
+}
+
+// judgeScorePattern extracts the first number from a judge model's reply
+var judgeScorePattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// LLMJudge asks a model to grade each response from 0 to 10 against the
+// case's expected answer, if any, and scores the grade divided by 10
+func LLMJudge(judge providers.LLMProvider, opts providers.RequestOptions) Scorer {
+	return Scorer{
+		Name: "llm_judge",
+		Score: func(ctx context.Context, c Case, resp *providers.CompletionResponse) (float64, error) {
+			var prompt strings.Builder
+			prompt.WriteString("You are grading an answer to the conversation below. ")
+			prompt.WriteString("Reply with only a grade from 0 (wrong or useless) to 10 (perfect).\n\nConversation:\n")
+			for _, msg := range c.messages() {
+				fmt.Fprintf(&prompt, "%s: %s\n", msg.Role, msg.Content)
+			}
+			if c.Expected != "" {
+				fmt.Fprintf(&prompt, "\nReference answer:\n%s\n", c.Expected)
+			}
+			fmt.Fprintf(&prompt, "\nAnswer to grade:\n%s\n", resp.Content)
+
+			reply, err := judge.Invoke(ctx, prompt.String(), opts)
+			if err != nil {
+				return 0, err
+			}
+
+			grade := judgeScorePattern.FindString(reply.Content)
+			if grade == "" {
+				return 0, fmt.Errorf("%w: judge reply contains no grade", providers.ErrResponseFormat)
+			}
+			n, err := strconv.ParseFloat(grade, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", providers.ErrResponseFormat, err)
+			}
+			if n > 10 {
+				n = 10
+			}
+			return n / 10, nil
+		},
+	}
+}
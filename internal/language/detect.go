@@ -0,0 +1,195 @@
+// Package language detects the language of prompts and applies
+// locale-specific system instructions and routing to requests
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Detection is the language of a text
+type Detection struct {
+	// Language is an ISO 639-1 code, empty if the language wasn't detected
+	Language string `json:"language"`
+
+	// Confidence is between 0 and 1
+	Confidence float64 `json:"confidence"`
+}
+
+// Detector detects the language of a text
+type Detector func(text string) Detection
+
+// scripts maps writing systems used by a single language to its code.
+// Han, kana, Cyrillic and Arabic need a closer look and are handled separately.
+var scripts = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Bengali, "bn"},
+	{unicode.Tamil, "ta"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// latinWords are frequent words of languages written in the Latin script,
+// which are told apart by counting them
+var latinWords = map[string]map[string]bool{
+	"en": wordSet("the and of to is in that it was for on are with as be this have not you but what from they by at or which would there their will"),
+	"es": wordSet("el la de que y en los las del por con una para es se no un su al lo como más pero sus le ya este sí porque esta entre cuando muy sin sobre también hay donde qué"),
+	"fr": wordSet("le la les de des et est un une du en que qui dans pour pas sur au aux avec ce il elle ne se sont mais ou nous vous je est-ce c'est"),
+	"de": wordSet("der die das und ist nicht ein eine zu den von mit sich des auf für im dem auch es an als wie bei oder ich sie wir aus nach wird"),
+	"it": wordSet("il la di che e è un una per non in del della sono con si le gli da al ma come anche questo mi nel alla"),
+	"pt": wordSet("o a de que e do da em um uma para é com não os as por mais se dos das no na ao mas como foi você são"),
+	"nl": wordSet("de het een en van is dat niet in op te zijn met voor die er maar ook als bij aan wat hij ik je wordt"),
+	"sv": wordSet("och att det som en på är av för med till den inte har de om ett jag var men så kan vi"),
+	"pl": wordSet("i w nie się na to że z do jest jak co ale po tak za od być są czy dla"),
+	"tr": wordSet("ve bir bu da de için ile ne çok daha gibi ama olarak var mı ben sen o değil"),
+	"id": wordSet("yang dan di ini itu dengan untuk tidak dari dalam akan pada juga saya ke ada bisa"),
+	"vi": wordSet("là và của có không các được cho một những với này trong người đã"),
+}
+
+// latinLetters are letters that are common in only some Latin-script languages
+var latinLetters = map[rune][]string{
+	'ñ': {"es"}, '¿': {"es"}, '¡': {"es"},
+	'ß': {"de"}, 'ä': {"de", "sv"}, 'ö': {"de", "sv", "tr"}, 'ü': {"de", "tr"},
+	'ã': {"pt"}, 'õ': {"pt"}, 'ç': {"fr", "pt", "tr"},
+	'è': {"fr", "it"}, 'ê': {"fr", "pt"}, 'à': {"fr", "it"}, 'ù': {"fr", "it"},
+	'å': {"sv"},
+	'ł': {"pl"}, 'ą': {"pl"}, 'ę': {"pl"}, 'ś': {"pl"}, 'ż': {"pl"}, 'ź': {"pl"}, 'ć': {"pl"}, 'ń': {"pl"},
+	'ğ': {"tr"}, 'ş': {"tr"}, 'ı': {"tr"},
+	'ơ': {"vi"}, 'ư': {"vi"}, 'đ': {"vi"}, 'ạ': {"vi"}, 'ộ': {"vi"}, 'ế': {"vi"},
+}
+
+// Detect returns the language of text, guessed from its script and, for the
+// Latin script, its most frequent words. It knows the major languages of
+// Europe and Asia; texts that are short or mix languages get a low
+// confidence.
+func Detect(text string) Detection {
+	counts := make(map[string]int)
+	var letters, latin, han, kana, cyrillic, arabic int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		default:
+			for _, script := range scripts {
+				if unicode.Is(script.table, r) {
+					counts[script.language]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return Detection{}
+	}
+
+	// Japanese mixes kanji with kana; Chinese has no kana
+	if kana > 0 {
+		counts["ja"] = han + kana
+	} else if han > 0 {
+		counts["zh"] = han
+	}
+	if cyrillic > 0 {
+		counts[cyrillicLanguage(text)] = cyrillic
+	}
+	if arabic > 0 {
+		counts[arabicLanguage(text)] = arabic
+	}
+
+	best, bestCount := "", 0
+	for language, count := range counts {
+		if count > bestCount || (count == bestCount && language < best) {
+			best, bestCount = language, count
+		}
+	}
+	if latin > bestCount {
+		detection := detectLatin(text)
+		detection.Confidence *= float64(latin) / float64(letters)
+		return detection
+	}
+	return Detection{Language: best, Confidence: float64(bestCount) / float64(letters)}
+}
+
+// detectLatin tells the languages written in the Latin script apart by
+// their frequent words and letters
+func detectLatin(text string) Detection {
+	scores := make(map[string]float64)
+	lower := strings.ToLower(text)
+	for _, word := range strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '-'
+	}) {
+		for language, words := range latinWords {
+			if words[word] {
+				scores[language]++
+			}
+		}
+	}
+	for _, r := range lower {
+		for _, language := range latinLetters[r] {
+			scores[language] += 0.5
+		}
+	}
+
+	best, second := "", 0.0
+	for language, score := range scores {
+		if score > scores[best] || (score == scores[best] && language < best) {
+			if best != "" {
+				second = max(second, scores[best])
+			}
+			best = language
+		} else {
+			second = max(second, score)
+		}
+	}
+	if best == "" {
+		return Detection{}
+	}
+
+	// Confident when the best language stands out and enough words matched
+	confidence := scores[best] / (scores[best] + second)
+	confidence *= min(scores[best]/3, 1)
+	return Detection{Language: best, Confidence: confidence}
+}
+
+// cyrillicLanguage tells Ukrainian from Russian by letters only Ukrainian uses
+func cyrillicLanguage(text string) string {
+	if strings.ContainsAny(strings.ToLower(text), "іїєґ") {
+		return "uk"
+	}
+	return "ru"
+}
+
+// arabicLanguage tells Persian from Arabic by letters only Persian uses
+func arabicLanguage(text string) string {
+	if strings.ContainsAny(text, "پچژگ") {
+		return "fa"
+	}
+	return "ar"
+}
+
+// wordSet returns the set of the space-separated words
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
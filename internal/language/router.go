@@ -0,0 +1,169 @@
+package language
+
+import (
+	"context"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// DefaultMinConfidence is the confidence below which a detected language is ignored
+const DefaultMinConfidence = 0.5
+
+// Any matches prompts in every language, including undetected ones
+const Any = config.LanguageAny
+
+// Rule applies a system instruction and routing to prompts in one of its languages
+type Rule struct {
+	// Languages are ISO 639-1 codes, or Any
+	Languages []string
+
+	// SystemPrompt is added to the system message, e.g. "Antworte auf
+	// Deutsch und sieze den Nutzer."
+	SystemPrompt string
+
+	// Provider and Model replace those of the request, if set
+	Provider providers.ProviderType
+	Model    string
+}
+
+// matches reports whether the rule applies to prompts in language
+func (r Rule) matches(language string) bool {
+	for _, code := range r.Languages {
+		if code == Any || (language != "" && code == language) {
+			return true
+		}
+	}
+	return false
+}
+
+// Router applies locale-specific rules to chat requests by the language of their prompt
+type Router struct {
+	rules         []Rule
+	minConfidence float64
+	detect        Detector
+}
+
+// NewRouter creates a router applying the first matching rule of rules, detecting
+// languages with Detect
+func NewRouter(rules ...Rule) *Router {
+	return &Router{rules: rules, minConfidence: DefaultMinConfidence, detect: Detect}
+}
+
+// NewRouterFromConfig creates a router from the language rules of the configuration
+func NewRouterFromConfig(cfg config.LanguageConfig) *Router {
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, ruleCfg := range cfg.Rules {
+		rules = append(rules, Rule{
+			Languages:    ruleCfg.Languages,
+			SystemPrompt: ruleCfg.SystemPrompt,
+			Provider:     providers.ProviderType(ruleCfg.Provider),
+			Model:        ruleCfg.Model,
+		})
+	}
+	router := NewRouter(rules...)
+	if cfg.MinConfidence > 0 {
+		router.minConfidence = cfg.MinConfidence
+	}
+	return router
+}
+
+// SetDetector replaces Detect, e.g. with a statistical detector
+func (r *Router) SetDetector(detect Detector) {
+	r.detect = detect
+}
+
+// SetMinConfidence sets the confidence below which a detected language is ignored
+func (r *Router) SetMinConfidence(confidence float64) {
+	r.minConfidence = confidence
+}
+
+// Detect returns the language of the last user message of messages. The
+// language set on ctx with WithLanguage takes precedence. Detections below
+// the minimum confidence have an empty language.
+func (r *Router) Detect(ctx context.Context, messages []providers.Message) Detection {
+	if language := FromContext(ctx); language != "" {
+		return Detection{Language: language, Confidence: 1}
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		detection := r.detect(messages[i].Content)
+		if detection.Confidence < r.minConfidence {
+			detection.Language = ""
+		}
+		return detection
+	}
+	return Detection{}
+}
+
+// Middleware returns middleware that detects the language of each request
+// and applies the first rule matching it: its system prompt is added and its
+// provider and model replace those of the request. The language is available
+// to later middleware with FromContext and is set as "language" in the
+// response's metadata. Install it with UnifiedProvider.Use.
+func (r *Router) Middleware() providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+			detection := r.Detect(ctx, messages)
+			if detection.Language != "" {
+				ctx = WithLanguage(ctx, detection.Language)
+			}
+			for _, rule := range r.rules {
+				if rule.matches(detection.Language) {
+					messages, opts = rule.apply(messages, opts)
+					break
+				}
+			}
+
+			resp, err := next(ctx, messages, opts)
+			if err != nil || detection.Language == "" {
+				return resp, err
+			}
+			if resp.Metadata == nil {
+				resp.Metadata = make(map[string]interface{})
+			}
+			resp.Metadata["language"] = detection.Language
+			return resp, nil
+		}
+	}
+}
+
+// apply returns messages and opts with the rule applied. The system prompt is
+// appended to a leading system message, or sent as a new one.
+func (r Rule) apply(messages []providers.Message, opts providers.RequestOptions) ([]providers.Message, providers.RequestOptions) {
+	if r.Provider != "" {
+		opts.Provider = r.Provider
+	}
+	if r.Model != "" {
+		opts.Model = r.Model
+	}
+	if r.SystemPrompt == "" {
+		return messages, opts
+	}
+
+	if len(messages) > 0 && messages[0].Role == "system" {
+		applied := append([]providers.Message(nil), messages...)
+		applied[0].Content += "\n\n" + r.SystemPrompt
+		return applied, opts
+	}
+	applied := make([]providers.Message, 0, len(messages)+1)
+	applied = append(applied, providers.Message{Role: "system", Content: r.SystemPrompt})
+	return append(applied, messages...), opts
+}
+
+// contextKey is the type of the language context key
+type contextKey struct{}
+
+// WithLanguage returns a context whose requests are treated as being in
+// language, e.g. the locale of the signed-in user, without detection
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, contextKey{}, language)
+}
+
+// FromContext returns the language set on ctx, or "" if none is set
+func FromContext(ctx context.Context) string {
+	language, _ := ctx.Value(contextKey{}).(string)
+	return language
+}
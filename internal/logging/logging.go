@@ -0,0 +1,38 @@
+// Package logging defines the logger gollmkit components write to
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger receives log records as a message followed by alternating keys and
+// values. *slog.Logger implements it. Records never contain key material.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// OrDefault returns logger, or slog.Default() if it is nil. The default
+// writes info records and above through the standard log package.
+func OrDefault(logger Logger) Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// Discard returns a logger dropping every record
+func Discard() Logger {
+	return slog.New(discardHandler{})
+}
+
+// discardHandler is a slog.Handler that is disabled at every level
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/useragent"
+)
+
+// maxEventSize bounds the lines of event streams, which hold whole messages
+const maxEventSize = 16 << 20
+
+// httpTransport exchanges messages with a server over the Streamable HTTP
+// transport: each message is POSTed, and the response is either a JSON body
+// or an event stream ending with the response
+type httpTransport struct {
+	url    string
+	client *http.Client
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+// NewHTTP connects to the MCP server at url over the Streamable HTTP transport.
+// ctx bounds the connection handshake.
+func NewHTTP(ctx context.Context, url string, opts Options) (*Client, error) {
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	t := &httpTransport{url: url, client: useragent.Wrap(client, "", opts.Header)}
+	return connect(ctx, t, opts)
+}
+
+// setProtocolVersion sets the negotiated revision sent with later requests
+func (t *httpTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.protocolVersion = version
+}
+
+// session returns the session ID and protocol version to send
+func (t *httpTransport) session() (string, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID, t.protocolVersion
+}
+
+// newRequest creates a request to the server carrying the session headers
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, version := t.session()
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	if version != "" {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+	return req, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, rpc request) (*message, error) {
+	data, err := json.Marshal(rpc)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" && rpc.Method == "initialize" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && req.Header.Get("Mcp-Session-Id") != "" {
+		return nil, fmt.Errorf("%w: session expired", ErrClosed)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("mcp server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if rpc.ID == nil {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return t.readStream(ctx, resp.Body, *rpc.ID)
+	}
+	var msg message
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("decoding mcp response: %w", err)
+	}
+	return &msg, nil
+}
+
+// readStream reads the events of a response stream until the response with
+// id, answering the requests of the server on the way
+func (t *httpTransport) readStream(ctx context.Context, r io.Reader, id int64) (*message, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	var data []string
+	for {
+		more := scanner.Scan()
+		line := scanner.Text()
+		if more && line != "" {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+
+		if len(data) > 0 {
+			var msg message
+			if json.Unmarshal([]byte(strings.Join(data, "\n")), &msg) == nil {
+				if msg.Method != "" && len(msg.ID) > 0 {
+					t.reply(ctx, serverRequestReply(&msg))
+				} else if msgID, ok := responseID(&msg); ok && msgID == id {
+					return &msg, nil
+				}
+			}
+			data = data[:0]
+		}
+		if !more {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: event stream ended without a response", ErrClosed)
+		}
+	}
+}
+
+// reply posts the reply to a request of the server
+func (t *httpTransport) reply(ctx context.Context, reply interface{}) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if resp, err := t.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// close ends the session, if the server assigned one
+func (t *httpTransport) close() error {
+	sessionID, _ := t.session()
+	if sessionID == "" {
+		return nil
+	}
+	req, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	t.mu.Lock()
+	t.sessionID = ""
+	t.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,315 @@
+// Package mcp is a Model Context Protocol client. It connects to MCP servers
+// over stdio or HTTP, lists the tools they expose and bridges them to the
+// tools package, so models can call them in chat requests and agents.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+	"github.com/gollmkit/gollmkit/internal/tools"
+	"github.com/gollmkit/gollmkit/internal/useragent"
+)
+
+// ProtocolVersion is the MCP revision the client requests
+const ProtocolVersion = "2025-03-26"
+
+// ErrClosed is returned by calls on a client whose connection was closed
+var ErrClosed = errors.New("mcp connection closed")
+
+// Error is a JSON-RPC error returned by a server
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Options configures a client
+type Options struct {
+	// Prefix is prepended to the names of the tools of the server, to tell
+	// apart tools of the same name on several servers, e.g. "github_"
+	Prefix string
+
+	// ClientName identifies the client to the server. Defaults to "gollmkit".
+	ClientName string
+
+	// HTTPClient sends the requests of HTTP servers. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Header is sent with every request to HTTP servers, e.g. Authorization
+	Header map[string]string
+
+	// Env is added to the environment of stdio servers, as "KEY=value" entries
+	Env []string
+	// Stderr receives the standard error of stdio servers. Discarded if nil.
+	Stderr io.Writer
+}
+
+// ServerInfo describes the server a client is connected to
+type ServerInfo struct {
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	ProtocolVersion string `json:"-"`
+	// Instructions tell the model how to use the server, if it gives any
+	Instructions string `json:"-"`
+}
+
+// Tool is a tool exposed by a server
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+}
+
+// Content is an item of the result of a tool call: text, an image or audio
+// as base64 Data, or an embedded resource
+type Content struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Data     string          `json:"data,omitempty"`
+	MimeType string          `json:"mimeType,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"`
+}
+
+// CallResult is the result of a tool call. IsError reports a failure of the
+// tool itself, described by the content, rather than of the protocol.
+type CallResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// Text returns the text of the result, with non-text items described by
+// their type, e.g. "[image image/png]"
+func (r *CallResult) Text() string {
+	if len(r.Content) == 0 && len(r.StructuredContent) > 0 {
+		return string(r.StructuredContent)
+	}
+	parts := make([]string, 0, len(r.Content))
+	for _, item := range r.Content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.MimeType != "":
+			parts = append(parts, fmt.Sprintf("[%s %s]", item.Type, item.MimeType))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s]", item.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// request is an outgoing JSON-RPC request, or a notification if ID is nil
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// message is an incoming JSON-RPC message: a response, or a request or
+// notification of the server if Method is set
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// transport exchanges JSON-RPC messages with a server
+type transport interface {
+	// roundTrip sends req and returns the response to it, or nil for notifications
+	roundTrip(ctx context.Context, req request) (*message, error)
+	close() error
+}
+
+// Client is a connection to an MCP server. It is safe for concurrent use.
+type Client struct {
+	transport transport
+	opts      Options
+	nextID    atomic.Int64
+	server    ServerInfo
+}
+
+// connect initializes the session of a client over t
+func connect(ctx context.Context, t transport, opts Options) (*Client, error) {
+	if opts.ClientName == "" {
+		opts.ClientName = "gollmkit"
+	}
+	c := &Client{transport: t, opts: opts}
+
+	params := map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": opts.ClientName, "version": useragent.Version()},
+	}
+	var result struct {
+		ProtocolVersion string     `json:"protocolVersion"`
+		ServerInfo      ServerInfo `json:"serverInfo"`
+		Instructions    string     `json:"instructions"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initializing mcp session: %w", err)
+	}
+	c.server = result.ServerInfo
+	c.server.ProtocolVersion = result.ProtocolVersion
+	c.server.Instructions = result.Instructions
+
+	if ht, ok := t.(*httpTransport); ok {
+		ht.setProtocolVersion(result.ProtocolVersion)
+	}
+	if _, err := t.roundTrip(ctx, request{JSONRPC: "2.0", Method: "notifications/initialized"}); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initializing mcp session: %w", err)
+	}
+	return c, nil
+}
+
+// Server returns the server the client is connected to
+func (c *Client) Server() ServerInfo {
+	return c.server
+}
+
+// ListTools returns the tools exposed by the server
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, fmt.Errorf("listing mcp tools: %w", err)
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == "" || result.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool calls the tool name of the server with the JSON arguments object.
+// A failure of the tool is reported by CallResult.IsError, not an error.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	var result CallResult
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return nil, fmt.Errorf("calling mcp tool %q: %w", name, err)
+	}
+	return &result, nil
+}
+
+// Tools lists the tools of the server and returns them as tools the model
+// can call, named with the Prefix of the client. Their calls are sent to
+// the server; failed calls return the text of the result as their error.
+func (c *Client) Tools(ctx context.Context) ([]*tools.Tool, error) {
+	listed, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bridged := make([]*tools.Tool, 0, len(listed))
+	for _, tool := range listed {
+		name := tool.Name
+		definition := providers.Tool{
+			Name:        c.opts.Prefix + name,
+			Description: tool.Description,
+			Parameters:  tool.InputSchema,
+		}
+		bridged = append(bridged, tools.Func(definition, func(ctx context.Context, arguments string) (string, error) {
+			result, err := c.CallTool(ctx, name, json.RawMessage(arguments))
+			if err != nil {
+				return "", err
+			}
+			if result.IsError {
+				return "", errors.New(result.Text())
+			}
+			return result.Text(), nil
+		}))
+	}
+	return bridged, nil
+}
+
+// Register adds the tools of the server to set. Call it again to pick up
+// changes to the tools of the server.
+func (c *Client) Register(ctx context.Context, set *tools.Set) error {
+	bridged, err := c.Tools(ctx)
+	if err != nil {
+		return err
+	}
+	set.Add(bridged...)
+	return nil
+}
+
+// Ping checks that the server is responsive
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, "ping", nil, nil)
+}
+
+// Close ends the session and releases the connection
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+// call sends a request and decodes the result of its response into result
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	id := c.nextID.Add(1)
+	resp, err := c.transport.roundTrip(ctx, request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("no response to %s", method)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("decoding %s result: %w", method, err)
+	}
+	return nil
+}
+
+// responseID returns the numeric ID of a response, false for other IDs
+func responseID(msg *message) (int64, bool) {
+	var id int64
+	if len(msg.ID) == 0 || json.Unmarshal(msg.ID, &id) != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// serverRequestReply returns the reply to a request of the server. Only
+// pings are supported; the client declares no other capabilities.
+func serverRequestReply(msg *message) map[string]interface{} {
+	reply := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		reply["result"] = map[string]interface{}{}
+	} else {
+		reply["error"] = &Error{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	return reply
+}
@@ -0,0 +1,455 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rpcMessage is a JSON-RPC message as seen by the test server
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+var (
+	echoTool = Tool{Name: "echo", Description: "Repeats text", InputSchema: map[string]interface{}{"type": "object"}}
+	failTool = Tool{Name: "fail", Description: "Always fails"}
+)
+
+// testServer answers requests like an MCP server exposing echoTool and
+// failTool, listed on two pages
+type testServer struct {
+	t *testing.T
+
+	mu          sync.Mutex
+	methods     []string // methods received, in order
+	pingReplies int      // replies of the client to pings of the server
+	expired     bool     // the HTTP session is gone
+}
+
+// record notes a message received by the server
+func (s *testServer) record(msg rpcMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.Method == "" {
+		if string(msg.ID) == `"server-1"` && string(msg.Result) == "{}" {
+			s.pingReplies++
+		} else {
+			s.t.Errorf("unexpected reply %s %s", msg.ID, msg.Result)
+		}
+		return
+	}
+	s.methods = append(s.methods, msg.Method)
+}
+
+// received returns the methods received and the number of ping replies
+func (s *testServer) received() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.methods...), s.pingReplies
+}
+
+// respond returns the encoded response to a request
+func (s *testServer) respond(req rpcMessage) []byte {
+	resp := rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	result, rpcErr := s.handle(req)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		resp.Result, _ = json.Marshal(result)
+	}
+	data, _ := json.Marshal(resp)
+	return data
+}
+
+// handle returns the result of a request, or its error
+func (s *testServer) handle(req rpcMessage) (interface{}, *Error) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			ClientInfo      struct {
+				Name string `json:"name"`
+			} `json:"clientInfo"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if params.ProtocolVersion != ProtocolVersion || params.ClientInfo.Name != "test-client" {
+			s.t.Errorf("initialize params %s", req.Params)
+		}
+		return map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"serverInfo":      map[string]string{"name": "test-server", "version": "1.0"},
+			"instructions":    "Call echo to repeat text.",
+		}, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if params.Cursor == "" {
+			return map[string]interface{}{"tools": []Tool{echoTool}, "nextCursor": "page-2"}, nil
+		}
+		return map[string]interface{}{"tools": []Tool{failTool}}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		json.Unmarshal(req.Params, &params)
+		switch params.Name {
+		case "echo":
+			return CallResult{Content: []Content{{Type: "text", Text: params.Arguments.Text}}}, nil
+		case "fail":
+			return CallResult{Content: []Content{{Type: "text", Text: "boom"}, {Type: "image", MimeType: "image/png"}}, IsError: true}, nil
+		}
+		return nil, &Error{Code: -32602, Message: "unknown tool: " + params.Name}
+	}
+	return nil, &Error{Code: -32601, Message: "method not found: " + req.Method}
+}
+
+// serveStdio answers newline-delimited requests from r on w. It pings the
+// client before the first page of tools. A tools/call request with
+// "hold":true in its arguments is answered after the next request, and held
+// is closed when it arrives.
+func (s *testServer) serveStdio(r io.Reader, w io.Writer, held chan<- struct{}) {
+	// Lines are written by another goroutine, as the buffer of an OS pipe
+	// lets a server read requests while the client is busy
+	lines := make(chan []byte, 64)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for line := range lines {
+			w.Write(line)
+		}
+	}()
+	defer func() {
+		close(lines)
+		<-written
+	}()
+
+	lines <- []byte("test server starting\n") // servers may log to stdout
+
+	var pending []byte
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var req rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			s.t.Errorf("invalid message %q: %v", scanner.Text(), err)
+			continue
+		}
+		s.record(req)
+		if req.Method == "" || len(req.ID) == 0 {
+			continue // replies and notifications
+		}
+		if req.Method == "tools/list" && !bytes.Contains(req.Params, []byte("cursor")) {
+			lines <- []byte(`{"jsonrpc":"2.0","id":"server-1","method":"ping"}` + "\n")
+		}
+
+		resp := append(s.respond(req), '\n')
+		if req.Method == "tools/call" && bytes.Contains(req.Params, []byte(`"hold":true`)) {
+			pending = resp
+			close(held)
+			continue
+		}
+		lines <- resp
+		if pending != nil {
+			lines <- pending
+			pending = nil
+		}
+	}
+}
+
+// newStdioClient connects to s through pipes with the transport of NewStdio.
+// Closing the returned writer ends the output of the server.
+func newStdioClient(t *testing.T, s *testServer, held chan<- struct{}) (*Client, *stdioTransport, io.Closer) {
+	t.Helper()
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.serveStdio(serverIn, serverOut, held)
+	}()
+	t.Cleanup(func() {
+		clientOut.Close()
+		serverOut.Close()
+		<-served
+	})
+
+	transport := &stdioTransport{
+		stdin:   clientOut,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go transport.read(clientIn)
+	client, err := connect(context.Background(), transport, Options{ClientName: "test-client", Prefix: "test_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, transport, serverOut
+}
+
+// testClient runs the calls shared by the stdio and HTTP tests
+func testClient(t *testing.T, client *Client) {
+	ctx := context.Background()
+
+	want := ServerInfo{Name: "test-server", Version: "1.0", ProtocolVersion: ProtocolVersion, Instructions: "Call echo to repeat text."}
+	if server := client.Server(); server != want {
+		t.Errorf("server %+v, want %+v", server, want)
+	}
+
+	listed, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listed, []Tool{echoTool, failTool}) {
+		t.Errorf("tools %+v", listed)
+	}
+
+	result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || result.Text() != "hello" {
+		t.Errorf("echo result %+v", result)
+	}
+
+	result, err = client.CallTool(ctx, "fail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || result.Text() != "boom\n[image image/png]" {
+		t.Errorf("fail result %+v", result)
+	}
+
+	_, err = client.CallTool(ctx, "missing", nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 || rpcErr.Message != "unknown tool: missing" {
+		t.Errorf("missing tool: %v", err)
+	}
+	if err == nil || err.Error() != `calling mcp tool "missing": mcp error -32602: unknown tool: missing` {
+		t.Errorf("missing tool error %v", err)
+	}
+
+	if err := client.call(ctx, "resources/list", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("unknown method: %v", err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+
+	bridged, err := client.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bridged) != 2 || bridged[0].Name() != "test_echo" || bridged[1].Name() != "test_fail" {
+		t.Fatalf("bridged tools %v", bridged)
+	}
+	if def := bridged[0].Definition(); def.Description != echoTool.Description || !reflect.DeepEqual(def.Parameters, echoTool.InputSchema) {
+		t.Errorf("definition %+v", def)
+	}
+	if output, err := bridged[0].Call(ctx, `{"text":"bridged"}`); err != nil || output != "bridged" {
+		t.Errorf("bridged echo: %q, %v", output, err)
+	}
+	if _, err := bridged[1].Call(ctx, `{}`); err == nil || err.Error() != "boom\n[image image/png]" {
+		t.Errorf("bridged fail: %v", err)
+	}
+}
+
+func TestStdioClient(t *testing.T) {
+	server := &testServer{t: t}
+	client, transport, serverOut := newStdioClient(t, server, nil)
+
+	testClient(t, client)
+
+	methods, pingReplies := server.received()
+	if len(methods) < 2 || methods[0] != "initialize" || methods[1] != "notifications/initialized" {
+		t.Errorf("methods %v don't start with the handshake", methods)
+	}
+	if pingReplies != 2 {
+		t.Errorf("%d replies to pings, want 2", pingReplies)
+	}
+
+	// The server exits
+	serverOut.Close()
+	<-transport.done
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("ping after exit: %v, want ErrClosed", err)
+	}
+}
+
+// TestStdioClientOutOfOrder delivers responses to the request of their ID
+// when the server answers concurrent requests out of order
+func TestStdioClientOutOfOrder(t *testing.T) {
+	held := make(chan struct{})
+	client, _, _ := newStdioClient(t, &testServer{t: t}, held)
+	ctx := context.Background()
+
+	first := make(chan string, 1)
+	go func() {
+		result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"first","hold":true}`))
+		if err != nil {
+			t.Error(err)
+			first <- ""
+			return
+		}
+		first <- result.Text()
+	}()
+
+	<-held
+	result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"second"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Text(); text != "second" {
+		t.Errorf("second call returned %q", text)
+	}
+	if text := <-first; text != "first" {
+		t.Errorf("first call returned %q", text)
+	}
+}
+
+// ServeHTTP answers requests over the Streamable HTTP transport. Tool calls
+// are answered with an event stream carrying a ping of the server and the
+// response to another request ahead of their own response.
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req rpcMessage
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.t.Errorf("invalid message: %v", err)
+		}
+	}
+
+	if req.Method == "initialize" {
+		w.Header().Set("Mcp-Session-Id", "session-1")
+	} else {
+		if session := r.Header.Get("Mcp-Session-Id"); session != "session-1" {
+			s.t.Errorf("%s %s: session %q", r.Method, req.Method, session)
+		}
+		if version := r.Header.Get("MCP-Protocol-Version"); version != ProtocolVersion {
+			s.t.Errorf("%s %s: protocol version %q", r.Method, req.Method, version)
+		}
+	}
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		s.t.Errorf("%s %s: authorization %q", r.Method, req.Method, r.Header.Get("Authorization"))
+	}
+
+	s.mu.Lock()
+	expired := s.expired
+	s.mu.Unlock()
+	switch {
+	case expired:
+		http.NotFound(w, r)
+		return
+	case r.Method == http.MethodDelete:
+		s.record(rpcMessage{Method: "DELETE"})
+		return
+	case bytes.Contains(req.Params, []byte(`"crash"`)):
+		http.Error(w, "internal failure", http.StatusInternalServerError)
+		return
+	}
+
+	s.record(req)
+	if req.Method == "" || len(req.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	resp := s.respond(req)
+	if req.Method != "tools/call" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+		return
+	}
+
+	var id int64
+	json.Unmarshal(req.ID, &id)
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":\"server-1\",\"method\":\"ping\"}\n\n")
+	fmt.Fprintf(w, ": keep-alive\n\ndata: {\"jsonrpc\":\"2.0\",\"id\":%d,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"stale\"}]}}\n\n", id+100)
+	fmt.Fprintf(w, "id: 3\ndata: %s\n\n", resp)
+}
+
+func TestHTTPClient(t *testing.T) {
+	server := &testServer{t: t}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := NewHTTP(context.Background(), ts.URL, Options{
+		ClientName: "test-client",
+		Prefix:     "test_",
+		HTTPClient: ts.Client(),
+		Header:     map[string]string{"Authorization": "Bearer test-token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testClient(t, client)
+
+	_, err = client.CallTool(context.Background(), "crash", nil)
+	if err == nil || !strings.Contains(err.Error(), "mcp server returned status 500: internal failure") {
+		t.Errorf("crash: %v", err)
+	}
+
+	// Each of the five streamed tool calls carried a ping of the server
+	methods, pingReplies := server.received()
+	if len(methods) < 2 || methods[0] != "initialize" || methods[1] != "notifications/initialized" {
+		t.Errorf("methods %v don't start with the handshake", methods)
+	}
+	if pingReplies != 5 {
+		t.Errorf("%d replies to pings, want 5", pingReplies)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if methods, _ := server.received(); methods[len(methods)-1] != "DELETE" {
+		t.Errorf("session wasn't deleted: %v", methods)
+	}
+}
+
+// TestHTTPClientSessionExpired reports calls in a session the server no
+// longer knows as closed
+func TestHTTPClientSessionExpired(t *testing.T) {
+	server := &testServer{t: t}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := NewHTTP(context.Background(), ts.URL, Options{
+		ClientName: "test-client",
+		Header:     map[string]string{"Authorization": "Bearer test-token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	server.expired = true
+	server.mu.Unlock()
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("ping in expired session: %v, want ErrClosed", err)
+	}
+}
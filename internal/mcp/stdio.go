@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// stdioCloseTimeout is how long a stdio server may take to exit after its
+// input is closed before it is killed
+const stdioCloseTimeout = 5 * time.Second
+
+// stdioTransport exchanges newline-delimited messages with a server process
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan *message
+	done    chan struct{} // closed when the server stops responding
+	err     error         // why it stopped, set before done is closed
+	once    sync.Once
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewStdio starts command with args as an MCP server speaking over its
+// standard input and output, and connects to it. ctx bounds the connection
+// handshake; the server runs until Close.
+func NewStdio(ctx context.Context, command string, args []string, opts Options) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
+	cmd.Stderr = opts.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting mcp server %s: %w", command, err)
+	}
+
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go t.read(stdout)
+	return connect(ctx, t, opts)
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req request) (*message, error) {
+	var ch chan *message
+	if req.ID != nil {
+		ch = make(chan *message, 1)
+		t.mu.Lock()
+		t.pending[*req.ID] = ch
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.pending, *req.ID)
+			t.mu.Unlock()
+		}()
+	}
+
+	if err := t.write(req); err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, nil
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// write sends a message as one line
+func (t *stdioTransport) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	select {
+	case <-t.done:
+		return t.err
+	default:
+	}
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+// read dispatches the messages of the server until its output ends
+func (t *stdioTransport) read(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			t.stop(fmt.Errorf("%w: %v", ErrClosed, err))
+			return
+		}
+	}
+}
+
+// dispatch delivers a response to its waiting request and answers requests of the server
+func (t *stdioTransport) dispatch(line []byte) {
+	var msg message
+	if json.Unmarshal(line, &msg) != nil {
+		return // servers may log to stdout by mistake
+	}
+	if msg.Method != "" {
+		if len(msg.ID) > 0 {
+			t.write(serverRequestReply(&msg))
+		}
+		return // notifications aren't used
+	}
+
+	id, ok := responseID(&msg)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	ch := t.pending[id]
+	t.mu.Unlock()
+	if ch != nil {
+		ch <- &msg
+	}
+}
+
+// stop fails pending and future requests with err
+func (t *stdioTransport) stop(err error) {
+	t.once.Do(func() {
+		t.err = err
+		close(t.done)
+	})
+}
+
+func (t *stdioTransport) close() error {
+	t.closeOnce.Do(func() { t.closeErr = t.shutdown() })
+	return t.closeErr
+}
+
+// shutdown closes the input of the server and waits for it to exit, killing
+// it if it doesn't in time
+func (t *stdioTransport) shutdown() error {
+	t.stop(ErrClosed)
+	t.writeMu.Lock()
+	t.stdin.Close()
+	t.writeMu.Unlock()
+
+	exited := make(chan error, 1)
+	go func() { exited <- t.cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-exited:
+	case <-time.After(stdioCloseTimeout):
+		t.cmd.Process.Kill()
+		err = <-exited
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return nil // servers may exit non-zero when their input closes
+	}
+	return err
+}
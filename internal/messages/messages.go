@@ -0,0 +1,152 @@
+// Package messages cleans up chat histories before they are sent: it drops
+// empty messages, merges consecutive messages of a role and adapts histories
+// to the role rules of providers
+package messages
+
+import "strings"
+
+// Roles of chat messages
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // the result of a tool call
+)
+
+// Placeholder is the content of the user message inserted ahead of
+// histories starting with an assistant turn, for providers that require the
+// user to speak first
+const Placeholder = "(continued)"
+
+// Message represents a chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// ToolCalls are the tools an assistant message calls
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is the call whose result a tool message holds
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// Attachments are the documents and images of a user message, sent
+	// ahead of its content
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a document or image attached to a message: either a file
+// uploaded to a provider, referenced by FileID or URI, or inline Data
+type Attachment struct {
+	FileID   string `json:"file_id,omitempty"`   // OpenAI and Anthropic file ID
+	URI      string `json:"uri,omitempty"`       // Gemini file URI
+	MIMEType string `json:"mime_type,omitempty"` // selects image or document blocks
+	Filename string `json:"filename,omitempty"`
+	Provider string `json:"provider,omitempty"` // provider holding the file
+	KeyName  string `json:"key_name,omitempty"` // key that uploaded the file
+
+	// Data is the content of inline attachments, sent with the message
+	Data []byte `json:"data,omitempty"`
+}
+
+// Inline reports whether the attachment carries its content rather than referring to an uploaded file
+func (a Attachment) Inline() bool {
+	return a.Data != nil
+}
+
+// ToolCall is a call of a tool requested by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON object
+}
+
+// plain reports whether msg is text only, neither calling tools, holding a
+// tool result nor carrying attachments
+func (msg Message) plain() bool {
+	return len(msg.ToolCalls) == 0 && msg.Role != RoleTool && len(msg.Attachments) == 0
+}
+
+// Rules are the constraints a provider puts on the roles of a conversation.
+// Consecutive user and assistant turns are always merged, so they alternate.
+type Rules struct {
+	// SystemFirst merges all system messages into one at the start, for
+	// providers that take the system prompt separately from the turns
+	SystemFirst bool
+
+	// UserFirst inserts a Placeholder user message when the first turn after
+	// the system prompt isn't from the user
+	UserFirst bool
+}
+
+// Provider rules
+var (
+	// AnthropicRules require a single leading system prompt and turns that
+	// alternate starting with the user
+	AnthropicRules = Rules{SystemFirst: true, UserFirst: true}
+
+	// GeminiRules require the system instruction to precede the contents,
+	// which must start with a user turn
+	GeminiRules = Rules{SystemFirst: true, UserFirst: true}
+)
+
+// StripEmpty returns msgs without the text messages whose content is blank.
+// Tool calls and results are kept, as each call must be answered.
+func StripEmpty(msgs []Message) []Message {
+	stripped := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if !msg.plain() || strings.TrimSpace(msg.Content) != "" {
+			stripped = append(stripped, msg)
+		}
+	}
+	return stripped
+}
+
+// MergeConsecutive returns msgs with consecutive text messages of the same
+// role joined into one, their contents separated by a blank line
+func MergeConsecutive(msgs []Message) []Message {
+	merged := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if last := len(merged) - 1; last >= 0 && merged[last].Role == msg.Role && merged[last].plain() && msg.plain() {
+			merged[last].Content += "\n\n" + msg.Content
+			continue
+		}
+		merged = append(merged, msg)
+	}
+	return merged
+}
+
+// Compact strips empty messages and merges consecutive messages of a role
+func Compact(msgs []Message) []Message {
+	return MergeConsecutive(StripEmpty(msgs))
+}
+
+// Normalize compacts msgs and adapts them to rules. msgs itself is left unchanged.
+func Normalize(msgs []Message, rules Rules) []Message {
+	msgs = StripEmpty(msgs)
+
+	var system []string
+	if rules.SystemFirst {
+		turns := make([]Message, 0, len(msgs))
+		for _, msg := range msgs {
+			if msg.Role == RoleSystem {
+				system = append(system, msg.Content)
+			} else {
+				turns = append(turns, msg)
+			}
+		}
+		msgs = turns
+	}
+	msgs = MergeConsecutive(msgs)
+
+	start := 0
+	if len(msgs) > 0 && msgs[0].Role == RoleSystem {
+		start = 1
+	}
+	if rules.UserFirst && len(msgs) > start && msgs[start].Role != RoleUser {
+		msgs = append(msgs[:start], append([]Message{{Role: RoleUser, Content: Placeholder}}, msgs[start:]...)...)
+	}
+
+	if len(system) > 0 {
+		msgs = append([]Message{{Role: RoleSystem, Content: strings.Join(system, "\n\n")}}, msgs...)
+	}
+	return msgs
+}
@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/pii"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// RegexChecker finds matches of regular expressions, reported under one category
+type RegexChecker struct {
+	category string
+	patterns []*regexp.Regexp
+}
+
+// NewRegexChecker creates a checker reporting matches of patterns as category
+func NewRegexChecker(category string, patterns ...string) (*RegexChecker, error) {
+	c := &RegexChecker{category: category}
+	for _, expr := range patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	return c, nil
+}
+
+// NewKeywordChecker creates a checker reporting case-insensitive whole-word
+// occurrences of keywords as category
+func NewKeywordChecker(category string, keywords ...string) (*RegexChecker, error) {
+	quoted := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		quoted[i] = regexp.QuoteMeta(keyword)
+	}
+	return NewRegexChecker(category, `(?i)\b(?:`+strings.Join(quoted, "|")+`)\b`)
+}
+
+// Check implements Checker
+func (c *RegexChecker) Check(ctx context.Context, text string) ([]Finding, error) {
+	var findings []Finding
+	for _, re := range c.patterns {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			findings = append(findings, Finding{
+				Category: c.category,
+				Match:    text[loc[0]:loc[1]],
+				Start:    loc[0],
+				End:      loc[1],
+			})
+		}
+	}
+	return findings, nil
+}
+
+// PIIChecker finds personally identifiable information, reported under its
+// kind such as EMAIL or PHONE
+type PIIChecker struct {
+	detector *pii.Detector
+}
+
+// NewPIIChecker creates a PII checker. A nil detector uses the built-in patterns.
+func NewPIIChecker(detector *pii.Detector) *PIIChecker {
+	if detector == nil {
+		detector = pii.NewDetector()
+	}
+	return &PIIChecker{detector: detector}
+}
+
+// Check implements Checker
+func (c *PIIChecker) Check(ctx context.Context, text string) ([]Finding, error) {
+	matches := c.detector.Find(text)
+	findings := make([]Finding, len(matches))
+	for i, m := range matches {
+		findings[i] = Finding{Category: string(m.Kind), Match: m.Value, Start: m.Start, End: m.End}
+	}
+	return findings, nil
+}
+
+// Moderator classifies text with a provider's moderation endpoint. It is
+// implemented by providers.UnifiedProvider.
+type Moderator interface {
+	Moderate(ctx context.Context, inputs []string, opts providers.ModerationOptions) ([]providers.ModerationResult, error)
+}
+
+// OpenAIChecker classifies text with OpenAI's moderation endpoint, reporting
+// each flagged category with its score
+type OpenAIChecker struct {
+	moderator Moderator
+	opts      providers.ModerationOptions
+}
+
+// NewOpenAIChecker creates a checker using moderator with opts
+func NewOpenAIChecker(moderator Moderator, opts providers.ModerationOptions) *OpenAIChecker {
+	return &OpenAIChecker{moderator: moderator, opts: opts}
+}
+
+// Check implements Checker
+func (c *OpenAIChecker) Check(ctx context.Context, text string) ([]Finding, error) {
+	results, err := c.moderator.Moderate(ctx, []string{text}, c.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, result := range results {
+		for category, flagged := range result.Categories {
+			if flagged {
+				findings = append(findings, Finding{
+					Category: category,
+					Start:    -1,
+					End:      -1,
+					Score:    result.Scores[category],
+				})
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Category < findings[j].Category })
+	return findings, nil
+}
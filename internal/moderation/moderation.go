@@ -0,0 +1,437 @@
+// Package moderation runs chat inputs and outputs through guardrail rules
+// that block, redact or flag content
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// ErrContentBlocked is wrapped by the errors of requests and responses
+// blocked by a rule. Use errors.As with *BlockedError for the details.
+var ErrContentBlocked = errors.New("content blocked")
+
+// Action is what a rule does with matching content
+type Action string
+
+const (
+	ActionBlock  Action = config.ModerationBlock
+	ActionRedact Action = config.ModerationRedact
+	ActionFlag   Action = config.ModerationFlag
+)
+
+// Stage is the point of a request where a rule applies
+type Stage string
+
+const (
+	StageInput  Stage = config.ModerationInput
+	StageOutput Stage = config.ModerationOutput
+	StageBoth   Stage = config.ModerationBoth
+)
+
+// Finding is content matched by a checker. Start and End delimit the match in
+// the checked text; they are -1 for checkers that classify the text as a whole.
+type Finding struct {
+	Category string  `json:"category"`
+	Match    string  `json:"match,omitempty"`
+	Start    int     `json:"start"`
+	End      int     `json:"end"`
+	Score    float64 `json:"score,omitempty"`
+}
+
+// Checker finds problematic content in text
+type Checker interface {
+	Check(ctx context.Context, text string) ([]Finding, error)
+}
+
+// Rule applies an action to the findings of a checker
+type Rule struct {
+	Name    string
+	Checker Checker
+	Action  Action
+
+	// Stage defaults to StageBoth
+	Stage Stage
+
+	// Categories restricts the rule to findings of these categories, if set
+	Categories []string
+}
+
+// BlockedError reports content blocked by a rule
+type BlockedError struct {
+	Rule     string
+	Stage    Stage
+	Findings []Finding
+}
+
+// Error implements error
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s content blocked by moderation rule %q: %s",
+		e.Stage, e.Rule, strings.Join(e.Categories(), ", "))
+}
+
+// Unwrap returns ErrContentBlocked and providers.ErrContentFiltered, so the
+// error carries the GLK-400-CONTENT_FILTER code
+func (e *BlockedError) Unwrap() []error {
+	return []error{ErrContentBlocked, providers.ErrContentFiltered}
+}
+
+// Categories returns the distinct categories of the findings, sorted
+func (e *BlockedError) Categories() []string {
+	return categories(e.Findings)
+}
+
+// Flag reports content matched by a rule with ActionFlag
+type Flag struct {
+	Rule      string
+	Stage     Stage
+	Findings  []Finding
+	RequestID string // ID of the flagged request, if known
+}
+
+// Guard applies moderation rules to chat requests and responses
+type Guard struct {
+	rules  []Rule
+	onFlag func(ctx context.Context, flag Flag)
+	logger logging.Logger
+}
+
+// NewGuard creates a guard applying rules in order. Flags are logged as
+// warnings until a handler is set with OnFlag.
+func NewGuard(rules ...Rule) *Guard {
+	g := &Guard{rules: rules}
+	g.onFlag = g.logFlag
+	return g
+}
+
+// NewGuardFromConfig creates a guard from the moderation rules of the
+// configuration. moderator serves openai rules and may be nil if there are none.
+func NewGuardFromConfig(cfg config.ModerationConfig, moderator Moderator) (*Guard, error) {
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, ruleCfg := range cfg.Rules {
+		label := ruleCfg.Name
+		if len(ruleCfg.Categories) > 0 {
+			label = ruleCfg.Categories[0]
+		}
+
+		var checker Checker
+		var err error
+		switch ruleCfg.Type {
+		case config.ModerationRegex:
+			checker, err = NewRegexChecker(label, ruleCfg.Patterns...)
+		case config.ModerationKeyword:
+			checker, err = NewKeywordChecker(label, ruleCfg.Patterns...)
+		case config.ModerationPII:
+			checker = NewPIIChecker(nil)
+		case config.ModerationOpenAI:
+			if moderator == nil {
+				err = errors.New("openai rules require a moderator")
+			} else {
+				checker = NewOpenAIChecker(moderator, providers.ModerationOptions{})
+			}
+		default:
+			err = fmt.Errorf("unknown rule type %q", ruleCfg.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("moderation rule %q: %w", ruleCfg.Name, err)
+		}
+
+		rules = append(rules, Rule{
+			Name:       ruleCfg.Name,
+			Checker:    checker,
+			Action:     Action(ruleCfg.Action),
+			Stage:      Stage(ruleCfg.Stage),
+			Categories: ruleCfg.Categories,
+		})
+	}
+	return NewGuard(rules...), nil
+}
+
+// OnFlag sets the handler called for content matched by flag rules
+func (g *Guard) OnFlag(fn func(ctx context.Context, flag Flag)) {
+	g.onFlag = fn
+}
+
+// SetLogger sets the logger flags are logged to while no OnFlag handler is
+// set, slog.Default() if unset
+func (g *Guard) SetLogger(logger logging.Logger) {
+	g.logger = logger
+}
+
+// Middleware returns middleware that checks the messages of a request before
+// it is sent and the response before it is returned. It only covers Chat and
+// Invoke; use Install to guard streamed requests too.
+func (g *Guard) Middleware() providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+			messages, err := g.checkMessages(ctx, messages)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next(ctx, messages, opts)
+			if err != nil {
+				return nil, err
+			}
+			if err := g.checkResponse(ctx, resp); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+	}
+}
+
+// StreamMiddleware returns stream middleware that checks the messages of a
+// request before the stream is opened. If the guard has output rules, the
+// response is held back until the stream ends, checked as a whole and
+// delivered as a single chunk, since text already streamed can't be blocked
+// or redacted. Thinking chunks are passed through.
+func (g *Guard) StreamMiddleware() providers.StreamMiddleware {
+	return func(next providers.StreamHandler) providers.StreamHandler {
+		return func(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (<-chan providers.StreamChunk, error) {
+			messages, err := g.checkMessages(ctx, messages)
+			if err != nil {
+				return nil, err
+			}
+
+			in, err := next(ctx, messages, opts)
+			if err != nil || !g.hasStage(StageOutput) {
+				return in, err
+			}
+			out := make(chan providers.StreamChunk)
+			go g.checkStream(ctx, in, out)
+			return out, nil
+		}
+	}
+}
+
+// BatchMiddleware returns batch middleware that checks the messages of every
+// request of a batch before any of them is submitted. Output rules aren't
+// applied to batch results.
+func (g *Guard) BatchMiddleware() providers.BatchMiddleware {
+	return func(next providers.BatchHandler) providers.BatchHandler {
+		return func(ctx context.Context, provider providers.ProviderType, requests []providers.BatchRequest) (*providers.Batch, error) {
+			checked := make([]providers.BatchRequest, len(requests))
+			for i, r := range requests {
+				messages, err := g.checkMessages(ctx, r.Messages)
+				if err != nil {
+					return nil, fmt.Errorf("batch request %q: %w", r.CustomID, err)
+				}
+				checked[i] = r
+				checked[i].Messages = messages
+			}
+			return next(ctx, provider, checked)
+		}
+	}
+}
+
+// Installer is a provider that middleware can be added to, such as
+// providers.UnifiedProvider
+type Installer interface {
+	Use(middleware ...providers.Middleware)
+	UseStream(middleware ...providers.StreamMiddleware)
+	UseBatch(middleware ...providers.BatchMiddleware)
+}
+
+// Install adds the guard to provider for chat, streamed and batch requests
+func (g *Guard) Install(provider Installer) {
+	provider.Use(g.Middleware())
+	provider.UseStream(g.StreamMiddleware())
+	provider.UseBatch(g.BatchMiddleware())
+}
+
+// checkStream collects the deltas of in and sends them to out as a single
+// chunk once the output rules passed
+func (g *Guard) checkStream(ctx context.Context, in <-chan providers.StreamChunk, out chan<- providers.StreamChunk) {
+	defer close(out)
+	send := func(chunk providers.StreamChunk) {
+		select {
+		case out <- chunk:
+		case <-ctx.Done():
+		}
+	}
+
+	var content strings.Builder
+	for chunk := range in {
+		switch {
+		case chunk.Error != nil:
+			send(chunk)
+		case chunk.Thinking != "":
+			send(providers.StreamChunk{Thinking: chunk.Thinking})
+		}
+		content.WriteString(chunk.Delta)
+		if chunk.Error != nil || (chunk.FinishReason == "" && chunk.Usage == nil) {
+			continue
+		}
+
+		// The final chunk
+		text, err := g.Apply(ctx, StageOutput, content.String())
+		if err != nil {
+			send(providers.StreamChunk{Error: err, RequestID: chunk.RequestID})
+			continue
+		}
+		if text != "" {
+			send(providers.StreamChunk{Delta: text})
+		}
+		chunk.Delta, chunk.Thinking = "", ""
+		send(chunk)
+	}
+}
+
+// hasStage reports whether any rule applies at stage
+func (g *Guard) hasStage(stage Stage) bool {
+	for _, rule := range g.rules {
+		if rule.Stage == "" || rule.Stage == StageBoth || rule.Stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply runs the rules of stage over text and returns it with redactions
+// applied, or a *BlockedError if a block rule matched
+func (g *Guard) Apply(ctx context.Context, stage Stage, text string) (string, error) {
+	for _, rule := range g.rules {
+		if rule.Stage != "" && rule.Stage != StageBoth && rule.Stage != stage {
+			continue
+		}
+
+		findings, err := rule.Checker.Check(ctx, text)
+		if err != nil {
+			// Fail closed: unchecked content must not get through
+			return "", fmt.Errorf("moderation rule %q failed: %w", rule.Name, err)
+		}
+		findings = filterCategories(findings, rule.Categories)
+		if len(findings) == 0 {
+			continue
+		}
+
+		switch rule.Action {
+		case ActionBlock:
+			return "", &BlockedError{Rule: rule.Name, Stage: stage, Findings: findings}
+		case ActionRedact:
+			text = redact(text, findings)
+		case ActionFlag:
+			if g.onFlag != nil {
+				g.onFlag(ctx, Flag{Rule: rule.Name, Stage: stage, Findings: findings, RequestID: providers.RequestIDFromContext(ctx)})
+			}
+		default:
+			return "", fmt.Errorf("moderation rule %q has unknown action %q", rule.Name, rule.Action)
+		}
+	}
+	return text, nil
+}
+
+// checkMessages applies the input rules to every message sent to the
+// model, whatever its role, returning a copy of messages with redactions applied
+func (g *Guard) checkMessages(ctx context.Context, messages []providers.Message) ([]providers.Message, error) {
+	checked := append([]providers.Message(nil), messages...)
+	for i, msg := range checked {
+		if msg.Content == "" {
+			continue
+		}
+		content, err := g.Apply(ctx, StageInput, msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		checked[i].Content = content
+	}
+	return checked, nil
+}
+
+// checkResponse applies the output rules to every choice of resp
+func (g *Guard) checkResponse(ctx context.Context, resp *providers.CompletionResponse) error {
+	if len(resp.Choices) == 0 {
+		content, err := g.Apply(ctx, StageOutput, resp.Content)
+		if err != nil {
+			return err
+		}
+		resp.Content = content
+		return nil
+	}
+
+	for i := range resp.Choices {
+		content, err := g.Apply(ctx, StageOutput, resp.Choices[i].Content)
+		if err != nil {
+			return err
+		}
+		resp.Choices[i].Content = content
+	}
+	resp.Content = resp.Choices[0].Content
+	return nil
+}
+
+// filterCategories returns the findings whose category is one of allowed, or
+// all findings if allowed is empty
+func filterCategories(findings []Finding, allowed []string) []Finding {
+	if len(allowed) == 0 {
+		return findings
+	}
+
+	var filtered []Finding
+	for _, finding := range findings {
+		for _, category := range allowed {
+			if strings.EqualFold(finding.Category, category) {
+				filtered = append(filtered, finding)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// redact replaces the findings in text with "[REDACTED:<category>]". Findings
+// without a position redact the whole text.
+func redact(text string, findings []Finding) string {
+	spans := make([]Finding, 0, len(findings))
+	for _, finding := range findings {
+		if finding.Start < 0 {
+			return fmt.Sprintf("[REDACTED:%s]", strings.Join(categories(findings), ","))
+		}
+		spans = append(spans, finding)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	var b strings.Builder
+	pos := 0
+	for _, span := range spans {
+		if span.Start < pos {
+			// Overlaps a span that was already redacted
+			pos = max(pos, span.End)
+			continue
+		}
+		b.WriteString(text[pos:span.Start])
+		fmt.Fprintf(&b, "[REDACTED:%s]", span.Category)
+		pos = span.End
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// categories returns the distinct categories of findings, sorted
+func categories(findings []Finding) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, finding := range findings {
+		if !seen[finding.Category] {
+			seen[finding.Category] = true
+			names = append(names, finding.Category)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// logFlag is the default flag handler
+func (g *Guard) logFlag(ctx context.Context, flag Flag) {
+	logging.OrDefault(g.logger).Warn("gollmkit: moderation rule flagged content",
+		"rule", flag.Rule, "stage", flag.Stage, "request_id", flag.RequestID,
+		"categories", strings.Join(categories(flag.Findings), ", "))
+}
@@ -0,0 +1,147 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// newGuardedProvider returns a mock provider guarded by rules
+func newGuardedProvider(t *testing.T, rules ...Rule) *providers.UnifiedProvider {
+	t.Helper()
+	cfg, err := config.New().Provider("mock").AddKey("m1", "mock-key").AddModel("mock-1", 0.001, 0.002).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.NewKeyStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := providers.NewUnifiedProvider(cfg, auth.NewKeyRotator(cfg, store), nil)
+	NewGuard(rules...).Install(provider)
+	return provider
+}
+
+// keywordRule returns a rule applying action to "project falcon"
+func keywordRule(t *testing.T, action Action, stage Stage) Rule {
+	t.Helper()
+	checker, err := NewKeywordChecker("confidential", "project falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Rule{Name: "codenames", Checker: checker, Action: action, Stage: stage}
+}
+
+var mockOpts = providers.RequestOptions{Provider: providers.Mock, Model: "mock-1"}
+
+func TestStreamBlockedPrompt(t *testing.T) {
+	provider := newGuardedProvider(t, keywordRule(t, ActionBlock, StageInput))
+
+	_, err := provider.InvokeStream(context.Background(), "When does Project Falcon launch?", mockOpts)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expected a BlockedError, got %v", err)
+	}
+	if blocked.Stage != StageInput {
+		t.Errorf("blocked at stage %s, want input", blocked.Stage)
+	}
+	if code := providers.CodeOf(err); code != providers.CodeContentFilter {
+		t.Errorf("code = %s, want %s", code, providers.CodeContentFilter)
+	}
+}
+
+func TestBlockedSystemAndToolMessages(t *testing.T) {
+	provider := newGuardedProvider(t, keywordRule(t, ActionBlock, StageInput))
+
+	for _, role := range []string{"system", "assistant", "tool"} {
+		messages := []providers.Message{
+			{Role: role, Content: "Project Falcon launches in May."},
+			{Role: "user", Content: "When is the launch?"},
+		}
+		if _, err := provider.Chat(context.Background(), messages, mockOpts); !errors.Is(err, ErrContentBlocked) {
+			t.Errorf("%s message: expected ErrContentBlocked, got %v", role, err)
+		}
+	}
+}
+
+func TestStreamRedactedOutput(t *testing.T) {
+	provider := newGuardedProvider(t, keywordRule(t, ActionRedact, StageOutput))
+
+	// The mock echoes the prompt, so the response mentions the codename
+	stream, err := provider.InvokeStream(context.Background(), "Tell me about Project Falcon", mockOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	var final providers.StreamChunk
+	for chunk := range stream {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		content.WriteString(chunk.Delta)
+		final = chunk
+	}
+
+	if strings.Contains(strings.ToLower(content.String()), "falcon") {
+		t.Errorf("streamed content not redacted: %q", content.String())
+	}
+	if !strings.Contains(content.String(), "[REDACTED:confidential]") {
+		t.Errorf("streamed content = %q, want a redaction", content.String())
+	}
+	if final.Usage == nil {
+		t.Error("final chunk has no usage")
+	}
+}
+
+func TestBatchCheckedBeforeSubmit(t *testing.T) {
+	provider := newGuardedProvider(t, keywordRule(t, ActionRedact, StageInput))
+	var submitted []providers.BatchRequest
+	provider.UseBatch(func(next providers.BatchHandler) providers.BatchHandler {
+		return func(ctx context.Context, provider providers.ProviderType, requests []providers.BatchRequest) (*providers.Batch, error) {
+			submitted = requests
+			return &providers.Batch{ID: "batch_1"}, nil
+		}
+	})
+
+	requests := []providers.BatchRequest{
+		{CustomID: "q1", Messages: []providers.Message{{Role: "user", Content: "Summarize the Project Falcon plan"}}},
+		{CustomID: "q2", Messages: []providers.Message{{Role: "user", Content: "Say hello"}}},
+	}
+	if _, err := provider.BatchSubmit(context.Background(), providers.OpenAI, requests); err != nil {
+		t.Fatal(err)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("submitted %d requests, want 2", len(submitted))
+	}
+	if content := submitted[0].Messages[0].Content; strings.Contains(strings.ToLower(content), "falcon") {
+		t.Errorf("submitted unredacted content %q", content)
+	}
+	if content := requests[0].Messages[0].Content; content != "Summarize the Project Falcon plan" {
+		t.Errorf("caller's request was modified to %q", content)
+	}
+}
+
+func TestBatchBlockedPrompt(t *testing.T) {
+	provider := newGuardedProvider(t, keywordRule(t, ActionBlock, StageInput))
+
+	requests := []providers.BatchRequest{
+		{CustomID: "q1", Messages: []providers.Message{{Role: "user", Content: "Say hello"}}},
+		{CustomID: "q2", Messages: []providers.Message{{Role: "user", Content: "When does Project Falcon launch?"}}},
+	}
+	_, err := provider.BatchSubmit(context.Background(), providers.OpenAI, requests)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expected a BlockedError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"q2"`) {
+		t.Errorf("error %q doesn't name the blocked request", err)
+	}
+	if code := providers.CodeOf(err); code != providers.CodeContentFilter {
+		t.Errorf("code = %s, want %s", code, providers.CodeContentFilter)
+	}
+}
@@ -0,0 +1,332 @@
+// Package pii detects personally identifiable information and replaces it
+// with opaque vault tokens before text is sent to an LLM provider
+package pii
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// Kind identifies a category of PII
+type Kind string
+
+const (
+	KindEmail      Kind = "EMAIL"
+	KindPhone      Kind = "PHONE"
+	KindSSN        Kind = "SSN"
+	KindCreditCard Kind = "CARD"
+	KindIPAddress  Kind = "IP"
+)
+
+// Match represents a detected PII value in a text
+type Match struct {
+	Kind  Kind
+	Value string
+	Start int
+	End   int
+}
+
+// pattern pairs a PII kind with the expression that detects it
+type pattern struct {
+	kind Kind
+	re   *regexp.Regexp
+}
+
+// Detector finds PII in text using regular expressions
+type Detector struct {
+	patterns []pattern
+}
+
+// NewDetector creates a detector with the built-in PII patterns
+func NewDetector() *Detector {
+	return &Detector{
+		patterns: []pattern{
+			{KindEmail, regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)},
+			{KindSSN, regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+			{KindCreditCard, regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`)},
+			{KindPhone, regexp.MustCompile(`\+?\d{1,3}?[ .-]?\(?\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`)},
+			{KindIPAddress, regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)},
+		},
+	}
+}
+
+// AddPattern registers an additional PII pattern
+func (d *Detector) AddPattern(kind Kind, expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid pattern for %s: %w", kind, err)
+	}
+	d.patterns = append(d.patterns, pattern{kind: kind, re: re})
+	return nil
+}
+
+// Find returns all non-overlapping PII matches in text ordered by position.
+// Patterns registered earlier win when matches overlap.
+func (d *Detector) Find(text string) []Match {
+	var matches []Match
+	for _, p := range d.patterns {
+		for _, loc := range p.re.FindAllStringIndex(text, -1) {
+			m := Match{Kind: p.kind, Value: text[loc[0]:loc[1]], Start: loc[0], End: loc[1]}
+			if !overlaps(matches, m) {
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// overlaps reports whether m overlaps any existing match
+func overlaps(matches []Match, m Match) bool {
+	for _, existing := range matches {
+		if m.Start < existing.End && existing.Start < m.End {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenPattern matches vault tokens produced by Tokenize
+var tokenPattern = regexp.MustCompile(`\[\[PII_[A-Z]+_\d+\]\]`)
+
+// Defaults of the conversation limits of a vault, see SetLimits
+const (
+	DefaultConversationTTL  = time.Hour
+	DefaultMaxConversations = 10000
+)
+
+// conversation holds the encrypted token mapping for a single conversation
+type conversation struct {
+	id       string
+	tokens   map[string]string // token -> encrypted value
+	digests  map[string]string // keyed digest of value -> token
+	counter  int
+	lastUsed time.Time
+	elem     *list.Element // of Vault.recent
+}
+
+// Vault replaces PII with tokens and stores the encrypted mapping per
+// conversation. Conversations are forgotten once idle for the TTL, and the
+// least recently used ones when there are too many, so the vault stays bounded
+// when callers never call Forget.
+type Vault struct {
+	mu               sync.Mutex
+	detector         *Detector
+	encryptor        *auth.KeyEncryptor
+	digestKey        []byte
+	conversations    map[string]*conversation // conversation ID -> mapping
+	recent           *list.List               // conversations, most recently used first
+	ttl              time.Duration
+	maxConversations int
+}
+
+// NewVault creates a vault whose mappings are encrypted with encryptionKey
+func NewVault(encryptionKey string, detector *Detector) *Vault {
+	if detector == nil {
+		detector = NewDetector()
+	}
+	digestKey := sha256.Sum256([]byte("gollmkit-pii:" + encryptionKey))
+
+	return &Vault{
+		detector:         detector,
+		encryptor:        auth.NewKeyEncryptor(encryptionKey),
+		digestKey:        digestKey[:],
+		conversations:    make(map[string]*conversation),
+		recent:           list.New(),
+		ttl:              DefaultConversationTTL,
+		maxConversations: DefaultMaxConversations,
+	}
+}
+
+// SetLimits sets how long an idle conversation is kept and how many
+// conversations are kept at most. Zero disables a limit. Tokens of a forgotten
+// conversation are no longer restored.
+func (v *Vault) SetLimits(ttl time.Duration, maxConversations int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.ttl = ttl
+	v.maxConversations = maxConversations
+	v.evict(time.Now())
+}
+
+// Len returns the number of conversations held
+func (v *Vault) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.conversations)
+}
+
+// Tokenize replaces every detected PII value in text with a vault token.
+// The same value is always mapped to the same token within a conversation.
+func (v *Vault) Tokenize(conversationID, text string) (string, error) {
+	matches := v.detector.Find(text)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	v.evict(now)
+	conv := v.conversations[conversationID]
+	if conv == nil {
+		conv = &conversation{
+			id:       conversationID,
+			tokens:   make(map[string]string),
+			digests:  make(map[string]string),
+			lastUsed: now,
+		}
+		conv.elem = v.recent.PushFront(conv)
+		v.conversations[conversationID] = conv
+		v.evict(now)
+	} else {
+		v.touch(conv, now)
+	}
+
+	var out []byte
+	last := 0
+	for _, m := range matches {
+		digest := v.digest(m.Value)
+		token, exists := conv.digests[digest]
+		if !exists {
+			encrypted, err := v.encryptor.Encrypt(m.Value)
+			if err != nil {
+				return "", fmt.Errorf("failed to encrypt PII value: %w", err)
+			}
+			conv.counter++
+			token = fmt.Sprintf("[[PII_%s_%d]]", m.Kind, conv.counter)
+			conv.tokens[token] = encrypted
+			conv.digests[digest] = token
+		}
+
+		out = append(out, text[last:m.Start]...)
+		out = append(out, token...)
+		last = m.End
+	}
+	out = append(out, text[last:]...)
+
+	return string(out), nil
+}
+
+// Detokenize restores the original values for all vault tokens in text.
+// Unknown tokens are left untouched.
+func (v *Vault) Detokenize(conversationID, text string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	v.evict(now)
+	conv := v.conversations[conversationID]
+	if conv == nil {
+		return text, nil
+	}
+	v.touch(conv, now)
+
+	var decryptErr error
+	result := tokenPattern.ReplaceAllStringFunc(text, func(token string) string {
+		encrypted, exists := conv.tokens[token]
+		if !exists {
+			return token
+		}
+		value, err := v.encryptor.Decrypt(encrypted)
+		if err != nil {
+			decryptErr = err
+			return token
+		}
+		return value
+	})
+
+	if decryptErr != nil {
+		return "", fmt.Errorf("failed to decrypt PII value: %w", decryptErr)
+	}
+	return result, nil
+}
+
+// partialTokenPattern matches the beginning of a vault token at the end of a text
+var partialTokenPattern = regexp.MustCompile(`\[(\[(P(I(I(_([A-Z]+(_(\d+(\])?)?)?)?)?)?)?)?)?$`)
+
+// StreamDetokenizer restores vault tokens in text that arrives in pieces, such
+// as the deltas of a streamed response. A token split across pieces is held
+// back until it is complete.
+type StreamDetokenizer struct {
+	vault          *Vault
+	conversationID string
+	pending        string
+}
+
+// NewStreamDetokenizer creates a stream detokenizer for a conversation
+func (v *Vault) NewStreamDetokenizer(conversationID string) *StreamDetokenizer {
+	return &StreamDetokenizer{vault: v, conversationID: conversationID}
+}
+
+// Next returns the restored text of piece, without a trailing partial token,
+// which is returned by a later call
+func (d *StreamDetokenizer) Next(piece string) (string, error) {
+	text := d.pending + piece
+	d.pending = ""
+	if loc := partialTokenPattern.FindStringIndex(text); loc != nil {
+		text, d.pending = text[:loc[0]], text[loc[0]:]
+	}
+	return d.vault.Detokenize(d.conversationID, text)
+}
+
+// Flush returns the restored text held back at the end of the stream
+func (d *StreamDetokenizer) Flush() (string, error) {
+	text := d.pending
+	d.pending = ""
+	return d.vault.Detokenize(d.conversationID, text)
+}
+
+// Forget removes the mapping of a conversation
+func (v *Vault) Forget(conversationID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if conv := v.conversations[conversationID]; conv != nil {
+		v.remove(conv)
+	}
+}
+
+// touch marks a conversation as used at now
+func (v *Vault) touch(conv *conversation, now time.Time) {
+	conv.lastUsed = now
+	v.recent.MoveToFront(conv.elem)
+}
+
+// evict forgets conversations idle for longer than the TTL and the least
+// recently used ones beyond the maximum. The caller must hold v.mu.
+func (v *Vault) evict(now time.Time) {
+	for back := v.recent.Back(); back != nil; back = v.recent.Back() {
+		conv := back.Value.(*conversation)
+		expired := v.ttl > 0 && now.Sub(conv.lastUsed) > v.ttl
+		if !expired && (v.maxConversations <= 0 || len(v.conversations) <= v.maxConversations) {
+			return
+		}
+		v.remove(conv)
+	}
+}
+
+// remove forgets a conversation. The caller must hold v.mu.
+func (v *Vault) remove(conv *conversation) {
+	v.recent.Remove(conv.elem)
+	delete(v.conversations, conv.id)
+}
+
+// digest returns a keyed digest of a value so plaintext never needs to be kept
+func (v *Vault) digest(value string) string {
+	mac := hmac.New(sha256.New, v.digestKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,246 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// AnthropicProvider sends requests to Anthropic only. It implements LLMProvider
+// with the same key rotation, usage tracking and middleware as
+// UnifiedProvider, without fallback to other providers.
+type AnthropicProvider struct {
+	vendorProvider
+}
+
+// NewAnthropicProvider creates a provider for the anthropic configuration of cfg
+func NewAnthropicProvider(cfg *config.Config, rotator *auth.KeyRotator, validator *auth.KeyValidator) *AnthropicProvider {
+	p := newVendorProvider(Anthropic, cfg, rotator, validator)
+	p.encode = anthropicRequestBody
+	p.decode = func(result map[string]interface{}, model string) (*CompletionResponse, error) {
+		return parseAnthropicResponse(result, model)
+	}
+	return &AnthropicProvider{p}
+}
+
+// anthropicHeaders returns a func setting the authentication, version and
+// beta headers of Anthropic requests. Beta features of opts are added to the
+// configured ones.
+func (p *UnifiedProvider) anthropicHeaders(opts RequestOptions) func(*http.Request, *auth.KeySelection) {
+	version := config.DefaultAnthropicVersion
+	var beta []string
+	if providerCfg, err := p.getConfig().GetProvider(string(Anthropic)); err == nil {
+		version = providerCfg.GetAPIVersion()
+		beta = append(beta, providerCfg.BetaFeatures...)
+	}
+	beta = append(beta, opts.BetaFeatures...)
+
+	return func(req *http.Request, key *auth.KeySelection) {
+		req.Header.Set("x-api-key", key.Key)
+		req.Header.Set("anthropic-version", version)
+		if len(beta) > 0 {
+			req.Header.Set("anthropic-beta", strings.Join(beta, ","))
+		}
+	}
+}
+
+// minThinkingBudget is the smallest thinking budget Anthropic accepts
+const minThinkingBudget = 1024
+
+// checkThinkingBudget validates RequestOptions.ThinkingBudget of Anthropic requests
+func checkThinkingBudget(opts RequestOptions) error {
+	if opts.ThinkingBudget == 0 || opts.Provider != Anthropic {
+		return nil
+	}
+
+	var message string
+	switch {
+	case opts.ThinkingBudget < minThinkingBudget:
+		message = fmt.Sprintf("thinking budget %d is below the minimum of %d tokens", opts.ThinkingBudget, minThinkingBudget)
+	case opts.MaxTokens <= opts.ThinkingBudget:
+		message = fmt.Sprintf("max tokens (%d) must exceed the thinking budget (%d)", opts.MaxTokens, opts.ThinkingBudget)
+	default:
+		return nil
+	}
+	return &Error{
+		Code:     CodeBadRequest,
+		Provider: opts.Provider,
+		Message:  message,
+		Err:      ErrBadRequest,
+	}
+}
+
+// anthropicRequestBody builds the messages API request body. A leading
+// system message is sent as the system prompt, which isn't a message role.
+func anthropicRequestBody(messages []Message, opts RequestOptions) map[string]interface{} {
+	system, messages := splitSystem(messages)
+	body := map[string]interface{}{
+		"model":      opts.Model,
+		"messages":   anthropicMessages(messages),
+		"max_tokens": opts.MaxTokens,
+		"stream":     opts.Stream,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	if len(opts.Stop) > 0 {
+		body["stop_sequences"] = opts.Stop
+	}
+
+	// Extended thinking doesn't allow changing temperature or top_p
+	if opts.ThinkingBudget > 0 {
+		body["thinking"] = map[string]interface{}{
+			"type":          "enabled",
+			"budget_tokens": opts.ThinkingBudget,
+		}
+	} else {
+		body["temperature"] = opts.Temperature
+		body["top_p"] = opts.TopP
+	}
+	anthropicTools(body, opts)
+	return applyExtra(body, opts)
+}
+
+// parseAnthropicResponse converts a messages API response body into a CompletionResponse
+func parseAnthropicResponse(result map[string]interface{}, model string) (*CompletionResponse, error) {
+	stopReason, _ := result["stop_reason"].(string)
+	content, ok := result["content"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing content in response", ErrResponseFormat)
+	}
+	if len(content) == 0 && stopReason != "refusal" {
+		return nil, emptyResponseError(Anthropic)
+	}
+
+	// Thinking blocks precede the text blocks of the answer
+	var text strings.Builder
+	var thinking []ThinkingBlock
+	var toolCalls []ToolCall
+	var citations []Citation
+	for _, item := range content {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid content format in response", ErrResponseFormat)
+		}
+		switch block["type"] {
+		case "text":
+			s, _ := block["text"].(string)
+			start := text.Len()
+			text.WriteString(s)
+			citations = append(citations, parseAnthropicCitations(block, start, text.Len())...)
+		case "thinking":
+			s, _ := block["thinking"].(string)
+			signature, _ := block["signature"].(string)
+			thinking = append(thinking, ThinkingBlock{Thinking: s, Signature: signature})
+		case "redacted_thinking":
+			data, _ := block["data"].(string)
+			thinking = append(thinking, ThinkingBlock{Redacted: true, Data: data})
+		case "tool_use":
+			toolCalls = append(toolCalls, anthropicToolCall(block))
+		}
+	}
+
+	usage, ok := result["usage"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing usage in response", ErrResponseFormat)
+	}
+
+	// input_tokens leaves out the prompt tokens written to and read from the cache
+	cacheRead, _ := usage["cache_read_input_tokens"].(float64)
+	cacheWrite, _ := usage["cache_creation_input_tokens"].(float64)
+	promptTokens := int(usage["input_tokens"].(float64)) + int(cacheRead) + int(cacheWrite)
+	tokenUsage := TokenUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: int(usage["output_tokens"].(float64)),
+		TotalTokens:      promptTokens + int(usage["output_tokens"].(float64)),
+		CachedTokens:     int(cacheRead),
+		ReasoningTokens:  countThinkingTokens(model, thinking),
+	}
+
+	finishReason := normalizeFinishReason(Anthropic, stopReason)
+	if stopReason == "refusal" && text.Len() == 0 {
+		return nil, safetyError(&SafetyBlockError{Provider: Anthropic, Reason: stopReason})
+	}
+
+	return &CompletionResponse{
+		Content:      text.String(),
+		Model:        model,
+		Usage:        tokenUsage,
+		ProviderName: string(Anthropic),
+		FinishReason: finishReason,
+		Choices:      []Choice{{Content: text.String(), FinishReason: finishReason}},
+		Thinking:     thinking,
+		ToolCalls:    toolCalls,
+		Citations:    citations,
+		Metadata:     result,
+	}, nil
+}
+
+// countThinkingTokens estimates the tokens of thinking blocks, which
+// Anthropic bills as output tokens without reporting them separately
+func countThinkingTokens(model string, blocks []ThinkingBlock) int {
+	tokens := 0
+	for _, block := range blocks {
+		tokens += tokenizer.CountTokens(model, block.Thinking)
+	}
+	return tokens
+}
+
+func (p *UnifiedProvider) callAnthropic(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	reqBody := anthropicRequestBody(messages, opts)
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	p.anthropicHeaders(opts)(req, key)
+
+	req, trace := traceRequest(req, opts)
+	start := time.Now()
+	resp, err := p.httpClient(Anthropic).Do(req)
+	if err != nil {
+		p.recordError(ctx, Anthropic, key.KeyName, err)
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+	p.recordRateLimit(Anthropic, key.KeyName, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		err = parseAPIError(Anthropic, resp)
+		p.recordError(ctx, Anthropic, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(Anthropic, opts.Model, start)
+
+	var result map[string]interface{}
+	info, err := decodeResponse(resp, trace, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := parseAnthropicResponse(result, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recordUsage(ctx, Anthropic, key.KeyName, opts.Model, completion.Usage)
+
+	completion.ResponseInfo = info
+	completion.ProviderRequestID = providerRequestID(resp.Header)
+	return completion, nil
+}
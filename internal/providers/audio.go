@@ -0,0 +1,509 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// DefaultAudioFilename names uploaded audio whose TranscribeOptions set no filename
+const DefaultAudioFilename = "audio.mp3"
+
+// DefaultTranscriptionPrompt instructs Gemini models to transcribe audio
+const DefaultTranscriptionPrompt = "Generate a verbatim transcript of the speech in this audio. Reply with the transcript only."
+
+// TranscribeOptions contains options for speech-to-text requests
+type TranscribeOptions struct {
+	Provider ProviderType `json:"provider,omitempty"`
+	Model    string       `json:"model,omitempty"`
+
+	// Filename of the audio. Its extension tells the provider the audio format.
+	// Defaults to DefaultAudioFilename.
+	Filename string `json:"filename,omitempty"`
+
+	// MIMEType of the audio, sent to Gemini. Defaults to the type of Filename's extension.
+	MIMEType string `json:"mime_type,omitempty"`
+
+	// Language is the ISO-639-1 code of the spoken language, if known
+	Language string `json:"language,omitempty"`
+
+	// Prompt guides the transcription, e.g. with spellings of names
+	Prompt string `json:"prompt,omitempty"`
+
+	// Duration of the audio, used for cost accounting when the provider
+	// doesn't report it
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Timeout bounds the provider call. Defaults to the provider's timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// TenantID attributes the request to a tenant, overriding tenant.WithTenant on the context
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Transcription is the result of a speech-to-text request
+type Transcription struct {
+	Text         string        `json:"text"`
+	Language     string        `json:"language,omitempty"`
+	Duration     time.Duration `json:"duration,omitempty"`
+	Model        string        `json:"model"`
+	ProviderName string        `json:"provider_name"`
+	Usage        TokenUsage    `json:"usage"`
+	Cost         float64       `json:"cost"`
+}
+
+// SpeechOptions contains options for text-to-speech requests
+type SpeechOptions struct {
+	Provider ProviderType `json:"provider,omitempty"`
+	Model    string       `json:"model,omitempty"`
+
+	// Voice to speak with. Defaults to "alloy".
+	Voice string `json:"voice,omitempty"`
+
+	// Format of the audio: mp3 (default), opus, aac, flac, wav or pcm
+	Format string `json:"format,omitempty"`
+
+	// Speed of the speech from 0.25 to 4.0. Zero uses the provider default.
+	Speed float64 `json:"speed,omitempty"`
+
+	// Timeout bounds the provider call. Defaults to the provider's timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// TenantID attributes the request to a tenant, overriding tenant.WithTenant on the context
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Speech is the result of a text-to-speech request
+type Speech struct {
+	Audio        []byte  `json:"audio"`
+	MIMEType     string  `json:"mime_type"`
+	Model        string  `json:"model"`
+	ProviderName string  `json:"provider_name"`
+	Characters   int     `json:"characters"`
+	Cost         float64 `json:"cost"`
+}
+
+// builtinTranscriptionPricing holds per-minute prices of transcription models
+// whose configuration omits cost_per_minute
+var builtinTranscriptionPricing = map[string]float64{
+	"whisper-1":              0.006,
+	"gpt-4o-transcribe":      0.006,
+	"gpt-4o-mini-transcribe": 0.003,
+}
+
+// builtinSpeechPricing holds per-1K-character prices of speech models whose
+// configuration omits cost_per_1k_chars
+var builtinSpeechPricing = map[string]float64{
+	"tts-1":    0.015,
+	"tts-1-hd": 0.03,
+}
+
+// Transcribe converts speech to text with OpenAI (Whisper, gpt-4o-transcribe)
+// or a Gemini model through Gemini or Vertex AI. The audio is read into
+// memory so it can be resent on key failover. Models priced per minute are
+// charged for the audio duration, other Gemini models for their tokens.
+func (p *UnifiedProvider) Transcribe(ctx context.Context, audio io.Reader, opts TranscribeOptions) (*Transcription, error) {
+	if p.piiVault != nil {
+		// Like images, audio can't have its PII replaced before it is sent
+		return nil, attachmentError(opts.Provider, "audio can't be scanned for PII and isn't sent while a PII vault is set")
+	}
+	if limit := p.getConfig().Global.RequestLimits.MaxAttachmentBytes; limit > 0 {
+		// Stop just past the limit so oversized uploads are rejected without buffering them
+		audio = io.LimitReader(audio, int64(limit)+1)
+	}
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	if opts.Filename == "" {
+		opts.Filename = DefaultAudioFilename
+	}
+	if opts.MIMEType == "" {
+		opts.MIMEType = audioMIMEType(opts.Filename)
+	}
+
+	req := mediaRequest{
+		Provider:        opts.Provider,
+		Model:           opts.Model,
+		Timeout:         opts.Timeout,
+		TenantID:        opts.TenantID,
+		PromptBytes:     len(opts.Prompt),
+		AttachmentBytes: len(data),
+	}
+	var result *Transcription
+	err = p.invokeMedia(ctx, &req, func(ctx context.Context, key *auth.KeySelection) (mediaUsage, error) {
+		var err error
+		switch req.Provider {
+		case OpenAI:
+			result, err = p.transcribeOpenAI(ctx, data, opts, req.Model, key)
+		case Gemini, Vertex:
+			result, err = p.transcribeGemini(ctx, data, opts, req, key)
+		case Mock:
+			result, err = transcribeMock(ctx, data, opts, req.Model)
+		default:
+			err = fmt.Errorf("transcription not supported for provider: %s", req.Provider)
+		}
+		if err != nil {
+			return mediaUsage{}, err
+		}
+
+		if result.Duration == 0 {
+			result.Duration = opts.Duration
+		}
+		result.Cost = p.transcriptionCost(req.Provider, req.Model, result)
+		return mediaUsage{Usage: result.Usage, Cost: result.Cost}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Speak converts text to speech with OpenAI (tts-1, tts-1-hd, gpt-4o-mini-tts),
+// charging the model's cost_per_1k_chars
+func (p *UnifiedProvider) Speak(ctx context.Context, text string, opts SpeechOptions) (*Speech, error) {
+	if opts.Voice == "" {
+		opts.Voice = "alloy"
+	}
+	if opts.Format == "" {
+		opts.Format = "mp3"
+	}
+	texts, _, forget, err := p.tokenizeInputs([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	defer forget()
+	text = texts[0]
+
+	req := mediaRequest{
+		Provider:    opts.Provider,
+		Model:       opts.Model,
+		Timeout:     opts.Timeout,
+		TenantID:    opts.TenantID,
+		PromptBytes: len(text),
+	}
+	var result *Speech
+	err = p.invokeMedia(ctx, &req, func(ctx context.Context, key *auth.KeySelection) (mediaUsage, error) {
+		var err error
+		switch req.Provider {
+		case OpenAI:
+			result, err = p.speakOpenAI(ctx, text, opts, req.Model, key)
+		case Mock:
+			result, err = speakMock(ctx, text, opts, req.Model)
+		default:
+			err = fmt.Errorf("speech synthesis not supported for provider: %s", req.Provider)
+		}
+		if err != nil {
+			return mediaUsage{}, err
+		}
+
+		result.Characters = len([]rune(text))
+		result.Cost = p.speechCost(req.Provider, req.Model, result.Characters)
+		return mediaUsage{Cost: result.Cost}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// transcriptionCost prices a transcription by its duration when the model has
+// a per-minute price, and by its tokens otherwise
+func (p *UnifiedProvider) transcriptionCost(provider ProviderType, model string, t *Transcription) float64 {
+	perMinute := builtinTranscriptionPricing[model]
+	if modelCfg := p.modelConfig(provider, model); modelCfg != nil && modelCfg.CostPerMinute > 0 {
+		perMinute = modelCfg.CostPerMinute
+	}
+	if perMinute > 0 {
+		return t.Duration.Minutes() * perMinute
+	}
+	if t.Usage.TotalTokens > 0 {
+		return p.CalculateCost(provider, model, t.Usage)
+	}
+	return 0
+}
+
+// speechCost prices speech synthesis by the number of input characters
+func (p *UnifiedProvider) speechCost(provider ProviderType, model string, characters int) float64 {
+	per1K := builtinSpeechPricing[model]
+	if modelCfg := p.modelConfig(provider, model); modelCfg != nil && modelCfg.CostPer1KChars > 0 {
+		per1K = modelCfg.CostPer1KChars
+	}
+	return float64(characters) / 1000 * per1K
+}
+
+// transcribeOpenAI uploads audio to the OpenAI transcriptions API
+func (p *UnifiedProvider) transcribeOpenAI(ctx context.Context, audio []byte, opts TranscribeOptions, model string, key *auth.KeySelection) (*Transcription, error) {
+	// Only whisper-1 reports the audio duration, in its verbose_json format
+	format := "json"
+	if model == "whisper-1" {
+		format = "verbose_json"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", filepath.Base(opts.Filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(audio); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{
+		"model":           model,
+		"response_format": format,
+		"language":        opts.Language,
+		"prompt":          opts.Prompt,
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/audio/transcriptions", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	setOpenAIHeaders(req, key)
+
+	resp, err := p.sendMediaRequest(req, OpenAI, key)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	var result struct {
+		Text     string  `json:"text"`
+		Language string  `json:"language"`
+		Duration float64 `json:"duration"`
+		Usage    struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+
+	return &Transcription{
+		Text:         result.Text,
+		Language:     result.Language,
+		Duration:     time.Duration(result.Duration * float64(time.Second)),
+		Model:        model,
+		ProviderName: string(OpenAI),
+		Usage: TokenUsage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// transcribeGemini sends audio inline to a Gemini model with a transcription prompt
+func (p *UnifiedProvider) transcribeGemini(ctx context.Context, audio []byte, opts TranscribeOptions, mr mediaRequest, key *auth.KeySelection) (*Transcription, error) {
+	prompt := DefaultTranscriptionPrompt
+	if opts.Language != "" {
+		prompt += fmt.Sprintf(" The audio is in language %q.", opts.Language)
+	}
+	if opts.Prompt != "" {
+		prompt += "\n\n" + opts.Prompt
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"contents": []map[string]interface{}{{
+			"role": "user",
+			"parts": []map[string]interface{}{
+				{"inline_data": map[string]string{
+					"mime_type": opts.MIMEType,
+					"data":      base64.StdEncoding.EncodeToString(audio),
+				}},
+				{"text": prompt},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var apiURL string
+	if mr.Provider == Vertex {
+		if apiURL, err = p.vertexURL(mr.Model, "generateContent"); err != nil {
+			return nil, err
+		}
+	} else {
+		apiURL = fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:generateContent",
+			url.PathEscape(mr.Model))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if mr.Provider == Vertex {
+		if err := p.setVertexHeaders(ctx, req, key); err != nil {
+			return nil, err
+		}
+	} else {
+		setGeminiHeaders(req, key)
+	}
+
+	resp, err := p.sendMediaRequest(req, mr.Provider, key)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+	completion, err := parseGeminiResponse(result, mr.Provider, mr.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transcription{
+		Text:         strings.TrimSpace(completion.Content),
+		Language:     opts.Language,
+		Model:        mr.Model,
+		ProviderName: string(mr.Provider),
+		Usage:        completion.Usage,
+	}, nil
+}
+
+// speakOpenAI calls the OpenAI speech API
+func (p *UnifiedProvider) speakOpenAI(ctx context.Context, text string, opts SpeechOptions, model string, key *auth.KeySelection) (*Speech, error) {
+	reqBody := map[string]interface{}{
+		"model":           model,
+		"input":           text,
+		"voice":           opts.Voice,
+		"response_format": opts.Format,
+	}
+	if opts.Speed != 0 {
+		reqBody["speed"] = opts.Speed
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/audio/speech", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setOpenAIHeaders(req, key)
+
+	resp, err := p.sendMediaRequest(req, OpenAI, key)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := resp.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = audioMIMEType("speech." + opts.Format)
+	}
+	return &Speech{Audio: audio, MIMEType: mimeType, Model: model, ProviderName: string(OpenAI)}, nil
+}
+
+// sendMediaRequest sends req and converts non-200 responses into errors. The
+// caller closes the body of the returned response.
+func (p *UnifiedProvider) sendMediaRequest(req *http.Request, provider ProviderType, key *auth.KeySelection) (*http.Response, error) {
+	resp, err := p.httpClient(provider).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	p.recordRateLimit(provider, key.KeyName, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		defer closeBody(resp.Body)
+		if provider == Vertex && resp.StatusCode == http.StatusUnauthorized {
+			p.tokens.invalidate(key.Key)
+		}
+		return nil, parseAPIError(provider, resp)
+	}
+	return resp, nil
+}
+
+// audioMIMEType returns the MIME type of an audio file by its extension
+func audioMIMEType(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case ".mp3", ".mpga", ".mpeg":
+		return "audio/mpeg"
+	case ".m4a", ".mp4":
+		return "audio/mp4"
+	case ".wav":
+		return "audio/wav"
+	case ".ogg", ".opus":
+		return "audio/ogg"
+	case ".flac":
+		return "audio/flac"
+	case ".webm":
+		return "audio/webm"
+	case ".aac":
+		return "audio/aac"
+	case ".pcm":
+		return "audio/pcm"
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// transcribeMock returns the audio bytes as the transcript, applying the
+// failure injection markers they contain
+func transcribeMock(ctx context.Context, audio []byte, opts TranscribeOptions, model string) (*Transcription, error) {
+	if _, err := mockCompletion(ctx, []Message{{Role: "user", Content: string(audio)}}, RequestOptions{Model: model}); err != nil {
+		return nil, err
+	}
+	return &Transcription{
+		Text:         strings.TrimSpace(injectPattern.ReplaceAllString(string(audio), "")),
+		Language:     opts.Language,
+		Model:        model,
+		ProviderName: string(Mock),
+	}, nil
+}
+
+// speakMock returns the text as the audio, applying the failure injection
+// markers it contains
+func speakMock(ctx context.Context, text string, opts SpeechOptions, model string) (*Speech, error) {
+	if _, err := mockCompletion(ctx, []Message{{Role: "user", Content: text}}, RequestOptions{Model: model}); err != nil {
+		return nil, err
+	}
+	return &Speech{
+		Audio:        []byte(text),
+		MIMEType:     audioMIMEType("speech." + opts.Format),
+		Model:        model,
+		ProviderName: string(Mock),
+	}, nil
+}
@@ -0,0 +1,594 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/redact"
+	"github.com/gollmkit/gollmkit/internal/tenant"
+)
+
+// batchDiscount is the price multiplier providers apply to batch requests
+const batchDiscount = 0.5
+
+// BatchState is the normalized state of a batch job
+type BatchState string
+
+const (
+	BatchInProgress BatchState = "in_progress"
+	BatchCompleted  BatchState = "completed"
+	BatchFailed     BatchState = "failed"
+	BatchCancelled  BatchState = "cancelled"
+	BatchExpired    BatchState = "expired"
+)
+
+// BatchRequest is a single chat request within a batch
+type BatchRequest struct {
+	CustomID string         `json:"custom_id"`
+	Messages []Message      `json:"messages"`
+	Options  RequestOptions `json:"options"`
+}
+
+// BatchCounts tracks the progress of a batch job
+type BatchCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Batch identifies a submitted batch job. The key used for submission is
+// remembered so polling and usage attribution use the same key. A Batch can
+// be persisted as JSON and resumed after a restart.
+type Batch struct {
+	ID         string       `json:"id"`
+	Provider   ProviderType `json:"provider"`
+	KeyName    string       `json:"key_name"`
+	State      BatchState   `json:"state"`
+	Counts     BatchCounts  `json:"counts"`
+	CreatedAt  time.Time    `json:"created_at"`
+	OutputFile string       `json:"output_file,omitempty"` // OpenAI output file ID
+	ResultsURL string       `json:"results_url,omitempty"` // Anthropic results URL
+
+	// Conversation is the PII vault conversation the requests were tokenized
+	// in. Their tokens are restored in the results while the vault keeps it,
+	// so the vault's TTL must cover the batch's completion window.
+	Conversation string `json:"conversation,omitempty"`
+
+	// Models maps the custom ID of each request to its model, for cost attribution
+	Models map[string]string `json:"models,omitempty"`
+
+	// Tenants maps the custom ID of each request sent for a tenant to its ID,
+	// for usage attribution
+	Tenants map[string]string `json:"tenants,omitempty"`
+
+	// Recorded is set once BatchResults has recorded the usage of the batch,
+	// so fetching the results again doesn't count it twice
+	Recorded bool `json:"recorded,omitempty"`
+}
+
+// BatchResult is the result of a single request within a batch
+type BatchResult struct {
+	CustomID string              `json:"custom_id"`
+	Response *CompletionResponse `json:"response,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Code     ErrorCode           `json:"code,omitempty"`
+}
+
+// BatchSubmit submits requests as an offline batch job to OpenAI or Anthropic.
+// The estimated cost of the requests of each tenant must fit within its
+// remaining quota, and each request counts against its rate limit. Requests
+// pass through the middleware added with UseBatch first.
+func (p *UnifiedProvider) BatchSubmit(ctx context.Context, provider ProviderType, requests []BatchRequest) (*Batch, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	defer endCall()
+
+	return p.batchHandler()(ctx, provider, requests)
+}
+
+// batchSubmit uploads and creates the batch job of BatchSubmit
+func (p *UnifiedProvider) batchSubmit(ctx context.Context, provider ProviderType, requests []BatchRequest) (*Batch, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("batch must contain at least one request")
+	}
+
+	// PII is replaced by vault tokens of a conversation of the batch
+	var conversationID string
+	var submitted bool
+	if p.piiVault != nil {
+		conversationID = "batch-" + randomID()
+		defer func() {
+			// The mapping is kept to restore the results of a submitted batch
+			if !submitted {
+				p.piiVault.Forget(conversationID)
+			}
+		}()
+	}
+
+	// Resolve options for every request before touching the network
+	prepared := make([]BatchRequest, len(requests))
+	models := make(map[string]string, len(requests))
+	tenants := make(map[string]string)
+	tenantCost := make(map[string]float64)
+	tenantRequests := make(map[string]int)
+	for i, r := range requests {
+		if r.CustomID == "" {
+			return nil, fmt.Errorf("batch request %d has empty custom_id", i)
+		}
+		if _, exists := models[r.CustomID]; exists {
+			return nil, fmt.Errorf("duplicate custom_id %q in batch", r.CustomID)
+		}
+
+		opts, err := p.mergeOptions(provider, r.Options)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Provider != provider {
+			return nil, fmt.Errorf("batch request %q: model %q resolves to provider %s, not %s", r.CustomID, r.Options.Model, opts.Provider, provider)
+		}
+		if err := p.validateModel(provider, opts.Model); err != nil {
+			return nil, err
+		}
+		if err := p.checkRequestSize(provider, r.Messages); err != nil {
+			return nil, fmt.Errorf("batch request %q: %w", r.CustomID, err)
+		}
+		opts.Stream = false
+
+		batchMessages, err := p.prepareAttachments(NormalizeMessages(r.Messages, opts), provider)
+		if err != nil {
+			return nil, fmt.Errorf("batch request %q: %w", r.CustomID, err)
+		}
+		if p.piiVault != nil {
+			if batchMessages, err = p.tokenizeMessages(conversationID, batchMessages, provider); err != nil {
+				return nil, fmt.Errorf("batch request %q: %w", r.CustomID, err)
+			}
+		}
+		prepared[i] = BatchRequest{CustomID: r.CustomID, Messages: batchMessages, Options: opts}
+		models[r.CustomID] = opts.Model
+
+		if opts.TenantID == "" {
+			opts.TenantID = tenant.FromContext(ctx)
+		}
+		if opts.TenantID != "" {
+			tenants[r.CustomID] = opts.TenantID
+			tenantCost[opts.TenantID] += p.estimate(batchMessages, opts).Cost * batchDiscount
+			tenantRequests[opts.TenantID]++
+		}
+	}
+
+	// Check the quotas before anything is uploaded
+	if p.tenants != nil {
+		for tenantID, cost := range tenantCost {
+			limits := p.getConfig().GetTenantLimits(tenantID)
+			if err := p.tenants.Reserve(tenantID, limits, tenantRequests[tenantID], cost, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The key must be allowed to use every model and match every key filter of the batch
+	var selectOpts []auth.SelectOption
+	for _, r := range prepared {
+		selectOpts = append(selectOpts, keyRestrictions(r.Options)...)
+	}
+	key, err := p.getNextKey(ctx, provider, selectOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Release()
+
+	var batch *Batch
+	switch provider {
+	case OpenAI:
+		batch, err = p.submitOpenAIBatch(ctx, key, prepared)
+	case Anthropic:
+		batch, err = p.submitAnthropicBatch(ctx, key, prepared)
+	default:
+		return nil, fmt.Errorf("batch API not supported for provider: %s", provider)
+	}
+	if err != nil {
+		p.recordError(ctx, provider, key.KeyName, err)
+		return nil, err
+	}
+
+	submitted = true
+	batch.Models = models
+	if len(tenants) > 0 {
+		batch.Tenants = tenants
+	}
+	batch.Conversation = conversationID
+	return batch, nil
+}
+
+// BatchStatus refreshes the state of a batch job
+func (p *UnifiedProvider) BatchStatus(ctx context.Context, batch *Batch) (*Batch, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	defer endCall()
+
+	key, err := p.rotator.GetKeyByName(ctx, string(batch.Provider), batch.KeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *Batch
+	switch batch.Provider {
+	case OpenAI:
+		var result map[string]interface{}
+		if err := p.batchRequest(ctx, "GET", "https://api.openai.com/v1/batches/"+batch.ID, nil, key, &result); err != nil {
+			return nil, err
+		}
+		updated = parseOpenAIBatch(result)
+	case Anthropic:
+		var result map[string]interface{}
+		if err := p.batchRequest(ctx, "GET", "https://api.anthropic.com/v1/messages/batches/"+batch.ID, nil, key, &result); err != nil {
+			return nil, err
+		}
+		updated = parseAnthropicBatch(result)
+	default:
+		return nil, fmt.Errorf("batch API not supported for provider: %s", batch.Provider)
+	}
+
+	updated.Provider = batch.Provider
+	updated.KeyName = batch.KeyName
+	updated.Models = batch.Models
+	updated.Tenants = batch.Tenants
+	updated.Recorded = batch.Recorded
+	updated.Conversation = batch.Conversation
+	return updated, nil
+}
+
+// BatchResults downloads the results of a completed batch job and attributes
+// the usage, at the discounted batch price, to the key that submitted it. The
+// usage is recorded the first time only, which sets batch.Recorded.
+func (p *UnifiedProvider) BatchResults(ctx context.Context, batch *Batch) ([]BatchResult, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	defer endCall()
+
+	if batch.State != BatchCompleted {
+		return nil, fmt.Errorf("batch %s is not completed (state: %s)", batch.ID, batch.State)
+	}
+
+	key, err := p.rotator.GetKeyByName(ctx, string(batch.Provider), batch.KeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultsURL string
+	var parseLine func(line []byte, models map[string]string) (BatchResult, error)
+	switch batch.Provider {
+	case OpenAI:
+		resultsURL = "https://api.openai.com/v1/files/" + batch.OutputFile + "/content"
+		parseLine = parseOpenAIBatchResult
+	case Anthropic:
+		resultsURL = batch.ResultsURL
+		parseLine = parseAnthropicBatchResult
+	default:
+		return nil, fmt.Errorf("batch API not supported for provider: %s", batch.Provider)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", resultsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.setBatchHeaders(req, batch.Provider, key)
+
+	resp, err := p.httpClient(batch.Provider).Do(req)
+	if err != nil {
+		return nil, redact.Error(err, key.Key)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, redact.Error(parseAPIError(batch.Provider, resp), key.Key)
+	}
+
+	var results []BatchResult
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		result, err := parseLine(scanner.Bytes(), batch.Models)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Response != nil && p.piiVault != nil && batch.Conversation != "" {
+			if err := p.detokenizeResponse(batch.Conversation, result.Response); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+
+	if !batch.Recorded {
+		for _, result := range results {
+			if result.Response == nil {
+				continue
+			}
+			usage := result.Response.Usage
+			cost := p.CalculateCost(batch.Provider, result.Response.Model, usage) * batchDiscount
+			p.recordCost(ctx, batch.Provider, batch.KeyName, result.Response.Model, usage.TotalTokens, cost)
+			if tenantID := batch.Tenants[result.CustomID]; p.tenants != nil && tenantID != "" {
+				p.tenants.Record(tenantID, usage.TotalTokens, cost, time.Now())
+			}
+		}
+		batch.Recorded = true
+	}
+
+	return results, nil
+}
+
+// submitOpenAIBatch uploads the requests as a JSONL file and creates a batch
+// job. The file counts against global.request_limits.max_attachment_bytes
+// like other uploads.
+func (p *UnifiedProvider) submitOpenAIBatch(ctx context.Context, key *auth.KeySelection, requests []BatchRequest) (*Batch, error) {
+	var jsonl bytes.Buffer
+	enc := json.NewEncoder(&jsonl)
+	for _, r := range requests {
+		line := map[string]interface{}{
+			"custom_id": r.CustomID,
+			"method":    "POST",
+			"url":       "/v1/chat/completions",
+			"body":      openAIRequestBody(r.Messages, r.Options),
+		}
+		if err := enc.Encode(line); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := UploadOptions{Provider: OpenAI, Filename: "batch.jsonl", MIMEType: "application/jsonl", Purpose: "batch"}
+	file, err := p.upload(ctx, "https://api.openai.com/v1/files", key, opts, &jsonl, func(form *multipart.Writer, content io.Reader) error {
+		return writeOpenAIUpload(form, opts, content)
+	})
+	if err != nil {
+		return nil, redact.Error(err, key.Key)
+	}
+
+	body := map[string]interface{}{
+		"input_file_id":     file.ID,
+		"endpoint":          "/v1/chat/completions",
+		"completion_window": "24h",
+	}
+	var result map[string]interface{}
+	if err := p.batchRequest(ctx, "POST", "https://api.openai.com/v1/batches", body, key, &result); err != nil {
+		return nil, err
+	}
+
+	batch := parseOpenAIBatch(result)
+	batch.Provider = OpenAI
+	batch.KeyName = key.KeyName
+	return batch, nil
+}
+
+// submitAnthropicBatch creates an Anthropic message batch
+func (p *UnifiedProvider) submitAnthropicBatch(ctx context.Context, key *auth.KeySelection, requests []BatchRequest) (*Batch, error) {
+	items := make([]map[string]interface{}, len(requests))
+	for i, r := range requests {
+		params := anthropicRequestBody(r.Messages, r.Options)
+		delete(params, "stream")
+		items[i] = map[string]interface{}{
+			"custom_id": r.CustomID,
+			"params":    params,
+		}
+	}
+
+	var result map[string]interface{}
+	body := map[string]interface{}{"requests": items}
+	if err := p.batchRequest(ctx, "POST", "https://api.anthropic.com/v1/messages/batches", body, key, &result); err != nil {
+		return nil, err
+	}
+
+	batch := parseAnthropicBatch(result)
+	batch.Provider = Anthropic
+	batch.KeyName = key.KeyName
+	return batch, nil
+}
+
+// batchRequest performs a JSON request against a batch endpoint
+func (p *UnifiedProvider) batchRequest(ctx context.Context, method, url string, body interface{}, key *auth.KeySelection, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	p.setBatchHeaders(req, ProviderType(key.Provider), key)
+
+	resp, err := p.httpClient(ProviderType(key.Provider)).Do(req)
+	if err != nil {
+		return redact.Error(err, key.Key)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return redact.Error(parseAPIError(ProviderType(key.Provider), resp), key.Key)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+	return nil
+}
+
+// setBatchHeaders sets the authentication headers for a batch request
+func (p *UnifiedProvider) setBatchHeaders(req *http.Request, provider ProviderType, key *auth.KeySelection) {
+	switch provider {
+	case OpenAI:
+		setOpenAIHeaders(req, key)
+	case Anthropic:
+		p.anthropicHeaders(RequestOptions{})(req, key)
+	}
+}
+
+// parseOpenAIBatch converts an OpenAI batch object into a Batch
+func parseOpenAIBatch(result map[string]interface{}) *Batch {
+	batch := &Batch{}
+	batch.ID, _ = result["id"].(string)
+	batch.OutputFile, _ = result["output_file_id"].(string)
+	if created, ok := result["created_at"].(float64); ok {
+		batch.CreatedAt = time.Unix(int64(created), 0)
+	}
+
+	status, _ := result["status"].(string)
+	switch status {
+	case "completed":
+		batch.State = BatchCompleted
+	case "failed":
+		batch.State = BatchFailed
+	case "expired":
+		batch.State = BatchExpired
+	case "cancelling", "cancelled":
+		batch.State = BatchCancelled
+	default: // validating, in_progress, finalizing
+		batch.State = BatchInProgress
+	}
+
+	if counts, ok := result["request_counts"].(map[string]interface{}); ok {
+		batch.Counts.Total = intField(counts, "total")
+		batch.Counts.Completed = intField(counts, "completed")
+		batch.Counts.Failed = intField(counts, "failed")
+	}
+	return batch
+}
+
+// parseAnthropicBatch converts an Anthropic message batch object into a Batch
+func parseAnthropicBatch(result map[string]interface{}) *Batch {
+	batch := &Batch{}
+	batch.ID, _ = result["id"].(string)
+	batch.ResultsURL, _ = result["results_url"].(string)
+	if created, ok := result["created_at"].(string); ok {
+		batch.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	}
+
+	counts, _ := result["request_counts"].(map[string]interface{})
+	succeeded := intField(counts, "succeeded")
+	failed := intField(counts, "errored") + intField(counts, "canceled") + intField(counts, "expired")
+	batch.Counts = BatchCounts{
+		Total:     intField(counts, "processing") + succeeded + failed,
+		Completed: succeeded,
+		Failed:    failed,
+	}
+
+	status, _ := result["processing_status"].(string)
+	switch status {
+	case "ended":
+		batch.State = BatchCompleted
+	case "canceling":
+		batch.State = BatchCancelled
+	default:
+		batch.State = BatchInProgress
+	}
+	return batch
+}
+
+// parseOpenAIBatchResult parses one line of an OpenAI batch output file
+func parseOpenAIBatchResult(line []byte, models map[string]string) (BatchResult, error) {
+	var item struct {
+		CustomID string `json:"custom_id"`
+		Response *struct {
+			StatusCode int                    `json:"status_code"`
+			Body       map[string]interface{} `json:"body"`
+		} `json:"response"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(line, &item); err != nil {
+		return BatchResult{}, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+
+	result := BatchResult{CustomID: item.CustomID}
+	switch {
+	case item.Error != nil:
+		result.Error = item.Error.Message
+	case item.Response == nil:
+		result.Error = "missing response"
+	case item.Response.StatusCode != http.StatusOK:
+		result.Error = newAPIError(OpenAI, item.Response.StatusCode).Error()
+		result.Code = codeForStatus(item.Response.StatusCode)
+	default:
+		model := models[item.CustomID]
+		if model == "" {
+			model, _ = item.Response.Body["model"].(string)
+		}
+		completion, err := parseOpenAIResponse(item.Response.Body, OpenAI, model)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Response = completion
+		}
+	}
+	return result, nil
+}
+
+// parseAnthropicBatchResult parses one line of an Anthropic batch results file
+func parseAnthropicBatchResult(line []byte, models map[string]string) (BatchResult, error) {
+	var item struct {
+		CustomID string `json:"custom_id"`
+		Result   struct {
+			Type    string                 `json:"type"`
+			Message map[string]interface{} `json:"message"`
+			Error   map[string]interface{} `json:"error"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(line, &item); err != nil {
+		return BatchResult{}, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+
+	result := BatchResult{CustomID: item.CustomID}
+	if item.Result.Type != "succeeded" {
+		result.Error = "request " + item.Result.Type
+		return result, nil
+	}
+
+	model := models[item.CustomID]
+	if model == "" {
+		model, _ = item.Result.Message["model"].(string)
+	}
+	completion, err := parseAnthropicResponse(item.Result.Message, model)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Response = completion
+	}
+	return result, nil
+}
+
+// intField returns an integer field of a decoded JSON object, or 0
+func intField(m map[string]interface{}, name string) int {
+	if v, ok := m[name].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/tenant"
+)
+
+// openAIBatchServer serves the OpenAI files and batch endpoints for a batch
+// of one request "q1", answered with content. The uploaded input file is
+// stored in uploaded.
+func openAIBatchServer(t *testing.T, uploaded *string, content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/files":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			raw, _ := io.ReadAll(file)
+			*uploaded = string(raw)
+			fmt.Fprint(w, `{"id":"file-in"}`)
+		case r.Method == "POST" && r.URL.Path == "/v1/batches":
+			fmt.Fprint(w, `{"id":"batch_1","status":"validating"}`)
+		case r.URL.Path == "/v1/batches/batch_1":
+			fmt.Fprint(w, `{"id":"batch_1","status":"completed","output_file_id":"file-out"}`)
+		case r.URL.Path == "/v1/files/file-out/content":
+			line, _ := json.Marshal(map[string]interface{}{
+				"custom_id": "q1",
+				"response": map[string]interface{}{
+					"status_code": 200,
+					"body": map[string]interface{}{
+						"model":   "gpt-4o-2024-08-06",
+						"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"}},
+						"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+					},
+				},
+			})
+			w.Write(append(line, '\n'))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// TestBatchResultsRecordedOnce resumes a batch persisted as JSON and fetches
+// its results twice, which records the usage once at the configured model
+func TestBatchResultsRecordedOnce(t *testing.T) {
+	var uploaded string
+	provider := newTestProvider(t, config.HTTPConfig{}, openAIBatchServer(t, &uploaded, "Paris"))
+	ctx := context.Background()
+
+	submitted, err := provider.BatchSubmit(ctx, OpenAI, []BatchRequest{{
+		CustomID: "q1",
+		Messages: []Message{{Role: "user", Content: "Capital of France?"}},
+		Options:  RequestOptions{Model: "gpt-4o"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Persist and resume, as after a restart
+	raw, err := json.Marshal(submitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch *Batch
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.Models["q1"] != "gpt-4o" {
+		t.Fatalf("resumed batch has models %v", batch.Models)
+	}
+
+	if batch, err = provider.BatchStatus(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		results, err := provider.BatchResults(ctx, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Response == nil || results[0].Response.Model != "gpt-4o" {
+			t.Fatalf("unexpected results %+v", results)
+		}
+	}
+	if !batch.Recorded {
+		t.Error("batch isn't marked as recorded")
+	}
+
+	stats, err := provider.rotator.GetKeyStatistics(ctx, "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := stats["o1"]
+	if usage == nil || usage.TokensUsed != 15 {
+		t.Fatalf("key usage %+v, want 15 tokens recorded once", usage)
+	}
+	// 10 input and 5 output tokens of gpt-4o at the batch discount
+	if want := (10*0.0025 + 5*0.01) / 1000 * batchDiscount; usage.CostUsed < want*0.999 || usage.CostUsed > want*1.001 {
+		t.Errorf("recorded cost %f, want %f", usage.CostUsed, want)
+	}
+}
+
+// TestBatchSubmitChecksTenantQuota refuses a batch whose estimated cost
+// exceeds the tenant's remaining quota before anything is uploaded
+func TestBatchSubmitChecksTenantQuota(t *testing.T) {
+	var uploaded string
+	provider := newTestProvider(t, config.HTTPConfig{}, openAIBatchServer(t, &uploaded, "Paris"), func(b *config.Builder) {
+		b.Tenant("acme", config.TenantConfig{DailyCostLimit: 0.01})
+	})
+	provider.SetTenantManager(tenant.NewManager())
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	request := func(id string) BatchRequest {
+		return BatchRequest{
+			CustomID: id,
+			Messages: []Message{{Role: "user", Content: "Capital of France?"}},
+			Options:  RequestOptions{Model: "gpt-4o", MaxTokens: 1000},
+		}
+	}
+
+	// Each request may cost up to $0.005 at the batch discount
+	batch, err := provider.BatchSubmit(ctx, OpenAI, []BatchRequest{request("q1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Tenants["q1"] != "acme" {
+		t.Errorf("batch tenants %v, want q1 attributed to acme", batch.Tenants)
+	}
+
+	uploaded = ""
+	_, err = provider.BatchSubmit(ctx, OpenAI, []BatchRequest{request("q1"), request("q2"), request("q3")})
+	if !errors.Is(err, tenant.ErrQuotaExceeded) {
+		t.Fatalf("expected the quota to be exceeded, got %v", err)
+	}
+	if uploaded != "" {
+		t.Error("the refused batch was uploaded")
+	}
+}
+
+// TestBatchErrorsAreTyped checks that failed uploads and result downloads
+// return API errors with codes like other calls
+func TestBatchErrorsAreTyped(t *testing.T) {
+	unauthorized := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"type":"invalid_request_error","message":"Incorrect API key provided"}}`)
+	}
+	ctx := context.Background()
+
+	provider := newTestProvider(t, config.HTTPConfig{}, unauthorized)
+	_, err := provider.BatchSubmit(ctx, OpenAI, []BatchRequest{{
+		CustomID: "q1",
+		Messages: []Message{{Role: "user", Content: "Capital of France?"}},
+		Options:  RequestOptions{Model: "gpt-4o"},
+	}})
+	if code := CodeOf(err); code != CodeAuth {
+		t.Errorf("upload: code %s, want %s (%v)", code, CodeAuth, err)
+	}
+
+	batch := &Batch{ID: "batch_1", Provider: OpenAI, KeyName: "o1", State: BatchCompleted, OutputFile: "file-out"}
+	_, err = provider.BatchResults(ctx, batch)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeAuth {
+		t.Errorf("results: got %v, want an API error with code %s", err, CodeAuth)
+	}
+}
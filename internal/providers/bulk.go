@@ -0,0 +1,180 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MapResult is the outcome of one prompt of Map
+type MapResult struct {
+	Index    int
+	Prompt   string
+	Response *CompletionResponse
+	Err      error
+	Attempts int
+}
+
+// MapProgress reports how far a Map call has got
+type MapProgress struct {
+	Total     int
+	Completed int // prompts finished, successfully or not
+	Failed    int
+}
+
+// MapOption configures Map
+type MapOption func(*mapOptions)
+
+// mapOptions holds the settings applied by MapOption
+type mapOptions struct {
+	progress   func(MapProgress)
+	maxRetries int
+}
+
+// defaultMapRetries is how often Map retries a rate-limited prompt
+const defaultMapRetries = 3
+
+// WithProgress calls fn after every finished prompt. Calls are serialized.
+func WithProgress(fn func(MapProgress)) MapOption {
+	return func(o *mapOptions) {
+		o.progress = fn
+	}
+}
+
+// WithRateLimitRetries sets how often a rate-limited prompt is retried, 3 by default
+func WithRateLimitRetries(n int) MapOption {
+	return func(o *mapOptions) {
+		o.maxRetries = n
+	}
+}
+
+// Map sends every prompt with opts using concurrency workers and returns the
+// results in the order of prompts. When a provider rate-limits a request, all
+// workers pause for the Retry-After the provider asked for (or an
+// exponential backoff) and the prompt is retried. Other failures are recorded
+// in the prompt's result; the returned error joins them and is nil only if
+// every prompt succeeded.
+func (p *UnifiedProvider) Map(ctx context.Context, prompts []string, opts RequestOptions, concurrency int, options ...MapOption) ([]MapResult, error) {
+	settings := mapOptions{maxRetries: defaultMapRetries}
+	for _, option := range options {
+		option(&settings)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]MapResult, len(prompts))
+	indexes := make(chan int)
+	gate := &pauseGate{}
+
+	var progressMu sync.Mutex
+	progress := MapProgress{Total: len(prompts)}
+	finish := func(result MapResult) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		progress.Completed++
+		if result.Err != nil {
+			progress.Failed++
+		}
+		if settings.progress != nil {
+			settings.progress(progress)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(prompts); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = p.mapOne(ctx, i, prompts[i], opts, settings.maxRetries, gate)
+				finish(results[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range prompts {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			for j := i; j < len(prompts); j++ {
+				results[j] = MapResult{Index: j, Prompt: prompts[j], Err: ctx.Err()}
+			}
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("prompt %d: %w", result.Index, result.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("%d of %d prompts failed: %w", len(errs), len(prompts), errors.Join(errs...))
+	}
+	return results, nil
+}
+
+// mapOne sends one prompt of Map, retrying it when rate limited
+func (p *UnifiedProvider) mapOne(ctx context.Context, index int, prompt string, opts RequestOptions, maxRetries int, gate *pauseGate) MapResult {
+	result := MapResult{Index: index, Prompt: prompt}
+	for {
+		if err := gate.wait(ctx); err != nil {
+			result.Err = err
+			return result
+		}
+
+		result.Attempts++
+		result.Response, result.Err = p.Invoke(ctx, prompt, opts)
+		if CodeOf(result.Err) != CodeRateLimit || result.Attempts > maxRetries {
+			return result
+		}
+
+		delay, ok := RetryAfter(result.Err)
+		if !ok {
+			delay = time.Second << (result.Attempts - 1)
+		}
+		gate.pause(delay)
+	}
+}
+
+// pauseGate holds back the workers of a Map call while a provider is rate limiting
+type pauseGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// pause holds back workers for d, unless they are held back longer already
+func (g *pauseGate) pause(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// wait blocks until the gate is open or ctx is done
+func (g *pauseGate) wait(ctx context.Context) error {
+	for {
+		g.mu.Lock()
+		delay := time.Until(g.until)
+		g.mu.Unlock()
+		if delay <= 0 {
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package providers
+
+import "unicode/utf8"
+
+// Citation types
+const (
+	CitationURL      = "url"      // a web page, from web search or grounding
+	CitationDocument = "document" // a document or search result supplied with the request
+)
+
+// Citation attributes a span of the content of a response to a source
+type Citation struct {
+	Type  string `json:"type"`
+	URI   string `json:"uri,omitempty"`
+	Title string `json:"title,omitempty"`
+
+	// Start and End are the byte offsets of the cited span in Content. They
+	// are -1 when the provider cites the source for the response as a whole.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// CitedText is the text of the source supporting the span, if the provider returns it
+	CitedText string `json:"cited_text,omitempty"`
+}
+
+// citationSpan returns start and end as offsets into content, or -1 for
+// both if they don't delimit a span of it
+func citationSpan(content string, start, end int) (int, int) {
+	if start < 0 || end < start || end > len(content) {
+		return -1, -1
+	}
+	return start, end
+}
+
+// runeOffset converts an offset in characters into one in bytes of content
+func runeOffset(content string, chars int) int {
+	offset := 0
+	for i := 0; i < chars; i++ {
+		if offset >= len(content) {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// parseOpenAICitations returns the url_citation annotations of a chat
+// completions response message, whose offsets count characters
+func parseOpenAICitations(message map[string]interface{}, content string) []Citation {
+	annotations, _ := message["annotations"].([]interface{})
+	var citations []Citation
+	for _, item := range annotations {
+		annotation, _ := item.(map[string]interface{})
+		if annotation["type"] != "url_citation" {
+			continue
+		}
+		source, _ := annotation["url_citation"].(map[string]interface{})
+		uri, _ := source["url"].(string)
+		title, _ := source["title"].(string)
+		startIndex, _ := source["start_index"].(float64)
+		endIndex, _ := source["end_index"].(float64)
+
+		start, end := citationSpan(content, runeOffset(content, int(startIndex)), runeOffset(content, int(endIndex)))
+		citations = append(citations, Citation{Type: CitationURL, URI: uri, Title: title, Start: start, End: end})
+	}
+	return citations
+}
+
+// parseXAICitations returns the sources xAI lists for a response answered with live search
+func parseXAICitations(result map[string]interface{}) []Citation {
+	urls, _ := result["citations"].([]interface{})
+	var citations []Citation
+	for _, item := range urls {
+		if uri, ok := item.(string); ok {
+			citations = append(citations, Citation{Type: CitationURL, URI: uri, Start: -1, End: -1})
+		}
+	}
+	return citations
+}
+
+// parseAnthropicCitations returns the citations of a text block spanning
+// start to end of the content
+func parseAnthropicCitations(block map[string]interface{}, start, end int) []Citation {
+	items, _ := block["citations"].([]interface{})
+	var citations []Citation
+	for _, item := range items {
+		source, _ := item.(map[string]interface{})
+		citation := Citation{Type: CitationDocument, Start: start, End: end}
+		citation.CitedText, _ = source["cited_text"].(string)
+
+		switch source["type"] {
+		case "web_search_result_location":
+			citation.Type = CitationURL
+			citation.URI, _ = source["url"].(string)
+			citation.Title, _ = source["title"].(string)
+		case "search_result_location":
+			citation.URI, _ = source["source"].(string)
+			citation.Title, _ = source["title"].(string)
+		default: // char_location, page_location and content_block_location of documents
+			citation.Title, _ = source["document_title"].(string)
+		}
+		citations = append(citations, citation)
+	}
+	return citations
+}
+
+// parseGeminiCitations returns the grounding sources and recitation sources
+// of a candidate, whose offsets count bytes of the first part
+func parseGeminiCitations(candidate map[string]interface{}, content string) []Citation {
+	var citations []Citation
+
+	grounding, _ := candidate["groundingMetadata"].(map[string]interface{})
+	chunks, _ := grounding["groundingChunks"].([]interface{})
+	supports, _ := grounding["groundingSupports"].([]interface{})
+	for _, item := range supports {
+		support, _ := item.(map[string]interface{})
+		segment, _ := support["segment"].(map[string]interface{})
+		if partIndex, _ := segment["partIndex"].(float64); partIndex != 0 {
+			continue
+		}
+		startIndex, _ := segment["startIndex"].(float64)
+		endIndex, _ := segment["endIndex"].(float64)
+		start, end := citationSpan(content, int(startIndex), int(endIndex))
+
+		indices, _ := support["groundingChunkIndices"].([]interface{})
+		for _, index := range indices {
+			i, _ := index.(float64)
+			if int(i) < 0 || int(i) >= len(chunks) {
+				continue
+			}
+			citation := geminiChunkCitation(chunks[int(i)])
+			citation.Start, citation.End = start, end
+			citations = append(citations, citation)
+		}
+	}
+
+	metadata, _ := candidate["citationMetadata"].(map[string]interface{})
+	sources, _ := metadata["citationSources"].([]interface{})
+	for _, item := range sources {
+		source, _ := item.(map[string]interface{})
+		uri, _ := source["uri"].(string)
+		startIndex, _ := source["startIndex"].(float64)
+		endIndex, _ := source["endIndex"].(float64)
+		start, end := citationSpan(content, int(startIndex), int(endIndex))
+		citations = append(citations, Citation{Type: CitationURL, URI: uri, Start: start, End: end})
+	}
+	return citations
+}
+
+// geminiChunkCitation returns the source of a grounding chunk: a web page or
+// a document retrieved from a data store
+func geminiChunkCitation(item interface{}) Citation {
+	chunk, _ := item.(map[string]interface{})
+	citation := Citation{Type: CitationURL}
+	source, ok := chunk["web"].(map[string]interface{})
+	if !ok {
+		source, _ = chunk["retrievedContext"].(map[string]interface{})
+		citation.Type = CitationDocument
+	}
+	citation.URI, _ = source["uri"].(string)
+	citation.Title, _ = source["title"].(string)
+	return citation
+}
@@ -0,0 +1,222 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// completionsURLs are the endpoints of providers implementing the OpenAI
+// legacy completions API, which continues a raw prompt without chat formatting
+var completionsURLs = map[ProviderType]string{
+	OpenAI:   "https://api.openai.com/v1/completions",
+	XAI:      "https://api.x.ai/v1/completions",
+	DeepSeek: "https://api.deepseek.com/beta/completions",
+}
+
+// fimProviders accept a suffix on their completions endpoint and fill in the middle
+var fimProviders = map[ProviderType]bool{
+	OpenAI:   true,
+	DeepSeek: true,
+}
+
+// RawBackend is implemented by registered backends that can continue a raw
+// prompt, e.g. local models served in completion mode. Raw requests to
+// backends without it fail with ErrBadRequest. Backends supporting
+// fill-in-the-middle, such as Codestral, send opts.Suffix along.
+type RawBackend interface {
+	Complete(ctx context.Context, prompt string, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error)
+}
+
+// InvokeRaw sends prompt as is to the completions endpoint of the provider,
+// for uses such as code infill where chat formatting hurts quality. The
+// model continues the prompt; no system prompt or role markers are added.
+// Supported by OpenAI, xAI, DeepSeek and registered backends implementing
+// RawBackend. Middleware added with Use sees the request with opts.Raw set.
+func (p *UnifiedProvider) InvokeRaw(ctx context.Context, prompt string, opts RequestOptions) (*CompletionResponse, error) {
+	opts.Raw = true
+	return p.Invoke(ctx, prompt, opts)
+}
+
+// CodeComplete asks the model for the code between prefix and suffix, e.g.
+// the text before and after the cursor of an editor. It is sent as a raw
+// completion with a suffix, which DeepSeek, OpenAI and registered backends
+// implementing RawBackend support, or formatted with the fim_template of
+// the model for endpoints without a suffix parameter.
+func (p *UnifiedProvider) CodeComplete(ctx context.Context, prefix, suffix string, opts RequestOptions) (*CompletionResponse, error) {
+	opts.Suffix = suffix
+	return p.InvokeRaw(ctx, prefix, opts)
+}
+
+// rawRequest returns the prompt of a raw request, the contents of its
+// messages. A suffix is filled into the fim_template of the model, if it has
+// one, and then removed from the returned options.
+func (p *UnifiedProvider) rawRequest(messages []Message, opts RequestOptions) (string, RequestOptions) {
+	var prompt strings.Builder
+	for _, msg := range messages {
+		prompt.WriteString(msg.Content)
+	}
+	if opts.Suffix == "" {
+		return prompt.String(), opts
+	}
+
+	template := p.fimTemplate(opts)
+	if template == "" {
+		return prompt.String(), opts
+	}
+	suffix := opts.Suffix
+	opts.Suffix = ""
+	return strings.NewReplacer("{prefix}", prompt.String(), "{suffix}", suffix).Replace(template), opts
+}
+
+// fimTemplate returns the fim_template of the model of opts, empty if it has none
+func (p *UnifiedProvider) fimTemplate(opts RequestOptions) string {
+	providerCfg, err := p.getConfig().GetProvider(string(opts.Provider))
+	if err != nil {
+		return ""
+	}
+	model, err := providerCfg.GetModelByName(opts.Model)
+	if err != nil {
+		return ""
+	}
+	return model.FIMTemplate
+}
+
+// completionsRequestBody builds the legacy completions request body
+func completionsRequestBody(prompt string, opts RequestOptions) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":       opts.Model,
+		"prompt":      prompt,
+		"stream":      opts.Stream,
+		"temperature": opts.Temperature,
+		"top_p":       opts.TopP,
+	}
+	if opts.MaxTokens > 0 {
+		body["max_tokens"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		body["stop"] = opts.Stop
+	}
+	if opts.N > 1 {
+		body["n"] = opts.N
+	}
+	if opts.Suffix != "" {
+		body["suffix"] = opts.Suffix
+	}
+	return applyExtra(body, opts)
+}
+
+// parseCompletionsResponse converts a legacy completions response body into a CompletionResponse
+func parseCompletionsResponse(result map[string]interface{}, provider ProviderType, model string) (*CompletionResponse, error) {
+	choices, ok := result["choices"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing choices in response", ErrResponseFormat)
+	}
+	if len(choices) == 0 {
+		return nil, emptyResponseError(provider)
+	}
+
+	usage, ok := result["usage"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing usage in response", ErrResponseFormat)
+	}
+	promptTokens, _ := usage["prompt_tokens"].(float64)
+	completionTokens, _ := usage["completion_tokens"].(float64)
+	totalTokens, _ := usage["total_tokens"].(float64)
+
+	parsed := make([]Choice, 0, len(choices))
+	for i, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		text, ok := choice["text"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid choice format in response", ErrResponseFormat)
+		}
+		finishReason, _ := choice["finish_reason"].(string)
+		parsed = append(parsed, Choice{
+			Index:        i,
+			Content:      text,
+			FinishReason: normalizeFinishReason(provider, finishReason),
+		})
+	}
+
+	return &CompletionResponse{
+		Content: parsed[0].Content,
+		Model:   model,
+		Usage: TokenUsage{
+			PromptTokens:     int(promptTokens),
+			CompletionTokens: int(completionTokens),
+			TotalTokens:      int(totalTokens),
+			CachedTokens:     openAICachedTokens(usage),
+		},
+		ProviderName: string(provider),
+		FinishReason: parsed[0].FinishReason,
+		Choices:      parsed,
+		Metadata:     result,
+	}, nil
+}
+
+// callCompletions calls a provider that implements the OpenAI legacy completions API
+func (p *UnifiedProvider) callCompletions(ctx context.Context, provider ProviderType, prompt string, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	jsonData, err := json.Marshal(completionsRequestBody(prompt, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", completionsURLs[provider], bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setOpenAIHeaders(req, key)
+
+	req, trace := traceRequest(req, opts)
+	start := time.Now()
+	resp, err := p.httpClient(provider).Do(req)
+	if err != nil {
+		p.recordError(ctx, provider, key.KeyName, err)
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+	p.recordRateLimit(provider, key.KeyName, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		err = parseAPIError(provider, resp)
+		p.recordError(ctx, provider, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(provider, opts.Model, start)
+
+	var result map[string]interface{}
+	info, err := decodeResponse(resp, trace, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := parseCompletionsResponse(result, provider, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recordUsage(ctx, provider, key.KeyName, opts.Model, completion.Usage)
+
+	completion.ResponseInfo = info
+	completion.ProviderRequestID = providerRequestID(resp.Header)
+	return completion, nil
+}
+
+// rawUnsupportedError reports a raw request to a provider without a completions endpoint
+func rawUnsupportedError(provider ProviderType) *Error {
+	return &Error{
+		Code:     CodeBadRequest,
+		Provider: provider,
+		Message:  fmt.Sprintf("%s does not support raw completions", providerDisplayName(provider)),
+		Err:      ErrBadRequest,
+	}
+}
@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// gzipProviders accept gzip-encoded request bodies, which are compressed by
+// default; other providers reject them and need compression.requests: gzip
+var gzipProviders = map[ProviderType]bool{
+	Gemini: true,
+	Vertex: true,
+}
+
+// compressTransport gzips request bodies of at least minBytes when compress
+// is set and decompresses gzip responses the base transport left encoded,
+// e.g. because Accept-Encoding was set explicitly through global.http.headers
+type compressTransport struct {
+	base     http.RoundTripper
+	compress bool
+	minBytes int
+}
+
+// withCompression returns a copy of client that compresses request bodies as
+// configured for provider. client itself is left unchanged.
+func withCompression(client *http.Client, cfg *config.Config, provider ProviderType) *http.Client {
+	compression := cfg.Providers[string(provider)].Compression
+	transport := &compressTransport{base: client.Transport, minBytes: compression.GetMinBytes()}
+	switch compression.Requests {
+	case config.CompressionGzip:
+		transport.compress = true
+	case config.CompressionNone:
+	default:
+		transport.compress = gzipProviders[provider]
+	}
+
+	wrapped := *client
+	wrapped.Transport = transport
+	return &wrapped
+}
+
+// compressionChanged reports whether the compression settings of any provider differ
+func compressionChanged(old, cfg *config.Config) bool {
+	for name, provider := range cfg.Providers {
+		if old.Providers[name].Compression != provider.Compression {
+			return true
+		}
+	}
+	for name, provider := range old.Providers {
+		if _, exists := cfg.Providers[name]; !exists && provider.Compression != (config.CompressionConfig{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *compressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.compress && req.Body != nil && req.Body != http.NoBody && req.GetBody != nil &&
+		req.ContentLength >= int64(t.minBytes) && req.Header.Get("Content-Encoding") == "" {
+		compressed, err := gzipBody(req)
+		if err != nil {
+			return nil, err
+		}
+		req = compressed
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Uncompressed && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") && req.Method != http.MethodHead {
+		resp.Body = &gzipReader{body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the base transport
+func (t *compressTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// gzipBody returns a copy of req with its body gzipped. Only bodies that can
+// be read again, i.e. with GetBody, are compressed, so req keeps its own.
+func gzipBody(req *http.Request) (*http.Request, error) {
+	// The original body won't be sent, but a RoundTripper must close it
+	defer req.Body.Close()
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	buf.Grow(int(req.ContentLength / 4))
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, body); err != nil {
+		return nil, fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress request body: %w", err)
+	}
+
+	// A RoundTripper must not modify the request it was given
+	compressed := req.Clone(req.Context())
+	data := buf.Bytes()
+	compressed.Body = io.NopCloser(bytes.NewReader(data))
+	compressed.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	compressed.ContentLength = int64(len(data))
+	compressed.Header.Set("Content-Encoding", "gzip")
+	compressed.Header.Del("Content-Length")
+	return compressed, nil
+}
+
+// gzipReader decompresses a response body, reading the gzip header on the
+// first Read so streams aren't blocked until the first event arrives
+type gzipReader struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+// Read implements io.Reader
+func (r *gzipReader) Read(p []byte) (int, error) {
+	if r.zr == nil && r.err == nil {
+		r.zr, r.err = gzip.NewReader(r.body)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.zr.Read(p)
+}
+
+// Close closes the response body
+func (r *gzipReader) Close() error {
+	return r.body.Close()
+}
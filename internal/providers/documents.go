@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/document"
+)
+
+// imageTypes are the image types that OpenAI and Anthropic accept
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// NewDocument returns an attachment sending data inline with its message,
+// its MIME type detected from the extension of filename. Providers that
+// read the type natively receive the document itself, others its text.
+func NewDocument(filename string, data []byte) Attachment {
+	if data == nil {
+		data = []byte{}
+	}
+	return Attachment{
+		Filename: filepath.Base(filename),
+		MIMEType: document.MIMEType(filename),
+		Data:     data,
+	}
+}
+
+// ReadDocument reads the file at path as an inline attachment, see NewDocument
+func ReadDocument(path string) (Attachment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read document: %w", err)
+	}
+	return NewDocument(path, data), nil
+}
+
+// nativeAttachment reports whether provider reads inline attachments of mimeType itself
+func nativeAttachment(provider ProviderType, mimeType string) bool {
+	switch provider {
+	case OpenAI:
+		return mimeType == document.TypePDF || imageTypes[mimeType]
+	case Anthropic:
+		return mimeType == document.TypePDF || mimeType == document.TypePlain || imageTypes[mimeType]
+	case Gemini, Vertex:
+		for _, prefix := range []string{"text/", "image/", "audio/", "video/"} {
+			if strings.HasPrefix(mimeType, prefix) {
+				return true
+			}
+		}
+		return mimeType == document.TypePDF
+	}
+	return false
+}
+
+// prepareAttachments checks the inline attachments of msgs against the
+// attachment size limit and replaces those provider can't read natively by
+// their text, prepended to the content of their message. msgs itself is
+// left unchanged.
+func (p *UnifiedProvider) prepareAttachments(msgs []Message, provider ProviderType) ([]Message, error) {
+	prepared := msgs
+	copied := false
+	for i, msg := range msgs {
+		if len(msg.Attachments) == 0 {
+			continue
+		}
+
+		var kept []Attachment
+		var texts []string
+		for _, attachment := range msg.Attachments {
+			if !attachment.Inline() {
+				kept = append(kept, attachment)
+				continue
+			}
+			if err := p.checkAttachmentSize(provider, len(attachment.Data)); err != nil {
+				return nil, err
+			}
+			if nativeAttachment(provider, attachment.MIMEType) {
+				kept = append(kept, attachment)
+				continue
+			}
+
+			text, err := document.ExtractText(attachment.MIMEType, attachment.Data)
+			if err != nil {
+				return nil, documentError(provider, attachment, err)
+			}
+			texts = append(texts, documentText(attachment, text))
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		if !copied {
+			prepared = append([]Message(nil), msgs...)
+			copied = true
+		}
+		if msg.Content != "" {
+			texts = append(texts, msg.Content)
+		}
+		prepared[i].Content = strings.Join(texts, "\n\n")
+		prepared[i].Attachments = kept
+	}
+	return prepared, nil
+}
+
+// documentText wraps the text extracted from an attachment in document tags naming it
+func documentText(attachment Attachment, text string) string {
+	if attachment.Filename == "" {
+		return "<document>\n" + text + "\n</document>"
+	}
+	return fmt.Sprintf("<document name=%q>\n%s\n</document>", attachment.Filename, text)
+}
+
+// documentError reports an inline attachment that provider can't read and
+// whose text can't be extracted
+func documentError(provider ProviderType, attachment Attachment, err error) *Error {
+	name := attachment.Filename
+	if name == "" {
+		name = "attachment"
+	}
+	message := fmt.Sprintf("can't read %s: %v", name, err)
+	if errors.Is(err, document.ErrUnsupported) {
+		message = fmt.Sprintf("%s does not support %s attachments", providerDisplayName(provider), attachment.MIMEType)
+	}
+	return attachmentError(provider, "%s", message)
+}
+
+// dataURL returns the content of an inline attachment as a data URL
+func dataURL(attachment Attachment) string {
+	return "data:" + attachment.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(attachment.Data)
+}
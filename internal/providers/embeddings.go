@@ -0,0 +1,500 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// Default embedding models, used when EmbedOptions.Model is empty. Like other
+// models they must be listed in the provider's models.
+const (
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	DefaultGeminiEmbeddingModel = "gemini-embedding-001"
+	DefaultVertexEmbeddingModel = "text-embedding-005"
+)
+
+// defaultEmbedConcurrency is how many chunks Embed sends at once by default
+const defaultEmbedConcurrency = 4
+
+// mockEmbeddingDimensions is the size of mock vectors without EmbedOptions.Dimensions
+const mockEmbeddingDimensions = 8
+
+// embeddingLimits are the per-request limits of a provider's embedding endpoint
+type embeddingLimits struct {
+	maxInputs int
+	maxTokens int // 0 if the provider only limits single inputs
+}
+
+// providerEmbeddingLimits are the documented limits of each embedding endpoint
+var providerEmbeddingLimits = map[ProviderType]embeddingLimits{
+	OpenAI: {maxInputs: 2048, maxTokens: 300000},
+	Gemini: {maxInputs: 100},
+	Vertex: {maxInputs: 250, maxTokens: 20000},
+	Mock:   {maxInputs: 16, maxTokens: 2000},
+}
+
+// EmbedOptions contains options for embedding requests
+type EmbedOptions struct {
+	// Provider defaults to the default provider. OpenAI, Gemini and Vertex AI
+	// have embedding endpoints.
+	Provider ProviderType `json:"provider,omitempty"`
+
+	// Model defaults to the provider's default embedding model
+	Model string `json:"model,omitempty"`
+
+	// Dimensions shortens the vectors, for models that support it
+	Dimensions int `json:"dimensions,omitempty"`
+
+	// TaskType tells Gemini and Vertex AI what the vectors are for, e.g.
+	// "RETRIEVAL_DOCUMENT" or "RETRIEVAL_QUERY"
+	TaskType string `json:"task_type,omitempty"`
+
+	// MaxInputsPerRequest and MaxTokensPerRequest override the provider's
+	// limits used to split the inputs into chunks
+	MaxInputsPerRequest int `json:"max_inputs_per_request,omitempty"`
+	MaxTokensPerRequest int `json:"max_tokens_per_request,omitempty"`
+
+	// Concurrency is how many chunks are sent at once, 4 by default
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// MaxRetries is how often a chunk that failed with a rate limit, timeout
+	// or upstream error is retried, 3 by default. Negative disables retries.
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// Progress is called after every finished chunk. Calls are serialized.
+	Progress func(EmbedProgress) `json:"-"`
+
+	// Timeout bounds each provider call. Defaults to the provider's timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// TenantID attributes the request to a tenant, overriding tenant.WithTenant on the context
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// EmbedProgress reports how far an Embed call has got
+type EmbedProgress struct {
+	TotalInputs     int
+	EmbeddedInputs  int
+	TotalChunks     int
+	CompletedChunks int // chunks finished, successfully or not
+	FailedChunks    int
+}
+
+// EmbedResponse holds the vectors of an Embed call
+type EmbedResponse struct {
+	// Embeddings are in the order of the inputs. Inputs of failed chunks have none.
+	Embeddings   [][]float32 `json:"embeddings"`
+	Model        string      `json:"model"`
+	ProviderName string      `json:"provider"`
+	Usage        TokenUsage  `json:"usage"`
+	Cost         float64     `json:"cost"`
+	Chunks       int         `json:"chunks"`
+}
+
+// embedChunk is a run of inputs sent in one request
+type embedChunk struct {
+	start, end int // inputs[start:end]
+	tokens     int
+}
+
+// Embed returns the embedding vectors of inputs. The inputs are split into
+// chunks that respect the provider's inputs and tokens per request, which
+// are sent using opts.Concurrency workers. Chunks failing with a retryable
+// error are retried on their own; when a provider rate-limits, all workers
+// pause for the Retry-After it asked for (or an exponential backoff). If
+// chunks still fail, the response holds the vectors of the others and the
+// returned error joins the failures.
+func (p *UnifiedProvider) Embed(ctx context.Context, inputs []string, opts EmbedOptions) (*EmbedResponse, error) {
+	if opts.Provider == "" {
+		opts.Provider = p.defaultProvider()
+	}
+	if opts.Model == "" {
+		opts.Model = defaultEmbeddingModel(opts.Provider)
+	}
+	limits, ok := providerEmbeddingLimits[opts.Provider]
+	if !ok {
+		return nil, fmt.Errorf("embeddings not supported for provider: %s", opts.Provider)
+	}
+	if opts.MaxInputsPerRequest > 0 {
+		limits.maxInputs = opts.MaxInputsPerRequest
+	}
+	if opts.MaxTokensPerRequest > 0 {
+		limits.maxTokens = opts.MaxTokensPerRequest
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = defaultEmbedConcurrency
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultMapRetries
+	}
+	for i, input := range inputs {
+		if input == "" {
+			return nil, &Error{
+				Code:     CodeBadRequest,
+				Provider: opts.Provider,
+				Message:  fmt.Sprintf("input %d is empty", i),
+				Err:      ErrBadRequest,
+			}
+		}
+	}
+
+	// PII is replaced by vault tokens before the inputs leave
+	inputs, _, forget, err := p.tokenizeInputs(inputs)
+	if err != nil {
+		return nil, err
+	}
+	defer forget()
+
+	chunks := chunkEmbedInputs(inputs, opts.Model, limits)
+	resp := &EmbedResponse{
+		Embeddings:   make([][]float32, len(inputs)),
+		Model:        opts.Model,
+		ProviderName: string(opts.Provider),
+		Chunks:       len(chunks),
+	}
+	chunkErrs := make([]error, len(chunks))
+	indexes := make(chan int)
+	gate := &pauseGate{}
+
+	var mu sync.Mutex
+	progress := EmbedProgress{TotalInputs: len(inputs), TotalChunks: len(chunks)}
+	finish := func(chunk embedChunk, usage mediaUsage, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.CompletedChunks++
+		if err != nil {
+			progress.FailedChunks++
+		} else {
+			progress.EmbeddedInputs += chunk.end - chunk.start
+			resp.Usage.PromptTokens += usage.Usage.PromptTokens
+			resp.Usage.TotalTokens += usage.Usage.TotalTokens
+			resp.Cost += usage.Cost
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				chunk := chunks[i]
+				usage, err := p.embedChunk(ctx, inputs[chunk.start:chunk.end], chunk, opts, resp.Embeddings[chunk.start:chunk.end], gate)
+				chunkErrs[i] = err
+				finish(chunk, usage, err)
+			}
+		}()
+	}
+
+feed:
+	for i := range chunks {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			for j := i; j < len(chunks); j++ {
+				chunkErrs[j] = ctx.Err()
+			}
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs []error
+	for i, err := range chunkErrs {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chunk %d (inputs %d-%d): %w", i, chunks[i].start, chunks[i].end-1, err))
+		}
+	}
+	if len(errs) > 0 {
+		return resp, fmt.Errorf("%d of %d chunks failed: %w", len(errs), len(chunks), errors.Join(errs...))
+	}
+	return resp, nil
+}
+
+// defaultEmbeddingModel returns the embedding model used for provider when none is set
+func defaultEmbeddingModel(provider ProviderType) string {
+	switch provider {
+	case OpenAI:
+		return DefaultOpenAIEmbeddingModel
+	case Gemini:
+		return DefaultGeminiEmbeddingModel
+	case Vertex:
+		return DefaultVertexEmbeddingModel
+	}
+	return ""
+}
+
+// chunkEmbedInputs splits inputs greedily into chunks within limits. An input
+// exceeding the token limit on its own gets a chunk of its own, for the
+// provider to truncate or reject.
+func chunkEmbedInputs(inputs []string, model string, limits embeddingLimits) []embedChunk {
+	var chunks []embedChunk
+	current := embedChunk{}
+	for i, input := range inputs {
+		tokens := tokenizer.CountTokens(model, input)
+		full := current.end-current.start >= limits.maxInputs ||
+			(limits.maxTokens > 0 && current.tokens+tokens > limits.maxTokens)
+		if current.end > current.start && full {
+			chunks = append(chunks, current)
+			current = embedChunk{start: i, end: i}
+		}
+		current.end = i + 1
+		current.tokens += tokens
+	}
+	if current.end > current.start {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// embedChunk embeds the inputs of one chunk into vectors, retrying it while
+// it fails with a retryable error
+func (p *UnifiedProvider) embedChunk(ctx context.Context, inputs []string, chunk embedChunk, opts EmbedOptions, vectors [][]float32, gate *pauseGate) (mediaUsage, error) {
+	size := 0
+	for _, input := range inputs {
+		size += len(input)
+	}
+
+	for attempts := 1; ; attempts++ {
+		if err := gate.wait(ctx); err != nil {
+			return mediaUsage{}, err
+		}
+
+		req := mediaRequest{
+			Provider:    opts.Provider,
+			Model:       opts.Model,
+			Timeout:     opts.Timeout,
+			TenantID:    opts.TenantID,
+			PromptBytes: size,
+		}
+		var usage mediaUsage
+		err := p.invokeMedia(ctx, &req, func(ctx context.Context, key *auth.KeySelection) (mediaUsage, error) {
+			var err error
+			switch req.Provider {
+			case OpenAI:
+				usage.Usage, err = p.embedOpenAI(ctx, inputs, opts, key, vectors)
+			case Gemini:
+				err = p.embedGemini(ctx, inputs, opts, key, vectors)
+				usage.Usage = TokenUsage{PromptTokens: chunk.tokens, TotalTokens: chunk.tokens}
+			case Vertex:
+				usage.Usage, err = p.embedVertex(ctx, inputs, opts, key, vectors)
+			case Mock:
+				err = embedMock(ctx, inputs, opts, vectors)
+				usage.Usage = TokenUsage{PromptTokens: chunk.tokens, TotalTokens: chunk.tokens}
+			default:
+				err = fmt.Errorf("embeddings not supported for provider: %s", req.Provider)
+			}
+			if err != nil {
+				return mediaUsage{}, err
+			}
+			usage.Cost = p.CalculateCost(req.Provider, req.Model, usage.Usage)
+			return usage, nil
+		})
+		if err == nil {
+			return usage, nil
+		}
+		if !retryableEmbedError(err) || attempts > opts.MaxRetries {
+			return mediaUsage{}, err
+		}
+
+		delay, ok := RetryAfter(err)
+		if !ok {
+			delay = time.Second << (attempts - 1)
+		}
+		if CodeOf(err) == CodeRateLimit {
+			gate.pause(delay)
+			continue
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return mediaUsage{}, ctx.Err()
+		}
+	}
+}
+
+// retryableEmbedError reports whether a chunk may succeed when sent again
+func retryableEmbedError(err error) bool {
+	switch CodeOf(err) {
+	case CodeRateLimit, CodeUnavailable, CodeUpstream, CodeTimeout:
+		return true
+	}
+	return false
+}
+
+// embedOpenAI calls the OpenAI embeddings API
+func (p *UnifiedProvider) embedOpenAI(ctx context.Context, inputs []string, opts EmbedOptions, key *auth.KeySelection, vectors [][]float32) (TokenUsage, error) {
+	reqBody := map[string]interface{}{
+		"model": opts.Model,
+		"input": inputs,
+	}
+	if opts.Dimensions > 0 {
+		reqBody["dimensions"] = opts.Dimensions
+	}
+	setHeaders := func(req *http.Request) error {
+		setOpenAIHeaders(req, key)
+		return nil
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := p.postImageRequest(ctx, OpenAI, "https://api.openai.com/v1/embeddings", reqBody, key, setHeaders, &result); err != nil {
+		return TokenUsage{}, err
+	}
+	if len(result.Data) != len(inputs) {
+		return TokenUsage{}, fmt.Errorf("%w: got %d embeddings for %d inputs", ErrResponseFormat, len(result.Data), len(inputs))
+	}
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(inputs) {
+			return TokenUsage{}, fmt.Errorf("%w: embedding index %d out of range", ErrResponseFormat, item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return TokenUsage{PromptTokens: result.Usage.PromptTokens, TotalTokens: result.Usage.TotalTokens}, nil
+}
+
+// embedGemini calls the Gemini batchEmbedContents API, which doesn't report token usage
+func (p *UnifiedProvider) embedGemini(ctx context.Context, inputs []string, opts EmbedOptions, key *auth.KeySelection, vectors [][]float32) error {
+	requests := make([]map[string]interface{}, len(inputs))
+	for i, input := range inputs {
+		request := map[string]interface{}{
+			"model":   "models/" + opts.Model,
+			"content": map[string]interface{}{"parts": []map[string]string{{"text": input}}},
+		}
+		if opts.TaskType != "" {
+			request["taskType"] = opts.TaskType
+		}
+		if opts.Dimensions > 0 {
+			request["outputDimensionality"] = opts.Dimensions
+		}
+		requests[i] = request
+	}
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents",
+		url.PathEscape(opts.Model))
+	setHeaders := func(req *http.Request) error {
+		setGeminiHeaders(req, key)
+		return nil
+	}
+
+	var result struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	if err := p.postImageRequest(ctx, Gemini, apiURL, map[string]interface{}{"requests": requests}, key, setHeaders, &result); err != nil {
+		return err
+	}
+	if len(result.Embeddings) != len(inputs) {
+		return fmt.Errorf("%w: got %d embeddings for %d inputs", ErrResponseFormat, len(result.Embeddings), len(inputs))
+	}
+	for i, embedding := range result.Embeddings {
+		vectors[i] = embedding.Values
+	}
+	return nil
+}
+
+// embedVertex calls the Vertex AI predict API of an embedding model
+func (p *UnifiedProvider) embedVertex(ctx context.Context, inputs []string, opts EmbedOptions, key *auth.KeySelection, vectors [][]float32) (TokenUsage, error) {
+	instances := make([]map[string]string, len(inputs))
+	for i, input := range inputs {
+		instances[i] = map[string]string{"content": input}
+		if opts.TaskType != "" {
+			instances[i]["task_type"] = opts.TaskType
+		}
+	}
+	reqBody := map[string]interface{}{"instances": instances}
+	if opts.Dimensions > 0 {
+		reqBody["parameters"] = map[string]int{"outputDimensionality": opts.Dimensions}
+	}
+	apiURL, err := p.vertexURL(opts.Model, "predict")
+	if err != nil {
+		return TokenUsage{}, err
+	}
+	setHeaders := func(req *http.Request) error {
+		return p.setVertexHeaders(ctx, req, key)
+	}
+
+	var result struct {
+		Predictions []struct {
+			Embeddings struct {
+				Values     []float32 `json:"values"`
+				Statistics struct {
+					TokenCount float64 `json:"token_count"`
+				} `json:"statistics"`
+			} `json:"embeddings"`
+		} `json:"predictions"`
+	}
+	if err := p.postImageRequest(ctx, Vertex, apiURL, reqBody, key, setHeaders, &result); err != nil {
+		return TokenUsage{}, err
+	}
+	if len(result.Predictions) != len(inputs) {
+		return TokenUsage{}, fmt.Errorf("%w: got %d embeddings for %d inputs", ErrResponseFormat, len(result.Predictions), len(inputs))
+	}
+	tokens := 0
+	for i, prediction := range result.Predictions {
+		vectors[i] = prediction.Embeddings.Values
+		tokens += int(prediction.Embeddings.Statistics.TokenCount)
+	}
+	return TokenUsage{PromptTokens: tokens, TotalTokens: tokens}, nil
+}
+
+// embedMock returns deterministic unit vectors derived from the inputs and
+// applies the failure injection markers of the inputs
+func embedMock(ctx context.Context, inputs []string, opts EmbedOptions, vectors [][]float32) error {
+	messages := make([]Message, len(inputs))
+	for i, input := range inputs {
+		messages[i] = Message{Role: "user", Content: input}
+	}
+	if _, err := mockCompletion(ctx, messages, RequestOptions{Model: opts.Model}); err != nil {
+		return err
+	}
+
+	dimensions := opts.Dimensions
+	if dimensions <= 0 {
+		dimensions = mockEmbeddingDimensions
+	}
+	for i, input := range inputs {
+		vector := make([]float32, dimensions)
+		var norm float64
+		seed := sha256.Sum256([]byte(input))
+		for d := range vector {
+			if d%8 == 0 && d > 0 {
+				seed = sha256.Sum256(seed[:])
+			}
+			value := float64(int32(binary.BigEndian.Uint32(seed[d%8*4:]))) / math.MaxInt32
+			vector[d] = float32(value)
+			norm += value * value
+		}
+		norm = math.Sqrt(norm)
+		for d := range vector {
+			vector[d] = float32(float64(vector[d]) / norm)
+		}
+		vectors[i] = vector
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/redact"
+	"github.com/gollmkit/gollmkit/internal/tenant"
+)
+
+// ErrorCode is a stable, machine-readable error identifier of the form
+// GLK-<http status>-<class>. Codes are never renamed once released, so
+// alerting and retry logic can rely on them instead of error messages.
+type ErrorCode string
+
+const (
+	CodeBadRequest     ErrorCode = "GLK-400-REQUEST"
+	CodeContextLength  ErrorCode = "GLK-400-CONTEXT_LENGTH"
+	CodeContentFilter  ErrorCode = "GLK-400-CONTENT_FILTER"
+	CodeInvalidModel   ErrorCode = "GLK-400-MODEL"
+	CodeInvalidConfig  ErrorCode = "GLK-400-CONFIG"
+	CodeAuth           ErrorCode = "GLK-401-AUTH"
+	CodeForbidden      ErrorCode = "GLK-403-FORBIDDEN"
+	CodeNotFound       ErrorCode = "GLK-404-NOT_FOUND"
+	CodeTooLarge       ErrorCode = "GLK-413-TOO_LARGE"
+	CodeRateLimit      ErrorCode = "GLK-429-RATE"
+	CodeInternal       ErrorCode = "GLK-500-INTERNAL"
+	CodeUpstream       ErrorCode = "GLK-502-UPSTREAM"
+	CodeResponseFormat ErrorCode = "GLK-502-FORMAT"
+	CodeUnavailable    ErrorCode = "GLK-503-UNAVAILABLE"
+	CodeKeyRotation    ErrorCode = "GLK-503-KEYS"
+	CodeTimeout        ErrorCode = "GLK-504-TIMEOUT"
+	CodeBudget         ErrorCode = "GLK-507-BUDGET"
+	CodeCanceled       ErrorCode = "GLK-499-CANCELED"
+)
+
+// Provider error classes. Errors returned for failed provider calls wrap one
+// of these, so callers can branch with errors.Is.
+var (
+	ErrRateLimited           = errors.New("rate limited")
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	ErrContentFiltered       = errors.New("content filtered")
+	ErrAuth                  = errors.New("authentication failed")
+	ErrOverloaded            = errors.New("provider overloaded")
+	ErrBadRequest            = errors.New("bad request")
+	ErrUpstream              = errors.New("provider error")
+	ErrEmptyResponse         = errors.New("provider returned no completion")
+)
+
+// Error is an error with a stable code, returned for failed provider calls
+type Error struct {
+	Code       ErrorCode
+	Provider   ProviderType
+	StatusCode int // HTTP status returned by the provider, 0 if none
+	Message    string
+	Err        error         // underlying cause, if any
+	RetryAfter time.Duration // how long the provider asked to wait before retrying, 0 if unknown
+
+	RequestID         string // ID gollmkit assigned to the request
+	ProviderRequestID string // ID the provider assigned to the request, for support tickets
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON serializes the error as an ErrorInfo
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ErrorInfoOf(e))
+}
+
+// ErrorInfo is the JSON representation of an error
+type ErrorInfo struct {
+	Code       ErrorCode     `json:"code"`
+	Message    string        `json:"message"`
+	Provider   string        `json:"provider,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+
+	RequestID         string `json:"request_id,omitempty"`
+	ProviderRequestID string `json:"provider_request_id,omitempty"`
+}
+
+// ErrorInfoOf returns the JSON representation of any error. Anything that
+// looks like key material is redacted from the message.
+func ErrorInfoOf(err error) ErrorInfo {
+	info := ErrorInfo{Code: CodeOf(err), Message: redact.String(err.Error())}
+
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		info.Provider = string(providerErr.Provider)
+		info.StatusCode = providerErr.StatusCode
+		info.RetryAfter = providerErr.RetryAfter
+		info.RequestID = providerErr.RequestID
+		info.ProviderRequestID = providerErr.ProviderRequestID
+	}
+	return info
+}
+
+// RetryAfter returns how long the provider asked to wait before retrying
+func RetryAfter(err error) (time.Duration, bool) {
+	var providerErr *Error
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// MarshalError serializes any error as JSON, e.g. {"code":"GLK-429-RATE","message":"..."}
+func MarshalError(err error) []byte {
+	data, marshalErr := json.Marshal(ErrorInfoOf(err))
+	if marshalErr != nil {
+		// ErrorInfo only holds strings and ints, so this can't happen in practice
+		return []byte(fmt.Sprintf(`{"code":%q,"message":%q}`, CodeInternal, err.Error()))
+	}
+	return data
+}
+
+// CodeOf returns the error code of err. Errors without a more specific code
+// are reported as CodeInternal.
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+
+	var providerErr *Error
+	var validationErr *config.ValidationError
+	switch {
+	case errors.As(err, &providerErr) && providerErr.Code != "":
+		return providerErr.Code
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	case errors.Is(err, tenant.ErrQuotaExceeded):
+		return CodeBudget
+	case errors.Is(err, ErrRateLimited), errors.Is(err, tenant.ErrRateLimited):
+		return CodeRateLimit
+	case errors.Is(err, ErrContextLengthExceeded):
+		return CodeContextLength
+	case errors.Is(err, ErrContentFiltered):
+		return CodeContentFilter
+	case errors.Is(err, ErrRequestTooLarge):
+		return CodeTooLarge
+	case errors.Is(err, ErrAuth):
+		return CodeAuth
+	case errors.Is(err, ErrOverloaded), errors.Is(err, ErrShutdown):
+		return CodeUnavailable
+	case errors.Is(err, auth.ErrBudgetExceeded):
+		return CodeBudget
+	case errors.Is(err, ErrInvalidModel), errors.Is(err, auth.ErrModelNotAllowed):
+		return CodeInvalidModel
+	case errors.Is(err, ErrInvalidConfig), errors.As(err, &validationErr):
+		return CodeInvalidConfig
+	case errors.Is(err, ErrResponseFormat):
+		return CodeResponseFormat
+	case errors.Is(err, ErrKeyRotation):
+		return CodeKeyRotation
+	case errors.Is(err, fs.ErrNotExist):
+		return CodeNotFound
+	default:
+		return CodeInternal
+	}
+}
+
+// maxErrorBodySize bounds how much of an error response body is read
+const maxErrorBodySize = 64 * 1024
+
+// classifyStatus maps a provider HTTP status to an error class and code
+func classifyStatus(status int) (error, ErrorCode) {
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrAuth, CodeAuth
+	case status == http.StatusForbidden:
+		return ErrAuth, CodeForbidden
+	case status == http.StatusNotFound:
+		return ErrBadRequest, CodeNotFound
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrRequestTooLarge, CodeTooLarge
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited, CodeRateLimit
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return ErrTimeout, CodeTimeout
+	case status == http.StatusServiceUnavailable, status == 529: // 529: Anthropic overloaded
+		return ErrOverloaded, CodeUnavailable
+	case status >= 400 && status < 500:
+		return ErrBadRequest, CodeBadRequest
+	default:
+		return ErrUpstream, CodeUpstream
+	}
+}
+
+// codeForStatus maps a provider HTTP status to an error code
+func codeForStatus(status int) ErrorCode {
+	_, code := classifyStatus(status)
+	return code
+}
+
+// newAPIError creates the error for a non-OK provider response
+func newAPIError(provider ProviderType, status int) *Error {
+	class, code := classifyStatus(status)
+	return &Error{
+		Code:       code,
+		Provider:   provider,
+		StatusCode: status,
+		Message:    fmt.Sprintf("%s API error: %d", providerDisplayName(provider), status),
+		Err:        class,
+	}
+}
+
+// parseAPIError creates the error for a non-OK provider response, refining
+// the class from the provider's error body and reading retry-after hints
+func parseAPIError(provider ProviderType, resp *http.Response) *Error {
+	apiErr := newAPIError(provider, resp.StatusCode)
+	apiErr.RetryAfter = parseRetryAfter(resp.Header, time.Now())
+	apiErr.ProviderRequestID = providerRequestID(resp.Header)
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	errType, message := parseErrorBody(body)
+	if message != "" {
+		apiErr.Message += " " + message
+	}
+
+	refineError(apiErr, errType, message)
+	return apiErr
+}
+
+// refineError narrows the class of apiErr using the provider's error type and message
+func refineError(apiErr *Error, errType, message string) {
+	switch {
+	case isContextLengthError(errType, message):
+		apiErr.Err, apiErr.Code = ErrContextLengthExceeded, CodeContextLength
+	case isContentFilterError(errType, message):
+		apiErr.Err, apiErr.Code = ErrContentFiltered, CodeContentFilter
+	case errType == "overloaded_error":
+		apiErr.Err, apiErr.Code = ErrOverloaded, CodeUnavailable
+	case errType == "insufficient_quota":
+		apiErr.Err, apiErr.Code = ErrRateLimited, CodeBudget
+	}
+}
+
+// parseErrorBody extracts the error type and message from the error bodies of
+// OpenAI ({"error":{"code","type","message"}}), Anthropic
+// ({"error":{"type","message"}}) and Gemini ({"error":{"status","message"}})
+func parseErrorBody(body []byte) (errType, message string) {
+	var parsed struct {
+		Error struct {
+			Type    string      `json:"type"`
+			Code    interface{} `json:"code"`
+			Status  string      `json:"status"`
+			Message string      `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", ""
+	}
+
+	errType = parsed.Error.Type
+	if code, ok := parsed.Error.Code.(string); ok && code != "" {
+		// OpenAI's code is more specific than its type
+		errType = code
+	}
+	if errType == "" {
+		errType = parsed.Error.Status
+	}
+	return errType, parsed.Error.Message
+}
+
+// isContextLengthError reports whether an error body describes a prompt that is too long
+func isContextLengthError(errType, message string) bool {
+	if errType == "context_length_exceeded" || errType == "string_above_max_length" {
+		return true
+	}
+	message = strings.ToLower(message)
+	return strings.Contains(message, "prompt is too long") ||
+		strings.Contains(message, "maximum context length") ||
+		strings.Contains(message, "exceeds the maximum number of tokens")
+}
+
+// isContentFilterError reports whether an error body describes a request blocked by a content policy
+func isContentFilterError(errType, message string) bool {
+	if errType == "content_filter" || errType == "content_policy_violation" {
+		return true
+	}
+	message = strings.ToLower(message)
+	return strings.Contains(message, "content management policy")
+}
+
+// parseRetryAfter reads the retry-after-ms or retry-after header, the latter
+// either as seconds or as an HTTP date
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v > 0 {
+			return time.Duration(v * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("retry-after")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// providerDisplayName returns the name of a provider as used in error messages
+func providerDisplayName(provider ProviderType) string {
+	switch provider {
+	case OpenAI:
+		return "OpenAI"
+	case Anthropic:
+		return "Anthropic"
+	case Gemini:
+		return "Gemini"
+	case Vertex:
+		return "Vertex AI"
+	case XAI:
+		return "xAI"
+	case DeepSeek:
+		return "DeepSeek"
+	default:
+		name := string(provider)
+		if name == "" {
+			return "Provider"
+		}
+		return strings.ToUpper(name[:1]) + name[1:]
+	}
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// CostEstimate is the cost of a request estimated locally from token counts
+// and model pricing
+type CostEstimate struct {
+	Provider ProviderType `json:"provider"`
+	Model    string       `json:"model"`
+
+	// Usage counts the prompt tokens and assumes every completion uses all of
+	// max_tokens, so Cost is an upper bound
+	Usage      TokenUsage `json:"usage"`
+	PromptCost float64    `json:"prompt_cost"`
+	Cost       float64    `json:"cost"`
+}
+
+// DryRunPlan describes how a request would be sent
+type DryRunPlan struct {
+	Provider ProviderType            `json:"provider"`
+	Model    string                  `json:"model"`
+	KeyName  string                  `json:"key_name"`
+	Strategy config.RotationStrategy `json:"strategy"`
+	Estimate CostEstimate            `json:"estimate"`
+}
+
+// EstimateCost estimates the cost of sending messages with opts without
+// making a network call
+func (p *UnifiedProvider) EstimateCost(ctx context.Context, messages []Message, opts RequestOptions) (*CostEstimate, error) {
+	opts, err := p.resolveOptions(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p.estimate(messages, opts), nil
+}
+
+// estimate estimates the cost of a request with resolved options
+func (p *UnifiedProvider) estimate(messages []Message, opts RequestOptions) *CostEstimate {
+	var prompt strings.Builder
+	for _, msg := range messages {
+		prompt.WriteString(msg.Content)
+	}
+
+	choices := opts.N
+	if choices < 1 {
+		choices = 1
+	}
+	maxTokens := opts.MaxTokens
+	if opts.MaxCompletionTokens > 0 {
+		maxTokens = opts.MaxCompletionTokens
+	}
+	usage := TokenUsage{
+		PromptTokens:     tokenizer.CountTokens(opts.Model, prompt.String()),
+		CompletionTokens: maxTokens * choices,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	return &CostEstimate{
+		Provider:   opts.Provider,
+		Model:      opts.Model,
+		Usage:      usage,
+		PromptCost: p.CalculateCost(opts.Provider, opts.Model, TokenUsage{PromptTokens: usage.PromptTokens, TotalTokens: usage.PromptTokens}),
+		Cost:       p.CalculateCost(opts.Provider, opts.Model, usage),
+	}
+}
+
+// dryRun resolves the provider, model and key of a request and returns the
+// plan instead of sending it. Selecting the key advances key rotation like a
+// real request would.
+func (p *UnifiedProvider) dryRun(ctx context.Context, messages []Message, opts RequestOptions) (*CompletionResponse, error) {
+	opts, err := p.resolveOptions(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := p.getNextKey(ctx, opts.Provider, keyRestrictions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	key.Release()
+
+	return &CompletionResponse{
+		Model:        opts.Model,
+		ProviderName: string(opts.Provider),
+		Plan: &DryRunPlan{
+			Provider: opts.Provider,
+			Model:    opts.Model,
+			KeyName:  key.KeyName,
+			Strategy: key.Strategy,
+			Estimate: *p.estimate(messages, opts),
+		},
+	}, nil
+}
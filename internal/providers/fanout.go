@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FanOutResult holds the outcome of one request of a fan-out
+type FanOutResult struct {
+	Options  RequestOptions
+	Response *CompletionResponse
+	Err      error
+}
+
+// JudgeFunc picks the index of the best successful result of a fan-out
+type JudgeFunc func(ctx context.Context, messages []Message, results []FanOutResult) (int, error)
+
+// ChatAll sends the same messages to every option set concurrently and returns
+// all results in the order of opts. An error is returned only if every request failed.
+func (p *UnifiedProvider) ChatAll(ctx context.Context, messages []Message, opts []RequestOptions) ([]FanOutResult, error) {
+	results := make([]FanOutResult, len(opts))
+
+	var wg sync.WaitGroup
+	for i, o := range opts {
+		wg.Add(1)
+		go func(i int, o RequestOptions) {
+			defer wg.Done()
+			resp, err := p.Chat(ctx, messages, o)
+			results[i] = FanOutResult{Options: o, Response: resp, Err: err}
+		}(i, o)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err == nil {
+			return results, nil
+		}
+		errs = append(errs, fmt.Errorf("%s/%s: %w", result.Options.Provider, result.Options.Model, result.Err))
+	}
+	return results, fmt.Errorf("all fan-out requests failed: %w", errors.Join(errs...))
+}
+
+// ChatBest fans out like ChatAll and uses judge to pick the best successful
+// response. All results are returned alongside the winner.
+func (p *UnifiedProvider) ChatBest(ctx context.Context, messages []Message, opts []RequestOptions, judge JudgeFunc) (*FanOutResult, []FanOutResult, error) {
+	results, err := p.ChatAll(ctx, messages, opts)
+	if err != nil {
+		return nil, results, err
+	}
+
+	var successful []FanOutResult
+	for _, result := range results {
+		if result.Err == nil {
+			successful = append(successful, result)
+		}
+	}
+	if len(successful) == 1 || judge == nil {
+		return &successful[0], results, nil
+	}
+
+	idx, err := judge(ctx, messages, successful)
+	if err != nil {
+		return nil, results, fmt.Errorf("judge failed: %w", err)
+	}
+	if idx < 0 || idx >= len(successful) {
+		return nil, results, fmt.Errorf("judge returned out of range index %d", idx)
+	}
+
+	return &successful[idx], results, nil
+}
+
+// judgeChoicePattern extracts the first number from a judge model's reply
+var judgeChoicePattern = regexp.MustCompile(`\d+`)
+
+// LLMJudge returns a JudgeFunc that asks a model to pick the best answer
+func LLMJudge(p *UnifiedProvider, opts RequestOptions) JudgeFunc {
+	return func(ctx context.Context, messages []Message, results []FanOutResult) (int, error) {
+		var prompt strings.Builder
+		prompt.WriteString("You are judging candidate answers to the conversation below. ")
+		prompt.WriteString("Reply with only the number of the best answer.\n\nConversation:\n")
+		for _, msg := range messages {
+			fmt.Fprintf(&prompt, "%s: %s\n", msg.Role, msg.Content)
+		}
+		for i, result := range results {
+			fmt.Fprintf(&prompt, "\nAnswer %d:\n%s\n", i+1, result.Response.Content)
+		}
+
+		resp, err := p.Invoke(ctx, prompt.String(), opts)
+		if err != nil {
+			return 0, err
+		}
+
+		choice := judgeChoicePattern.FindString(resp.Content)
+		if choice == "" {
+			return 0, fmt.Errorf("%w: judge reply contains no answer number", ErrResponseFormat)
+		}
+		n, err := strconv.Atoi(choice)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+		}
+		return n - 1, nil
+	}
+}
@@ -0,0 +1,641 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/document"
+	"github.com/gollmkit/gollmkit/internal/messages"
+	"github.com/gollmkit/gollmkit/internal/redact"
+)
+
+// Attachment refers to a file uploaded to a provider, see File.Attachment
+type Attachment = messages.Attachment
+
+// DefaultUploadFilename is the filename of uploads without one
+const DefaultUploadFilename = "upload"
+
+// DefaultFilePurpose is the OpenAI purpose of uploads, for files referenced in chat messages
+const DefaultFilePurpose = "user_data"
+
+// anthropicFilesBeta is the beta feature enabling the Anthropic Files API
+const anthropicFilesBeta = "files-api-2025-04-14"
+
+// filesPageSize is the page size of file listings
+const filesPageSize = 100
+
+// File is a file uploaded to a provider. Files belong to the account or
+// project of the key that uploaded them, which is remembered so later calls
+// and the requests referencing the file use the same key.
+type File struct {
+	ID        string       `json:"id"` // Gemini: the resource name, e.g. files/abc123
+	Provider  ProviderType `json:"provider"`
+	KeyName   string       `json:"key_name"`
+	Filename  string       `json:"filename,omitempty"`
+	MIMEType  string       `json:"mime_type,omitempty"`
+	Bytes     int64        `json:"bytes,omitempty"`
+	Purpose   string       `json:"purpose,omitempty"` // OpenAI only
+	URI       string       `json:"uri,omitempty"`     // Gemini only
+	State     string       `json:"state,omitempty"`   // Gemini only: PROCESSING, ACTIVE or FAILED
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"` // zero if the file doesn't expire
+}
+
+// Attachment returns the reference to the file to put in Message.Attachments
+func (f *File) Attachment() Attachment {
+	return Attachment{
+		FileID:   f.ID,
+		URI:      f.URI,
+		MIMEType: f.MIMEType,
+		Filename: f.Filename,
+		Provider: string(f.Provider),
+		KeyName:  f.KeyName,
+	}
+}
+
+// UploadOptions configures a file upload
+type UploadOptions struct {
+	Provider ProviderType `json:"provider,omitempty"`
+
+	// Filename of the file. Defaults to DefaultUploadFilename.
+	Filename string `json:"filename,omitempty"`
+
+	// MIMEType of the file. Defaults to the type of Filename's extension,
+	// then application/octet-stream.
+	MIMEType string `json:"mime_type,omitempty"`
+
+	// Purpose of the file for OpenAI. Defaults to DefaultFilePurpose.
+	Purpose string `json:"purpose,omitempty"`
+
+	// KeyName uploads with the named key instead of a rotated one
+	KeyName string `json:"key_name,omitempty"`
+}
+
+// UploadFile streams content to the file API of OpenAI, Anthropic or Gemini
+// as a multipart upload, without buffering it, so it can be referenced in
+// messages with File.Attachment. Uploads are limited by
+// global.request_limits.max_attachment_bytes. As the content can't be read
+// twice, a failed upload isn't retried with another key.
+func (p *UnifiedProvider) UploadFile(ctx context.Context, content io.Reader, opts UploadOptions) (*File, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	defer endCall()
+
+	if opts.Provider == "" {
+		opts.Provider = p.defaultProvider()
+	}
+	if opts.Filename == "" {
+		opts.Filename = DefaultUploadFilename
+	}
+	if opts.MIMEType == "" {
+		opts.MIMEType = document.MIMEType(opts.Filename)
+	}
+	if opts.Purpose == "" {
+		opts.Purpose = DefaultFilePurpose
+	}
+
+	var endpoint string
+	var writeForm func(form *multipart.Writer, content io.Reader) error
+	switch opts.Provider {
+	case OpenAI:
+		endpoint = "https://api.openai.com/v1/files"
+		writeForm = func(form *multipart.Writer, content io.Reader) error {
+			return writeOpenAIUpload(form, opts, content)
+		}
+	case Anthropic:
+		endpoint = "https://api.anthropic.com/v1/files"
+		writeForm = func(form *multipart.Writer, content io.Reader) error {
+			return writeFilePart(form, opts, content)
+		}
+	case Gemini:
+		endpoint = "https://generativelanguage.googleapis.com/upload/v1beta/files?uploadType=multipart"
+		writeForm = func(form *multipart.Writer, content io.Reader) error {
+			return writeGeminiUpload(form, opts, content)
+		}
+	default:
+		return nil, fmt.Errorf("file uploads not supported for provider: %s", opts.Provider)
+	}
+
+	key, err := p.fileKey(ctx, opts.Provider, opts.KeyName)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Release()
+
+	file, err := p.upload(ctx, endpoint, key, opts, content, writeForm)
+	if err != nil {
+		err = redact.Error(err, key.Key)
+		p.recordError(ctx, opts.Provider, key.KeyName, err)
+		return nil, err
+	}
+	return file, nil
+}
+
+// upload sends the multipart body written by writeForm to endpoint. The
+// body is streamed through a pipe as the request reads it.
+func (p *UnifiedProvider) upload(ctx context.Context, endpoint string, key *auth.KeySelection, opts UploadOptions, content io.Reader, writeForm func(*multipart.Writer, io.Reader) error) (*File, error) {
+	content = &sizeLimitedReader{r: content, check: func(size int64) error {
+		return p.checkAttachmentSize(opts.Provider, int(size))
+	}}
+
+	body, pipe := io.Pipe()
+	form := multipart.NewWriter(pipe)
+	written := make(chan error, 1)
+	go func() {
+		err := writeForm(form, content)
+		if err == nil {
+			err = form.Close()
+		}
+		pipe.CloseWithError(err)
+		written <- err
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	contentType := form.FormDataContentType()
+	if opts.Provider == Gemini {
+		contentType = "multipart/related; boundary=" + form.Boundary()
+	}
+	req.Header.Set("Content-Type", contentType)
+	p.setFileHeaders(req, key)
+
+	resp, err := p.sendMediaRequest(req, opts.Provider, key)
+	body.Close() // unblocks the writer if the provider answered early
+	if writeErr := <-written; writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		if err == nil {
+			closeBody(resp.Body)
+		}
+		return nil, writeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	file, err := decodeFile(opts.Provider, resp.Body, true)
+	if err != nil {
+		return nil, err
+	}
+	file.KeyName = key.KeyName
+	if file.MIMEType == "" {
+		file.MIMEType = opts.MIMEType
+	}
+	return file, nil
+}
+
+// GetFile returns the current metadata of file, e.g. to wait for a Gemini
+// file to become ACTIVE
+func (p *UnifiedProvider) GetFile(ctx context.Context, file *File) (*File, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	defer endCall()
+
+	endpoint, err := fileURL(file.Provider, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.rotator.GetKeyByName(ctx, string(file.Provider), file.KeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *File
+	err = p.fileRequest(ctx, "GET", endpoint, key, func(body io.Reader) error {
+		updated, err = decodeFile(file.Provider, body, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated.KeyName = key.KeyName
+	return updated, nil
+}
+
+// ListFiles returns the files uploaded to provider with the named key, or
+// with a rotated key if keyName is empty
+func (p *UnifiedProvider) ListFiles(ctx context.Context, provider ProviderType, keyName string) ([]File, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	defer endCall()
+
+	if provider == "" {
+		provider = p.defaultProvider()
+	}
+	if _, err := fileURL(provider, ""); err != nil {
+		return nil, err
+	}
+	key, err := p.fileKey(ctx, provider, keyName)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Release()
+
+	var files []File
+	cursor := ""
+	for {
+		var page struct {
+			Data          []json.RawMessage `json:"data"`  // OpenAI, Anthropic
+			Files         []json.RawMessage `json:"files"` // Gemini
+			HasMore       bool              `json:"has_more"`
+			LastID        string            `json:"last_id"`
+			NextPageToken string            `json:"nextPageToken"`
+		}
+		err := p.fileRequest(ctx, "GET", filesPageURL(provider, cursor), key, func(body io.Reader) error {
+			if err := json.NewDecoder(body).Decode(&page); err != nil {
+				return fmt.Errorf("%w: %v", ErrResponseFormat, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, raw := range append(page.Data, page.Files...) {
+			file, err := decodeFile(provider, bytes.NewReader(raw), false)
+			if err != nil {
+				return nil, err
+			}
+			file.KeyName = key.KeyName
+			files = append(files, *file)
+		}
+
+		cursor = page.NextPageToken
+		if page.HasMore {
+			cursor = page.LastID
+		}
+		if cursor == "" {
+			return files, nil
+		}
+	}
+}
+
+// DeleteFile deletes file from its provider
+func (p *UnifiedProvider) DeleteFile(ctx context.Context, file *File) error {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return err
+	}
+	defer endCall()
+
+	endpoint, err := fileURL(file.Provider, file.ID)
+	if err != nil {
+		return err
+	}
+	key, err := p.rotator.GetKeyByName(ctx, string(file.Provider), file.KeyName)
+	if err != nil {
+		return err
+	}
+	return p.fileRequest(ctx, "DELETE", endpoint, key, nil)
+}
+
+// fileKey returns the named key of provider, or a rotated key if keyName is empty
+func (p *UnifiedProvider) fileKey(ctx context.Context, provider ProviderType, keyName string) (*auth.KeySelection, error) {
+	if keyName != "" {
+		return p.rotator.GetKeyByName(ctx, string(provider), keyName)
+	}
+	return p.getNextKey(ctx, provider)
+}
+
+// fileRequest sends a request to the file API and passes the response body to decode, if not nil
+func (p *UnifiedProvider) fileRequest(ctx context.Context, method, endpoint string, key *auth.KeySelection, decode func(io.Reader) error) error {
+	provider := ProviderType(key.Provider)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	p.setFileHeaders(req, key)
+
+	resp, err := p.sendMediaRequest(req, provider, key)
+	if err == nil {
+		defer closeBody(resp.Body)
+		if decode != nil {
+			err = decode(resp.Body)
+		}
+	}
+	if err != nil {
+		err = redact.Error(err, key.Key)
+		p.recordError(ctx, provider, key.KeyName, err)
+		return err
+	}
+	return nil
+}
+
+// setFileHeaders sets the authentication headers of a file API request
+func (p *UnifiedProvider) setFileHeaders(req *http.Request, key *auth.KeySelection) {
+	switch ProviderType(key.Provider) {
+	case OpenAI:
+		setOpenAIHeaders(req, key)
+	case Anthropic:
+		p.anthropicHeaders(RequestOptions{BetaFeatures: []string{anthropicFilesBeta}})(req, key)
+	case Gemini:
+		setGeminiHeaders(req, key)
+	}
+}
+
+// fileURL returns the URL of the file with id, or of the file collection if id is empty
+func fileURL(provider ProviderType, id string) (string, error) {
+	var base string
+	switch provider {
+	case OpenAI:
+		base = "https://api.openai.com/v1/files"
+	case Anthropic:
+		base = "https://api.anthropic.com/v1/files"
+	case Gemini:
+		// Gemini IDs are resource names including the collection
+		if id != "" {
+			return "https://generativelanguage.googleapis.com/v1beta/" + id, nil
+		}
+		return "https://generativelanguage.googleapis.com/v1beta/files", nil
+	default:
+		return "", fmt.Errorf("file API not supported for provider: %s", provider)
+	}
+	if id != "" {
+		return base + "/" + url.PathEscape(id), nil
+	}
+	return base, nil
+}
+
+// filesPageURL returns the URL of the page of files after cursor
+func filesPageURL(provider ProviderType, cursor string) string {
+	base, _ := fileURL(provider, "")
+	query := url.Values{}
+	switch provider {
+	case OpenAI:
+		query.Set("limit", strconv.Itoa(filesPageSize))
+		if cursor != "" {
+			query.Set("after", cursor)
+		}
+	case Anthropic:
+		query.Set("limit", strconv.Itoa(filesPageSize))
+		if cursor != "" {
+			query.Set("after_id", cursor)
+		}
+	case Gemini:
+		query.Set("pageSize", strconv.Itoa(filesPageSize))
+		if cursor != "" {
+			query.Set("pageToken", cursor)
+		}
+	}
+	return base + "?" + query.Encode()
+}
+
+// decodeFile decodes a file object of the provider's file API. Gemini nests
+// the file of upload responses in a file field.
+func decodeFile(provider ProviderType, body io.Reader, uploaded bool) (*File, error) {
+	var result struct {
+		// OpenAI and Anthropic
+		ID        string          `json:"id"`
+		Filename  string          `json:"filename"`
+		MIMEType  string          `json:"mime_type"`
+		Bytes     int64           `json:"bytes"`
+		SizeBytes int64           `json:"size_bytes"`
+		Purpose   string          `json:"purpose"`
+		CreatedAt json.RawMessage `json:"created_at"` // unix seconds for OpenAI, RFC 3339 for Anthropic
+		ExpiresAt int64           `json:"expires_at"`
+
+		// Gemini
+		geminiFile
+		File *geminiFile `json:"file"`
+	}
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+
+	if provider == Gemini {
+		gemini := &result.geminiFile
+		if uploaded {
+			if result.File == nil {
+				return nil, fmt.Errorf("%w: missing file in response", ErrResponseFormat)
+			}
+			gemini = result.File
+		}
+		return gemini.file(), nil
+	}
+
+	if result.ID == "" {
+		return nil, fmt.Errorf("%w: missing file id in response", ErrResponseFormat)
+	}
+	file := &File{
+		ID:       result.ID,
+		Provider: provider,
+		Filename: result.Filename,
+		MIMEType: result.MIMEType,
+		Bytes:    result.Bytes,
+		Purpose:  result.Purpose,
+	}
+	if provider == Anthropic {
+		file.Bytes = result.SizeBytes
+	}
+	var created interface{}
+	if json.Unmarshal(result.CreatedAt, &created) == nil {
+		switch created := created.(type) {
+		case float64:
+			file.CreatedAt = time.Unix(int64(created), 0)
+		case string:
+			file.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		}
+	}
+	if result.ExpiresAt > 0 {
+		file.ExpiresAt = time.Unix(result.ExpiresAt, 0)
+	}
+	return file, nil
+}
+
+// geminiFile is a file resource of the Gemini API
+type geminiFile struct {
+	Name           string    `json:"name"`
+	DisplayName    string    `json:"displayName"`
+	MIMEType       string    `json:"mimeType"`
+	SizeBytes      string    `json:"sizeBytes"` // int64 encoded as a string
+	URI            string    `json:"uri"`
+	State          string    `json:"state"`
+	CreateTime     time.Time `json:"createTime"`
+	ExpirationTime time.Time `json:"expirationTime"`
+}
+
+// file converts a Gemini file resource into a File
+func (g *geminiFile) file() *File {
+	size, _ := strconv.ParseInt(g.SizeBytes, 10, 64)
+	return &File{
+		ID:        g.Name,
+		Provider:  Gemini,
+		Filename:  g.DisplayName,
+		MIMEType:  g.MIMEType,
+		Bytes:     size,
+		URI:       g.URI,
+		State:     g.State,
+		CreatedAt: g.CreateTime,
+		ExpiresAt: g.ExpirationTime,
+	}
+}
+
+// writeFilePart writes content as the file field of a multipart form, with
+// its MIME type so the provider can tell documents from images
+func writeFilePart(form *multipart.Writer, opts UploadOptions, content io.Reader) error {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     "file",
+		"filename": filepath.Base(opts.Filename),
+	}))
+	header.Set("Content-Type", opts.MIMEType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, content)
+	return err
+}
+
+// writeOpenAIUpload writes an OpenAI multipart upload: the purpose of the
+// file followed by its content
+func writeOpenAIUpload(form *multipart.Writer, opts UploadOptions, content io.Reader) error {
+	if err := form.WriteField("purpose", opts.Purpose); err != nil {
+		return err
+	}
+	return writeFilePart(form, opts, content)
+}
+
+// writeGeminiUpload writes a Gemini multipart upload: the JSON metadata of
+// the file followed by its content
+func writeGeminiUpload(form *multipart.Writer, opts UploadOptions, content io.Reader) error {
+	metadata, err := json.Marshal(map[string]interface{}{
+		"file": map[string]interface{}{"displayName": filepath.Base(opts.Filename)},
+	})
+	if err != nil {
+		return err
+	}
+	part, err := form.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(metadata); err != nil {
+		return err
+	}
+
+	part, err = form.CreatePart(textproto.MIMEHeader{"Content-Type": {opts.MIMEType}})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, content)
+	return err
+}
+
+// sizeLimitedReader fails once the bytes read exceed what check accepts
+type sizeLimitedReader struct {
+	r     io.Reader
+	read  int64
+	check func(size int64) error
+}
+
+func (r *sizeLimitedReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.read += int64(n)
+	if checkErr := r.check(r.read); checkErr != nil {
+		return n, checkErr
+	}
+	return n, err
+}
+
+// checkAttachments validates the attachments of messages for the provider of
+// opts and pins the request to the key that uploaded the referenced files
+func checkAttachments(messages []Message, opts RequestOptions) (RequestOptions, error) {
+	keyName := opts.KeyName
+	found := false
+	for _, msg := range messages {
+		for _, attachment := range msg.Attachments {
+			if err := checkAttachment(msg, attachment, opts.Provider); err != nil {
+				return opts, err
+			}
+			if attachment.Inline() {
+				continue
+			}
+			found = true
+			if attachment.KeyName == "" {
+				continue
+			}
+			if keyName != "" && keyName != attachment.KeyName {
+				return opts, attachmentError(opts.Provider, "attachments uploaded with keys %s and %s can't be sent in one request", keyName, attachment.KeyName)
+			}
+			keyName = attachment.KeyName
+		}
+	}
+	if !found {
+		return opts, nil
+	}
+
+	opts.KeyName = keyName
+	if opts.Provider == Anthropic && !containsString(opts.BetaFeatures, anthropicFilesBeta) {
+		opts.BetaFeatures = append(append([]string(nil), opts.BetaFeatures...), anthropicFilesBeta)
+	}
+	return opts, nil
+}
+
+// checkAttachment validates an attachment of msg sent to provider
+func checkAttachment(msg Message, attachment Attachment, provider ProviderType) error {
+	if msg.Role != "user" {
+		return attachmentError(provider, "only user messages can have attachments, not %s messages", msg.Role)
+	}
+	if attachment.Inline() {
+		return nil
+	}
+	if attachment.Provider != "" && ProviderType(attachment.Provider) != provider {
+		return attachmentError(provider, "file %s was uploaded to %s, not %s", attachment.FileID+attachment.URI,
+			providerDisplayName(ProviderType(attachment.Provider)), providerDisplayName(provider))
+	}
+
+	switch provider {
+	case OpenAI, Anthropic:
+		if attachment.FileID == "" {
+			return attachmentError(provider, "attachments need a file ID")
+		}
+	case Gemini, Vertex:
+		if attachment.URI == "" {
+			return attachmentError(provider, "attachments need a file URI")
+		}
+	default:
+		return attachmentError(provider, "%s does not support file attachments", providerDisplayName(provider))
+	}
+	return nil
+}
+
+// attachmentError reports an invalid attachment
+func attachmentError(provider ProviderType, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:     CodeBadRequest,
+		Provider: provider,
+		Message:  fmt.Sprintf(format, args...),
+		Err:      ErrBadRequest,
+	}
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
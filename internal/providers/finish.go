@@ -0,0 +1,68 @@
+package providers
+
+import "strings"
+
+// Normalized finish reasons reported in CompletionResponse.FinishReason and
+// StreamChunk.FinishReason, independent of the provider's own vocabulary
+const (
+	// FinishReasonStop means the model ended its turn naturally
+	FinishReasonStop = "stop"
+	// FinishReasonLength means generation hit the max_tokens limit
+	FinishReasonLength = "length"
+	// FinishReasonStopSequence means one of RequestOptions.Stop was generated.
+	// OpenAI doesn't distinguish stop sequences and reports FinishReasonStop.
+	FinishReasonStopSequence = "stop_sequence"
+	// FinishReasonContentFilter means the output was withheld or cut by a safety filter
+	FinishReasonContentFilter = "content_filter"
+	// FinishReasonToolCalls means the model stopped to call a tool
+	FinishReasonToolCalls = "tool_calls"
+	// FinishReasonOther is any provider reason without a normalized equivalent
+	FinishReasonOther = "other"
+)
+
+// normalizeFinishReason maps a provider's stop reason to a normalized finish
+// reason. An empty reason stays empty.
+func normalizeFinishReason(provider ProviderType, reason string) string {
+	if reason == "" {
+		return ""
+	}
+
+	switch provider {
+	case OpenAI, XAI, DeepSeek, Mock:
+		switch reason {
+		case "stop":
+			return FinishReasonStop
+		case "length":
+			return FinishReasonLength
+		case "content_filter":
+			return FinishReasonContentFilter
+		case "tool_calls", "function_call":
+			return FinishReasonToolCalls
+		}
+
+	case Anthropic:
+		switch reason {
+		case "end_turn":
+			return FinishReasonStop
+		case "max_tokens":
+			return FinishReasonLength
+		case "stop_sequence":
+			return FinishReasonStopSequence
+		case "tool_use":
+			return FinishReasonToolCalls
+		case "refusal":
+			return FinishReasonContentFilter
+		}
+
+	case Gemini, Vertex:
+		switch strings.ToUpper(reason) {
+		case "STOP":
+			return FinishReasonStop
+		case "MAX_TOKENS":
+			return FinishReasonLength
+		case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+			return FinishReasonContentFilter
+		}
+	}
+	return FinishReasonOther
+}
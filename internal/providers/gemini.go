@@ -0,0 +1,230 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// GeminiProvider sends requests to Gemini only. It implements LLMProvider
+// with the same key rotation, usage tracking and middleware as
+// UnifiedProvider, without fallback to other providers.
+type GeminiProvider struct {
+	vendorProvider
+}
+
+// NewGeminiProvider creates a provider for the gemini configuration of cfg
+func NewGeminiProvider(cfg *config.Config, rotator *auth.KeyRotator, validator *auth.KeyValidator) *GeminiProvider {
+	p := newVendorProvider(Gemini, cfg, rotator, validator)
+	p.encode = geminiRequestBody
+	p.decode = func(result map[string]interface{}, model string) (*CompletionResponse, error) {
+		return parseGeminiResponse(result, Gemini, model)
+	}
+	return &GeminiProvider{p}
+}
+
+// geminiRequestBody builds the generateContent request body
+func geminiRequestBody(messages []Message, opts RequestOptions) map[string]interface{} {
+	var combinedContent string
+	var parts []map[string]interface{}
+	for _, msg := range messages {
+		role := msg.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		combinedContent += fmt.Sprintf("%s: %s\n", role, msg.Content)
+
+		// Attached files precede the conversation text
+		for _, attachment := range msg.Attachments {
+			if attachment.Inline() {
+				parts = append(parts, map[string]interface{}{"inlineData": map[string]interface{}{
+					"mimeType": attachment.MIMEType,
+					"data":     base64.StdEncoding.EncodeToString(attachment.Data),
+				}})
+				continue
+			}
+			fileData := map[string]interface{}{"fileUri": attachment.URI}
+			if attachment.MIMEType != "" {
+				fileData["mimeType"] = attachment.MIMEType
+			}
+			parts = append(parts, map[string]interface{}{"fileData": fileData})
+		}
+	}
+	parts = append(parts, map[string]interface{}{"text": combinedContent})
+
+	generationConfig := map[string]interface{}{
+		"temperature": opts.Temperature,
+		"topP":        opts.TopP,
+	}
+	if opts.MaxTokens > 0 {
+		generationConfig["maxOutputTokens"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		generationConfig["stopSequences"] = opts.Stop
+	}
+	if opts.N > 1 {
+		generationConfig["candidateCount"] = opts.N
+	}
+
+	body := map[string]interface{}{
+		"contents": []map[string]interface{}{{
+			"role":  "user",
+			"parts": parts,
+		}},
+		"generationConfig": generationConfig,
+	}
+	if len(opts.SafetySettings) > 0 {
+		body["safetySettings"] = geminiSafetySettings(opts)
+	}
+	return applyExtra(body, opts)
+}
+
+// setGeminiHeaders sets the authentication header for Gemini requests. The key
+// isn't passed in the URL, where it would end up in errors and logs.
+func setGeminiHeaders(req *http.Request, key *auth.KeySelection) {
+	req.Header.Set("x-goog-api-key", key.Key)
+}
+
+func (p *UnifiedProvider) callGemini(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	reqBody := geminiRequestBody(messages, opts)
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:generateContent",
+		url.PathEscape(opts.Model))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setGeminiHeaders(req, key)
+
+	req, trace := traceRequest(req, opts)
+	start := time.Now()
+	resp, err := p.httpClient(Gemini).Do(req)
+	if err != nil {
+		p.recordError(ctx, Gemini, key.KeyName, err)
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		err = parseAPIError(Gemini, resp)
+		p.recordError(ctx, Gemini, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(Gemini, opts.Model, start)
+
+	var result map[string]interface{}
+	info, err := decodeResponse(resp, trace, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := parseGeminiResponse(result, Gemini, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recordUsage(ctx, Gemini, key.KeyName, opts.Model, completion.Usage)
+
+	completion.ResponseInfo = info
+	completion.ProviderRequestID = providerRequestID(resp.Header)
+	return completion, nil
+}
+
+// parseGeminiResponse converts a generateContent response body, as returned by
+// the Generative Language API and Vertex AI, into a CompletionResponse
+func parseGeminiResponse(result map[string]interface{}, provider ProviderType, model string) (*CompletionResponse, error) {
+	if block := geminiPromptBlock(result, provider); block != nil {
+		return nil, safetyError(block)
+	}
+
+	candidates, _ := result["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		return nil, emptyResponseError(provider)
+	}
+
+	// Candidates withheld by safety filters, or stopped before generating
+	// anything, have no content. They are returned as empty choices; if every
+	// candidate was withheld, the safety block is returned instead.
+	var blockReason string
+	var ratings []SafetyRating
+	var citations []Citation
+	choices := make([]Choice, 0, len(candidates))
+	for i, c := range candidates {
+		candidate, _ := c.(map[string]interface{})
+		finishReason, _ := candidate["finishReason"].(string)
+		content, _ := candidate["content"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		if len(parts) == 0 {
+			normalized := normalizeFinishReason(provider, finishReason)
+			if normalized == "" {
+				return nil, fmt.Errorf("%w: missing parts in response", ErrResponseFormat)
+			}
+			if normalized == FinishReasonContentFilter && blockReason == "" {
+				blockReason = finishReason
+				ratings = parseSafetyRatings(candidate["safetyRatings"])
+			}
+			choices = append(choices, Choice{Index: i, FinishReason: normalized})
+			continue
+		}
+
+		text, ok := parts[0].(map[string]interface{})["text"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid text format in response", ErrResponseFormat)
+		}
+		if len(choices) == 0 {
+			citations = parseGeminiCitations(candidate, text)
+		}
+
+		choices = append(choices, Choice{
+			Index:        i,
+			Content:      text,
+			FinishReason: normalizeFinishReason(provider, finishReason),
+		})
+	}
+
+	if block := filteredChoices(provider, choices, blockReason, ratings); block != nil {
+		return nil, safetyError(block)
+	}
+
+	usageMetadata, ok := result["usageMetadata"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing usage in response", ErrResponseFormat)
+	}
+
+	promptTokens, _ := usageMetadata["promptTokenCount"].(float64)
+	candidatesTokens, _ := usageMetadata["candidatesTokenCount"].(float64)
+	totalTokens, _ := usageMetadata["totalTokenCount"].(float64)
+	cachedTokens, _ := usageMetadata["cachedContentTokenCount"].(float64)
+
+	return &CompletionResponse{
+		Content: choices[0].Content,
+		Model:   model,
+		Usage: TokenUsage{
+			PromptTokens:     int(promptTokens),
+			CompletionTokens: int(candidatesTokens),
+			TotalTokens:      int(totalTokens),
+			CachedTokens:     int(cachedTokens),
+		},
+		ProviderName: string(provider),
+		FinishReason: choices[0].FinishReason,
+		Choices:      choices,
+		Citations:    citations,
+		Metadata:     result,
+	}, nil
+}
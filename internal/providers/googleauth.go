@@ -0,0 +1,286 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Google OAuth endpoints and scope used for Vertex AI
+const (
+	googleTokenURL      = "https://oauth2.googleapis.com/token"
+	googleCloudScope    = "https://www.googleapis.com/auth/cloud-platform"
+	googleMetadataToken = "/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// googleADC is the key value selecting Application Default Credentials
+const googleADC = "adc"
+
+// googleTokenEarlyExpiry is how long before their expiry access tokens are refreshed
+const googleTokenEarlyExpiry = time.Minute
+
+// googleCredentials is the subset of a Google credentials file used to obtain
+// access tokens: either a service account key or gcloud user credentials
+type googleCredentials struct {
+	Type         string `json:"type"` // service_account or authorized_user
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleToken is a cached OAuth access token
+type googleToken struct {
+	value  string
+	expiry time.Time
+}
+
+// googleTokenCache caches access tokens by the credentials they were issued for
+type googleTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]googleToken
+}
+
+// newGoogleTokenCache creates an empty token cache
+func newGoogleTokenCache() *googleTokenCache {
+	return &googleTokenCache{tokens: make(map[string]googleToken)}
+}
+
+// invalidate drops the cached token of credentials, e.g. after it was rejected
+func (c *googleTokenCache) invalidate(credentials string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, credentials)
+}
+
+// googleAccessToken returns an OAuth access token for the credentials held by
+// a key: a path to a credentials file, inline credentials JSON, or "adc" for
+// Application Default Credentials. Tokens are cached until shortly before
+// they expire.
+func (p *UnifiedProvider) googleAccessToken(ctx context.Context, credentials string) (string, error) {
+	p.tokens.mu.Lock()
+	cached, exists := p.tokens.tokens[credentials]
+	p.tokens.mu.Unlock()
+	if exists && time.Now().Before(cached.expiry.Add(-googleTokenEarlyExpiry)) {
+		return cached.value, nil
+	}
+
+	token, err := p.fetchGoogleToken(ctx, credentials)
+	if err != nil {
+		return "", err
+	}
+
+	p.tokens.mu.Lock()
+	p.tokens.tokens[credentials] = token
+	p.tokens.mu.Unlock()
+	return token.value, nil
+}
+
+// fetchGoogleToken obtains a new access token for credentials
+func (p *UnifiedProvider) fetchGoogleToken(ctx context.Context, credentials string) (googleToken, error) {
+	creds, err := loadGoogleCredentials(credentials)
+	if err != nil {
+		return googleToken{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	var req *http.Request
+	switch {
+	case creds == nil:
+		// No credentials file: ask the metadata server of the GCE/GKE/Cloud Run instance
+		req, err = metadataTokenRequest(ctx)
+	case creds.Type == "service_account":
+		req, err = serviceAccountTokenRequest(ctx, creds, time.Now())
+	case creds.Type == "authorized_user":
+		req, err = formTokenRequest(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {creds.ClientID},
+			"client_secret": {creds.ClientSecret},
+			"refresh_token": {creds.RefreshToken},
+		})
+	default:
+		return googleToken{}, fmt.Errorf("%w: unsupported Google credentials type %q", ErrInvalidConfig, creds.Type)
+	}
+	if err != nil {
+		return googleToken{}, err
+	}
+
+	resp, err := p.httpClient(Vertex).Do(req)
+	if err != nil {
+		return googleToken{}, fmt.Errorf("Google token request failed: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return googleToken{}, &Error{
+			Code:       CodeAuth,
+			Provider:   Vertex,
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("Google token request failed: %d %s", resp.StatusCode, strings.TrimSpace(string(body))),
+			Err:        ErrAuth,
+		}
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.AccessToken == "" {
+		return googleToken{}, fmt.Errorf("%w: invalid Google token response", ErrResponseFormat)
+	}
+
+	return googleToken{
+		value:  result.AccessToken,
+		expiry: time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// loadGoogleCredentials parses the credentials held by a key. For "adc" it
+// follows the Application Default Credentials lookup and returns nil when only
+// the metadata server is left.
+func loadGoogleCredentials(credentials string) (*googleCredentials, error) {
+	credentials = strings.TrimSpace(credentials)
+
+	var data []byte
+	switch {
+	case strings.HasPrefix(credentials, "{"):
+		data = []byte(credentials)
+
+	case strings.EqualFold(credentials, googleADC):
+		path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		if path == "" {
+			path = gcloudCredentialsPath()
+			if _, err := os.Stat(path); err != nil {
+				return nil, nil
+			}
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read Google credentials: %w", err)
+		}
+
+	default:
+		var err error
+		if data, err = os.ReadFile(credentials); err != nil {
+			return nil, fmt.Errorf("failed to read Google credentials: %w", err)
+		}
+	}
+
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid Google credentials: %w", err)
+	}
+	return &creds, nil
+}
+
+// gcloudCredentialsPath returns the well-known location of the credentials
+// written by "gcloud auth application-default login"
+func gcloudCredentialsPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// serviceAccountTokenRequest builds the JWT bearer grant request exchanging a
+// service account key for an access token
+func serviceAccountTokenRequest(ctx context.Context, creds *googleCredentials, now time.Time) (*http.Request, error) {
+	key, err := parseRSAPrivateKey(creds.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: service account %s: %v", ErrInvalidConfig, creds.ClientEmail, err)
+	}
+
+	tokenURL := creds.TokenURI
+	if tokenURL == "" {
+		tokenURL = googleTokenURL
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": googleCloudScope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign service account assertion: %w", err)
+	}
+
+	return formTokenRequest(ctx, tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + encoding.EncodeToString(signature)},
+	})
+}
+
+// parseRSAPrivateKey parses the PEM-encoded private key of a service account
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// formTokenRequest builds a form-encoded POST to an OAuth token endpoint
+func formTokenRequest(ctx context.Context, tokenURL string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// metadataTokenRequest builds the request for the default service account
+// token of the instance metadata server
+func metadataTokenRequest(ctx context.Context) (*http.Request, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+host+googleMetadataToken, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return req, nil
+}
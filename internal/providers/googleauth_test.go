@@ -0,0 +1,209 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// serviceAccountKey returns a service account credentials file with a new
+// RSA key, and the key
+func serviceAccountKey(t *testing.T, tokenURI string) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "gollmkit@project.iam.gserviceaccount.com",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"private_key_id": "key-1",
+		"token_uri":      tokenURI,
+	})
+	return string(creds), key
+}
+
+// TestServiceAccountToken exchanges a service account JWT signed with the
+// key of the credentials for access tokens, which are cached until shortly
+// before they expire
+func TestServiceAccountToken(t *testing.T) {
+	const tokenURI = "https://oauth2.example.com/token"
+	var (
+		mu      sync.Mutex
+		issued  int
+		expires = []int{30, 3600} // the first token expires within googleTokenEarlyExpiry
+		public  *rsa.PublicKey
+	)
+	provider := newTestProvider(t, config.HTTPConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != "POST" || r.URL.Path != "/token" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if grant := r.PostForm.Get("grant_type"); grant != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type %q", grant)
+		}
+		checkAssertion(t, r.PostForm.Get("assertion"), public, tokenURI)
+
+		expiresIn := expires[len(expires)-1]
+		if issued < len(expires) {
+			expiresIn = expires[issued]
+		}
+		issued++
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d,"token_type":"Bearer"}`, issued, expiresIn)
+	})
+	creds, key := serviceAccountKey(t, tokenURI)
+	public = &key.PublicKey
+	ctx := context.Background()
+
+	for i, want := range []string{"token-1", "token-2", "token-2"} {
+		token, err := provider.googleAccessToken(ctx, creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != want {
+			t.Errorf("call %d: token %q, want %q", i+1, token, want)
+		}
+	}
+
+	provider.tokens.invalidate(creds)
+	if token, err := provider.googleAccessToken(ctx, creds); err != nil || token != "token-3" {
+		t.Errorf("after invalidate: token %q (%v), want token-3", token, err)
+	}
+}
+
+// checkAssertion verifies the signature and claims of a service account JWT
+func checkAssertion(t *testing.T, assertion string, public *rsa.PublicKey, audience string) {
+	t.Helper()
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("assertion has %d parts, want 3", len(parts))
+	}
+	encoding := base64.RawURLEncoding
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+		Kid string `json:"kid"`
+	}
+	if raw, err := encoding.DecodeString(parts[0]); err != nil || json.Unmarshal(raw, &header) != nil {
+		t.Fatalf("invalid header %q", parts[0])
+	}
+	if header.Alg != "RS256" || header.Typ != "JWT" || header.Kid != "key-1" {
+		t.Errorf("header %+v", header)
+	}
+
+	var claims struct {
+		Iss   string `json:"iss"`
+		Scope string `json:"scope"`
+		Aud   string `json:"aud"`
+		Iat   int64  `json:"iat"`
+		Exp   int64  `json:"exp"`
+	}
+	if raw, err := encoding.DecodeString(parts[1]); err != nil || json.Unmarshal(raw, &claims) != nil {
+		t.Fatalf("invalid claims %q", parts[1])
+	}
+	if claims.Iss != "gollmkit@project.iam.gserviceaccount.com" {
+		t.Errorf("iss %q", claims.Iss)
+	}
+	if claims.Scope != googleCloudScope {
+		t.Errorf("scope %q", claims.Scope)
+	}
+	if claims.Aud != audience {
+		t.Errorf("aud %q, want %q", claims.Aud, audience)
+	}
+	if now := time.Now().Unix(); claims.Iat < now-60 || claims.Iat > now+60 {
+		t.Errorf("iat %d, now is %d", claims.Iat, now)
+	}
+	if claims.Exp != claims.Iat+3600 {
+		t.Errorf("exp %d, want iat+3600", claims.Exp)
+	}
+}
+
+// TestServiceAccountTokenRejected returns an authentication error when the
+// token endpoint rejects the assertion
+func TestServiceAccountTokenRejected(t *testing.T) {
+	provider := newTestProvider(t, config.HTTPConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`)
+	})
+	creds, _ := serviceAccountKey(t, "https://oauth2.example.com/token")
+
+	_, err := provider.googleAccessToken(context.Background(), creds)
+	if code := CodeOf(err); code != CodeAuth {
+		t.Errorf("code %s, want %s (%v)", code, CodeAuth, err)
+	}
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPKCS8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+
+	tests := []struct {
+		name    string
+		pem     string
+		wantErr bool
+	}{
+		{"pkcs8", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})), false},
+		{"pkcs1", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})), false},
+		{"not pem", "not a key", true},
+		{"not rsa", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8})), true},
+		{"garbage", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")})), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseRSAPrivateKey(tt.pem)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !parsed.Equal(key) {
+				t.Error("parsed a different key")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// ErrHealthChecksRunning is returned by StartHealthChecks if health checks already run
+var ErrHealthChecksRunning = errors.New("health checks already running")
+
+// healthChecks is a running health checker
+type healthChecks struct {
+	checker *auth.HealthChecker
+	done    chan struct{}
+}
+
+// StartHealthChecks validates the keys of every provider with
+// rotation.health_check enabled every global.health_check_interval, marking
+// failing keys unhealthy so rotation skips them. The keys are read from the
+// current configuration before every check, so reloads take effect. Checks
+// run in the background until ctx is done or StopHealthChecks is called.
+func (p *BaseProvider) StartHealthChecks(ctx context.Context) error {
+	interval, err := p.getConfig().Global.GetHealthCheckInterval()
+	if err != nil {
+		return fmt.Errorf("%w: invalid health check interval: %v", ErrInvalidConfig, err)
+	}
+
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+	if p.health != nil {
+		select {
+		case <-p.health.done:
+			// Stopped because its context was done
+		default:
+			return ErrHealthChecksRunning
+		}
+	}
+
+	// An injected validator keeps its own settings; otherwise global.validation applies
+	validator := p.validator
+	if validator == nil {
+		if validator, err = auth.NewKeyValidatorFromConfig(p.getConfig().Global.Validation); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		validator.SetLogger(p.logger)
+
+		// Probes go through the proxy and TLS settings and carry the User-Agent of global.http
+		client := newConfiguredClient(p.getConfig(), p.logger)
+		client.Timeout = auth.ValidationTimeout
+		validator.SetHTTPClient(client)
+	}
+
+	checker := auth.NewHealthChecker(p.rotator.KeyStore(), interval)
+	checker.SetValidator(validator)
+	checker.SetLogger(p.logger)
+	health := &healthChecks{checker: checker, done: make(chan struct{})}
+	go func() {
+		defer close(health.done)
+		checker.Run(ctx, p.rotator.HealthCheckKeys)
+	}()
+	p.health = health
+	return nil
+}
+
+// StopHealthChecks stops health checks started with StartHealthChecks and
+// waits until a check in progress finished or ctx is done
+func (p *BaseProvider) StopHealthChecks(ctx context.Context) error {
+	p.healthMu.Lock()
+	health := p.health
+	p.health = nil
+	p.healthMu.Unlock()
+	if health == nil {
+		return nil
+	}
+
+	health.checker.Stop()
+	select {
+	case <-health.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+	"github.com/gollmkit/gollmkit/internal/useragent"
+)
+
+// NewHTTPClient creates the HTTP client used to call providers from the
+// timeout, proxy, TLS and connection pool settings. Its requests carry the
+// User-Agent and headers of the settings. Connections are kept alive and use
+// HTTP/2 where the provider supports it.
+//
+// The timeout only bounds requests whose context has no deadline. It isn't
+// set as http.Client.Timeout, which would cut off streams and requests given
+// a longer timeout of their own.
+func NewHTTPClient(cfg config.HTTPConfig) (*http.Client, error) {
+	timeout, err := cfg.GetTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid http timeout: %w", err)
+	}
+
+	idleConnTimeout, err := cfg.GetIdleConnTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid http idle connection timeout: %w", err)
+	}
+
+	// Keep enough idle connections for concurrent requests to reuse them;
+	// the default of 2 per host makes most requests pay a new TLS handshake
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.GetMaxIdleConnsPerHost()
+	transport.MaxIdleConns = 0 // bounded per host
+	transport.MaxConnsPerHost = cfg.GetMaxConnsPerHost()
+	transport.IdleConnTimeout = idleConnTimeout
+	transport.ForceAttemptHTTP2 = !cfg.DisableHTTP2
+	if cfg.DisableHTTP2 {
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// Resume TLS sessions when new connections are opened
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+	if cfg.CAFile != "" || cfg.CertFile != "" {
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if cfg.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	client := &http.Client{Transport: &deadlineTransport{base: transport, timeout: timeout}}
+	return useragent.Wrap(client, cfg.UserAgent, cfg.Headers), nil
+}
+
+// newConfiguredClient creates the HTTP client for cfg, falling back to a
+// default client with a timeout if the settings can't be applied
+func newConfiguredClient(cfg *config.Config, logger logging.Logger) *http.Client {
+	client, err := NewHTTPClient(cfg.Global.HTTP)
+	if err != nil {
+		logging.OrDefault(logger).Warn("gollmkit: invalid http client settings, using defaults", "error", err)
+		client, _ = NewHTTPClient(config.HTTPConfig{})
+	}
+	return client
+}
+
+// SetHTTPClient replaces the HTTP clients used to call providers with client,
+// shared by all providers. An injected client is kept when the configuration
+// is reloaded. Requests still carry the User-Agent and headers of
+// global.http and are compressed as configured; client itself isn't modified.
+func (p *BaseProvider) SetHTTPClient(client *http.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeIdleConnections()
+	p.client = useragent.Wrap(client, p.config.Global.HTTP.UserAgent, p.config.Global.HTTP.Headers)
+}
+
+// httpClient returns the HTTP client of provider. Every provider has its own
+// client and connection pool, created on first use, unless one was injected
+// with SetHTTPClient.
+func (p *BaseProvider) httpClient(provider ProviderType) *http.Client {
+	p.mu.RLock()
+	client, exists := p.clients[provider]
+	p.mu.RUnlock()
+	if exists {
+		return client
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, exists = p.clients[provider]; exists {
+		return client
+	}
+	base := p.client
+	if base == nil {
+		base = newConfiguredClient(p.config, p.logger)
+	}
+	client = withCompression(base, p.config, provider)
+	p.clients[provider] = client
+	return client
+}
+
+// closeIdleConnections drops the per-provider clients and closes the idle
+// connections of those gollmkit created; connections of requests in flight
+// close when they finish. An injected client's connections are left alone.
+// The caller must hold p.mu.
+func (p *BaseProvider) closeIdleConnections() {
+	if p.client == nil {
+		for _, client := range p.clients {
+			client.CloseIdleConnections()
+		}
+	}
+	p.clients = make(map[ProviderType]*http.Client)
+}
+
+// deadlineTransport bounds requests without a context deadline by timeout,
+// including reading the response body, like http.Client.Timeout does for all
+// requests. Requests with a deadline, such as provider calls carrying their
+// request timeout, are left to it.
+type deadlineTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Deadline(); ok || t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the base transport
+func (t *deadlineTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// cancelOnClose releases the deadline of a response when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels its deadline
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// maxDrainBytes is how much of an unread response body is discarded so its
+// connection can be reused; connections with more left are closed instead
+const maxDrainBytes = 64 << 10
+
+// closeBody discards the rest of a response body and closes it, which returns
+// the connection to the pool rather than tearing it down
+func closeBody(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// TestStreamOutlastsClientTimeout streams for longer than global.http.timeout
+// within the request timeout
+func TestStreamOutlastsClientTimeout(t *testing.T) {
+	words := []string{"one", " two", " three", " four"}
+	provider := newTestProvider(t, config.HTTPConfig{Timeout: "100ms"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range words {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", word)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	stream, err := provider.InvokeStream(context.Background(), "Count to four", RequestOptions{
+		Provider: OpenAI, Model: "gpt-4o", Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	var finish string
+	for chunk := range stream {
+		if chunk.Error != nil {
+			t.Fatalf("stream failed after %q: %v", text.String(), chunk.Error)
+		}
+		text.WriteString(chunk.Delta)
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+	}
+	if want := strings.Join(words, ""); text.String() != want {
+		t.Errorf("streamed %q, want %q", text.String(), want)
+	}
+	if finish != "stop" {
+		t.Errorf("finish reason %q, want stop", finish)
+	}
+}
+
+// TestClientTimeoutWithoutDeadline checks that global.http.timeout still
+// bounds requests made without a deadline, including the response body
+func TestClientTimeoutWithoutDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, "late")
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(config.HTTPConfig{Timeout: "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("reading the body returned %v, want a deadline error", err)
+	}
+}
+
+// TestRequestTimeoutLongerThanClientTimeout waits for a response past
+// global.http.timeout because the request timeout allows it, and times out
+// once the request timeout passes
+func TestRequestTimeoutLongerThanClientTimeout(t *testing.T) {
+	provider := newTestProvider(t, config.HTTPConfig{Timeout: "50ms"}, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"done"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+	})
+	ctx := context.Background()
+
+	resp, err := provider.Invoke(ctx, "Take your time", RequestOptions{Provider: OpenAI, Model: "gpt-4o", Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "done" {
+		t.Errorf("content %q, want done", resp.Content)
+	}
+
+	_, err = provider.Invoke(ctx, "Take your time", RequestOptions{Provider: OpenAI, Model: "gpt-4o", Timeout: 100 * time.Millisecond})
+	if code := CodeOf(err); code != CodeTimeout {
+		t.Errorf("code %s, want %s (%v)", code, CodeTimeout, err)
+	}
+}
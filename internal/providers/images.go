@@ -0,0 +1,319 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// Image response formats
+const (
+	ImageFormatURL    = "url"
+	ImageFormatBase64 = "b64_json"
+)
+
+// ImageOptions contains options for image generation requests
+type ImageOptions struct {
+	Provider ProviderType `json:"provider,omitempty"`
+	Model    string       `json:"model,omitempty"`
+
+	// N is the number of images to generate, at least one
+	N int `json:"n,omitempty"`
+
+	// Size is "WIDTHxHEIGHT" (e.g. "1024x1024"). Imagen also accepts an
+	// aspect ratio such as "16:9".
+	Size string `json:"size,omitempty"`
+
+	// Quality is passed to OpenAI, e.g. "standard" or "hd" for DALL·E 3
+	Quality string `json:"quality,omitempty"`
+
+	// ResponseFormat is ImageFormatURL or ImageFormatBase64. Imagen and
+	// gpt-image models always return base64 data.
+	ResponseFormat string `json:"response_format,omitempty"`
+
+	// Timeout bounds the provider call. Defaults to the provider's timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// TenantID attributes the request to a tenant, overriding tenant.WithTenant on the context
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Image is a generated image, either hosted at URL or inline as base64 data
+type Image struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	MIMEType      string `json:"mime_type,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// Bytes decodes the base64 data of the image
+func (i Image) Bytes() ([]byte, error) {
+	if i.B64JSON == "" {
+		return nil, fmt.Errorf("image has no inline data (url: %s)", i.URL)
+	}
+	return base64.StdEncoding.DecodeString(i.B64JSON)
+}
+
+// ImageResponse is the result of an image generation request
+type ImageResponse struct {
+	Images       []Image `json:"images"`
+	Model        string  `json:"model"`
+	ProviderName string  `json:"provider_name"`
+	Cost         float64 `json:"cost"`
+}
+
+// builtinImagePricing holds per-image prices of image models whose
+// configuration omits cost_per_image. Keys are "model" or "model:quality".
+var builtinImagePricing = map[string]float64{
+	"dall-e-2":                0.02,
+	"dall-e-3":                0.04,
+	"dall-e-3:hd":             0.08,
+	"gpt-image-1":             0.042,
+	"imagen-3.0-generate-002": 0.03,
+	"imagen-4.0-generate-001": 0.04,
+}
+
+// GenerateImage generates images from a prompt with OpenAI (DALL·E, gpt-image)
+// or Imagen through Gemini or Vertex AI. Keys are selected, failed over and
+// charged like chat requests, at the model's cost_per_image.
+func (p *UnifiedProvider) GenerateImage(ctx context.Context, prompt string, opts ImageOptions) (*ImageResponse, error) {
+	if opts.N <= 0 {
+		opts.N = 1
+	}
+	prompts, conversationID, forget, err := p.tokenizeInputs([]string{prompt})
+	if err != nil {
+		return nil, err
+	}
+	defer forget()
+	prompt = prompts[0]
+
+	req := mediaRequest{
+		Provider:    opts.Provider,
+		Model:       opts.Model,
+		Timeout:     opts.Timeout,
+		TenantID:    opts.TenantID,
+		PromptBytes: len(prompt),
+	}
+	var resp *ImageResponse
+	err = p.invokeMedia(ctx, &req, func(ctx context.Context, key *auth.KeySelection) (mediaUsage, error) {
+		opts.Provider = req.Provider
+
+		var err error
+		switch req.Provider {
+		case OpenAI:
+			resp, err = p.generateOpenAIImage(ctx, prompt, opts, key)
+		case Gemini, Vertex:
+			resp, err = p.generateImagenImage(ctx, prompt, opts, key)
+		case Mock:
+			resp, err = generateMockImage(ctx, prompt, opts)
+		default:
+			err = fmt.Errorf("image generation not supported for provider: %s", req.Provider)
+		}
+		if err != nil {
+			return mediaUsage{}, err
+		}
+
+		resp.Cost = p.imageCost(opts, len(resp.Images))
+		return mediaUsage{Cost: resp.Cost}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Images {
+		if resp.Images[i].RevisedPrompt, err = p.detokenizeText(conversationID, resp.Images[i].RevisedPrompt); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// imageCost returns the cost of n images, from the model's cost_per_image or
+// the built-in image pricing
+func (p *UnifiedProvider) imageCost(opts ImageOptions, n int) float64 {
+	if modelCfg := p.modelConfig(opts.Provider, opts.Model); modelCfg != nil && modelCfg.CostPerImage > 0 {
+		return modelCfg.CostPerImage * float64(n)
+	}
+	if price, ok := builtinImagePricing[opts.Model+":"+opts.Quality]; ok {
+		return price * float64(n)
+	}
+	return builtinImagePricing[opts.Model] * float64(n)
+}
+
+// generateOpenAIImage calls the OpenAI images API
+func (p *UnifiedProvider) generateOpenAIImage(ctx context.Context, prompt string, opts ImageOptions, key *auth.KeySelection) (*ImageResponse, error) {
+	reqBody := map[string]interface{}{
+		"model":  opts.Model,
+		"prompt": prompt,
+		"n":      opts.N,
+	}
+	if opts.Size != "" {
+		reqBody["size"] = opts.Size
+	}
+	if opts.Quality != "" {
+		reqBody["quality"] = opts.Quality
+	}
+	if opts.ResponseFormat != "" && !strings.HasPrefix(opts.Model, "gpt-image") {
+		reqBody["response_format"] = opts.ResponseFormat
+	}
+
+	var result struct {
+		Data []struct {
+			URL           string `json:"url"`
+			B64JSON       string `json:"b64_json"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+	}
+	setHeaders := func(req *http.Request) error {
+		setOpenAIHeaders(req, key)
+		return nil
+	}
+	if err := p.postImageRequest(ctx, OpenAI, "https://api.openai.com/v1/images/generations", reqBody, key, setHeaders, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("%w: missing data in image response", ErrResponseFormat)
+	}
+
+	resp := &ImageResponse{Model: opts.Model, ProviderName: string(OpenAI)}
+	for _, item := range result.Data {
+		image := Image{URL: item.URL, B64JSON: item.B64JSON, RevisedPrompt: item.RevisedPrompt}
+		if item.B64JSON != "" {
+			image.MIMEType = "image/png"
+		}
+		resp.Images = append(resp.Images, image)
+	}
+	return resp, nil
+}
+
+// generateImagenImage calls the Imagen predict method through the Generative
+// Language API or Vertex AI
+func (p *UnifiedProvider) generateImagenImage(ctx context.Context, prompt string, opts ImageOptions, key *auth.KeySelection) (*ImageResponse, error) {
+	parameters := map[string]interface{}{"sampleCount": opts.N}
+	if opts.Size != "" {
+		parameters["aspectRatio"] = aspectRatio(opts.Size)
+	}
+	reqBody := map[string]interface{}{
+		"instances":  []map[string]interface{}{{"prompt": prompt}},
+		"parameters": parameters,
+	}
+
+	var apiURL string
+	var setHeaders func(*http.Request) error
+	if opts.Provider == Vertex {
+		var err error
+		if apiURL, err = p.vertexURL(opts.Model, "predict"); err != nil {
+			return nil, err
+		}
+		setHeaders = func(req *http.Request) error {
+			return p.setVertexHeaders(ctx, req, key)
+		}
+	} else {
+		apiURL = fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:predict",
+			url.PathEscape(opts.Model))
+		setHeaders = func(req *http.Request) error {
+			setGeminiHeaders(req, key)
+			return nil
+		}
+	}
+
+	var result struct {
+		Predictions []struct {
+			BytesBase64Encoded string `json:"bytesBase64Encoded"`
+			MIMEType           string `json:"mimeType"`
+		} `json:"predictions"`
+	}
+	if err := p.postImageRequest(ctx, opts.Provider, apiURL, reqBody, key, setHeaders, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Predictions) == 0 {
+		// Imagen drops images that fail its safety filters
+		return nil, &Error{
+			Code:     CodeContentFilter,
+			Provider: opts.Provider,
+			Message:  fmt.Sprintf("%s returned no images", providerDisplayName(opts.Provider)),
+			Err:      ErrContentFiltered,
+		}
+	}
+
+	resp := &ImageResponse{Model: opts.Model, ProviderName: string(opts.Provider)}
+	for _, prediction := range result.Predictions {
+		resp.Images = append(resp.Images, Image{B64JSON: prediction.BytesBase64Encoded, MIMEType: prediction.MIMEType})
+	}
+	return resp, nil
+}
+
+// postImageRequest sends a JSON image request and decodes the response into result
+func (p *UnifiedProvider) postImageRequest(ctx context.Context, provider ProviderType, apiURL string, reqBody interface{}, key *auth.KeySelection, setHeaders func(*http.Request) error, result interface{}) error {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if setHeaders != nil {
+		if err := setHeaders(req); err != nil {
+			return err
+		}
+	}
+
+	resp, err := p.sendMediaRequest(req, provider, key)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+	return nil
+}
+
+// aspectRatio converts a "WIDTHxHEIGHT" size into the "W:H" aspect ratio
+// Imagen expects. Sizes that already are ratios are returned unchanged.
+func aspectRatio(size string) string {
+	w, h, ok := strings.Cut(size, "x")
+	if !ok {
+		return size
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return size
+	}
+
+	a, b := width, height
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return fmt.Sprintf("%d:%d", width/a, height/a)
+}
+
+// mockImagePNG is a 1x1 transparent PNG returned by the mock provider
+const mockImagePNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+
+// generateMockImage returns N placeholder images, applying the failure
+// injection markers of the prompt
+func generateMockImage(ctx context.Context, prompt string, opts ImageOptions) (*ImageResponse, error) {
+	if _, err := mockCompletion(ctx, []Message{{Role: "user", Content: prompt}}, RequestOptions{Model: opts.Model}); err != nil {
+		return nil, err
+	}
+
+	resp := &ImageResponse{Model: opts.Model, ProviderName: string(Mock)}
+	for i := 0; i < opts.N; i++ {
+		resp.Images = append(resp.Images, Image{B64JSON: mockImagePNG, MIMEType: "image/png"})
+	}
+	return resp, nil
+}
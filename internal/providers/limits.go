@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// ErrRequestTooLarge is wrapped by the errors of requests rejected for
+// exceeding global.request_limits
+var ErrRequestTooLarge = errors.New("request too large")
+
+// FinishReasonLengthCap is the finish reason set when a response was cut to
+// fit RequestOptions.MaxResponseBytes or MaxResponseTokens
+const FinishReasonLengthCap = "length_cap"
+
+// applyResponseCap truncates the content of the response and each of its
+// choices to the configured byte and token caps. Usage is left untouched so it
+// reflects the tokens actually consumed.
+func applyResponseCap(resp *CompletionResponse, opts RequestOptions) {
+	if content, capped := capContent(resp.Content, opts); capped {
+		resp.Content = content
+		resp.FinishReason = FinishReasonLengthCap
+	}
+
+	for i := range resp.Choices {
+		if content, capped := capContent(resp.Choices[i].Content, opts); capped {
+			resp.Choices[i].Content = content
+			resp.Choices[i].FinishReason = FinishReasonLengthCap
+		}
+	}
+}
+
+// capContent truncates content to the byte and token caps in opts and reports
+// whether it was cut
+func capContent(content string, opts RequestOptions) (string, bool) {
+	capped := false
+
+	if opts.MaxResponseTokens > 0 {
+		truncated := tokenizer.Truncate(opts.Model, content, opts.MaxResponseTokens)
+		if len(truncated) < len(content) {
+			content = truncated
+			capped = true
+		}
+	}
+
+	if opts.MaxResponseBytes > 0 && len(content) > opts.MaxResponseBytes {
+		content = truncateBytes(content, opts.MaxResponseBytes)
+		capped = true
+	}
+
+	return content, capped
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// checkRequestSize rejects chat requests exceeding the message count or prompt
+// size limits, before any provider is called
+func (p *UnifiedProvider) checkRequestSize(provider ProviderType, messages []Message) error {
+	limits := p.getConfig().Global.RequestLimits
+	if limits.MaxMessages > 0 && len(messages) > limits.MaxMessages {
+		return tooLargeError(provider, "request has %d messages, limit is %d", len(messages), limits.MaxMessages)
+	}
+
+	size := 0
+	for _, msg := range messages {
+		size += len(msg.Content)
+	}
+	return p.checkPromptSize(provider, size)
+}
+
+// checkPromptSize rejects prompts of size bytes exceeding the prompt size limit
+func (p *UnifiedProvider) checkPromptSize(provider ProviderType, size int) error {
+	limit := p.getConfig().Global.RequestLimits.MaxPromptBytes
+	if limit > 0 && size > limit {
+		return tooLargeError(provider, "prompt is %d bytes, limit is %d", size, limit)
+	}
+	return nil
+}
+
+// checkAttachmentSize rejects uploads of size bytes exceeding the attachment size limit
+func (p *UnifiedProvider) checkAttachmentSize(provider ProviderType, size int) error {
+	limit := p.getConfig().Global.RequestLimits.MaxAttachmentBytes
+	if limit > 0 && size > limit {
+		return tooLargeError(provider, "attachment exceeds the limit of %d bytes", limit)
+	}
+	return nil
+}
+
+// tooLargeError returns the error of a request exceeding a size limit
+func tooLargeError(provider ProviderType, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:     CodeTooLarge,
+		Provider: provider,
+		Message:  fmt.Sprintf(format, args...),
+		Err:      ErrRequestTooLarge,
+	}
+}
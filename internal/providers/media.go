@@ -0,0 +1,158 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/analytics"
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/redact"
+	"github.com/gollmkit/gollmkit/internal/tenant"
+)
+
+// mediaRequest describes a request to a non-chat endpoint such as image
+// generation or transcription
+type mediaRequest struct {
+	Provider ProviderType
+	Model    string
+	Timeout  time.Duration
+	TenantID string
+
+	// PromptBytes and AttachmentBytes are checked against global.request_limits
+	PromptBytes     int
+	AttachmentBytes int
+}
+
+// mediaUsage is what a media call consumed
+type mediaUsage struct {
+	Usage TokenUsage
+	Cost  float64
+}
+
+// mediaCall performs a media request with key and reports its usage
+type mediaCall func(ctx context.Context, key *auth.KeySelection) (mediaUsage, error)
+
+// invokeMedia runs call with the key selection, failover, timeout, tenant
+// quota and usage accounting of chat requests. Defaults are filled into req.
+func (p *UnifiedProvider) invokeMedia(ctx context.Context, req *mediaRequest, call mediaCall) error {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return err
+	}
+	defer endCall()
+
+	if req.Provider == "" {
+		req.Provider = p.defaultProvider()
+	}
+	if err := p.validateModel(req.Provider, req.Model); err != nil {
+		return err
+	}
+	if err := p.checkPromptSize(req.Provider, req.PromptBytes); err != nil {
+		return err
+	}
+	if err := p.checkAttachmentSize(req.Provider, req.AttachmentBytes); err != nil {
+		return err
+	}
+
+	if req.Timeout == 0 {
+		providerCfg, err := p.getConfig().GetProvider(string(req.Provider))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		if req.Timeout, err = providerCfg.GetTimeout(&p.getConfig().Global); err != nil {
+			return fmt.Errorf("%w: invalid timeout for %s: %v", ErrInvalidConfig, req.Provider, err)
+		}
+	}
+
+	if req.TenantID == "" {
+		req.TenantID = tenant.FromContext(ctx)
+	}
+	if p.tenants != nil && req.TenantID != "" {
+		limits := p.getConfig().GetTenantLimits(req.TenantID)
+		if err := p.tenants.Allow(req.TenantID, limits, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	key, err := p.getNextKey(ctx, req.Provider, auth.ForModel(req.Model))
+	if err != nil {
+		return err
+	}
+	defer func() { key.Release() }()
+
+	opts := RequestOptions{Provider: req.Provider, Model: req.Model, Timeout: req.Timeout, TenantID: req.TenantID}
+	var usage mediaUsage
+	var failedKeys []string
+	for {
+		usage, err = p.dispatchMedia(ctx, *req, key, call)
+		if err == nil {
+			break
+		}
+
+		next := p.failoverKey(ctx, opts, key, &failedKeys, err)
+		if next == nil {
+			return err
+		}
+		key = next
+	}
+
+	p.recordCost(ctx, req.Provider, key.KeyName, req.Model, usage.Usage.TotalTokens, usage.Cost)
+	if p.tenants != nil && req.TenantID != "" {
+		p.tenants.Record(req.TenantID, usage.Usage.TotalTokens, usage.Cost, time.Now())
+	}
+	return nil
+}
+
+// dispatchMedia runs a single attempt of a media request
+func (p *UnifiedProvider) dispatchMedia(ctx context.Context, req mediaRequest, key *auth.KeySelection, call mediaCall) (mediaUsage, error) {
+	defer p.beginRequest(req.Provider)()
+	start := time.Now()
+
+	callerCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, req.Timeout)
+	defer cancel()
+
+	usage, err := call(ctx, key)
+	err = redact.Error(timeoutError(ctx, callerCtx, RequestOptions{Provider: req.Provider, Timeout: req.Timeout}, err), key.Key)
+	if err != nil {
+		p.recordError(callerCtx, req.Provider, key.KeyName, err)
+	} else {
+		p.recordLatency(req.Provider, req.Model, start)
+	}
+
+	if p.tracker != nil {
+		event := analytics.Event{
+			Time:     start,
+			Provider: string(req.Provider),
+			Model:    req.Model,
+			KeyName:  key.KeyName,
+			Tenant:   req.TenantID,
+			Latency:  time.Since(start),
+		}
+		if err != nil {
+			event.Error = err.Error()
+			event.ErrorCode = string(CodeOf(err))
+		} else {
+			event.InputTokens = usage.Usage.PromptTokens
+			event.OutputTokens = usage.Usage.CompletionTokens
+			event.Cost = usage.Cost
+		}
+		_ = p.tracker.Record(event)
+	}
+	return usage, err
+}
+
+// modelConfig returns the configuration of a model, or nil if it isn't configured
+func (p *UnifiedProvider) modelConfig(provider ProviderType, model string) *config.ModelConfig {
+	providerCfg, err := p.getConfig().GetProvider(string(provider))
+	if err != nil {
+		return nil
+	}
+	modelCfg, err := providerCfg.GetModelByName(model)
+	if err != nil {
+		return nil
+	}
+	return modelCfg
+}
@@ -0,0 +1,83 @@
+package providers
+
+import "context"
+
+// ChatHandler sends a conversation to an LLM, like UnifiedProvider.Chat
+type ChatHandler func(ctx context.Context, messages []Message, opts RequestOptions) (*CompletionResponse, error)
+
+// Middleware wraps a ChatHandler to inspect or rewrite requests and responses
+type Middleware func(next ChatHandler) ChatHandler
+
+// StreamHandler streams the response to a conversation, like UnifiedProvider.ChatStream
+type StreamHandler func(ctx context.Context, messages []Message, opts RequestOptions) (<-chan StreamChunk, error)
+
+// StreamMiddleware wraps a StreamHandler to inspect or rewrite streamed
+// requests and their chunks
+type StreamMiddleware func(next StreamHandler) StreamHandler
+
+// BatchHandler submits a batch of requests, like UnifiedProvider.BatchSubmit
+type BatchHandler func(ctx context.Context, provider ProviderType, requests []BatchRequest) (*Batch, error)
+
+// BatchMiddleware wraps a BatchHandler to inspect or rewrite batch requests
+// before they are submitted
+type BatchMiddleware func(next BatchHandler) BatchHandler
+
+// Use adds middleware around Chat and Invoke. Middleware added first is the
+// outermost: it sees requests first and responses last. Streamed requests
+// don't pass through middleware; add stream middleware with UseStream.
+func (p *UnifiedProvider) Use(middleware ...Middleware) {
+	p.middlewareMu.Lock()
+	defer p.middlewareMu.Unlock()
+	p.middleware = append(p.middleware, middleware...)
+}
+
+// UseStream adds middleware around ChatStream and InvokeStream, in the same
+// order as Use
+func (p *UnifiedProvider) UseStream(middleware ...StreamMiddleware) {
+	p.middlewareMu.Lock()
+	defer p.middlewareMu.Unlock()
+	p.streamMiddleware = append(p.streamMiddleware, middleware...)
+}
+
+// UseBatch adds middleware around BatchSubmit, in the same order as Use
+func (p *UnifiedProvider) UseBatch(middleware ...BatchMiddleware) {
+	p.middlewareMu.Lock()
+	defer p.middlewareMu.Unlock()
+	p.batchMiddleware = append(p.batchMiddleware, middleware...)
+}
+
+// chatHandler returns chat wrapped in the middleware
+func (p *UnifiedProvider) chatHandler() ChatHandler {
+	p.middlewareMu.RLock()
+	defer p.middlewareMu.RUnlock()
+
+	handler := ChatHandler(p.chat)
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		handler = p.middleware[i](handler)
+	}
+	return handler
+}
+
+// streamHandler returns chatStream wrapped in the stream middleware
+func (p *UnifiedProvider) streamHandler() StreamHandler {
+	p.middlewareMu.RLock()
+	defer p.middlewareMu.RUnlock()
+
+	handler := StreamHandler(p.chatStream)
+	for i := len(p.streamMiddleware) - 1; i >= 0; i-- {
+		handler = p.streamMiddleware[i](handler)
+	}
+	return handler
+}
+
+// batchHandler returns batchSubmit wrapped in the batch middleware
+func (p *UnifiedProvider) batchHandler() BatchHandler {
+	p.middlewareMu.RLock()
+	defer p.middlewareMu.RUnlock()
+
+	handler := BatchHandler(p.batchSubmit)
+	for i := len(p.batchMiddleware) - 1; i >= 0; i-- {
+		handler = p.batchMiddleware[i](handler)
+	}
+	return handler
+}
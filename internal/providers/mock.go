@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// Mock is an offline provider for tests. It echoes the last user message and
+// never calls a remote API. Prompts may contain failure injection markers:
+//
+//	[[inject:429]]             fail with the given HTTP status code
+//	[[inject:slow:5s]]         wait for the duration (or until ctx is done) before responding
+//	[[inject:malformed_json]]  fail as if the response body couldn't be decoded
+//	[[inject:network]]         fail as if the connection was dropped
+//	[[inject:empty]]           respond with empty content
+//	[[inject:tool:NAME:ARGS]]  call the tool NAME of RequestOptions.Tools with the JSON ARGS, if given
+//
+// Only markers of the last user message apply, in the order they appear, so
+// "[[inject:slow:2s]][[inject:500]]" fails after two seconds. Tools are only
+// called in reply to the user message with the marker; the reply to tool
+// results echoes the results. To use it, configure a "mock" provider with any key.
+const Mock ProviderType = "mock"
+
+// injectPattern matches failure injection markers in mock prompts
+var injectPattern = regexp.MustCompile(`\[\[inject:([a-z_0-9]+)(?::([^\]]+))?\]\]`)
+
+func (p *UnifiedProvider) callMock(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	start := time.Now()
+	completion, err := mockCompletion(ctx, messages, opts)
+	if err != nil {
+		p.recordError(ctx, Mock, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(Mock, opts.Model, start)
+
+	p.recordUsage(ctx, Mock, key.KeyName, opts.Model, completion.Usage)
+
+	return completion, nil
+}
+
+// mockCompletion builds the mock response for messages, applying any
+// failure injection markers
+func mockCompletion(ctx context.Context, messages []Message, opts RequestOptions) (*CompletionResponse, error) {
+	var prompt strings.Builder
+	var lastUser string
+	for _, msg := range messages {
+		prompt.WriteString(msg.Content)
+		if msg.Role == "user" {
+			lastUser = msg.Content
+		}
+	}
+
+	content := "mock response: " + strings.TrimSpace(injectPattern.ReplaceAllString(lastUser, ""))
+	var results []string
+	for i := len(messages) - 1; i >= 0 && messages[i].Role == "tool"; i-- {
+		results = append([]string{messages[i].Content}, results...)
+	}
+	if len(results) > 0 {
+		content = "mock response: " + strings.Join(results, ", ")
+	}
+	var toolCalls []ToolCall
+
+	for _, match := range injectPattern.FindAllStringSubmatch(lastUser, -1) {
+		kind, arg := match[1], match[2]
+
+		var err error
+		switch kind {
+		case "slow":
+			err = mockDelay(ctx, arg)
+		case "malformed_json":
+			err = fmt.Errorf("%w: invalid character '<' looking for beginning of value", ErrResponseFormat)
+		case "network":
+			err = fmt.Errorf("Mock API connection reset by peer")
+		case "empty":
+			content = ""
+		case "tool":
+			if call, ok := mockToolCall(messages, opts, match[0], arg, len(toolCalls)); ok {
+				toolCalls = append(toolCalls, call)
+			}
+		default:
+			status, convErr := strconv.Atoi(kind)
+			if convErr != nil {
+				return nil, fmt.Errorf("unknown mock inject marker %q", match[0])
+			}
+			err = newAPIError(Mock, status)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	finishReason := FinishReasonStop
+	if len(toolCalls) > 0 {
+		content, finishReason = "", FinishReasonToolCalls
+	}
+
+	// Every requested candidate echoes the same content
+	choices := make([]Choice, max(opts.N, 1))
+	for i := range choices {
+		choices[i] = Choice{Index: i, Content: content, FinishReason: finishReason}
+	}
+
+	promptTokens := tokenizer.CountTokens(opts.Model, prompt.String())
+	completionTokens := tokenizer.CountTokens(opts.Model, content) * len(choices)
+	return &CompletionResponse{
+		Content: content,
+		Model:   opts.Model,
+		Usage: TokenUsage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+		ProviderName: string(Mock),
+		FinishReason: finishReason,
+		Choices:      choices,
+		ToolCalls:    toolCalls,
+	}, nil
+}
+
+// mockToolCall returns the call of a tool marker with argument "NAME:ARGS".
+// Tools are only called if they are offered and the marker is in the user
+// message being replied to.
+func mockToolCall(messages []Message, opts RequestOptions, marker, arg string, index int) (ToolCall, bool) {
+	last := len(messages) - 1
+	if last < 0 || messages[last].Role != "user" || !strings.Contains(messages[last].Content, marker) {
+		return ToolCall{}, false
+	}
+	name, arguments, _ := strings.Cut(arg, ":")
+	for _, tool := range opts.Tools {
+		if tool.Name == name {
+			if arguments == "" {
+				arguments = "{}"
+			}
+			return ToolCall{ID: fmt.Sprintf("call_mock_%d", index), Name: name, Arguments: arguments}, true
+		}
+	}
+	return ToolCall{}, false
+}
+
+// mockStreamBody renders the mock response as an OpenAI-style event stream
+// with one delta per word followed by the finish reason and usage
+func mockStreamBody(ctx context.Context, messages []Message, opts RequestOptions) (io.ReadCloser, error) {
+	completion, err := mockCompletion(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var body strings.Builder
+	writeEvent := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(&body, "data: %s\n\n", data)
+	}
+
+	for _, word := range strings.SplitAfter(completion.Content, " ") {
+		if word == "" {
+			continue
+		}
+		writeEvent(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": word}}},
+		})
+	}
+	writeEvent(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]string{}, "finish_reason": "stop"}},
+	})
+	writeEvent(map[string]interface{}{"choices": []interface{}{}, "usage": completion.Usage})
+	body.WriteString("data: [DONE]\n\n")
+
+	return io.NopCloser(strings.NewReader(body.String())), nil
+}
+
+// mockDelay waits for the duration given by a slow marker
+func mockDelay(ctx context.Context, arg string) error {
+	delay, err := time.ParseDuration(arg)
+	if err != nil {
+		return fmt.Errorf("invalid mock slow duration %q: %w", arg, err)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
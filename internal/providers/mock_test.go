@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestMockCompletionMarkers applies the injection markers of the last user
+// message only, so a marker earlier in a conversation doesn't fail every
+// later turn
+func TestMockCompletionMarkers(t *testing.T) {
+	weather := RequestOptions{Tools: []Tool{{Name: "get_weather"}}}
+	tests := []struct {
+		name      string
+		messages  []Message
+		opts      RequestOptions
+		wantErr   error
+		want      string
+		toolCalls int
+	}{
+		{
+			name:     "marker in last user message",
+			messages: []Message{{Role: "user", Content: "hi [[inject:503]]"}},
+			wantErr:  ErrOverloaded,
+		},
+		{
+			name: "marker in earlier user message",
+			messages: []Message{
+				{Role: "user", Content: "hi [[inject:503]]"},
+				{Role: "assistant", Content: "failed"},
+				{Role: "user", Content: "again"},
+			},
+			want: "mock response: again",
+		},
+		{
+			name: "marker in system and assistant messages",
+			messages: []Message{
+				{Role: "system", Content: "[[inject:network]]"},
+				{Role: "assistant", Content: "[[inject:malformed_json]]"},
+				{Role: "user", Content: "hello"},
+			},
+			want: "mock response: hello",
+		},
+		{
+			name: "several markers",
+			messages: []Message{
+				{Role: "user", Content: "[[inject:empty]] and [[inject:malformed_json]]"},
+			},
+			wantErr: ErrResponseFormat,
+		},
+		{
+			name:      "tool marker",
+			messages:  []Message{{Role: "user", Content: `weather? [[inject:tool:get_weather:{"city":"Paris"}]]`}},
+			opts:      weather,
+			toolCalls: 1,
+		},
+		{
+			name: "tool results",
+			messages: []Message{
+				{Role: "user", Content: `weather? [[inject:tool:get_weather:{"city":"Paris"}]]`},
+				{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_mock_0", Name: "get_weather"}}},
+				{Role: "tool", Content: "sunny", ToolCallID: "call_mock_0"},
+			},
+			opts: weather,
+			want: "mock response: sunny",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := mockCompletion(context.Background(), tt.messages, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.ToolCalls) != tt.toolCalls {
+				t.Errorf("%d tool calls, want %d", len(resp.ToolCalls), tt.toolCalls)
+			}
+			if resp.Content != tt.want {
+				t.Errorf("content %q, want %q", resp.Content, tt.want)
+			}
+		})
+	}
+}
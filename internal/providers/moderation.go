@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// DefaultModerationModel is the OpenAI moderation model used when none is set
+const DefaultModerationModel = "omni-moderation-latest"
+
+// ModerationOptions contains options for moderation requests
+type ModerationOptions struct {
+	// Provider defaults to OpenAI, the only provider with a moderation endpoint
+	Provider ProviderType `json:"provider,omitempty"`
+
+	// Model defaults to DefaultModerationModel. Like other models it must be
+	// listed in the provider's models.
+	Model string `json:"model,omitempty"`
+
+	// Timeout bounds the provider call. Defaults to the provider's timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// TenantID attributes the request to a tenant, overriding tenant.WithTenant on the context
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// ModerationResult is the classification of a single input
+type ModerationResult struct {
+	Flagged    bool               `json:"flagged"`
+	Categories map[string]bool    `json:"categories"`
+	Scores     map[string]float64 `json:"category_scores"`
+}
+
+// Moderate classifies inputs with OpenAI's moderation endpoint, returning one
+// result per input. Moderation is free, so only the request is counted.
+func (p *UnifiedProvider) Moderate(ctx context.Context, inputs []string, opts ModerationOptions) ([]ModerationResult, error) {
+	if opts.Provider == "" {
+		opts.Provider = OpenAI
+	}
+	if opts.Model == "" {
+		opts.Model = DefaultModerationModel
+	}
+
+	inputs, _, forget, err := p.tokenizeInputs(inputs)
+	if err != nil {
+		return nil, err
+	}
+	defer forget()
+
+	size := 0
+	for _, input := range inputs {
+		size += len(input)
+	}
+
+	req := mediaRequest{
+		Provider:    opts.Provider,
+		Model:       opts.Model,
+		Timeout:     opts.Timeout,
+		TenantID:    opts.TenantID,
+		PromptBytes: size,
+	}
+	var results []ModerationResult
+	err = p.invokeMedia(ctx, &req, func(ctx context.Context, key *auth.KeySelection) (mediaUsage, error) {
+		var err error
+		switch req.Provider {
+		case OpenAI:
+			results, err = p.moderateOpenAI(ctx, inputs, req.Model, key)
+		case Mock:
+			results, err = moderateMock(ctx, inputs, req.Model)
+		default:
+			err = fmt.Errorf("moderation not supported for provider: %s", req.Provider)
+		}
+		return mediaUsage{}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// moderateOpenAI calls the OpenAI moderations API
+func (p *UnifiedProvider) moderateOpenAI(ctx context.Context, inputs []string, model string, key *auth.KeySelection) ([]ModerationResult, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/moderations", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setOpenAIHeaders(req, key)
+
+	resp, err := p.sendMediaRequest(req, OpenAI, key)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	var result struct {
+		Results []ModerationResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+	if len(result.Results) != len(inputs) {
+		return nil, fmt.Errorf("%w: got %d moderation results for %d inputs", ErrResponseFormat, len(result.Results), len(inputs))
+	}
+	return result.Results, nil
+}
+
+// mockFlagPattern marks mock moderation inputs to flag, e.g. "[[flag:violence]]"
+var mockFlagPattern = regexp.MustCompile(`\[\[flag:([a-z_/-]+)\]\]`)
+
+// moderateMock flags inputs containing "[[flag:<category>]]" markers and
+// applies the failure injection markers of the inputs
+func moderateMock(ctx context.Context, inputs []string, model string) ([]ModerationResult, error) {
+	messages := make([]Message, len(inputs))
+	for i, input := range inputs {
+		messages[i] = Message{Role: "user", Content: input}
+	}
+	if _, err := mockCompletion(ctx, messages, RequestOptions{Model: model}); err != nil {
+		return nil, err
+	}
+
+	results := make([]ModerationResult, len(inputs))
+	for i, input := range inputs {
+		result := ModerationResult{Categories: map[string]bool{}, Scores: map[string]float64{}}
+		for _, match := range mockFlagPattern.FindAllStringSubmatch(input, -1) {
+			result.Flagged = true
+			result.Categories[match[1]] = true
+			result.Scores[match[1]] = 1
+		}
+		results[i] = result
+	}
+	return results, nil
+}
@@ -0,0 +1,31 @@
+package providers
+
+import "github.com/gollmkit/gollmkit/internal/messages"
+
+// messageRules are the role rules of providers restricting conversations.
+// Histories of other providers are only compacted.
+var messageRules = map[ProviderType]messages.Rules{
+	Anthropic: messages.AnthropicRules,
+	Gemini:    messages.GeminiRules,
+	Vertex:    messages.GeminiRules,
+}
+
+// NormalizeMessages returns msgs as they are sent for the provider of opts:
+// without empty messages, with consecutive messages of a role merged and
+// adapted to the provider's role rules. msgs is returned unchanged when
+// opts.RawMessages or opts.Raw is set.
+func NormalizeMessages(msgs []Message, opts RequestOptions) []Message {
+	if opts.RawMessages || opts.Raw {
+		return msgs
+	}
+	return messages.Normalize(msgs, messageRules[opts.Provider])
+}
+
+// splitSystem returns the content of a leading system message and the
+// messages after it, for APIs taking the system prompt separately
+func splitSystem(msgs []Message) (string, []Message) {
+	if len(msgs) > 0 && msgs[0].Role == messages.RoleSystem {
+		return msgs[0].Content, msgs[1:]
+	}
+	return "", msgs
+}
@@ -0,0 +1,243 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// OpenAIProvider sends requests to OpenAI only. It implements LLMProvider
+// with the same key rotation, usage tracking and middleware as
+// UnifiedProvider, without fallback to other providers.
+type OpenAIProvider struct {
+	vendorProvider
+}
+
+// NewOpenAIProvider creates a provider for the openai configuration of cfg
+func NewOpenAIProvider(cfg *config.Config, rotator *auth.KeyRotator, validator *auth.KeyValidator) *OpenAIProvider {
+	p := newVendorProvider(OpenAI, cfg, rotator, validator)
+	p.encode = openAIRequestBody
+	p.decode = func(result map[string]interface{}, model string) (*CompletionResponse, error) {
+		return parseOpenAIResponse(result, OpenAI, model)
+	}
+	return &OpenAIProvider{p}
+}
+
+// chatCompletionsURLs are the endpoints of providers implementing the OpenAI chat completions API
+var chatCompletionsURLs = map[ProviderType]string{
+	OpenAI:   "https://api.openai.com/v1/chat/completions",
+	XAI:      "https://api.x.ai/v1/chat/completions",
+	DeepSeek: "https://api.deepseek.com/chat/completions",
+}
+
+// setOpenAIHeaders sets the authentication headers for OpenAI requests
+func setOpenAIHeaders(req *http.Request, key *auth.KeySelection) {
+	req.Header.Set("Authorization", "Bearer "+key.Key)
+}
+
+// openAIRequestBody builds the chat completions request body
+func openAIRequestBody(messages []Message, opts RequestOptions) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":    opts.Model,
+		"messages": openAIMessages(messages),
+		"stream":   opts.Stream,
+	}
+	if len(opts.Stop) > 0 {
+		body["stop"] = opts.Stop
+	}
+
+	// Reasoning models reject max_tokens and sampling parameters. A zero
+	// limit, only possible when set explicitly, sends none.
+	reasoning := isReasoningModel(opts.Model)
+	maxTokens := opts.MaxTokens
+	if opts.MaxCompletionTokens > 0 {
+		maxTokens = opts.MaxCompletionTokens
+	}
+	switch {
+	case maxTokens == 0:
+	case reasoning || opts.MaxCompletionTokens > 0:
+		body["max_completion_tokens"] = maxTokens
+	default:
+		body["max_tokens"] = maxTokens
+	}
+	if !reasoning {
+		body["temperature"] = opts.Temperature
+		body["top_p"] = opts.TopP
+	}
+	if opts.ReasoningEffort != "" {
+		body["reasoning_effort"] = opts.ReasoningEffort
+	}
+	if opts.N > 1 {
+		body["n"] = opts.N
+	}
+	openAITools(body, opts)
+	return applyExtra(body, opts)
+}
+
+// reasoningModelPattern matches OpenAI's o-series reasoning models, e.g. o1, o3-mini, o4-mini
+var reasoningModelPattern = regexp.MustCompile(`^o[0-9]+(-|$)`)
+
+// isReasoningModel reports whether model is an OpenAI reasoning model
+func isReasoningModel(model string) bool {
+	return reasoningModelPattern.MatchString(model)
+}
+
+// reasoningEfforts lists the accepted RequestOptions.ReasoningEffort values
+var reasoningEfforts = map[string]bool{"minimal": true, "low": true, "medium": true, "high": true}
+
+// openAIReasoningTokens returns completion_tokens_details.reasoning_tokens of a usage object
+func openAIReasoningTokens(usage map[string]interface{}) int {
+	details, _ := usage["completion_tokens_details"].(map[string]interface{})
+	tokens, _ := details["reasoning_tokens"].(float64)
+	return int(tokens)
+}
+
+// openAICachedTokens returns prompt_tokens_details.cached_tokens of a usage object
+func openAICachedTokens(usage map[string]interface{}) int {
+	details, _ := usage["prompt_tokens_details"].(map[string]interface{})
+	tokens, _ := details["cached_tokens"].(float64)
+	return int(tokens)
+}
+
+// parseOpenAIResponse converts a chat completions response body into a CompletionResponse
+func parseOpenAIResponse(result map[string]interface{}, provider ProviderType, model string) (*CompletionResponse, error) {
+	choices, ok := result["choices"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing choices in response", ErrResponseFormat)
+	}
+	if len(choices) == 0 {
+		return nil, emptyResponseError(provider)
+	}
+
+	usage, ok := result["usage"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing usage in response", ErrResponseFormat)
+	}
+
+	tokenUsage := TokenUsage{
+		PromptTokens:     int(usage["prompt_tokens"].(float64)),
+		CompletionTokens: int(usage["completion_tokens"].(float64)),
+		TotalTokens:      int(usage["total_tokens"].(float64)),
+		CachedTokens:     openAICachedTokens(usage),
+		ReasoningTokens:  openAIReasoningTokens(usage),
+	}
+
+	// Content is null for choices withheld by the content filter and may be
+	// for choices calling tools
+	var ratings []SafetyRating
+	var toolCalls []ToolCall
+	var citations []Citation
+	parsed := make([]Choice, 0, len(choices))
+	for i, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		message, ok := choice["message"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid message format in response", ErrResponseFormat)
+		}
+		msgContent, ok := message["content"].(string)
+		if !ok && message["content"] != nil {
+			return nil, fmt.Errorf("%w: invalid message format in response", ErrResponseFormat)
+		}
+		finishReason, _ := choice["finish_reason"].(string)
+		if ratings == nil {
+			ratings = parseContentFilterResults(choice["content_filter_results"])
+		}
+		if i == 0 {
+			toolCalls = parseOpenAIToolCalls(message)
+			citations = parseOpenAICitations(message, msgContent)
+		}
+
+		parsed = append(parsed, Choice{
+			Index:        i,
+			Content:      msgContent,
+			FinishReason: normalizeFinishReason(provider, finishReason),
+		})
+	}
+
+	if block := filteredChoices(provider, parsed, "content_filter", ratings); block != nil {
+		return nil, safetyError(block)
+	}
+	if provider == XAI {
+		citations = append(citations, parseXAICitations(result)...)
+	}
+
+	return &CompletionResponse{
+		Content:      parsed[0].Content,
+		Model:        model,
+		Usage:        tokenUsage,
+		ProviderName: string(provider),
+		FinishReason: parsed[0].FinishReason,
+		Choices:      parsed,
+		ToolCalls:    toolCalls,
+		Citations:    citations,
+		Metadata:     result,
+	}, nil
+}
+
+func (p *UnifiedProvider) callOpenAI(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	return p.callOpenAICompatible(ctx, OpenAI, messages, opts, key)
+}
+
+// callOpenAICompatible calls a provider that implements the OpenAI chat completions API
+func (p *UnifiedProvider) callOpenAICompatible(ctx context.Context, provider ProviderType, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	if opts.Raw {
+		prompt, rawOpts := p.rawRequest(messages, opts)
+		return p.callCompletions(ctx, provider, prompt, rawOpts, key)
+	}
+
+	reqBody := openAIRequestBody(messages, opts)
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", chatCompletionsURLs[provider], bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setOpenAIHeaders(req, key)
+
+	req, trace := traceRequest(req, opts)
+	start := time.Now()
+	resp, err := p.httpClient(provider).Do(req)
+	if err != nil {
+		p.recordError(ctx, provider, key.KeyName, err)
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+	p.recordRateLimit(provider, key.KeyName, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		err = parseAPIError(provider, resp)
+		p.recordError(ctx, provider, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(provider, opts.Model, start)
+
+	var result map[string]interface{}
+	info, err := decodeResponse(resp, trace, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := parseOpenAIResponse(result, provider, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recordUsage(ctx, provider, key.KeyName, opts.Model, completion.Usage)
+
+	completion.ResponseInfo = info
+	completion.ProviderRequestID = providerRequestID(resp.Header)
+	return completion, nil
+}
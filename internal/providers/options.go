@@ -0,0 +1,76 @@
+package providers
+
+// OptionField identifies a RequestOptions field whose zero value is a legal setting
+type OptionField uint8
+
+// Fields that can be set explicitly to their zero value
+const (
+	FieldMaxTokens OptionField = 1 << iota
+	FieldTemperature
+	FieldTopP
+)
+
+// IsSet reports whether field was set, either to a non-zero value or
+// explicitly to zero with one of the With methods
+func (o RequestOptions) IsSet(field OptionField) bool {
+	if o.Set&field != 0 {
+		return true
+	}
+	switch field {
+	case FieldMaxTokens:
+		return o.MaxTokens != 0
+	case FieldTemperature:
+		return o.Temperature != 0
+	case FieldTopP:
+		return o.TopP != 0
+	}
+	return false
+}
+
+// WithMaxTokens returns a copy of o with MaxTokens set to n. Unlike assigning
+// the field, this keeps a zero from being replaced by the default; a
+// MaxTokens of 0 sends no limit, which Anthropic doesn't allow.
+func (o RequestOptions) WithMaxTokens(n int) RequestOptions {
+	o.MaxTokens = n
+	o.Set |= FieldMaxTokens
+	return o
+}
+
+// WithTemperature returns a copy of o with Temperature set to t, including 0
+func (o RequestOptions) WithTemperature(t float32) RequestOptions {
+	o.Temperature = t
+	o.Set |= FieldTemperature
+	return o
+}
+
+// WithTopP returns a copy of o with TopP set to p, including 0
+func (o RequestOptions) WithTopP(p float32) RequestOptions {
+	o.TopP = p
+	o.Set |= FieldTopP
+	return o
+}
+
+// applyExtra merges the RequestOptions.Extra fields of the request's provider
+// into body. Nested objects are merged key by key; other values replace
+// what gollmkit would send, and nil values remove the field.
+func applyExtra(body map[string]interface{}, opts RequestOptions) map[string]interface{} {
+	mergeFields(body, opts.Extra[opts.Provider])
+	return body
+}
+
+// mergeFields merges src into dst recursively
+func mergeFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		nested, ok := value.(map[string]interface{})
+		existing, exists := dst[key].(map[string]interface{})
+		if ok && exists {
+			mergeFields(existing, nested)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,152 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// ModelPrice is the price of a model in USD per 1k tokens
+type ModelPrice struct {
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cached_input,omitempty"` // prompt tokens read from the provider's cache, Input if 0
+	Output      float64 `json:"output"`
+}
+
+// Cost returns the cost of usage at price p
+func (p ModelPrice) Cost(usage TokenUsage) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	uncached := usage.PromptTokens - usage.CachedTokens
+	return float64(uncached)/1000*p.Input +
+		float64(usage.CachedTokens)/1000*cachedPrice +
+		float64(usage.CompletionTokens)/1000*p.Output
+}
+
+// configuredPrice returns the price set in a model configuration, if any
+func configuredPrice(model *config.ModelConfig) (ModelPrice, bool) {
+	if model.InputCostPer1KTokens == 0 && model.OutputCostPer1KTokens == 0 {
+		return ModelPrice{}, false
+	}
+	return ModelPrice{
+		Input:       model.InputCostPer1KTokens,
+		CachedInput: model.CachedInputCostPer1KTokens,
+		Output:      model.OutputCostPer1KTokens,
+	}, true
+}
+
+// bundledPricing holds list prices per 1k tokens, so cost tracking works for
+// models whose configuration omits pricing. Entries match model names by
+// prefix, e.g. "claude-3-5-sonnet" prices "claude-3-5-sonnet-20241022"; the
+// longest matching entry wins. Vertex AI uses the Gemini prices.
+var bundledPricing = map[ProviderType]map[string]ModelPrice{
+	OpenAI: {
+		"gpt-4.1":       {Input: 0.002, CachedInput: 0.0005, Output: 0.008},
+		"gpt-4.1-mini":  {Input: 0.0004, CachedInput: 0.0001, Output: 0.0016},
+		"gpt-4.1-nano":  {Input: 0.0001, CachedInput: 0.000025, Output: 0.0004},
+		"gpt-4o":        {Input: 0.0025, CachedInput: 0.00125, Output: 0.01},
+		"gpt-4o-mini":   {Input: 0.00015, CachedInput: 0.000075, Output: 0.0006},
+		"gpt-4-turbo":   {Input: 0.01, Output: 0.03},
+		"gpt-4":         {Input: 0.03, Output: 0.06},
+		"gpt-3.5-turbo": {Input: 0.0005, Output: 0.0015},
+		"o1":            {Input: 0.015, CachedInput: 0.0075, Output: 0.06},
+		"o1-mini":       {Input: 0.0011, CachedInput: 0.00055, Output: 0.0044},
+		"o3":            {Input: 0.002, CachedInput: 0.0005, Output: 0.008},
+		"o3-mini":       {Input: 0.0011, CachedInput: 0.00055, Output: 0.0044},
+		"o4-mini":       {Input: 0.0011, CachedInput: 0.000275, Output: 0.0044},
+
+		"text-embedding-3-small": {Input: 0.00002},
+		"text-embedding-3-large": {Input: 0.00013},
+		"text-embedding-ada-002": {Input: 0.0001},
+	},
+	Anthropic: {
+		"claude-opus-4":     {Input: 0.015, CachedInput: 0.0015, Output: 0.075},
+		"claude-sonnet-4":   {Input: 0.003, CachedInput: 0.0003, Output: 0.015},
+		"claude-3-7-sonnet": {Input: 0.003, CachedInput: 0.0003, Output: 0.015},
+		"claude-3-5-sonnet": {Input: 0.003, CachedInput: 0.0003, Output: 0.015},
+		"claude-3-5-haiku":  {Input: 0.0008, CachedInput: 0.00008, Output: 0.004},
+		"claude-3-opus":     {Input: 0.015, CachedInput: 0.0015, Output: 0.075},
+		"claude-3-sonnet":   {Input: 0.003, CachedInput: 0.0003, Output: 0.015},
+		"claude-3-haiku":    {Input: 0.00025, CachedInput: 0.00003, Output: 0.00125},
+	},
+	Gemini: {
+		"gemini-2.5-pro":        {Input: 0.00125, CachedInput: 0.00031, Output: 0.01},
+		"gemini-2.5-flash":      {Input: 0.0003, CachedInput: 0.000075, Output: 0.0025},
+		"gemini-2.0-flash":      {Input: 0.0001, CachedInput: 0.000025, Output: 0.0004},
+		"gemini-2.0-flash-lite": {Input: 0.000075, Output: 0.0003},
+		"gemini-1.5-pro":        {Input: 0.00125, CachedInput: 0.0003125, Output: 0.005},
+		"gemini-1.5-flash":      {Input: 0.000075, CachedInput: 0.00001875, Output: 0.0003},
+
+		"gemini-embedding-001": {Input: 0.00015},
+		"text-embedding-005":   {Input: 0.0001},
+	},
+	XAI: {
+		"grok-4":      {Input: 0.003, CachedInput: 0.00075, Output: 0.015},
+		"grok-3":      {Input: 0.003, CachedInput: 0.00075, Output: 0.015},
+		"grok-3-mini": {Input: 0.0003, CachedInput: 0.000075, Output: 0.0005},
+	},
+	DeepSeek: {
+		"deepseek-chat":     {Input: 0.00027, CachedInput: 0.00007, Output: 0.0011},
+		"deepseek-reasoner": {Input: 0.00055, CachedInput: 0.00014, Output: 0.00219},
+	},
+}
+
+var (
+	priceOverridesMu sync.RWMutex
+	priceOverrides   = make(map[ProviderType]map[string]ModelPrice)
+)
+
+// SetModelPrice sets the catalog price of a model at runtime, e.g. after a
+// provider changes its prices. It overrides the bundled price of the exact
+// model name; prices in the configuration still take precedence.
+func SetModelPrice(provider ProviderType, model string, price ModelPrice) {
+	priceOverridesMu.Lock()
+	defer priceOverridesMu.Unlock()
+	if priceOverrides[provider] == nil {
+		priceOverrides[provider] = make(map[string]ModelPrice)
+	}
+	priceOverrides[provider][model] = price
+}
+
+// LoadPricing sets catalog prices from JSON of the form
+// {"openai": {"gpt-4o": {"input": 0.0025, "cached_input": 0.00125, "output": 0.01}}}
+func LoadPricing(r io.Reader) error {
+	var prices map[ProviderType]map[string]ModelPrice
+	if err := json.NewDecoder(r).Decode(&prices); err != nil {
+		return fmt.Errorf("invalid pricing: %w", err)
+	}
+	for provider, models := range prices {
+		for model, price := range models {
+			SetModelPrice(provider, model, price)
+		}
+	}
+	return nil
+}
+
+// CatalogPrice returns the catalog price of a model: a price set with
+// SetModelPrice or LoadPricing, or else the bundled list price
+func CatalogPrice(provider ProviderType, model string) (ModelPrice, bool) {
+	priceOverridesMu.RLock()
+	price, ok := priceOverrides[provider][model]
+	priceOverridesMu.RUnlock()
+	if ok {
+		return price, true
+	}
+
+	if provider == Vertex {
+		provider = Gemini
+	}
+	var match string
+	for prefix, candidate := range bundledPricing[provider] {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(match) {
+			match, price, ok = prefix, candidate, true
+		}
+	}
+	return price, ok
+}
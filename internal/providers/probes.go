@@ -0,0 +1,164 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// HealthStatus is the overall state of a provider or of gollmkit as a whole
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"
+	HealthDegraded HealthStatus = "degraded" // requests are served, but some keys can't be used or a budget is spent
+	HealthDown     HealthStatus = "down"     // requests can't be served
+)
+
+// Health aggregates key store reachability, key health per provider and
+// budget state, as reported by readiness probes
+type Health struct {
+	Status       HealthStatus               `json:"status"`
+	Time         time.Time                  `json:"time"`
+	ShuttingDown bool                       `json:"shutting_down,omitempty"`
+	KeyStore     KeyStoreHealth             `json:"key_store"`
+	Providers    map[string]*ProviderHealth `json:"providers,omitempty"`
+	Budget       BudgetHealth               `json:"budget"`
+}
+
+// Ready reports whether requests can be served
+func (h *Health) Ready() bool {
+	return h.Status != HealthDown
+}
+
+// KeyStoreHealth reports whether the key store could be read
+type KeyStoreHealth struct {
+	Reachable bool          `json:"reachable"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ProviderHealth holds the key health of a single provider. A key is usable
+// if it is enabled, healthy, not expired and within its cost limit.
+type ProviderHealth struct {
+	Status         HealthStatus `json:"status"`
+	TotalKeys      int          `json:"total_keys"`
+	UsableKeys     int          `json:"usable_keys"`
+	UnhealthyKeys  []string     `json:"unhealthy_keys,omitempty"`
+	OverBudgetKeys []string     `json:"over_budget_keys,omitempty"`
+}
+
+// BudgetHealth compares today's cost with global.daily_cost_limit
+type BudgetHealth struct {
+	DailyCost      float64 `json:"daily_cost"`
+	DailyCostLimit float64 `json:"daily_cost_limit,omitempty"`
+	Exceeded       bool    `json:"exceeded,omitempty"`
+}
+
+// Health checks the key store, the keys of every provider and the daily
+// budget. It is down if the key store can't be read, no provider has a usable
+// key or Shutdown was called, and degraded if some keys can't be used or the
+// daily cost limit is reached.
+func (p *BaseProvider) Health(ctx context.Context) *Health {
+	health := &Health{Time: time.Now(), Status: HealthOK}
+
+	p.callsMu.Lock()
+	health.ShuttingDown = p.closing
+	p.callsMu.Unlock()
+
+	start := time.Now()
+	snapshot, err := p.Snapshot(ctx)
+	health.KeyStore.Latency = time.Since(start)
+	if err != nil {
+		health.KeyStore.Error = err.Error()
+		health.Status = HealthDown
+		return health
+	}
+	health.KeyStore.Reachable = true
+
+	cfg := p.getConfig()
+	health.Budget = BudgetHealth{
+		DailyCost:      snapshot.DailyCost,
+		DailyCostLimit: cfg.Global.DailyCostLimit,
+		Exceeded:       cfg.Global.DailyCostLimit > 0 && snapshot.DailyCost >= cfg.Global.DailyCostLimit,
+	}
+
+	health.Providers = make(map[string]*ProviderHealth, len(snapshot.Providers))
+	usableProviders := 0
+	for name, state := range snapshot.Providers {
+		providerHealth := &ProviderHealth{}
+		now := time.Now()
+		for _, key := range cfg.Providers[name].APIKeys {
+			providerHealth.TotalKeys++
+			stats := state.Statistics.KeyStats[key.Name]
+			switch {
+			case !key.Enabled || key.IsExpired(now):
+			case stats == nil || !stats.Healthy || stats.Deleted:
+				providerHealth.UnhealthyKeys = append(providerHealth.UnhealthyKeys, key.Name)
+			case key.CostLimit > 0 && stats.Usage != nil && stats.Usage.DailyCost >= key.CostLimit:
+				providerHealth.OverBudgetKeys = append(providerHealth.OverBudgetKeys, key.Name)
+			default:
+				providerHealth.UsableKeys++
+			}
+		}
+		sort.Strings(providerHealth.UnhealthyKeys)
+		sort.Strings(providerHealth.OverBudgetKeys)
+
+		switch {
+		case providerHealth.UsableKeys == 0:
+			providerHealth.Status = HealthDown
+		case providerHealth.UsableKeys < providerHealth.TotalKeys:
+			providerHealth.Status = HealthDegraded
+		default:
+			providerHealth.Status = HealthOK
+		}
+		if providerHealth.UsableKeys > 0 {
+			usableProviders++
+		}
+		if providerHealth.Status != HealthOK {
+			health.Status = HealthDegraded
+		}
+		health.Providers[name] = providerHealth
+	}
+
+	if health.Budget.Exceeded {
+		health.Status = HealthDegraded
+	}
+	if health.ShuttingDown || (len(health.Providers) > 0 && usableProviders == 0) {
+		health.Status = HealthDown
+	}
+	return health
+}
+
+// LivenessHandler returns an http.Handler for liveness probes. It answers
+// 200 as long as the process serves HTTP; use ReadinessHandler to take the
+// instance out of rotation instead of restarting it.
+func (p *BaseProvider) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, http.StatusOK, map[string]HealthStatus{"status": HealthOK})
+	})
+}
+
+// ReadinessHandler returns an http.Handler for readiness probes. It answers
+// 200 with the Health as JSON if requests can be served, including when
+// degraded, and 503 otherwise.
+func (p *BaseProvider) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := p.Health(r.Context())
+		status := http.StatusOK
+		if !health.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeProbe(w, status, health)
+	})
+}
+
+// writeProbe writes body as the JSON response of a probe
+func writeProbe(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
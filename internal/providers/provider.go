@@ -2,16 +2,24 @@
 package providers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
+	"reflect"
+	"sync"
+	"time"
 
+	"github.com/gollmkit/gollmkit/internal/analytics"
 	"github.com/gollmkit/gollmkit/internal/auth"
 	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+	"github.com/gollmkit/gollmkit/internal/messages"
+	"github.com/gollmkit/gollmkit/internal/pii"
+	"github.com/gollmkit/gollmkit/internal/redact"
+	"github.com/gollmkit/gollmkit/internal/tenant"
 )
 
 // Common errors
@@ -20,6 +28,7 @@ var (
 	ErrKeyRotation    = errors.New("key rotation failed")
 	ErrInvalidConfig  = errors.New("invalid configuration")
 	ErrResponseFormat = errors.New("invalid response format")
+	ErrTimeout        = errors.New("request timed out")
 )
 
 // ProviderType identifies the LLM provider
@@ -29,13 +38,12 @@ const (
 	OpenAI    ProviderType = "openai"
 	Anthropic ProviderType = "anthropic"
 	Gemini    ProviderType = "gemini"
+	XAI       ProviderType = "xai"
+	DeepSeek  ProviderType = "deepseek"
 )
 
 // Message represents a chat message
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+type Message = messages.Message
 
 // RequestOptions contains common options for LLM requests
 type RequestOptions struct {
@@ -45,16 +53,160 @@ type RequestOptions struct {
 	Temperature float32      `json:"temperature,omitempty"`
 	TopP        float32      `json:"top_p,omitempty"`
 	Stop        []string     `json:"stop,omitempty"`
-	Stream      bool         `json:"stream,omitempty"`
+
+	// Set marks MaxTokens, Temperature and TopP as set even when zero. Zero
+	// values are otherwise replaced by the model or provider defaults. See
+	// WithTemperature, WithTopP and WithMaxTokens.
+	Set OptionField `json:"set,omitempty"`
+
+	// Stream is set by ChatStream and InvokeStream; Chat and Invoke always
+	// request the complete response
+	Stream bool `json:"stream,omitempty"`
+
+	// Raw is set by InvokeRaw: the prompt is sent to the provider's
+	// completions endpoint as is, without chat formatting
+	Raw bool `json:"raw,omitempty"`
+
+	// Suffix is set by CodeComplete: the text after the cursor, for the model
+	// to fill in what goes between the prompt and it
+	Suffix string `json:"suffix,omitempty"`
+
+	// ReasoningEffort ("minimal", "low", "medium" or "high") bounds how much
+	// reasoning models such as OpenAI's o-series think before answering
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
+	// MaxCompletionTokens caps the completion including reasoning tokens. It
+	// replaces MaxTokens for reasoning models, which don't accept max_tokens.
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+
+	// ThinkingBudget enables Anthropic extended thinking with that many budget
+	// tokens (at least 1024 and less than MaxTokens). The thinking is returned
+	// in CompletionResponse.Thinking.
+	ThinkingBudget int `json:"thinking_budget,omitempty"`
+
+	// SafetySettings override the Gemini and Vertex safety thresholds
+	// configured for the provider
+	SafetySettings []config.SafetySetting `json:"safety_settings,omitempty"`
+
+	// BetaFeatures are sent in the anthropic-beta header of Anthropic
+	// requests, in addition to the configured beta_features
+	BetaFeatures []string `json:"beta_features,omitempty"`
+
+	// Tools are the functions the model may call and ToolChoice whether it
+	// must: ToolChoiceAuto (default), ToolChoiceNone, ToolChoiceRequired or
+	// the name of a tool. Supported by OpenAI, Anthropic, xAI, DeepSeek and
+	// registered backends.
+	Tools      []Tool `json:"tools,omitempty"`
+	ToolChoice string `json:"tool_choice,omitempty"`
+
+	// Extra holds raw fields merged into the request body of each provider,
+	// for provider features gollmkit doesn't support yet, e.g.
+	// {OpenAI: {"parallel_tool_calls": false}}. Nested objects are merged,
+	// other values replace gollmkit's, and nil removes a field.
+	Extra map[ProviderType]map[string]interface{} `json:"extra,omitempty"`
+
+	// N requests that many candidate completions (OpenAI n, Gemini
+	// candidateCount). They are returned in CompletionResponse.Choices.
+	N int `json:"n,omitempty"`
+
+	// ConversationID scopes the PII vault mapping when a vault is configured
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	// MaxResponseBytes and MaxResponseTokens cap the delivered response; longer
+	// output is truncated and FinishReason is set to FinishReasonLengthCap
+	MaxResponseBytes  int `json:"max_response_bytes,omitempty"`
+	MaxResponseTokens int `json:"max_response_tokens,omitempty"`
+
+	// Timeout bounds the provider call independently of the caller's context.
+	// Defaults to the provider's timeout, then global.key_timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// IncludeResponseInfo attaches the raw body, status, headers and timing
+	// breakdown of the provider response to CompletionResponse.ResponseInfo
+	IncludeResponseInfo bool `json:"include_response_info,omitempty"`
+
+	// TenantID attributes the request to a tenant, overriding tenant.WithTenant on the context
+	TenantID string `json:"tenant_id,omitempty"`
+
+	// KeyFilter restricts rotation to keys carrying all of these tags, e.g.
+	// {"team": "search"}, to isolate teams sharing a deployment
+	KeyFilter map[string]string `json:"key_filter,omitempty"`
+
+	// KeyName pins the request to the named key. Messages with attachments
+	// set it to the key that uploaded the files, as files are only visible
+	// to the account or project that owns them.
+	KeyName string `json:"key_name,omitempty"`
+
+	// RequestID identifies the request in logs, analytics events, errors and
+	// the response. Defaults to the ID of the context (WithRequestID), then a
+	// new random ID.
+	RequestID string `json:"request_id,omitempty"`
+
+	// DryRun makes Chat and Invoke resolve the provider, model and key and
+	// return the plan in CompletionResponse.Plan without calling the provider
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// RawMessages sends messages exactly as given. By default empty messages
+	// are dropped, consecutive messages of a role merged and the history
+	// adapted to the role rules of the provider, see NormalizeMessages.
+	RawMessages bool `json:"raw_messages,omitempty"`
 }
 
-// CompletionResponse represents a unified response format
+// CompletionResponse represents a unified response format. Content and
+// FinishReason are those of the first choice.
 type CompletionResponse struct {
-	Content      string                 `json:"content"`
-	Model        string                 `json:"model"`
-	Usage        TokenUsage             `json:"usage"`
-	ProviderName string                 `json:"provider_name"`
+	Content      string     `json:"content"`
+	Model        string     `json:"model"`
+	Usage        TokenUsage `json:"usage"`
+	ProviderName string     `json:"provider_name"`
+
+	// FinishReason is why generation stopped, normalized across providers
+	// (FinishReasonStop, FinishReasonLength, FinishReasonContentFilter, ...)
+	FinishReason string `json:"finish_reason,omitempty"`
+
+	// Choices holds every candidate completion, in order
+	Choices []Choice `json:"choices,omitempty"`
+
+	// Thinking holds the extended thinking blocks preceding the answer in Content
+	Thinking []ThinkingBlock `json:"thinking,omitempty"`
+
+	// ToolCalls are the tools the first choice calls, with FinishReason
+	// FinishReasonToolCalls. Their results are sent back as messages with
+	// role "tool" and the ToolCallID of the call.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// Citations attribute spans of Content to the sources the provider
+	// grounded them in: web search results, documents or retrieved files
+	Citations []Citation `json:"citations,omitempty"`
+
+	// RequestID is the ID gollmkit assigned to the request and
+	// ProviderRequestID the one the provider did, for cross-referencing
+	// support tickets. Both are also set in Metadata.
+	RequestID         string `json:"request_id,omitempty"`
+	ProviderRequestID string `json:"provider_request_id,omitempty"`
+
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	ResponseInfo *ResponseInfo          `json:"response_info,omitempty"`
+
+	// Plan is set instead of a completion for RequestOptions.DryRun requests
+	Plan *DryRunPlan `json:"plan,omitempty"`
+}
+
+// Choice is one candidate completion
+type Choice struct {
+	Index        int    `json:"index"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+}
+
+// ThinkingBlock is a block of extended thinking. Redacted blocks carry only
+// the encrypted Data; Signature and Data must be passed back unchanged when
+// the conversation continues.
+type ThinkingBlock struct {
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  bool   `json:"redacted,omitempty"`
+	Data      string `json:"data,omitempty"`
 }
 
 // TokenUsage tracks token usage for billing
@@ -62,6 +214,19 @@ type TokenUsage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+
+	// CachedTokens are prompt tokens read from the provider's prompt cache,
+	// already included in PromptTokens and billed at the cached input price
+	CachedTokens int `json:"cached_tokens,omitempty"`
+
+	// ReasoningTokens are the reasoning or thinking tokens of reasoning
+	// models, already included in CompletionTokens. Anthropic doesn't report
+	// them, so for Claude they are estimated from the returned thinking.
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+
+	// Estimated is set when the tokens were counted locally: for streams that
+	// don't report usage, or that were left at a response cap before they did
+	Estimated bool `json:"estimated,omitempty"`
 }
 
 // DefaultOptions returns default RequestOptions for a provider
@@ -88,6 +253,33 @@ func DefaultOptions(provider ProviderType) RequestOptions {
 			Temperature: 0.7,
 			MaxTokens:   2000,
 		}
+	case XAI:
+		return RequestOptions{
+			Provider:    XAI,
+			Model:       "grok-3-mini",
+			Temperature: 0.7,
+			MaxTokens:   2000,
+		}
+	case DeepSeek:
+		return RequestOptions{
+			Provider:    DeepSeek,
+			Model:       "deepseek-chat",
+			Temperature: 0.7,
+			MaxTokens:   2000,
+		}
+	case Vertex:
+		return RequestOptions{
+			Provider:    Vertex,
+			Model:       "gemini-2.0-flash",
+			Temperature: 0.7,
+			MaxTokens:   2000,
+		}
+	case Mock:
+		return RequestOptions{
+			Provider:  Mock,
+			Model:     "mock",
+			MaxTokens: 2000,
+		}
 	default:
 		return RequestOptions{
 			Provider:    OpenAI,
@@ -106,10 +298,26 @@ type LLMProvider interface {
 
 // BaseProvider contains common functionality for all providers
 type BaseProvider struct {
+	mu        sync.RWMutex
 	config    *config.Config
 	rotator   *auth.KeyRotator
 	validator *auth.KeyValidator
-	client    *http.Client
+	client    *http.Client                  // injected with SetHTTPClient, nil if unset
+	clients   map[ProviderType]*http.Client // one client per provider, created on first use
+	tracker   *analytics.Tracker
+	logger    logging.Logger
+
+	inFlightMu sync.Mutex
+	inFlight   map[ProviderType]int // provider -> requests currently being sent
+
+	healthMu sync.Mutex
+	health   *healthChecks // running health checks, nil if stopped
+
+	callsMu sync.Mutex
+	closing bool           // Shutdown was called, new calls are rejected
+	calls   sync.WaitGroup // calls in progress, including open streams
+
+	deprecations sync.Map // "provider/model" of remapped models already logged
 }
 
 // NewBaseProvider creates a new base provider with common functionality
@@ -118,17 +326,47 @@ func NewBaseProvider(cfg *config.Config, rotator *auth.KeyRotator, validator *au
 		config:    cfg,
 		rotator:   rotator,
 		validator: validator,
-		client:    &http.Client{},
+		clients:   make(map[ProviderType]*http.Client),
+		inFlight:  make(map[ProviderType]int),
 	}
 }
 
+// getConfig returns the current configuration
+func (p *BaseProvider) getConfig() *config.Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// UpdateConfig swaps in a new configuration without interrupting in-flight
+// requests. New or changed keys are stored in the key store first.
+func (p *BaseProvider) UpdateConfig(ctx context.Context, cfg *config.Config) error {
+	if err := p.rotator.UpdateConfig(ctx, cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !reflect.DeepEqual(cfg.Global.HTTP, p.config.Global.HTTP) || compressionChanged(p.config, cfg) {
+		p.closeIdleConnections() // clients with the new settings are created on next use
+	}
+	p.config = cfg
+	return nil
+}
+
+// NewKeyManager creates a key manager whose runtime key changes are applied
+// with UpdateConfig
+func (p *BaseProvider) NewKeyManager() *auth.KeyManager {
+	return auth.NewKeyManager(p.rotator, p)
+}
+
 // validateModel checks if the model is valid for the given provider
 func (p *BaseProvider) validateModel(provider ProviderType, model string) error {
 	if model == "" {
 		return fmt.Errorf("%w: model name cannot be empty", ErrInvalidModel)
 	}
 
-	providerCfg, err := p.config.GetProvider(string(provider))
+	providerCfg, err := p.getConfig().GetProvider(string(provider))
 	if err != nil {
 		return fmt.Errorf("%w: %s provider not configured", ErrInvalidConfig, provider)
 	}
@@ -139,19 +377,134 @@ func (p *BaseProvider) validateModel(provider ProviderType, model string) error
 	return nil
 }
 
+// logDeprecation logs the first request for a deprecated model that was remapped
+func (p *BaseProvider) logDeprecation(provider ProviderType, model, replacement string) {
+	if _, logged := p.deprecations.LoadOrStore(string(provider)+"/"+model, true); !logged {
+		p.log().Warn("gollmkit: model is deprecated", "provider", provider, "model", model, "replacement", replacement)
+	}
+}
+
 // getNextKey gets the next valid API key using the rotator
-func (p *BaseProvider) getNextKey(ctx context.Context, provider ProviderType) (*auth.KeySelection, error) {
-	key, err := p.rotator.GetNextKey(ctx, string(provider))
+func (p *BaseProvider) getNextKey(ctx context.Context, provider ProviderType, opts ...auth.SelectOption) (*auth.KeySelection, error) {
+	key, err := p.rotator.GetNextKey(ctx, string(provider), opts...)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrKeyRotation, err)
+		return nil, fmt.Errorf("%w: %w", ErrKeyRotation, err)
 	}
 	return key, nil
 }
 
-// recordUsage records token usage for the key
-func (p *BaseProvider) recordUsage(ctx context.Context, provider ProviderType, keyName string, usage TokenUsage) error {
-	cost := float64(usage.TotalTokens) * 0.001 // Default cost per 1k tokens
-	return p.rotator.RecordUsage(ctx, string(provider), keyName, usage.TotalTokens, cost)
+// keyRestrictions returns the key selection options of a request: keys must
+// allow its model and carry the tags of its key filter, and be the pinned key if any
+func keyRestrictions(opts RequestOptions) []auth.SelectOption {
+	restrictions := []auth.SelectOption{auth.ForModel(opts.Model), auth.WithTags(opts.KeyFilter)}
+	if opts.KeyName != "" {
+		restrictions = append(restrictions, auth.OnlyKeys(opts.KeyName))
+	}
+	return restrictions
+}
+
+// SetTracker sets the analytics tracker that receives an event for every request
+func (p *BaseProvider) SetTracker(tracker *analytics.Tracker) {
+	p.tracker = tracker
+}
+
+// SetLogger sets the logger of the provider and of its key rotator and
+// validator. Requests are logged at debug level and failures as warnings;
+// API keys are never logged. Without a logger slog.Default() is used.
+func (p *BaseProvider) SetLogger(logger logging.Logger) {
+	p.logger = logger
+	p.rotator.SetLogger(logger)
+	if p.validator != nil {
+		p.validator.SetLogger(logger)
+	}
+}
+
+// log returns the logger of the provider
+func (p *BaseProvider) log() logging.Logger {
+	return logging.OrDefault(p.logger)
+}
+
+// CalculateCost calculates the cost of a request from the model's configured pricing
+func (p *BaseProvider) CalculateCost(provider ProviderType, model string, usage TokenUsage) float64 {
+	if providerCfg, err := p.getConfig().GetProvider(string(provider)); err == nil {
+		if modelCfg, err := providerCfg.GetModelByName(model); err == nil {
+			if price, ok := configuredPrice(modelCfg); ok {
+				return price.Cost(usage)
+			}
+		}
+	}
+	if price, ok := CatalogPrice(provider, model); ok {
+		return price.Cost(usage)
+	}
+	return float64(usage.TotalTokens) * 0.001 // Default cost per 1k tokens
+}
+
+// recordUsage records token usage for the key. It is written to the key store
+// in the background, and failing to record it never fails the request.
+func (p *BaseProvider) recordUsage(ctx context.Context, provider ProviderType, keyName, model string, usage TokenUsage) {
+	p.recordCost(ctx, provider, keyName, model, usage.TotalTokens, p.CalculateCost(provider, model, usage))
+}
+
+// recordCost records tokens and their cost for the key, like recordUsage
+func (p *BaseProvider) recordCost(ctx context.Context, provider ProviderType, keyName, model string, tokens int, cost float64) {
+	if err := p.rotator.RecordUsage(ctx, string(provider), keyName, model, tokens, cost); err != nil {
+		p.log().Warn("gollmkit: failed to record usage",
+			"provider", provider, "model", model, "key", keyName, "error", err)
+	}
+}
+
+// trackRequest records an analytics event for a finished request
+func (p *BaseProvider) trackRequest(opts RequestOptions, key *auth.KeySelection, start time.Time, resp *CompletionResponse, err error) {
+	if p.tracker != nil {
+		p.trackEvent(p.requestEvent(opts, key, start, resp, err))
+	}
+}
+
+// requestEvent returns the analytics event of a finished request
+func (p *BaseProvider) requestEvent(opts RequestOptions, key *auth.KeySelection, start time.Time, resp *CompletionResponse, err error) analytics.Event {
+	event := analytics.Event{
+		Time:      start,
+		Provider:  string(opts.Provider),
+		Model:     opts.Model,
+		KeyName:   key.KeyName,
+		Tenant:    opts.TenantID,
+		Latency:   time.Since(start),
+		RequestID: opts.RequestID,
+	}
+	if err != nil {
+		event.Error = err.Error()
+		event.ErrorCode = string(CodeOf(err))
+		event.ProviderRequestID = ErrorInfoOf(err).ProviderRequestID
+	} else {
+		event.ProviderRequestID = resp.ProviderRequestID
+		event.InputTokens = resp.Usage.PromptTokens
+		event.OutputTokens = resp.Usage.CompletionTokens
+		event.Cost = p.CalculateCost(opts.Provider, opts.Model, resp.Usage)
+	}
+	return event
+}
+
+// trackEvent records an analytics event if a tracker is set
+func (p *BaseProvider) trackEvent(event analytics.Event) {
+	if p.tracker == nil {
+		return
+	}
+
+	// Analytics are best-effort and must not fail the request
+	_ = p.tracker.Record(event)
+}
+
+// logRequest logs a summary of a request at debug level, or a warning if it failed
+func (p *BaseProvider) logRequest(opts RequestOptions, key *auth.KeySelection, start time.Time, resp *CompletionResponse, err error) {
+	if err != nil {
+		p.log().Warn("gollmkit: request failed",
+			"request_id", opts.RequestID, "provider", opts.Provider, "model", opts.Model, "key", key.KeyName,
+			"latency", time.Since(start), "code", CodeOf(err), "error", err)
+		return
+	}
+	p.log().Debug("gollmkit: request",
+		"request_id", opts.RequestID, "provider", opts.Provider, "model", opts.Model, "key", key.KeyName,
+		"latency", time.Since(start), "tokens", resp.Usage.TotalTokens, "cost", p.CalculateCost(opts.Provider, opts.Model, resp.Usage))
 }
 
 // recordError records an error for a key
@@ -161,36 +514,119 @@ func (p *BaseProvider) recordError(ctx context.Context, provider ProviderType, k
 	}
 }
 
+// beginRequest marks a request to provider as in flight and returns a func that ends it
+func (p *BaseProvider) beginRequest(provider ProviderType) func() {
+	p.inFlightMu.Lock()
+	p.inFlight[provider]++
+	p.inFlightMu.Unlock()
+
+	return func() {
+		p.inFlightMu.Lock()
+		p.inFlight[provider]--
+		p.inFlightMu.Unlock()
+	}
+}
+
+// InFlight returns the number of requests currently being sent to provider
+func (p *BaseProvider) InFlight(provider ProviderType) int {
+	p.inFlightMu.Lock()
+	defer p.inFlightMu.Unlock()
+	return p.inFlight[provider]
+}
+
+// recordLatency records the latency of a successful call started at start
+func (p *BaseProvider) recordLatency(provider ProviderType, model string, start time.Time) {
+	p.rotator.RecordLatency(string(provider), model, time.Since(start))
+}
+
 // UnifiedProvider is the unified LLM provider that handles all provider types
 type UnifiedProvider struct {
 	*BaseProvider
+	piiVault *pii.Vault
+	tenants  *tenant.Manager
+	tokens   *googleTokenCache
+
+	// pinned restricts requests to one provider, see OpenAIProvider
+	pinned ProviderType
+
+	backends backends // of providers added with Register
+
+	middlewareMu     sync.RWMutex
+	middleware       []Middleware
+	streamMiddleware []StreamMiddleware
+	batchMiddleware  []BatchMiddleware
 }
 
 // NewUnifiedProvider creates a new unified LLM provider
 func NewUnifiedProvider(cfg *config.Config, rotator *auth.KeyRotator, validator *auth.KeyValidator) *UnifiedProvider {
 	return &UnifiedProvider{
 		BaseProvider: NewBaseProvider(cfg, rotator, validator),
+		tokens:       newGoogleTokenCache(),
 	}
 }
 
+// SetTenantManager enables per-tenant quotas, rate limits and usage attribution.
+// The tenant of a request is RequestOptions.TenantID or tenant.FromContext.
+func (p *UnifiedProvider) SetTenantManager(m *tenant.Manager) {
+	p.tenants = m
+}
+
+// SetPIIVault enables PII tokenization: detected PII is replaced by vault tokens
+// before messages reach any provider and restored only in the returned
+// response. Streamed deltas are restored as they arrive. Batch requests and
+// the inputs of embeddings, moderation, image and speech requests are
+// tokenized too; audio to transcribe is refused, as its PII can't be replaced.
+func (p *UnifiedProvider) SetPIIVault(vault *pii.Vault) {
+	p.piiVault = vault
+}
+
 // Invoke sends a single prompt to the LLM
 func (p *UnifiedProvider) Invoke(ctx context.Context, prompt string, opts RequestOptions) (*CompletionResponse, error) {
 	if opts.Provider == "" {
-		opts.Provider = OpenAI
+		opts.Provider = p.defaultProvider()
 	}
 
 	messages := []Message{{Role: "user", Content: prompt}}
 	return p.Chat(ctx, messages, opts)
 }
 
+// defaultProvider picks the provider for requests that don't specify one
+func (p *UnifiedProvider) defaultProvider() ProviderType {
+	if p.pinned != "" {
+		return p.pinned
+	}
+	global := p.getConfig().Global
+	if global.ProviderRouting == config.RotationLatencyOptimized && len(global.FallbackChain) > 0 {
+		if provider, err := p.rotator.FastestProvider(global.FallbackChain); err == nil {
+			return ProviderType(provider)
+		}
+	}
+	return OpenAI
+}
+
 // mergeOptions merges request options with configuration and defaults
 func (p *UnifiedProvider) mergeOptions(provider ProviderType, opts RequestOptions) (RequestOptions, error) {
+	cfg := p.getConfig()
+
+	// Aliases naming a provider select it
+	if alias, ok := cfg.Global.GetModelAlias(opts.Model); ok {
+		if alias.Provider != "" {
+			provider = ProviderType(alias.Provider)
+		}
+		opts.Model = alias.Model
+	}
+
 	// Get provider configuration
-	providerCfg, err := p.config.GetProvider(string(provider))
+	providerCfg, err := cfg.GetProvider(string(provider))
 	if err != nil {
 		return opts, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
 	}
 
+	if replacement, ok := providerCfg.RemapModel(opts.Model); ok {
+		p.logDeprecation(provider, opts.Model, replacement)
+		opts.Model = replacement
+	}
+
 	// Start with a clean options struct containing what we know
 	result := RequestOptions{
 		Provider: provider,
@@ -200,7 +636,43 @@ func (p *UnifiedProvider) mergeOptions(provider ProviderType, opts RequestOption
 		Temperature: opts.Temperature,
 		TopP:        opts.TopP,
 		Stop:        opts.Stop,
+		Set:         opts.Set,
 		Stream:      opts.Stream,
+		Raw:         opts.Raw,
+		Suffix:      opts.Suffix,
+		N:           opts.N,
+
+		ReasoningEffort:     opts.ReasoningEffort,
+		MaxCompletionTokens: opts.MaxCompletionTokens,
+		ThinkingBudget:      opts.ThinkingBudget,
+		Extra:               opts.Extra,
+		SafetySettings:      opts.SafetySettings,
+		BetaFeatures:        opts.BetaFeatures,
+		Tools:               opts.Tools,
+		ToolChoice:          opts.ToolChoice,
+
+		ConversationID:    opts.ConversationID,
+		MaxResponseBytes:  opts.MaxResponseBytes,
+		MaxResponseTokens: opts.MaxResponseTokens,
+		Timeout:           opts.Timeout,
+
+		IncludeResponseInfo: opts.IncludeResponseInfo,
+		TenantID:            opts.TenantID,
+		KeyFilter:           opts.KeyFilter,
+		KeyName:             opts.KeyName,
+		RequestID:           opts.RequestID,
+		RawMessages:         opts.RawMessages,
+	}
+
+	if len(result.SafetySettings) == 0 {
+		result.SafetySettings = providerCfg.SafetySettings
+	}
+
+	if result.Timeout == 0 {
+		result.Timeout, err = providerCfg.GetTimeout(&cfg.Global)
+		if err != nil {
+			return opts, fmt.Errorf("%w: invalid timeout for %s: %v", ErrInvalidConfig, provider, err)
+		}
 	}
 
 	// Get model configuration if specified
@@ -227,7 +699,7 @@ func (p *UnifiedProvider) mergeOptions(provider ProviderType, opts RequestOption
 		if result.Model == "" {
 			result.Model = modelCfg.Name
 		}
-		if result.MaxTokens == 0 {
+		if !result.IsSet(FieldMaxTokens) {
 			result.MaxTokens = modelCfg.MaxTokens
 		}
 	}
@@ -239,299 +711,301 @@ func (p *UnifiedProvider) mergeOptions(provider ProviderType, opts RequestOption
 	if result.Model == "" {
 		result.Model = defaults.Model
 	}
-	if result.MaxTokens == 0 {
+	if !result.IsSet(FieldMaxTokens) {
 		result.MaxTokens = defaults.MaxTokens
 	}
-	if result.Temperature == 0 {
+	if !result.IsSet(FieldTemperature) {
 		result.Temperature = defaults.Temperature
 	}
-	if result.TopP == 0 {
+	if !result.IsSet(FieldTopP) {
 		result.TopP = defaults.TopP
 	}
 
 	return result, nil
 }
 
-// Chat sends a series of messages to the LLM
+// Chat sends a series of messages to the LLM through the middleware added with Use
 func (p *UnifiedProvider) Chat(ctx context.Context, messages []Message, opts RequestOptions) (*CompletionResponse, error) {
-	if opts.Provider == "" {
-		opts.Provider = OpenAI
-	}
-
-	// Merge options with configuration and defaults
-	mergedOpts, err := p.mergeOptions(opts.Provider, opts)
+	ctx = withRequestID(ctx, &opts)
+	endCall, err := p.beginCall()
 	if err != nil {
-		return nil, err
-	}
-	opts = mergedOpts
-
-	if err := p.validateModel(opts.Provider, opts.Model); err != nil {
-		return nil, err
+		return nil, errorWithRequestID(err, opts.RequestID)
 	}
+	defer endCall()
 
-	key, err := p.getNextKey(ctx, opts.Provider)
-	if err != nil {
-		return nil, err
-	}
-
-	switch opts.Provider {
-	case OpenAI:
-		return p.callOpenAI(ctx, messages, opts, key)
-	case Anthropic:
-		return p.callAnthropic(ctx, messages, opts, key)
-	case Gemini:
-		return p.callGemini(ctx, messages, opts, key)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", opts.Provider)
-	}
+	resp, err := p.chatHandler()(ctx, messages, opts)
+	return resp, errorWithRequestID(err, opts.RequestID)
 }
 
-func (p *UnifiedProvider) callOpenAI(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
-	reqBody := map[string]interface{}{
-		"model":       opts.Model,
-		"messages":    messages,
-		"max_tokens":  opts.MaxTokens,
-		"temperature": opts.Temperature,
-		"top_p":       opts.TopP,
-		"stop":        opts.Stop,
-		"stream":      opts.Stream,
+// chat sends a series of messages to the LLM
+func (p *UnifiedProvider) chat(ctx context.Context, messages []Message, opts RequestOptions) (*CompletionResponse, error) {
+	if opts.DryRun {
+		return p.dryRun(ctx, messages, opts)
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	opts, err := p.prepareRequest(ctx, messages, opts)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	opts.Stream = false // use ChatStream for streamed responses
+	messages, err = p.prepareAttachments(NormalizeMessages(messages, opts), opts.Provider)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+key.Key)
+	// PII is replaced by vault tokens before the request leaves and restored in the response
+	var conversationID string
+	if p.piiVault != nil {
+		var forget func()
+		conversationID, forget = p.vaultConversation(opts)
+		defer forget()
+		if messages, err = p.tokenizeMessages(conversationID, messages, opts.Provider); err != nil {
+			return nil, err
+		}
+	}
 
-	resp, err := p.client.Do(req)
+	key, err := p.getNextKey(ctx, opts.Provider, keyRestrictions(opts)...)
 	if err != nil {
-		p.recordError(ctx, OpenAI, key.KeyName, err)
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer func() { key.Release() }()
+
+	var resp *CompletionResponse
+	var failedKeys []string
+	for {
+		resp, err = p.dispatch(ctx, messages, opts, key)
+		if err == nil {
+			break
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("OpenAI API error: %d", resp.StatusCode)
-		p.recordError(ctx, OpenAI, key.KeyName, err)
+		next := p.failoverKey(ctx, opts, key, &failedKeys, err)
+		if next == nil {
+			break
+		}
+		key = next
+	}
+	if err != nil {
 		return nil, err
 	}
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	if p.piiVault != nil {
+		if err := p.detokenizeResponse(conversationID, resp); err != nil {
+			return nil, err
+		}
 	}
 
-	choices, ok := result["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		return nil, fmt.Errorf("%w: missing choices in response", ErrResponseFormat)
+	if p.tenants != nil && opts.TenantID != "" {
+		p.tenants.Record(opts.TenantID, resp.Usage.TotalTokens, p.CalculateCost(opts.Provider, opts.Model, resp.Usage), time.Now())
 	}
 
-	usage, ok := result["usage"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("%w: missing usage in response", ErrResponseFormat)
-	}
+	applyResponseCap(resp, opts)
+	return resp, nil
+}
 
-	tokenUsage := TokenUsage{
-		PromptTokens:     int(usage["prompt_tokens"].(float64)),
-		CompletionTokens: int(usage["completion_tokens"].(float64)),
-		TotalTokens:      int(usage["total_tokens"].(float64)),
+// prepareRequest merges opts with configuration and defaults, validates the
+// model and request size and checks the tenant's quota before a request is sent
+func (p *UnifiedProvider) prepareRequest(ctx context.Context, messages []Message, opts RequestOptions) (RequestOptions, error) {
+	opts, err := p.resolveOptions(messages, opts)
+	if err != nil {
+		return opts, err
 	}
 
-	if err := p.recordUsage(ctx, OpenAI, key.KeyName, tokenUsage); err != nil {
-		return nil, err
+	if opts.TenantID == "" {
+		opts.TenantID = tenant.FromContext(ctx)
 	}
-
-	msgContent, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{})["content"].(string)
-	if !ok {
-		return nil, fmt.Errorf("%w: invalid message format in response", ErrResponseFormat)
+	if p.tenants != nil && opts.TenantID != "" {
+		limits := p.getConfig().GetTenantLimits(opts.TenantID)
+		if err := p.tenants.Allow(opts.TenantID, limits, time.Now()); err != nil {
+			return opts, err
+		}
 	}
-
-	return &CompletionResponse{
-		Content:      msgContent,
-		Model:        opts.Model,
-		Usage:        tokenUsage,
-		ProviderName: string(OpenAI),
-		Metadata:     result,
-	}, nil
+	return opts, nil
 }
 
-func (p *UnifiedProvider) callAnthropic(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
-	reqBody := map[string]interface{}{
-		"model":          opts.Model,
-		"messages":       messages,
-		"max_tokens":     opts.MaxTokens,
-		"temperature":    opts.Temperature,
-		"top_p":          opts.TopP,
-		"stop_sequences": opts.Stop,
-		"stream":         opts.Stream,
+// checkPinned rejects requests to providers other than the pinned one
+func (p *UnifiedProvider) checkPinned(provider ProviderType) error {
+	if p.pinned == "" || provider == p.pinned {
+		return nil
 	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
+	return &Error{
+		Code:     CodeBadRequest,
+		Provider: provider,
+		Message:  fmt.Sprintf("%s provider can't send requests to %s", providerDisplayName(p.pinned), providerDisplayName(provider)),
+		Err:      ErrBadRequest,
 	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+// resolveOptions merges opts with configuration and defaults and validates
+// the model and request size
+func (p *UnifiedProvider) resolveOptions(messages []Message, opts RequestOptions) (RequestOptions, error) {
+	if opts.Provider == "" {
+		opts.Provider = p.defaultProvider()
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", key.Key)
-	req.Header.Set("anthropic-version", "2024-01-01")
+	if err := p.checkPinned(opts.Provider); err != nil {
+		return opts, err
+	}
 
-	resp, err := p.client.Do(req)
+	opts, err := p.mergeOptions(opts.Provider, opts)
 	if err != nil {
-		p.recordError(ctx, Anthropic, key.KeyName, err)
-		return nil, err
+		return opts, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("Anthropic API error: %d", resp.StatusCode)
-		p.recordError(ctx, Anthropic, key.KeyName, err)
-		return nil, err
+	// Checked again after merging, as a model alias can select another provider
+	if err := p.checkPinned(opts.Provider); err != nil {
+		return opts, err
 	}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	if err := p.validateModel(opts.Provider, opts.Model); err != nil {
+		return opts, err
 	}
 
-	content, ok := result["content"].([]interface{})
-	if !ok || len(content) == 0 {
-		return nil, fmt.Errorf("%w: missing content in response", ErrResponseFormat)
+	if opts.N > 1 && (opts.Provider == Anthropic || opts.Provider == DeepSeek) {
+		return opts, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  fmt.Sprintf("%s does not support multiple completions (n > 1)", providerDisplayName(opts.Provider)),
+			Err:      ErrBadRequest,
+		}
 	}
 
-	text, ok := content[0].(map[string]interface{})["text"].(string)
-	if !ok {
-		return nil, fmt.Errorf("%w: invalid content format in response", ErrResponseFormat)
+	if len(opts.Tools) > 0 && (opts.Provider == Gemini || opts.Provider == Vertex) {
+		return opts, toolsUnsupportedError(opts.Provider)
 	}
 
-	usage, ok := result["usage"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("%w: missing usage in response", ErrResponseFormat)
+	if opts.Raw && (opts.Provider == Anthropic || opts.Provider == Gemini || opts.Provider == Vertex) {
+		return opts, rawUnsupportedError(opts.Provider)
 	}
 
-	tokenUsage := TokenUsage{
-		PromptTokens:     int(usage["input_tokens"].(float64)),
-		CompletionTokens: int(usage["output_tokens"].(float64)),
-		TotalTokens:      int(usage["input_tokens"].(float64)) + int(usage["output_tokens"].(float64)),
+	if _, raw := completionsURLs[opts.Provider]; raw && opts.Suffix != "" && !fimProviders[opts.Provider] && p.fimTemplate(opts) == "" {
+		return opts, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  fmt.Sprintf("%s does not support fill-in-the-middle without a fim_template for %s", providerDisplayName(opts.Provider), opts.Model),
+			Err:      ErrBadRequest,
+		}
 	}
 
-	if err := p.recordUsage(ctx, Anthropic, key.KeyName, tokenUsage); err != nil {
-		return nil, err
+	if opts.ReasoningEffort != "" && !reasoningEfforts[opts.ReasoningEffort] {
+		return opts, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  fmt.Sprintf("invalid reasoning effort %q, must be minimal, low, medium or high", opts.ReasoningEffort),
+			Err:      ErrBadRequest,
+		}
 	}
 
-	return &CompletionResponse{
-		Content:      text,
-		Model:        opts.Model,
-		Usage:        tokenUsage,
-		ProviderName: string(Anthropic),
-		Metadata:     result,
-	}, nil
-}
-
-func (p *UnifiedProvider) callGemini(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
-	var combinedContent string
-	for _, msg := range messages {
-		role := msg.Role
-		if role == "assistant" {
-			role = "model"
+	if opts.Provider == Anthropic && opts.MaxTokens <= 0 {
+		return opts, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  "Anthropic requires max tokens to be set",
+			Err:      ErrBadRequest,
 		}
-		combinedContent += fmt.Sprintf("%s: %s\n", role, msg.Content)
 	}
 
-	reqBody := map[string]interface{}{
-		"contents": []map[string]interface{}{{
-			"parts": []map[string]interface{}{{
-				"text": combinedContent,
-			}},
-		}},
-		"generationConfig": map[string]interface{}{
-			"temperature":     opts.Temperature,
-			"topP":            opts.TopP,
-			"maxOutputTokens": opts.MaxTokens,
-			"stopSequences":   opts.Stop,
-		},
+	if err := checkThinkingBudget(opts); err != nil {
+		return opts, err
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
+	if opts, err = checkAttachments(messages, opts); err != nil {
+		return opts, err
 	}
 
-	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:generateContent?key=%s",
-		url.PathEscape(opts.Model),
-		url.QueryEscape(key.Key))
-
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	if err := p.checkRequestSize(opts.Provider, messages); err != nil {
+		return opts, err
 	}
+	return opts, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// maxAuthFailoverAttempts bounds how many keys Chat tries when keys are rejected
+const maxAuthFailoverAttempts = 3
 
-	resp, err := p.client.Do(req)
-	if err != nil {
-		p.recordError(ctx, Gemini, key.KeyName, err)
-		return nil, err
+// failoverKey returns the key to retry with after key failed with err, or nil
+// when err isn't an auth error or failover is exhausted. A rejected key is
+// taken out of rotation and added to failedKeys.
+func (p *UnifiedProvider) failoverKey(ctx context.Context, opts RequestOptions, key *auth.KeySelection, failedKeys *[]string, err error) *auth.KeySelection {
+	if !isAuthError(err) || len(*failedKeys)+1 >= maxAuthFailoverAttempts {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("Gemini API error: %d", resp.StatusCode)
-		p.recordError(ctx, Gemini, key.KeyName, err)
-		return nil, err
-	}
+	*failedKeys = append(*failedKeys, key.KeyName)
+	_ = p.rotator.MarkUnhealthy(ctx, string(opts.Provider), key.KeyName)
+	key.Release()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	next, nextErr := p.getNextKey(ctx, opts.Provider, append(keyRestrictions(opts), auth.ExcludeKeys(*failedKeys...))...)
+	if nextErr != nil {
+		return nil // no other key to try, report the auth error
 	}
+	return next
+}
 
-	candidates, ok := result["candidates"].([]interface{})
-	if !ok || len(candidates) == 0 {
-		return nil, fmt.Errorf("%w: missing candidates in response", ErrResponseFormat)
-	}
+// isAuthError reports whether the provider rejected the key itself
+func isAuthError(err error) bool {
+	code := CodeOf(err)
+	return code == CodeAuth || code == CodeForbidden
+}
 
-	content, ok := candidates[0].(map[string]interface{})["content"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("%w: invalid content format in response", ErrResponseFormat)
-	}
+// dispatch sends the request to the provider selected in opts
+func (p *UnifiedProvider) dispatch(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	defer p.beginRequest(opts.Provider)()
+	start := time.Now()
 
-	parts, ok := content["parts"].([]interface{})
-	if !ok || len(parts) == 0 {
-		return nil, fmt.Errorf("%w: missing parts in response", ErrResponseFormat)
+	callerCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
 	}
 
-	text, ok := parts[0].(map[string]interface{})["text"].(string)
-	if !ok {
-		return nil, fmt.Errorf("%w: invalid text format in response", ErrResponseFormat)
+	var resp *CompletionResponse
+	var err error
+	switch opts.Provider {
+	case OpenAI:
+		resp, err = p.callOpenAI(ctx, messages, opts, key)
+	case Anthropic:
+		resp, err = p.callAnthropic(ctx, messages, opts, key)
+	case Gemini:
+		resp, err = p.callGemini(ctx, messages, opts, key)
+	case Vertex:
+		resp, err = p.callVertex(ctx, messages, opts, key)
+	case XAI, DeepSeek:
+		resp, err = p.callOpenAICompatible(ctx, opts.Provider, messages, opts, key)
+	case Mock:
+		resp, err = p.callMock(ctx, messages, opts, key)
+	default:
+		resp, err = p.callRegistered(ctx, messages, opts, key)
 	}
 
-	usage := TokenUsage{
-		PromptTokens:     int(result["usageMetadata"].(map[string]interface{})["promptTokenCount"].(float64)),
-		CompletionTokens: int(result["usageMetadata"].(map[string]interface{})["candidatesTokenCount"].(float64)),
-		TotalTokens:      int(result["usageMetadata"].(map[string]interface{})["totalTokenCount"].(float64)),
+	err = redact.Error(timeoutError(ctx, callerCtx, opts, err), key.Key)
+	redactResponse(resp, key.Key)
+	if resp != nil {
+		resp.setRequestIDs(opts.RequestID, "")
 	}
+	p.trackRequest(opts, key, start, resp, errorWithRequestID(err, opts.RequestID))
+	p.logRequest(opts, key, start, resp, err)
+	return resp, err
+}
 
-	if err := p.recordUsage(ctx, Gemini, key.KeyName, usage); err != nil {
-		return nil, err
+// timeoutError converts err into a timeout error when it was caused by the
+// opts.Timeout deadline on ctx. Our own deadline firing is a timeout of the
+// provider, not a caller cancellation.
+func timeoutError(ctx, callerCtx context.Context, opts RequestOptions, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) || callerCtx.Err() != nil {
+		return err
+	}
+	return &Error{
+		Code:     CodeTimeout,
+		Provider: opts.Provider,
+		Message:  fmt.Sprintf("%s call exceeded %s", providerDisplayName(opts.Provider), opts.Timeout),
+		Err:      ErrTimeout,
 	}
+}
 
-	return &CompletionResponse{
-		Content:      text,
-		Model:        opts.Model,
-		Usage:        usage,
-		ProviderName: string(Gemini),
-		Metadata:     result,
-	}, nil
+// randomID returns a random hex identifier
+func randomID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/logging"
+)
+
+// redirectTransport sends every request to a test server instead of the
+// provider's endpoint
+type redirectTransport struct {
+	base   http.RoundTripper
+	target *url.URL
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return t.base.RoundTrip(req)
+}
+
+// newTestProvider returns an OpenAI provider whose calls are served by
+// handler, through a client built by NewHTTPClient from httpCfg. configure
+// adds to the configuration.
+func newTestProvider(t *testing.T, httpCfg config.HTTPConfig, handler http.HandlerFunc, configure ...func(*config.Builder)) *UnifiedProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	builder := config.New().Provider("openai").AddKey("o1", "sk-test").AddModel("gpt-4o", 0.0025, 0.01).
+		AddModel(DefaultOpenAIEmbeddingModel, 0.00002, 0)
+	for _, fn := range configure {
+		fn(builder)
+	}
+	cfg, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.NewKeyStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewUnifiedProvider(cfg, auth.NewKeyRotator(cfg, store), nil)
+	provider.SetLogger(logging.Discard())
+
+	client, err := NewHTTPClient(httpCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse(server.URL)
+	client.Transport = &redirectTransport{base: client.Transport, target: target}
+	provider.SetHTTPClient(client)
+	return provider
+}
@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// rateLimitHeaders lists the header names carrying rate limit headroom, OpenAI first, Anthropic second
+var rateLimitHeaders = struct {
+	remainingRequests []string
+	remainingTokens   []string
+	resetRequests     []string
+	resetTokens       []string
+}{
+	remainingRequests: []string{"x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining"},
+	remainingTokens:   []string{"x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining"},
+	resetRequests:     []string{"x-ratelimit-reset-requests", "anthropic-ratelimit-requests-reset"},
+	resetTokens:       []string{"x-ratelimit-reset-tokens", "anthropic-ratelimit-tokens-reset"},
+}
+
+// parseRateLimitHeaders extracts the rate limit state from response headers.
+// The second return value is false if the response carries no rate limit headers.
+func parseRateLimitHeaders(h http.Header) (auth.RateLimitState, bool) {
+	now := time.Now()
+	state := auth.RateLimitState{
+		RemainingRequests: -1,
+		RemainingTokens:   -1,
+		UpdatedAt:         now,
+	}
+
+	found := false
+	if v, ok := firstHeader(h, rateLimitHeaders.remainingRequests); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			state.RemainingRequests = n
+			found = true
+		}
+	}
+	if v, ok := firstHeader(h, rateLimitHeaders.remainingTokens); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			state.RemainingTokens = n
+			found = true
+		}
+	}
+	if v, ok := firstHeader(h, rateLimitHeaders.resetRequests); ok {
+		state.ResetRequests = parseResetHeader(v, now)
+	}
+	if v, ok := firstHeader(h, rateLimitHeaders.resetTokens); ok {
+		state.ResetTokens = parseResetHeader(v, now)
+	}
+
+	return state, found
+}
+
+// firstHeader returns the value of the first header present in names
+func firstHeader(h http.Header, names []string) (string, bool) {
+	for _, name := range names {
+		if v := h.Get(name); v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// parseResetHeader parses a reset header given either as a duration
+// (OpenAI, e.g. "6m0s") or as an RFC 3339 timestamp (Anthropic)
+func parseResetHeader(value string, now time.Time) time.Time {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// recordRateLimit feeds provider-reported rate limit headroom into key selection
+func (p *BaseProvider) recordRateLimit(provider ProviderType, keyName string, h http.Header) {
+	if state, ok := parseRateLimitHeaders(h); ok {
+		p.rotator.RecordRateLimit(string(provider), keyName, state)
+	}
+}
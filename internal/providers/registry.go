@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// Backend sends requests to a provider registered with Register. Chat is
+// called with opts merged with the configuration and defaults, and with the
+// key selected by the rotator; key usage, latency and errors are recorded by
+// the caller.
+type Backend interface {
+	Chat(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error)
+}
+
+// BackendFunc adapts a plain function to the Backend interface
+type BackendFunc func(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error)
+
+// Chat calls f
+func (f BackendFunc) Chat(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	return f(ctx, messages, opts, key)
+}
+
+// Factory creates the backend of a registered provider from its
+// configuration and the HTTP client configured in global.http
+type Factory func(cfg config.ProviderConfig, client *http.Client) (Backend, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[ProviderType]Factory)
+)
+
+// Register registers a provider implemented outside gollmkit, e.g.
+// Register("mistral", newMistralBackend). Requests for name, configured under
+// providers.<name> like any provider, are sent to the backend created by
+// factory. Registering an existing name replaces it; built-in providers can't
+// be replaced. Streaming isn't supported for registered providers.
+func Register(name ProviderType, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// Unregister removes a previously registered provider
+func Unregister(name ProviderType) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	delete(factories, name)
+}
+
+// Registered returns the registered providers, sorted
+func Registered() []ProviderType {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]ProviderType, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// backends caches the backends of registered providers, created on first use
+// and recreated when the configuration changes
+type backends struct {
+	mu      sync.Mutex
+	config  *config.Config
+	created map[ProviderType]Backend
+}
+
+// backend returns the backend of a registered provider
+func (p *UnifiedProvider) backend(provider ProviderType) (Backend, error) {
+	factoriesMu.RLock()
+	factory, exists := factories[provider]
+	factoriesMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unsupported provider: %s", provider)
+	}
+
+	cfg := p.getConfig()
+	p.backends.mu.Lock()
+	defer p.backends.mu.Unlock()
+	if p.backends.config != cfg {
+		p.backends.config = cfg
+		p.backends.created = make(map[ProviderType]Backend)
+	}
+	if backend, exists := p.backends.created[provider]; exists {
+		return backend, nil
+	}
+
+	providerCfg, err := cfg.GetProvider(string(provider))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	backend, err := factory(*providerCfg, p.httpClient(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s provider: %w", provider, err)
+	}
+	p.backends.created[provider] = backend
+	return backend, nil
+}
+
+// callRegistered sends a request to a registered provider
+func (p *UnifiedProvider) callRegistered(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	backend, err := p.backend(opts.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var resp *CompletionResponse
+	if opts.Raw {
+		raw, ok := backend.(RawBackend)
+		if !ok {
+			return nil, rawUnsupportedError(opts.Provider)
+		}
+		prompt, rawOpts := p.rawRequest(messages, opts)
+		resp, err = raw.Complete(ctx, prompt, rawOpts, key)
+	} else {
+		resp, err = backend.Chat(ctx, messages, opts, key)
+	}
+	if err != nil {
+		p.recordError(ctx, opts.Provider, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(opts.Provider, opts.Model, start)
+
+	if resp.ProviderName == "" {
+		resp.ProviderName = string(opts.Provider)
+	}
+	p.recordUsage(ctx, opts.Provider, key.KeyName, opts.Model, resp.Usage)
+	return resp, nil
+}
@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// providerRequestIDHeaders are the response headers providers return their
+// request ID in: x-request-id for OpenAI, xAI and DeepSeek, request-id for
+// Anthropic and x-goog-request-id for Google
+var providerRequestIDHeaders = []string{"x-request-id", "request-id", "x-goog-request-id"}
+
+// providerRequestID returns the provider's request ID from response headers, if any
+func providerRequestID(header http.Header) string {
+	id, _ := firstHeader(header, providerRequestIDHeaders)
+	return id
+}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying a request ID. Chat uses it for
+// requests that don't set RequestOptions.RequestID, so IDs of incoming HTTP
+// requests can be propagated.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID of ctx, or "" if none. Inside
+// Chat, including its middleware, ctx always carries the ID of the request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random request ID such as req_3f2a...
+func newRequestID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("req_%x", time.Now().UnixNano())
+	}
+	return "req_" + hex.EncodeToString(b)
+}
+
+// withRequestID assigns opts a request ID, from ctx or a new one, and
+// returns ctx carrying it
+func withRequestID(ctx context.Context, opts *RequestOptions) context.Context {
+	if opts.RequestID == "" {
+		opts.RequestID = RequestIDFromContext(ctx)
+	}
+	if opts.RequestID == "" {
+		opts.RequestID = newRequestID()
+	}
+	return WithRequestID(ctx, opts.RequestID)
+}
+
+// setRequestIDs records the request IDs of a fresh response in its fields and metadata
+func (r *CompletionResponse) setRequestIDs(requestID, providerRequestID string) {
+	r.RequestID = requestID
+	if providerRequestID != "" {
+		r.ProviderRequestID = providerRequestID
+	}
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]interface{})
+	}
+	r.Metadata["request_id"] = r.RequestID
+	if r.ProviderRequestID != "" {
+		r.Metadata["provider_request_id"] = r.ProviderRequestID
+	}
+}
+
+// errorWithRequestID records requestID in err if it is an *Error without one
+func errorWithRequestID(err error, requestID string) error {
+	var providerErr *Error
+	if errors.As(err, &providerErr) && providerErr.RequestID == "" {
+		providerErr.RequestID = requestID
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ResponseInfo holds the raw HTTP response of a provider call for debugging
+// and support tickets. It is only collected when RequestOptions.IncludeResponseInfo is set.
+type ResponseInfo struct {
+	StatusCode int             `json:"status_code"`
+	Header     http.Header     `json:"header"`
+	RawBody    json.RawMessage `json:"raw_body,omitempty"`
+	Timing     RequestTiming   `json:"timing"`
+}
+
+// RequestID returns the provider's request ID from the response headers, if any
+func (i *ResponseInfo) RequestID() string {
+	return providerRequestID(i.Header)
+}
+
+// RequestTiming breaks down where the time of a provider call was spent.
+// Connection phases are zero when a pooled connection was reused.
+type RequestTiming struct {
+	DNS             time.Duration `json:"dns"`
+	Connect         time.Duration `json:"connect"`
+	TLSHandshake    time.Duration `json:"tls_handshake"`
+	TimeToFirstByte time.Duration `json:"time_to_first_byte"`
+	Total           time.Duration `json:"total"`
+	ReusedConn      bool          `json:"reused_conn"`
+}
+
+// requestTrace records connection timings of a request via httptrace
+type requestTrace struct {
+	mu                     sync.Mutex
+	start                  time.Time
+	dnsStart, connectStart time.Time
+	tlsStart               time.Time
+	timing                 RequestTiming
+}
+
+// traceRequest attaches a timing trace to req when opts asks for response info
+func traceRequest(req *http.Request, opts RequestOptions) (*http.Request, *requestTrace) {
+	if !opts.IncludeResponseInfo {
+		return req, nil
+	}
+
+	t := &requestTrace{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.since(t.dnsStart, &t.timing.DNS)
+		},
+		ConnectStart: func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone: func(string, string, error) {
+			t.since(t.connectStart, &t.timing.Connect)
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.since(t.tlsStart, &t.timing.TLSHandshake)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.timing.ReusedConn = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.since(t.start, &t.timing.TimeToFirstByte)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+// mark records the current time in at
+func (t *requestTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// since stores the time elapsed since from in d
+func (t *requestTrace) since(from time.Time, d *time.Duration) {
+	t.mu.Lock()
+	*d = time.Since(from)
+	t.mu.Unlock()
+}
+
+// decodeResponse decodes a JSON response body into out and, if the request
+// was traced, returns the response info including the raw body
+func decodeResponse(resp *http.Response, trace *requestTrace, out interface{}) (*ResponseInfo, error) {
+	if trace == nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+		}
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.timing.Total = time.Since(trace.start)
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	return &ResponseInfo{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		RawBody:    body,
+		Timing:     trace.timing,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SafetyRating is a provider's assessment of one harm category
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// SafetyBlockError reports a prompt or response blocked by a provider's
+// safety filters, or refused by the model. It is returned as the cause of an
+// *Error with CodeContentFilter and matches ErrContentFiltered.
+type SafetyBlockError struct {
+	Provider ProviderType
+	Prompt   bool   // the prompt was blocked, so nothing was generated
+	Reason   string // the provider's block or finish reason, e.g. SAFETY, content_filter or refusal
+	Ratings  []SafetyRating
+}
+
+// Error implements error
+func (e *SafetyBlockError) Error() string {
+	target := "response"
+	if e.Prompt {
+		target = "prompt"
+	}
+
+	var blocked []string
+	for _, rating := range e.Ratings {
+		if rating.Blocked {
+			blocked = append(blocked, rating.Category)
+		}
+	}
+	if len(blocked) == 0 {
+		return fmt.Sprintf("%s blocked by %s (%s)", target, providerDisplayName(e.Provider), e.Reason)
+	}
+	return fmt.Sprintf("%s blocked by %s (%s: %s)", target, providerDisplayName(e.Provider), e.Reason, strings.Join(blocked, ", "))
+}
+
+// Unwrap returns ErrContentFiltered
+func (e *SafetyBlockError) Unwrap() error {
+	return ErrContentFiltered
+}
+
+// safetyError wraps a safety block as a content filter *Error
+func safetyError(block *SafetyBlockError) *Error {
+	return &Error{
+		Code:     CodeContentFilter,
+		Provider: block.Provider,
+		Message:  "content filtered",
+		Err:      block,
+	}
+}
+
+// emptyResponseError reports a response without any completion and without a block reason
+func emptyResponseError(provider ProviderType) *Error {
+	return &Error{
+		Code:     CodeUpstream,
+		Provider: provider,
+		Message:  fmt.Sprintf("%s returned no candidates", providerDisplayName(provider)),
+		Err:      ErrEmptyResponse,
+	}
+}
+
+// filteredChoices returns the safety block of a response whose every choice
+// was withheld by a content filter, or nil
+func filteredChoices(provider ProviderType, choices []Choice, reason string, ratings []SafetyRating) *SafetyBlockError {
+	for _, choice := range choices {
+		if choice.FinishReason != FinishReasonContentFilter || choice.Content != "" {
+			return nil
+		}
+	}
+	return &SafetyBlockError{Provider: provider, Reason: reason, Ratings: ratings}
+}
+
+// parseContentFilterResults converts the content_filter_results Azure
+// OpenAI attaches to choices, e.g. {"hate": {"filtered": true, "severity": "high"}}
+func parseContentFilterResults(value interface{}) []SafetyRating {
+	results, _ := value.(map[string]interface{})
+	categories := make([]string, 0, len(results))
+	for category := range results {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var ratings []SafetyRating
+	for _, category := range categories {
+		result, _ := results[category].(map[string]interface{})
+		severity, _ := result["severity"].(string)
+		filtered, _ := result["filtered"].(bool)
+		ratings = append(ratings, SafetyRating{Category: category, Probability: severity, Blocked: filtered})
+	}
+	return ratings
+}
+
+// parseSafetyRatings converts the safetyRatings of a Gemini response
+func parseSafetyRatings(value interface{}) []SafetyRating {
+	items, _ := value.([]interface{})
+	ratings := make([]SafetyRating, 0, len(items))
+	for _, item := range items {
+		rating, _ := item.(map[string]interface{})
+		category, _ := rating["category"].(string)
+		probability, _ := rating["probability"].(string)
+		blocked, _ := rating["blocked"].(bool)
+		ratings = append(ratings, SafetyRating{Category: category, Probability: probability, Blocked: blocked})
+	}
+	return ratings
+}
+
+// geminiPromptBlock returns the safety block of a Gemini response whose
+// prompt was rejected, or nil
+func geminiPromptBlock(result map[string]interface{}, provider ProviderType) *SafetyBlockError {
+	feedback, _ := result["promptFeedback"].(map[string]interface{})
+	reason, _ := feedback["blockReason"].(string)
+	if reason == "" {
+		return nil
+	}
+	return &SafetyBlockError{
+		Provider: provider,
+		Prompt:   true,
+		Reason:   reason,
+		Ratings:  parseSafetyRatings(feedback["safetyRatings"]),
+	}
+}
+
+// geminiSafetySettings converts safety settings to the generateContent format
+func geminiSafetySettings(opts RequestOptions) []map[string]string {
+	settings := make([]map[string]string, 0, len(opts.SafetySettings))
+	for _, setting := range opts.SafetySettings {
+		settings = append(settings, map[string]string{
+			"category":  setting.Category,
+			"threshold": setting.Threshold,
+		})
+	}
+	return settings
+}
@@ -0,0 +1,24 @@
+package providers
+
+import (
+	"encoding/json"
+
+	"github.com/gollmkit/gollmkit/internal/redact"
+)
+
+// redactResponse removes key material from the provider data carried by resp:
+// the raw response in Metadata and the headers and body of ResponseInfo
+func redactResponse(resp *CompletionResponse, secret string) {
+	if resp == nil {
+		return
+	}
+	for name, value := range resp.Metadata {
+		resp.Metadata[name] = redact.Value(value, secret)
+	}
+	if info := resp.ResponseInfo; info != nil {
+		redact.Header(info.Header, secret)
+		if len(info.RawBody) > 0 {
+			info.RawBody = json.RawMessage(redact.String(string(info.RawBody), secret))
+		}
+	}
+}
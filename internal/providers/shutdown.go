@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// ErrShutdown is returned for requests made after Shutdown was called
+var ErrShutdown = errors.New("provider is shut down")
+
+// beginCall marks a call as in progress and returns a func that ends it, or
+// ErrShutdown once Shutdown was called
+func (p *BaseProvider) beginCall() (func(), error) {
+	p.callsMu.Lock()
+	defer p.callsMu.Unlock()
+	if p.closing {
+		return nil, ErrShutdown
+	}
+	p.calls.Add(1)
+	return p.calls.Done, nil
+}
+
+// Shutdown stops accepting requests and waits until calls in progress,
+// including open streams, and running health checks have finished, then
+// writes queued usage and flushes pending usage statistics of the key store
+// and analytics tracker.
+// Requests made after Shutdown fail with ErrShutdown. If ctx is done before
+// everything finished, pending usage is flushed anyway and an error wrapping
+// ctx.Err() is returned.
+func (p *BaseProvider) Shutdown(ctx context.Context) error {
+	p.callsMu.Lock()
+	p.closing = true
+	p.callsMu.Unlock()
+
+	var errs []error
+	if err := p.StopHealthChecks(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("health checks still running: %w", err))
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		p.calls.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("requests still in progress: %w", ctx.Err()))
+	}
+
+	if err := p.rotator.StopUsageQueue(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if flusher, ok := p.rotator.KeyStore().(auth.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush key usage: %w", err))
+		}
+	}
+	if p.tracker != nil {
+		if err := p.tracker.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		p.log().Error("gollmkit: shutdown incomplete", "error", err)
+		return err
+	}
+	p.log().Info("gollmkit: shut down")
+	return nil
+}
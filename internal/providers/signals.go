@@ -0,0 +1,48 @@
+//go:build !windows
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals reloads the configuration from configPath on SIGHUP and logs
+// the current rotation state, key health and statistics as JSON on SIGUSR1.
+// It blocks until ctx is done.
+func (p *BaseProvider) HandleSignals(ctx context.Context, configPath string) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGUSR1)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigCh:
+			switch sig {
+			case syscall.SIGHUP:
+				if err := p.ReloadConfig(ctx, configPath); err != nil {
+					p.log().Error("gollmkit: SIGHUP: config reload failed", "path", configPath, "error", err)
+					continue
+				}
+				p.log().Info("gollmkit: SIGHUP: configuration reloaded", "path", configPath)
+			case syscall.SIGUSR1:
+				snapshot, err := p.Snapshot(ctx)
+				if err != nil {
+					p.log().Error("gollmkit: SIGUSR1: failed to snapshot state", "error", err)
+					continue
+				}
+				data, err := json.Marshal(snapshot)
+				if err != nil {
+					p.log().Error("gollmkit: SIGUSR1: failed to encode state", "error", err)
+					continue
+				}
+				p.log().Info("gollmkit: state", "state", string(data))
+			}
+		}
+	}
+}
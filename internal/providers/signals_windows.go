@@ -0,0 +1,13 @@
+//go:build windows
+
+package providers
+
+import (
+	"context"
+)
+
+// HandleSignals is a no-op on Windows, which has no SIGHUP/SIGUSR1.
+// Use ReloadConfig and Snapshot directly instead. It blocks until ctx is done.
+func (p *BaseProvider) HandleSignals(ctx context.Context, configPath string) {
+	<-ctx.Done()
+}
@@ -0,0 +1,195 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/usage"
+)
+
+// StateSnapshot is a point-in-time view of key rotation, health and statistics
+type StateSnapshot struct {
+	Time           time.Time                 `json:"time"`
+	Providers      map[string]*ProviderState `json:"providers"`
+	DailyCost      float64                   `json:"daily_cost"`
+	DailyCostLimit float64                   `json:"daily_cost_limit,omitempty"`
+}
+
+// ProviderState holds the rotation status and statistics of a single provider
+type ProviderState struct {
+	Rotation   *auth.RotationStatus `json:"rotation"`
+	Statistics *auth.ProviderStats  `json:"statistics"`
+	InFlight   int                  `json:"in_flight"`
+	DailyCost  float64              `json:"daily_cost"`
+	Forecast   *usage.Forecast      `json:"forecast,omitempty"` // set if a tracker is set
+}
+
+// defaultForecastWindow is the burn rate window of the forecasts in snapshots
+const defaultForecastWindow = 7 * 24 * time.Hour
+
+// ForecastSpend projects the month-end cost of provider, or of all providers
+// if provider is empty, from its burn rate over the last window. It uses the
+// events held in memory by the tracker set with SetTracker, so a process
+// started mid-month only knows the spend since it started.
+func (p *BaseProvider) ForecastSpend(provider ProviderType, window time.Duration) (*usage.Forecast, error) {
+	if p.tracker == nil {
+		return nil, fmt.Errorf("%w: spend forecasts need an analytics tracker", ErrInvalidConfig)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: forecast window must be positive", ErrInvalidConfig)
+	}
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if windowStart := now.Add(-window); windowStart.Before(since) {
+		since = windowStart
+	}
+	forecast := usage.ForecastSpend(p.tracker.Events(since), string(provider), window, now)
+	return &forecast, nil
+}
+
+// Snapshot collects the current rotation state, key health and statistics of all providers
+func (p *BaseProvider) Snapshot(ctx context.Context) (*StateSnapshot, error) {
+	cfg := p.getConfig()
+
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snapshot := &StateSnapshot{
+		Time:           time.Now(),
+		Providers:      make(map[string]*ProviderState, len(names)),
+		DailyCostLimit: cfg.Global.DailyCostLimit,
+	}
+	for _, name := range names {
+		rotation, err := p.rotator.GetRotationStatus(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rotation status for %s: %w", name, err)
+		}
+		stats, err := p.rotator.GetProviderStatistics(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get statistics for %s: %w", name, err)
+		}
+
+		state := &ProviderState{
+			Rotation:   rotation,
+			Statistics: stats,
+			InFlight:   p.InFlight(ProviderType(name)),
+		}
+		if p.tracker != nil {
+			state.Forecast, _ = p.ForecastSpend(ProviderType(name), defaultForecastWindow)
+		}
+		for _, keyStats := range stats.KeyStats {
+			if keyStats.Usage != nil {
+				state.DailyCost += keyStats.Usage.DailyCost
+			}
+		}
+		snapshot.DailyCost += state.DailyCost
+		snapshot.Providers[name] = state
+	}
+
+	return snapshot, nil
+}
+
+// ReloadConfig loads the configuration at configPath and swaps it in
+func (p *BaseProvider) ReloadConfig(ctx context.Context, configPath string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	return p.UpdateConfig(ctx, cfg)
+}
+
+// WatchConfig reloads the configuration whenever the file at configPath
+// changes. Invalid changes are logged and the current configuration is kept.
+func (p *BaseProvider) WatchConfig(ctx context.Context, configPath string) (*config.Watcher, error) {
+	return config.Watch(configPath,
+		func(cfg *config.Config) {
+			if err := p.UpdateConfig(ctx, cfg); err != nil {
+				p.log().Error("gollmkit: config reload failed", "path", configPath, "error", err)
+				return
+			}
+			p.log().Info("gollmkit: configuration reloaded", "path", configPath)
+		},
+		func(err error) {
+			p.log().Error("gollmkit: config reload failed", "path", configPath, "error", err)
+		})
+}
+
+// defaultStateInterval is how often PublishState writes the state file when no interval is given
+const defaultStateInterval = 2 * time.Second
+
+// WriteState writes the current snapshot as JSON to path. The file is
+// replaced atomically so readers such as `gollmkit watch` never see a partial write.
+func (p *BaseProvider) WriteState(ctx context.Context, path string) error {
+	snapshot, err := p.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gollmkit-state-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// PublishState writes the state file at path every interval until ctx is done.
+// Write errors are logged and publishing continues.
+func (p *BaseProvider) PublishState(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.WriteState(ctx, path); err != nil {
+			p.log().Warn("gollmkit: state publish failed", "path", path, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReadState reads a state file written by WriteState
+func ReadState(path string) (*StateSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var snapshot StateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid state file: %w", err)
+	}
+	return &snapshot, nil
+}
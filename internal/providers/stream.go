@@ -0,0 +1,727 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/redact"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// StreamChunk is one increment of a streamed completion. Delta holds newly
+// generated text and Thinking newly generated extended thinking. The last
+// chunk carries FinishReason and Usage; a chunk with Error set also ends the stream.
+type StreamChunk struct {
+	Delta        string      `json:"delta,omitempty"`
+	Thinking     string      `json:"thinking,omitempty"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+	Usage        *TokenUsage `json:"usage,omitempty"`
+	Error        error       `json:"-"`
+
+	// RequestID is set on the final chunk, see RequestOptions.RequestID
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// maxSSELineSize bounds a single line of a server-sent event stream
+const maxSSELineSize = 1024 * 1024
+
+// streamState accumulates the finish reason and usage reported across stream events
+type streamState struct {
+	finishReason string
+	usage        TokenUsage
+	hasUsage     bool
+
+	// thinkingDelta is extended thinking decoded from the current event
+	thinkingDelta string
+	thinking      strings.Builder
+}
+
+// streamDecodeFunc handles one server-sent event of a provider's stream. It
+// returns the text delta the event carries and whether the stream is complete.
+type streamDecodeFunc func(event, data string, st *streamState) (delta string, done bool, err error)
+
+// InvokeStream sends a single prompt to the LLM and streams the response
+func (p *UnifiedProvider) InvokeStream(ctx context.Context, prompt string, opts RequestOptions) (<-chan StreamChunk, error) {
+	messages := []Message{{Role: "user", Content: prompt}}
+	return p.ChatStream(ctx, messages, opts)
+}
+
+// ChatStream sends a series of messages to the LLM and streams the response.
+// Errors that occur before the provider starts responding are returned
+// directly; later errors arrive as a chunk with Error set. The channel is
+// closed when the stream ends. Callers that stop reading early must cancel ctx.
+// The request passes through the middleware added with UseStream.
+func (p *UnifiedProvider) ChatStream(ctx context.Context, messages []Message, opts RequestOptions) (<-chan StreamChunk, error) {
+	ctx = withRequestID(ctx, &opts)
+	out, err := p.streamHandler()(ctx, messages, opts)
+	return out, errorWithRequestID(err, opts.RequestID)
+}
+
+// chatStream sends a series of messages to the LLM and streams the response
+func (p *UnifiedProvider) chatStream(ctx context.Context, messages []Message, opts RequestOptions) (<-chan StreamChunk, error) {
+	endCall, err := p.beginCall()
+	if err != nil {
+		return nil, err
+	}
+	streaming := false // the stream goroutine ends the call
+	forget := func() {}
+	defer func() {
+		if !streaming {
+			endCall()
+			forget()
+		}
+	}()
+
+	if opts.N > 1 {
+		return nil, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  "multiple completions (n > 1) can't be streamed",
+			Err:      ErrBadRequest,
+		}
+	}
+	if opts.Raw {
+		return nil, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  "raw completions can't be streamed",
+			Err:      ErrBadRequest,
+		}
+	}
+	if len(opts.Tools) > 0 {
+		return nil, &Error{
+			Code:     CodeBadRequest,
+			Provider: opts.Provider,
+			Message:  "tool calls can't be streamed",
+			Err:      ErrBadRequest,
+		}
+	}
+
+	opts, err = p.prepareRequest(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Stream = true
+	messages, err = p.prepareAttachments(NormalizeMessages(messages, opts), opts.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversationID string
+	if p.piiVault != nil {
+		conversationID, forget = p.vaultConversation(opts)
+		if messages, err = p.tokenizeMessages(conversationID, messages, opts.Provider); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := p.getNextKey(ctx, opts.Provider, keyRestrictions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+
+	end := p.beginRequest(opts.Provider)
+	start := time.Now()
+
+	// The timeout covers the whole stream, not just the initial response
+	var streamCtx context.Context
+	var cancel context.CancelFunc
+	if opts.Timeout > 0 {
+		streamCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	} else {
+		streamCtx, cancel = context.WithCancel(ctx)
+	}
+
+	var body io.ReadCloser
+	var decode streamDecodeFunc
+	var failedKeys []string
+	for {
+		body, decode, err = p.openStream(streamCtx, messages, opts, key)
+		if err == nil {
+			break
+		}
+		err = redact.Error(timeoutError(streamCtx, ctx, opts, err), key.Key)
+		p.trackRequest(opts, key, start, nil, err)
+
+		next := p.failoverKey(ctx, opts, key, &failedKeys, err)
+		if next == nil {
+			cancel()
+			end()
+			key.Release()
+			return nil, err
+		}
+		key = next
+	}
+
+	out := make(chan StreamChunk)
+	streaming = true
+	go func() {
+		defer endCall()
+		defer close(out)
+		defer key.Release()
+		defer end()
+		defer cancel()
+		defer body.Close()
+
+		p.pumpStream(streamCtx, ctx, body, decode, messages, opts, key, start, out)
+	}()
+	if p.piiVault != nil {
+		return p.detokenizeStream(ctx, conversationID, out, forget), nil
+	}
+	return out, nil
+}
+
+// pumpStream reads the event stream from body, sends its deltas to out and
+// finishes with a chunk carrying the finish reason and usage, or the error
+func (p *UnifiedProvider) pumpStream(ctx, callerCtx context.Context, body io.Reader, decode streamDecodeFunc, messages []Message, opts RequestOptions, key *auth.KeySelection, start time.Time, out chan<- StreamChunk) {
+	var content strings.Builder
+	var discarded string // the part of the last delta cut by the response cap
+	var st streamState
+	var firstToken time.Time // when the first text or thinking arrived
+	limit := streamCap{opts: opts}
+
+	err := readSSE(body, func(event, data string) (bool, error) {
+		delta, done, err := decode(event, data, &st)
+		if firstToken.IsZero() && (delta != "" || st.thinkingDelta != "") {
+			firstToken = time.Now()
+		}
+		if st.thinkingDelta != "" {
+			st.thinking.WriteString(st.thinkingDelta)
+			if !sendChunk(ctx, out, StreamChunk{Thinking: st.thinkingDelta}) {
+				return true, ctx.Err()
+			}
+			st.thinkingDelta = ""
+		}
+		if err != nil || delta == "" {
+			return done, err
+		}
+
+		fitted, capped := limit.fit(delta)
+		if capped {
+			// Stop reading; closing the stream lets the provider stop generating
+			st.finishReason = FinishReasonLengthCap
+			discarded = delta[len(fitted):]
+			done = true
+		}
+		delta = fitted
+		content.WriteString(delta)
+
+		if delta != "" && !sendChunk(ctx, out, StreamChunk{Delta: delta}) {
+			return true, ctx.Err()
+		}
+		return done, nil
+	})
+	if err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: %s stream ended before completion", ErrResponseFormat, providerDisplayName(opts.Provider))
+	}
+
+	if err != nil {
+		err = redact.Error(timeoutError(ctx, callerCtx, opts, err), key.Key)
+		if callerCtx.Err() == nil {
+			p.recordError(callerCtx, opts.Provider, key.KeyName, err)
+		}
+		err = errorWithRequestID(err, opts.RequestID)
+		p.trackRequest(opts, key, start, nil, err)
+		sendChunk(callerCtx, out, StreamChunk{Error: err, RequestID: opts.RequestID})
+		return
+	}
+
+	usage := st.usage
+	switch {
+	case !st.hasUsage:
+		usage = estimateUsage(messages, content.String()+discarded, opts.Model)
+	case limit.hit:
+		// The stream was left before the provider reported the completion tokens
+		usage.CompletionTokens = tokenizer.CountTokens(opts.Model, content.String()+discarded)
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		usage.Estimated = true
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if usage.ReasoningTokens == 0 && st.thinking.Len() > 0 {
+		usage.ReasoningTokens = tokenizer.CountTokens(opts.Model, st.thinking.String())
+	}
+
+	p.recordUsage(callerCtx, opts.Provider, key.KeyName, opts.Model, usage)
+	if p.tenants != nil && opts.TenantID != "" {
+		p.tenants.Record(opts.TenantID, usage.TotalTokens, p.CalculateCost(opts.Provider, opts.Model, usage), time.Now())
+	}
+
+	event := p.requestEvent(opts, key, start, &CompletionResponse{
+		Content:      content.String(),
+		Model:        opts.Model,
+		Usage:        usage,
+		ProviderName: string(opts.Provider),
+		FinishReason: st.finishReason,
+	}, nil)
+	if !firstToken.IsZero() {
+		event.TimeToFirstToken = firstToken.Sub(start)
+		event.TokensPerSecond = tokensPerSecond(usage.CompletionTokens, time.Since(firstToken))
+		p.rotator.RecordStream(string(opts.Provider), opts.Model, event.TimeToFirstToken, event.TokensPerSecond)
+	}
+	p.trackEvent(event)
+
+	sendChunk(callerCtx, out, StreamChunk{FinishReason: st.finishReason, Usage: &usage, RequestID: opts.RequestID})
+}
+
+// tokensPerSecond returns the generation rate of a stream that produced
+// tokens in the time after its first token, zero if there are too few to tell
+func tokensPerSecond(tokens int, elapsed time.Duration) float64 {
+	if tokens < 2 || elapsed <= 0 {
+		return 0
+	}
+	// The first token arrived at the start of elapsed
+	return float64(tokens-1) / elapsed.Seconds()
+}
+
+// openStream sends a streaming request to the provider selected in opts and
+// returns the event stream body with the decoder for its event format
+func (p *UnifiedProvider) openStream(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (io.ReadCloser, streamDecodeFunc, error) {
+	var reqBody map[string]interface{}
+	var apiURL string
+	var decode streamDecodeFunc
+	var setHeaders func(*http.Request, *auth.KeySelection)
+
+	switch opts.Provider {
+	case OpenAI, XAI, DeepSeek:
+		reqBody = openAIRequestBody(messages, opts)
+		reqBody["stream_options"] = map[string]interface{}{"include_usage": true}
+		apiURL = chatCompletionsURLs[opts.Provider]
+		decode, setHeaders = openAIStreamDecoder(opts.Provider), setOpenAIHeaders
+	case Anthropic:
+		reqBody = anthropicRequestBody(messages, opts)
+		apiURL = "https://api.anthropic.com/v1/messages"
+		decode, setHeaders = decodeAnthropicStream, p.anthropicHeaders(opts)
+	case Gemini:
+		reqBody = geminiRequestBody(messages, opts)
+		apiURL = fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:streamGenerateContent?alt=sse",
+			url.PathEscape(opts.Model))
+		decode, setHeaders = geminiStreamDecoder(Gemini), setGeminiHeaders
+	case Vertex:
+		reqBody = geminiRequestBody(messages, opts)
+		var err error
+		if apiURL, err = p.vertexURL(opts.Model, "streamGenerateContent?alt=sse"); err != nil {
+			return nil, nil, err
+		}
+		decode = geminiStreamDecoder(Vertex)
+	case Mock:
+		start := time.Now()
+		body, err := mockStreamBody(ctx, messages, opts)
+		if err != nil {
+			p.recordError(ctx, Mock, key.KeyName, err)
+			return nil, nil, err
+		}
+		p.recordLatency(Mock, opts.Model, start)
+		return body, openAIStreamDecoder(Mock), nil
+	default:
+		return nil, nil, fmt.Errorf("streaming is not supported by provider %s", opts.Provider)
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if setHeaders != nil {
+		setHeaders(req, key)
+	}
+	if opts.Provider == Vertex {
+		if err := p.setVertexHeaders(ctx, req, key); err != nil {
+			p.recordError(ctx, Vertex, key.KeyName, err)
+			return nil, nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := p.httpClient(opts.Provider).Do(req)
+	if err != nil {
+		p.recordError(ctx, opts.Provider, key.KeyName, err)
+		return nil, nil, err
+	}
+	p.recordRateLimit(opts.Provider, key.KeyName, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		err := parseAPIError(opts.Provider, resp)
+		closeBody(resp.Body)
+		p.recordError(ctx, opts.Provider, key.KeyName, err)
+		return nil, nil, err
+	}
+	p.recordLatency(opts.Provider, opts.Model, start)
+
+	return resp.Body, decode, nil
+}
+
+// readSSE reads server-sent events from r and calls fn with the type and data
+// of each event until fn reports the stream is done. It returns
+// io.ErrUnexpectedEOF if r ends first.
+func readSSE(r io.Reader, fn func(event, data string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var event string
+	var data []string
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			event = ""
+			return false, nil
+		}
+		done, err := fn(event, strings.Join(data, "\n"))
+		event, data = "", data[:0]
+		return done, err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if done, err := dispatch(); done || err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment, used by some providers as a keep-alive
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// The final event may not be followed by a blank line
+	if done, err := dispatch(); done || err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// openAIStreamDecoder returns the decoder of chat completion chunks of
+// providers implementing the OpenAI API. The finish reason comes in the last
+// choice delta, usage in a final chunk without choices.
+func openAIStreamDecoder(provider ProviderType) streamDecodeFunc {
+	return func(event, data string, st *streamState) (string, bool, error) {
+		return decodeOpenAIStream(provider, data, st)
+	}
+}
+
+// decodeOpenAIStream handles one chat completion chunk
+func decodeOpenAIStream(provider ProviderType, data string, st *streamState) (string, bool, error) {
+	if data == "[DONE]" {
+		return "", true, nil
+	}
+
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+			FinishReason *string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *struct {
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			TotalTokens         int `json:"total_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+			CompletionTokensDetails struct {
+				ReasoningTokens int `json:"reasoning_tokens"`
+			} `json:"completion_tokens_details"`
+		} `json:"usage"`
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := decodeEvent(provider, data, &chunk); err != nil {
+		return "", false, err
+	}
+
+	if chunk.Error != nil {
+		return "", false, streamError(provider, chunk.Error.Type, chunk.Error.Message)
+	}
+	if chunk.Usage != nil {
+		st.usage = TokenUsage{
+			PromptTokens:     chunk.Usage.PromptTokens,
+			CompletionTokens: chunk.Usage.CompletionTokens,
+			TotalTokens:      chunk.Usage.TotalTokens,
+			CachedTokens:     chunk.Usage.PromptTokensDetails.CachedTokens,
+			ReasoningTokens:  chunk.Usage.CompletionTokensDetails.ReasoningTokens,
+		}
+		st.hasUsage = true
+	}
+	if len(chunk.Choices) == 0 {
+		return "", false, nil
+	}
+
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil {
+		st.finishReason = normalizeFinishReason(provider, *choice.FinishReason)
+	}
+	return choice.Delta.Content, false, nil
+}
+
+// decodeAnthropicStream handles the messages API event model: message_start
+// carries input usage, content_block_delta the text and thinking,
+// message_delta the stop reason and cumulative output usage, and message_stop
+// ends the stream
+func decodeAnthropicStream(event, data string, st *streamState) (string, bool, error) {
+	switch event {
+	case "message_start":
+		var ev struct {
+			Message struct {
+				Usage struct {
+					InputTokens              int `json:"input_tokens"`
+					OutputTokens             int `json:"output_tokens"`
+					CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+					CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+		}
+		if err := decodeEvent(Anthropic, data, &ev); err != nil {
+			return "", false, err
+		}
+		usage := ev.Message.Usage
+		st.usage.PromptTokens = usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+		st.usage.CompletionTokens = usage.OutputTokens
+		st.usage.CachedTokens = usage.CacheReadInputTokens
+
+	case "content_block_delta":
+		var ev struct {
+			Delta struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				Thinking string `json:"thinking"`
+			} `json:"delta"`
+		}
+		if err := decodeEvent(Anthropic, data, &ev); err != nil {
+			return "", false, err
+		}
+		switch ev.Delta.Type {
+		case "text_delta":
+			return ev.Delta.Text, false, nil
+		case "thinking_delta":
+			st.thinkingDelta = ev.Delta.Thinking
+		}
+
+	case "message_delta":
+		var ev struct {
+			Delta struct {
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage struct {
+				InputTokens              int `json:"input_tokens"`
+				OutputTokens             int `json:"output_tokens"`
+				CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+				CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			} `json:"usage"`
+		}
+		if err := decodeEvent(Anthropic, data, &ev); err != nil {
+			return "", false, err
+		}
+		if ev.Delta.StopReason != "" {
+			st.finishReason = normalizeFinishReason(Anthropic, ev.Delta.StopReason)
+		}
+		if usage := ev.Usage; usage.InputTokens > 0 {
+			st.usage.PromptTokens = usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+			st.usage.CachedTokens = usage.CacheReadInputTokens
+		}
+		st.usage.CompletionTokens = ev.Usage.OutputTokens
+		st.hasUsage = true
+
+	case "message_stop":
+		return "", true, nil
+
+	case "error":
+		errType, message := parseErrorBody([]byte(data))
+		return "", false, streamError(Anthropic, errType, message)
+	}
+
+	// ping, content_block_start and content_block_stop carry no text
+	return "", false, nil
+}
+
+// geminiStreamDecoder returns the decoder of streamGenerateContent responses
+// of the Generative Language API and Vertex AI. Each event is a partial
+// response; the one with a finish reason is the last.
+func geminiStreamDecoder(provider ProviderType) streamDecodeFunc {
+	return func(event, data string, st *streamState) (string, bool, error) {
+		return decodeGeminiStream(provider, data, st)
+	}
+}
+
+// decodeGeminiStream handles one partial generateContent response
+func decodeGeminiStream(provider ProviderType, data string, st *streamState) (string, bool, error) {
+	var chunk struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback *struct {
+			BlockReason   string         `json:"blockReason"`
+			SafetyRatings []SafetyRating `json:"safetyRatings"`
+		} `json:"promptFeedback"`
+		UsageMetadata *struct {
+			PromptTokenCount        int `json:"promptTokenCount"`
+			CandidatesTokenCount    int `json:"candidatesTokenCount"`
+			TotalTokenCount         int `json:"totalTokenCount"`
+			CachedContentTokenCount int `json:"cachedContentTokenCount"`
+		} `json:"usageMetadata"`
+		Error *struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := decodeEvent(provider, data, &chunk); err != nil {
+		return "", false, err
+	}
+
+	if chunk.Error != nil {
+		return "", false, streamError(provider, chunk.Error.Status, chunk.Error.Message)
+	}
+	if feedback := chunk.PromptFeedback; feedback != nil && feedback.BlockReason != "" {
+		return "", false, safetyError(&SafetyBlockError{
+			Provider: provider,
+			Prompt:   true,
+			Reason:   feedback.BlockReason,
+			Ratings:  feedback.SafetyRatings,
+		})
+	}
+	if chunk.UsageMetadata != nil {
+		st.usage = TokenUsage{
+			PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+			CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      chunk.UsageMetadata.TotalTokenCount,
+			CachedTokens:     chunk.UsageMetadata.CachedContentTokenCount,
+		}
+		st.hasUsage = true
+	}
+	if len(chunk.Candidates) == 0 {
+		return "", false, nil
+	}
+
+	candidate := chunk.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if candidate.FinishReason != "" {
+		st.finishReason = normalizeFinishReason(provider, candidate.FinishReason)
+		return text.String(), true, nil
+	}
+	return text.String(), false, nil
+}
+
+// decodeEvent unmarshals the data of a stream event
+func decodeEvent(provider ProviderType, data string, v interface{}) error {
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("%w: invalid %s stream event: %v", ErrResponseFormat, providerDisplayName(provider), err)
+	}
+	return nil
+}
+
+// streamError creates the error for an error event sent mid-stream
+func streamError(provider ProviderType, errType, message string) *Error {
+	apiErr := &Error{
+		Code:     CodeUpstream,
+		Provider: provider,
+		Message:  fmt.Sprintf("%s stream error:", providerDisplayName(provider)),
+		Err:      ErrUpstream,
+	}
+	if message != "" {
+		apiErr.Message += " " + message
+	} else {
+		apiErr.Message += " " + errType
+	}
+
+	refineError(apiErr, errType, message)
+	return apiErr
+}
+
+// streamCap applies the response caps in opts to the deltas of a stream. It
+// keeps count of the bytes and tokens delivered, so each delta is measured once.
+type streamCap struct {
+	opts   RequestOptions
+	bytes  int
+	tokens int
+	hit    bool
+}
+
+// fit returns the part of delta that fits within the caps and whether the cap was hit
+func (c *streamCap) fit(delta string) (string, bool) {
+	if c.hit {
+		return "", true
+	}
+	if c.opts.MaxResponseBytes <= 0 && c.opts.MaxResponseTokens <= 0 {
+		return delta, false
+	}
+
+	if c.opts.MaxResponseTokens > 0 {
+		tokens := tokenizer.CountTokens(c.opts.Model, delta)
+		if c.tokens+tokens > c.opts.MaxResponseTokens {
+			delta = tokenizer.Truncate(c.opts.Model, delta, c.opts.MaxResponseTokens-c.tokens)
+			tokens = tokenizer.CountTokens(c.opts.Model, delta)
+			c.hit = true
+		}
+		c.tokens += tokens
+	}
+	if c.opts.MaxResponseBytes > 0 && c.bytes+len(delta) > c.opts.MaxResponseBytes {
+		delta = truncateBytes(delta, c.opts.MaxResponseBytes-c.bytes)
+		c.hit = true
+	}
+	c.bytes += len(delta)
+	return delta, c.hit
+}
+
+// estimateUsage counts tokens locally for streams that don't report usage
+func estimateUsage(messages []Message, content, model string) TokenUsage {
+	var prompt strings.Builder
+	for _, msg := range messages {
+		prompt.WriteString(msg.Content)
+	}
+
+	usage := TokenUsage{
+		PromptTokens:     tokenizer.CountTokens(model, prompt.String()),
+		CompletionTokens: tokenizer.CountTokens(model, content),
+		Estimated:        true,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// sendChunk sends chunk on out unless ctx is done first
+func sendChunk(ctx context.Context, out chan<- StreamChunk, chunk StreamChunk) bool {
+	select {
+	case out <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,416 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+type sseEvent struct {
+	event, data string
+}
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []sseEvent
+		err    error
+	}{
+		{
+			name:   "events",
+			stream: "event: a\ndata: 1\n\ndata: 2\n\ndata: [DONE]\n\n",
+			want:   []sseEvent{{"a", "1"}, {"", "2"}, {"", "[DONE]"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: {\"text\":\ndata:  \"two lines\"}\n\ndata: [DONE]\n\n",
+			want:   []sseEvent{{"", "{\"text\":\n \"two lines\"}"}, {"", "[DONE]"}},
+		},
+		{
+			name:   "comments and keep-alives",
+			stream: ": keep-alive\n\n:ping\ndata: 1\n: between fields\n\nevent: ping\n\ndata: [DONE]\n\n",
+			want:   []sseEvent{{"", "1"}, {"", "[DONE]"}},
+		},
+		{
+			name:   "no space after colon and CRLF line endings",
+			stream: "event:delta\r\ndata:1\r\n\r\ndata: [DONE]\r\n\r\n",
+			want:   []sseEvent{{"delta", "1"}, {"", "[DONE]"}},
+		},
+		{
+			name:   "unknown fields",
+			stream: "id: 7\nretry: 1000\ndata: 1\n\ndata: [DONE]\n\n",
+			want:   []sseEvent{{"", "1"}, {"", "[DONE]"}},
+		},
+		{
+			name:   "events after done are not read",
+			stream: "data: [DONE]\n\ndata: late\n\n",
+			want:   []sseEvent{{"", "[DONE]"}},
+		},
+		{
+			name:   "final event without blank line",
+			stream: "data: 1\n\ndata: [DONE]",
+			want:   []sseEvent{{"", "1"}, {"", "[DONE]"}},
+		},
+		{
+			name:   "truncated",
+			stream: "data: 1\n\ndata: {\"partial",
+			want:   []sseEvent{{"", "1"}, {"", "{\"partial"}},
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name: "empty",
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "line too long",
+			stream: "data: " + strings.Repeat("x", maxSSELineSize) + "\n\n",
+			err:    bufio.ErrTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []sseEvent
+			err := readSSE(strings.NewReader(tt.stream), func(event, data string) (bool, error) {
+				got = append(got, sseEvent{event, data})
+				return data == "[DONE]", nil
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSSEStopsOnError(t *testing.T) {
+	failed := errors.New("failed")
+	calls := 0
+	err := readSSE(strings.NewReader("data: 1\n\ndata: 2\n\n"), func(event, data string) (bool, error) {
+		calls++
+		return false, failed
+	})
+	if err != failed || calls != 1 {
+		t.Errorf("error %v after %d calls, want %v after 1", err, calls, failed)
+	}
+}
+
+// decodedStream is what a decoder made of a stream fixture
+type decodedStream struct {
+	text     string
+	thinking string
+	st       *streamState
+}
+
+// decodeFixture runs the events of stream through decode, as pumpStream does
+func decodeFixture(stream string, decode streamDecodeFunc) (decodedStream, error) {
+	result := decodedStream{st: &streamState{}}
+	var text, thinking strings.Builder
+	err := readSSE(strings.NewReader(stream), func(event, data string) (bool, error) {
+		delta, done, err := decode(event, data, result.st)
+		thinking.WriteString(result.st.thinkingDelta)
+		result.st.thinkingDelta = ""
+		text.WriteString(delta)
+		return done, err
+	})
+	result.text, result.thinking = text.String(), thinking.String()
+	return result, err
+}
+
+const openAIStreamFixture = `data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}
+
+: keep-alive
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1",
+data:  "choices":[{"index":0,"delta":{"content":", world"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: {"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":1}}}
+
+data: [DONE]
+
+`
+
+const openAIToolCallFixture = `data: {"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"ci"}}]},"finish_reason":null}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"Paris\"}"}}]},"finish_reason":null}]}
+
+data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`
+
+func TestDecodeOpenAIStream(t *testing.T) {
+	decode := openAIStreamDecoder(OpenAI)
+
+	t.Run("text", func(t *testing.T) {
+		got, err := decodeFixture(openAIStreamFixture, decode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.text != "Hello, world" {
+			t.Errorf("text %q", got.text)
+		}
+		if got.st.finishReason != FinishReasonStop {
+			t.Errorf("finish reason %q", got.st.finishReason)
+		}
+		want := TokenUsage{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12, CachedTokens: 4, ReasoningTokens: 1}
+		if !got.st.hasUsage || got.st.usage != want {
+			t.Errorf("usage %+v, want %+v", got.st.usage, want)
+		}
+	})
+
+	t.Run("tool call deltas", func(t *testing.T) {
+		// Streamed requests can't offer tools, but argument fragments must
+		// not surface as text or break decoding
+		got, err := decodeFixture(openAIToolCallFixture, decode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.text != "" {
+			t.Errorf("tool call arguments decoded as text %q", got.text)
+		}
+		if got.st.finishReason != FinishReasonToolCalls {
+			t.Errorf("finish reason %q", got.st.finishReason)
+		}
+	})
+
+	t.Run("mid-stream error", func(t *testing.T) {
+		stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+			"data: {\"error\":{\"type\":\"server_error\",\"message\":\"The server had an error\"}}\n\n"
+		got, err := decodeFixture(stream, decode)
+		if code := CodeOf(err); code != CodeUpstream {
+			t.Errorf("code %s, want %s (%v)", code, CodeUpstream, err)
+		}
+		if !strings.Contains(err.Error(), "The server had an error") {
+			t.Errorf("error %q lacks the provider's message", err)
+		}
+		if got.text != "Hel" {
+			t.Errorf("text before the error %q", got.text)
+		}
+	})
+
+	t.Run("context length error", func(t *testing.T) {
+		stream := "data: {\"error\":{\"type\":\"invalid_request_error\",\"message\":\"This model's maximum context length is 128000 tokens\"}}\n\n"
+		_, err := decodeFixture(stream, decode)
+		if !errors.Is(err, ErrContextLengthExceeded) {
+			t.Errorf("error %v, want ErrContextLengthExceeded", err)
+		}
+	})
+
+	t.Run("truncated final event", func(t *testing.T) {
+		stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\ndata: {\"choices\":[{\"index\":0,\"del"
+		_, err := decodeFixture(stream, decode)
+		if !errors.Is(err, ErrResponseFormat) {
+			t.Errorf("error %v, want ErrResponseFormat", err)
+		}
+	})
+
+	t.Run("no done", func(t *testing.T) {
+		stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n"
+		if _, err := decodeFixture(stream, decode); err != io.ErrUnexpectedEOF {
+			t.Errorf("error %v, want io.ErrUnexpectedEOF", err)
+		}
+	})
+}
+
+const anthropicStreamFixture = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":20,"cache_read_input_tokens":5,"output_tokens":1}}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Greet them."}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"abc"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,
+data:  "delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"!"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\": \"Pa"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"ris\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":2}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestDecodeAnthropicStream(t *testing.T) {
+	t.Run("text, thinking and tool use", func(t *testing.T) {
+		got, err := decodeFixture(anthropicStreamFixture, decodeAnthropicStream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.text != "Hello!" {
+			t.Errorf("text %q", got.text)
+		}
+		if got.thinking != "Greet them." {
+			t.Errorf("thinking %q", got.thinking)
+		}
+		if got.st.finishReason != FinishReasonToolCalls {
+			t.Errorf("finish reason %q", got.st.finishReason)
+		}
+		want := TokenUsage{PromptTokens: 25, CompletionTokens: 15, CachedTokens: 5}
+		if !got.st.hasUsage || got.st.usage != want {
+			t.Errorf("usage %+v, want %+v", got.st.usage, want)
+		}
+	})
+
+	t.Run("mid-stream error", func(t *testing.T) {
+		stream := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n" +
+			"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+		got, err := decodeFixture(stream, decodeAnthropicStream)
+		if !errors.Is(err, ErrOverloaded) || CodeOf(err) != CodeUnavailable {
+			t.Errorf("error %v (%s), want ErrOverloaded", err, CodeOf(err))
+		}
+		if got.text != "Hel" {
+			t.Errorf("text before the error %q", got.text)
+		}
+	})
+
+	t.Run("truncated final event", func(t *testing.T) {
+		stream := "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_re"
+		if _, err := decodeFixture(stream, decodeAnthropicStream); !errors.Is(err, ErrResponseFormat) {
+			t.Errorf("error %v, want ErrResponseFormat", err)
+		}
+	})
+
+	t.Run("no message_stop", func(t *testing.T) {
+		stream := "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":2}}\n\n"
+		if _, err := decodeFixture(stream, decodeAnthropicStream); err != io.ErrUnexpectedEOF {
+			t.Errorf("error %v, want io.ErrUnexpectedEOF", err)
+		}
+	})
+}
+
+const geminiStreamFixture = `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}],"usageMetadata":{"promptTokenCount":7,"totalTokenCount":7}}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":", "},{"text":"world"}]}}]}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":"!"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":4,"totalTokenCount":11,"cachedContentTokenCount":2}}
+
+`
+
+func TestDecodeGeminiStream(t *testing.T) {
+	decode := geminiStreamDecoder(Gemini)
+
+	t.Run("text", func(t *testing.T) {
+		got, err := decodeFixture(geminiStreamFixture, decode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.text != "Hello, world!" {
+			t.Errorf("text %q", got.text)
+		}
+		if got.st.finishReason != FinishReasonStop {
+			t.Errorf("finish reason %q", got.st.finishReason)
+		}
+		want := TokenUsage{PromptTokens: 7, CompletionTokens: 4, TotalTokens: 11, CachedTokens: 2}
+		if got.st.usage != want {
+			t.Errorf("usage %+v, want %+v", got.st.usage, want)
+		}
+	})
+
+	t.Run("blocked prompt", func(t *testing.T) {
+		stream := `data: {"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"HIGH"}]}}` + "\n\n"
+		_, err := decodeFixture(stream, decode)
+		var blocked *SafetyBlockError
+		if !errors.As(err, &blocked) || !blocked.Prompt || blocked.Reason != "SAFETY" {
+			t.Errorf("error %v, want a prompt SafetyBlockError", err)
+		}
+	})
+
+	t.Run("mid-stream error", func(t *testing.T) {
+		stream := `data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n" +
+			`data: {"error":{"code":503,"status":"UNAVAILABLE","message":"The model is overloaded."}}` + "\n\n"
+		got, err := decodeFixture(stream, decode)
+		if code := CodeOf(err); code != CodeUpstream {
+			t.Errorf("code %s, want %s (%v)", code, CodeUpstream, err)
+		}
+		if got.text != "Hel" {
+			t.Errorf("text before the error %q", got.text)
+		}
+	})
+
+	t.Run("truncated final event", func(t *testing.T) {
+		stream := `data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n" + `data: {"candidates":[{"content":{"par`
+		if _, err := decodeFixture(stream, decode); !errors.Is(err, ErrResponseFormat) {
+			t.Errorf("error %v, want ErrResponseFormat", err)
+		}
+	})
+}
+
+// TestStreamEndsEarly delivers the text streamed before the provider's
+// stream broke off, then an error
+func TestStreamEndsEarly(t *testing.T) {
+	provider := newTestProvider(t, config.HTTPConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+	})
+
+	stream, err := provider.InvokeStream(context.Background(), "Say hello", RequestOptions{Provider: OpenAI, Model: "gpt-4o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	var streamErr error
+	for chunk := range stream {
+		text.WriteString(chunk.Delta)
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+	if text.String() != "Hel" {
+		t.Errorf("streamed %q, want Hel", text.String())
+	}
+	if !errors.Is(streamErr, ErrResponseFormat) {
+		t.Errorf("error %v, want ErrResponseFormat", streamErr)
+	}
+}
@@ -0,0 +1,286 @@
+package providers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/document"
+	"github.com/gollmkit/gollmkit/internal/messages"
+)
+
+// ToolCall is a call of a tool requested by the model
+type ToolCall = messages.ToolCall
+
+// Tool describes a function the model may call. Parameters is the JSON
+// schema of the arguments object; nil accepts no arguments.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Tool choices of RequestOptions.ToolChoice besides the name of a tool
+const (
+	ToolChoiceAuto     = "auto"     // the model decides whether to call tools (default)
+	ToolChoiceNone     = "none"     // the model must not call tools
+	ToolChoiceRequired = "required" // the model must call at least one tool
+)
+
+// toolsUnsupportedError reports tools sent to a provider that can't call them
+func toolsUnsupportedError(provider ProviderType) *Error {
+	return &Error{
+		Code:     CodeBadRequest,
+		Provider: provider,
+		Message:  fmt.Sprintf("%s does not support tool calling", providerDisplayName(provider)),
+		Err:      ErrBadRequest,
+	}
+}
+
+// toolParameters returns the parameter schema of tool, an empty object schema if it has none
+func toolParameters(tool Tool) map[string]interface{} {
+	if tool.Parameters == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return tool.Parameters
+}
+
+// toolArguments returns the arguments of call as raw JSON, an empty object if there are none
+func toolArguments(call ToolCall) json.RawMessage {
+	if call.Arguments == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(call.Arguments)
+}
+
+// openAITools adds the tools and tool choice of opts to a chat completions request body
+func openAITools(body map[string]interface{}, opts RequestOptions) {
+	if len(opts.Tools) == 0 {
+		return
+	}
+	tools := make([]interface{}, 0, len(opts.Tools))
+	for _, tool := range opts.Tools {
+		tools = append(tools, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  toolParameters(tool),
+			},
+		})
+	}
+	body["tools"] = tools
+
+	switch opts.ToolChoice {
+	case "":
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		body["tool_choice"] = opts.ToolChoice
+	default:
+		body["tool_choice"] = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": opts.ToolChoice},
+		}
+	}
+}
+
+// openAIMessages converts messages to the chat completions format, in which
+// tool calls are nested in a function object
+func openAIMessages(messages []Message) []interface{} {
+	converted := make([]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		if len(msg.Attachments) > 0 {
+			converted = append(converted, map[string]interface{}{
+				"role":    msg.Role,
+				"content": openAIContentParts(msg),
+			})
+			continue
+		}
+		if len(msg.ToolCalls) == 0 {
+			converted = append(converted, msg)
+			continue
+		}
+
+		calls := make([]interface{}, 0, len(msg.ToolCalls))
+		for _, call := range msg.ToolCalls {
+			calls = append(calls, map[string]interface{}{
+				"id":   call.ID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      call.Name,
+					"arguments": string(toolArguments(call)),
+				},
+			})
+		}
+		converted = append(converted, map[string]interface{}{
+			"role":       msg.Role,
+			"content":    msg.Content,
+			"tool_calls": calls,
+		})
+	}
+	return converted
+}
+
+// openAIContentParts returns the content of a message with attachments as
+// file and image parts followed by its text
+func openAIContentParts(msg Message) []interface{} {
+	parts := make([]interface{}, 0, len(msg.Attachments)+1)
+	for _, attachment := range msg.Attachments {
+		switch {
+		case !attachment.Inline():
+			parts = append(parts, map[string]interface{}{
+				"type": "file",
+				"file": map[string]interface{}{"file_id": attachment.FileID},
+			})
+		case imageTypes[attachment.MIMEType]:
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": dataURL(attachment)},
+			})
+		default:
+			parts = append(parts, map[string]interface{}{
+				"type": "file",
+				"file": map[string]interface{}{"filename": attachment.Filename, "file_data": dataURL(attachment)},
+			})
+		}
+	}
+	if msg.Content != "" {
+		parts = append(parts, map[string]interface{}{"type": "text", "text": msg.Content})
+	}
+	return parts
+}
+
+// parseOpenAIToolCalls returns the tool calls of a chat completions response message
+func parseOpenAIToolCalls(message map[string]interface{}) []ToolCall {
+	items, _ := message["tool_calls"].([]interface{})
+	var calls []ToolCall
+	for _, item := range items {
+		call, _ := item.(map[string]interface{})
+		function, _ := call["function"].(map[string]interface{})
+		id, _ := call["id"].(string)
+		name, _ := function["name"].(string)
+		arguments, _ := function["arguments"].(string)
+		calls = append(calls, ToolCall{ID: id, Name: name, Arguments: arguments})
+	}
+	return calls
+}
+
+// anthropicTools adds the tools and tool choice of opts to a messages API request body
+func anthropicTools(body map[string]interface{}, opts RequestOptions) {
+	if len(opts.Tools) == 0 {
+		return
+	}
+	tools := make([]interface{}, 0, len(opts.Tools))
+	for _, tool := range opts.Tools {
+		tools = append(tools, map[string]interface{}{
+			"name":         tool.Name,
+			"description":  tool.Description,
+			"input_schema": toolParameters(tool),
+		})
+	}
+	body["tools"] = tools
+
+	switch opts.ToolChoice {
+	case "":
+	case ToolChoiceAuto, ToolChoiceNone:
+		body["tool_choice"] = map[string]interface{}{"type": opts.ToolChoice}
+	case ToolChoiceRequired:
+		body["tool_choice"] = map[string]interface{}{"type": "any"}
+	default:
+		body["tool_choice"] = map[string]interface{}{"type": "tool", "name": opts.ToolChoice}
+	}
+}
+
+// anthropicMessages converts messages to the messages API format, in which
+// tool calls are tool_use blocks of the assistant and tool results
+// tool_result blocks of the next user turn
+func anthropicMessages(messages []Message) []interface{} {
+	converted := make([]interface{}, 0, len(messages))
+	var results []interface{} // tool_result blocks of the pending user turn
+	flush := func() {
+		if len(results) > 0 {
+			converted = append(converted, map[string]interface{}{"role": "user", "content": results})
+			results = nil
+		}
+	}
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "tool":
+			results = append(results, map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     msg.Content,
+			})
+		case len(results) > 0 && msg.Role == "user":
+			results = append(results, anthropicContentBlocks(msg)...)
+			flush()
+		case len(msg.Attachments) > 0:
+			flush()
+			converted = append(converted, map[string]interface{}{"role": msg.Role, "content": anthropicContentBlocks(msg)})
+		case len(msg.ToolCalls) > 0:
+			flush()
+			var blocks []interface{}
+			if msg.Content != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Name,
+					"input": toolArguments(call),
+				})
+			}
+			converted = append(converted, map[string]interface{}{"role": msg.Role, "content": blocks})
+		default:
+			flush()
+			converted = append(converted, msg)
+		}
+	}
+	flush()
+	return converted
+}
+
+// anthropicContentBlocks returns the content of a user message as blocks:
+// image or document blocks for its attachments followed by its text
+func anthropicContentBlocks(msg Message) []interface{} {
+	blocks := make([]interface{}, 0, len(msg.Attachments)+1)
+	for _, attachment := range msg.Attachments {
+		block := map[string]interface{}{"type": "document"}
+		if strings.HasPrefix(attachment.MIMEType, "image/") {
+			block["type"] = "image"
+		} else if attachment.Filename != "" {
+			block["title"] = attachment.Filename
+		}
+
+		switch {
+		case !attachment.Inline():
+			block["source"] = map[string]interface{}{"type": "file", "file_id": attachment.FileID}
+		case attachment.MIMEType == document.TypePlain:
+			block["source"] = map[string]interface{}{"type": "text", "media_type": attachment.MIMEType, "data": string(attachment.Data)}
+		default:
+			block["source"] = map[string]interface{}{
+				"type":       "base64",
+				"media_type": attachment.MIMEType,
+				"data":       base64.StdEncoding.EncodeToString(attachment.Data),
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	if msg.Content != "" || len(blocks) == 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "text", "text": msg.Content})
+	}
+	return blocks
+}
+
+// anthropicToolCall converts a tool_use content block into a ToolCall
+func anthropicToolCall(block map[string]interface{}) ToolCall {
+	id, _ := block["id"].(string)
+	name, _ := block["name"].(string)
+	arguments, err := json.Marshal(block["input"])
+	if err != nil || block["input"] == nil {
+		arguments = []byte("{}")
+	}
+	return ToolCall{ID: id, Name: name, Arguments: string(arguments)}
+}
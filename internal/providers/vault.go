@@ -0,0 +1,172 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/document"
+)
+
+// vaultConversation returns the PII vault conversation of a request and a
+// function to call once its response is restored, which forgets the mapping
+// of requests without a ConversationID
+func (p *UnifiedProvider) vaultConversation(opts RequestOptions) (string, func()) {
+	if opts.ConversationID != "" {
+		return opts.ConversationID, func() {}
+	}
+	// Without a conversation the mapping is only needed for this call
+	conversationID := "ephemeral-" + randomID()
+	return conversationID, func() { p.piiVault.Forget(conversationID) }
+}
+
+// tokenizeMessages replaces the PII in the content, tool call arguments and
+// tool results of messages with vault tokens. Inline documents are sent as
+// their tokenized text; attachments whose text can't be read, such as images
+// and uploaded files, are refused, as their PII can't be replaced.
+func (p *UnifiedProvider) tokenizeMessages(conversationID string, messages []Message, provider ProviderType) ([]Message, error) {
+	tokenized := make([]Message, len(messages))
+	for i, msg := range messages {
+		if len(msg.Attachments) > 0 {
+			texts := make([]string, 0, len(msg.Attachments)+1)
+			for _, attachment := range msg.Attachments {
+				text, err := attachmentText(attachment)
+				if err != nil {
+					return nil, attachmentError(provider, "%s", err)
+				}
+				texts = append(texts, documentText(attachment, text))
+			}
+			if msg.Content != "" {
+				texts = append(texts, msg.Content)
+			}
+			msg.Content = strings.Join(texts, "\n\n")
+			msg.Attachments = nil
+		}
+
+		content, err := p.piiVault.Tokenize(conversationID, msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content = content
+
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				if call.Arguments, err = p.piiVault.Tokenize(conversationID, call.Arguments); err != nil {
+					return nil, err
+				}
+				calls[j] = call
+			}
+			msg.ToolCalls = calls
+		}
+		tokenized[i] = msg
+	}
+	return tokenized, nil
+}
+
+// tokenizeInputs replaces the PII in the inputs of a request to a non-chat
+// endpoint, such as embedding inputs or an image prompt, with vault tokens of
+// a conversation of their own. Without a vault inputs are returned as is.
+// Call forget once any text of the result has been restored with
+// detokenizeText.
+func (p *UnifiedProvider) tokenizeInputs(inputs []string) (tokenized []string, conversationID string, forget func(), err error) {
+	if p.piiVault == nil {
+		return inputs, "", func() {}, nil
+	}
+	conversationID, forget = p.vaultConversation(RequestOptions{})
+	tokenized = make([]string, len(inputs))
+	for i, input := range inputs {
+		if tokenized[i], err = p.piiVault.Tokenize(conversationID, input); err != nil {
+			forget()
+			return nil, "", nil, err
+		}
+	}
+	return tokenized, conversationID, forget, nil
+}
+
+// detokenizeText restores the PII in text tokenized by tokenizeInputs
+func (p *UnifiedProvider) detokenizeText(conversationID, text string) (string, error) {
+	if p.piiVault == nil || text == "" {
+		return text, nil
+	}
+	return p.piiVault.Detokenize(conversationID, text)
+}
+
+// attachmentText returns the text of an attachment sent with a PII vault
+func attachmentText(attachment Attachment) (string, error) {
+	name := attachment.Filename
+	if name == "" {
+		name = "attachment"
+	}
+	if !attachment.Inline() {
+		return "", fmt.Errorf("uploaded file %s can't be scanned for PII, attach it inline", name)
+	}
+	text, err := document.ExtractText(attachment.MIMEType, attachment.Data)
+	if err != nil {
+		return "", fmt.Errorf("%s can't be scanned for PII: %w", name, err)
+	}
+	return text, nil
+}
+
+// detokenizeResponse restores the PII in the content, choices and tool call
+// arguments of resp
+func (p *UnifiedProvider) detokenizeResponse(conversationID string, resp *CompletionResponse) error {
+	var err error
+	if resp.Content, err = p.piiVault.Detokenize(conversationID, resp.Content); err != nil {
+		return err
+	}
+	for i := range resp.Choices {
+		if resp.Choices[i].Content, err = p.piiVault.Detokenize(conversationID, resp.Choices[i].Content); err != nil {
+			return err
+		}
+	}
+	for i := range resp.ToolCalls {
+		if resp.ToolCalls[i].Arguments, err = p.piiVault.Detokenize(conversationID, resp.ToolCalls[i].Arguments); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detokenizeStream restores the PII in the deltas of a stream as they arrive.
+// A token split across deltas is held back until it is complete. done is
+// called once the stream has ended.
+func (p *UnifiedProvider) detokenizeStream(ctx context.Context, conversationID string, in <-chan StreamChunk, done func()) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer done()
+		defer close(out)
+		defer func() {
+			// Let the stream end if the caller went away
+			for range in {
+			}
+		}()
+
+		restorer := p.piiVault.NewStreamDetokenizer(conversationID)
+		for chunk := range in {
+			final := chunk.FinishReason != "" || chunk.Usage != nil || chunk.Error != nil
+			delta, err := restorer.Next(chunk.Delta)
+			if err == nil && final {
+				var rest string
+				rest, err = restorer.Flush()
+				delta += rest
+			}
+			if err != nil {
+				sendChunk(ctx, out, StreamChunk{Error: err, RequestID: chunk.RequestID})
+				return
+			}
+
+			chunk.Delta = delta
+			if chunk.Delta == "" && chunk.Thinking == "" && !final {
+				continue
+			}
+			if !sendChunk(ctx, out, chunk) {
+				return
+			}
+		}
+		if rest, err := restorer.Flush(); err != nil || rest != "" {
+			sendChunk(ctx, out, StreamChunk{Delta: rest, Error: err})
+		}
+	}()
+	return out
+}
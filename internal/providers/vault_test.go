@@ -0,0 +1,96 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/pii"
+)
+
+const testEmail = "jane.doe@example.com"
+
+func TestVaultTokenizesEmbeddingInputs(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	provider := newTestProvider(t, config.HTTPConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		sent = append(sent, body.Input...)
+		mu.Unlock()
+
+		data := make([]map[string]interface{}, len(body.Input))
+		for i := range body.Input {
+			data[i] = map[string]interface{}{"index": i, "embedding": []float32{1, 0}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  data,
+			"usage": map[string]int{"prompt_tokens": 8, "total_tokens": 8},
+		})
+	})
+	vault := pii.NewVault("test-key", nil)
+	provider.SetPIIVault(vault)
+
+	resp, err := provider.Embed(context.Background(), []string{"Contact " + testEmail, "No PII here"}, EmbedOptions{Provider: OpenAI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Embeddings) != 2 {
+		t.Fatalf("got %d embeddings, want 2", len(resp.Embeddings))
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent %d inputs, want 2", len(sent))
+	}
+	if strings.Contains(sent[0], testEmail) || !strings.Contains(sent[0], "[[PII_EMAIL_1]]") {
+		t.Errorf("sent %q, want the email replaced by a token", sent[0])
+	}
+	if sent[1] != "No PII here" {
+		t.Errorf("sent %q, want it unchanged", sent[1])
+	}
+	if n := vault.Len(); n != 0 {
+		t.Errorf("vault holds %d conversations after the call, want 0", n)
+	}
+}
+
+func TestVaultTokenizesBatchRequests(t *testing.T) {
+	var uploaded string
+	// Echo the token the provider was sent
+	provider := newTestProvider(t, config.HTTPConfig{}, openAIBatchServer(t, &uploaded, "I'll write to [[PII_EMAIL_1]]"))
+	provider.SetPIIVault(pii.NewVault("test-key", nil))
+	ctx := context.Background()
+
+	batch, err := provider.BatchSubmit(ctx, OpenAI, []BatchRequest{{
+		CustomID: "q1",
+		Messages: []Message{{Role: "user", Content: "Email " + testEmail + " about the invoice"}},
+		Options:  RequestOptions{Model: "gpt-4o"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(uploaded, testEmail) || !strings.Contains(uploaded, "[[PII_EMAIL_1]]") {
+		t.Errorf("uploaded %s, want the email replaced by a token", uploaded)
+	}
+
+	if batch, err = provider.BatchStatus(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	results, err := provider.BatchResults(ctx, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Response == nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if want := "I'll write to " + testEmail; results[0].Response.Content != want {
+		t.Errorf("content %q, want %q", results[0].Response.Content, want)
+	}
+}
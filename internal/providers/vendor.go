@@ -0,0 +1,80 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// vendorProvider sends every request to a single provider. It shares key
+// rotation, usage tracking and middleware with UnifiedProvider but never
+// falls back to another provider.
+type vendorProvider struct {
+	unified *UnifiedProvider
+	encode  func(messages []Message, opts RequestOptions) map[string]interface{}
+	decode  func(result map[string]interface{}, model string) (*CompletionResponse, error)
+}
+
+// newVendorProvider creates a provider pinned to provider
+func newVendorProvider(provider ProviderType, cfg *config.Config, rotator *auth.KeyRotator, validator *auth.KeyValidator) vendorProvider {
+	unified := NewUnifiedProvider(cfg, rotator, validator)
+	unified.pinned = provider
+	return vendorProvider{unified: unified}
+}
+
+// Invoke sends a single prompt to the LLM
+func (p vendorProvider) Invoke(ctx context.Context, prompt string, opts RequestOptions) (*CompletionResponse, error) {
+	return p.unified.Invoke(ctx, prompt, opts)
+}
+
+// InvokeRaw sends prompt as is to the provider's completions endpoint
+func (p vendorProvider) InvokeRaw(ctx context.Context, prompt string, opts RequestOptions) (*CompletionResponse, error) {
+	return p.unified.InvokeRaw(ctx, prompt, opts)
+}
+
+// CodeComplete asks the model for the code between prefix and suffix
+func (p vendorProvider) CodeComplete(ctx context.Context, prefix, suffix string, opts RequestOptions) (*CompletionResponse, error) {
+	return p.unified.CodeComplete(ctx, prefix, suffix, opts)
+}
+
+// Chat sends a series of messages to the LLM through the middleware added with Use
+func (p vendorProvider) Chat(ctx context.Context, messages []Message, opts RequestOptions) (*CompletionResponse, error) {
+	return p.unified.Chat(ctx, messages, opts)
+}
+
+// ChatStream sends a series of messages to the LLM and streams the response
+func (p vendorProvider) ChatStream(ctx context.Context, messages []Message, opts RequestOptions) (<-chan StreamChunk, error) {
+	return p.unified.ChatStream(ctx, messages, opts)
+}
+
+// Use appends middleware wrapping every Chat and Invoke call
+func (p vendorProvider) Use(middleware ...Middleware) {
+	p.unified.Use(middleware...)
+}
+
+// UseStream appends middleware wrapping every ChatStream and InvokeStream call
+func (p vendorProvider) UseStream(middleware ...StreamMiddleware) {
+	p.unified.UseStream(middleware...)
+}
+
+// EncodeRequest returns the JSON request body sent for messages and opts,
+// after opts are merged with the configuration and defaults. No request is sent.
+func (p vendorProvider) EncodeRequest(messages []Message, opts RequestOptions) ([]byte, error) {
+	opts, err := p.unified.resolveOptions(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(p.encode(messages, opts))
+}
+
+// DecodeResponse converts a response body of the provider into a CompletionResponse
+func (p vendorProvider) DecodeResponse(data []byte, model string) (*CompletionResponse, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponseFormat, err)
+	}
+	return p.decode(result, model)
+}
@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+)
+
+// Vertex serves Gemini models through Vertex AI, for GCP organizations that
+// block the API-key based Generative Language API. It authenticates with
+// OAuth: each configured key holds a path to a service account or gcloud
+// credentials file, the credentials JSON itself, or "adc" for Application
+// Default Credentials. The provider config sets project and location.
+const Vertex ProviderType = "vertex"
+
+// vertexURL returns the endpoint of a Vertex AI model method such as generateContent
+func (p *UnifiedProvider) vertexURL(model, method string) (string, error) {
+	providerCfg, err := p.getConfig().GetProvider(string(Vertex))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if providerCfg.Project == "" {
+		return "", fmt.Errorf("%w: vertex provider requires a project", ErrInvalidConfig)
+	}
+
+	location := providerCfg.GetLocation()
+	host := location + "-aiplatform.googleapis.com"
+	if location == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+
+	return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		host,
+		url.PathEscape(providerCfg.Project),
+		url.PathEscape(location),
+		url.PathEscape(model),
+		method), nil
+}
+
+// setVertexHeaders sets the OAuth bearer token for Vertex AI requests
+func (p *UnifiedProvider) setVertexHeaders(ctx context.Context, req *http.Request, key *auth.KeySelection) error {
+	token, err := p.googleAccessToken(ctx, key.Key)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (p *UnifiedProvider) callVertex(ctx context.Context, messages []Message, opts RequestOptions, key *auth.KeySelection) (*CompletionResponse, error) {
+	apiURL, err := p.vertexURL(opts.Model, "generateContent")
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(geminiRequestBody(messages, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if err := p.setVertexHeaders(ctx, req, key); err != nil {
+		p.recordError(ctx, Vertex, key.KeyName, err)
+		return nil, err
+	}
+
+	req, trace := traceRequest(req, opts)
+	start := time.Now()
+	resp, err := p.httpClient(Vertex).Do(req)
+	if err != nil {
+		p.recordError(ctx, Vertex, key.KeyName, err)
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized {
+			p.tokens.invalidate(key.Key)
+		}
+		err = parseAPIError(Vertex, resp)
+		p.recordError(ctx, Vertex, key.KeyName, err)
+		return nil, err
+	}
+	p.recordLatency(Vertex, opts.Model, start)
+
+	var result map[string]interface{}
+	info, err := decodeResponse(resp, trace, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := parseGeminiResponse(result, Vertex, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recordUsage(ctx, Vertex, key.KeyName, opts.Model, completion.Usage)
+
+	completion.ResponseInfo = info
+	completion.ProviderRequestID = providerRequestID(resp.Header)
+	return completion, nil
+}
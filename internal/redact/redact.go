@@ -0,0 +1,112 @@
+// Package redact removes API key material from text, errors and response
+// data before it is returned, logged or persisted
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Placeholder replaces redacted key material
+const Placeholder = "[REDACTED]"
+
+// minSecretLength is the length below which exact secrets aren't redacted,
+// as short values would match unrelated text
+const minSecretLength = 8
+
+// patterns match key material of the built-in providers and credentials in
+// URLs and headers. Where a pattern has a group, only the group is redacted.
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-ant-[a-zA-Z0-9_-]{20,}`),
+	regexp.MustCompile(`\bsk-(?:proj-)?[a-zA-Z0-9_-]{20,}`),
+	regexp.MustCompile(`\bAIza[a-zA-Z0-9_-]{35}`),
+	regexp.MustCompile(`\bxai-[a-zA-Z0-9]{20,}`),
+	regexp.MustCompile(`(?i)[?&](?:key|api_key|access_token)=([^&\s"']+)`),
+	regexp.MustCompile(`(?i)bearer\s+([a-zA-Z0-9._~+/-]+=*)`),
+}
+
+// sensitiveHeaders carry credentials and are redacted entirely
+var sensitiveHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Proxy-Authorization"}
+
+// String redacts the given secrets and anything that looks like key material from s
+func String(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			s = strings.ReplaceAll(s, secret, Placeholder)
+		}
+	}
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatchIndex(match)
+			if len(groups) < 4 || groups[2] < 0 {
+				return Placeholder
+			}
+			return match[:groups[2]] + Placeholder + match[groups[3]:]
+		})
+	}
+	return s
+}
+
+// Error returns err with secrets and key material redacted from its message.
+// The original error is still reachable with errors.Is and errors.As.
+func Error(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	if redacted := String(message, secrets...); redacted != message {
+		return &redactedError{message: redacted, err: err}
+	}
+	return err
+}
+
+// redactedError is an error whose message had key material redacted
+type redactedError struct {
+	message string
+	err     error
+}
+
+// Error returns the redacted message
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the original error
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Header redacts credential headers and key material in the values of h, in place
+func Header(h http.Header, secrets ...string) {
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, Placeholder)
+		}
+	}
+	for name, values := range h {
+		for i, value := range values {
+			values[i] = String(value, secrets...)
+		}
+		h[name] = values
+	}
+}
+
+// Value returns v with secrets and key material redacted from every string it
+// holds, descending into the maps and slices of decoded JSON. Maps and slices
+// are redacted in place.
+func Value(v interface{}, secrets ...string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return String(v, secrets...)
+	case map[string]interface{}:
+		for name, value := range v {
+			v[name] = Value(value, secrets...)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = Value(value, secrets...)
+		}
+	}
+	return v
+}
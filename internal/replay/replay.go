@@ -0,0 +1,155 @@
+// Package replay records LLM request/response pairs and serves them back, for
+// deterministic integration tests and offline development
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// ErrNotRecorded is returned for requests that have no recording
+var ErrNotRecorded = errors.New("no recorded response for request")
+
+// Entry is a recorded request and its response or error
+type Entry struct {
+	Key      string                        `json:"key"`
+	Time     time.Time                     `json:"time"`
+	Messages []providers.Message           `json:"messages"`
+	Options  providers.RequestOptions      `json:"options"`
+	Response *providers.CompletionResponse `json:"response,omitempty"`
+	Error    *providers.ErrorInfo          `json:"error,omitempty"`
+}
+
+// Store persists recorded entries
+type Store interface {
+	// Append records an entry, replacing earlier entries with the same key
+	Append(ctx context.Context, entry Entry) error
+	// Lookup returns the latest entry recorded under key, or ErrNotRecorded
+	Lookup(ctx context.Context, key string) (*Entry, error)
+}
+
+// requestFingerprint holds the parts of a request that identify it for replay.
+// Options that don't change the response, such as timeouts and tenant IDs, are left out.
+type requestFingerprint struct {
+	Messages    []providers.Message    `json:"messages"`
+	Provider    providers.ProviderType `json:"provider,omitempty"`
+	Model       string                 `json:"model,omitempty"`
+	MaxTokens   int                    `json:"max_tokens,omitempty"`
+	Temperature float32                `json:"temperature,omitempty"`
+	TopP        float32                `json:"top_p,omitempty"`
+	Stop        []string               `json:"stop,omitempty"`
+	N           int                    `json:"n,omitempty"`
+	Set         providers.OptionField  `json:"set,omitempty"`
+
+	ReasoningEffort     string `json:"reasoning_effort,omitempty"`
+	MaxCompletionTokens int    `json:"max_completion_tokens,omitempty"`
+	ThinkingBudget      int    `json:"thinking_budget,omitempty"`
+
+	Extra          map[providers.ProviderType]map[string]interface{} `json:"extra,omitempty"`
+	SafetySettings []config.SafetySetting                            `json:"safety_settings,omitempty"`
+	BetaFeatures   []string                                          `json:"beta_features,omitempty"`
+}
+
+// RequestKey returns the key a request is recorded under: a hash of its
+// messages, provider, model and sampling options
+func RequestKey(messages []providers.Message, opts providers.RequestOptions) string {
+	data, _ := json.Marshal(requestFingerprint{
+		Messages:    messages,
+		Provider:    opts.Provider,
+		Model:       opts.Model,
+		MaxTokens:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		Stop:        opts.Stop,
+		N:           opts.N,
+		Set:         opts.Set,
+
+		ReasoningEffort:     opts.ReasoningEffort,
+		MaxCompletionTokens: opts.MaxCompletionTokens,
+		ThinkingBudget:      opts.ThinkingBudget,
+
+		Extra:          opts.Extra,
+		SafetySettings: opts.SafetySettings,
+		BetaFeatures:   opts.BetaFeatures,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record returns middleware that appends every request and its response or
+// error to store. Failing to record doesn't fail the request.
+func Record(store Store) providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+			resp, err := next(ctx, messages, opts)
+			if opts.DryRun {
+				return resp, err
+			}
+
+			entry := Entry{
+				Key:      RequestKey(messages, opts),
+				Time:     time.Now(),
+				Messages: messages,
+				Options:  opts,
+				Response: resp,
+			}
+			if err != nil {
+				info := providers.ErrorInfoOf(err)
+				entry.Error = &info
+			}
+			_ = store.Append(ctx, entry)
+			return resp, err
+		}
+	}
+}
+
+// Provider serves recorded responses without calling any LLM. It implements
+// providers.LLMProvider, so it can stand in for a UnifiedProvider in tests.
+type Provider struct {
+	store Store
+}
+
+// NewProvider creates a provider replaying the entries of store
+func NewProvider(store Store) *Provider {
+	return &Provider{store: store}
+}
+
+// Invoke replays a single prompt
+func (p *Provider) Invoke(ctx context.Context, prompt string, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+	return p.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, opts)
+}
+
+// Chat returns the response recorded for messages and opts. Recorded errors
+// are returned as *providers.Error with their original code.
+func (p *Provider) Chat(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+	key := RequestKey(messages, opts)
+	entry, err := p.store.Lookup(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotRecorded) {
+			return nil, fmt.Errorf("%w (model %q, key %s)", ErrNotRecorded, opts.Model, key)
+		}
+		return nil, err
+	}
+
+	if entry.Error != nil {
+		return nil, &providers.Error{
+			Code:       entry.Error.Code,
+			Provider:   providers.ProviderType(entry.Error.Provider),
+			StatusCode: entry.Error.StatusCode,
+			Message:    entry.Error.Message,
+			RetryAfter: entry.Error.RetryAfter,
+		}
+	}
+	if entry.Response == nil {
+		return nil, fmt.Errorf("recorded entry %s has neither a response nor an error", key)
+	}
+	return entry.Response, nil
+}
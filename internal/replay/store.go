@@ -0,0 +1,171 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// MemoryStore keeps recorded entries in memory
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+// Append implements Store
+func (m *MemoryStore) Append(ctx context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.Key] = entry
+	return nil
+}
+
+// Lookup implements Store
+func (m *MemoryStore) Lookup(ctx context.Context, key string) (*Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exists := m.entries[key]
+	if !exists {
+		return nil, ErrNotRecorded
+	}
+	return &entry, nil
+}
+
+// JSONLStore records entries to a file, one JSON object per line. Existing
+// recordings are loaded when the store is opened, so the file can be checked
+// in next to the tests replaying it.
+type JSONLStore struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries *MemoryStore
+}
+
+// maxEntrySize bounds a single line of a JSONL recording
+const maxEntrySize = 16 << 20
+
+// OpenJSONLStore opens the recording at path, creating it if it doesn't exist
+func OpenJSONLStore(path string) (*JSONLStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+
+	entries := NewMemoryStore()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid recording %s line %d: %w", path, line, err)
+		}
+		entries.entries[entry.Key] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return &JSONLStore{file: file, entries: entries}, nil
+}
+
+// Append implements Store
+func (s *JSONLStore) Append(ctx context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return s.entries.Append(ctx, entry)
+}
+
+// Lookup implements Store
+func (s *JSONLStore) Lookup(ctx context.Context, key string) (*Entry, error) {
+	return s.entries.Lookup(ctx, key)
+}
+
+// Close closes the recording file
+func (s *JSONLStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// tableNamePattern matches table names that are safe to interpolate into SQL
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore records entries in a SQL table, one JSON-encoded entry per row. It
+// works with any database/sql driver that accepts "?" placeholders and
+// INSERT ... ON CONFLICT upserts, such as SQLite.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore creates a recording store on db, creating table if it doesn't exist
+func NewSQLStore(ctx context.Context, db *sql.DB, table string) (*SQLStore, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid recording table name %q", table)
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		request_key TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		recorded_at TIMESTAMP NOT NULL
+	)`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording table: %w", err)
+	}
+	return &SQLStore{db: db, table: table}, nil
+}
+
+// Append implements Store
+func (s *SQLStore) Append(ctx context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (request_key, data, recorded_at) VALUES (?, ?, ?)
+		ON CONFLICT(request_key) DO UPDATE SET data = excluded.data, recorded_at = excluded.recorded_at`, s.table),
+		entry.Key, string(data), entry.Time)
+	return err
+}
+
+// Lookup implements Store
+func (s *SQLStore) Lookup(ctx context.Context, key string) (*Entry, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data FROM %s WHERE request_key = ?", s.table), key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotRecorded
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, fmt.Errorf("invalid recorded entry: %w", err)
+	}
+	return &entry, nil
+}
@@ -0,0 +1,326 @@
+// Package session keeps conversation history across LLM calls and persists it
+// to a pluggable store
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/logging"
+	"github.com/gollmkit/gollmkit/internal/providers"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+)
+
+// ErrNotFound is returned by stores for unknown session IDs
+var ErrNotFound = errors.New("session not found")
+
+// DefaultMaxHistoryTokens is the history budget of sessions that don't set one
+const DefaultMaxHistoryTokens = 4000
+
+// Summarization defaults
+const (
+	DefaultSummaryKeepRecent = 4
+	DefaultSummaryPrompt     = "Summarize the conversation below for your own future reference. " +
+		"Keep names, facts, decisions and open questions; omit pleasantries. " +
+		"If a previous summary is given, merge it into the new one. Reply with the summary only."
+)
+
+// Chatter sends a conversation to an LLM. It is implemented by providers.UnifiedProvider.
+type Chatter interface {
+	Chat(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error)
+}
+
+// Options configures a session
+type Options struct {
+	// SystemPrompt is sent before the history on every call and never truncated
+	SystemPrompt string
+
+	// MaxHistoryTokens bounds the history kept and sent with each call. Older
+	// messages are dropped once it is exceeded. Set it to the model's context
+	// window minus room for the response. Defaults to DefaultMaxHistoryTokens.
+	MaxHistoryTokens int
+
+	// RequestOptions are used for every call of the session. ConversationID
+	// defaults to the session ID.
+	RequestOptions providers.RequestOptions
+
+	// Summary enables compressing older turns into a running summary instead
+	// of dropping them
+	Summary *SummaryOptions
+}
+
+// SummaryOptions configures automatic summarization of long histories
+type SummaryOptions struct {
+	// Threshold is the history size in tokens above which older turns are
+	// summarized. Defaults to three quarters of MaxHistoryTokens.
+	Threshold int
+
+	// KeepRecent is how many of the latest messages are kept verbatim.
+	// Defaults to DefaultSummaryKeepRecent.
+	KeepRecent int
+
+	// RequestOptions select the model that writes summaries, typically a
+	// cheap one. Provider defaults to the session's provider; other unset
+	// fields fall back to the provider's configuration.
+	RequestOptions providers.RequestOptions
+
+	// Prompt instructs the summarizing model. Defaults to DefaultSummaryPrompt.
+	Prompt string
+}
+
+// Record is the persisted state of a session
+type Record struct {
+	ID        string              `json:"id"`
+	Messages  []providers.Message `json:"messages"`
+	Summary   string              `json:"summary,omitempty"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// Manager opens sessions backed by a store
+type Manager struct {
+	provider Chatter
+	store    Store
+	logger   logging.Logger
+}
+
+// NewManager creates a session manager. A nil store keeps sessions in memory.
+func NewManager(provider Chatter, store Store) *Manager {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Manager{provider: provider, store: store}
+}
+
+// SetLogger sets the logger receiving failed summaries of the sessions
+// started afterwards, slog.Default() if unset
+func (m *Manager) SetLogger(logger logging.Logger) {
+	m.logger = logger
+}
+
+// New starts a session with a random ID
+func (m *Manager) New(opts Options) *Session {
+	return m.newSession(Record{ID: newID()}, opts)
+}
+
+// Open resumes the session with the given ID, or starts it if the store has no record of it
+func (m *Manager) Open(ctx context.Context, id string, opts Options) (*Session, error) {
+	record, err := m.store.Load(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return m.newSession(Record{ID: id}, opts), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", id, err)
+	}
+	return m.newSession(*record, opts), nil
+}
+
+// Delete removes a session from the store
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	return m.store.Delete(ctx, id)
+}
+
+// newSession creates a session from its persisted record
+func (m *Manager) newSession(record Record, opts Options) *Session {
+	if opts.MaxHistoryTokens <= 0 {
+		opts.MaxHistoryTokens = DefaultMaxHistoryTokens
+	}
+	if opts.RequestOptions.ConversationID == "" {
+		opts.RequestOptions.ConversationID = record.ID
+	}
+	if opts.Summary != nil {
+		summary := *opts.Summary
+		if summary.Threshold <= 0 {
+			summary.Threshold = opts.MaxHistoryTokens * 3 / 4
+		}
+		if summary.KeepRecent <= 0 {
+			summary.KeepRecent = DefaultSummaryKeepRecent
+		}
+		if summary.Prompt == "" {
+			summary.Prompt = DefaultSummaryPrompt
+		}
+		if summary.RequestOptions.Provider == "" {
+			summary.RequestOptions.Provider = opts.RequestOptions.Provider
+		}
+		if summary.RequestOptions.ConversationID == "" {
+			summary.RequestOptions.ConversationID = record.ID
+		}
+		opts.Summary = &summary
+	}
+	return &Session{
+		provider: m.provider,
+		store:    m.store,
+		logger:   m.logger,
+		opts:     opts,
+		record:   record,
+	}
+}
+
+// Session is a conversation whose history is sent with every call. It is
+// safe for concurrent use; calls on one session are serialized.
+type Session struct {
+	mu       sync.Mutex
+	provider Chatter
+	store    Store
+	logger   logging.Logger
+	opts     Options
+	record   Record
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.record.ID
+}
+
+// Summary returns the running summary of turns no longer kept verbatim
+func (s *Session) Summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record.Summary
+}
+
+// History returns a copy of the messages kept in the session
+func (s *Session) History() []providers.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]providers.Message(nil), s.record.Messages...)
+}
+
+// Send adds a user message to the session, sends the conversation and adds
+// the reply to the history. The session is saved after each exchange. On
+// error the history is left unchanged.
+func (s *Session) Send(ctx context.Context, content string) (*providers.CompletionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := append(append([]providers.Message(nil), s.record.Messages...),
+		providers.Message{Role: "user", Content: content})
+	history = s.truncate(history)
+
+	resp, err := s.provider.Chat(ctx, s.conversation(history), s.opts.RequestOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	history = append(history, providers.Message{Role: "assistant", Content: resp.Content})
+	history = s.summarize(ctx, history)
+	s.record.Messages = s.truncate(history)
+	s.record.UpdatedAt = time.Now()
+
+	if err := s.store.Save(ctx, s.record); err != nil {
+		return resp, fmt.Errorf("failed to save session %s: %w", s.record.ID, err)
+	}
+	return resp, nil
+}
+
+// Reset clears the history of the session and saves it
+func (s *Session) Reset(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record.Messages = nil
+	s.record.Summary = ""
+	s.record.UpdatedAt = time.Now()
+	return s.store.Save(ctx, s.record)
+}
+
+// conversation prepends the system prompt and the running summary to history
+func (s *Session) conversation(history []providers.Message) []providers.Message {
+	system := s.systemContent()
+	if system == "" {
+		return history
+	}
+	return append([]providers.Message{{Role: "system", Content: system}}, history...)
+}
+
+// systemContent returns the system prompt followed by the running summary
+func (s *Session) systemContent() string {
+	if s.record.Summary == "" {
+		return s.opts.SystemPrompt
+	}
+
+	summary := "Summary of the earlier conversation:\n" + s.record.Summary
+	if s.opts.SystemPrompt == "" {
+		return summary
+	}
+	return s.opts.SystemPrompt + "\n\n" + summary
+}
+
+// summarize compresses all but the most recent messages of history into the
+// running summary once history exceeds the summary threshold. If the summary
+// can't be written, history is returned unchanged and truncation applies.
+func (s *Session) summarize(ctx context.Context, history []providers.Message) []providers.Message {
+	summary := s.opts.Summary
+	if summary == nil || s.countTokens(history) <= summary.Threshold {
+		return history
+	}
+
+	// Keep the recent turns starting at a user message
+	split := len(history) - summary.KeepRecent
+	for split > 0 && split < len(history) && history[split].Role != "user" {
+		split++
+	}
+	if split <= 0 || split >= len(history) {
+		return history
+	}
+
+	var transcript strings.Builder
+	if s.record.Summary != "" {
+		fmt.Fprintf(&transcript, "Previous summary:\n%s\n\n", s.record.Summary)
+	}
+	transcript.WriteString("Conversation:\n")
+	for _, msg := range history[:split] {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	resp, err := s.provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: summary.Prompt},
+		{Role: "user", Content: transcript.String()},
+	}, summary.RequestOptions)
+	if err != nil {
+		logging.OrDefault(s.logger).Warn("gollmkit: failed to summarize session, truncating instead", "session", s.record.ID, "error", err)
+		return history
+	}
+
+	s.record.Summary = strings.TrimSpace(resp.Content)
+	return history[split:]
+}
+
+// countTokens returns the token count of the contents of messages
+func (s *Session) countTokens(messages []providers.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += tokenizer.CountTokens(s.opts.RequestOptions.Model, msg.Content)
+	}
+	return total
+}
+
+// truncate drops the oldest messages of history until it fits the token
+// budget, which also covers the system prompt and summary. The latest message is always kept, and the history never starts
+// with an assistant message since providers expect the user to speak first.
+func (s *Session) truncate(history []providers.Message) []providers.Message {
+	model := s.opts.RequestOptions.Model
+	budget := s.opts.MaxHistoryTokens - tokenizer.CountTokens(model, s.systemContent())
+	total := s.countTokens(history)
+
+	start := 0
+	for start < len(history)-1 && (total > budget || history[start].Role == "assistant") {
+		total -= tokenizer.CountTokens(model, history[start].Content)
+		start++
+	}
+	return history[start:]
+}
+
+// newID returns a random session ID
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// Store persists session records keyed by session ID
+type Store interface {
+	// Load returns the record of a session, or ErrNotFound
+	Load(ctx context.Context, id string) (*Record, error)
+	Save(ctx context.Context, record Record) error
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps sessions in memory
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty in-memory session store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+// Load implements Store
+func (m *MemoryStore) Load(ctx context.Context, id string) (*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, exists := m.records[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	record.Messages = append([]providers.Message(nil), record.Messages...)
+	return &record, nil
+}
+
+// Save implements Store
+func (m *MemoryStore) Save(ctx context.Context, record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record.Messages = append([]providers.Message(nil), record.Messages...)
+	m.records[record.ID] = record
+	return nil
+}
+
+// Delete implements Store
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, id)
+	return nil
+}
+
+// tableNamePattern matches table names that are safe to interpolate into SQL
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore keeps sessions in a SQL table, one JSON-encoded record per row. It
+// works with any database/sql driver that accepts "?" placeholders and
+// INSERT ... ON CONFLICT upserts, such as SQLite.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore creates a session store on db, creating table if it doesn't exist
+func NewSQLStore(ctx context.Context, db *sql.DB, table string) (*SQLStore, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid session table name %q", table)
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session table: %w", err)
+	}
+	return &SQLStore{db: db, table: table}, nil
+}
+
+// Load implements Store
+func (s *SQLStore) Load(ctx context.Context, id string) (*Record, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data FROM %s WHERE id = ?", s.table), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord([]byte(data))
+}
+
+// Save implements Store
+func (s *SQLStore) Save(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (id, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`, s.table),
+		record.ID, string(data), record.UpdatedAt)
+	return err
+}
+
+// Delete implements Store
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", s.table), id)
+	return err
+}
+
+// KV is a minimal key-value client. Adapt a Redis client to it to keep
+// sessions in Redis.
+type KV interface {
+	// Get returns the value of key and whether it exists
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set stores value under key, expiring it after ttl unless ttl is zero
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// KVStore keeps sessions in a key-value store such as Redis, one
+// JSON-encoded record per key
+type KVStore struct {
+	kv     KV
+	prefix string
+	ttl    time.Duration
+}
+
+// NewKVStore creates a session store on kv. Keys are the session ID with
+// prefix prepended; sessions expire ttl after their last save unless ttl is zero.
+func NewKVStore(kv KV, prefix string, ttl time.Duration) *KVStore {
+	return &KVStore{kv: kv, prefix: prefix, ttl: ttl}
+}
+
+// Load implements Store
+func (s *KVStore) Load(ctx context.Context, id string) (*Record, error) {
+	data, exists, err := s.kv.Get(ctx, s.prefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return decodeRecord([]byte(data))
+}
+
+// Save implements Store
+func (s *KVStore) Save(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.kv.Set(ctx, s.prefix+record.ID, string(data), s.ttl)
+}
+
+// Delete implements Store
+func (s *KVStore) Delete(ctx context.Context, id string) error {
+	return s.kv.Del(ctx, s.prefix+id)
+}
+
+// decodeRecord parses a JSON-encoded session record
+func decodeRecord(data []byte) (*Record, error) {
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid session record: %w", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,147 @@
+// Package tenant attributes LLM usage to tenants and enforces per-tenant quotas
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// Errors returned when a tenant may not send a request
+var (
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+	ErrRateLimited   = errors.New("tenant rate limited")
+)
+
+// contextKey is the type of the tenant context key
+type contextKey struct{}
+
+// WithTenant returns a context carrying the tenant ID
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant ID carried by ctx, if any
+func FromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(contextKey{}).(string)
+	return tenantID
+}
+
+// rateWindow is the window of TenantConfig.RateLimit
+const rateWindow = time.Minute
+
+// Manager records tenant usage and enforces tenant quotas and rate limits.
+// Limits are passed per call so configuration reloads apply immediately.
+type Manager struct {
+	mu       sync.Mutex
+	usage    map[string]*auth.UsageSeries
+	requests map[string][]time.Time // tenant -> request times within the rate window
+}
+
+// NewManager creates a tenant manager without recorded usage
+func NewManager() *Manager {
+	return &Manager{
+		usage:    make(map[string]*auth.UsageSeries),
+		requests: make(map[string][]time.Time),
+	}
+}
+
+// Allow checks the quotas and rate limit of a tenant and, if the request may
+// proceed, counts it against the rate limit
+func (m *Manager) Allow(tenantID string, limits config.TenantConfig, now time.Time) error {
+	return m.Reserve(tenantID, limits, 1, 0, now)
+}
+
+// Reserve checks like Allow that a tenant may send n requests expected to
+// cost up to cost, e.g. a batch, without exceeding its quotas or rate limit,
+// and counts them against the rate limit. The cost isn't recorded; Record
+// the actual usage once it is known.
+func (m *Manager) Reserve(tenantID string, limits config.TenantConfig, n int, cost float64, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var today, month float64
+	if series, exists := m.usage[tenantID]; exists {
+		today = auth.SumPoints(series.Points(auth.ResolutionDay, now)).Cost
+		since, _, _ := auth.WindowThisMonth.Range(now)
+		month = auth.SumPoints(series.Points(auth.ResolutionDay, since)).Cost
+	}
+	if limits.DailyCostLimit > 0 && exceeds(today, cost, limits.DailyCostLimit) {
+		return quotaError(tenantID, "daily", today, limits.DailyCostLimit, cost)
+	}
+	if limits.MonthlyCostLimit > 0 && exceeds(month, cost, limits.MonthlyCostLimit) {
+		return quotaError(tenantID, "monthly", month, limits.MonthlyCostLimit, cost)
+	}
+
+	if limits.RateLimit > 0 {
+		recent := m.requests[tenantID][:0]
+		for _, at := range m.requests[tenantID] {
+			if now.Sub(at) < rateWindow {
+				recent = append(recent, at)
+			}
+		}
+		if len(recent)+n > limits.RateLimit {
+			m.requests[tenantID] = recent
+			return fmt.Errorf("%w: tenant %s exceeded %d requests per minute",
+				ErrRateLimited, tenantID, limits.RateLimit)
+		}
+		for i := 0; i < n; i++ {
+			recent = append(recent, now)
+		}
+		m.requests[tenantID] = recent
+	}
+
+	return nil
+}
+
+// exceeds reports whether a tenant that spent spent may not spend cost more
+// within limit
+func exceeds(spent, cost, limit float64) bool {
+	return spent >= limit || spent+cost > limit
+}
+
+// quotaError describes a request refused by a cost limit of a tenant
+func quotaError(tenantID, period string, spent, limit, cost float64) error {
+	if cost > 0 {
+		return fmt.Errorf("%w: tenant %s spent $%.2f of its $%.2f %s limit, requests would cost up to $%.2f",
+			ErrQuotaExceeded, tenantID, spent, limit, period, cost)
+	}
+	return fmt.Errorf("%w: tenant %s spent $%.2f of its $%.2f %s limit",
+		ErrQuotaExceeded, tenantID, spent, limit, period)
+}
+
+// Record attributes the tokens and cost of a completed request to a tenant
+func (m *Manager) Record(tenantID string, tokens int, cost float64, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, exists := m.usage[tenantID]
+	if !exists {
+		series = auth.NewUsageSeries()
+		m.usage[tenantID] = series
+	}
+	series.Add(at, tokens, cost)
+}
+
+// Usage returns the usage of a tenant within a window
+func (m *Manager) Usage(tenantID string, window auth.Window, now time.Time) (*auth.UsagePoint, error) {
+	since, res, err := window.Range(now)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := auth.UsagePoint{Start: since}
+	if series, exists := m.usage[tenantID]; exists {
+		total = auth.SumPoints(series.Points(res, since))
+		total.Start = since
+	}
+	return &total, nil
+}
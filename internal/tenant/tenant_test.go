@@ -0,0 +1,39 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+func TestReserve(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	limits := config.TenantConfig{DailyCostLimit: 1, RateLimit: 5}
+
+	m := NewManager()
+	if err := m.Reserve("acme", limits, 3, 0.6, now); err != nil {
+		t.Fatal(err)
+	}
+	m.Record("acme", 1000, 0.6, now)
+
+	// Another 0.6 would exceed the daily limit
+	if err := m.Reserve("acme", limits, 1, 0.6, now); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := m.Reserve("acme", limits, 1, 0.3, now); err != nil {
+		t.Errorf("expected the reservation to fit, got %v", err)
+	}
+
+	// 4 of 5 requests were counted in this minute
+	if err := m.Reserve("acme", limits, 2, 0, now); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if err := m.Allow("acme", limits, now); err != nil {
+		t.Errorf("expected the last request to be allowed, got %v", err)
+	}
+	if err := m.Allow("acme", limits, now.Add(time.Minute)); err != nil {
+		t.Errorf("expected the rate limit to reset, got %v", err)
+	}
+}
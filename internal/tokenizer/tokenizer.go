@@ -0,0 +1,131 @@
+// Package tokenizer provides token counting for LLM models
+package tokenizer
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// Tokenizer counts tokens for a piece of text
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc adapts a plain function to the Tokenizer interface
+type TokenizerFunc func(text string) int
+
+// CountTokens calls f(text)
+func (f TokenizerFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// charsPerToken is the average characters per token used by the fallback estimator
+const charsPerToken = 4
+
+// Default is the fallback tokenizer used for models without a registered tokenizer
+var Default Tokenizer = TokenizerFunc(estimateTokens)
+
+var (
+	mu       sync.RWMutex
+	exact    = make(map[string]Tokenizer) // model name -> tokenizer
+	prefixes = make(map[string]Tokenizer) // model name prefix -> tokenizer
+)
+
+// Register registers a tokenizer for a model name. A trailing "*" registers
+// the tokenizer for every model starting with the given prefix, e.g. "llama-3-*".
+func Register(model string, t Tokenizer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if prefix, ok := strings.CutSuffix(model, "*"); ok {
+		prefixes[prefix] = t
+		return
+	}
+	exact[model] = t
+}
+
+// Unregister removes a previously registered tokenizer
+func Unregister(model string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if prefix, ok := strings.CutSuffix(model, "*"); ok {
+		delete(prefixes, prefix)
+		return
+	}
+	delete(exact, model)
+}
+
+// Get returns the tokenizer for a model. Exact registrations win over prefix
+// registrations, the longest matching prefix wins, and Default is used otherwise.
+func Get(model string) Tokenizer {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if t, exists := exact[model]; exists {
+		return t
+	}
+
+	var best Tokenizer
+	bestLen := -1
+	for prefix, t := range prefixes {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best = t
+			bestLen = len(prefix)
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	return Default
+}
+
+// CountTokens counts the tokens in text using the tokenizer for model
+func CountTokens(model, text string) int {
+	return Get(model).CountTokens(text)
+}
+
+// EstimateCost estimates the cost of sending prompt to a model and receiving
+// outputTokens tokens back, using the model's configured pricing
+func EstimateCost(model *config.ModelConfig, prompt string, outputTokens int) float64 {
+	return model.CalculateCost(CountTokens(model.Name, prompt), outputTokens)
+}
+
+// Truncate shortens text so that it fits within maxTokens for the given model.
+// Text is cut on a rune boundary; the original text is returned if it already fits.
+func Truncate(model, text string, maxTokens int) string {
+	t := Get(model)
+	if maxTokens <= 0 {
+		return ""
+	}
+	if t.CountTokens(text) <= maxTokens {
+		return text
+	}
+
+	// Binary search for the longest rune prefix that fits
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if t.CountTokens(string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return string(runes[:lo])
+}
+
+// estimateTokens approximates the token count from the character count
+func estimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	if n == 0 {
+		return 0
+	}
+	return (n + charsPerToken - 1) / charsPerToken
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Schema returns the JSON schema of the value v encodes to, generated from
+// its type. Struct fields are named by their json tags and required unless
+// they are pointers or tagged omitempty. A field's description and enum tags
+// set the description and allowed values, e.g.
+//
+//	City  string `json:"city" description:"City name, e.g. Paris"`
+//	Units string `json:"units,omitempty" enum:"celsius,fahrenheit"`
+func Schema(v interface{}) map[string]interface{} {
+	if v == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return typeSchema(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+// typeSchema returns the schema of t. seen holds the structs being
+// generated, whose recursive uses are left unconstrained.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawJSONType:
+		return map[string]interface{}{}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		return map[string]interface{}{} // encodes itself, its shape is unknown
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the object schema of a struct type
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	addFields(t, seen, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the properties of the exported fields of t, including
+// those of embedded structs, as encoding/json does
+func addFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(embedded, seen, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := typeSchema(field.Type, seen)
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+
+		omitempty := false
+		for _, option := range strings.Split(options, ",") {
+			omitempty = omitempty || option == "omitempty" || option == "omitzero"
+		}
+		if !omitempty && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
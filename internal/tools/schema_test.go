@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type address struct {
+	Street string `json:"street"`
+	City   string `json:"city,omitempty"`
+}
+
+type base struct {
+	ID string `json:"id" description:"Record ID"`
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children,omitempty"`
+}
+
+type object = map[string]interface{}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want object
+	}{
+		{
+			name: "nil",
+			v:    nil,
+			want: object{"type": "object", "properties": object{}},
+		},
+		{
+			name: "required and omitempty",
+			v: struct {
+				Name  string  `json:"name"`
+				Age   int     `json:"age,omitempty"`
+				Score float64 `json:"score,omitzero"`
+				Plain bool
+			}{},
+			want: object{
+				"type": "object",
+				"properties": object{
+					"name":  object{"type": "string"},
+					"age":   object{"type": "integer"},
+					"score": object{"type": "number"},
+					"Plain": object{"type": "boolean"},
+				},
+				"required": []string{"name", "Plain"},
+			},
+		},
+		{
+			name: "skipped fields",
+			v: struct {
+				Visible string `json:"visible"`
+				Secret  string `json:"-"`
+				hidden  string
+			}{},
+			want: object{
+				"type":       "object",
+				"properties": object{"visible": object{"type": "string"}},
+				"required":   []string{"visible"},
+			},
+		},
+		{
+			name: "pointers",
+			v: &struct {
+				Nickname *string  `json:"nickname"`
+				Home     *address `json:"home"`
+				Count    **int    `json:"count"`
+			}{},
+			want: object{
+				"type": "object",
+				"properties": object{
+					"nickname": object{"type": "string"},
+					"home": object{
+						"type": "object",
+						"properties": object{
+							"street": object{"type": "string"},
+							"city":   object{"type": "string"},
+						},
+						"required": []string{"street"},
+					},
+					"count": object{"type": "integer"},
+				},
+			},
+		},
+		{
+			name: "nested structs",
+			v: struct {
+				Address address `json:"address" description:"Delivery address"`
+			}{},
+			want: object{
+				"type": "object",
+				"properties": object{
+					"address": object{
+						"type": "object",
+						"properties": object{
+							"street": object{"type": "string"},
+							"city":   object{"type": "string"},
+						},
+						"required":    []string{"street"},
+						"description": "Delivery address",
+					},
+				},
+				"required": []string{"address"},
+			},
+		},
+		{
+			name: "slices, arrays and maps",
+			v: struct {
+				Tags      []string          `json:"tags,omitempty"`
+				Stops     []*address        `json:"stops"`
+				Point     [2]float32        `json:"point"`
+				Data      []byte            `json:"data,omitempty"`
+				Labels    map[string]string `json:"labels,omitempty"`
+				Rows      [][]int           `json:"rows,omitempty"`
+				Anything  interface{}       `json:"anything,omitempty"`
+				Raw       json.RawMessage   `json:"raw,omitempty"`
+				CreatedAt time.Time         `json:"created_at"`
+			}{},
+			want: object{
+				"type": "object",
+				"properties": object{
+					"tags": object{"type": "array", "items": object{"type": "string"}},
+					"stops": object{"type": "array", "items": object{
+						"type": "object",
+						"properties": object{
+							"street": object{"type": "string"},
+							"city":   object{"type": "string"},
+						},
+						"required": []string{"street"},
+					}},
+					"point":      object{"type": "array", "items": object{"type": "number"}},
+					"data":       object{"type": "string", "contentEncoding": "base64"},
+					"labels":     object{"type": "object", "additionalProperties": object{"type": "string"}},
+					"rows":       object{"type": "array", "items": object{"type": "array", "items": object{"type": "integer"}}},
+					"anything":   object{},
+					"raw":        object{},
+					"created_at": object{"type": "string", "format": "date-time"},
+				},
+				"required": []string{"stops", "point", "created_at"},
+			},
+		},
+		{
+			name: "embedded structs",
+			v: struct {
+				base
+				address
+				Note string `json:"note" enum:"a,b"`
+			}{},
+			want: object{
+				"type": "object",
+				"properties": object{
+					"id":     object{"type": "string", "description": "Record ID"},
+					"street": object{"type": "string"},
+					"city":   object{"type": "string"},
+					"note":   object{"type": "string", "enum": []string{"a", "b"}},
+				},
+				"required": []string{"id", "street", "note"},
+			},
+		},
+		{
+			name: "recursive structs",
+			v:    node{},
+			want: object{
+				"type": "object",
+				"properties": object{
+					"name":     object{"type": "string"},
+					"children": object{"type": "array", "items": object{"type": "object"}},
+				},
+				"required": []string{"name"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Schema(tt.v)
+			if !reflect.DeepEqual(got, tt.want) {
+				gotJSON, _ := json.MarshalIndent(got, "", "  ")
+				wantJSON, _ := json.MarshalIndent(tt.want, "", "  ")
+				t.Errorf("schema:\n%s\nwant:\n%s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestNewDecodesArguments(t *testing.T) {
+	type args struct {
+		City  string   `json:"city"`
+		Units *string  `json:"units"`
+		Days  []int    `json:"days,omitempty"`
+		Home  *address `json:"home,omitempty"`
+	}
+	var received args
+	tool := New("forecast", args{}, func(ctx context.Context, a args) (any, error) {
+		received = a
+		return map[string]int{"days": len(a.Days)}, nil
+	})
+
+	if def := tool.Definition(); def.Name != "forecast" || !reflect.DeepEqual(def.Parameters, Schema(args{})) {
+		t.Errorf("definition %+v doesn't carry the schema of the arguments", def)
+	}
+	result, err := tool.Call(context.Background(), `{"city":"Paris","days":[1,2],"home":{"street":"Rue de Rivoli"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != `{"days":2}` {
+		t.Errorf("result %s, want {\"days\":2}", result)
+	}
+	if received.City != "Paris" || received.Units != nil || received.Home == nil || received.Home.Street != "Rue de Rivoli" {
+		t.Errorf("decoded %+v", received)
+	}
+
+	if _, err := tool.Call(context.Background(), `{"city":`); err == nil {
+		t.Error("malformed arguments were accepted")
+	}
+}
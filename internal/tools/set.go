@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// DefaultMaxRounds is the number of model calls per conversation of sets that don't set one
+const DefaultMaxRounds = 10
+
+// ErrMaxRounds is returned when the model still calls tools after the maximum number of rounds
+var ErrMaxRounds = errors.New("model still calls tools after the maximum number of rounds")
+
+// Chatter sends a conversation to an LLM. It is implemented by providers.UnifiedProvider.
+type Chatter interface {
+	Chat(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error)
+}
+
+// Set is a group of tools offered to the model together, whose calls it
+// executes. It is safe for concurrent use.
+type Set struct {
+	mu        sync.RWMutex
+	tools     []*Tool
+	maxRounds int
+}
+
+// NewSet creates a set of tools
+func NewSet(tools ...*Tool) *Set {
+	s := &Set{maxRounds: DefaultMaxRounds}
+	s.Add(tools...)
+	return s
+}
+
+// WithMaxRounds sets the number of model calls after which Chat gives up
+// with ErrMaxRounds, and returns the set
+func (s *Set) WithMaxRounds(n int) *Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > 0 {
+		s.maxRounds = n
+	}
+	return s
+}
+
+// Add adds tools to the set. Adding a tool with the name of an existing one replaces it.
+func (s *Set) Add(tools ...*Tool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+outer:
+	for _, tool := range tools {
+		for i := range s.tools {
+			if s.tools[i].Name() == tool.Name() {
+				s.tools[i] = tool
+				continue outer
+			}
+		}
+		s.tools = append(s.tools, tool)
+	}
+}
+
+// Get returns the tool with the given name
+func (s *Set) Get(name string) (*Tool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, tool := range s.tools {
+		if tool.Name() == name {
+			return tool, true
+		}
+	}
+	return nil, false
+}
+
+// Definitions returns the definitions of the tools, in the order they were added
+func (s *Set) Definitions() []providers.Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	definitions := make([]providers.Tool, 0, len(s.tools))
+	for _, tool := range s.tools {
+		definitions = append(definitions, tool.Definition())
+	}
+	return definitions
+}
+
+// Apply returns opts with the tools of the set added to opts.Tools. Tools
+// already in opts.Tools take precedence over those of the set with the same name.
+func (s *Set) Apply(opts providers.RequestOptions) providers.RequestOptions {
+	offered := make(map[string]bool, len(opts.Tools))
+	for _, tool := range opts.Tools {
+		offered[tool.Name] = true
+	}
+	tools := append([]providers.Tool(nil), opts.Tools...)
+	for _, definition := range s.Definitions() {
+		if !offered[definition.Name] {
+			tools = append(tools, definition)
+		}
+	}
+	opts.Tools = tools
+	return opts
+}
+
+// Execute runs calls concurrently and returns their results as tool
+// messages, in the order of calls. Errors, panics and calls of unknown
+// tools are sent back as the result, so the model can correct its call.
+func (s *Set) Execute(ctx context.Context, calls []providers.ToolCall) []providers.Message {
+	results := make([]providers.Message, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call providers.ToolCall) {
+			defer wg.Done()
+			results[i] = providers.Message{Role: "tool", ToolCallID: call.ID, Content: s.execute(ctx, call)}
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+// execute runs the tool of call and returns its result, or the error to send back
+func (s *Set) execute(ctx context.Context, call providers.ToolCall) (output string) {
+	tool, exists := s.Get(call.Name)
+	if !exists {
+		return fmt.Sprintf("error: unknown tool %q", call.Name)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			output = fmt.Sprintf("error: tool %q panicked: %v", call.Name, r)
+		}
+	}()
+
+	output, err := tool.Call(ctx, call.Arguments)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return output
+}
+
+// Chat sends messages with the tools of the set and executes the tools the
+// model calls, sending back their results, until it answers without calling
+// tools. It returns the last response, with the usage of all calls, and the
+// messages the exchange added after messages. When the model still calls
+// tools after the maximum number of rounds, both are returned with ErrMaxRounds.
+func (s *Set) Chat(ctx context.Context, chatter Chatter, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, []providers.Message, error) {
+	return s.run(ctx, chatter.Chat, messages, s.Apply(opts))
+}
+
+// Middleware returns middleware that offers the tools of the set with every
+// chat request and executes the calls of the model as Chat does, so callers
+// only see its final answer. Raw requests pass through unchanged.
+func (s *Set) Middleware() providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, error) {
+			if opts.Raw {
+				return next(ctx, messages, opts)
+			}
+			resp, _, err := s.run(ctx, next, messages, s.Apply(opts))
+			return resp, err
+		}
+	}
+}
+
+// run is the loop of Chat, sending the requests with handler
+func (s *Set) run(ctx context.Context, handler providers.ChatHandler, messages []providers.Message, opts providers.RequestOptions) (*providers.CompletionResponse, []providers.Message, error) {
+	s.mu.RLock()
+	maxRounds := s.maxRounds
+	s.mu.RUnlock()
+
+	conversation := append([]providers.Message(nil), messages...)
+	var added []providers.Message
+	var usage providers.TokenUsage
+	var resp *providers.CompletionResponse
+
+	for round := 0; round < maxRounds; round++ {
+		var err error
+		resp, err = handler(ctx, conversation, opts)
+		if err != nil {
+			return nil, added, err
+		}
+		usage = addUsage(usage, resp.Usage)
+		resp.Usage = usage
+
+		reply := providers.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls}
+		conversation = append(conversation, reply)
+		added = append(added, reply)
+		if len(resp.ToolCalls) == 0 {
+			return resp, added, nil
+		}
+
+		results := s.Execute(ctx, resp.ToolCalls)
+		conversation = append(conversation, results...)
+		added = append(added, results...)
+	}
+	return resp, added, fmt.Errorf("%w (%d)", ErrMaxRounds, maxRounds)
+}
+
+// addUsage returns the sum of two usages
+func addUsage(a, b providers.TokenUsage) providers.TokenUsage {
+	a.PromptTokens += b.PromptTokens
+	a.CompletionTokens += b.CompletionTokens
+	a.TotalTokens += b.TotalTokens
+	a.CachedTokens += b.CachedTokens
+	a.ReasoningTokens += b.ReasoningTokens
+	return a
+}
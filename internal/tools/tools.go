@@ -0,0 +1,96 @@
+// Package tools defines tools from Go functions, with argument schemas
+// generated from Go types, and runs the tool calls of models until they answer
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+// Tool is a function the model may call, with the definition offered to it
+type Tool struct {
+	definition providers.Tool
+	call       func(ctx context.Context, arguments string) (string, error)
+}
+
+// New creates a tool calling fn with the arguments of the model decoded into
+// a value of the type of args, whose JSON schema is sent as the parameters
+// of the tool (see Schema). The result of fn is sent back as is if it is a
+// string, JSON encoded otherwise.
+//
+//	type weatherArgs struct {
+//		City string `json:"city" description:"City name"`
+//	}
+//
+//	weather := tools.New("get_weather", weatherArgs{}, func(ctx context.Context, args weatherArgs) (any, error) {
+//		return lookupWeather(ctx, args.City)
+//	})
+func New[T any](name string, args T, fn func(ctx context.Context, args T) (any, error)) *Tool {
+	return &Tool{
+		definition: providers.Tool{Name: name, Parameters: Schema(args)},
+		call: func(ctx context.Context, arguments string) (string, error) {
+			var decoded T
+			if arguments != "" {
+				if err := json.Unmarshal([]byte(arguments), &decoded); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+			}
+			result, err := fn(ctx, decoded)
+			if err != nil {
+				return "", err
+			}
+			return encodeResult(result)
+		},
+	}
+}
+
+// Func creates a tool from a hand-written definition, calling fn with the
+// JSON arguments of the model
+func Func(definition providers.Tool, fn func(ctx context.Context, arguments string) (string, error)) *Tool {
+	return &Tool{definition: definition, call: fn}
+}
+
+// WithDescription sets the description of the tool, which tells the model
+// when to call it, and returns the tool
+func (t *Tool) WithDescription(description string) *Tool {
+	t.definition.Description = description
+	return t
+}
+
+// Name returns the name of the tool
+func (t *Tool) Name() string {
+	return t.definition.Name
+}
+
+// Definition returns the definition of the tool sent to the model
+func (t *Tool) Definition() providers.Tool {
+	return t.definition
+}
+
+// Call executes the tool with the JSON arguments of a tool call and returns
+// the result to send back
+func (t *Tool) Call(ctx context.Context, arguments string) (string, error) {
+	return t.call(ctx, arguments)
+}
+
+// encodeResult returns the text sent back for the result of a tool
+func encodeResult(result interface{}) (string, error) {
+	switch r := result.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return r, nil
+	case []byte:
+		return string(r), nil
+	case json.RawMessage:
+		return string(r), nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("encoding result: %w", err)
+	}
+	return string(encoded), nil
+}
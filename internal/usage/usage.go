@@ -0,0 +1,237 @@
+// Package usage rolls recorded request events up into usage and cost reports
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/analytics"
+)
+
+// Dimension is a field that usage can be grouped by
+type Dimension string
+
+const (
+	ByProvider Dimension = "provider"
+	ByModel    Dimension = "model"
+	ByKey      Dimension = "key"
+	ByTenant   Dimension = "tenant"
+	ByDay      Dimension = "day"
+)
+
+// dayLayout formats days in UTC
+const dayLayout = "2006-01-02"
+
+// ParseDimensions parses a comma separated list of dimensions, e.g. "provider,day"
+func ParseDimensions(list string) ([]Dimension, error) {
+	var dims []Dimension
+	for _, name := range strings.Split(list, ",") {
+		dim := Dimension(strings.TrimSpace(name))
+		switch dim {
+		case ByProvider, ByModel, ByKey, ByTenant, ByDay:
+			dims = append(dims, dim)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown dimension %q (available: provider, model, key, tenant, day)", dim)
+		}
+	}
+	return dims, nil
+}
+
+// Query selects the events of a report and how they are grouped
+type Query struct {
+	Since   time.Time
+	Until   time.Time // zero means no upper bound
+	GroupBy []Dimension
+}
+
+// Row is the usage of one group. Fields of dimensions that aren't grouped by are empty.
+type Row struct {
+	Day          string  `json:"day,omitempty"`
+	Provider     string  `json:"provider,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	KeyName      string  `json:"key_name,omitempty"`
+	Tenant       string  `json:"tenant,omitempty"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// Rollup groups the events matching q and sums their usage and cost. Rows are
+// ordered by day, provider, model and key.
+func Rollup(events []analytics.Event, q Query) []Row {
+	groups := make(map[Row]*Row)
+	for _, event := range events {
+		if event.Time.Before(q.Since) || (!q.Until.IsZero() && !event.Time.Before(q.Until)) {
+			continue
+		}
+
+		var id Row
+		for _, dim := range q.GroupBy {
+			switch dim {
+			case ByProvider:
+				id.Provider = event.Provider
+			case ByModel:
+				id.Model = event.Model
+			case ByKey:
+				id.KeyName = event.KeyName
+			case ByTenant:
+				id.Tenant = event.Tenant
+			case ByDay:
+				id.Day = event.Time.UTC().Format(dayLayout)
+			}
+		}
+
+		row, exists := groups[id]
+		if !exists {
+			row = &Row{Day: id.Day, Provider: id.Provider, Model: id.Model, KeyName: id.KeyName, Tenant: id.Tenant}
+			groups[id] = row
+		}
+		row.Requests++
+		if event.Error != "" {
+			row.Errors++
+		}
+		row.InputTokens += int64(event.InputTokens)
+		row.OutputTokens += int64(event.OutputTokens)
+		row.Cost += event.Cost
+	}
+
+	rows := make([]Row, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.KeyName != b.KeyName {
+			return a.KeyName < b.KeyName
+		}
+		return a.Tenant < b.Tenant
+	})
+	return rows
+}
+
+// CostByProvider returns the cost of each provider over the last window, e.g. 30 days
+func CostByProvider(events []analytics.Event, window time.Duration, now time.Time) map[string]float64 {
+	costs := make(map[string]float64)
+	for _, row := range Rollup(events, Query{Since: now.Add(-window), GroupBy: []Dimension{ByProvider}}) {
+		costs[row.Provider] = row.Cost
+	}
+	return costs
+}
+
+// Forecast projects the spend of the current calendar month (UTC) from the
+// recent burn rate
+type Forecast struct {
+	Provider   string        `json:"provider,omitempty"` // empty for all providers
+	Window     time.Duration `json:"window"`
+	MonthStart time.Time     `json:"month_start"`
+	MonthEnd   time.Time     `json:"month_end"`
+	Spent      float64       `json:"spent"`     // cost so far this month
+	BurnRate   float64       `json:"burn_rate"` // average cost per day over the window
+	Projected  float64       `json:"projected"` // projected cost at the end of the month
+}
+
+// ForecastSpend projects the month-end cost of provider, or of all providers
+// if provider is empty: the cost so far this month plus the average daily cost
+// over the last window for the rest of the month. events must reach back to
+// the start of the month and the start of the window.
+func ForecastSpend(events []analytics.Event, provider string, window time.Duration, now time.Time) Forecast {
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	forecast := Forecast{
+		Provider:   provider,
+		Window:     window,
+		MonthStart: monthStart,
+		MonthEnd:   monthStart.AddDate(0, 1, 0),
+	}
+
+	windowStart := now.Add(-window)
+	var windowCost float64
+	for _, event := range events {
+		if (provider != "" && event.Provider != provider) || event.Time.After(now) {
+			continue
+		}
+		if !event.Time.Before(monthStart) {
+			forecast.Spent += event.Cost
+		}
+		if !event.Time.Before(windowStart) {
+			windowCost += event.Cost
+		}
+	}
+
+	if window > 0 {
+		forecast.BurnRate = windowCost / window.Hours() * 24
+	}
+	forecast.Projected = forecast.Spent + forecast.BurnRate*forecast.MonthEnd.Sub(now).Hours()/24
+	return forecast
+}
+
+// WriteJSON writes rows as a JSON array
+func WriteJSON(w io.Writer, rows []Row) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// WriteCSV writes rows as CSV with a header line. Only the grouped dimensions
+// are written as columns, followed by the usage columns.
+func WriteCSV(w io.Writer, rows []Row, groupBy []Dimension) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, 0, len(groupBy)+5)
+	for _, dim := range groupBy {
+		header = append(header, string(dim))
+	}
+	header = append(header, "requests", "errors", "input_tokens", "output_tokens", "cost")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := make([]string, 0, len(header))
+		for _, dim := range groupBy {
+			switch dim {
+			case ByProvider:
+				record = append(record, row.Provider)
+			case ByModel:
+				record = append(record, row.Model)
+			case ByKey:
+				record = append(record, row.KeyName)
+			case ByTenant:
+				record = append(record, row.Tenant)
+			case ByDay:
+				record = append(record, row.Day)
+			}
+		}
+		record = append(record,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.Errors, 10),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
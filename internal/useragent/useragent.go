@@ -0,0 +1,97 @@
+// Package useragent identifies gollmkit and the application using it to
+// providers, so they can attribute traffic during support escalations
+package useragent
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// modulePath is the module gollmkit is built from
+const modulePath = "github.com/gollmkit/gollmkit"
+
+// Version returns the gollmkit release the binary was built with, or
+// "devel" when it isn't built from a released module
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "devel"
+}
+
+// String returns the User-Agent sent to providers: the application's product
+// tokens, if any, followed by gollmkit's version and the Go version,
+// e.g. "billing-service/2.3 gollmkit/v0.4.0 (go1.23.5)"
+func String(application string) string {
+	ua := "gollmkit/" + Version() + " (" + runtime.Version() + ")"
+	if application = strings.TrimSpace(application); application != "" {
+		return application + " " + ua
+	}
+	return ua
+}
+
+// Transport sets the User-Agent and additional headers on every request that
+// doesn't set them itself, then sends it with Base
+type Transport struct {
+	Base      http.RoundTripper // http.DefaultTransport if nil
+	UserAgent string
+	Header    http.Header
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	for name, values := range t.Header {
+		if req.Header.Get(name) == "" {
+			req.Header[name] = values
+		}
+	}
+	return base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of Base, if it keeps any
+func (t *Transport) CloseIdleConnections() {
+	if closer, ok := t.Base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Wrap returns a copy of client whose requests carry the User-Agent of
+// application and header. client itself is left unchanged.
+func Wrap(client *http.Client, application string, header map[string]string) *http.Client {
+	transport := &Transport{Base: client.Transport, UserAgent: String(application), Header: make(http.Header, len(header))}
+	if inner, ok := client.Transport.(*Transport); ok {
+		// Rewrapping replaces the previous settings rather than stacking them
+		transport.Base = inner.Base
+	}
+	for name, value := range header {
+		transport.Header.Set(name, value)
+	}
+
+	wrapped := *client
+	wrapped.Transport = transport
+	return &wrapped
+}
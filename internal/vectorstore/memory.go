@@ -0,0 +1,135 @@
+package vectorstore
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// MemoryStore keeps records in memory and searches them exhaustively. It
+// suits tests and collections of up to tens of thousands of records.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	records    map[string]Record
+	dimensions int
+}
+
+// NewMemoryStore creates an empty in-memory store. With zero dimensions,
+// the first record stored sets them.
+func NewMemoryStore(dimensions int) *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record), dimensions: dimensions}
+}
+
+// newMemoryFromConfig creates the memory store of vector_store
+func newMemoryFromConfig(cfg *config.Config) (VectorStore, error) {
+	return NewMemoryStore(cfg.VectorStore.Dimensions), nil
+}
+
+// Upsert implements VectorStore
+func (s *MemoryStore) Upsert(ctx context.Context, records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dimensions := s.dimensions
+	for _, record := range records {
+		if dimensions == 0 {
+			dimensions = len(record.Vector)
+		}
+		if err := checkDimensions(record.Vector, dimensions); err != nil {
+			return err
+		}
+	}
+	s.dimensions = dimensions
+
+	for _, record := range records {
+		record.Vector = append([]float32(nil), record.Vector...)
+		record.Metadata = copyMetadata(record.Metadata)
+		s.records[record.ID] = record
+	}
+	return nil
+}
+
+// Query implements VectorStore
+func (s *MemoryStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := checkDimensions(vector, s.dimensions); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	matches := make([]Match, 0, len(s.records))
+	for _, record := range s.records {
+		if !filter.matches(record.Metadata) {
+			continue
+		}
+		matches = append(matches, Match{
+			Record: Record{ID: record.ID, Content: record.Content, Metadata: copyMetadata(record.Metadata)},
+			Score:  cosineSimilarity(vector, record.Vector),
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// Delete implements VectorStore
+func (s *MemoryStore) Delete(ctx context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+// Len returns the number of records in the store
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Close implements VectorStore
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, zero if either is a zero vector
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// copyMetadata returns a copy of metadata, nil if it is empty
+func copyMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
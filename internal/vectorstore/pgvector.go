@@ -0,0 +1,219 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// schemaTimeout bounds the creation of the table of stores created from configuration
+const schemaTimeout = 30 * time.Second
+
+// tablePattern matches unquoted table names, optionally schema-qualified
+var tablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// PGVectorStore keeps records in a PostgreSQL table using the pgvector
+// extension, with the columns id, embedding, content and metadata (jsonb).
+// It uses any database/sql driver for PostgreSQL, such as pgx or lib/pq.
+type PGVectorStore struct {
+	db         *sql.DB
+	table      string
+	dimensions int
+	ownsDB     bool // close db with the store
+}
+
+// NewPGVectorStore creates a store of the records in table of db. Call
+// EnsureSchema to create the table if it doesn't exist.
+func NewPGVectorStore(db *sql.DB, table string, dimensions int) (*PGVectorStore, error) {
+	if !tablePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid pgvector table name %q", table)
+	}
+	return &PGVectorStore{db: db, table: table, dimensions: dimensions}, nil
+}
+
+// newPGVectorFromConfig opens the database of vector_store.dsn with the
+// registered vector_store.driver and creates the table if dimensions are set
+func newPGVectorFromConfig(cfg *config.Config) (VectorStore, error) {
+	storeCfg := cfg.VectorStore
+	db, err := sql.Open(storeCfg.GetDriver(), storeCfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("opening pgvector database: %w", err)
+	}
+	store, err := NewPGVectorStore(db, storeCfg.GetCollection(), storeCfg.Dimensions)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	store.ownsDB = true
+
+	if storeCfg.Dimensions > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaTimeout)
+		defer cancel()
+		if err := store.EnsureSchema(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// EnsureSchema creates the vector extension, the table and its HNSW cosine
+// index if they don't exist. It needs the dimensions of the store.
+func (s *PGVectorStore) EnsureSchema(ctx context.Context) error {
+	if s.dimensions <= 0 {
+		return fmt.Errorf("creating pgvector table %s: dimensions must be set", s.table)
+	}
+	index := strings.ReplaceAll(s.table, ".", "_") + "_embedding_idx"
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	embedding vector(%d) NOT NULL,
+	content TEXT NOT NULL DEFAULT '',
+	metadata JSONB NOT NULL DEFAULT '{}'::jsonb
+)`, s.table, s.dimensions),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING hnsw (embedding vector_cosine_ops)", index, s.table),
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("creating pgvector table %s: %w", s.table, err)
+		}
+	}
+	return nil
+}
+
+// Upsert implements VectorStore
+func (s *PGVectorStore) Upsert(ctx context.Context, records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	for _, record := range records {
+		if err := checkDimensions(record.Vector, s.dimensions); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("upserting into %s: %w", s.table, err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, embedding, content, metadata) VALUES ($1, $2::vector, $3, $4::jsonb)
+ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding, content = EXCLUDED.content, metadata = EXCLUDED.metadata`, s.table)
+	for _, record := range records {
+		metadata, err := metadataJSON(record.Metadata)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, record.ID, vectorLiteral(record.Vector), record.Content, metadata); err != nil {
+			return fmt.Errorf("upserting %s into %s: %w", record.ID, s.table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("upserting into %s: %w", s.table, err)
+	}
+	return nil
+}
+
+// Query implements VectorStore
+func (s *PGVectorStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	if err := checkDimensions(vector, s.dimensions); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+	metadata, err := metadataJSON(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT id, content, metadata::text, 1 - (embedding <=> $1::vector) FROM %s
+WHERE metadata @> $2::jsonb ORDER BY embedding <=> $1::vector LIMIT $3`, s.table)
+	rows, err := s.db.QueryContext(ctx, query, vectorLiteral(vector), metadata, k)
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", s.table, err)
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var match Match
+		var metadata string
+		if err := rows.Scan(&match.ID, &match.Content, &metadata, &match.Score); err != nil {
+			return nil, fmt.Errorf("querying %s: %w", s.table, err)
+		}
+		if err := json.Unmarshal([]byte(metadata), &match.Metadata); err != nil {
+			return nil, fmt.Errorf("decoding metadata of %s: %w", match.ID, err)
+		}
+		if len(match.Metadata) == 0 {
+			match.Metadata = nil
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying %s: %w", s.table, err)
+	}
+	return matches, nil
+}
+
+// Delete implements VectorStore
+func (s *PGVectorStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", s.table, strings.Join(placeholders, ", "))
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("deleting from %s: %w", s.table, err)
+	}
+	return nil
+}
+
+// Close implements VectorStore. The database is closed only if the store
+// opened it from configuration.
+func (s *PGVectorStore) Close() error {
+	if !s.ownsDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// vectorLiteral formats vector as a pgvector literal, e.g. "[0.1,0.2]"
+func vectorLiteral(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// metadataJSON encodes metadata as a JSON object, "{}" if it is empty
+func metadataJSON(metadata map[string]string) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("encoding metadata: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,256 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/useragent"
+)
+
+// QdrantOptions configures a Qdrant store
+type QdrantOptions struct {
+	APIKey     string
+	Dimensions int          // needed by EnsureCollection
+	HTTPClient *http.Client // defaults to http.DefaultClient
+}
+
+// QdrantStore keeps records as points of a Qdrant collection, using its
+// REST API. Point IDs are UUIDs derived from the record IDs, which are kept
+// in the payload with the content and metadata.
+type QdrantStore struct {
+	baseURL    string
+	collection string
+	apiKey     string
+	dimensions int
+	client     *http.Client
+}
+
+// NewQdrantStore creates a store of the points of collection on the Qdrant
+// server at baseURL, e.g. http://localhost:6333. Call EnsureCollection to
+// create the collection if it doesn't exist.
+func NewQdrantStore(baseURL, collection string, opts QdrantOptions) *QdrantStore {
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &QdrantStore{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		collection: collection,
+		apiKey:     opts.APIKey,
+		dimensions: opts.Dimensions,
+		client:     useragent.Wrap(client, "", nil),
+	}
+}
+
+// newQdrantFromConfig creates the Qdrant store of vector_store and its
+// collection if dimensions are set
+func newQdrantFromConfig(cfg *config.Config) (VectorStore, error) {
+	storeCfg := cfg.VectorStore
+	store := NewQdrantStore(storeCfg.URL, storeCfg.GetCollection(), QdrantOptions{
+		APIKey:     storeCfg.APIKey,
+		Dimensions: storeCfg.Dimensions,
+	})
+	if storeCfg.Dimensions > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaTimeout)
+		defer cancel()
+		if err := store.EnsureCollection(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// EnsureCollection creates the collection with cosine distance if it
+// doesn't exist. It needs the dimensions of the store.
+func (s *QdrantStore) EnsureCollection(ctx context.Context) error {
+	err := s.do(ctx, http.MethodGet, "", nil, nil)
+	if err == nil {
+		return nil
+	}
+	if statusErr, ok := err.(*qdrantStatusError); !ok || statusErr.status != http.StatusNotFound {
+		return fmt.Errorf("checking qdrant collection %s: %w", s.collection, err)
+	}
+
+	if s.dimensions <= 0 {
+		return fmt.Errorf("creating qdrant collection %s: dimensions must be set", s.collection)
+	}
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{"size": s.dimensions, "distance": "Cosine"},
+	}
+	if err := s.do(ctx, http.MethodPut, "", body, nil); err != nil {
+		return fmt.Errorf("creating qdrant collection %s: %w", s.collection, err)
+	}
+	return nil
+}
+
+// Upsert implements VectorStore
+func (s *QdrantStore) Upsert(ctx context.Context, records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	points := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		if err := checkDimensions(record.Vector, s.dimensions); err != nil {
+			return err
+		}
+		payload := map[string]interface{}{"id": record.ID, "content": record.Content}
+		if len(record.Metadata) > 0 {
+			payload["metadata"] = record.Metadata
+		}
+		points = append(points, map[string]interface{}{
+			"id":      pointID(record.ID),
+			"vector":  record.Vector,
+			"payload": payload,
+		})
+	}
+	if err := s.do(ctx, http.MethodPut, "/points?wait=true", map[string]interface{}{"points": points}, nil); err != nil {
+		return fmt.Errorf("upserting into qdrant collection %s: %w", s.collection, err)
+	}
+	return nil
+}
+
+// Query implements VectorStore
+func (s *QdrantStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	if err := checkDimensions(vector, s.dimensions); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	body := map[string]interface{}{"vector": vector, "limit": k, "with_payload": true}
+	if len(filter) > 0 {
+		conditions := make([]interface{}, 0, len(filter))
+		for key, value := range filter {
+			conditions = append(conditions, map[string]interface{}{
+				"key":   "metadata." + key,
+				"match": map[string]interface{}{"value": value},
+			})
+		}
+		body["filter"] = map[string]interface{}{"must": conditions}
+	}
+
+	var result struct {
+		Result []struct {
+			Score   float64 `json:"score"`
+			Payload struct {
+				ID       string            `json:"id"`
+				Content  string            `json:"content"`
+				Metadata map[string]string `json:"metadata"`
+			} `json:"payload"`
+		} `json:"result"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/points/search", body, &result); err != nil {
+		return nil, fmt.Errorf("querying qdrant collection %s: %w", s.collection, err)
+	}
+
+	matches := make([]Match, 0, len(result.Result))
+	for _, point := range result.Result {
+		matches = append(matches, Match{
+			Record: Record{ID: point.Payload.ID, Content: point.Payload.Content, Metadata: point.Payload.Metadata},
+			Score:  point.Score,
+		})
+	}
+	return matches, nil
+}
+
+// Delete implements VectorStore
+func (s *QdrantStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	points := make([]string, len(ids))
+	for i, id := range ids {
+		points[i] = pointID(id)
+	}
+	if err := s.do(ctx, http.MethodPost, "/points/delete?wait=true", map[string]interface{}{"points": points}, nil); err != nil {
+		return fmt.Errorf("deleting from qdrant collection %s: %w", s.collection, err)
+	}
+	return nil
+}
+
+// Close implements VectorStore
+func (s *QdrantStore) Close() error {
+	if closer, ok := s.client.Transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	return nil
+}
+
+// qdrantStatusError is an unsuccessful response of the Qdrant API
+type qdrantStatusError struct {
+	status  int
+	message string
+}
+
+func (e *qdrantStatusError) Error() string {
+	return fmt.Sprintf("qdrant returned status %d: %s", e.status, e.message)
+}
+
+// do sends a request to path under the collection and decodes the response into result
+func (s *QdrantStore) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	endpoint := s.baseURL + "/collections/" + url.PathEscape(s.collection) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.apiKey != "" {
+		req.Header.Set("api-key", s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var apiErr struct {
+			Status struct {
+				Error string `json:"error"`
+			} `json:"status"`
+		}
+		message := strings.TrimSpace(string(raw))
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Status.Error != "" {
+			message = apiErr.Status.Error
+		}
+		return &qdrantStatusError{status: resp.StatusCode, message: message}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding qdrant response: %w", err)
+	}
+	return nil
+}
+
+// pointID derives the UUID of the point of a record ID, as Qdrant only
+// accepts UUIDs and integers as point IDs. It is a name-based (version 5) UUID.
+func pointID(id string) string {
+	sum := sha1.Sum([]byte("gollmkit:" + id))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
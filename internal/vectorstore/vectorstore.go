@@ -0,0 +1,135 @@
+// Package vectorstore stores embeddings and finds the nearest ones to a
+// query vector, backing semantic caching and retrieval. Stores are kept in
+// memory, in PostgreSQL with pgvector, or in Qdrant, selected with the
+// vector_store section of the configuration.
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gollmkit/gollmkit/internal/config"
+)
+
+// ErrDimensionMismatch is returned for vectors whose length differs from the dimensions of the store
+var ErrDimensionMismatch = errors.New("vector dimensions don't match the store")
+
+// Record is an embedding with the content it was computed from
+type Record struct {
+	ID       string            `json:"id"`
+	Vector   []float32         `json:"vector,omitempty"`
+	Content  string            `json:"content,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Match is a record found by a query. Score is the cosine similarity of its
+// vector to the query vector, from -1 to 1, higher being closer. The Vector
+// of matches isn't set.
+type Match struct {
+	Record
+	Score float64 `json:"score"`
+}
+
+// Filter restricts a query to the records whose metadata has every key set
+// to the given value. A nil filter matches every record.
+type Filter map[string]string
+
+// matches reports whether metadata satisfies the filter
+func (f Filter) matches(metadata map[string]string) bool {
+	for key, value := range f {
+		if actual, ok := metadata[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// VectorStore stores records and finds the nearest ones to a vector by
+// cosine similarity. Implementations are safe for concurrent use.
+type VectorStore interface {
+	// Upsert stores records, replacing the records with the same IDs
+	Upsert(ctx context.Context, records ...Record) error
+
+	// Query returns the k records nearest to vector that match filter,
+	// nearest first
+	Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error)
+
+	// Delete removes the records with the given IDs. Unknown IDs are ignored.
+	Delete(ctx context.Context, ids ...string) error
+
+	// Close releases the connections of the store
+	Close() error
+}
+
+// Factory creates a vector store from the vector_store section of cfg
+type Factory func(cfg *config.Config) (VectorStore, error)
+
+var (
+	storesMu sync.RWMutex
+	stores   = map[string]Factory{
+		config.VectorStoreMemory:   newMemoryFromConfig,
+		config.VectorStorePGVector: newPGVectorFromConfig,
+		config.VectorStoreQdrant:   newQdrantFromConfig,
+	}
+)
+
+// Register registers a vector store type selectable with vector_store.type,
+// e.g. Register("pinecone", newPineconeStore). Registering an existing type
+// replaces it. Backend-specific settings are available to the factory in
+// vector_store.options.
+func Register(name string, factory Factory) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	stores[name] = factory
+}
+
+// Unregister removes a previously registered vector store type
+func Unregister(name string) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	delete(stores, name)
+}
+
+// Types returns the registered vector store types, sorted
+func Types() []string {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFromConfig creates the vector store of the type registered for
+// vector_store.type, memory if it is empty
+func NewFromConfig(cfg *config.Config) (VectorStore, error) {
+	name := cfg.VectorStore.Type
+	if name == "" {
+		name = config.VectorStoreMemory
+	}
+
+	storesMu.RLock()
+	factory, exists := stores[name]
+	storesMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown vector store type %q, registered: %s",
+			name, strings.Join(Types(), ", "))
+	}
+	return factory(cfg)
+}
+
+// checkDimensions returns ErrDimensionMismatch if vector doesn't have
+// dimensions elements. Zero dimensions accept any length.
+func checkDimensions(vector []float32, dimensions int) error {
+	if dimensions > 0 && len(vector) != dimensions {
+		return fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(vector), dimensions)
+	}
+	return nil
+}
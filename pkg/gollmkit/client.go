@@ -1 +1,55 @@
+// Package gollmkit is the public entry point for applications and plug-ins
+// outside this module. It re-exports the client and the registration APIs of
+// the internal packages; the types are aliases, so values can be passed to
+// and from them without conversion.
 package gollmkit
+
+import (
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/providers"
+)
+
+type (
+	// Config is the gollmkit configuration
+	Config = config.Config
+
+	// Client sends requests to every configured provider
+	Client = providers.UnifiedProvider
+
+	// KeyStore stores the API keys of the providers
+	KeyStore = auth.KeyStore
+
+	// KeyRotator selects the key of each request
+	KeyRotator = auth.KeyRotator
+
+	// KeyValidator checks keys before they are used
+	KeyValidator = auth.KeyValidator
+)
+
+// LoadConfig loads configuration from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// NewKeyStoreFromConfig creates the key store configured in
+// global.key_store, populated with the keys of the configuration
+func NewKeyStoreFromConfig(cfg *Config) (KeyStore, error) {
+	return auth.NewKeyStoreFromConfig(cfg)
+}
+
+// NewKeyRotator creates a key rotator selecting keys from keyStore
+func NewKeyRotator(cfg *Config, keyStore KeyStore) *KeyRotator {
+	return auth.NewKeyRotator(cfg, keyStore)
+}
+
+// NewKeyValidator creates a key validator
+func NewKeyValidator() *KeyValidator {
+	return auth.NewKeyValidator()
+}
+
+// NewClient creates a client for the providers of cfg, built-in and
+// registered with Register. validator may be nil.
+func NewClient(cfg *Config, rotator *KeyRotator, validator *KeyValidator) *Client {
+	return providers.NewUnifiedProvider(cfg, rotator, validator)
+}
@@ -1 +1,11 @@
 package gollmkit
+
+import "github.com/gollmkit/gollmkit/internal/providers"
+
+// RequestOptions configures a request: provider, model, sampling and more
+type RequestOptions = providers.RequestOptions
+
+// DefaultOptions returns the default options of a provider
+func DefaultOptions(provider ProviderType) RequestOptions {
+	return providers.DefaultOptions(provider)
+}
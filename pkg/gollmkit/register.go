@@ -0,0 +1,217 @@
+package gollmkit
+
+import (
+	"context"
+	"time"
+
+	"github.com/gollmkit/gollmkit/internal/auth"
+	"github.com/gollmkit/gollmkit/internal/config"
+	"github.com/gollmkit/gollmkit/internal/document"
+	"github.com/gollmkit/gollmkit/internal/providers"
+	"github.com/gollmkit/gollmkit/internal/tokenizer"
+	"github.com/gollmkit/gollmkit/internal/vectorstore"
+)
+
+type (
+	// ProviderConfig is the configuration of a provider under providers.<name>
+	ProviderConfig = config.ProviderConfig
+
+	// Backend sends the requests of a provider registered with Register
+	Backend = providers.Backend
+
+	// BackendFunc adapts a plain function to the Backend interface
+	BackendFunc = providers.BackendFunc
+
+	// RawBackend is implemented by backends supporting raw completions
+	RawBackend = providers.RawBackend
+
+	// ProviderFactory creates the backend of a registered provider
+	ProviderFactory = providers.Factory
+
+	// KeySelection is the key selected for a request
+	KeySelection = auth.KeySelection
+)
+
+// Register registers a provider implemented outside gollmkit, see
+// providers.Register
+func Register(name ProviderType, factory ProviderFactory) {
+	providers.Register(name, factory)
+}
+
+// Unregister removes a previously registered provider
+func Unregister(name ProviderType) {
+	providers.Unregister(name)
+}
+
+// RegisteredProviders returns the registered providers, sorted
+func RegisteredProviders() []ProviderType {
+	return providers.Registered()
+}
+
+type (
+	// KeyStoreFactory creates an empty key store from configuration
+	KeyStoreFactory = auth.KeyStoreFactory
+
+	// KeyUsage is the usage of a key
+	KeyUsage = auth.KeyUsage
+
+	// MasterKey wraps the data key of a file key store, e.g. with a KMS
+	MasterKey = auth.MasterKey
+)
+
+// RegisterKeyStore registers a key store type selectable with
+// global.key_store.type. Registering an existing type replaces it.
+func RegisterKeyStore(name string, factory KeyStoreFactory) {
+	auth.RegisterKeyStore(name, factory)
+}
+
+// UnregisterKeyStore removes a previously registered key store type
+func UnregisterKeyStore(name string) {
+	auth.UnregisterKeyStore(name)
+}
+
+// KeyStoreTypes returns the registered key store types, sorted
+func KeyStoreTypes() []string {
+	return auth.KeyStoreTypes()
+}
+
+// NewFileKeyStoreWithMasterKey opens the file key store at path with envelope
+// encryption by master, e.g. a KMS not built into gollmkit. Return it from a
+// KeyStoreFactory to select it by configuration.
+func NewFileKeyStoreWithMasterKey(ctx context.Context, path string, master MasterKey, flushInterval time.Duration) (KeyStore, error) {
+	store, err := auth.NewFileKeyStoreWithMasterKey(ctx, path, master, flushInterval)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+type (
+	// StrategyName names a rotation strategy in rotation.strategy
+	StrategyName = config.RotationStrategy
+
+	// RotationStrategy is a custom key selection strategy
+	RotationStrategy = auth.RotationStrategy
+
+	// RotationStrategyFunc adapts a plain function to the RotationStrategy interface
+	RotationStrategyFunc = auth.RotationStrategyFunc
+
+	// APIKey is a configured API key
+	APIKey = config.APIKey
+)
+
+// RegisterRotationStrategy registers a strategy selectable by name with
+// rotation.strategy. Register strategies before loading the configuration,
+// which is validated against them.
+func RegisterRotationStrategy(name StrategyName, strategy RotationStrategy) {
+	auth.RegisterRotationStrategy(name, strategy)
+}
+
+// UnregisterRotationStrategy removes a previously registered strategy
+func UnregisterRotationStrategy(name StrategyName) {
+	auth.UnregisterRotationStrategy(name)
+}
+
+type (
+	// Tokenizer counts the tokens of a piece of text for a model
+	Tokenizer = tokenizer.Tokenizer
+
+	// TokenizerFunc adapts a plain function to the Tokenizer interface
+	TokenizerFunc = tokenizer.TokenizerFunc
+)
+
+// RegisterTokenizer registers the tokenizer of a model, used for token
+// counts and cost estimates. A trailing "*" registers it for every model
+// starting with the prefix, e.g. "llama-3-*".
+func RegisterTokenizer(model string, t Tokenizer) {
+	tokenizer.Register(model, t)
+}
+
+// UnregisterTokenizer removes a previously registered tokenizer
+func UnregisterTokenizer(model string) {
+	tokenizer.Unregister(model)
+}
+
+type (
+	// ProviderValidator validates the keys of one provider
+	ProviderValidator = auth.ProviderValidator
+
+	// FullProber is implemented by validators with a billable full probe
+	FullProber = auth.FullProber
+
+	// PatternValidator checks key formats with a regular expression and an
+	// optional live probe
+	PatternValidator = auth.PatternValidator
+
+	// ProbeFunc is the live check of a PatternValidator
+	ProbeFunc = auth.ProbeFunc
+
+	// ValidationResult is the result of validating a key
+	ValidationResult = auth.ValidationResult
+)
+
+// RegisterValidator registers the validator of a provider's keys, replacing
+// the validator of a built-in provider. Provider names are case-insensitive.
+func RegisterValidator(provider string, validator ProviderValidator) {
+	auth.RegisterValidator(provider, validator)
+}
+
+// UnregisterValidator removes the validator of a provider
+func UnregisterValidator(provider string) {
+	auth.UnregisterValidator(provider)
+}
+
+// ValidatedProviders returns the providers with a registered validator, sorted
+func ValidatedProviders() []string {
+	return auth.ValidatedProviders()
+}
+
+// BearerProbe returns a probe for OpenAI-compatible APIs, listing the models
+// at modelsURL with the key as bearer token
+func BearerProbe(modelsURL string) ProbeFunc {
+	return auth.BearerProbe(modelsURL)
+}
+
+// DocumentExtractor returns the text of a document
+type DocumentExtractor = document.Extractor
+
+// RegisterDocumentExtractor registers the extractor of a MIME type, used for
+// attachments the provider can't read natively. Registering an existing
+// type, built-in ones included, replaces it.
+func RegisterDocumentExtractor(mimeType string, extractor DocumentExtractor) {
+	document.Register(mimeType, extractor)
+}
+
+// UnregisterDocumentExtractor removes the extractor of a MIME type
+func UnregisterDocumentExtractor(mimeType string) {
+	document.Unregister(mimeType)
+}
+
+type (
+	// VectorStore stores embeddings and finds the nearest ones to a vector
+	VectorStore = vectorstore.VectorStore
+
+	// VectorStoreFactory creates a vector store from the vector_store section
+	// of the configuration
+	VectorStoreFactory = vectorstore.Factory
+
+	// VectorRecord is an embedding with the content it was computed from
+	VectorRecord = vectorstore.Record
+
+	// VectorMatch is a record found by a vector store query
+	VectorMatch = vectorstore.Match
+
+	// VectorFilter restricts a query to records with matching metadata
+	VectorFilter = vectorstore.Filter
+)
+
+// RegisterVectorStore registers a vector store type selectable with
+// vector_store.type. Registering an existing type replaces it.
+func RegisterVectorStore(name string, factory VectorStoreFactory) {
+	vectorstore.Register(name, factory)
+}
+
+// UnregisterVectorStore removes a previously registered vector store type
+func UnregisterVectorStore(name string) {
+	vectorstore.Unregister(name)
+}
@@ -1 +1,21 @@
 package gollmkit
+
+import "github.com/gollmkit/gollmkit/internal/providers"
+
+type (
+	// ProviderType names a provider, built-in or registered with Register
+	ProviderType = providers.ProviderType
+
+	// Message is a chat message
+	Message = providers.Message
+)
+
+// Built-in providers
+const (
+	OpenAI    = providers.OpenAI
+	Anthropic = providers.Anthropic
+	Gemini    = providers.Gemini
+	XAI       = providers.XAI
+	DeepSeek  = providers.DeepSeek
+	Vertex    = providers.Vertex
+)
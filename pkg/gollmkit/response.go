@@ -1 +1,14 @@
 package gollmkit
+
+import "github.com/gollmkit/gollmkit/internal/providers"
+
+type (
+	// CompletionResponse is the response to a chat request
+	CompletionResponse = providers.CompletionResponse
+
+	// TokenUsage is the token usage of a request
+	TokenUsage = providers.TokenUsage
+
+	// StreamChunk is a piece of a streamed response
+	StreamChunk = providers.StreamChunk
+)
@@ -0,0 +1,45 @@
+package gollmkit
+
+import (
+	"context"
+
+	"github.com/gollmkit/gollmkit/internal/providers"
+	"github.com/gollmkit/gollmkit/internal/tools"
+)
+
+type (
+	// Tool is a function the model may call, created with NewTool
+	Tool = tools.Tool
+
+	// ToolSet runs the tool calls of a model until it answers
+	ToolSet = tools.Set
+
+	// ToolDefinition is the definition of a tool sent to the model
+	ToolDefinition = providers.Tool
+)
+
+// NewTool creates a tool calling fn with the arguments of the model decoded
+// into a value of the type of args, see tools.New. The parameters of the
+// tool are the JSON schema of args, see ToolSchema.
+func NewTool[T any](name string, args T, fn func(ctx context.Context, args T) (any, error)) *Tool {
+	return tools.New(name, args, fn)
+}
+
+// ToolFunc creates a tool from a hand-written definition, calling fn with
+// the JSON arguments of the model
+func ToolFunc(definition ToolDefinition, fn func(ctx context.Context, arguments string) (string, error)) *Tool {
+	return tools.Func(definition, fn)
+}
+
+// NewToolSet creates a set of tools
+func NewToolSet(members ...*Tool) *ToolSet {
+	return tools.NewSet(members...)
+}
+
+// ToolSchema returns the JSON schema of the value v encodes to, generated
+// from its type. Struct fields are named by their json tags and required
+// unless they are pointers or tagged omitempty; description and enum tags
+// document them.
+func ToolSchema(v interface{}) map[string]interface{} {
+	return tools.Schema(v)
+}